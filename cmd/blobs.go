@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/joelanford/extensiondb/internal/blobstore"
+	"github.com/joelanford/extensiondb/internal/db"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/opencontainers/go-digest"
+	"github.com/spf13/cobra"
+)
+
+type blobStoreFlags struct {
+	url string
+}
+
+func (f *blobStoreFlags) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.url, "blob-store", "postgres:", "store of bundle ClusterServiceVersions: postgres: for large objects in the database, file:///<dir>, or s3://<bucket>/<prefix>")
+}
+
+// query returns a query of pdb that stores and reads blobs in the store named
+// by the flags.
+func (f *blobStoreFlags) query(pdb *db.DB) (*query.Query, error) {
+	store, err := blobstore.Open(f.url, pdb.DB)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --blob-store: %w", err)
+	}
+	return query.New(pdb.DB).WithBlobStore(store), nil
+}
+
+func newQueryCSVCmd() *cobra.Command {
+	var (
+		blobs     blobStoreFlags
+		digestArg string
+	)
+	cmd := &cobra.Command{
+		Use:   "csv",
+		Short: "Print the ClusterServiceVersion of a bundle as JSON",
+		Long: `Print the ClusterServiceVersion of a bundle, named by the digest of its image,
+as JSON.
+
+ClusterServiceVersions are stored in the blob store named by --blob-store when
+bundles are ingested, so it must name the store they were ingested with.
+Bundles ingested before ClusterServiceVersions were stored have none.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			dig, err := digest.Parse(digestArg)
+			if err != nil {
+				return fmt.Errorf("invalid --digest: %w", err)
+			}
+			pdb, err := openDB()
+			if err != nil {
+				return err
+			}
+			q, err := blobs.query(pdb)
+			if err != nil {
+				return err
+			}
+			b, err := q.GetBundleByDigest(cmd.Context(), dig)
+			if err != nil {
+				return fmt.Errorf("error getting bundle %s: %w", dig, err)
+			}
+			csv, err := q.GetBundleCSV(cmd.Context(), b)
+			if errors.Is(err, query.ErrNoCSV) {
+				return fmt.Errorf("bundle %s has no stored ClusterServiceVersion", dig)
+			}
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(cmd.OutOrStdout(), string(csv))
+			return err
+		},
+	}
+	blobs.addFlags(cmd)
+	cmd.Flags().StringVar(&digestArg, "digest", "", "digest of the bundle image")
+	return cmd
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/controller"
+	apiclient "github.com/joelanford/extensiondb/pkg/client"
+	"github.com/spf13/cobra"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+)
+
+func newControllerCmd() *cobra.Command {
+	var (
+		serverURL    string
+		tokenFile    string
+		kinds        []string
+		channel      string
+		resyncPeriod time.Duration
+		metricsAddr  string
+	)
+	cmd := &cobra.Command{
+		Use:   "controller",
+		Short: "Annotate installed operators with available updates and lifecycle phases",
+		Long: `Run a Kubernetes controller that watches installed operators, looks up their
+installed versions in an extensiondb server, and annotates each resource with
+the updates available from that version and the version's lifecycle phase.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctrl.SetLogger(zap.New())
+			logger := ctrl.Log.WithName("setup")
+
+			api, err := apiclient.New(serverURL, nil)
+			if err != nil {
+				return err
+			}
+			if tokenFile != "" {
+				token, err := readTokenFile(tokenFile)
+				if err != nil {
+					return err
+				}
+				api.BearerToken = token
+			}
+
+			mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+				Metrics: metricsserver.Options{BindAddress: metricsAddr},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create manager: %w", err)
+			}
+
+			watched := 0
+			for _, kind := range kinds {
+				var target controller.Target
+				switch kind {
+				case "subscription":
+					target = controller.Subscriptions
+				case "clusterextension":
+					target = controller.ClusterExtensions
+				default:
+					return fmt.Errorf("unknown kind %q; expected subscription or clusterextension", kind)
+				}
+				r := &controller.Reconciler{
+					Client:       mgr.GetClient(),
+					API:          api,
+					Target:       target,
+					Channel:      channel,
+					ResyncPeriod: resyncPeriod,
+				}
+				// Clusters may only have one version of OLM installed, so
+				// kinds they don't serve are skipped.
+				if err := r.SetupWithManager(mgr); errors.Is(err, controller.ErrNotServed) {
+					logger.Info("WARNING: skipping kind that is not served by the cluster", "kind", kind, "error", err.Error())
+					continue
+				} else if err != nil {
+					return fmt.Errorf("failed to set up %s controller: %w", kind, err)
+				}
+				watched++
+			}
+			if watched == 0 {
+				return fmt.Errorf("none of the kinds %s are served by the cluster", strings.Join(kinds, ", "))
+			}
+
+			return mgr.Start(cmd.Context())
+		},
+	}
+	cmd.Flags().StringVar(&serverURL, "server-url", "http://localhost:8080", "URL of the extensiondb server")
+	cmd.Flags().StringVar(&tokenFile, "token-file", "", "file containing a bearer token for the extensiondb server")
+	cmd.Flags().StringSliceVar(&kinds, "kinds", []string{"subscription", "clusterextension"}, "kinds of installed operators to watch (subscription, clusterextension); kinds the cluster doesn't serve are skipped")
+	cmd.Flags().StringVar(&channel, "channel", "stable", "update graph channel to consult")
+	cmd.Flags().DurationVar(&resyncPeriod, "resync-period", time.Hour, "how often to check each installed operator again")
+	cmd.Flags().StringVar(&metricsAddr, "metrics-bind-address", "0", "address for the controller metrics endpoint, or 0 to disable it")
+	return cmd
+}
+
+func readTokenFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+
+	"github.com/joelanford/extensiondb/internal/defaultchannels"
+	"github.com/joelanford/extensiondb/internal/printer"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/spf13/cobra"
+)
+
+type defaultChannelResult struct {
+	Package        string `json:"package"`
+	Tag            string `json:"tag"`
+	DefaultChannel string `json:"defaultChannel"`
+}
+
+func newQueryDefaultChannelsCmd(output *string) *cobra.Command {
+	var (
+		catalogName  string
+		packageNames []string
+		changes      bool
+	)
+	cmd := &cobra.Command{
+		Use:   "default-channels",
+		Short: "List the default channel of each package in each tag of a catalog",
+		Long: `List the default channel of each package in each tag of a catalog.
+
+The default channel of a package is what a new installation subscribes to, so
+a change of it between two OpenShift versions of a catalog silently changes
+what users get. Each tag is represented by its most recently ingested digest,
+and tags that name OpenShift versions, such as v4.9 and v4.10, are ordered by
+version. Default channels are only recorded for catalogs ingested from
+file-based catalogs, so tags ingested before then are missing.
+
+With --changes, only the changes of default channels between the successive
+tags that have each package are listed.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if catalogName == "" {
+				return errors.New("--catalog is required")
+			}
+			pdb, err := openDB()
+			if err != nil {
+				return err
+			}
+			channels, err := query.New(pdb.DB).ListDefaultChannelsByTag(cmd.Context(), catalogName, packageNames)
+			if err != nil {
+				return err
+			}
+
+			if changes {
+				return printer.Print(cmd.OutOrStdout(), *output, defaultchannels.Changes(channels), []printer.Column[defaultchannels.Change]{
+					{Header: "package", Value: func(c defaultchannels.Change) string { return c.Package }},
+					{Header: "from tag", Value: func(c defaultchannels.Change) string { return c.FromTag }},
+					{Header: "to tag", Value: func(c defaultchannels.Change) string { return c.ToTag }},
+					{Header: "from", Value: func(c defaultchannels.Change) string { return c.From }},
+					{Header: "to", Value: func(c defaultchannels.Change) string { return c.To }},
+				})
+			}
+
+			results := make([]defaultChannelResult, 0, len(channels))
+			for _, dc := range channels {
+				results = append(results, defaultChannelResult{Package: dc.Package, Tag: dc.Tag, DefaultChannel: dc.DefaultChannel})
+			}
+			slices.SortStableFunc(results, func(a, b defaultChannelResult) int {
+				return cmp.Or(cmp.Compare(a.Package, b.Package), defaultchannels.CompareTags(a.Tag, b.Tag))
+			})
+			return printer.Print(cmd.OutOrStdout(), *output, results, []printer.Column[defaultChannelResult]{
+				{Header: "package", Value: func(r defaultChannelResult) string { return r.Package }},
+				{Header: "tag", Value: func(r defaultChannelResult) string { return r.Tag }},
+				{Header: "default channel", Value: func(r defaultChannelResult) string { return r.DefaultChannel }},
+			})
+		},
+	}
+	cmd.Flags().StringVar(&catalogName, "catalog", "", "name of the catalog, such as redhat-operator-index")
+	cmd.Flags().StringSliceVar(&packageNames, "package", nil, "only list these packages")
+	cmd.Flags().BoolVar(&changes, "changes", false, "only list the changes of default channels between tags")
+	return cmd
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/joelanford/extensiondb/internal/catalogdiff"
+	"github.com/joelanford/extensiondb/internal/models"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/spf13/cobra"
+)
+
+func newDiffCmd() *cobra.Command {
+	var (
+		fromRef string
+		toRef   string
+		format  string
+		output  string
+	)
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Compare the packages, bundles, and default channels of two catalogs",
+		Long: `Compare the most recently ingested contents of two catalogs, such as
+redhat-operator-index:v4.18 and redhat-operator-index:v4.19, and report the
+packages and bundles that were added or removed and the packages whose default
+channel changed.
+
+Default channels are only recorded for catalogs ingested from file-based
+catalogs since they were first stored, so packages whose default channel is
+unknown in either catalog are not compared.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			pdb, err := openDB()
+			if err != nil {
+				return err
+			}
+			q := query.New(pdb.DB)
+
+			from, err := getCatalogFlag(cmd.Context(), q, "from", fromRef)
+			if err != nil {
+				return err
+			}
+			to, err := getCatalogFlag(cmd.Context(), q, "to", toRef)
+			if err != nil {
+				return err
+			}
+			d, err := catalogdiff.Compute(cmd.Context(), q, from, to)
+			if err != nil {
+				return err
+			}
+			return writeOutput(output, func(w io.Writer) error {
+				return d.Write(w, format)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&fromRef, "from", "", "catalog to compare from, as <name>:<tag>")
+	cmd.Flags().StringVar(&toRef, "to", "", "catalog to compare to, as <name>:<tag>")
+	cmd.Flags().StringVar(&format, "format", "markdown", "output format: "+catalogdiff.Formats)
+	cmd.Flags().StringVarP(&output, "output", "o", "-", "file to write to, or - for stdout")
+	_ = cmd.MarkFlagRequired("from")
+	_ = cmd.MarkFlagRequired("to")
+	return cmd
+}
+
+// getCatalogFlag returns the catalog named by the <name>:<tag> value of a flag.
+func getCatalogFlag(ctx context.Context, q *query.Query, flag, catalogRef string) (*models.Catalog, error) {
+	name, tag, ok := strings.Cut(catalogRef, ":")
+	if !ok {
+		return nil, fmt.Errorf("--%s must be of the form <name>:<tag>, got %q", flag, catalogRef)
+	}
+	c, err := q.GetCatalog(ctx, name, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get catalog %s: %w", catalogRef, err)
+	}
+	return c, nil
+}
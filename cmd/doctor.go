@@ -0,0 +1,101 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/doctor"
+	"github.com/joelanford/extensiondb/internal/ingest"
+	"github.com/joelanford/extensiondb/internal/printer"
+	"github.com/joelanford/extensiondb/migrations"
+	"github.com/spf13/cobra"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+func newDoctorCmd() *cobra.Command {
+	var (
+		templatesDir  string
+		catalogImages []string
+		graphCacheDir string
+		minFreeGiB    uint64
+		timeout       time.Duration
+		output        string
+	)
+	var defaultCatalogImages []string
+	for _, c := range ingest.DefaultConfig().Catalogs {
+		defaultCatalogImages = append(defaultCatalogImages, c.Image(c.Tags[0]))
+	}
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the environment for common problems",
+		Long: `Check the environment for common problems, and print a pass/fail report.
+
+The checks are:
+  - the database can be reached, and every migration has been applied
+  - each catalog image's tag can be resolved, which requires its registry to
+    be reachable and, if it requires them, valid credentials
+  - the file systems holding $CATALOGS_DIR, temporary files, and the graph
+    cache have enough free space
+  - the templates are valid
+
+Checks that depend on the database are skipped if it can't be reached. The
+command fails if any check fails, so attach its output when reporting that
+ingestion doesn't work.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			var refs []reference.NamedTagged
+			for _, image := range catalogImages {
+				named, err := reference.ParseNormalizedNamed(image)
+				if err != nil {
+					return fmt.Errorf("invalid --catalog-image %q: %w", image, err)
+				}
+				tagged, ok := named.(reference.NamedTagged)
+				if !ok {
+					return fmt.Errorf("invalid --catalog-image %q: expected a tag", image)
+				}
+				refs = append(refs, tagged)
+			}
+
+			pdb, dbErr := openDB()
+			checks := []doctor.Check{
+				{Name: "database", Run: func(context.Context) (string, error) { return "connected", dbErr }},
+				doctor.Migrations(pdb, migrations.FS),
+			}
+			for _, ref := range refs {
+				checks = append(checks, doctor.Registry(ref))
+			}
+			minFree := minFreeGiB << 30
+			checks = append(checks,
+				doctor.DiskSpace("catalogs", cmp.Or(os.Getenv("CATALOGS_DIR"), "."), minFree),
+				doctor.DiskSpace("temporary files", os.TempDir(), minFree),
+			)
+			if graphCacheDir != "" {
+				checks = append(checks, doctor.DiskSpace("graph cache", graphCacheDir, minFree))
+			}
+			checks = append(checks, doctor.Templates(templatesDir))
+
+			results := doctor.Run(cmd.Context(), timeout, checks)
+			if err := printer.Print(cmd.OutOrStdout(), output, results, []printer.Column[doctor.Result]{
+				{Header: "check", Value: func(r doctor.Result) string { return r.Check }},
+				{Header: "status", Value: func(r doctor.Result) string { return string(r.Status) }},
+				{Header: "detail", Value: func(r doctor.Result) string { return r.Detail }},
+			}); err != nil {
+				return err
+			}
+			if failed := doctor.Failed(results); failed > 0 {
+				return fmt.Errorf("%d of %d checks failed", failed, len(results))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&templatesDir, "templates-dir", "./examples/cincinnati/product-templates", "directory containing olm.cincinnati templates")
+	cmd.Flags().StringSliceVar(&catalogImages, "catalog-image", defaultCatalogImages, "tagged catalog image to resolve from its registry (repeatable)")
+	cmd.Flags().StringVar(&graphCacheDir, "graph-cache-dir", "", "also check the free space for the graph cache in this directory")
+	cmd.Flags().Uint64Var(&minFreeGiB, "min-free-gib", 5, "free space, in GiB, that each file system needs to pass")
+	cmd.Flags().DurationVar(&timeout, "timeout", 30*time.Second, "how long each check may take")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "output format: "+printer.Formats)
+	return cmd
+}
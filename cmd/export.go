@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/analytics"
+	"github.com/joelanford/extensiondb/internal/fbc"
+	"github.com/joelanford/extensiondb/internal/graphdata"
+	"github.com/joelanford/extensiondb/internal/graphdb"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/spf13/cobra"
+)
+
+func newExportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export data from the database in other formats",
+	}
+	cmd.AddCommand(
+		newExportFBCCmd(),
+		newExportGraphDataCmd(),
+		newExportAnalyticsCmd(),
+	)
+	return cmd
+}
+
+func newExportFBCCmd() *cobra.Command {
+	var (
+		catalogRef   string
+		packages     []string
+		templatesDir string
+		output       string
+		format       string
+	)
+	cmd := &cobra.Command{
+		Use:   "fbc",
+		Short: "Export a catalog as a declarative config with channels derived from update graphs",
+		Long: `Export a catalog as a declarative config (file-based catalog).
+
+The channels of packages that have an olm.cincinnati template in the templates
+directory are derived from the best edges of their update graph. Other packages
+get a single channel in which each version replaces the previous one.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			name, tag, ok := strings.Cut(catalogRef, ":")
+			if !ok {
+				return fmt.Errorf("--catalog must be of the form <name>:<tag>, got %q", catalogRef)
+			}
+
+			var templates []graph.Template
+			if templatesDir != "" {
+				var err error
+				templates, err = graphdb.ReadTemplatesDir(templatesDir)
+				if err != nil {
+					return fmt.Errorf("failed to read templates: %w", err)
+				}
+			}
+
+			pdb, err := openDB()
+			if err != nil {
+				return err
+			}
+			q := query.New(pdb.DB)
+
+			c, err := q.GetCatalog(cmd.Context(), name, tag)
+			if err != nil {
+				return fmt.Errorf("failed to get catalog %s: %w", catalogRef, err)
+			}
+			cfg, err := fbc.Render(cmd.Context(), q, c, fbc.Options{
+				Packages: packages,
+				Channels: fbc.TemplateChannels(cmd.Context(), graphdb.New(pdb.DB), templates, time.Now()),
+			})
+			if err != nil {
+				return err
+			}
+
+			return writeOutput(output, func(w io.Writer) error {
+				switch format {
+				case "json":
+					return declcfg.WriteJSON(*cfg, w)
+				case "yaml":
+					return declcfg.WriteYAML(*cfg, w)
+				}
+				return fmt.Errorf("unknown format %q", format)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&catalogRef, "catalog", "", "catalog to export, as <name>:<tag>")
+	cmd.Flags().StringSliceVar(&packages, "package", nil, "limit the export to these packages")
+	cmd.Flags().StringVar(&templatesDir, "templates-dir", "", "directory containing olm.cincinnati templates")
+	cmd.Flags().StringVarP(&output, "output", "o", "-", "file to write to, or - for stdout")
+	cmd.Flags().StringVar(&format, "format", "json", "output format (json or yaml)")
+	_ = cmd.MarkFlagRequired("catalog")
+	return cmd
+}
+
+func newExportGraphDataCmd() *cobra.Command {
+	var (
+		templatesDir string
+		packages     []string
+		outputDir    string
+		url          string
+	)
+	cmd := &cobra.Command{
+		Use:   "graph-data",
+		Short: "Export update graphs in the cincinnati-graph-data repository layout",
+		Long: `Export update graphs in the channels/ and blocked-edges/ layout used by
+openshift/cincinnati-graph-data, so that the OpenShift Update Service can serve
+graphs curated in extensiondb.
+
+Each package is written to its own subdirectory of the output directory.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			templates, err := graphdb.ReadTemplatesDir(templatesDir)
+			if err != nil {
+				return fmt.Errorf("failed to read templates: %w", err)
+			}
+			if len(packages) > 0 {
+				templates = slices.DeleteFunc(templates, func(t graph.Template) bool {
+					return !slices.Contains(packages, t.Name)
+				})
+			}
+
+			pdb, err := openDB()
+			if err != nil {
+				return err
+			}
+			builder := graphdb.New(pdb.DB)
+
+			now := time.Now()
+			for _, tmpl := range templates {
+				g, err := builder.Build(cmd.Context(), []graph.Template{tmpl}, now)
+				if err != nil {
+					return fmt.Errorf("failed to build graph for package %q: %w", tmpl.Name, err)
+				}
+				files, err := graphdata.Export(g, tmpl.Name, graphdata.Options{URL: url})
+				if err != nil {
+					return err
+				}
+				for _, f := range files {
+					path := filepath.Join(outputDir, tmpl.Name, filepath.FromSlash(f.Path))
+					if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+						return err
+					}
+					if err := os.WriteFile(path, f.Data, 0o644); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&templatesDir, "templates-dir", "./examples/cincinnati/product-templates", "directory containing olm.cincinnati templates")
+	cmd.Flags().StringSliceVar(&packages, "package", nil, "limit the export to these packages")
+	cmd.Flags().StringVarP(&outputDir, "output-dir", "o", "", "directory to write the packages' repository layouts to")
+	cmd.Flags().StringVar(&url, "blocked-edge-url", "", "URL linked from every blocked edge")
+	_ = cmd.MarkFlagRequired("output-dir")
+	return cmd
+}
+
+func newExportAnalyticsCmd() *cobra.Command {
+	var (
+		templatesDir string
+		outputDir    string
+		format       string
+	)
+	cmd := &cobra.Command{
+		Use:   "analytics",
+		Short: "Export flat tables for loading into BI tools or dataframes",
+		Long: `Export flat tables for loading into BI tools or dataframes:
+
+  bundles             every ingested bundle, with its digest and build time
+  catalog_membership  the bundles in the latest digest of each catalog
+  edges               the edges of the update graph of each template
+  lifecycle_phases    the lifecycle phase and dates of each node in the graphs
+
+Each table is written to <table>.csv or <table>.parquet in the output directory.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			var write func(io.Writer, analytics.Table) error
+			switch format {
+			case "csv":
+				write = analytics.WriteCSV
+			case "parquet":
+				write = analytics.WriteParquet
+			default:
+				return fmt.Errorf("unknown format %q", format)
+			}
+
+			templates, err := graphdb.ReadTemplatesDir(templatesDir)
+			if err != nil {
+				return fmt.Errorf("failed to read templates: %w", err)
+			}
+
+			pdb, err := openDB()
+			if err != nil {
+				return err
+			}
+			tables, err := analytics.Tables(cmd.Context(), query.New(pdb.DB), graphdb.New(pdb.DB), templates, time.Now())
+			if err != nil {
+				return err
+			}
+
+			if err := os.MkdirAll(outputDir, 0o755); err != nil {
+				return err
+			}
+			for _, t := range tables {
+				path := filepath.Join(outputDir, t.Name+"."+format)
+				if err := writeOutput(path, func(w io.Writer) error { return write(w, t) }); err != nil {
+					return fmt.Errorf("failed to write %s: %w", path, err)
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&templatesDir, "templates-dir", "./examples/cincinnati/product-templates", "directory containing olm.cincinnati templates")
+	cmd.Flags().StringVarP(&outputDir, "output-dir", "o", "", "directory to write the tables to")
+	cmd.Flags().StringVar(&format, "format", "csv", "output format (csv or parquet)")
+	_ = cmd.MarkFlagRequired("output-dir")
+	return cmd
+}
+
+// writeOutput calls write with stdout if path is "-", and with the file at
+// path otherwise.
+func writeOutput(path string, write func(io.Writer) error) error {
+	if path == "-" {
+		return write(os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/freshness"
+	"github.com/joelanford/extensiondb/internal/printer"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/spf13/cobra"
+)
+
+// freshnessFlags are the flags that set catalog freshness thresholds.
+type freshnessFlags struct {
+	maxDigestAge           time.Duration
+	maxIngestionAge        time.Duration
+	catalogMaxDigestAge    map[string]string
+	catalogMaxIngestionAge map[string]string
+}
+
+func (f *freshnessFlags) addFlags(cmd *cobra.Command) {
+	cmd.Flags().DurationVar(&f.maxDigestAge, "max-digest-age", 0, "report catalogs whose digest has not changed for this long as stale, or 0 to disable")
+	cmd.Flags().DurationVar(&f.maxIngestionAge, "max-ingestion-age", 0, "report catalogs that have not been ingested for this long as stale, or 0 to disable")
+	cmd.Flags().StringToStringVar(&f.catalogMaxDigestAge, "catalog-max-digest-age", nil, "override --max-digest-age for catalogs, as <name>:<tag>=<duration>")
+	cmd.Flags().StringToStringVar(&f.catalogMaxIngestionAge, "catalog-max-ingestion-age", nil, "override --max-ingestion-age for catalogs, as <name>:<tag>=<duration>")
+}
+
+// thresholds returns the thresholds set by the flags.
+func (f *freshnessFlags) thresholds() (freshness.Thresholds, error) {
+	t := freshness.Thresholds{
+		MaxDigestAge:    f.maxDigestAge,
+		MaxIngestionAge: f.maxIngestionAge,
+		Catalogs:        map[string]freshness.Thresholds{},
+	}
+	if err := parseCatalogThresholds("catalog-max-digest-age", f.catalogMaxDigestAge, t.Catalogs, func(ct *freshness.Thresholds, d time.Duration) { ct.MaxDigestAge = d }); err != nil {
+		return freshness.Thresholds{}, err
+	}
+	if err := parseCatalogThresholds("catalog-max-ingestion-age", f.catalogMaxIngestionAge, t.Catalogs, func(ct *freshness.Thresholds, d time.Duration) { ct.MaxIngestionAge = d }); err != nil {
+		return freshness.Thresholds{}, err
+	}
+	return t, nil
+}
+
+// parseCatalogThresholds parses the <name>:<tag>=<duration> values of a flag
+// and sets them in the thresholds of each catalog.
+func parseCatalogThresholds(name string, values map[string]string, catalogs map[string]freshness.Thresholds, set func(*freshness.Thresholds, time.Duration)) error {
+	for catalog, value := range values {
+		if _, _, ok := strings.Cut(catalog, ":"); !ok {
+			return fmt.Errorf("--%s must be of the form <name>:<tag>=<duration>, got catalog %q", name, catalog)
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid --%s for catalog %s: %w", name, catalog, err)
+		}
+		ct := catalogs[catalog]
+		set(&ct, d)
+		catalogs[catalog] = ct
+	}
+	return nil
+}
+
+// enabled reports whether any threshold is set.
+func (f *freshnessFlags) enabled() bool {
+	return f.maxDigestAge > 0 || f.maxIngestionAge > 0 || len(f.catalogMaxDigestAge) > 0 || len(f.catalogMaxIngestionAge) > 0
+}
+
+func newQueryFreshnessCmd(output *string) *cobra.Command {
+	var flags freshnessFlags
+	cmd := &cobra.Command{
+		Use:   "freshness",
+		Short: "List when each catalog's digest last changed and when it was last ingested",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			thresholds, err := flags.thresholds()
+			if err != nil {
+				return err
+			}
+			pdb, err := openDB()
+			if err != nil {
+				return err
+			}
+			catalogs, err := query.New(pdb.DB).ListCatalogFreshness(cmd.Context())
+			if err != nil {
+				return err
+			}
+			statuses := freshness.Check(catalogs, thresholds, time.Now())
+			return printer.Print(cmd.OutOrStdout(), *output, statuses, []printer.Column[freshness.Status]{
+				{Header: "catalog", Value: func(s freshness.Status) string { return s.Catalog }},
+				{Header: "digest changed", Value: func(s freshness.Status) string { return formatTime(s.DigestChangedAt) }},
+				{Header: "ingested", Value: func(s freshness.Status) string { return formatTime(s.IngestedAt) }},
+				{Header: "stale", Value: func(s freshness.Status) string { return staleChecks(s) }},
+			})
+		},
+	}
+	flags.addFlags(cmd)
+	return cmd
+}
+
+func staleChecks(s freshness.Status) string {
+	var checks []string
+	if s.StaleDigest {
+		checks = append(checks, "digest")
+	}
+	if s.StaleIngestion {
+		checks = append(checks, "ingestion")
+	}
+	return strings.Join(checks, ",")
+}
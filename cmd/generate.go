@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/internal/synthetic"
+	"github.com/spf13/cobra"
+)
+
+func newGenerateCmd() *cobra.Command {
+	var (
+		cfg          synthetic.Config
+		start        string
+		until        string
+		templatesDir string
+		catalogRef   string
+		skipDB       bool
+	)
+	cmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Generate synthetic packages for testing at scale",
+		Long: `Generate synthetic packages with realistic version ladders, release
+cadences, and lifecycle templates.
+
+The packages' bundles are stored in the database as if they had been
+ingested, optionally along with a catalog that contains all of them, and
+their olm.cincinnati templates are written to the templates directory. The
+same seed and options always generate the same packages.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if skipDB && templatesDir == "" {
+				return errors.New("--skip-db requires --templates-dir")
+			}
+			var err error
+			if cfg.Start, err = parseTimeFlag("start", start, time.Time{}); err != nil {
+				return err
+			}
+			if cfg.Until, err = parseTimeFlag("until", until, time.Time{}); err != nil {
+				return err
+			}
+
+			d, err := synthetic.Generate(cfg)
+			if err != nil {
+				return err
+			}
+			if templatesDir != "" {
+				if err := d.WriteTemplates(templatesDir); err != nil {
+					return fmt.Errorf("failed to write templates: %w", err)
+				}
+			}
+			if skipDB {
+				return nil
+			}
+
+			pdb, err := openDB()
+			if err != nil {
+				return err
+			}
+			if err := pdb.RunMigrations("migrations"); err != nil {
+				return fmt.Errorf("failed to run migrations: %w", err)
+			}
+			q := query.New(pdb.DB)
+			if err := d.Load(cmd.Context(), q); err != nil {
+				return err
+			}
+			if catalogRef != "" {
+				name, tag, ok := strings.Cut(catalogRef, ":")
+				if !ok {
+					return fmt.Errorf("--catalog must be of the form <name>:<tag>, got %q", catalogRef)
+				}
+				if _, err := d.LoadCatalog(cmd.Context(), q, name, tag); err != nil {
+					return err
+				}
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "generated %d packages with %d bundles\n", len(d.Packages), len(d.Bundles()))
+			return nil
+		},
+	}
+	cmd.Flags().Uint64Var(&cfg.Seed, "seed", 0, "seed for the random number generator")
+	cmd.Flags().IntVar(&cfg.Packages, "packages", 10, "number of packages")
+	cmd.Flags().IntVar(&cfg.Majors, "majors", 1, "number of major versions per package")
+	cmd.Flags().IntVar(&cfg.MinorsPerMajor, "minors", 6, "number of version streams per major version")
+	cmd.Flags().StringVar(&start, "start", "2020-01-01", "general availability date of each package's first version stream")
+	cmd.Flags().StringVar(&until, "until", "", "stop releasing after this date (default: release until each stream's end of life)")
+	cmd.Flags().DurationVar(&cfg.MinorInterval, "minor-interval", 120*24*time.Hour, "average time between version streams")
+	cmd.Flags().DurationVar(&cfg.PatchInterval, "patch-interval", 30*24*time.Hour, "average time between patch releases of a stream in full support")
+	cmd.Flags().DurationVar(&cfg.FullSupport, "full-support", 180*24*time.Hour, "length of each stream's full support phase")
+	cmd.Flags().DurationVar(&cfg.Maintenance, "maintenance", 365*24*time.Hour, "length of each stream's maintenance phase")
+	cmd.Flags().IntVar(&cfg.Extensions, "extensions", 0, "number of extended support phases of streams with even minor versions")
+	cmd.Flags().DurationVar(&cfg.ExtensionLength, "extension-length", 180*24*time.Hour, "length of each extended support phase")
+	cmd.Flags().StringVar(&templatesDir, "templates-dir", "", "directory to write the packages' olm.cincinnati templates to")
+	cmd.Flags().StringVar(&catalogRef, "catalog", "", "also store a catalog containing every generated bundle, as <name>:<tag>")
+	cmd.Flags().BoolVar(&skipDB, "skip-db", false, "only write templates; don't store the packages in the database")
+	return cmd
+}
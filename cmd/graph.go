@@ -0,0 +1,510 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/examples/cincinnati/pkg/viz"
+	"github.com/joelanford/extensiondb/internal/compat"
+	"github.com/joelanford/extensiondb/internal/defaultchannels"
+	"github.com/joelanford/extensiondb/internal/publish"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/internal/vulns"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/opencontainers/go-digest"
+	"github.com/spf13/cobra"
+)
+
+// graphFormats are the formats the graph command writes.
+const graphFormats = "cincinnati-json, mermaid, or dot"
+
+// nodeTexts are the node labels of the --node-text flag.
+const nodeTexts = "version or platforms"
+
+// planFormats are the formats the plan command writes.
+const planFormats = "text or json"
+
+func newGraphCmd() *cobra.Command {
+	var (
+		src           graphSource
+		channel       string
+		format        string
+		minVersion    string
+		maxVersion    string
+		catalog       string
+		asOf          string
+		builtAfter    string
+		builtBefore   string
+		shortestPaths bool
+		nodeText      string
+		output        string
+	)
+	cmd := &cobra.Command{
+		Use:   "graph [<package>]",
+		Short: "Write the update graph of a package",
+		Long: `Write the update graph of a package as a Cincinnati graph document, as
+served by the server's /api/upgrades_info/graph endpoint, as a Mermaid
+diagram, or as a Graphviz DOT digraph.
+
+Graphs built from the database use the package's template, or version streams
+inferred from its bundles if it has none. --channel limits the graph to a
+channel: either "stable" or "stable-<major>.<minor>". Without a package
+argument, --channel names the package too, as <package>:<channel>.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pkgName, ch := "", channel
+			if len(args) > 0 {
+				pkgName = args[0]
+			} else {
+				var ok bool
+				pkgName, ch, ok = strings.Cut(channel, ":")
+				if !ok || pkgName == "" || ch == "" {
+					return fmt.Errorf("a package argument or a --channel of the form <package>:<channel> is required, got %q", channel)
+				}
+			}
+			keep := graph.AllNodes()
+			if ch != "" {
+				inChannel, err := graph.ChannelNodes(ch)
+				if err != nil {
+					return err
+				}
+				keep = inChannel
+			}
+			inRange, err := versionRangeNodes(minVersion, maxVersion)
+			if err != nil {
+				return err
+			}
+			keep = graph.AndNodes(keep, inRange)
+			if catalog != "" {
+				if !src.usesDatabase() {
+					return errors.New("--catalog requires the database")
+				}
+				c, err := graph.ParseCatalog(catalog)
+				if err != nil {
+					return err
+				}
+				keep = graph.AndNodes(keep, graph.InCatalog(c.Name, c.Tag))
+			}
+			after, err := parseTimeFlag("built-after", builtAfter, time.Time{})
+			if err != nil {
+				return err
+			}
+			before, err := parseTimeFlag("built-before", builtBefore, time.Time{})
+			if err != nil {
+				return err
+			}
+			if !after.IsZero() || !before.IsZero() {
+				keep = graph.AndNodes(keep, graph.BuiltBetween(after, before))
+			}
+			t, err := parseTimeFlag("as-of", asOf, time.Now())
+			if err != nil {
+				return err
+			}
+			keepEdge := graph.AllEdges()
+			if shortestPaths {
+				keepEdge = viz.ShortestPathEdges()
+			}
+			text, err := nodeTextFlag(nodeText)
+			if err != nil {
+				return err
+			}
+
+			src.inferStreams = true
+			g, _, err := src.build(cmd.Context(), t, pkgName)
+			if err != nil {
+				return err
+			}
+			return writeOutput(output, func(w io.Writer) error {
+				switch format {
+				case "cincinnati-json":
+					enc := json.NewEncoder(w)
+					enc.SetIndent("", "  ")
+					return enc.Encode(g.Cincinnati(graph.AndNodes(graph.PackageNodes(pkgName), keep), ch))
+				case "mermaid":
+					_, err := io.WriteString(w, viz.Mermaid(g, pkgName, viz.MermaidConfig{KeepNode: keep, KeepEdge: keepEdge, NodeText: text, Summary: true}))
+					return err
+				case "dot":
+					_, err := io.WriteString(w, viz.Dot(g, pkgName, viz.DotConfig{KeepNode: keep, KeepEdge: keepEdge, Summary: true}))
+					return err
+				default:
+					return fmt.Errorf("unknown format %q: expected %s", format, graphFormats)
+				}
+			})
+		},
+	}
+	src.addFlags(cmd)
+	// Packages without a template always have their streams inferred.
+	_ = cmd.Flags().MarkHidden("infer-streams")
+	cmd.Flags().StringVar(&channel, "channel", "", `limit the graph to a channel, "stable" or "stable-<major>.<minor>", or <package>:<channel> without a package argument`)
+	cmd.Flags().StringVar(&format, "format", "cincinnati-json", "output format: "+graphFormats)
+	cmd.Flags().StringVar(&minVersion, "min-version", "", "leave out versions lower than this one")
+	cmd.Flags().StringVar(&maxVersion, "max-version", "", "leave out versions higher than this one")
+	cmd.Flags().StringVar(&catalog, "catalog", "", "leave out versions that don't ship in the latest contents of this catalog, as <name>:<tag>")
+	cmd.Flags().StringVar(&builtAfter, "built-after", "", "leave out versions built before this date")
+	cmd.Flags().StringVar(&builtBefore, "built-before", "", "leave out versions built on or after this date")
+	cmd.Flags().StringVar(&asOf, "as-of", "", "build the graph as of this date (default: now)")
+	cmd.Flags().BoolVar(&shortestPaths, "shortest-paths", false, "only draw the edges on a shortest path to a head, in mermaid and dot output")
+	cmd.Flags().StringVar(&nodeText, "node-text", "version", "what to label the nodes of mermaid output with: "+nodeTexts)
+	cmd.Flags().StringVarP(&output, "output", "o", "-", "file to write to, or - for stdout")
+	return cmd
+}
+
+// versionRangeNodes matches the nodes whose versions are between minVersion
+// and maxVersion, inclusive. An empty bound is not checked.
+func versionRangeNodes(minVersion, maxVersion string) (graph.NodePredicate, error) {
+	var lower, upper *semver.Version
+	if minVersion != "" {
+		v, err := semver.Parse(minVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --min-version %q: %w", minVersion, err)
+		}
+		lower = &v
+	}
+	if maxVersion != "" {
+		v, err := semver.Parse(maxVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --max-version %q: %w", maxVersion, err)
+		}
+		upper = &v
+	}
+	if lower != nil && upper != nil && lower.GT(*upper) {
+		return nil, fmt.Errorf("--min-version %s is higher than --max-version %s", lower, upper)
+	}
+	return graph.NodeInRange(func(v semver.Version) bool {
+		return (lower == nil || v.GTE(*lower)) && (upper == nil || v.LTE(*upper))
+	}), nil
+}
+
+// nodeTextFlag returns the node labels named by a --node-text flag: "version"
+// labels nodes with their versions, and "platforms" adds the platform versions
+// each node supports.
+func nodeTextFlag(nodeText string) (func(*graph.Graph, *graph.Node) string, error) {
+	switch nodeText {
+	case "version":
+		return viz.DefaultNodeText(), nil
+	case "platforms":
+		return viz.PlatformNodeText(), nil
+	default:
+		return nil, fmt.Errorf("unknown --node-text %q: expected %s", nodeText, nodeTexts)
+	}
+}
+
+func newVizCmd() *cobra.Command {
+	var (
+		src       graphSource
+		pkgName   string
+		asOf      string
+		certified bool
+		nodeText  string
+		output    string
+	)
+	cmd := &cobra.Command{
+		Use:   "viz",
+		Short: "Render the update graph of a package as a Mermaid diagram",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			t, err := parseTimeFlag("as-of", asOf, time.Now())
+			if err != nil {
+				return err
+			}
+			text, err := nodeTextFlag(nodeText)
+			if err != nil {
+				return err
+			}
+			g, _, err := src.build(cmd.Context(), t, pkgName)
+			if err != nil {
+				return err
+			}
+			cfg := viz.MermaidConfig{NodeText: text, Summary: true}
+			if certified {
+				cfg.KeepNode = graph.CertifiedNodes()
+			}
+			return writeOutput(output, func(w io.Writer) error {
+				_, err := io.WriteString(w, viz.Mermaid(g, pkgName, cfg))
+				return err
+			})
+		},
+	}
+	src.addFlags(cmd)
+	cmd.Flags().StringVar(&pkgName, "package", "", "package to render")
+	cmd.Flags().StringVar(&asOf, "as-of", "", "build the graph as of this date (default: now)")
+	cmd.Flags().BoolVar(&certified, "certified", false, "only render versions that are certified in the Red Hat Ecosystem Catalog")
+	cmd.Flags().StringVar(&nodeText, "node-text", "version", "what to label the nodes with: "+nodeTexts)
+	cmd.Flags().StringVarP(&output, "output", "o", "-", "file to write to, or - for stdout")
+	_ = cmd.MarkFlagRequired("package")
+	return cmd
+}
+
+func newCompatCmd() *cobra.Command {
+	var (
+		src     graphSource
+		pkgName string
+		asOf    string
+		format  string
+		output  string
+	)
+	cmd := &cobra.Command{
+		Use:   "compat",
+		Short: "Report which versions of a package are supported on which OpenShift versions",
+		Long: `Report, for each version of a package, whether it is supported, functional,
+blocked by its maxOpenShiftVersion property, or unsupported on each OpenShift
+version named by the package's version streams.
+
+Snapshots do not include bundle properties, so no version is reported as
+blocked when building from a snapshot.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			t, err := parseTimeFlag("as-of", asOf, time.Now())
+			if err != nil {
+				return err
+			}
+			g, _, err := src.build(cmd.Context(), t, pkgName)
+			if err != nil {
+				return err
+			}
+			var maxOCP map[digest.Digest]string
+			if src.usesDatabase() {
+				pdb, err := openDB()
+				if err != nil {
+					return err
+				}
+				if maxOCP, err = query.New(pdb.DB).GetMaxOpenShiftVersions(cmd.Context(), pkgName); err != nil {
+					return err
+				}
+			}
+			m := compat.Build(g, pkgName, maxOCP)
+			return writeOutput(output, func(w io.Writer) error {
+				return m.Write(w, format)
+			})
+		},
+	}
+	src.addFlags(cmd)
+	cmd.Flags().StringVar(&pkgName, "package", "", "package to report on")
+	cmd.Flags().StringVar(&asOf, "as-of", "", "build the graph as of this date (default: now)")
+	cmd.Flags().StringVar(&format, "format", "table", "output format: "+compat.Formats)
+	cmd.Flags().StringVarP(&output, "output", "o", "-", "file to write to, or - for stdout")
+	_ = cmd.MarkFlagRequired("package")
+	return cmd
+}
+
+func newPlanCmd() *cobra.Command {
+	var (
+		src                     graphSource
+		plansFile               string
+		fromPlatform            string
+		toPlatform              string
+		installed               []string
+		asOf                    string
+		preferUnaffected        bool
+		excludeOlderThan        time.Duration
+		preferEUS               bool
+		forbidEOLTargets        bool
+		forbidCrossMinorIntoEOL bool
+		channelsCatalog         string
+		format                  string
+	)
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Plan the updates of installed packages across an OpenShift update",
+		Long: `Plan the updates of installed packages across an OpenShift update, and print
+a report of the plan.
+
+Either describe a single cluster with --from-platform, --to-platform, and
+--installed, or name a file of plans in the format read by the publish
+command.
+
+With --prefer-unaffected, updates to bundles that are not affected by any
+vulnerability recorded by the vulns command are preferred over updates of the
+same length. This requires the database.
+
+With --exclude-older-than, updates are never planned through or to bundles
+built longer ago than that before --as-of, such as 13140h for about 18
+months. Installed bundles are updated from regardless of their age.
+
+With --prefer-eus, updates from and to EUS platform versions, such as 4.14 to
+4.16, are planned to bundles of version streams with extended support phases
+over bundles of other streams, so that clusters that stay on EUS platform
+versions also stay on EUS streams.
+
+With --forbid-eol-targets, updates are never planned to or through bundles of
+end of life streams. With --forbid-cross-minor-into-eol, updates are never
+planned into an end of life stream from another major.minor version, but
+updates within one still are.
+
+With --default-channels-catalog, the installed packages whose default channel
+differs between the v<from> and v<to> tags of that catalog, such as v4.14 and
+v4.16 of redhat-operator-index, are reported after each plan, or on stderr
+with --format json. New installations of those packages subscribe to a
+different channel after the update. This requires the database.
+
+With --format json, each plan is written as a JSON document on its own line,
+in the form described by the platform update schema of the schema package.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			var plans []publish.Plan
+			if plansFile != "" {
+				var err error
+				if plans, err = publish.ReadPlansFile(plansFile); err != nil {
+					return err
+				}
+			} else {
+				plan, err := parsePlanFlags(fromPlatform, toPlatform, installed)
+				if err != nil {
+					return err
+				}
+				plans = append(plans, *plan)
+			}
+
+			var packageNames []string
+			for _, p := range plans {
+				for _, iv := range p.Installed {
+					packageNames = append(packageNames, iv.Package)
+				}
+			}
+			t, err := parseTimeFlag("as-of", asOf, time.Now())
+			if err != nil {
+				return err
+			}
+			if format != "text" && format != "json" {
+				return fmt.Errorf("unknown format %q: expected %s", format, planFormats)
+			}
+			if preferUnaffected && !src.usesDatabase() {
+				return errors.New("--prefer-unaffected requires the database")
+			}
+			g, _, err := src.build(cmd.Context(), t, packageNames...)
+			if err != nil {
+				return err
+			}
+
+			var channels []query.TagDefaultChannel
+			if channelsCatalog != "" {
+				pdb, err := openDB()
+				if err != nil {
+					return err
+				}
+				if channels, err = query.New(pdb.DB).ListDefaultChannelsByTag(cmd.Context(), channelsCatalog, packageNames); err != nil {
+					return err
+				}
+			}
+
+			var opts []graph.PlanOption
+			if preferUnaffected {
+				pdb, err := openDB()
+				if err != nil {
+					return err
+				}
+				q := query.New(pdb.DB)
+				affected := map[digest.Digest][]string{}
+				for _, pkgName := range packageNames {
+					byDigest, err := q.GetAffectedBundles(cmd.Context(), pkgName)
+					if err != nil {
+						return err
+					}
+					maps.Copy(affected, byDigest)
+				}
+				opts = append(opts, graph.PreferNodes(vulns.UnaffectedNodes(affected)))
+			}
+			if excludeOlderThan > 0 {
+				opts = append(opts, graph.ExcludeNodes(graph.OlderThan(excludeOlderThan)))
+			}
+			if preferEUS {
+				opts = append(opts, graph.PreferEUS())
+			}
+			var keep []graph.EdgePredicate
+			if forbidEOLTargets {
+				keep = append(keep, graph.ForbidEOLTargets())
+			}
+			if forbidCrossMinorIntoEOL {
+				keep = append(keep, graph.ForbidCrossMinorIntoEOL())
+			}
+			if len(keep) > 0 {
+				opts = append(opts, graph.KeepEdges(graph.AndEdges(keep...)))
+			}
+
+			for _, p := range plans {
+				pu, err := p.Update(cmd.Context(), g, opts...)
+				if err != nil {
+					return err
+				}
+				var changes []defaultchannels.Change
+				if channelsCatalog != "" {
+					changes = defaultchannels.Between(channels, defaultchannels.PlatformTag(p.FromPlatform), defaultchannels.PlatformTag(p.ToPlatform))
+				}
+				if format == "json" {
+					if err := json.NewEncoder(cmd.OutOrStdout()).Encode(pu); err != nil {
+						return err
+					}
+					writeDefaultChannelChanges(cmd.ErrOrStderr(), p.Name, changes)
+					continue
+				}
+				if len(plans) > 1 {
+					fmt.Fprintf(cmd.OutOrStdout(), "# %s\n", p.Name)
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), pu.PrettyReport())
+				writeDefaultChannelChanges(cmd.OutOrStdout(), p.Name, changes)
+			}
+			return nil
+		},
+	}
+	src.addFlags(cmd)
+	cmd.Flags().StringVar(&plansFile, "plans-file", "", "YAML or JSON file of plans")
+	cmd.Flags().StringVar(&fromPlatform, "from-platform", "", "OpenShift version the cluster is updating from, as <major>.<minor>")
+	cmd.Flags().StringVar(&toPlatform, "to-platform", "", "OpenShift version the cluster is updating to, as <major>.<minor>")
+	cmd.Flags().StringSliceVar(&installed, "installed", nil, "installed package versions, as <package>@<version>")
+	cmd.Flags().StringVar(&asOf, "as-of", "", "build the graph as of this date (default: now)")
+	cmd.Flags().BoolVar(&preferUnaffected, "prefer-unaffected", false, "prefer updates to bundles that are not affected by known vulnerabilities")
+	cmd.Flags().DurationVar(&excludeOlderThan, "exclude-older-than", 0, "never update through or to bundles built longer ago than this")
+	cmd.Flags().BoolVar(&preferEUS, "prefer-eus", false, "prefer updates to bundles of EUS streams when updating between EUS platform versions")
+	cmd.Flags().BoolVar(&forbidEOLTargets, "forbid-eol-targets", false, "never update to or through bundles of end of life streams")
+	cmd.Flags().BoolVar(&forbidCrossMinorIntoEOL, "forbid-cross-minor-into-eol", false, "never update into an end of life stream from another major.minor version")
+	cmd.Flags().StringVar(&channelsCatalog, "default-channels-catalog", "", "report changes of the installed packages' default channels between the platform versions' tags of this catalog")
+	cmd.Flags().StringVar(&format, "format", "text", "output format: "+planFormats)
+	cmd.MarkFlagsMutuallyExclusive("plans-file", "from-platform")
+	cmd.MarkFlagsMutuallyExclusive("plans-file", "to-platform")
+	cmd.MarkFlagsMutuallyExclusive("plans-file", "installed")
+	return cmd
+}
+
+// writeDefaultChannelChanges writes the changes of default channels that a
+// plan's platform update brings, if there are any.
+func writeDefaultChannelChanges(w io.Writer, planName string, changes []defaultchannels.Change) {
+	if len(changes) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Default channel changes in %s:\n", planName)
+	for _, c := range changes {
+		fmt.Fprintf(w, "  %s: %s (%s) -> %s (%s)\n", c.Package, c.From, c.FromTag, c.To, c.ToTag)
+	}
+}
+
+func parsePlanFlags(fromPlatform, toPlatform string, installed []string) (*publish.Plan, error) {
+	if fromPlatform == "" || toPlatform == "" || len(installed) == 0 {
+		return nil, errors.New("either --plans-file or all of --from-platform, --to-platform, and --installed are required")
+	}
+	plan := &publish.Plan{Name: "plan"}
+	var err error
+	if plan.FromPlatform, err = graph.NewMajorMinorFromString(fromPlatform); err != nil {
+		return nil, fmt.Errorf("invalid --from-platform: %w", err)
+	}
+	if plan.ToPlatform, err = graph.NewMajorMinorFromString(toPlatform); err != nil {
+		return nil, fmt.Errorf("invalid --to-platform: %w", err)
+	}
+	for _, i := range installed {
+		pkgName, version, ok := strings.Cut(i, "@")
+		if !ok {
+			return nil, fmt.Errorf("--installed must be of the form <package>@<version>, got %q", i)
+		}
+		v, err := semver.Parse(version)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version in --installed %q: %w", i, err)
+		}
+		plan.Installed = append(plan.Installed, publish.InstalledVersion{Package: pkgName, Version: v})
+	}
+	return plan, nil
+}
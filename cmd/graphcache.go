@@ -0,0 +1,30 @@
+package main
+
+import (
+	"github.com/joelanford/extensiondb/internal/graphdb"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/spf13/cobra"
+)
+
+// graphCacheFlags configure where the graphs built from the database are
+// cached, so that they are only rebuilt once a new ingestion run finishes.
+type graphCacheFlags struct {
+	dir string
+	db  bool
+}
+
+func (f *graphCacheFlags) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.dir, "graph-cache-dir", "", "cache the graphs built from the database in this directory, and reuse them until a new ingestion run finishes")
+	cmd.Flags().BoolVar(&f.db, "graph-cache-db", false, "cache the graphs built from the database in the database, and reuse them until a new ingestion run finishes")
+	cmd.MarkFlagsMutuallyExclusive("graph-cache-dir", "graph-cache-db")
+}
+
+// apply configures the builder to cache its graphs, if a cache is configured.
+func (f *graphCacheFlags) apply(b *graphdb.Builder, q *query.Query) {
+	switch {
+	case f.dir != "":
+		b.Cache = graphdb.DirCache(f.dir)
+	case f.db:
+		b.Cache = graphdb.DBCache{Query: q}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/graphdb"
+	"github.com/joelanford/extensiondb/internal/templateimpact"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/spf13/cobra"
+)
+
+func newImpactCmd() *cobra.Command {
+	var (
+		templatePath      string
+		templatesDir      string
+		asOf              string
+		format            string
+		output            string
+		failOnNewDeadEnds bool
+	)
+	cmd := &cobra.Command{
+		Use:   "impact",
+		Short: "Report how proposed template changes would change update graphs",
+		Long: `Build the update graph of each package of the proposed olm.cincinnati
+templates from the database twice, with its current template in
+--templates-dir and with the proposed one, and report the differences: the
+updates that would be added or removed, the versions that would be left out of
+the graph and why, the versions whose lifecycle phases would change, and the
+versions that would become dead ends, without successors even though a newer
+version of the same major version is in the graph.
+
+Run it on a template change before it is merged:
+
+  extensiondb impact --template quay-operator.yaml --fail-on-new-dead-ends
+
+--template is a template file, or a glob pattern of several. Packages without
+a current template are compared with an empty graph.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			proposed, err := graphdb.ReadTemplatesDir(templatePath)
+			if err != nil {
+				return fmt.Errorf("failed to read proposed templates: %w", err)
+			}
+			if len(proposed) == 0 {
+				return fmt.Errorf("no templates found in %s", templatePath)
+			}
+			current, err := graphdb.ReadTemplatesDir(templatesDir)
+			if err != nil {
+				return fmt.Errorf("failed to read templates: %w", err)
+			}
+			var packageNames []string
+			for _, tmpl := range proposed {
+				packageNames = append(packageNames, tmpl.Name)
+			}
+			current = filterTemplates(current, packageNames)
+			t, err := parseTimeFlag("as-of", asOf, time.Now())
+			if err != nil {
+				return err
+			}
+
+			pdb, err := openDB()
+			if err != nil {
+				return err
+			}
+			builder := graphdb.New(pdb.DB)
+			builder.SkipInvalidNodes = true
+			build := func(templates []graph.Template) (*graph.Graph, error) {
+				return builder.Build(cmd.Context(), templates, t)
+			}
+			currentGraph, err := build(current)
+			if err != nil {
+				return fmt.Errorf("failed to build current graphs: %w", err)
+			}
+			proposedGraph, err := build(proposed)
+			if err != nil {
+				return fmt.Errorf("failed to build proposed graphs: %w", err)
+			}
+
+			r := templateimpact.Compare(currentGraph, proposedGraph, packageNames)
+			if err := writeOutput(output, func(w io.Writer) error {
+				return r.Write(w, format)
+			}); err != nil {
+				return err
+			}
+			if n := r.NewDeadEnds(); failOnNewDeadEnds && n > 0 {
+				return fmt.Errorf("the proposed templates make %d new dead ends", n)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&templatePath, "template", "", "proposed olm.cincinnati template file, or a glob pattern of several")
+	cmd.Flags().StringVar(&templatesDir, "templates-dir", "./examples/cincinnati/product-templates", "directory containing the current olm.cincinnati templates")
+	cmd.Flags().StringVar(&asOf, "as-of", "", "build the graphs as of this date (default: now)")
+	cmd.Flags().StringVar(&format, "format", "markdown", "output format: "+templateimpact.Formats)
+	cmd.Flags().StringVarP(&output, "output", "o", "-", "file to write to, or - for stdout")
+	cmd.Flags().BoolVar(&failOnNewDeadEnds, "fail-on-new-dead-ends", false, "fail if the proposed templates make any version a dead end")
+	_ = cmd.MarkFlagRequired("template")
+	return cmd
+}
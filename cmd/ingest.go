@@ -0,0 +1,580 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/buildinfo"
+	"github.com/joelanford/extensiondb/internal/helm"
+	"github.com/joelanford/extensiondb/internal/ingest"
+	"github.com/joelanford/extensiondb/internal/models"
+	"github.com/joelanford/extensiondb/internal/pyxis"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/internal/registry"
+	"github.com/opencontainers/go-digest"
+	"github.com/spf13/cobra"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+func newIngestCmd() *cobra.Command {
+	var (
+		configFile      string
+		pullCatalogs    bool
+		catalogCacheDir string
+		pluginFlags     ingestPluginFlags
+		logs            logFlags
+		blobs           blobStoreFlags
+		packageFilters  packageFilterFlags
+		helmRepos       []string
+		concurrency     int
+		hostConcurrency map[string]int
+		rateLimits      rateLimitFlags
+		resume          bool
+		drainTimeout    time.Duration
+	)
+	cmd := &cobra.Command{
+		Use:   "ingest",
+		Short: "Load bundles from extracted catalogs and charts from Helm repositories into the database",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			plugins, err := pluginFlags.plugins()
+			if err != nil {
+				return err
+			}
+			logger, err := logs.logger(cmd.OutOrStdout())
+			if err != nil {
+				return err
+			}
+			if err := packageFilters.validate(); err != nil {
+				return err
+			}
+			config := ingest.DefaultConfig()
+			if configFile != "" {
+				if config, err = ingest.ReadConfigFile(configFile); err != nil {
+					return err
+				}
+			}
+			helmSources, err := helmCatalogSources(config, helmRepos, packageFilters)
+			if err != nil {
+				return err
+			}
+			// Flags that are set override the config file.
+			if config.Concurrency > 0 && !cmd.Flags().Changed("concurrency") {
+				concurrency = config.Concurrency
+			}
+			hostConcurrency = mergeHostConcurrency(config.HostConcurrency, hostConcurrency)
+			if concurrency < 1 {
+				return fmt.Errorf("--concurrency must be at least 1, got %d", concurrency)
+			}
+			for host, n := range hostConcurrency {
+				if n < 1 {
+					return fmt.Errorf("--host-concurrency for %s must be at least 1, got %d", host, n)
+				}
+			}
+			registryLimits, err := rateLimits.limits(cmd, registry.RateLimits{Default: config.RateLimit, Hosts: config.HostRateLimit})
+			if err != nil {
+				return err
+			}
+			reg := registry.Client{RateLimiter: registry.NewRateLimiter(registryLimits)}
+
+			pdb, err := openDB()
+			if err != nil {
+				return err
+			}
+
+			// Run migrations
+			if err := pdb.RunMigrations("migrations"); err != nil {
+				return fmt.Errorf("failed to run migrations: %w", err)
+			}
+
+			q, err := blobs.query(pdb)
+			if err != nil {
+				return err
+			}
+
+			var sources []catalogSource
+			for _, c := range config.Catalogs {
+				for _, tag := range c.Tags {
+					packages := packageFilters.filter(config.Filter(c.Packages), c.Name, tag)
+					var src ingest.Source = ingest.CatalogDir(c.TagDir(tag), packages, reg)
+					if pullCatalogs || c.Pull {
+						ref, err := c.ImageReference(tag)
+						if err != nil {
+							return err
+						}
+						if catalogCacheDir == "" {
+							catalogCacheDir = defaultCatalogCacheDir()
+						}
+						src = &ingest.CatalogImage{Ref: ref, CacheDir: filepath.Join(catalogCacheDir, c.Name, tag), Packages: packages, Registry: reg}
+					}
+					sources = append(sources, catalogSource{name: c.Name, tag: tag, src: src})
+				}
+			}
+			sources = append(sources, helmSources...)
+
+			run, err := q.CreateIngestionRun(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("failed to create ingestion run: %w", err)
+			}
+			limits := ingest.HostLimits{Default: concurrency, Hosts: hostConcurrency}
+			buildErr := buildDB(cmd.Context(), logger, q, run, sources, limits, plugins, resume, drainTimeout)
+
+			// Record the outcome even if the build was interrupted.
+			if err := q.FinishIngestionRun(context.WithoutCancel(cmd.Context()), run, buildErr); err != nil {
+				return errors.Join(buildErr, err)
+			}
+			return buildErr
+		},
+	}
+	cmd.Flags().StringVar(&configFile, "config", "", "YAML or JSON file declaring the catalogs, Helm repositories, concurrency and rate limits, and package filters to ingest (default: the Red Hat and certified operator catalogs of OpenShift 4.12 through 4.19)")
+	cmd.Flags().BoolVar(&pullCatalogs, "pull-catalogs", false, "pull every catalog from its image instead of reading it from $CATALOGS_DIR")
+	cmd.Flags().StringVar(&catalogCacheDir, "catalog-cache-dir", "", "directory to extract pulled catalogs to, and reuse them from while their digests are unchanged (default: extensiondb/catalogs in the user cache directory)")
+	pluginFlags.addFlags(cmd)
+	logs.addFlags(cmd)
+	blobs.addFlags(cmd)
+	packageFilters.addFlags(cmd)
+	cmd.Flags().StringSliceVar(&helmRepos, "helm-repo", nil, "Helm chart repository to ingest charts from, in addition to those of --config, as <name>=<url> (repeatable)")
+	cmd.Flags().IntVar(&concurrency, "concurrency", 32, "maximum number of bundles to fetch at once from each registry host, overriding --config")
+	cmd.Flags().StringToIntVar(&hostConcurrency, "host-concurrency", nil, "override --concurrency for registry hosts, as <host>=<n>, in addition to those of --config")
+	rateLimits.addFlags(cmd)
+	cmd.Flags().BoolVar(&resume, "resume", false, "skip catalogs whose current digest was already ingested successfully")
+	cmd.Flags().DurationVar(&drainTimeout, "drain-timeout", 30*time.Second, "on SIGINT or SIGTERM, how long to let the bundles being ingested finish before canceling them, or 0 to cancel them at once")
+	return cmd
+}
+
+// ingestPluginFlags are the flags that enable ingest plugins.
+type ingestPluginFlags struct {
+	buildRecordsFile string
+	pyxisURL         string
+}
+
+func (f *ingestPluginFlags) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.buildRecordsFile, "build-records", "", "YAML or JSON file of Brew and Konflux build records to link ingested bundles to")
+	cmd.Flags().StringVar(&f.pyxisURL, "pyxis-url", "", "record the vendor, support level, certification, and publish date of ingested bundles from this Pyxis API (e.g. "+pyxis.DefaultURL+")")
+}
+
+// plugins returns the plugins enabled by the flags.
+func (f *ingestPluginFlags) plugins() ([]ingest.Plugin, error) {
+	var plugins []ingest.Plugin
+	if f.buildRecordsFile != "" {
+		records, err := buildinfo.ReadRecordsFile(f.buildRecordsFile)
+		if err != nil {
+			return nil, err
+		}
+		plugins = append(plugins, buildinfo.Plugin{Records: records})
+	}
+	if f.pyxisURL != "" {
+		// Catalog metadata changes rarely, so it is refreshed daily rather
+		// than on every ingestion.
+		plugins = append(plugins, pyxis.Plugin{Client: &pyxis.Client{URL: f.pyxisURL}, RefreshAfter: 24 * time.Hour})
+	}
+	return plugins, nil
+}
+
+// logFlags configure the structured logs of ingestion.
+type logFlags struct {
+	format string
+	level  string
+}
+
+func (f *logFlags) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.format, "log-format", "text", "format of the ingestion logs: text, or json for log aggregators")
+	cmd.Flags().StringVar(&f.level, "log-level", "info", "minimum level of the ingestion logs: debug, info, warn, or error")
+}
+
+// logger returns a logger that writes to w in the format and from the level
+// named by the flags. It is also made slog's default logger, which the
+// ingest package logs with.
+func (f *logFlags) logger(w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(f.level)); err != nil {
+		return nil, fmt.Errorf("--log-level must be debug, info, warn, or error, got %q", f.level)
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	var logger *slog.Logger
+	switch f.format {
+	case "text":
+		logger = slog.New(slog.NewTextHandler(w, opts))
+	case "json":
+		logger = slog.New(slog.NewJSONHandler(w, opts))
+	default:
+		return nil, fmt.Errorf("--log-format must be text or json, got %q", f.format)
+	}
+	slog.SetDefault(logger)
+	return logger, nil
+}
+
+// packageFilterFlags select the packages of each catalog to ingest, in
+// addition to the package filters of the config file. Each pattern is given
+// as <glob> for every catalog, or as <catalog>=<glob> for the catalogs named
+// <catalog> or <catalog>:<tag>.
+type packageFilterFlags struct {
+	include []string
+	exclude []string
+}
+
+func (f *packageFilterFlags) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&f.include, "include-package", nil, "only ingest the packages matching this glob, as <glob> or <catalog>[:<tag>]=<glob> (repeatable)")
+	cmd.Flags().StringArrayVar(&f.exclude, "exclude-package", nil, "skip the packages matching this glob, even if they are included, as <glob> or <catalog>[:<tag>]=<glob> (repeatable)")
+}
+
+func (f *packageFilterFlags) validate() error {
+	for _, flag := range []struct {
+		name     string
+		patterns []string
+	}{{"--include-package", f.include}, {"--exclude-package", f.exclude}} {
+		for _, p := range flag.patterns {
+			catalog, glob, scoped := strings.Cut(p, "=")
+			if scoped && catalog == "" {
+				return fmt.Errorf("%s must be of the form <glob> or <catalog>[:<tag>]=<glob>, got %q", flag.name, p)
+			}
+			if !scoped {
+				glob = p
+			}
+			if err := (ingest.PackageFilter{Include: []string{glob}}).Validate(); err != nil {
+				return fmt.Errorf("invalid %s: %w", flag.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// filter returns the package filter of the catalog name:tag, whose filter in
+// the config file is base.
+func (f *packageFilterFlags) filter(base ingest.PackageFilter, name, tag string) ingest.PackageFilter {
+	return ingest.PackageFilter{
+		Include: append(base.Include, catalogPatterns(f.include, name, tag)...),
+		Exclude: append(base.Exclude, catalogPatterns(f.exclude, name, tag)...),
+	}
+}
+
+// catalogPatterns returns the patterns that apply to the catalog name:tag.
+func catalogPatterns(patterns []string, name, tag string) []string {
+	var matched []string
+	for _, p := range patterns {
+		catalog, glob, scoped := strings.Cut(p, "=")
+		switch {
+		case !scoped:
+			matched = append(matched, p)
+		case catalog == name || catalog == name+":"+tag:
+			matched = append(matched, glob)
+		}
+	}
+	return matched
+}
+
+// rateLimitFlags limit how many requests per second are made to each
+// registry host, so that large ingestions don't exceed the request limits of
+// registries.
+type rateLimitFlags struct {
+	rate  float64
+	hosts map[string]string
+}
+
+func (f *rateLimitFlags) addFlags(cmd *cobra.Command) {
+	cmd.Flags().Float64Var(&f.rate, "rate-limit", 0, "maximum number of requests per second to each registry host, or 0 for no limit")
+	cmd.Flags().StringToStringVar(&f.hosts, "host-rate-limit", nil, "override --rate-limit for registry hosts, as <host>=<n>")
+}
+
+// limits returns the rate limits of config, overridden by those of the flags
+// that are set.
+func (f *rateLimitFlags) limits(cmd *cobra.Command, config registry.RateLimits) (registry.RateLimits, error) {
+	limits := registry.RateLimits{Default: config.Default, Hosts: maps.Clone(config.Hosts)}
+	if cmd.Flags().Changed("rate-limit") {
+		limits.Default = f.rate
+	}
+	if limits.Default < 0 {
+		return registry.RateLimits{}, fmt.Errorf("--rate-limit must not be negative, got %g", limits.Default)
+	}
+	if limits.Hosts == nil {
+		limits.Hosts = map[string]float64{}
+	}
+	for host, v := range f.hosts {
+		r, err := strconv.ParseFloat(v, 64)
+		if err != nil || r < 0 {
+			return registry.RateLimits{}, fmt.Errorf("--host-rate-limit for %s must be a non-negative number, got %q", host, v)
+		}
+		limits.Hosts[host] = r
+	}
+	return limits, nil
+}
+
+// catalogSource is a source of extensions that is recorded as a catalog.
+// sourceType is the type of source recorded in the provenance of its
+// bundles, or models.SourceTypeCatalog if it is empty.
+type catalogSource struct {
+	name, tag  string
+	src        ingest.Source
+	sourceType string
+}
+
+// helmCatalogSources returns a catalog source for each Helm chart repository
+// of the config, and for each given as <name>=<url>, whose charts are
+// selected by the package filters. Chart repositories are unversioned, so
+// each is recorded as the "latest" tag of its catalog.
+func helmCatalogSources(config ingest.Config, helmRepos []string, packageFilters packageFilterFlags) ([]catalogSource, error) {
+	repos := slices.Clone(config.HelmRepositories)
+	for _, r := range helmRepos {
+		name, repoURL, ok := strings.Cut(r, "=")
+		if !ok || name == "" || repoURL == "" {
+			return nil, fmt.Errorf("--helm-repo must be of the form <name>=<url>, got %q", r)
+		}
+		repos = append(repos, ingest.HelmRepositoryConfig{Name: name, URL: repoURL})
+	}
+	sources := make([]catalogSource, 0, len(repos))
+	for _, r := range repos {
+		packages := packageFilters.filter(config.Filter(r.Packages), r.Name, "latest")
+		sources = append(sources, catalogSource{name: r.Name, tag: "latest", src: &helm.Repository{URL: r.URL, Packages: packages}})
+	}
+	return sources, nil
+}
+
+// defaultCatalogCacheDir returns the directory that pulled catalogs are
+// extracted to by default.
+func defaultCatalogCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "extensiondb", "catalogs")
+}
+
+// mergeHostConcurrency returns the per-host concurrency limits of the config
+// file, overridden by those of the flags.
+func mergeHostConcurrency(config, flags map[string]int) map[string]int {
+	merged := maps.Clone(config)
+	if merged == nil {
+		merged = map[string]int{}
+	}
+	maps.Copy(merged, flags)
+	return merged
+}
+
+// buildDB ingests the bundles of each source. Bundles are fetched from each
+// registry host independently, within the host's limit, so that a slow
+// registry doesn't hold up fetches from the others. The references of
+// streaming sources, such as file-based catalogs, are ingested as they are
+// listed rather than all held in memory. With resume, sources whose current
+// digest was already ingested successfully are skipped, so that an
+// interrupted ingestion picks up where it stopped.
+//
+// Once ctx is done, such as on SIGTERM, no more bundles are started, and the
+// bundles in flight are given up to drainTimeout to finish their fetches and
+// database writes before they are canceled. A summary of the catalogs and
+// bundles that were completed and that remain is then logged, and an error is
+// returned, so that the catalog in progress is not recorded as ingested.
+//
+// Progress is logged to logger with the catalog, tag, and digest of each
+// source, and the reference of each bundle along with how many of the
+// source's bundles have been processed.
+func buildDB(ctx context.Context, logger *slog.Logger, q *query.Query, run *models.IngestionRun, sources []catalogSource, limits ingest.HostLimits, plugins []ingest.Plugin, resume bool, drainTimeout time.Duration) error {
+	work, cancel := ingest.WithDrain(ctx, drainTimeout)
+	defer cancel()
+
+	var summary ingestSummary
+	defer func() {
+		if ctx.Err() != nil {
+			summary.log(logger, sources)
+		}
+	}()
+
+	for _, cs := range sources {
+		if ctx.Err() != nil {
+			return fmt.Errorf("ingestion interrupted: %w", context.Cause(ctx))
+		}
+		log := logger.With("catalog", cs.name, "tag", cs.tag)
+		log.Info("ingesting catalog")
+		summary.inProgress = true
+
+		c, err := q.GetOrCreateCatalog(work, cs.name, cs.tag)
+		if err != nil {
+			return fmt.Errorf("error creating catalog %s:%s: %w", cs.name, cs.tag, err)
+		}
+
+		var (
+			catalogDigest digest.Digest
+			refs          []reference.Canonical
+		)
+		streaming, isStreaming := cs.src.(ingest.StreamingSource)
+		if isStreaming {
+			catalogDigest, err = streaming.Digest(work)
+		} else {
+			catalogDigest, refs, err = cs.src.ListReferences(work)
+		}
+		if err != nil {
+			return fmt.Errorf("error listing references in %s:%s: %w", cs.name, cs.tag, err)
+		}
+		log = log.With("digest", catalogDigest.String())
+		if resume {
+			ingested, err := q.GetCatalogIngestedDigest(work, c)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("error getting ingested digest of %s:%s: %w", cs.name, cs.tag, err)
+			}
+			if ingested == catalogDigest.String() {
+				log.Info("skipping catalog, which was already ingested at its digest")
+				summary.completed++
+				summary.inProgress = false
+				continue
+			}
+		}
+		cd, err := q.GetOrCreateCatalogDigest(work, c, catalogDigest.String())
+		if err != nil {
+			return fmt.Errorf("error creating catalog digest for %s:%s: %w", cs.name, cs.tag, err)
+		}
+		if dcs, ok := cs.src.(ingest.DefaultChannelSource); ok {
+			channels, err := dcs.DefaultChannels(work)
+			if err != nil {
+				return fmt.Errorf("error reading default channels of %s:%s: %w", cs.name, cs.tag, err)
+			}
+			if err := q.SetCatalogDigestDefaultChannels(work, cd, channels); err != nil {
+				return fmt.Errorf("error recording default channels of %s:%s: %w", cs.name, cs.tag, err)
+			}
+		}
+
+		// The bundle references of listed sources are created at once, and
+		// those of streamed sources as they are read.
+		bundleRefs := make(map[string]*models.BundleReference, len(refs))
+		brs, err := q.GetOrCreateBundleReferences(work, refs)
+		if err != nil {
+			return fmt.Errorf("error creating bundle references of %s:%s: %w", cs.name, cs.tag, err)
+		}
+		for i, br := range brs {
+			bundleRefs[refs[i].String()] = br
+		}
+
+		summary.startCatalog(len(refs))
+
+		// logBundle logs the outcome of a bundle with the number of bundles
+		// processed so far, and the total unless the source is streamed,
+		// because its size isn't known until it has been listed.
+		var processed atomic.Int64
+		logBundle := func(level slog.Level, msg string, ref reference.Canonical, args ...any) {
+			args = append(args, "reference", ref.String(), "processed", processed.Add(1))
+			if !isStreaming {
+				args = append(args, "total", len(refs))
+			}
+			log.Log(ctx, level, msg, args...)
+		}
+		origin := ingest.Origin{
+			SourceType:     cmp.Or(cs.sourceType, models.SourceTypeCatalog),
+			SourceID:       cs.name + ":" + cs.tag,
+			IngestionRunID: run.ID,
+		}
+		ingestRef := func(egCtx context.Context, canonicalRef reference.Canonical) error {
+			// Bundles that are queued when the shutdown begins are left for
+			// the next ingestion.
+			if ctx.Err() != nil {
+				summary.skipped.Add(1)
+				return nil
+			}
+
+			br, ok := bundleRefs[canonicalRef.String()]
+			if !ok {
+				var err error
+				br, err = q.GetOrCreateCanonicalBundleReference(egCtx, canonicalRef)
+				if err != nil {
+					return fmt.Errorf("error creating bundle reference %s: %w", canonicalRef, err)
+				}
+			}
+
+			if err := q.EnsureCatalogDigestBundleReference(egCtx, cd, br); err != nil {
+				return fmt.Errorf("error ensuring catalog bundle reference %s: %w", canonicalRef, err)
+			}
+
+			created, err := ingest.Bundle(egCtx, q, cs.src, br, canonicalRef, origin, plugins...)
+			if errors.Is(err, ingest.ErrFetch) {
+				summary.failed.Add(1)
+				logBundle(slog.LevelWarn, "failed to fetch bundle", canonicalRef, "error", err)
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if !created {
+				summary.updated.Add(1)
+				logBundle(slog.LevelInfo, "updated bundle", canonicalRef)
+				return nil
+			}
+			summary.created.Add(1)
+			logBundle(slog.LevelInfo, "created bundle", canonicalRef)
+			return nil
+		}
+		if isStreaming {
+			err = ingest.ForEachByHostSeq(work, ingest.UntilDone(ctx, streaming.References(work)), limits, ingestRef)
+		} else {
+			err = ingest.ForEachByHost(work, refs, limits, ingestRef)
+		}
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("ingestion of %s:%s interrupted: %w", cs.name, cs.tag, context.Cause(ctx))
+		}
+
+		if err := q.RecordCatalogIngestion(work, c, catalogDigest.String()); err != nil {
+			return fmt.Errorf("error recording ingestion of %s:%s: %w", cs.name, cs.tag, err)
+		}
+		log.Info("ingested catalog", "created", summary.created.Load(), "updated", summary.updated.Load(), "failed", summary.failed.Load())
+		summary.completed++
+		summary.inProgress = false
+	}
+	return nil
+}
+
+// ingestSummary counts the catalogs and bundles that an ingestion completed,
+// so that what remains can be reported when it is interrupted.
+type ingestSummary struct {
+	// completed is how many of the sources were ingested or skipped, in
+	// order, and inProgress is whether the source after them was started.
+	completed  int
+	inProgress bool
+
+	// total is the number of references of the current source, or 0 if
+	// it is streamed.
+	total int
+
+	created, updated, failed, skipped atomic.Int64
+}
+
+func (s *ingestSummary) startCatalog(total int) {
+	s.total = total
+	s.created.Store(0)
+	s.updated.Store(0)
+	s.failed.Store(0)
+	s.skipped.Store(0)
+}
+
+func (s *ingestSummary) log(logger *slog.Logger, sources []catalogSource) {
+	logger.Warn("ingestion interrupted", "completedCatalogs", s.completed, "catalogs", len(sources))
+	remaining := sources[s.completed:]
+	if s.inProgress {
+		cs := remaining[0]
+		done := s.created.Load() + s.updated.Load() + s.failed.Load()
+		args := []any{"catalog", cs.name, "tag", cs.tag, "processed", done, "created", s.created.Load(), "updated", s.updated.Load(), "failed", s.failed.Load()}
+		if s.total > 0 {
+			args = append(args, "remaining", int64(s.total)-done)
+		} else {
+			// Listing a streamed catalog stops with the bundles that
+			// were queued.
+			args = append(args, "skipped", s.skipped.Load())
+		}
+		logger.Warn("catalog interrupted", args...)
+		remaining = remaining[1:]
+	}
+	for _, cs := range remaining {
+		logger.Warn("catalog not started", "catalog", cs.name, "tag", cs.tag)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/joelanford/extensiondb/internal/printer"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/spf13/cobra"
+)
+
+type labelResult struct {
+	Package string `json:"package"`
+	Version string `json:"version"`
+	Digest  string `json:"digest"`
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+}
+
+type labelValueResult struct {
+	Value   string `json:"value"`
+	Bundles int    `json:"bundles"`
+}
+
+func newQueryLabelsCmd(output *string) *cobra.Command {
+	var (
+		filter query.BundleLabelFilter
+		values bool
+	)
+	cmd := &cobra.Command{
+		Use:   "labels",
+		Short: "List the labels of bundle images",
+		Long: `List the labels of bundle images, oldest bundle first.
+
+The operators.operatorframework.io.bundle.* and com.redhat.* labels of each
+bundle image are stored when the bundle is ingested. --key, --value, and
+--package limit the list to labels with that key, labels with that value,
+and bundles of those packages.
+
+With --values, the number of bundles with each value of --key is listed
+instead, most common first.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if values && filter.Key == "" {
+				return errors.New("--values requires --key")
+			}
+			pdb, err := openDB()
+			if err != nil {
+				return err
+			}
+			q := query.New(pdb.DB)
+
+			if values {
+				counts, err := q.CountBundleLabelValues(cmd.Context(), filter.Key)
+				if err != nil {
+					return err
+				}
+				results := make([]labelValueResult, 0, len(counts))
+				for _, c := range counts {
+					results = append(results, labelValueResult(c))
+				}
+				return printer.Print(cmd.OutOrStdout(), *output, results, []printer.Column[labelValueResult]{
+					{Header: "value", Value: func(r labelValueResult) string { return r.Value }},
+					{Header: "bundles", Value: func(r labelValueResult) string { return strconv.Itoa(r.Bundles) }},
+				})
+			}
+
+			labels, err := q.ListBundleLabels(cmd.Context(), filter)
+			if err != nil {
+				return err
+			}
+			results := make([]labelResult, 0, len(labels))
+			for _, l := range labels {
+				results = append(results, labelResult(l))
+			}
+			return printer.Print(cmd.OutOrStdout(), *output, results, []printer.Column[labelResult]{
+				{Header: "package", Value: func(r labelResult) string { return r.Package }},
+				{Header: "version", Value: func(r labelResult) string { return r.Version }},
+				{Header: "key", Value: func(r labelResult) string { return r.Key }},
+				{Header: "value", Value: func(r labelResult) string { return r.Value }},
+				{Header: "digest", Value: func(r labelResult) string { return r.Digest }},
+			})
+		},
+	}
+	cmd.Flags().StringVar(&filter.Key, "key", "", "only list labels with this key")
+	cmd.Flags().StringVar(&filter.Value, "value", "", "only list labels with this value")
+	cmd.Flags().StringSliceVar(&filter.Packages, "package", nil, "only list the labels of bundles of these packages")
+	cmd.Flags().BoolVar(&values, "values", false, "count the bundles with each value of --key")
+	cmd.MarkFlagsMutuallyExclusive("values", "value")
+	cmd.MarkFlagsMutuallyExclusive("values", "package")
+	return cmd
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/ingest"
+	"github.com/joelanford/extensiondb/internal/models"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/internal/registry"
+	"github.com/spf13/cobra"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+func newMigrateLegacyCmd() *cobra.Command {
+	var (
+		pluginFlags     ingestPluginFlags
+		logs            logFlags
+		blobs           blobStoreFlags
+		concurrency     int
+		hostConcurrency map[string]int
+		rateLimits      rateLimitFlags
+		drainTimeout    time.Duration
+	)
+	cmd := &cobra.Command{
+		Use:   "migrate-legacy",
+		Short: "Migrate the images of the legacy image_configs tables to packages and bundles",
+		Long: `Migrate the images recorded in the image_configs and ocp_catalog_references
+tables, which preceded the packages and bundles tables, to packages and
+bundles.
+
+The legacy tables only recorded the config of each image, so every image is
+fetched from its registry again and stored as the ingest command stores
+bundles. Images that the legacy tables recorded in a catalog for an OpenShift
+version are recorded in the catalog of that name, tagged v<version>. Images
+that are not canonical references or can't be fetched are logged and
+skipped.
+
+The legacy tables are not changed. Once the migration succeeds, they can be
+dropped with:
+
+  DROP TABLE ocp_catalog_references, image_configs;`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			plugins, err := pluginFlags.plugins()
+			if err != nil {
+				return err
+			}
+			logger, err := logs.logger(cmd.OutOrStdout())
+			if err != nil {
+				return err
+			}
+			if concurrency < 1 {
+				return fmt.Errorf("--concurrency must be at least 1, got %d", concurrency)
+			}
+			for host, n := range hostConcurrency {
+				if n < 1 {
+					return fmt.Errorf("--host-concurrency for %s must be at least 1, got %d", host, n)
+				}
+			}
+			registryLimits, err := rateLimits.limits(cmd, registry.RateLimits{})
+			if err != nil {
+				return err
+			}
+			reg := registry.Client{RateLimiter: registry.NewRateLimiter(registryLimits)}
+
+			pdb, err := openDB()
+			if err != nil {
+				return err
+			}
+			if err := pdb.RunMigrations("migrations"); err != nil {
+				return fmt.Errorf("failed to run migrations: %w", err)
+			}
+			q, err := blobs.query(pdb)
+			if err != nil {
+				return err
+			}
+
+			ok, err := q.HasLegacySchema(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if !ok {
+				logger.Info("the database has no legacy tables to migrate")
+				return nil
+			}
+			images, err := q.ListLegacyImages(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("failed to list legacy images: %w", err)
+			}
+			sources, uncataloged := legacySources(logger, images, reg)
+
+			run, err := q.CreateIngestionRun(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("failed to create ingestion run: %w", err)
+			}
+			limits := ingest.HostLimits{Default: concurrency, Hosts: hostConcurrency}
+			migrateErr := buildDB(cmd.Context(), logger, q, run, sources, limits, plugins, false, drainTimeout)
+			if migrateErr == nil {
+				migrateErr = ingestUncataloged(cmd.Context(), logger, q, run, uncataloged, reg, limits, plugins)
+			}
+
+			// Record the outcome even if the migration was interrupted.
+			if err := q.FinishIngestionRun(context.WithoutCancel(cmd.Context()), run, migrateErr); err != nil {
+				return errors.Join(migrateErr, err)
+			}
+			if migrateErr != nil {
+				return migrateErr
+			}
+			logger.Info("migrated the legacy images", "catalogs", len(sources), "uncatalogedImages", len(uncataloged))
+			return nil
+		},
+	}
+	pluginFlags.addFlags(cmd)
+	logs.addFlags(cmd)
+	blobs.addFlags(cmd)
+	cmd.Flags().IntVar(&concurrency, "concurrency", 32, "maximum number of images to fetch at once from each registry host")
+	cmd.Flags().StringToIntVar(&hostConcurrency, "host-concurrency", nil, "override --concurrency for registry hosts, as <host>=<n>")
+	rateLimits.addFlags(cmd)
+	cmd.Flags().DurationVar(&drainTimeout, "drain-timeout", 30*time.Second, "on SIGINT or SIGTERM, how long to let the images being migrated finish before canceling them, or 0 to cancel them at once")
+	return cmd
+}
+
+// legacySources returns a catalog source for each catalog and OpenShift
+// version that legacy images were recorded in, ordered by catalog name and
+// tag, and the images that were recorded in none. Images that are not
+// canonical references are logged and skipped. The sources fetch images
+// with reg.
+func legacySources(logger *slog.Logger, images []query.LegacyImage, reg registry.Client) ([]catalogSource, []reference.Canonical) {
+	type catalogKey struct{ name, tag string }
+	var (
+		byCatalog   = map[catalogKey][]reference.Canonical{}
+		uncataloged []reference.Canonical
+	)
+	for _, img := range images {
+		named, err := reference.ParseNamed(img.Reference)
+		if err != nil {
+			logger.Warn("skipping legacy image", "reference", img.Reference, "error", err)
+			continue
+		}
+		ref, ok := named.(reference.Canonical)
+		if !ok {
+			logger.Warn("skipping legacy image, which is not a canonical reference", "reference", img.Reference)
+			continue
+		}
+		if len(img.Catalogs) == 0 {
+			uncataloged = append(uncataloged, ref)
+			continue
+		}
+		for _, c := range img.Catalogs {
+			key := catalogKey{name: c.Name, tag: "v" + strings.TrimPrefix(c.OCPVersion, "v")}
+			byCatalog[key] = append(byCatalog[key], ref)
+		}
+	}
+
+	sources := make([]catalogSource, 0, len(byCatalog))
+	for key, refs := range byCatalog {
+		sources = append(sources, catalogSource{name: key.name, tag: key.tag, src: ingest.Legacy{Refs: refs, Registry: reg}, sourceType: models.SourceTypeBackfill})
+	}
+	slices.SortFunc(sources, func(a, b catalogSource) int {
+		return cmp.Or(cmp.Compare(a.name, b.name), cmp.Compare(a.tag, b.tag))
+	})
+	return sources, uncataloged
+}
+
+// ingestUncataloged ingests bundle images that are not in any catalog, as
+// the webhook ingestion queue does.
+func ingestUncataloged(ctx context.Context, logger *slog.Logger, q *query.Query, run *models.IngestionRun, refs []reference.Canonical, reg registry.Client, limits ingest.HostLimits, plugins []ingest.Plugin) error {
+	origin := ingest.Origin{SourceType: models.SourceTypeBackfill, SourceID: "uncataloged", IngestionRunID: run.ID}
+	return ingest.ForEachByHost(ctx, refs, limits, func(ctx context.Context, ref reference.Canonical) error {
+		br, err := q.GetOrCreateCanonicalBundleReference(ctx, ref)
+		if err != nil {
+			return fmt.Errorf("error creating bundle reference %s: %w", ref, err)
+		}
+		if _, err := ingest.Bundle(ctx, q, ingest.Legacy{Registry: reg}, br, ref, origin, plugins...); errors.Is(err, ingest.ErrFetch) {
+			logger.Warn("skipping legacy image", "reference", ref.String(), "error", err)
+		} else if err != nil {
+			return err
+		}
+		return nil
+	})
+}
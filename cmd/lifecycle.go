@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+
+	"github.com/joelanford/extensiondb/internal/lifecycle"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/spf13/cobra"
+)
+
+// lifecycleFlags configure an endpoint that provides current lifecycle
+// dates, which replace those of the templates.
+type lifecycleFlags struct {
+	url string
+}
+
+func (f *lifecycleFlags) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&f.url, "lifecycle-url", "", `look up lifecycle dates at this URL, in which "{package}" and "{stream}" are replaced by the package name and <major>.<minor>; streams it has no dates for keep those of their template`)
+}
+
+// apply replaces the lifecycle dates of the templates with those of the
+// endpoint, if one is configured.
+func (f *lifecycleFlags) apply(ctx context.Context, templates []graph.Template) ([]graph.Template, error) {
+	if f.url == "" {
+		return templates, nil
+	}
+	return lifecycle.Apply(ctx, lifecycle.HTTP{URL: f.url}, templates)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/liveness"
+	"github.com/joelanford/extensiondb/internal/printer"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/spf13/cobra"
+)
+
+func newLivenessCmd() *cobra.Command {
+	var (
+		opts     liveness.Options
+		interval time.Duration
+	)
+	cmd := &cobra.Command{
+		Use:   "liveness",
+		Short: "Check that the images of stored bundles still exist in their registries",
+		Long: `Check that the images of stored bundles still exist in their registries,
+and that the tags they were ingested from still refer to them.
+
+Each image is checked with HEAD requests, without fetching its content. The
+status of each checked bundle (live, missing, or retagged) is recorded with
+the time it was checked and the last time it was found live. List the results
+with "query liveness".
+
+With --sample, only that many bundles are checked, least recently checked
+first, so that repeated runs cycle through every bundle. Bundles whose
+registry can't be reached are not recorded and are checked first the next
+time.
+
+By default, the bundles are checked once. With --interval, they are checked
+on that schedule; errors are logged and the bundles are retried at the next
+tick.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			pdb, err := openDB()
+			if err != nil {
+				return err
+			}
+			if err := pdb.RunMigrations("migrations"); err != nil {
+				return fmt.Errorf("failed to run migrations: %w", err)
+			}
+			q := query.New(pdb.DB)
+
+			check := func(ctx context.Context) error {
+				r, err := liveness.Check(ctx, q, opts)
+				log.Printf("checked bundles: %d live, %d missing, %d retagged", r.Live, r.Missing, r.Retagged)
+				return err
+			}
+
+			if interval <= 0 {
+				return check(cmd.Context())
+			}
+
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				if err := check(cmd.Context()); err != nil {
+					log.Printf("error checking bundles: %v", err)
+				}
+				select {
+				case <-cmd.Context().Done():
+					return nil
+				case <-ticker.C:
+				}
+			}
+		},
+	}
+	cmd.Flags().IntVar(&opts.Sample, "sample", 0, "check this many of the least recently checked bundles, or 0 for every bundle")
+	cmd.Flags().IntVar(&opts.Concurrency, "concurrency", 8, "how many bundles to check at once")
+	cmd.Flags().DurationVar(&interval, "interval", 0, "check bundles on this interval instead of once")
+	return cmd
+}
+
+type livenessResult struct {
+	Package        string     `json:"package"`
+	Version        string     `json:"version"`
+	Digest         string     `json:"digest"`
+	Status         string     `json:"status"`
+	Message        string     `json:"message,omitempty"`
+	CheckedAt      *time.Time `json:"checkedAt,omitempty"`
+	LastVerifiedAt *time.Time `json:"lastVerifiedAt,omitempty"`
+}
+
+func newQueryLivenessCmd(output *string) *cobra.Command {
+	var statuses []string
+	cmd := &cobra.Command{
+		Use:   "liveness",
+		Short: "List the latest registry liveness check of each checked bundle",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			pdb, err := openDB()
+			if err != nil {
+				return err
+			}
+			checks, err := query.New(pdb.DB).ListBundleLiveness(cmd.Context(), statuses)
+			if err != nil {
+				return err
+			}
+
+			results := make([]livenessResult, 0, len(checks))
+			for _, c := range checks {
+				r := livenessResult{Package: c.PackageName, Version: c.Version, Digest: c.Digest, Status: c.Status, Message: c.Message.String}
+				if c.CheckedAt.Valid {
+					r.CheckedAt = &c.CheckedAt.Time
+				}
+				if c.LastVerifiedAt.Valid {
+					r.LastVerifiedAt = &c.LastVerifiedAt.Time
+				}
+				results = append(results, r)
+			}
+			return printer.Print(cmd.OutOrStdout(), *output, results, []printer.Column[livenessResult]{
+				{Header: "package", Value: func(r livenessResult) string { return r.Package }},
+				{Header: "version", Value: func(r livenessResult) string { return r.Version }},
+				{Header: "status", Value: func(r livenessResult) string { return r.Status }},
+				{Header: "checked", Value: func(r livenessResult) string { return formatTime(r.CheckedAt) }},
+				{Header: "last verified", Value: func(r livenessResult) string { return formatTime(r.LastVerifiedAt) }},
+				{Header: "message", Value: func(r livenessResult) string { return r.Message }},
+			})
+		},
+	}
+	cmd.Flags().StringSliceVar(&statuses, "status", nil, "only list bundles with these statuses (live, missing, retagged)")
+	return cmd
+}
@@ -2,216 +2,83 @@ package main
 
 import (
 	"context"
-	"database/sql"
-	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
-	"os"
 	"os/signal"
-	"path/filepath"
-	"strings"
-	"sync"
 	"syscall"
 
 	"github.com/joelanford/extensiondb/internal/db"
-	"github.com/joelanford/extensiondb/internal/models"
-	"github.com/joelanford/extensiondb/internal/query"
-	"github.com/joelanford/extensiondb/internal/registry"
-	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
-	"github.com/operator-framework/operator-registry/alpha/declcfg"
-	"go.podman.io/image/v5/docker/reference"
-	"golang.org/x/sync/errgroup"
+	"github.com/spf13/cobra"
 )
 
 func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
-	pdb, err := db.NewDB(db.Config{
-		Host:     "localhost",
-		Port:     5432,
-		User:     "postgres",
-		Password: "postgres",
-		DBName:   "extensiondb",
-		SSLMode:  "disable",
-	})
-	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
-	}
-
-	// Run migrations
-	if err := pdb.RunMigrations("migrations"); err != nil {
-		log.Fatalf("Failed to run migrations: %v", err)
-	}
-
-	q := query.New(pdb.DB)
-
-	catalogNames := []string{
-		"redhat-operator-index",
-		"certified-operator-index",
-	}
-	catalogVersions := []string{
-		"v4.19",
-		"v4.18",
-		"v4.17",
-		"v4.16",
-		"v4.15",
-		"v4.14",
-		"v4.13",
-		"v4.12",
+	rootCmd := &cobra.Command{
+		Use:          "extensiondb",
+		Short:        "Store and analyze operator catalog data",
+		SilenceUsage: true,
 	}
-
-	if err := buildDB(ctx, os.Getenv("CATALOGS_DIR"), q, catalogNames, catalogVersions); err != nil {
+	addDBFlags(rootCmd)
+	rootCmd.AddCommand(
+		newIngestCmd(),
+		newServeCmd(),
+		newExportCmd(),
+		newControllerCmd(),
+		newNotifyCmd(),
+		newPublishCmd(),
+		newQueryCmd(),
+		newGenerateCmd(),
+		newSnapshotCmd(),
+		newGraphCmd(),
+		newVizCmd(),
+		newRenderCmd(),
+		newPlanCmd(),
+		newCompatCmd(),
+		newPathsCmd(),
+		newImpactCmd(),
+		newVulnsCmd(),
+		newDiffCmd(),
+		newTrendsCmd(),
+		newLivenessCmd(),
+		newPruneCmd(),
+		newImportStreamsCmd(),
+		newMigrateLegacyCmd(),
+		newRetryFailedCmd(),
+		newDoctorCmd(),
+	)
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		log.Fatal(err)
 	}
 }
 
-func readCatalogDigest(catalogDir string) (string, error) {
-	digestFile := filepath.Join(catalogDir, ".metadata", "digest")
-	digestBytes, err := os.ReadFile(digestFile)
-	if err != nil {
-		return "", err
-	}
-	return fmt.Sprintf("sha256:%s", strings.TrimSpace(string(digestBytes))), nil
+// dbFlags are the database connection flags of the root command. Flags that
+// aren't set fall back to the environment variables that db.ConfigFromEnv
+// reads, and then to db.DefaultConfig.
+var dbFlags db.Config
+
+func addDBFlags(cmd *cobra.Command) {
+	defaults := db.DefaultConfig()
+	flags := cmd.PersistentFlags()
+	flags.StringVar(&dbFlags.URL, "db-url", "", fmt.Sprintf("database connection URL or key/value connection string, which overrides the other --db flags and their variables (default: $%s)", db.EnvURL))
+	flags.StringVar(&dbFlags.Host, "db-host", "", fmt.Sprintf("database host (default: $%s or %s)", db.EnvHost, defaults.Host))
+	flags.IntVar(&dbFlags.Port, "db-port", 0, fmt.Sprintf("database port (default: $%s or %d)", db.EnvPort, defaults.Port))
+	flags.StringVar(&dbFlags.User, "db-user", "", fmt.Sprintf("database user (default: $%s or %s)", db.EnvUser, defaults.User))
+	flags.StringVar(&dbFlags.Password, "db-password", "", fmt.Sprintf("database password (default: $%s or %s)", db.EnvPassword, defaults.Password))
+	flags.StringVar(&dbFlags.DBName, "db-name", "", fmt.Sprintf("database name (default: $%s or %s)", db.EnvName, defaults.DBName))
+	flags.StringVar(&dbFlags.SSLMode, "db-sslmode", "", fmt.Sprintf("database SSL mode (default: $%s or %s)", db.EnvSSLMode, defaults.SSLMode))
 }
 
-func buildDB(ctx context.Context, catalogsDir string, q *query.Query, catalogNames []string, catalogTags []string) error {
-	for _, catalogName := range catalogNames {
-		for _, catalogTag := range catalogTags {
-			fmt.Printf("Processing catalog %s:%s\n", catalogName, catalogTag)
-
-			catalogDir := filepath.Join(catalogsDir, catalogName, strings.TrimPrefix(catalogTag, "v"))
-
-			c, err := q.GetOrCreateCatalog(ctx, catalogName, catalogTag)
-			if err != nil {
-				return fmt.Errorf("error creating catalog %s:%s: %w", catalogName, catalogTag, err)
-			}
-
-			catalogDigest, err := readCatalogDigest(catalogDir)
-			if err != nil {
-				return fmt.Errorf("error reading catalog digest for %s:%s: %w", catalogName, catalogTag, err)
-			}
-			cd, err := q.GetOrCreateCatalogDigest(ctx, c, catalogDigest)
-			if err != nil {
-				return fmt.Errorf("error creating catalog digest for %s:%s: %w", catalogName, catalogTag, err)
-			}
-
-			imageRefChan := make(chan reference.Named)
-			imageRefs := make([]reference.Named, 0)
-			imageRefWg := sync.WaitGroup{}
-			imageRefWg.Go(func() {
-				for ref := range imageRefChan {
-					imageRefs = append(imageRefs, ref)
-				}
-			})
-
-			if err := declcfg.WalkMetasFS(ctx, os.DirFS(catalogDir), func(path string, meta *declcfg.Meta, err error) error {
-				if err != nil {
-					return err
-				}
-				if meta.Schema != declcfg.SchemaBundle {
-					return nil
-				}
-				var b struct {
-					Image string `json:"image"`
-				}
-				if err := json.Unmarshal(meta.Blob, &b); err != nil {
-					return err
-				}
-
-				namedRef, err := reference.ParseNamed(b.Image)
-				if err != nil {
-					return err
-				}
-
-				select {
-				case <-ctx.Done():
-					return ctx.Err()
-				case imageRefChan <- namedRef:
-				}
-				return nil
-			}, declcfg.WithConcurrency(16)); err != nil {
-				return err
-			}
-			close(imageRefChan)
-			imageRefWg.Wait()
-
-			type logWithTotal struct {
-				msg   string
-				total int
-			}
-			messagesChan := make(chan logWithTotal)
-			logWg := sync.WaitGroup{}
-			logWg.Go(func() {
-				i := 0
-				for msg := range messagesChan {
-					i++
-					fmt.Printf("%s: (%d of %d)\n", msg.msg, i, msg.total)
-				}
-			})
-
-			eg, egCtx := errgroup.WithContext(ctx)
-			eg.SetLimit(32)
-			for _, imageRef := range imageRefs {
-				eg.Go(func() error {
-					canonicalRef, ok := imageRef.(reference.Canonical)
-					if !ok {
-						return fmt.Errorf("image reference is not a canonical reference")
-					}
-
-					br, err := q.GetOrCreateCanonicalBundleReference(egCtx, canonicalRef)
-					if err != nil {
-						return fmt.Errorf("error creating bundle reference %s: %w", imageRef, err)
-					}
-
-					if err := q.EnsureCatalogDigestBundleReference(ctx, cd, br); err != nil {
-						return fmt.Errorf("error ensuring catalog bundle reference %s: %w", imageRef, err)
-					}
-
-					if b, err := q.GetBundleByDigest(egCtx, canonicalRef.Digest()); err == nil {
-						if err := q.EnsureBundleReferenceBundle(egCtx, b, br); err != nil {
-							return fmt.Errorf("error ensuring bundle reference %s: %w", imageRef, err)
-						}
-						messagesChan <- logWithTotal{msg: fmt.Sprintf("Successfully updated bundle for %q", canonicalRef), total: len(imageRefs)}
-						return nil
-					} else if !errors.Is(err, sql.ErrNoRows) {
-						return fmt.Errorf("error getting bundle: %w", err)
-					}
-
-					// Fetch image info from registry using canonical reference
-					imageInfo, err := registry.FetchRegistryV1Bundle(egCtx, canonicalRef)
-					if err != nil {
-						messagesChan <- logWithTotal{msg: fmt.Sprintf("Failed to fetch image info for %v: %v", canonicalRef, err), total: len(imageRefs)}
-						return nil
-					}
-
-					p, err := q.GetOrCreatePackage(egCtx, imageInfo.PackageName)
-					if err != nil {
-						return fmt.Errorf("error creating package %s: %w", imageInfo.PackageName, err)
-					}
-
-					b := &models.Bundle{
-						PackageID:  sql.NullString{String: p.ID, Valid: true},
-						Descriptor: models.JSONB[ocispec.Descriptor]{V: &imageInfo.ReferenceDescriptor},
-						Index:      models.JSONB[ocispec.Index]{imageInfo.Index},
-						Manifest:   models.JSONB[ocispec.Manifest]{&imageInfo.Manifest},
-						Image:      models.JSONB[ocispec.Image]{&imageInfo.ImageConfig},
-						Version:    imageInfo.CSV.Spec.Version.String(),
-					}
-					if err := q.CreateBundleWithCatalogAndReference(egCtx, b, c, br); err != nil {
-						return fmt.Errorf("error creating bundle: %w", err)
-					}
-					messagesChan <- logWithTotal{msg: fmt.Sprintf("Successfully created bundle for %q", canonicalRef), total: len(imageRefs)}
-					return nil
-				})
-			}
-			if err := eg.Wait(); err != nil {
-				return err
-			}
-			close(messagesChan)
-			logWg.Wait()
-		}
+func openDB() (*db.DB, error) {
+	env, err := db.ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	pdb, err := db.NewDB(dbFlags.WithDefaults(env).WithDefaults(db.DefaultConfig()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
-	return nil
+	return pdb, nil
 }
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/freshness"
+	"github.com/joelanford/extensiondb/internal/graphdb"
+	"github.com/joelanford/extensiondb/internal/notify"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/spf13/cobra"
+)
+
+func newNotifyCmd() *cobra.Command {
+	var (
+		templatesDir    string
+		eolWarningDays  int
+		maxAge          time.Duration
+		slackWebhookURL string
+		webhookURL      string
+		smtpAddr        string
+		smtpUsername    string
+		smtpPassword    string
+		emailFrom       string
+		emailTo         []string
+		thresholdFlags  freshnessFlags
+		lifecycleSrc    lifecycleFlags
+	)
+	cmd := &cobra.Command{
+		Use:   "notify",
+		Short: "Send notifications when version streams cross lifecycle boundaries or catalogs go stale",
+		Long: `Send notifications when the version streams described by olm.cincinnati
+templates enter a new lifecycle phase or approach their end of life, and,
+with freshness thresholds, when a catalog's digest has not changed or the
+catalog has not been ingested for longer than its threshold.
+
+Each notification is sent once. Run this command periodically, for example
+daily from a cron job.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			var notifiers notify.Notifiers
+			if slackWebhookURL != "" {
+				notifiers = append(notifiers, notify.SlackNotifier{WebhookURL: slackWebhookURL})
+			}
+			if webhookURL != "" {
+				notifiers = append(notifiers, notify.WebhookNotifier{URL: webhookURL})
+			}
+			if smtpAddr != "" {
+				if emailFrom == "" || len(emailTo) == 0 {
+					return errors.New("--smtp-addr requires --email-from and --email-to")
+				}
+				en, err := notify.NewEmailNotifier(smtpAddr, smtpUsername, smtpPassword, emailFrom, emailTo)
+				if err != nil {
+					return err
+				}
+				notifiers = append(notifiers, en)
+			}
+			if len(notifiers) == 0 {
+				return errors.New("no notifiers configured; set at least one of --slack-webhook-url, --webhook-url, or --smtp-addr")
+			}
+
+			templates, err := graphdb.ReadTemplatesDir(templatesDir)
+			if err != nil {
+				return fmt.Errorf("failed to read templates: %w", err)
+			}
+			templates, err = lifecycleSrc.apply(cmd.Context(), templates)
+			if err != nil {
+				return err
+			}
+			thresholds, err := thresholdFlags.thresholds()
+			if err != nil {
+				return err
+			}
+
+			pdb, err := openDB()
+			if err != nil {
+				return err
+			}
+			if err := pdb.RunMigrations("migrations"); err != nil {
+				return fmt.Errorf("failed to run migrations: %w", err)
+			}
+
+			q := query.New(pdb.DB)
+			now := time.Now()
+			events := notify.Events(templates, now, notify.Options{
+				EndOfLifeWarning: time.Duration(eolWarningDays) * 24 * time.Hour,
+				MaxAge:           maxAge,
+			})
+			if thresholdFlags.enabled() {
+				catalogs, err := q.ListCatalogFreshness(cmd.Context())
+				if err != nil {
+					return err
+				}
+				events = append(events, freshness.Events(freshness.Check(catalogs, thresholds, now))...)
+			}
+			return notify.Send(cmd.Context(), q, notifiers, events)
+		},
+	}
+	cmd.Flags().StringVar(&templatesDir, "templates-dir", "./examples/cincinnati/product-templates", "directory containing olm.cincinnati templates")
+	cmd.Flags().IntVar(&eolWarningDays, "eol-warning-days", 30, "notify this many days before a version stream's end of life, or 0 to disable")
+	cmd.Flags().DurationVar(&maxAge, "max-age", 7*24*time.Hour, "ignore lifecycle boundaries crossed longer ago than this")
+	thresholdFlags.addFlags(cmd)
+	lifecycleSrc.addFlags(cmd)
+
+	cmd.Flags().StringVar(&slackWebhookURL, "slack-webhook-url", "", "post notifications to this Slack incoming webhook")
+	cmd.Flags().StringVar(&webhookURL, "webhook-url", "", "post notifications as JSON to this URL")
+	cmd.Flags().StringVar(&smtpAddr, "smtp-addr", "", "send notifications by email through this SMTP server (host:port)")
+	cmd.Flags().StringVar(&smtpUsername, "smtp-username", "", "username for the SMTP server")
+	cmd.Flags().StringVar(&smtpPassword, "smtp-password", "", "password for the SMTP server")
+	cmd.Flags().StringVar(&emailFrom, "email-from", "", "sender address for notification emails")
+	cmd.Flags().StringSliceVar(&emailTo, "email-to", nil, "recipient addresses for notification emails")
+	return cmd
+}
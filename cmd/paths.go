@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/goldenpaths"
+	"github.com/spf13/cobra"
+)
+
+// defaultGoldenPathsDir is the directory of golden path files, one per
+// package.
+const defaultGoldenPathsDir = "testdata/golden-paths"
+
+func newPathsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "paths",
+		Short: "Record and verify golden update paths of packages",
+		Long: `Record the best update paths between key versions of packages in golden
+files, and verify them against the current graphs, so that catalog changes
+that break paths which used to be valid fail in CI:
+
+  extensiondb paths snapshot --package quay-operator
+  extensiondb paths verify`,
+	}
+	cmd.AddCommand(newPathsSnapshotCmd(), newPathsVerifyCmd())
+	return cmd
+}
+
+func newPathsSnapshotCmd() *cobra.Command {
+	var (
+		src          graphSource
+		packageNames []string
+		pairs        []string
+		dir          string
+		asOf         string
+	)
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Write the best update paths of packages to golden files",
+		Long: `Write the best update paths between key versions of each package to
+<dir>/<package>.json, replacing the file if it exists.
+
+By default, the paths recorded are from the oldest build of each major.minor
+version stream to the newest build of that stream and to the newest build of
+the package. --pair records the path between two versions of a package
+instead, as <package>@<from>:<to>; packages without any --pair keep the
+default pairs. Pairs without a path are not recorded.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			byPackage := map[string][]goldenpaths.Pair{}
+			for _, p := range pairs {
+				pkgName, pair, err := parsePairFlag(p)
+				if err != nil {
+					return err
+				}
+				if !slices.Contains(packageNames, pkgName) {
+					packageNames = append(packageNames, pkgName)
+				}
+				byPackage[pkgName] = append(byPackage[pkgName], pair)
+			}
+			if len(packageNames) == 0 {
+				return errors.New("--package or --pair is required")
+			}
+			t, err := parseTimeFlag("as-of", asOf, time.Now())
+			if err != nil {
+				return err
+			}
+			g, _, err := src.build(cmd.Context(), t, packageNames...)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return err
+			}
+			for _, pkgName := range packageNames {
+				pkgPairs, ok := byPackage[pkgName]
+				if !ok {
+					pkgPairs = goldenpaths.KeyPairs(g, pkgName)
+				}
+				f, err := goldenpaths.Snapshot(g, pkgName, pkgPairs)
+				if err != nil {
+					return err
+				}
+				path := filepath.Join(dir, pkgName+".json")
+				if err := f.WriteFile(path); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "wrote %d paths of %s to %s\n", len(f.Paths), pkgName, path)
+			}
+			return nil
+		},
+	}
+	src.addFlags(cmd)
+	cmd.Flags().StringSliceVar(&packageNames, "package", nil, "packages whose paths between key versions are recorded")
+	cmd.Flags().StringArrayVar(&pairs, "pair", nil, "record the path between two versions of a package, as <package>@<from>:<to>")
+	cmd.Flags().StringVar(&dir, "dir", defaultGoldenPathsDir, "directory to write the golden files to")
+	cmd.Flags().StringVar(&asOf, "as-of", "", "build the graph as of this date (default: now)")
+	return cmd
+}
+
+func newPathsVerifyCmd() *cobra.Command {
+	var (
+		src          graphSource
+		packageNames []string
+		dir          string
+		asOf         string
+	)
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify golden update paths against the current graphs",
+		Long: `Verify the golden update paths of each package in <dir> against the current
+graph of the package, and fail if any is broken: if a version of the path is
+no longer in the graph, or an update between two of its versions is no
+longer an edge of the graph.
+
+Paths that are still valid but are no longer the best path are reported as
+changed without failing. Run paths snapshot again to accept them.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if len(packageNames) == 0 {
+				matches, err := filepath.Glob(filepath.Join(dir, "*.json"))
+				if err != nil {
+					return err
+				}
+				for _, m := range matches {
+					packageNames = append(packageNames, strings.TrimSuffix(filepath.Base(m), ".json"))
+				}
+				if len(packageNames) == 0 {
+					return fmt.Errorf("no golden paths in %s", dir)
+				}
+			}
+			var files []*goldenpaths.File
+			for _, pkgName := range packageNames {
+				f, err := goldenpaths.ReadFile(filepath.Join(dir, pkgName+".json"))
+				if err != nil {
+					return err
+				}
+				files = append(files, f)
+			}
+			t, err := parseTimeFlag("as-of", asOf, time.Now())
+			if err != nil {
+				return err
+			}
+			g, _, err := src.build(cmd.Context(), t, packageNames...)
+			if err != nil {
+				return err
+			}
+
+			var total, broken int
+			for _, f := range files {
+				results := goldenpaths.Verify(g, f)
+				total += len(results)
+				broken += len(goldenpaths.Broken(results))
+				for _, r := range results {
+					switch r.Status {
+					case goldenpaths.StatusBroken:
+						fmt.Fprintf(cmd.OutOrStdout(), "BROKEN  %s %s -> %s: %s\n", r.Package, r.Path.From, r.Path.To, r.Reason)
+					case goldenpaths.StatusChanged:
+						fmt.Fprintf(cmd.OutOrStdout(), "CHANGED %s %s -> %s: %s, was %s\n", r.Package, r.Path.From, r.Path.To, strings.Join(r.Current, " -> "), strings.Join(r.Path.Nodes, " -> "))
+					}
+				}
+			}
+			if broken > 0 {
+				return fmt.Errorf("%d of %d golden paths are broken", broken, total)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "verified %d golden paths of %d packages\n", total, len(files))
+			return nil
+		},
+	}
+	src.addFlags(cmd)
+	cmd.Flags().StringSliceVar(&packageNames, "package", nil, "only verify the paths of these packages (default: every package in --dir)")
+	cmd.Flags().StringVar(&dir, "dir", defaultGoldenPathsDir, "directory of the golden files")
+	cmd.Flags().StringVar(&asOf, "as-of", "", "build the graph as of this date (default: now)")
+	return cmd
+}
+
+// parsePairFlag parses a --pair of the form <package>@<from>:<to>.
+func parsePairFlag(s string) (string, goldenpaths.Pair, error) {
+	pkgName, versions, ok := strings.Cut(s, "@")
+	from, to, ok2 := strings.Cut(versions, ":")
+	if !ok || !ok2 || pkgName == "" || from == "" || to == "" {
+		return "", goldenpaths.Pair{}, fmt.Errorf("--pair must be of the form <package>@<from>:<to>, got %q", s)
+	}
+	return pkgName, goldenpaths.Pair{From: from, To: to}, nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/printer"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/spf13/cobra"
+)
+
+type provenanceResult struct {
+	Package        string    `json:"package"`
+	Version        string    `json:"version"`
+	Digest         string    `json:"digest"`
+	Event          string    `json:"event"`
+	SourceType     string    `json:"sourceType"`
+	SourceID       string    `json:"sourceID"`
+	IngestionRunID string    `json:"ingestionRunID,omitempty"`
+	RecordedAt     time.Time `json:"recordedAt"`
+}
+
+func newQueryProvenanceCmd(output *string) *cobra.Command {
+	var filter query.BundleProvenanceFilter
+	cmd := &cobra.Command{
+		Use:   "provenance",
+		Short: "List the ingestions that created and last updated bundles",
+		Long: `List the ingestion that created each bundle and the one that last updated it,
+oldest bundle first, so that bad bundle data can be traced back to how it was
+ingested.
+
+Each is recorded with its source type, which is one of catalog (catalog
+ingestion), webhook (images pushed to a registry), read-through (images that
+clients looked up), or backfill (migrate-legacy), the source, such as the
+catalog redhat-operator-index:v4.19 or the pushed image, and the ingestion run.
+--digest, --source-type, --source, --run, and --package limit the list to the
+bundle with that digest, to those sources and runs, and to bundles of those
+packages. Bundles ingested before provenance was recorded are not listed.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			pdb, err := openDB()
+			if err != nil {
+				return err
+			}
+			records, err := query.New(pdb.DB).ListBundleProvenance(cmd.Context(), filter)
+			if err != nil {
+				return err
+			}
+			results := make([]provenanceResult, 0, len(records))
+			for _, r := range records {
+				results = append(results, provenanceResult{
+					Package:        r.Package,
+					Version:        r.Version,
+					Digest:         r.Digest,
+					Event:          r.Event,
+					SourceType:     r.SourceType,
+					SourceID:       r.SourceID,
+					IngestionRunID: r.IngestionRunID.String,
+					RecordedAt:     r.RecordedAt.Time,
+				})
+			}
+			return printer.Print(cmd.OutOrStdout(), *output, results, []printer.Column[provenanceResult]{
+				{Header: "package", Value: func(r provenanceResult) string { return r.Package }},
+				{Header: "version", Value: func(r provenanceResult) string { return r.Version }},
+				{Header: "event", Value: func(r provenanceResult) string { return r.Event }},
+				{Header: "source type", Value: func(r provenanceResult) string { return r.SourceType }},
+				{Header: "source", Value: func(r provenanceResult) string { return r.SourceID }},
+				{Header: "run", Value: func(r provenanceResult) string { return r.IngestionRunID }},
+				{Header: "recorded", Value: func(r provenanceResult) string { return formatTime(&r.RecordedAt) }},
+				{Header: "digest", Value: func(r provenanceResult) string { return r.Digest }},
+			})
+		},
+	}
+	cmd.Flags().StringVar(&filter.Digest, "digest", "", "only list the provenance of the bundle with this digest")
+	cmd.Flags().StringVar(&filter.SourceType, "source-type", "", "only list bundles ingested from this type of source: catalog, webhook, read-through, or backfill")
+	cmd.Flags().StringVar(&filter.SourceID, "source", "", "only list bundles ingested from this source, such as redhat-operator-index:v4.19")
+	cmd.Flags().StringVar(&filter.IngestionRunID, "run", "", "only list bundles ingested by this ingestion run")
+	cmd.Flags().StringSliceVar(&filter.Packages, "package", nil, "only list bundles of these packages")
+	return cmd
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/graphdb"
+	"github.com/joelanford/extensiondb/internal/publish"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/spf13/cobra"
+)
+
+func newPublishCmd() *cobra.Command {
+	var (
+		bucketURL      string
+		templatesDir   string
+		catalogs       []string
+		plansFile      string
+		interval       time.Duration
+		afterIngestion bool
+	)
+	cmd := &cobra.Command{
+		Use:   "publish",
+		Short: "Publish snapshots of update graphs, catalogs, and plan reports to object storage",
+		Long: `Publish snapshots of update graphs, catalogs, and plan reports to object
+storage, for consumers that can't reach the database.
+
+The bucket is an s3://<bucket>/<prefix>, gs://<bucket>/<prefix>, or
+file:///<dir> URL. Each snapshot is written under <prefix>/<version>/, where
+version is the UTC time the snapshot was taken, and its manifest is copied to
+<prefix>/latest.json once the snapshot is complete.
+
+By default, a single snapshot is published. With --interval, a snapshot is
+published on that schedule; adding --after-ingestion publishes only when an
+ingestion run has finished since the previous snapshot.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if afterIngestion && interval <= 0 {
+				return errors.New("--after-ingestion requires --interval")
+			}
+
+			bucket, prefix, err := publish.OpenBucket(bucketURL)
+			if err != nil {
+				return err
+			}
+			templates, err := graphdb.ReadTemplatesDir(templatesDir)
+			if err != nil {
+				return fmt.Errorf("failed to read templates: %w", err)
+			}
+			var plans []publish.Plan
+			if plansFile != "" {
+				plans, err = publish.ReadPlansFile(plansFile)
+				if err != nil {
+					return err
+				}
+			}
+
+			pdb, err := openDB()
+			if err != nil {
+				return err
+			}
+			q := query.New(pdb.DB)
+			p := &publish.Publisher{
+				Bucket:    bucket,
+				Prefix:    prefix,
+				Query:     q,
+				Builder:   graphdb.New(pdb.DB),
+				Templates: templates,
+				Catalogs:  catalogs,
+				Plans:     plans,
+			}
+
+			publishOnce := func(ctx context.Context) (*publish.Manifest, error) {
+				m, err := p.Publish(ctx, time.Now())
+				if err != nil {
+					return nil, err
+				}
+				log.Printf("published snapshot %s (%d objects)", m.Version, len(m.Objects))
+				return m, nil
+			}
+
+			m, err := publishOnce(cmd.Context())
+			if err != nil || interval <= 0 {
+				return err
+			}
+			lastRun := m.IngestionRun
+
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-cmd.Context().Done():
+					return nil
+				case <-ticker.C:
+				}
+				if afterIngestion {
+					run, err := q.GetLatestIngestionRun(cmd.Context())
+					if errors.Is(err, sql.ErrNoRows) {
+						continue
+					}
+					if err != nil {
+						log.Printf("error getting latest ingestion run: %v", err)
+						continue
+					}
+					if run.ID == lastRun {
+						continue
+					}
+				}
+				m, err := publishOnce(cmd.Context())
+				if err != nil {
+					log.Printf("error publishing snapshot: %v", err)
+					continue
+				}
+				lastRun = m.IngestionRun
+			}
+		},
+	}
+	cmd.Flags().StringVar(&bucketURL, "bucket", "", "bucket to publish to (s3://<bucket>/<prefix>, gs://<bucket>/<prefix>, or file:///<dir>)")
+	cmd.Flags().StringVar(&templatesDir, "templates-dir", "./examples/cincinnati/product-templates", "directory containing olm.cincinnati templates")
+	cmd.Flags().StringSliceVar(&catalogs, "catalog", nil, "limit the exported catalogs to these <name>:<tag> references")
+	cmd.Flags().StringVar(&plansFile, "plans-file", "", "YAML file listing OpenShift update plans to publish reports for")
+	cmd.Flags().DurationVar(&interval, "interval", 0, "publish a snapshot on this interval instead of once")
+	cmd.Flags().BoolVar(&afterIngestion, "after-ingestion", false, "with --interval, publish only when an ingestion run has finished since the previous snapshot")
+	_ = cmd.MarkFlagRequired("bucket")
+	return cmd
+}
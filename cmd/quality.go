@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/joelanford/extensiondb/internal/csvquality"
+	"github.com/joelanford/extensiondb/internal/printer"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/spf13/cobra"
+)
+
+type flaggedBundleResult struct {
+	Package string   `json:"package"`
+	Version string   `json:"version"`
+	Release string   `json:"release,omitempty"`
+	Digest  string   `json:"digest"`
+	Flags   []string `json:"flags"`
+}
+
+func newQueryQualityCmd(output *string) *cobra.Command {
+	var (
+		flags        []string
+		packageNames []string
+	)
+	cmd := &cobra.Command{
+		Use:   "quality",
+		Short: "List the bundles whose ClusterServiceVersions have quality flags",
+		Long: `List the bundles whose ClusterServiceVersions have quality flags, by package
+and version.
+
+The ClusterServiceVersion of each bundle is checked when the bundle is
+ingested, and each problem found is recorded as a flag:
+
+  ` + csvquality.MissingVersion + `        spec.version is missing
+  ` + csvquality.InvalidInstallModes + `  spec.installModes are missing, unknown, duplicated, or all unsupported
+  ` + csvquality.BrokenRelatedImages + `  an image in spec.relatedImages is missing or not a valid reference
+
+--flag limits the list to bundles with any of those flags. Bundles ingested
+before ClusterServiceVersions were checked are never listed.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			for _, f := range flags {
+				if !slices.Contains(csvquality.Flags, f) {
+					return fmt.Errorf("unknown --flag %q; expected one of %s", f, strings.Join(csvquality.Flags, ", "))
+				}
+			}
+			pdb, err := openDB()
+			if err != nil {
+				return err
+			}
+			bundles, err := query.New(pdb.DB).ListFlaggedBundles(cmd.Context(), flags, packageNames)
+			if err != nil {
+				return err
+			}
+			results := make([]flaggedBundleResult, 0, len(bundles))
+			for _, b := range bundles {
+				results = append(results, flaggedBundleResult{
+					Package: b.Package,
+					Version: b.Version,
+					Release: b.Release.String,
+					Digest:  b.Digest,
+					Flags:   b.Flags,
+				})
+			}
+			return printer.Print(cmd.OutOrStdout(), *output, results, []printer.Column[flaggedBundleResult]{
+				{Header: "package", Value: func(r flaggedBundleResult) string { return r.Package }},
+				{Header: "version", Value: func(r flaggedBundleResult) string { return r.Version }},
+				{Header: "release", Value: func(r flaggedBundleResult) string { return r.Release }},
+				{Header: "flags", Value: func(r flaggedBundleResult) string { return strings.Join(r.Flags, ",") }},
+				{Header: "digest", Value: func(r flaggedBundleResult) string { return r.Digest }},
+			})
+		},
+	}
+	cmd.Flags().StringSliceVar(&flags, "flag", nil, "only list bundles with any of these flags: "+strings.Join(csvquality.Flags, ", "))
+	cmd.Flags().StringSliceVar(&packageNames, "package", nil, "only list the bundles of these packages")
+	return cmd
+}
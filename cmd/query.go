@@ -0,0 +1,342 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/graphdb"
+	"github.com/joelanford/extensiondb/internal/lifecycle"
+	"github.com/joelanford/extensiondb/internal/printer"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/opencontainers/go-digest"
+	"github.com/spf13/cobra"
+)
+
+func newQueryCmd() *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "query",
+		Short: "Look up bundles, catalogs, and packages in the database",
+	}
+	cmd.PersistentFlags().StringVarP(&output, "output", "o", "table", "output format: "+printer.Formats)
+	cmd.AddCommand(
+		newQueryBundlesCmd(&output),
+		newQueryCatalogsCmd(&output),
+		newQueryPackagesCmd(&output),
+		newQueryLifecycleCmd(&output),
+		newQueryFreshnessCmd(&output),
+		newQueryLivenessCmd(&output),
+		newQueryUnreferencedCmd(&output),
+		newQueryLabelsCmd(&output),
+		newQueryDefaultChannelsCmd(&output),
+		newQueryCSVCmd(),
+		newQueryQualityCmd(&output),
+		newQueryRebuildsCmd(&output),
+		newQueryProvenanceCmd(&output),
+	)
+	return cmd
+}
+
+type bundleResult struct {
+	Package   string     `json:"package"`
+	Version   string     `json:"version"`
+	Release   string     `json:"release,omitempty"`
+	Digest    string     `json:"digest"`
+	BuildTime *time.Time `json:"buildTime,omitempty"`
+}
+
+func newQueryBundlesCmd(output *string) *cobra.Command {
+	var (
+		pkgName   string
+		verified  bool
+		certified bool
+	)
+	cmd := &cobra.Command{
+		Use:   "bundles",
+		Short: "List the bundles of a package, oldest first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			pdb, err := openDB()
+			if err != nil {
+				return err
+			}
+			q := query.New(pdb.DB)
+
+			pkg, err := q.GetPackageByName(cmd.Context(), pkgName)
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("package %q not found", pkgName)
+			}
+			if err != nil {
+				return err
+			}
+			list := q.ListBundlesForPackage
+			if verified {
+				list = q.ListVerifiedBundlesForPackage
+			}
+			if certified {
+				list = q.ListCertifiedBundlesForPackage
+			}
+			bundles, err := list(cmd.Context(), pkg)
+			if err != nil {
+				return err
+			}
+
+			results := make([]bundleResult, 0, len(bundles))
+			for _, b := range bundles {
+				r := bundleResult{Package: pkg.Name, Version: b.Version, Release: b.Release.String}
+				if b.Descriptor.V != nil {
+					r.Digest = b.Descriptor.V.Digest.String()
+				}
+				if b.Image.V != nil {
+					r.BuildTime = b.Image.V.Created
+				}
+				results = append(results, r)
+			}
+			return printer.Print(cmd.OutOrStdout(), *output, results, []printer.Column[bundleResult]{
+				{Header: "package", Value: func(r bundleResult) string { return r.Package }},
+				{Header: "version", Value: func(r bundleResult) string { return r.Version }},
+				{Header: "release", Value: func(r bundleResult) string { return r.Release }},
+				{Header: "built", Value: func(r bundleResult) string { return formatTime(r.BuildTime) }},
+				{Header: "digest", Value: func(r bundleResult) string { return r.Digest }},
+			})
+		},
+	}
+	cmd.Flags().StringVar(&pkgName, "package", "", "package to list bundles for")
+	cmd.Flags().BoolVar(&verified, "verified", false, "only list bundles whose signatures and attestations have been verified")
+	cmd.Flags().BoolVar(&certified, "certified", false, "only list bundles that are certified in the Red Hat Ecosystem Catalog (requires ingesting with --pyxis-url)")
+	cmd.MarkFlagsMutuallyExclusive("verified", "certified")
+	_ = cmd.MarkFlagRequired("package")
+	return cmd
+}
+
+type catalogResult struct {
+	Name           string    `json:"name"`
+	Tag            string    `json:"tag"`
+	FirstShippedAt time.Time `json:"firstShippedAt"`
+	LastShippedAt  time.Time `json:"lastShippedAt"`
+	Current        bool      `json:"current"`
+}
+
+func newQueryCatalogsCmd(output *string) *cobra.Command {
+	var digestString string
+	cmd := &cobra.Command{
+		Use:   "catalogs",
+		Short: "List the catalogs that have ever contained an image",
+		Long: `List the catalogs that have ever contained an image digest, from any
+repository, with when each catalog first and last contained it and whether
+its latest ingested digest still does.
+
+The image need not have been ingested as a bundle, so this also finds images
+missing from their registries.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			dgst, err := digest.Parse(digestString)
+			if err != nil {
+				return fmt.Errorf("invalid digest %q: %w", digestString, err)
+			}
+
+			pdb, err := openDB()
+			if err != nil {
+				return err
+			}
+			q := query.New(pdb.DB)
+
+			shipments, err := q.GetCatalogsForDigest(cmd.Context(), dgst)
+			if err != nil {
+				return err
+			}
+			if len(shipments) == 0 {
+				return fmt.Errorf("no catalog has contained an image with digest %s", dgst)
+			}
+
+			results := make([]catalogResult, 0, len(shipments))
+			for _, s := range shipments {
+				results = append(results, catalogResult{
+					Name:           s.Catalog.Name,
+					Tag:            s.Catalog.Tag,
+					FirstShippedAt: s.FirstShippedAt,
+					LastShippedAt:  s.LastShippedAt,
+					Current:        s.Current,
+				})
+			}
+			return printer.Print(cmd.OutOrStdout(), *output, results, []printer.Column[catalogResult]{
+				{Header: "name", Value: func(r catalogResult) string { return r.Name }},
+				{Header: "tag", Value: func(r catalogResult) string { return r.Tag }},
+				{Header: "first shipped", Value: func(r catalogResult) string { return formatTime(&r.FirstShippedAt) }},
+				{Header: "last shipped", Value: func(r catalogResult) string { return formatTime(&r.LastShippedAt) }},
+				{Header: "current", Value: func(r catalogResult) string { return strconv.FormatBool(r.Current) }},
+			})
+		},
+	}
+	cmd.Flags().StringVar(&digestString, "digest", "", "digest of the image")
+	_ = cmd.MarkFlagRequired("digest")
+	return cmd
+}
+
+type packageResult struct {
+	Name       string    `json:"name"`
+	Bundles    int       `json:"bundles"`
+	FirstBuilt time.Time `json:"firstBuilt"`
+	LastBuilt  time.Time `json:"lastBuilt"`
+}
+
+func newQueryPackagesCmd(output *string) *cobra.Command {
+	var since, until string
+	cmd := &cobra.Command{
+		Use:   "packages",
+		Short: "List the packages with bundles built in a time range",
+		Long: `List the packages with bundles built in a time range, with the number of
+bundles built in the range and the first and last build times.
+
+--built-since and --built-until accept dates (2006-01-02) or RFC 3339 times.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			sinceTime, err := parseTimeFlag("built-since", since, time.Time{})
+			if err != nil {
+				return err
+			}
+			untilTime, err := parseTimeFlag("built-until", until, time.Now())
+			if err != nil {
+				return err
+			}
+
+			pdb, err := openDB()
+			if err != nil {
+				return err
+			}
+			packages, err := query.New(pdb.DB).ListPackagesBuiltBetween(cmd.Context(), sinceTime, untilTime)
+			if err != nil {
+				return err
+			}
+
+			results := make([]packageResult, 0, len(packages))
+			for _, p := range packages {
+				results = append(results, packageResult(p))
+			}
+			return printer.Print(cmd.OutOrStdout(), *output, results, []printer.Column[packageResult]{
+				{Header: "name", Value: func(r packageResult) string { return r.Name }},
+				{Header: "bundles", Value: func(r packageResult) string { return strconv.Itoa(r.Bundles) }},
+				{Header: "first built", Value: func(r packageResult) string { return formatTime(&r.FirstBuilt) }},
+				{Header: "last built", Value: func(r packageResult) string { return formatTime(&r.LastBuilt) }},
+			})
+		},
+	}
+	cmd.Flags().StringVar(&since, "built-since", "", "only count bundles built at or after this time")
+	cmd.Flags().StringVar(&until, "built-until", "", "only count bundles built before this time (default now)")
+	return cmd
+}
+
+func newQueryLifecycleCmd(output *string) *cobra.Command {
+	var (
+		templatesDir string
+		fromDB       bool
+		date         string
+		eolBefore    string
+		lifecycleSrc lifecycleFlags
+	)
+	cmd := &cobra.Command{
+		Use:   "lifecycle",
+		Short: "List the lifecycle phase of every version stream of every package",
+		Long: `List the lifecycle phase of every version stream of every package as of a
+date, with the days remaining until its next phase and the successor stream
+to move to.
+
+With --eol-before, list only the streams that reach their end of life before
+that date, soonest first. For example, to list the streams that reach their
+end of life in the next quarter:
+
+  extensiondb query lifecycle --eol-before $(date -d '+3 months' +%F)
+
+With --from-db, the version streams stored by "import-streams" are used
+instead of the templates.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			asOf, err := parseTimeFlag("date", date, time.Now())
+			if err != nil {
+				return err
+			}
+			var templates []graph.Template
+			if fromDB {
+				pdb, err := openDB()
+				if err != nil {
+					return err
+				}
+				templates, err = query.New(pdb.DB).ListVersionStreams(cmd.Context())
+				if err != nil {
+					return err
+				}
+			} else {
+				templates, err = graphdb.ReadTemplatesDir(templatesDir)
+				if err != nil {
+					return fmt.Errorf("failed to read templates: %w", err)
+				}
+			}
+			templates, err = lifecycleSrc.apply(cmd.Context(), templates)
+			if err != nil {
+				return err
+			}
+
+			streams := lifecycle.Streams(templates, asOf)
+			if eolBefore != "" {
+				before, err := parseTimeFlag("eol-before", eolBefore, time.Time{})
+				if err != nil {
+					return err
+				}
+				streams = lifecycle.EndingBefore(streams, asOf, before)
+			}
+			return printer.Print(cmd.OutOrStdout(), *output, streams, []printer.Column[lifecycle.Stream]{
+				{Header: "package", Value: func(s lifecycle.Stream) string { return s.Package }},
+				{Header: "stream", Value: func(s lifecycle.Stream) string { return s.Stream.String() }},
+				{Header: "phase", Value: func(s lifecycle.Stream) string { return s.Phase }},
+				{Header: "next phase", Value: func(s lifecycle.Stream) string { return s.NextPhase }},
+				{Header: "starts", Value: func(s lifecycle.Stream) string {
+					if s.NextPhaseDate == nil {
+						return ""
+					}
+					return s.NextPhaseDate.Time().Format(time.DateOnly)
+				}},
+				{Header: "days left", Value: func(s lifecycle.Stream) string {
+					if s.DaysRemaining == nil {
+						return ""
+					}
+					return strconv.Itoa(*s.DaysRemaining)
+				}},
+				{Header: "successor", Value: func(s lifecycle.Stream) string {
+					if s.Successor == nil {
+						return ""
+					}
+					return s.Successor.String()
+				}},
+			})
+		},
+	}
+	cmd.Flags().StringVar(&templatesDir, "templates-dir", "./examples/cincinnati/product-templates", "directory containing olm.cincinnati templates")
+	cmd.Flags().BoolVar(&fromDB, "from-db", false, "read the version streams from the database instead of --templates-dir")
+	cmd.Flags().StringVar(&date, "date", "", "report the lifecycle as of this date (default now)")
+	cmd.Flags().StringVar(&eolBefore, "eol-before", "", "only list streams that reach their end of life before this date")
+	lifecycleSrc.addFlags(cmd)
+	return cmd
+}
+
+func parseTimeFlag(name, value string, def time.Time) (time.Time, error) {
+	if value == "" {
+		return def, nil
+	}
+	for _, layout := range []string{time.DateOnly, time.RFC3339} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --%s %q: expected a date (2006-01-02) or RFC 3339 time", name, value)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"github.com/joelanford/extensiondb/internal/printer"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/spf13/cobra"
+)
+
+type identicalRebuildResult struct {
+	Package     string `json:"package"`
+	Version     string `json:"version"`
+	Release     string `json:"release,omitempty"`
+	Digest      string `json:"digest"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+func newQueryRebuildsCmd(output *string) *cobra.Command {
+	var packageNames []string
+	cmd := &cobra.Command{
+		Use:   "rebuilds",
+		Short: "List the bundles that are identical rebuilds of each other",
+		Long: `List the bundles that share their content fingerprint with another bundle of
+the same package and version, so that they are rebuilds that change nothing
+that they install.
+
+The fingerprint of each bundle is computed when it is ingested from its
+ClusterServiceVersion and other manifests, such as CustomResourceDefinitions,
+without the fields that rebuilds change: creation timestamps, labels, the
+createdAt annotation, status, and the name of the ClusterServiceVersion.
+Bundles are listed by package, version, and fingerprint, so that each set of
+identical rebuilds is listed together. Bundles ingested before fingerprints
+were computed are never listed.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			pdb, err := openDB()
+			if err != nil {
+				return err
+			}
+			rebuilds, err := query.New(pdb.DB).ListIdenticalRebuilds(cmd.Context(), packageNames)
+			if err != nil {
+				return err
+			}
+			results := make([]identicalRebuildResult, 0, len(rebuilds))
+			for _, r := range rebuilds {
+				results = append(results, identicalRebuildResult{
+					Package:     r.Package,
+					Version:     r.Version,
+					Release:     r.Release.String,
+					Digest:      r.Digest,
+					Fingerprint: r.Fingerprint,
+				})
+			}
+			return printer.Print(cmd.OutOrStdout(), *output, results, []printer.Column[identicalRebuildResult]{
+				{Header: "package", Value: func(r identicalRebuildResult) string { return r.Package }},
+				{Header: "version", Value: func(r identicalRebuildResult) string { return r.Version }},
+				{Header: "release", Value: func(r identicalRebuildResult) string { return r.Release }},
+				{Header: "fingerprint", Value: func(r identicalRebuildResult) string { return r.Fingerprint }},
+				{Header: "digest", Value: func(r identicalRebuildResult) string { return r.Digest }},
+			})
+		},
+	}
+	cmd.Flags().StringSliceVar(&packageNames, "package", nil, "only list the bundles of these packages")
+	return cmd
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/joelanford/extensiondb/examples/cincinnati/pkg/viz"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/spf13/cobra"
+)
+
+// renderFormats are the formats the render command writes, named by the
+// extensions of the files they are written to.
+var renderFormats = []string{"mmd", "dot", "html", "json"}
+
+func newRenderCmd() *cobra.Command {
+	var (
+		src           graphSource
+		outputDir     string
+		formats       []string
+		asOf          string
+		shortestPaths bool
+		nodeText      string
+		certified     bool
+	)
+	cmd := &cobra.Command{
+		Use:   "render [<package>...]",
+		Short: "Render the update graphs of packages to files in several formats",
+		Long: `Build the update graphs of the named packages, or of every package with a
+template, once, and write each package's graph in each --format to
+<output-dir>/<package>/<package>.<format>:
+
+  mmd   a Mermaid diagram
+  dot   a Graphviz DOT digraph
+  html  a page that draws the Mermaid diagram in a browser
+  json  a Cincinnati graph document`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, f := range formats {
+				if !slices.Contains(renderFormats, f) {
+					return fmt.Errorf("unknown format %q: expected one of %v", f, renderFormats)
+				}
+			}
+			text, err := nodeTextFlag(nodeText)
+			if err != nil {
+				return err
+			}
+			t, err := parseTimeFlag("as-of", asOf, time.Now())
+			if err != nil {
+				return err
+			}
+			g, templates, err := src.build(cmd.Context(), t, args...)
+			if err != nil {
+				return err
+			}
+			packageNames := args
+			if len(packageNames) == 0 {
+				for _, tmpl := range templates {
+					packageNames = append(packageNames, tmpl.Name)
+				}
+			}
+
+			keep, keepEdge := graph.AllNodes(), graph.AllEdges()
+			if certified {
+				keep = graph.CertifiedNodes()
+			}
+			if shortestPaths {
+				keepEdge = viz.ShortestPathEdges()
+			}
+			mermaidConfig := viz.MermaidConfig{KeepNode: keep, KeepEdge: keepEdge, NodeText: text, Summary: true}
+			for _, pkgName := range packageNames {
+				dir := filepath.Join(outputDir, pkgName)
+				if err := os.MkdirAll(dir, 0o755); err != nil {
+					return err
+				}
+				for _, f := range formats {
+					path := filepath.Join(dir, pkgName+"."+f)
+					if err := writeOutput(path, func(w io.Writer) error {
+						switch f {
+						case "mmd":
+							_, err := io.WriteString(w, viz.Mermaid(g, pkgName, mermaidConfig))
+							return err
+						case "dot":
+							_, err := io.WriteString(w, viz.Dot(g, pkgName, viz.DotConfig{KeepNode: keep, KeepEdge: keepEdge, Summary: true}))
+							return err
+						case "html":
+							_, err := io.WriteString(w, viz.HTML(g, pkgName, mermaidConfig))
+							return err
+						default:
+							enc := json.NewEncoder(w)
+							enc.SetIndent("", "  ")
+							return enc.Encode(g.Cincinnati(graph.AndNodes(graph.PackageNodes(pkgName), keep), ""))
+						}
+					}); err != nil {
+						return fmt.Errorf("failed to write %s: %w", path, err)
+					}
+					fmt.Fprintf(cmd.OutOrStdout(), "Wrote %s\n", path)
+				}
+			}
+			return nil
+		},
+	}
+	src.addFlags(cmd)
+	cmd.Flags().StringVar(&outputDir, "output-dir", "render", "directory to write a subdirectory of files for each package to")
+	cmd.Flags().StringSliceVar(&formats, "format", []string{"mmd"}, fmt.Sprintf("formats to write (repeatable): %v", renderFormats))
+	cmd.Flags().StringVar(&asOf, "as-of", "", "build the graphs as of this date (default: now)")
+	cmd.Flags().BoolVar(&shortestPaths, "shortest-paths", false, "only draw the edges on a shortest path to a head, in mmd, dot, and html output")
+	cmd.Flags().StringVar(&nodeText, "node-text", "version", "what to label the nodes of mmd and html output with: "+nodeTexts)
+	cmd.Flags().BoolVar(&certified, "certified", false, "only render versions that are certified in the Red Hat Ecosystem Catalog")
+	return cmd
+}
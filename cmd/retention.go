@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/internal/retention"
+	"github.com/spf13/cobra"
+)
+
+// retentionFlags configure how long the rows of the audit tables are kept.
+type retentionFlags struct {
+	ingestionRunDays int
+	notificationDays int
+}
+
+func (f *retentionFlags) addFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&f.ingestionRunDays, "ingestion-run-retention-days", 0, "delete ingestion runs that started this many days ago, except the latest finished run, or 0 to keep them")
+	cmd.Flags().IntVar(&f.notificationDays, "notification-retention-days", 0, "delete the records of lifecycle notifications sent this many days ago, or 0 to keep them")
+}
+
+func (f *retentionFlags) enabled() bool {
+	return f.ingestionRunDays > 0 || f.notificationDays > 0
+}
+
+func (f *retentionFlags) policy() (retention.Policy, error) {
+	if f.ingestionRunDays < 0 {
+		return retention.Policy{}, fmt.Errorf("--ingestion-run-retention-days must not be negative, got %d", f.ingestionRunDays)
+	}
+	if f.notificationDays < 0 {
+		return retention.Policy{}, fmt.Errorf("--notification-retention-days must not be negative, got %d", f.notificationDays)
+	}
+	return retention.Policy{
+		IngestionRuns: time.Duration(f.ingestionRunDays) * 24 * time.Hour,
+		Notifications: time.Duration(f.notificationDays) * 24 * time.Hour,
+	}, nil
+}
+
+func newPruneCmd() *cobra.Command {
+	var (
+		flags    retentionFlags
+		interval time.Duration
+	)
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete ingestion runs and notification records older than their retention period",
+		Long: `Delete the rows of the audit tables that are older than their retention
+period: ingestion runs, with the graphs cached by them, and the records of
+sent lifecycle notifications.
+
+Each table is only pruned if its retention period is set. The latest finished
+ingestion run is always kept. Notifications whose events are still detected
+once their records are deleted are sent again by the notify command, so
+--notification-retention-days should be longer than its --max-age.
+
+Rows are deleted oldest first, in batches, so that pruning a large backlog
+doesn't hold locks for long. By default, the tables are pruned once. With
+--interval, they are pruned on that schedule; errors are logged and the
+tables are pruned again at the next tick. The serve command can also prune
+the tables, and reports the rows reclaimed at /metrics.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if !flags.enabled() {
+				return errors.New("--ingestion-run-retention-days or --notification-retention-days is required")
+			}
+			policy, err := flags.policy()
+			if err != nil {
+				return err
+			}
+			pdb, err := openDB()
+			if err != nil {
+				return err
+			}
+			if err := pdb.RunMigrations("migrations"); err != nil {
+				return fmt.Errorf("failed to run migrations: %w", err)
+			}
+			pruner := retention.NewPruner(query.New(pdb.DB), policy)
+
+			if interval <= 0 {
+				r, err := pruner.Prune(cmd.Context(), time.Now())
+				log.Printf("pruned audit tables: %d ingestion runs, %d lifecycle notifications", r.IngestionRuns, r.LifecycleNotifications)
+				return err
+			}
+			pruner.Run(cmd.Context(), interval)
+			return nil
+		},
+	}
+	flags.addFlags(cmd)
+	cmd.Flags().DurationVar(&interval, "interval", 0, "prune the tables on this interval instead of once")
+	return cmd
+}
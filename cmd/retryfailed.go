@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/ingest"
+	"github.com/joelanford/extensiondb/internal/models"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/internal/registry"
+	"github.com/spf13/cobra"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+func newRetryFailedCmd() *cobra.Command {
+	var (
+		pluginFlags     ingestPluginFlags
+		logs            logFlags
+		blobs           blobStoreFlags
+		filter          query.FetchFailureFilter
+		olderThan       time.Duration
+		concurrency     int
+		hostConcurrency map[string]int
+		rateLimits      rateLimitFlags
+	)
+	cmd := &cobra.Command{
+		Use:   "retry-failed",
+		Short: "Retry the bundle images that ingestion failed to fetch",
+		Long: `Retry the bundle images recorded in the fetch_failures table.
+
+Ingestion retries transient registry errors, such as timeouts and 5xx
+responses, with exponential backoff. Images that still fail, or fail with
+an error that is not transient, such as a missing image, are recorded in the
+fetch_failures table with the error and the number of attempts, and are
+skipped. This command fetches them again, least recently attempted first,
+and stores the ones that succeed as the ingest command does, removing them
+from the table. Images that fail again stay in the table, with the new
+error and attempts.
+
+Retried images are recorded with the source type and source of the
+ingestion that failed to fetch them.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			plugins, err := pluginFlags.plugins()
+			if err != nil {
+				return err
+			}
+			logger, err := logs.logger(cmd.OutOrStdout())
+			if err != nil {
+				return err
+			}
+			if concurrency < 1 {
+				return fmt.Errorf("--concurrency must be at least 1, got %d", concurrency)
+			}
+			for host, n := range hostConcurrency {
+				if n < 1 {
+					return fmt.Errorf("--host-concurrency for %s must be at least 1, got %d", host, n)
+				}
+			}
+			registryLimits, err := rateLimits.limits(cmd, registry.RateLimits{})
+			if err != nil {
+				return err
+			}
+			reg := registry.Client{RateLimiter: registry.NewRateLimiter(registryLimits)}
+			if olderThan < 0 {
+				return fmt.Errorf("--older-than must not be negative, got %s", olderThan)
+			}
+			if olderThan > 0 {
+				filter.AttemptedBefore = time.Now().Add(-olderThan)
+			}
+
+			pdb, err := openDB()
+			if err != nil {
+				return err
+			}
+			if err := pdb.RunMigrations("migrations"); err != nil {
+				return fmt.Errorf("failed to run migrations: %w", err)
+			}
+			q, err := blobs.query(pdb)
+			if err != nil {
+				return err
+			}
+
+			failures, err := q.ListFetchFailures(cmd.Context(), filter)
+			if err != nil {
+				return fmt.Errorf("failed to list fetch failures: %w", err)
+			}
+			if len(failures) == 0 {
+				logger.Info("no fetch failures to retry")
+				return nil
+			}
+
+			run, err := q.CreateIngestionRun(cmd.Context())
+			if err != nil {
+				return fmt.Errorf("failed to create ingestion run: %w", err)
+			}
+			limits := ingest.HostLimits{Default: concurrency, Hosts: hostConcurrency}
+			fetched, failed, retryErr := retryFetchFailures(cmd.Context(), logger, q, run, failures, reg, limits, plugins)
+
+			// Record the outcome even if the retries were interrupted.
+			if err := q.FinishIngestionRun(context.WithoutCancel(cmd.Context()), run, retryErr); err != nil {
+				return errors.Join(retryErr, err)
+			}
+			if retryErr != nil {
+				return retryErr
+			}
+			logger.Info("retried fetch failures", "fetched", fetched, "failed", failed)
+			return nil
+		},
+	}
+	pluginFlags.addFlags(cmd)
+	logs.addFlags(cmd)
+	blobs.addFlags(cmd)
+	cmd.Flags().StringVar(&filter.SourceType, "source-type", "", "only retry the images of ingestions of this source type, such as catalog or webhook")
+	cmd.Flags().StringVar(&filter.SourceID, "source", "", "only retry the images of ingestions of this source, such as redhat-operator-index:v4.19")
+	cmd.Flags().DurationVar(&olderThan, "older-than", 0, "only retry the images last attempted at least this long ago")
+	cmd.Flags().IntVar(&filter.Limit, "limit", 0, "maximum number of images to retry, or 0 for all")
+	cmd.Flags().IntVar(&concurrency, "concurrency", 32, "maximum number of images to fetch at once from each registry host")
+	cmd.Flags().StringToIntVar(&hostConcurrency, "host-concurrency", nil, "override --concurrency for registry hosts, as <host>=<n>")
+	rateLimits.addFlags(cmd)
+	return cmd
+}
+
+// retryFetchFailures ingests the images of fetch failures with reg, removing
+// the failures of those that are stored, and returns the numbers of images
+// that were fetched and that failed again. Failures whose reference is not a
+// canonical reference are logged and removed, because they can never be
+// fetched.
+func retryFetchFailures(ctx context.Context, logger *slog.Logger, q *query.Query, run *models.IngestionRun, failures []models.FetchFailure, reg registry.Client, limits ingest.HostLimits, plugins []ingest.Plugin) (int, int, error) {
+	var (
+		origins = make(map[string]ingest.Origin, len(failures))
+		refs    = make([]reference.Canonical, 0, len(failures))
+	)
+	for _, ff := range failures {
+		named, err := reference.ParseNamed(ff.Reference)
+		ref, ok := named.(reference.Canonical)
+		if err != nil || !ok {
+			logger.Warn("removing fetch failure, which is not a canonical reference", "reference", ff.Reference)
+			if err := q.DeleteFetchFailure(ctx, ff.Reference); err != nil {
+				return 0, 0, fmt.Errorf("error deleting fetch failure: %w", err)
+			}
+			continue
+		}
+		origins[ref.String()] = ingest.Origin{SourceType: ff.SourceType, SourceID: ff.SourceID, IngestionRunID: run.ID}
+		refs = append(refs, ref)
+	}
+
+	var fetched, failed atomic.Int64
+	err := ingest.ForEachByHost(ctx, refs, limits, func(ctx context.Context, ref reference.Canonical) error {
+		br, err := q.GetOrCreateCanonicalBundleReference(ctx, ref)
+		if err != nil {
+			return fmt.Errorf("error creating bundle reference %s: %w", ref, err)
+		}
+		if _, err := ingest.Bundle(ctx, q, ingest.FBC{Registry: reg}, br, ref, origins[ref.String()], plugins...); errors.Is(err, ingest.ErrFetch) {
+			failed.Add(1)
+			logger.Warn("failed to fetch bundle", "reference", ref.String(), "error", err)
+			return nil
+		} else if err != nil {
+			return err
+		}
+		// Bundle only removes the failure when it creates the bundle, and
+		// the bundle may have been stored since by another reference.
+		if err := q.DeleteFetchFailure(ctx, ref.String()); err != nil {
+			return fmt.Errorf("error deleting fetch failure: %w", err)
+		}
+		fetched.Add(1)
+		logger.Info("fetched bundle", "reference", ref.String())
+		return nil
+	})
+	return int(fetched.Load()), int(failed.Load()), err
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/graphdb"
+	"github.com/joelanford/extensiondb/internal/ingest"
+	"github.com/joelanford/extensiondb/internal/jira"
+	"github.com/joelanford/extensiondb/internal/models"
+	"github.com/joelanford/extensiondb/internal/retention"
+	"github.com/joelanford/extensiondb/internal/server"
+	"github.com/spf13/cobra"
+)
+
+type serveOptions struct {
+	addr         string
+	templatesDir string
+	cacheMaxAge  time.Duration
+	freshness    freshnessFlags
+	lifecycle    lifecycleFlags
+	graphCache   graphCacheFlags
+
+	tlsCertFile  string
+	tlsKeyFile   string
+	clientCAFile string
+
+	tokenFile        string
+	oidcIssuerURL    string
+	oidcClientID     string
+	oidcAdminGroups  []string
+	adminCommonNames []string
+	insecureNoAuth   bool
+
+	jiraURL       string
+	jiraUsername  string
+	jiraTokenFile string
+	jiraIssueType string
+
+	enableWebhooks    bool
+	webhookSecretFile string
+	readThrough       bool
+	ingestPlugins     ingestPluginFlags
+	blobs             blobStoreFlags
+
+	retention         retentionFlags
+	retentionInterval time.Duration
+}
+
+func newServeCmd() *cobra.Command {
+	var opts serveOptions
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve update graphs and catalog data over HTTP",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runServe(cmd.Context(), opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.addr, "addr", "127.0.0.1:8080", "address to listen on")
+	cmd.Flags().StringVar(&opts.templatesDir, "templates-dir", "./examples/cincinnati/product-templates", "directory containing olm.cincinnati templates")
+	cmd.Flags().DurationVar(&opts.cacheMaxAge, "cache-max-age", 5*time.Minute, "how long clients and caches may reuse responses without revalidating them")
+
+	cmd.Flags().StringVar(&opts.tlsCertFile, "tls-cert-file", "", "serve TLS using this certificate")
+	cmd.Flags().StringVar(&opts.tlsKeyFile, "tls-key-file", "", "serve TLS using this private key")
+	cmd.Flags().StringVar(&opts.clientCAFile, "client-ca-file", "", "authenticate TLS client certificates signed by these CAs")
+
+	cmd.Flags().StringVar(&opts.tokenFile, "token-file", "", `authenticate bearer tokens listed in this file (one "<token> <reader|admin>" per line)`)
+	cmd.Flags().StringVar(&opts.oidcIssuerURL, "oidc-issuer-url", "", "authenticate OIDC ID tokens from this issuer")
+	cmd.Flags().StringVar(&opts.oidcClientID, "oidc-client-id", "", "audience expected in OIDC ID tokens")
+	cmd.Flags().StringSliceVar(&opts.oidcAdminGroups, "oidc-admin-groups", nil, "OIDC groups granted the admin role")
+	cmd.Flags().StringSliceVar(&opts.adminCommonNames, "client-cert-admin-cns", nil, "client certificate common names granted the admin role")
+	cmd.Flags().BoolVar(&opts.insecureNoAuth, "insecure-no-auth", false, "allow listening on a non-loopback address without authentication, which lets every client use every endpoint, including those that modify data")
+
+	cmd.Flags().StringVar(&opts.jiraURL, "jira-url", "", "file issues for detected problems in this Jira server")
+	cmd.Flags().StringVar(&opts.jiraUsername, "jira-username", "", "Jira username, for servers that authenticate API tokens with basic authentication")
+	cmd.Flags().StringVar(&opts.jiraTokenFile, "jira-token-file", "", "file containing a Jira API token or personal access token")
+	cmd.Flags().StringVar(&opts.jiraIssueType, "jira-issue-type", "Bug", "type of the Jira issues filed for detected problems")
+
+	cmd.Flags().BoolVar(&opts.enableWebhooks, "enable-webhooks", false, "ingest bundles pushed to known repositories, as reported by Quay and Harbor webhooks")
+	cmd.Flags().StringVar(&opts.webhookSecretFile, "webhook-secret-file", "", "file containing the secret that registries present to the webhook endpoints (default: require the admin role)")
+	cmd.Flags().BoolVar(&opts.readThrough, "read-through", false, "ingest bundle images that clients look up at /api/bundles but that are not in the database, if they are in the repository of a known package")
+	opts.ingestPlugins.addFlags(cmd)
+	opts.blobs.addFlags(cmd)
+
+	opts.freshness.addFlags(cmd)
+	opts.lifecycle.addFlags(cmd)
+	opts.graphCache.addFlags(cmd)
+
+	opts.retention.addFlags(cmd)
+	cmd.Flags().DurationVar(&opts.retentionInterval, "retention-interval", 24*time.Hour, "how often to prune the audit tables, if a retention period is set")
+	return cmd
+}
+
+func runServe(ctx context.Context, opts serveOptions) error {
+	pdb, err := openDB()
+	if err != nil {
+		return err
+	}
+
+	templates, err := graphdb.ReadTemplatesDir(opts.templatesDir)
+	if err != nil {
+		return fmt.Errorf("failed to read templates: %w", err)
+	}
+	templates, err = opts.lifecycle.apply(ctx, templates)
+	if err != nil {
+		return err
+	}
+	thresholds, err := opts.freshness.thresholds()
+	if err != nil {
+		return err
+	}
+	policy, err := opts.retention.policy()
+	if err != nil {
+		return err
+	}
+	if opts.retention.enabled() && opts.retentionInterval <= 0 {
+		return fmt.Errorf("--retention-interval must be positive, got %s", opts.retentionInterval)
+	}
+
+	auth, err := newAuthenticator(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if auth == nil {
+		if !opts.insecureNoAuth && !loopbackAddr(opts.addr) {
+			return fmt.Errorf("refusing to serve on %s without authentication: configure an authentication method, listen on a loopback address, or pass --insecure-no-auth", opts.addr)
+		}
+		log.Printf("WARNING: no authentication configured; every client can access every endpoint")
+	}
+
+	var jiraClient *jira.Client
+	if opts.jiraURL != "" {
+		token := ""
+		if opts.jiraTokenFile != "" {
+			token, err = readTokenFile(opts.jiraTokenFile)
+			if err != nil {
+				return err
+			}
+		}
+		jiraClient, err = jira.NewClient(opts.jiraURL, opts.jiraUsername, token, nil)
+		if err != nil {
+			return err
+		}
+		jiraClient.IssueType = opts.jiraIssueType
+	}
+
+	if err := pdb.RunMigrations("migrations"); err != nil {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+	q, err := opts.blobs.query(pdb)
+	if err != nil {
+		return err
+	}
+	if err := graphdb.ImportVersionStreams(ctx, q, templates); err != nil {
+		return err
+	}
+
+	var (
+		queue         *ingest.Queue
+		bundleQueue   server.BundleQueue
+		readThrough   server.BundleQueue
+		webhookSecret string
+	)
+	if opts.enableWebhooks || opts.readThrough {
+		plugins, err := opts.ingestPlugins.plugins()
+		if err != nil {
+			return err
+		}
+		queue = ingest.NewQueue(q, 100, plugins...)
+		go queue.Run(ctx)
+	}
+	if opts.enableWebhooks {
+		if opts.webhookSecretFile != "" {
+			webhookSecret, err = readTokenFile(opts.webhookSecretFile)
+			if err != nil {
+				return err
+			}
+		}
+		bundleQueue = queue
+	}
+	if opts.readThrough {
+		readThrough = queue.Source(models.SourceTypeReadThrough)
+	}
+
+	builder := graphdb.New(pdb.DB)
+	opts.graphCache.apply(builder, q)
+
+	var pruner *retention.Pruner
+	if opts.retention.enabled() {
+		pruner = retention.NewPruner(q, policy)
+		go pruner.Run(ctx, opts.retentionInterval)
+	}
+
+	handler, err := server.New(server.Config{
+		Query:     q,
+		Builder:   builder,
+		Templates: templates,
+		Auth:      auth,
+
+		CacheMaxAge: opts.cacheMaxAge,
+		Jira:        jiraClient,
+
+		BundleQueue:   bundleQueue,
+		WebhookSecret: webhookSecret,
+		ReadThrough:   readThrough,
+
+		FreshnessThresholds: thresholds,
+		Retention:           pruner,
+	})
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Addr:              opts.addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if opts.clientCAFile != "" {
+		caBytes, err := os.ReadFile(opts.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBytes) {
+			return fmt.Errorf("no certificates found in client CA file %s", opts.clientCAFile)
+		}
+		srv.TLSConfig = &tls.Config{
+			ClientCAs:  pool,
+			ClientAuth: tls.VerifyClientCertIfGiven,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("error shutting down server: %v", err)
+		}
+	}()
+
+	log.Printf("Serving on %s", opts.addr)
+	if opts.tlsCertFile != "" || opts.tlsKeyFile != "" {
+		err = srv.ListenAndServeTLS(opts.tlsCertFile, opts.tlsKeyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// loopbackAddr reports whether addr, a listen address, only accepts
+// connections from this host.
+func loopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func newAuthenticator(ctx context.Context, opts serveOptions) (server.Authenticator, error) {
+	var auths server.Authenticators
+	if opts.clientCAFile != "" {
+		if opts.tlsCertFile == "" {
+			return nil, errors.New("--client-ca-file requires --tls-cert-file and --tls-key-file")
+		}
+		auths = append(auths, server.ClientCertAuthenticator{AdminCommonNames: opts.adminCommonNames})
+	}
+	if opts.tokenFile != "" {
+		ta, err := server.NewTokenAuthenticatorFromFile(opts.tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read token file: %w", err)
+		}
+		auths = append(auths, ta)
+	}
+	if opts.oidcIssuerURL != "" {
+		oa, err := server.NewOIDCAuthenticator(ctx, opts.oidcIssuerURL, opts.oidcClientID, opts.oidcAdminGroups)
+		if err != nil {
+			return nil, err
+		}
+		auths = append(auths, oa)
+	}
+	if len(auths) == 0 {
+		return nil, nil
+	}
+	return auths, nil
+}
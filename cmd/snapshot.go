@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/fbc"
+	"github.com/joelanford/extensiondb/internal/graphdb"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/internal/snapshot"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/spf13/cobra"
+)
+
+func newSnapshotCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Manage SQLite snapshots for offline use",
+	}
+	cmd.AddCommand(newSnapshotCreateCmd())
+	return cmd
+}
+
+func newSnapshotCreateCmd() *cobra.Command {
+	var (
+		templatesDir string
+		output       string
+	)
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Write the templates and their bundles to a SQLite snapshot",
+		Long: `Write the olm.cincinnati templates, and the bundles of their images, to a
+SQLite snapshot.
+
+The graph, viz, and plan commands read the snapshot with --snapshot, without
+access to the database or the templates directory. To build a binary that
+carries the snapshot with it, write the snapshot to cmd/snapshot.sqlite and
+build with -tags embedsnapshot:
+
+  extensiondb snapshot create -o cmd/snapshot.sqlite
+  go build -tags embedsnapshot -o extensiondb ./cmd`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			templates, err := graphdb.ReadTemplatesDir(templatesDir)
+			if err != nil {
+				return fmt.Errorf("failed to read templates: %w", err)
+			}
+			pdb, err := openDB()
+			if err != nil {
+				return err
+			}
+			return snapshot.Create(cmd.Context(), graphdb.New(pdb.DB), templates, output, time.Now())
+		},
+	}
+	cmd.Flags().StringVar(&templatesDir, "templates-dir", "./examples/cincinnati/product-templates", "directory containing olm.cincinnati templates")
+	cmd.Flags().StringVarP(&output, "output", "o", "extensiondb.sqlite", "file to write the snapshot to")
+	return cmd
+}
+
+// graphSource builds graphs from a rendered file-based catalog and a
+// templates directory, from a snapshot file, from the snapshot embedded in the
+// binary, or from the database and a templates directory, in that order of
+// preference.
+type graphSource struct {
+	fbcPath      string
+	snapshotPath string
+	templatesDir string
+	inferStreams bool
+	skipInvalid  bool
+	collapse     bool
+	cache        graphCacheFlags
+}
+
+func (s *graphSource) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&s.fbcPath, "fbc", "", "build graphs from this rendered file-based catalog, such as a directory of a ClusterCatalog's contents or a file of opm render output, and --templates-dir instead of the database")
+	cmd.Flags().StringVar(&s.snapshotPath, "snapshot", "", "build graphs from this snapshot instead of the database (default: the embedded snapshot, if any)")
+	cmd.Flags().StringVar(&s.templatesDir, "templates-dir", "./examples/cincinnati/product-templates", "directory containing olm.cincinnati templates, when building graphs from the database or --fbc")
+	cmd.Flags().BoolVar(&s.skipInvalid, "skip-invalid-nodes", false, "leave out bundles that can't be placed in the graph, such as those whose major.minor is in no version stream, and log them, when building graphs from the database or --fbc")
+	cmd.Flags().BoolVar(&s.collapse, "collapse-rebuilds", false, "make a single node of each version that was built more than once, from its newest build, when building graphs from the database")
+	cmd.Flags().BoolVar(&s.inferStreams, "infer-streams", false, "infer the version streams of packages without a template from their bundles, when building graphs from the database or --fbc")
+	cmd.MarkFlagsMutuallyExclusive("fbc", "snapshot")
+	s.cache.addFlags(cmd)
+}
+
+// usesDatabase reports whether graphs are built from the database rather than
+// a rendered catalog or a snapshot.
+func (s *graphSource) usesDatabase() bool {
+	return s.fbcPath == "" && s.snapshotPath == "" && len(embeddedSnapshot) == 0
+}
+
+// build builds a graph of the named packages, or of every package if none
+// are named, and returns it with the packages' templates.
+func (s *graphSource) build(ctx context.Context, asOf time.Time, packageNames ...string) (*graph.Graph, []graph.Template, error) {
+	if s.fbcPath != "" {
+		return s.buildFBC(ctx, asOf, packageNames...)
+	}
+
+	var (
+		snap *snapshot.Snapshot
+		err  error
+	)
+	switch {
+	case s.snapshotPath != "":
+		snap, err = snapshot.Open(ctx, s.snapshotPath)
+	case len(embeddedSnapshot) > 0:
+		snap, err = snapshot.OpenBytes(ctx, embeddedSnapshot)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if snap != nil {
+		defer snap.Close()
+		g, err := snap.Build(ctx, asOf, packageNames...)
+		if err != nil {
+			return nil, nil, err
+		}
+		return g, filterTemplates(snap.Templates(), packageNames), nil
+	}
+
+	templates, err := graphdb.ReadTemplatesDir(s.templatesDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read templates: %w", err)
+	}
+	templates = filterTemplates(templates, packageNames)
+	pdb, err := openDB()
+	if err != nil {
+		return nil, nil, err
+	}
+	builder := graphdb.New(pdb.DB)
+	builder.SkipInvalidNodes = s.skipInvalid
+	builder.CollapseRebuilds = s.collapse
+	s.cache.apply(builder, query.New(pdb.DB))
+	if s.inferStreams {
+		for _, name := range packageNames {
+			if slices.ContainsFunc(templates, func(t graph.Template) bool { return t.Name == name }) {
+				continue
+			}
+			tmpl, err := builder.InferTemplate(ctx, name)
+			if err != nil {
+				return nil, nil, err
+			}
+			templates = append(templates, tmpl)
+		}
+	}
+	if len(templates) == 0 {
+		return nil, nil, fmt.Errorf("no templates found for packages %v", packageNames)
+	}
+	g, err := builder.Build(ctx, templates, asOf)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, skipped := range g.SkippedNodes() {
+		log.Printf("skipped %s: %v", skipped.Node.NVR(), skipped.Err)
+	}
+	return g, templates, nil
+}
+
+// buildFBC builds a graph from the rendered catalog at s.fbcPath and the
+// templates in s.templatesDir. Rebuilds are always collapsed.
+func (s *graphSource) buildFBC(ctx context.Context, asOf time.Time, packageNames ...string) (*graph.Graph, []graph.Template, error) {
+	cfg, err := fbc.Load(ctx, s.fbcPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load catalog: %w", err)
+	}
+	templates, err := graphdb.ReadTemplatesDir(s.templatesDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read templates: %w", err)
+	}
+	g, templates, err := fbc.BuildGraph(ctx, cfg, templates, fbc.GraphOptions{
+		AsOf:             asOf,
+		InferStreams:     s.inferStreams,
+		SkipInvalidNodes: s.skipInvalid,
+	}, packageNames...)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, skipped := range g.SkippedNodes() {
+		log.Printf("skipped %s: %v", skipped.Node.NVR(), skipped.Err)
+	}
+	return g, templates, nil
+}
+
+func filterTemplates(templates []graph.Template, packageNames []string) []graph.Template {
+	if len(packageNames) == 0 {
+		return templates
+	}
+	var filtered []graph.Template
+	for _, tmpl := range templates {
+		if slices.Contains(packageNames, tmpl.Name) {
+			filtered = append(filtered, tmpl)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,11 @@
+//go:build embedsnapshot
+
+package main
+
+import _ "embed"
+
+// embeddedSnapshot is the snapshot at cmd/snapshot.sqlite, embedded by
+// building with -tags embedsnapshot.
+//
+//go:embed snapshot.sqlite
+var embeddedSnapshot []byte
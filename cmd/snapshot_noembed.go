@@ -0,0 +1,7 @@
+//go:build !embedsnapshot
+
+package main
+
+// embeddedSnapshot is empty unless the binary is built with -tags
+// embedsnapshot.
+var embeddedSnapshot []byte
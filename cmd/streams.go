@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/joelanford/extensiondb/internal/graphdb"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/spf13/cobra"
+)
+
+func newImportStreamsCmd() *cobra.Command {
+	var templatesDir string
+	cmd := &cobra.Command{
+		Use:   "import-streams",
+		Short: "Store the version streams of olm.cincinnati templates in the database",
+		Long: `Store the version streams of olm.cincinnati templates, and their lifecycle
+dates, in the database, so that lifecycle phases can be computed without the
+templates, such as with "query lifecycle --from-db".
+
+The streams of each template's package replace those stored before. The
+streams of packages without a template are kept. "serve" imports its
+templates when it starts.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			templates, err := graphdb.ReadTemplatesDir(templatesDir)
+			if err != nil {
+				return fmt.Errorf("failed to read templates: %w", err)
+			}
+			pdb, err := openDB()
+			if err != nil {
+				return err
+			}
+			if err := pdb.RunMigrations("migrations"); err != nil {
+				return fmt.Errorf("failed to run migrations: %w", err)
+			}
+			if err := graphdb.ImportVersionStreams(cmd.Context(), query.New(pdb.DB), templates); err != nil {
+				return err
+			}
+			log.Printf("imported the version streams of %d packages", len(templates))
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&templatesDir, "templates-dir", "./examples/cincinnati/product-templates", "directory containing olm.cincinnati templates")
+	return cmd
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/graphdb"
+	"github.com/joelanford/extensiondb/internal/printer"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/internal/trends"
+	"github.com/spf13/cobra"
+)
+
+// trendsOptions are the flags shared by the trends subcommands.
+type trendsOptions struct {
+	from, to string
+	output   string
+}
+
+// window returns the range of the report. to defaults to tomorrow, so that
+// today is included, and from to a year before to.
+func (o trendsOptions) window() (time.Time, time.Time, error) {
+	to, err := parseTimeFlag("to", o.to, time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	from, err := parseTimeFlag("from", o.from, to.AddDate(-1, 0, 0))
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("--from must be before --to")
+	}
+	return from, to, nil
+}
+
+// analyze computes the release trends from the database.
+func (o trendsOptions) analyze(cmd *cobra.Command) (*trends.Report, error) {
+	from, to, err := o.window()
+	if err != nil {
+		return nil, err
+	}
+	pdb, err := openDB()
+	if err != nil {
+		return nil, err
+	}
+	timelines, err := query.New(pdb.DB).ListBundleTimelines(cmd.Context())
+	if err != nil {
+		return nil, err
+	}
+	return trends.Analyze(timelines, from, to), nil
+}
+
+func newTrendsCmd() *cobra.Command {
+	var opts trendsOptions
+	cmd := &cobra.Command{
+		Use:   "trends",
+		Short: "Report how package releases and update graphs change over time",
+		Long: `Report how package releases and update graphs change over time, from the
+build times of bundles and the times catalogs were ingested. Bundles are
+counted in the month in which they were built.`,
+	}
+	cmd.PersistentFlags().StringVar(&opts.from, "from", "", "first date of the report (default: a year before --to)")
+	cmd.PersistentFlags().StringVar(&opts.to, "to", "", "date the report ends before (default: tomorrow)")
+	cmd.PersistentFlags().StringVarP(&opts.output, "output", "o", "table", "output format: "+printer.Formats)
+	cmd.AddCommand(
+		newTrendsReleasesCmd(&opts),
+		newTrendsZStreamsCmd(&opts),
+		newTrendsTimeToCatalogCmd(&opts),
+		newTrendsHealthCmd(&opts),
+	)
+	return cmd
+}
+
+func newTrendsReleasesCmd(opts *trendsOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "releases",
+		Short: "Count the bundles built each month per package",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			r, err := opts.analyze(cmd)
+			if err != nil {
+				return err
+			}
+			return printer.Print(cmd.OutOrStdout(), opts.output, r.Releases, []printer.Column[trends.MonthlyReleases]{
+				{Header: "package", Value: func(m trends.MonthlyReleases) string { return m.Package }},
+				{Header: "month", Value: func(m trends.MonthlyReleases) string { return m.Month }},
+				{Header: "bundles", Value: func(m trends.MonthlyReleases) string { return strconv.Itoa(m.Bundles) }},
+			})
+		},
+	}
+}
+
+func newTrendsZStreamsCmd(opts *trendsOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "zstreams",
+		Short: "Report the average days between builds of each major.minor stream",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			r, err := opts.analyze(cmd)
+			if err != nil {
+				return err
+			}
+			return printer.Print(cmd.OutOrStdout(), opts.output, r.ZStreams, []printer.Column[trends.ZStreamCadence]{
+				{Header: "package", Value: func(z trends.ZStreamCadence) string { return z.Package }},
+				{Header: "stream", Value: func(z trends.ZStreamCadence) string { return z.Stream }},
+				{Header: "releases", Value: func(z trends.ZStreamCadence) string { return strconv.Itoa(z.Releases) }},
+				{Header: "average days", Value: func(z trends.ZStreamCadence) string { return formatDays(z.AverageDays) }},
+			})
+		},
+	}
+}
+
+func newTrendsTimeToCatalogCmd(opts *trendsOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "time-to-catalog",
+		Short: "Report the days from build to first catalog appearance of each month's bundles",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			r, err := opts.analyze(cmd)
+			if err != nil {
+				return err
+			}
+			return printer.Print(cmd.OutOrStdout(), opts.output, r.TimeToCatalog, []printer.Column[trends.MonthlyTimeToCatalog]{
+				{Header: "package", Value: func(m trends.MonthlyTimeToCatalog) string { return m.Package }},
+				{Header: "month", Value: func(m trends.MonthlyTimeToCatalog) string { return m.Month }},
+				{Header: "bundles", Value: func(m trends.MonthlyTimeToCatalog) string { return strconv.Itoa(m.Bundles) }},
+				{Header: "average days", Value: func(m trends.MonthlyTimeToCatalog) string { return formatDays(m.AverageDays) }},
+				{Header: "max days", Value: func(m trends.MonthlyTimeToCatalog) string { return formatDays(m.MaxDays) }},
+			})
+		},
+	}
+}
+
+func newTrendsHealthCmd(opts *trendsOptions) *cobra.Command {
+	var templatesDir string
+	cmd := &cobra.Command{
+		Use:   "health",
+		Short: "Report the heads and dead ends of each package's update graph at the end of each month",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			from, to, err := opts.window()
+			if err != nil {
+				return err
+			}
+			templates, err := graphdb.ReadTemplatesDir(templatesDir)
+			if err != nil {
+				return fmt.Errorf("failed to read templates: %w", err)
+			}
+			pdb, err := openDB()
+			if err != nil {
+				return err
+			}
+			r, err := trends.Compute(cmd.Context(), query.New(pdb.DB), graphdb.New(pdb.DB), templates, from, to)
+			if err != nil {
+				return err
+			}
+			return printer.Print(cmd.OutOrStdout(), opts.output, r.Health, []printer.Column[trends.MonthlyHealth]{
+				{Header: "package", Value: func(m trends.MonthlyHealth) string { return m.Package }},
+				{Header: "month", Value: func(m trends.MonthlyHealth) string { return m.Month }},
+				{Header: "heads", Value: func(m trends.MonthlyHealth) string { return strconv.Itoa(m.Heads) }},
+				{Header: "dead ends", Value: func(m trends.MonthlyHealth) string { return strconv.Itoa(m.DeadEnds) }},
+			})
+		},
+	}
+	cmd.Flags().StringVar(&templatesDir, "templates-dir", "./examples/cincinnati/product-templates", "directory containing olm.cincinnati templates")
+	return cmd
+}
+
+func formatDays(days float64) string {
+	return strconv.FormatFloat(days, 'f', 1, 64)
+}
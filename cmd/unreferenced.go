@@ -0,0 +1,115 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/printer"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/spf13/cobra"
+)
+
+type unreferencedResult struct {
+	Repository    string     `json:"repository"`
+	Digest        string     `json:"digest"`
+	Package       string     `json:"package,omitempty"`
+	Version       string     `json:"version"`
+	BuiltAt       *time.Time `json:"builtAt,omitempty"`
+	LastShippedAt *time.Time `json:"lastShippedAt,omitempty"`
+}
+
+type unreferencedRepositoryResult struct {
+	Repository  string     `json:"repository"`
+	Images      int        `json:"images"`
+	OldestBuilt *time.Time `json:"oldestBuilt,omitempty"`
+	NewestBuilt *time.Time `json:"newestBuilt,omitempty"`
+}
+
+func newQueryUnreferencedCmd(output *string) *cobra.Command {
+	var (
+		olderThan    time.Duration
+		byRepository bool
+	)
+	cmd := &cobra.Command{
+		Use:   "unreferenced",
+		Short: "List the bundle images that no catalog ships, as candidates for registry cleanup",
+		Long: `List the bundle images that are in the latest ingested contents of no
+catalog, grouped by repository, so that registry owners can plan image
+cleanup.
+
+Only images built more than --older-than ago, and that no catalog has
+shipped within that time, are listed, so that images that were just built or
+just dropped from a catalog are kept. An image's digest shipping from any
+repository, such as a mirror, keeps it in every repository.
+
+The report only covers ingested catalogs: images may still be referenced by
+catalogs that are not ingested, or installed on clusters.
+
+With --by-repository, only the number of images in each repository and
+their oldest and newest build times are listed.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			pdb, err := openDB()
+			if err != nil {
+				return err
+			}
+			images, err := query.New(pdb.DB).ListUnreferencedImages(cmd.Context(), time.Now().Add(-olderThan))
+			if err != nil {
+				return err
+			}
+
+			results := make([]unreferencedResult, 0, len(images))
+			for _, ui := range images {
+				r := unreferencedResult{Repository: ui.Repo, Digest: ui.Digest, Package: ui.PackageName.String, Version: ui.Version}
+				if ui.BuiltAt.Valid {
+					r.BuiltAt = &ui.BuiltAt.Time
+				}
+				if ui.LastShippedAt.Valid {
+					r.LastShippedAt = &ui.LastShippedAt.Time
+				}
+				results = append(results, r)
+			}
+
+			if byRepository {
+				repos := unreferencedRepositories(results)
+				return printer.Print(cmd.OutOrStdout(), *output, repos, []printer.Column[unreferencedRepositoryResult]{
+					{Header: "repository", Value: func(r unreferencedRepositoryResult) string { return r.Repository }},
+					{Header: "images", Value: func(r unreferencedRepositoryResult) string { return strconv.Itoa(r.Images) }},
+					{Header: "oldest built", Value: func(r unreferencedRepositoryResult) string { return formatTime(r.OldestBuilt) }},
+					{Header: "newest built", Value: func(r unreferencedRepositoryResult) string { return formatTime(r.NewestBuilt) }},
+				})
+			}
+			return printer.Print(cmd.OutOrStdout(), *output, results, []printer.Column[unreferencedResult]{
+				{Header: "repository", Value: func(r unreferencedResult) string { return r.Repository }},
+				{Header: "digest", Value: func(r unreferencedResult) string { return r.Digest }},
+				{Header: "package", Value: func(r unreferencedResult) string { return r.Package }},
+				{Header: "version", Value: func(r unreferencedResult) string { return r.Version }},
+				{Header: "built", Value: func(r unreferencedResult) string { return formatTime(r.BuiltAt) }},
+				{Header: "last shipped", Value: func(r unreferencedResult) string { return formatTime(r.LastShippedAt) }},
+			})
+		},
+	}
+	cmd.Flags().DurationVar(&olderThan, "older-than", 90*24*time.Hour, "only list images built, and last shipped in a catalog, longer ago than this")
+	cmd.Flags().BoolVar(&byRepository, "by-repository", false, "only list the number of images in each repository")
+	return cmd
+}
+
+// unreferencedRepositories summarizes images, which are ordered by repository
+// and then by build time, per repository.
+func unreferencedRepositories(images []unreferencedResult) []unreferencedRepositoryResult {
+	var repos []unreferencedRepositoryResult
+	for _, img := range images {
+		if len(repos) == 0 || repos[len(repos)-1].Repository != img.Repository {
+			repos = append(repos, unreferencedRepositoryResult{Repository: img.Repository})
+		}
+		r := &repos[len(repos)-1]
+		r.Images++
+		if img.BuiltAt != nil {
+			if r.OldestBuilt == nil {
+				r.OldestBuilt = img.BuiltAt
+			}
+			r.NewestBuilt = img.BuiltAt
+		}
+	}
+	return repos
+}
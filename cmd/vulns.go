@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/internal/vulns"
+	"github.com/spf13/cobra"
+)
+
+func newVulnsCmd() *cobra.Command {
+	var (
+		feeds    []string
+		interval time.Duration
+	)
+	cmd := &cobra.Command{
+		Use:   "vulns",
+		Short: "Ingest OpenVEX and OSV vulnerability feeds for bundle images and their related images",
+		Long: `Ingest OpenVEX and OSV vulnerability feeds for bundle images and their
+related images.
+
+Each feed is an http(s) URL or a local file containing an OpenVEX document, a
+single OSV entry, a list of OSV entries, or an OSV query response. Products and
+packages are matched to images by digest, so they must be identified by a
+package URL or image reference that includes one. The status of each
+vulnerability is recorded for every bundle whose bundle image or related
+images have that digest.
+
+By default, the feeds are ingested once. With --interval, they are ingested on
+that schedule; errors are logged and the feed is retried at the next tick.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			pdb, err := openDB()
+			if err != nil {
+				return err
+			}
+			q := query.New(pdb.DB)
+
+			ingestFeed := func(ctx context.Context, feed string) error {
+				data, err := vulns.Fetch(ctx, http.DefaultClient, feed)
+				if err != nil {
+					return err
+				}
+				statements, err := vulns.Parse(data)
+				if err != nil {
+					return err
+				}
+				n, err := vulns.Ingest(ctx, q, statements)
+				if err != nil {
+					return err
+				}
+				log.Printf("ingested %s: %d statements, %d bundle statuses", feed, len(statements), n)
+				return nil
+			}
+
+			if interval <= 0 {
+				for _, feed := range feeds {
+					if err := ingestFeed(cmd.Context(), feed); err != nil {
+						return fmt.Errorf("error ingesting %s: %w", feed, err)
+					}
+				}
+				return nil
+			}
+
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				for _, feed := range feeds {
+					if err := ingestFeed(cmd.Context(), feed); err != nil {
+						log.Printf("error ingesting %s: %v", feed, err)
+					}
+				}
+				select {
+				case <-cmd.Context().Done():
+					return nil
+				case <-ticker.C:
+				}
+			}
+		},
+	}
+	cmd.Flags().StringSliceVar(&feeds, "feed", nil, "URL or path of an OpenVEX or OSV feed (repeatable)")
+	cmd.Flags().DurationVar(&interval, "interval", 0, "ingest the feeds on this interval instead of once")
+	_ = cmd.MarkFlagRequired("feed")
+	return cmd
+}
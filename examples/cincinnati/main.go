@@ -5,23 +5,17 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"math"
-	"os"
-	"path/filepath"
 	"slices"
-	"strings"
 	"time"
 
 	_ "crypto/sha256"
 
 	"github.com/blang/semver/v4"
-	"github.com/joelanford/extensiondb/examples/cincinnati/pkg/graph"
-	"github.com/joelanford/extensiondb/examples/cincinnati/pkg/util"
-	"github.com/joelanford/extensiondb/examples/cincinnati/pkg/viz"
 	"github.com/joelanford/extensiondb/internal/db"
-	"go.podman.io/image/v5/docker/reference"
-	ggraph "gonum.org/v1/gonum/graph"
-	"sigs.k8s.io/yaml"
+	"github.com/joelanford/extensiondb/internal/graphdb"
+	"github.com/joelanford/extensiondb/internal/templateloader"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/joelanford/extensiondb/pkg/util"
 )
 
 func main() {
@@ -59,109 +53,26 @@ func main() {
 	printShortestPathsFrom(g, acm)
 	printShortestPathsFrom(g, kubevirt)
 	printUpgradePlans(g)
-
-	if err := writeMermaidFile(g, "./examples/cincinnati", "quay-operator"); err != nil {
-		log.Fatal(err)
-	}
-	if err := writeMermaidFile(g, "./examples/cincinnati", "cluster-logging"); err != nil {
-		log.Fatal(err)
-	}
-	if err := writeMermaidFile(g, "./examples/cincinnati", "advanced-cluster-management"); err != nil {
-		log.Fatal(err)
-	}
-	if err := writeMermaidFile(g, "./examples/cincinnati", "kubevirt-hyperconverged"); err != nil {
-		log.Fatal(err)
-	}
 }
 
 func newGraphFromFile(path string) (*graph.Graph, error) {
-	pdb, err := db.NewDB(db.Config{
-		Host:     "localhost",
-		Port:     5432,
-		User:     "postgres",
-		Password: "postgres",
-		DBName:   "extensiondb",
-		SSLMode:  "disable",
-	})
+	cfg, err := db.ConfigFromEnv()
 	if err != nil {
 		return nil, err
 	}
-
-	entries, err := os.ReadDir(path)
+	pdb, err := db.NewDB(cfg.WithDefaults(db.DefaultConfig()))
 	if err != nil {
 		return nil, err
 	}
-	packages := make([]graph.Package, len(entries))
-	for _, entry := range entries {
-		filename := filepath.Join(path, entry.Name())
-		fileData, err := os.ReadFile(filename)
-		if err != nil {
-			return nil, err
-		}
-		var tmpl graph.Template
-		if err := yaml.Unmarshal(fileData, &tmpl); err != nil {
-			return nil, err
-		}
-		if err := tmpl.Validate(); err != nil {
-			return nil, err
-		}
-
-		nodes, err := queryNodes(context.TODO(), pdb, tmpl.Images)
-		if err != nil {
-			return nil, err
-		}
-		packages = append(packages, graph.Package{
-			Name:    tmpl.Name,
-			Nodes:   nodes,
-			Streams: tmpl.VersionStreams,
-		})
-	}
-
-	return graph.NewGraph(graph.GraphConfig{
-		Packages:     packages,
-		AsOf:         time.Now(),
-		IncludePreGA: false,
-	})
-}
 
-func queryNodes(ctx context.Context, pdb *db.DB, refs []graph.CanonicalReference) ([]*graph.Node, error) {
-	placeholders := make([]string, 0, len(refs))
-	params := make([]any, 0, len(refs)*2)
-	for i, ref := range refs {
-		a := i*2 + 1
-		b := a + 1
-		placeholders = append(placeholders, fmt.Sprintf("($%d,$%d)", a, b))
-		params = append(params, ref.Name(), ref.Digest().String())
-	}
-	refLookup := map[string]reference.Canonical{}
-	for _, ref := range refs {
-		refLookup[ref.String()] = ref
-	}
-
-	query := fmt.Sprintf(`SELECT p.name, b.version, b.release, (br.repo || '@' || br.digest) as reference, (b.image ->> 'created')::timestamp as built_at FROM bundles as b JOIN packages as p ON p.id = b.package_id JOIN bundle_reference_bundles as brb ON brb.bundle_id = b.id JOIN bundle_references as br ON br.id = brb.bundle_reference_id WHERE (br.repo, br.digest) IN (%s) ORDER BY built_at ASC`, strings.Join(placeholders, ","))
-	rows, err := pdb.QueryContext(ctx, query, params...)
+	templates, err := templateloader.Load(context.TODO(), templateloader.Dir(path))
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var nodes []*graph.Node
-	for rows.Next() {
-		var (
-			n   graph.Node
-			ref string
-		)
-		if err := rows.Scan(&n.Name, &n.Version, &n.Release, &ref, &n.ReleaseDate); err != nil {
-			return nil, err
-		}
-		n.ImageReference = refLookup[ref]
-		nodes = append(nodes, &n)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-
-	return nodes, nil
+	// Query the bundles of up to 8 of the product's packages at once.
+	builder := graphdb.New(pdb.DB)
+	builder.Concurrency = 8
+	return builder.Build(context.TODO(), templates, time.Now())
 }
 
 func printDirectPathsFrom(ng *graph.Graph, from *graph.Node) {
@@ -195,9 +106,12 @@ func printShortestPathsFrom(ng *graph.Graph, fromNode *graph.Node) {
 
 	var shortestPaths []shortestPath
 	for to := range ng.NodesMatching(graph.AllNodes()) {
-		p, w, _ := ng.Paths().Between(fromNode.ID(), to.ID())
+		p, w, ok := ng.ShortestPath(fromNode, to)
+		if !ok {
+			continue
+		}
 		shortestPaths = append(shortestPaths, shortestPath{
-			path:   util.MapSlice(p, func(i ggraph.Node) *graph.Node { return i.(*graph.Node) }),
+			path:   p,
 			from:   fromNode,
 			to:     to,
 			weight: w,
@@ -211,9 +125,6 @@ func printShortestPathsFrom(ng *graph.Graph, fromNode *graph.Node) {
 	})
 	fmt.Printf("Shortest path from %s to every descendent:\n", fromNode.NVR())
 	for _, sp := range shortestPaths {
-		if sp.weight == math.Inf(1) {
-			continue
-		}
 		fmt.Printf("  %s (%s) --> %s (%s): %.2f\n", sp.from.VR(), sp.from.LifecyclePhase, sp.to.VR(), sp.to.LifecyclePhase, sp.weight)
 	}
 	fmt.Println()
@@ -248,6 +159,7 @@ func printUpgradePlans(ng *graph.Graph) {
 		},
 	} {
 		up, err := ng.PlanOpenShiftUpdate(
+			context.TODO(),
 			slices.Collect(ng.NodesMatching(cfg.nodes)),
 			cfg.fromPlatform,
 			cfg.toPlatform,
@@ -258,26 +170,3 @@ func printUpgradePlans(ng *graph.Graph) {
 		fmt.Println(up.PrettyReport())
 	}
 }
-
-func writeMermaidFile(ng *graph.Graph, dir string, pkg string) error {
-	nm := viz.Mermaid(ng, pkg, viz.MermaidConfig{KeepEdge: onShortestPathToAnyHead})
-	return os.WriteFile(filepath.Join(dir, fmt.Sprintf("mermaid/%s.mmd", pkg)), []byte(nm), 0644)
-}
-
-func onShortestPathToAnyHead(g *graph.Graph, from *graph.Node, to *graph.Node, w float64) bool {
-	shortestPathTo := map[*graph.Node][]ggraph.Node{}
-	for head := range g.Heads() {
-		sp, spWeight, _ := g.Paths().Between(from.ID(), head.ID())
-		if spWeight == math.Inf(1) {
-			continue
-		}
-		shortestPathTo[head] = sp
-	}
-
-	for _, sp := range shortestPathTo {
-		if len(sp) > 1 && sp[1] == to {
-			return true
-		}
-	}
-	return false
-}
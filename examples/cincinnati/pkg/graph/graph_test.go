@@ -0,0 +1,44 @@
+package graph_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/blang/semver/v4"
+	oldgraph "github.com/joelanford/extensiondb/examples/cincinnati/pkg/graph"
+	oldutil "github.com/joelanford/extensiondb/examples/cincinnati/pkg/util"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Programs that still import the old paths keep building graphs with them and
+// passing the results to code that imports the new ones.
+func TestAliases(t *testing.T) {
+	mm := oldgraph.MajorMinor{Major: 1, Minor: 0}
+	pkg := oldgraph.Package{
+		Name: "foo",
+		Streams: []oldgraph.VersionStream{{
+			Version:        mm,
+			LifecycleDates: oldgraph.LifecycleDates{FullSupport: oldgraph.NewDate(2020, 1, 1), Maintenance: oldgraph.NewDate(2031, 1, 1), EndOfLife: oldgraph.NewDate(2032, 1, 1)},
+		}},
+		Nodes: []*oldgraph.Node{
+			{Name: "foo", Version: semver.MustParse("1.0.1"), ReleaseDate: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)},
+			{Name: "foo", Version: semver.MustParse("1.0.0"), ReleaseDate: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		},
+	}
+	var g *graph.Graph
+	g, err := oldgraph.NewGraph(t.Context(), oldgraph.GraphConfig{
+		Packages:  []graph.Package{pkg},
+		AsOf:      time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		PathScope: oldgraph.PathScopePackage,
+	})
+	require.NoError(t, err)
+
+	nodes := slices.SortedFunc(g.NodesMatching(oldgraph.PackageNodes("foo")), oldutil.Compare)
+	require.Len(t, nodes, 2)
+	assert.Equal(t, "foo.v1.0.0", nodes[0].NVR())
+	assert.Equal(t, graph.LifecyclePhaseFullSupport, nodes[1].LifecyclePhase)
+	assert.True(t, g.HeadsFor("foo").Has(nodes[1]), "the newest node is the head")
+}
@@ -0,0 +1,322 @@
+// Package schema publishes JSON Schemas for the documents that extensiondb
+// reads and writes, so that automation consuming them can validate documents
+// before acting on them.
+//
+// The schemas are plain JSON Schema (draft 2020-12) documents, available from
+// Schema.JSON. Schema.Validate checks documents against them without further
+// dependencies, supporting only the keywords the schemas use: $ref to $defs,
+// type, properties, required, additionalProperties, items, minItems,
+// maxItems, minLength, minimum, and pattern.
+package schema
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math/big"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/joelanford/extensiondb/pkg/graph"
+)
+
+//go:embed *.schema.json
+var files embed.FS
+
+var (
+	// Plans describes a list of OpenShift update plans, as read by the plan
+	// and publish commands.
+	Plans = mustLoad("plans.schema.json", nil)
+
+	// PlatformUpdate describes a planned OpenShift update, as written by
+	// graph.PlatformUpdate.MarshalJSON.
+	PlatformUpdate = mustLoad("platform-update.schema.json", nil)
+
+	// CincinnatiGraph describes an update graph in the Cincinnati update
+	// protocol, as returned by graph.Graph.Cincinnati. Validate also checks
+	// that edges refer to nodes in the graph.
+	CincinnatiGraph = mustLoad("cincinnati-graph.schema.json", checkCincinnatiEdges)
+)
+
+// Schema is a JSON Schema for a kind of document.
+type Schema struct {
+	name  string
+	data  []byte
+	root  *node
+	check func(data []byte) []string
+}
+
+// Name returns the file name of the schema, such as plans.schema.json.
+func (s *Schema) Name() string {
+	return s.name
+}
+
+// JSON returns the schema document.
+func (s *Schema) JSON() []byte {
+	return bytes.Clone(s.data)
+}
+
+// Validate checks that data is a single JSON document that the schema
+// describes. It returns an error listing every violation, each prefixed with
+// the path of the offending value, such as $.nodes[2].version.
+func (s *Schema) Validate(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	if _, err := dec.Token(); err == nil {
+		return errors.New("invalid JSON: unexpected data after the document")
+	}
+
+	var v validator
+	v.validate(s.root, doc, "$")
+	if len(v.violations) == 0 && s.check != nil {
+		v.violations = s.check(data)
+	}
+	if len(v.violations) == 0 {
+		return nil
+	}
+	errs := make([]error, 0, len(v.violations))
+	for _, violation := range v.violations {
+		errs = append(errs, errors.New(violation))
+	}
+	return fmt.Errorf("document does not match %s: %w", s.name, errors.Join(errs...))
+}
+
+func mustLoad(name string, check func(data []byte) []string) *Schema {
+	data, err := files.ReadFile(name)
+	if err != nil {
+		panic(err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var root node
+	if err := dec.Decode(&root); err != nil {
+		panic(fmt.Sprintf("invalid schema %s: %v", name, err))
+	}
+	if err := root.compile(&root); err != nil {
+		panic(fmt.Sprintf("invalid schema %s: %v", name, err))
+	}
+	return &Schema{name: name, data: data, root: &root, check: check}
+}
+
+// node is a schema or subschema. Unknown keywords are rejected when loading
+// schemas, so that schemas can't rely on keywords that aren't validated.
+type node struct {
+	Schema      string `json:"$schema"`
+	ID          string `json:"$id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+
+	Ref  string           `json:"$ref"`
+	Defs map[string]*node `json:"$defs"`
+
+	Type                 types            `json:"type"`
+	Properties           map[string]*node `json:"properties"`
+	Required             []string         `json:"required"`
+	AdditionalProperties *additional      `json:"additionalProperties"`
+	Items                *node            `json:"items"`
+	MinItems             *int             `json:"minItems"`
+	MaxItems             *int             `json:"maxItems"`
+	MinLength            *int             `json:"minLength"`
+	Minimum              *json.Number     `json:"minimum"`
+	Pattern              string           `json:"pattern"`
+
+	ref     *node
+	pattern *regexp.Regexp
+	minimum *big.Rat
+}
+
+// compile resolves references to the root's $defs and compiles patterns.
+func (n *node) compile(root *node) error {
+	if n.Ref != "" {
+		name, ok := strings.CutPrefix(n.Ref, "#/$defs/")
+		if !ok || root.Defs[name] == nil {
+			return fmt.Errorf("unresolvable $ref %q", n.Ref)
+		}
+		n.ref = root.Defs[name]
+	}
+	if n.Pattern != "" {
+		re, err := regexp.Compile(n.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", n.Pattern, err)
+		}
+		n.pattern = re
+	}
+	if n.Minimum != nil {
+		r, ok := new(big.Rat).SetString(n.Minimum.String())
+		if !ok {
+			return fmt.Errorf("invalid minimum %s", *n.Minimum)
+		}
+		n.minimum = r
+	}
+
+	var children []*node
+	children = slices.AppendSeq(children, maps.Values(n.Defs))
+	children = slices.AppendSeq(children, maps.Values(n.Properties))
+	if n.AdditionalProperties != nil && n.AdditionalProperties.schema != nil {
+		children = append(children, n.AdditionalProperties.schema)
+	}
+	if n.Items != nil {
+		children = append(children, n.Items)
+	}
+	for _, c := range children {
+		if err := c.compile(root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// types is the type keyword, which is a type name or a list of them.
+type types []string
+
+func (t *types) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*t = types{name}
+		return nil
+	}
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return fmt.Errorf("type must be a string or a list of strings")
+	}
+	*t = names
+	return nil
+}
+
+// additional is the additionalProperties keyword, which either allows or
+// forbids additional properties, or is a schema for their values.
+type additional struct {
+	allowed bool
+	schema  *node
+}
+
+func (a *additional) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &a.allowed); err == nil {
+		return nil
+	}
+	a.allowed = true
+	return json.Unmarshal(data, &a.schema)
+}
+
+type validator struct {
+	violations []string
+}
+
+func (v *validator) fail(path, format string, args ...any) {
+	v.violations = append(v.violations, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *validator) validate(n *node, doc any, path string) {
+	if n.ref != nil {
+		v.validate(n.ref, doc, path)
+	}
+	if len(n.Type) > 0 && !slices.ContainsFunc(n.Type, func(t string) bool { return hasType(doc, t) }) {
+		v.fail(path, "expected %s, got %s", strings.Join(n.Type, " or "), typeOf(doc))
+		return
+	}
+
+	switch doc := doc.(type) {
+	case map[string]any:
+		for _, name := range n.Required {
+			if _, ok := doc[name]; !ok {
+				v.fail(path, "missing required property %q", name)
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(doc)) {
+			propPath := path + "." + name
+			if p, ok := n.Properties[name]; ok {
+				v.validate(p, doc[name], propPath)
+				continue
+			}
+			switch ap := n.AdditionalProperties; {
+			case ap == nil:
+			case !ap.allowed:
+				v.fail(path, "unexpected property %q", name)
+			case ap.schema != nil:
+				v.validate(ap.schema, doc[name], propPath)
+			}
+		}
+	case []any:
+		if n.MinItems != nil && len(doc) < *n.MinItems {
+			v.fail(path, "expected at least %d items, got %d", *n.MinItems, len(doc))
+		}
+		if n.MaxItems != nil && len(doc) > *n.MaxItems {
+			v.fail(path, "expected at most %d items, got %d", *n.MaxItems, len(doc))
+		}
+		if n.Items != nil {
+			for i, item := range doc {
+				v.validate(n.Items, item, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	case string:
+		if n.MinLength != nil && utf8.RuneCountInString(doc) < *n.MinLength {
+			v.fail(path, "expected at least %d characters", *n.MinLength)
+		}
+		if n.pattern != nil && !n.pattern.MatchString(doc) {
+			v.fail(path, "%q does not match pattern %q", doc, n.Pattern)
+		}
+	case json.Number:
+		if r, ok := new(big.Rat).SetString(doc.String()); ok && n.minimum != nil && r.Cmp(n.minimum) < 0 {
+			v.fail(path, "%s is less than the minimum %s", doc, *n.Minimum)
+		}
+	}
+}
+
+func hasType(doc any, t string) bool {
+	switch t {
+	case "integer":
+		n, ok := doc.(json.Number)
+		if !ok {
+			return false
+		}
+		r, ok := new(big.Rat).SetString(n.String())
+		return ok && r.IsInt()
+	case "number":
+		_, ok := doc.(json.Number)
+		return ok
+	default:
+		return typeOf(doc) == t
+	}
+}
+
+func typeOf(doc any) string {
+	switch doc.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
+
+func checkCincinnatiEdges(data []byte) []string {
+	var cg graph.CincinnatiGraph
+	if err := json.Unmarshal(data, &cg); err != nil {
+		return []string{"$: " + err.Error()}
+	}
+	var violations []string
+	for i, edge := range cg.Edges {
+		for j, index := range edge {
+			if index >= len(cg.Nodes) {
+				violations = append(violations, fmt.Sprintf("$.edges[%d][%d]: no node with index %d", i, j, index))
+			}
+		}
+	}
+	return violations
+}
@@ -0,0 +1,134 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/joelanford/extensiondb/examples/cincinnati/pkg/schema"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/joelanford/extensiondb/pkg/graph/graphtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testGraph(t *testing.T) (*graph.Graph, []*graph.Node) {
+	streams := graphtest.Streams(graph.MajorMinor{Major: 1, Minor: 0}, graph.MajorMinor{Major: 1, Minor: 1})
+	for i := range streams {
+		streams[i].SupportedPlatformVersions = []graph.MajorMinor{{Major: 4, Minor: 14}, {Major: 4, Minor: 15}}
+	}
+	release := "2"
+	nodes := graphtest.Nodes("foo", "1.0.0", "1.1.0")
+	for _, n := range nodes {
+		n.Release = &release
+	}
+	return graphtest.New(t, graph.Package{Name: "foo", Streams: streams, Nodes: nodes}), nodes
+}
+
+func TestSchemas(t *testing.T) {
+	for _, s := range []*schema.Schema{schema.Plans, schema.PlatformUpdate, schema.CincinnatiGraph} {
+		var doc map[string]any
+		require.NoError(t, json.Unmarshal(s.JSON(), &doc), s.Name())
+		assert.Equal(t, "https://json-schema.org/draft/2020-12/schema", doc["$schema"], s.Name())
+	}
+}
+
+func TestPlans(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		doc     string
+		wantErr []string
+	}{
+		{
+			name: "valid",
+			doc:  `[{"name": "eus-4.14", "fromPlatform": "4.14", "toPlatform": "4.16", "installed": [{"package": "foo", "version": "1.0.0-rc.1"}]}]`,
+		},
+		{
+			name:    "missing name",
+			doc:     `[{"fromPlatform": "4.14", "toPlatform": "4.16", "installed": [{"package": "foo", "version": "1.0.0"}]}]`,
+			wantErr: []string{`$[0]: missing required property "name"`},
+		},
+		{
+			name: "invalid versions",
+			doc:  `[{"name": "eus", "fromPlatform": "4.14.1", "toPlatform": 4.16, "installed": [{"package": "foo", "version": "v1"}]}]`,
+			wantErr: []string{
+				`$[0].fromPlatform: "4.14.1" does not match pattern`,
+				`$[0].installed[0].version: "v1" does not match pattern`,
+				`$[0].toPlatform: expected string, got number`,
+			},
+		},
+		{
+			name:    "unknown property",
+			doc:     `[{"name": "eus", "fromPlatform": "4.14", "toPlatform": "4.16", "installed": [{"package": "foo", "version": "1.0.0"}], "cluster": "a"}]`,
+			wantErr: []string{`$[0]: unexpected property "cluster"`},
+		},
+		{
+			name:    "not JSON",
+			doc:     `- name: eus`,
+			wantErr: []string{"invalid JSON"},
+		},
+		{
+			name:    "trailing data",
+			doc:     `[] []`,
+			wantErr: []string{"unexpected data after the document"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := schema.Plans.Validate([]byte(tc.doc))
+			if len(tc.wantErr) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, want := range tc.wantErr {
+				assert.ErrorContains(t, err, want)
+			}
+		})
+	}
+}
+
+func TestPlatformUpdate(t *testing.T) {
+	g, nodes := testGraph(t)
+	pu, err := g.PlanOpenShiftUpdate(t.Context(), nodes[:1], graph.MajorMinor{Major: 4, Minor: 14}, graph.MajorMinor{Major: 4, Minor: 15})
+	require.NoError(t, err)
+	data, err := json.Marshal(pu)
+	require.NoError(t, err)
+	require.NoError(t, schema.PlatformUpdate.Validate(data))
+
+	var doc struct {
+		From        string `json:"from"`
+		NodeUpdates []struct {
+			Package string   `json:"package"`
+			From    string   `json:"from"`
+			Before  []string `json:"before"`
+			After   []string `json:"after"`
+		} `json:"nodeUpdates"`
+	}
+	require.NoError(t, json.Unmarshal(data, &doc))
+	assert.Equal(t, "4.14", doc.From)
+	require.Len(t, doc.NodeUpdates, 1)
+	assert.Equal(t, "foo", doc.NodeUpdates[0].Package)
+	assert.Equal(t, "1.0.0_2", doc.NodeUpdates[0].From)
+	assert.Equal(t, []string{"1.0.0_2"}, doc.NodeUpdates[0].Before)
+	assert.NotNil(t, doc.NodeUpdates[0].After, "paths are never null")
+
+	err = schema.PlatformUpdate.Validate([]byte(`{"name": "OpenShift", "from": "4.14", "to": "4.15", "nodeUpdates": [{"package": "foo", "from": "1.0.0", "before": null, "after": []}]}`))
+	assert.ErrorContains(t, err, "$.nodeUpdates[0].before: expected array, got null")
+}
+
+func TestCincinnatiGraph(t *testing.T) {
+	g, _ := testGraph(t)
+	data, err := json.Marshal(g.Cincinnati(graph.AllNodes(), "stable"))
+	require.NoError(t, err)
+	require.NoError(t, schema.CincinnatiGraph.Validate(data))
+
+	err = schema.CincinnatiGraph.Validate([]byte(`{"nodes": [{"version": "1.0.0", "payload": "", "metadata": {}}], "edges": [[0, 1], [0, -1]]}`))
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "$.edges[1][1]: -1 is less than the minimum 0")
+
+	err = schema.CincinnatiGraph.Validate([]byte(`{"nodes": [{"version": "1.0.0", "payload": "", "metadata": {"a": 1}}], "edges": [[0, 1]]}`))
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "$.nodes[0].metadata.a: expected string, got number")
+
+	err = schema.CincinnatiGraph.Validate([]byte(`{"nodes": [{"version": "1.0.0", "payload": "", "metadata": {}}], "edges": [[0, 1]]}`))
+	assert.ErrorContains(t, err, "$.edges[0][1]: no node with index 1")
+}
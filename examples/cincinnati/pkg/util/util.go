@@ -1,52 +1,34 @@
+// Package util is the former import path of the util package, which has moved
+// to github.com/joelanford/extensiondb/pkg/util along with the graph package.
+//
+// Deprecated: Use github.com/joelanford/extensiondb/pkg/util instead. This
+// package will be removed in a future release.
 package util
 
 import (
-	"hash/fnv"
 	"iter"
-	"maps"
-	"slices"
+
+	"github.com/joelanford/extensiondb/pkg/util"
 )
 
+type Comparer[T any] = util.Comparer[T]
+
 func KeySlice[K comparable, V any](s []V, key func(V) K) map[K]V {
-	m := make(map[K]V, len(s))
-	for _, v := range s {
-		m[key(v)] = v
-	}
-	return m
+	return util.KeySlice(s, key)
 }
 
-func MapSlice[I, O any](in []I, key func(I) O) []O {
-	out := make([]O, len(in))
-	for i := range in {
-		out[i] = key(in[i])
-	}
-	return out
+func MapSlice[I, O any](in []I, f func(I) O) []O {
+	return util.MapSlice(in, f)
 }
 
 func OrderedMap[K comparable, V any](m map[K]V, cmp func(a, b K) int) iter.Seq2[K, V] {
-	return func(yield func(K, V) bool) {
-		orderedKeys := slices.SortedFunc(maps.Keys(m), cmp)
-		for _, k := range orderedKeys {
-			v := m[k]
-			if !yield(k, v) {
-				return
-			}
-		}
-	}
-}
-
-type Comparer[T any] interface {
-	Compare(T) int
+	return util.OrderedMap(m, cmp)
 }
 
 func Compare[T Comparer[T]](a, b T) int {
-	return a.Compare(b)
+	return util.Compare(a, b)
 }
 
 func HashString(s string) uint64 {
-	h := fnv.New64a()
-	if _, err := h.Write([]byte(s)); err != nil {
-		panic(err)
-	}
-	return h.Sum64()
+	return util.HashString(s)
 }
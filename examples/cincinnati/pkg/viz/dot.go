@@ -0,0 +1,84 @@
+package viz
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/joelanford/extensiondb/pkg/util"
+)
+
+type DotConfig struct {
+	KeepNode graph.NodePredicate
+	KeepEdge graph.EdgePredicate
+
+	// Summary, when true, adds a note to the digraph describing the rendered
+	// nodes, the heads of each stream, and when the digraph was generated.
+	Summary bool
+}
+
+// Dot renders the nodes of a package as a Graphviz DOT digraph, with a
+// cluster for each stream. Nodes and clusters are filled by lifecycle phase,
+// heads are outlined in bold, and edges are labeled with their weights. With
+// cfg.Summary, a note node describes the rendered nodes.
+func Dot(g *graph.Graph, pkg string, cfg DotConfig) string {
+	if cfg.KeepNode == nil {
+		cfg.KeepNode = graph.AllNodes()
+	}
+	if cfg.KeepEdge == nil {
+		cfg.KeepEdge = graph.AllEdges()
+	}
+
+	nodesByStream := map[graph.MajorMinor][]*graph.Node{}
+	for _, n := range slices.SortedFunc(g.NodesMatching(graph.PackageNodes(pkg)), util.Compare) {
+		if !cfg.KeepNode(g, n) {
+			continue
+		}
+		mm := graph.NewMajorMinorFromVersion(n.Version)
+		nodesByStream[mm] = append(nodesByStream[mm], n)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "digraph %s {\n", strconv.Quote(pkg))
+	sb.WriteString("  rankdir=LR;\n")
+	sb.WriteString("  node [shape=box, style=filled];\n")
+
+	edgesTo := map[*graph.Node][]graph.WeightedEdge{}
+	for e := range g.EdgesMatching(func(g *graph.Graph, from, to *graph.Node, weight float64) bool {
+		return from.Name == pkg && cfg.KeepNode(g, from) && cfg.KeepEdge(g, from, to, weight)
+	}) {
+		edgesTo[e.To] = append(edgesTo[e.To], e)
+	}
+
+	var edges strings.Builder
+	for mm, nodes := range util.OrderedMap(nodesByStream, util.Compare) {
+		phase := nodes[0].LifecyclePhase
+		fmt.Fprintf(&sb, "\n  subgraph %s {\n", strconv.Quote("cluster_"+mm.String()))
+		fmt.Fprintf(&sb, "    label=%s;\n", strconv.Quote(fmt.Sprintf("%s (%s)", mm, phase)))
+		fmt.Fprintf(&sb, "    style=filled;\n    fillcolor=%s;\n", strconv.Quote(colorForLifecyclePhase(phase).Hex()))
+		for _, to := range nodes {
+			attrs := fmt.Sprintf("fillcolor=%s", strconv.Quote(colorForLifecyclePhase(to.LifecyclePhase).Hex()))
+			if g.HeadsFor(pkg).Has(to) {
+				attrs += ", penwidth=3"
+			}
+			fmt.Fprintf(&sb, "    %s [%s];\n", strconv.Quote(to.VR()), attrs)
+
+			for _, e := range edgesTo[to] {
+				fmt.Fprintf(&edges, "  %s -> %s [label=%s];\n", strconv.Quote(e.From.VR()), strconv.Quote(to.VR()), strconv.Quote(strconv.FormatFloat(e.Weight, 'g', -1, 64)))
+			}
+		}
+		sb.WriteString("  }\n")
+	}
+	if edges.Len() > 0 {
+		sb.WriteString("\n")
+		sb.WriteString(edges.String())
+	}
+	if cfg.Summary {
+		lines := summarize(g, nodesByStream).lines()
+		fmt.Fprintf(&sb, "\n  summary [shape=note, style=solid, label=%s];\n", strconv.Quote(strings.Join(lines, "\n")))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
@@ -0,0 +1,91 @@
+package viz_test
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/examples/cincinnati/pkg/viz"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDot(t *testing.T) {
+	released := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var nodes []*graph.Node
+	for i, v := range []string{"1.0.0", "1.0.1", "1.0.2"} {
+		nodes = append(nodes, &graph.Node{Name: "foo", Version: semver.MustParse(v), ReleaseDate: released.AddDate(0, i, 0)})
+	}
+	g, err := graph.NewGraph(t.Context(), graph.GraphConfig{
+		Packages: []graph.Package{{
+			Name: "foo",
+			Streams: []graph.VersionStream{{
+				Version:              graph.MajorMinor{Major: 1},
+				MinimumUpdateVersion: semver.MustParse("1.0.0"),
+				LifecycleDates: graph.LifecycleDates{
+					FullSupport: graph.NewDate(2024, 1, 1),
+					Maintenance: graph.NewDate(2025, 1, 1),
+					EndOfLife:   graph.NewDate(2026, 1, 1),
+				},
+			}},
+			Nodes: nodes,
+		}},
+		AsOf: released.AddDate(0, 6, 0),
+	})
+	require.NoError(t, err)
+
+	dot := viz.Dot(g, "foo", viz.DotConfig{KeepEdge: viz.ShortestPathEdges()})
+	assert.Contains(t, dot, `digraph "foo" {`)
+	assert.Contains(t, dot, `subgraph "cluster_1.0" {`)
+	assert.Contains(t, dot, `label="1.0 (Full Support)";`)
+	assert.Regexp(t, `"1.0.2" \[fillcolor="#[0-9a-f]{6}", penwidth=3\];`, dot)
+	assert.Contains(t, dot, `"1.0.0" -> "1.0.2"`)
+	assert.Contains(t, dot, `"1.0.1" -> "1.0.2"`)
+	assert.NotContains(t, dot, `"1.0.0" -> "1.0.1"`)
+
+	dot = viz.Dot(g, "foo", viz.DotConfig{KeepNode: graph.NodeInRange(semver.MustParseRange("<1.0.2"))})
+	assert.NotContains(t, dot, `"1.0.2"`)
+	assert.Contains(t, dot, `"1.0.0" -> "1.0.1" [label="`)
+	assert.NotContains(t, dot, "summary")
+
+	dot = viz.Dot(g, "foo", viz.DotConfig{Summary: true})
+	assert.Regexp(t, `\n  summary \[shape=note, style=solid, label="Nodes: 3\\nDead-end nodes: 0\\nHeads by stream:\\n  1.0: 1.0.2\\nAs of: 2024-07-01\\nGenerated at: [^"]+"\];\n}\n$`, dot)
+}
+
+func TestDotExtensionPhases(t *testing.T) {
+	released := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	dates := graph.LifecycleDates{
+		FullSupport: graph.NewDate(2020, 1, 1),
+		Maintenance: graph.NewDate(2021, 1, 1),
+		EndOfLife:   graph.NewDate(2030, 1, 1),
+	}
+	var streams []graph.VersionStream
+	var nodes []*graph.Node
+	// Each stream has one more extension than the last, all of them started.
+	for minor := range 4 {
+		dates.Extensions = append(dates.Extensions, graph.NewDate(2022, time.Month(minor+1), 1))
+		streams = append(streams, graph.VersionStream{Version: graph.MajorMinor{Major: 1, Minor: uint64(minor)}, LifecycleDates: dates})
+		nodes = append(nodes, &graph.Node{Name: "foo", Version: semver.Version{Major: 1, Minor: uint64(minor)}, ReleaseDate: released})
+	}
+	g, err := graph.NewGraph(t.Context(), graph.GraphConfig{
+		Packages: []graph.Package{{Name: "foo", Streams: streams, Nodes: nodes}},
+		AsOf:     released.AddDate(0, 6, 0),
+	})
+	require.NoError(t, err)
+
+	dot := viz.Dot(g, "foo", viz.DotConfig{})
+	colors := map[string]bool{}
+	for minor := range 4 {
+		phase := fmt.Sprintf("EUS-%d", minor+1)
+		m := regexp.MustCompile(`label="1\.` + fmt.Sprint(minor) + ` \(` + phase + `\)";\n    style=filled;\n    fillcolor="(#[0-9a-f]{6})";`).FindStringSubmatch(dot)
+		require.NotNil(t, m, "stream 1.%d is in %s", minor, phase)
+		colors[m[1]] = true
+	}
+	assert.Len(t, colors, 4, "every extension phase has its own color")
+
+	mmd := viz.Mermaid(g, "foo", viz.MermaidConfig{})
+	assert.Contains(t, mmd, `subgraph 1.3["1.3 (EUS-4)"]`)
+}
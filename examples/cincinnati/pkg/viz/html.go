@@ -0,0 +1,33 @@
+package viz
+
+import (
+	"fmt"
+	"html"
+
+	"github.com/joelanford/extensiondb/pkg/graph"
+)
+
+// mermaidScript is the URL of the Mermaid release that HTML pages load.
+const mermaidScript = "https://cdn.jsdelivr.net/npm/mermaid@11/dist/mermaid.esm.min.mjs"
+
+// HTML renders the Mermaid diagram of a package as a standalone HTML page,
+// which loads Mermaid to draw it when it is opened in a browser.
+func HTML(g *graph.Graph, pkg string, cfg MermaidConfig) string {
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>%[1]s</title>
+</head>
+<body>
+<h1>%[1]s</h1>
+<pre class="mermaid">
+%[2]s</pre>
+<script type="module">
+import mermaid from %[3]q;
+mermaid.initialize({ startOnLoad: true, maxEdges: 100000, maxTextSize: 10000000 });
+</script>
+</body>
+</html>
+`, html.EscapeString(pkg), html.EscapeString(Mermaid(g, pkg, cfg)), mermaidScript)
+}
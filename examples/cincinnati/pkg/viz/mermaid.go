@@ -6,11 +6,11 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/joelanford/extensiondb/examples/cincinnati/pkg/graph"
-	"github.com/joelanford/extensiondb/examples/cincinnati/pkg/util"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/joelanford/extensiondb/pkg/util"
 	"github.com/lucasb-eyer/go-colorful"
-	ggraph "gonum.org/v1/gonum/graph"
 )
 
 type MermaidConfig struct {
@@ -21,6 +21,10 @@ type MermaidConfig struct {
 	NodeStyle func(*graph.Graph, *graph.Node) string
 
 	EdgeStyle func(*graph.Graph, *graph.Node, *graph.Node, float64) string
+
+	// Summary, when true, appends a comment block to the diagram describing the
+	// rendered nodes, the heads of each stream, and when the diagram was generated.
+	Summary bool
 }
 
 func defaultMermaidConfig(m *MermaidConfig) {
@@ -34,9 +38,7 @@ func defaultMermaidConfig(m *MermaidConfig) {
 		m.KeepEdge = graph.AllEdges()
 	}
 	if m.NodeText == nil {
-		m.NodeText = func(_ *graph.Graph, n *graph.Node) string {
-			return n.VR()
-		}
+		m.NodeText = DefaultNodeText()
 	}
 
 	if m.NodeStyle == nil {
@@ -47,16 +49,72 @@ func defaultMermaidConfig(m *MermaidConfig) {
 	}
 }
 
+// DefaultNodeText labels each node with its version and release.
+func DefaultNodeText() func(*graph.Graph, *graph.Node) string {
+	return func(_ *graph.Graph, n *graph.Node) string {
+		return n.VR()
+	}
+}
+
+// PlatformNodeText labels each node with its version and release, followed by
+// the platform versions the node supports, collapsed into contiguous ranges
+// (e.g. "4.14–4.16, 4.18").
+func PlatformNodeText() func(*graph.Graph, *graph.Node) string {
+	return func(_ *graph.Graph, n *graph.Node) string {
+		platforms := platformRanges(n.SupportedPlatformVersions.UnsortedList())
+		if platforms == "" {
+			return n.VR()
+		}
+		return fmt.Sprintf("%s<br/>%s", n.VR(), platforms)
+	}
+}
+
+// ShortestPathEdges keeps only the edges that are the first hop of a shortest
+// path from their source node to a head, which hides the many edges that no
+// update would take.
+func ShortestPathEdges() graph.EdgePredicate {
+	return func(g *graph.Graph, from *graph.Node, to *graph.Node, _ float64) bool {
+		for head := range g.HeadsFor(from.Name) {
+			sp, _, _ := g.ShortestPath(from, head)
+			if len(sp) > 1 && sp[1] == to {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func platformRanges(platforms []graph.MajorMinor) string {
+	slices.SortFunc(platforms, util.Compare)
+
+	var ranges []string
+	for i := 0; i < len(platforms); {
+		start, end := platforms[i], platforms[i]
+		for i++; i < len(platforms); i++ {
+			next := platforms[i]
+			if next.Major != end.Major || next.Minor != end.Minor+1 {
+				break
+			}
+			end = next
+		}
+		if start == end {
+			ranges = append(ranges, start.String())
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%s–%s", start, end))
+		}
+	}
+	return strings.Join(ranges, ", ")
+}
+
 func defaultNodeStyle() func(*graph.Graph, *graph.Node) string {
 	return func(g *graph.Graph, node *graph.Node) string {
-		fullSupportNodes := g.NodesMatching(func(_ *graph.Graph, n *graph.Node) bool {
+		fullSupportNodes := g.NodesMatching(graph.AndNodes(graph.PackageNodes(node.Name), func(_ *graph.Graph, n *graph.Node) bool {
 			return n.LifecyclePhase == graph.LifecyclePhaseFullSupport
-		})
+		}))
 
 		hasPathToFullSupport := false
 		for to := range fullSupportNodes {
-			sp, _, _ := g.Paths().Between(node.ID(), to.ID())
-			if len(sp) > 0 {
+			if _, _, ok := g.ShortestPath(node, to); ok {
 				hasPathToFullSupport = true
 				break
 			}
@@ -67,13 +125,22 @@ func defaultNodeStyle() func(*graph.Graph, *graph.Node) string {
 			warningStyle = ",stroke:#ff0000,stroke-width:3px"
 		}
 
+		// Outline unverified nodes with a dashed border, keeping the warning
+		// stroke color if there is one.
+		if !node.Verified {
+			if warningStyle == "" {
+				warningStyle = ",stroke:#666666,stroke-width:2px"
+			}
+			warningStyle += ",stroke-dasharray:5 5"
+		}
+
 		lfp := node.LifecyclePhase
 		fillColor := colorForLifecyclePhase(lfp)
 		textColor := colorful.LinearRgb(0, 0, 0)
 		fh, fs, fl := fillColor.Hsl()
 		fl *= .9
 
-		if g.Heads().Has(node) {
+		if g.HeadsFor(node.Name).Has(node) {
 			fl = 1 - fl
 			textColor = colorful.LinearRgb(.95, .95, .95)
 		}
@@ -84,15 +151,12 @@ func defaultNodeStyle() func(*graph.Graph, *graph.Node) string {
 func defaultEdgeStyle() func(*graph.Graph, *graph.Node, *graph.Node, float64) string {
 	return func(g *graph.Graph, from *graph.Node, to *graph.Node, _ float64) string {
 		shortestPathTo := map[*graph.Node][]*graph.Node{}
-		for head := range g.Heads() {
-			sp, _, _ := g.Paths().Between(from.ID(), head.ID())
-			if len(sp) == 0 {
+		for head := range g.HeadsFor(from.Name) {
+			sp, _, ok := g.ShortestPath(from, head)
+			if !ok {
 				continue
 			}
-			shortestPathTo[head] = util.MapSlice(sp[1:], func(i ggraph.Node) *graph.Node {
-				n := i.(*graph.Node)
-				return n
-			})
+			shortestPathTo[head] = sp[1:]
 		}
 
 		for head, sp := range util.OrderedMap(shortestPathTo, func(a, b *graph.Node) int { return b.Compare(a) }) {
@@ -129,6 +193,13 @@ func Mermaid(g *graph.Graph, pkg string, cfg MermaidConfig) string {
 	edgeCount := 0
 	edgeStyles := map[string][]string{}
 
+	edgesTo := map[*graph.Node][]graph.WeightedEdge{}
+	for e := range g.EdgesMatching(func(g *graph.Graph, from, to *graph.Node, weight float64) bool {
+		return from.Name == pkg && cfg.KeepNode(g, from) && cfg.KeepEdge(g, from, to, weight)
+	}) {
+		edgesTo[e.To] = append(edgesTo[e.To], e)
+	}
+
 	for mm, vGroup := range util.OrderedMap(bundleMinorVersions, util.Compare) {
 		subgraphString := fmt.Sprintf("%s", mm)
 		sb.WriteString(fmt.Sprintf("\n  subgraph %s[\"%s (%s)\"]\n", subgraphString, mm, vGroup[0].LifecyclePhase.String()))
@@ -140,21 +211,12 @@ func Mermaid(g *graph.Graph, pkg string, cfg MermaidConfig) string {
 				nodeClasses[class] = style
 			}
 
-			// TODO: Use cfg.NodeText
-			sb.WriteString(fmt.Sprintf("    %s:::%s\n", to.VR(), class))
-
-			for _, from := range slices.SortedFunc(g.To(to), util.Compare) {
-				weight := g.EdgeWeight(from, to)
-				if !cfg.KeepNode(g, from) {
-					continue
-				}
-				if !cfg.KeepEdge(g, from, to, weight) {
-					continue
-				}
+			sb.WriteString(fmt.Sprintf("    %s[\"%s\"]:::%s\n", mermaidID(to), cfg.NodeText(g, to), class))
 
-				edgeStyle := cfg.EdgeStyle(g, from, to, weight)
+			for _, e := range edgesTo[to] {
+				edgeStyle := cfg.EdgeStyle(g, e.From, to, e.Weight)
 				edgeStyles[edgeStyle] = append(edgeStyles[edgeStyle], strconv.Itoa(edgeCount))
-				sb.WriteString(fmt.Sprintf("    %s --> %s\n", from.VR(), to.VR()))
+				sb.WriteString(fmt.Sprintf("    %s --> %s\n", mermaidID(e.From), mermaidID(to)))
 				edgeCount++
 			}
 		}
@@ -170,9 +232,88 @@ func Mermaid(g *graph.Graph, pkg string, cfg MermaidConfig) string {
 		sb.WriteString(fmt.Sprintf("  linkStyle %s %s;\n", strings.Join(edges, ","), style))
 	}
 
+	if cfg.Summary {
+		writeSummary(&sb, g, bundleMinorVersions)
+	}
+
 	return sb.String()
 }
 
+// mermaidID returns the ID of a node in a Mermaid diagram: its version and
+// release, with the separators of prereleases and build metadata replaced
+// and any other characters that Mermaid IDs can't contain removed.
+func mermaidID(n *graph.Node) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '.' || r == '_' {
+			return r
+		}
+		return -1
+	}, mermaidIDReplacer.Replace(n.VR()))
+}
+
+var mermaidIDReplacer = strings.NewReplacer("-", "_pre_", "+", "_build_")
+
+// summary describes the nodes of a rendered graph.
+type summary struct {
+	nodes        int
+	deadEnds     int
+	headsByMinor map[graph.MajorMinor][]string
+	asOf         time.Time
+	generatedAt  time.Time
+}
+
+func summarize(g *graph.Graph, nodesByStream map[graph.MajorMinor][]*graph.Node) summary {
+	s := summary{
+		headsByMinor: map[graph.MajorMinor][]string{},
+		asOf:         g.AsOf(),
+		generatedAt:  time.Now().UTC(),
+	}
+	var highestNode *graph.Node
+	for _, nodes := range nodesByStream {
+		for _, n := range nodes {
+			s.nodes++
+			if highestNode == nil || n.Compare(highestNode) > 0 {
+				highestNode = n
+			}
+		}
+	}
+	for mm, nodes := range nodesByStream {
+		for _, n := range nodes {
+			if !g.HeadsFor(n.Name).Has(n) {
+				continue
+			}
+			s.headsByMinor[mm] = append(s.headsByMinor[mm], n.VR())
+			if n != highestNode {
+				s.deadEnds++
+			}
+		}
+	}
+	return s
+}
+
+// lines returns the lines of the summary, indented by nesting.
+func (s summary) lines() []string {
+	lines := []string{
+		fmt.Sprintf("Nodes: %d", s.nodes),
+		fmt.Sprintf("Dead-end nodes: %d", s.deadEnds),
+		"Heads by stream:",
+	}
+	for mm, heads := range util.OrderedMap(s.headsByMinor, util.Compare) {
+		lines = append(lines, fmt.Sprintf("  %s: %s", mm, strings.Join(heads, ", ")))
+	}
+	return append(lines,
+		fmt.Sprintf("As of: %s", s.asOf.Format(time.DateOnly)),
+		fmt.Sprintf("Generated at: %s", s.generatedAt.Format(time.RFC3339)),
+	)
+}
+
+func writeSummary(sb *strings.Builder, g *graph.Graph, nodesByStream map[graph.MajorMinor][]*graph.Node) {
+	sb.WriteString("\n%% Summary\n")
+	for _, line := range summarize(g, nodesByStream).lines() {
+		sb.WriteString(fmt.Sprintf("%%%%   %s\n", line))
+	}
+}
+
 func fillStyle(lfp graph.LifecyclePhase) string {
 	return fmt.Sprintf("fill:%s", colorForLifecyclePhase(lfp).Hex())
 }
@@ -187,10 +328,13 @@ func colorForLifecyclePhase(lfp graph.LifecyclePhase) colorful.Color {
 		return colorful.Hsl(60, 1, .9)
 	case graph.LifecyclePhaseEndOfLife:
 		return colorful.Hsl(360, 1, .9)
-	case 2:
+	case graph.LifeCyclePhaseUnknown:
+		return colorful.Hsl(0, 0, .9)
+	case graph.LifecycleExtensionPhase(1):
 		return colorful.Hsl(170, 1, .9)
-	case 3:
-		return colorful.Hsl(240, 1, .9)
 	}
-	panic("unknown lifecycleState: " + lfp.String())
+	// Templates may have any number of extensions, so the hues of later
+	// extension phases approach purple from the blue of EUS-2.
+	n := float64(lfp - graph.LifecyclePhaseMaintenance)
+	return colorful.Hsl(300-120/n, 1, .9)
 }
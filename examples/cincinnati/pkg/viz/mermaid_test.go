@@ -0,0 +1,137 @@
+package viz_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/examples/cincinnati/pkg/viz"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestMermaidPrereleaseIDs(t *testing.T) {
+	released := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var nodes []*graph.Node
+	for i, v := range []string{"1.0.0-rc.1", "1.0.0", "1.0.1+build.5"} {
+		nodes = append(nodes, &graph.Node{Name: "foo", Version: semver.MustParse(v), ReleaseDate: released.AddDate(0, i, 0)})
+	}
+	g, err := graph.NewGraph(t.Context(), graph.GraphConfig{
+		Packages: []graph.Package{{
+			Name: "foo",
+			Streams: []graph.VersionStream{{
+				Version: graph.MajorMinor{Major: 1},
+				LifecycleDates: graph.LifecycleDates{
+					FullSupport: graph.NewDate(2024, 1, 1),
+					Maintenance: graph.NewDate(2025, 1, 1),
+					EndOfLife:   graph.NewDate(2026, 1, 1),
+				},
+			}},
+			Nodes: nodes,
+		}},
+		AsOf: released.AddDate(0, 6, 0),
+	})
+	require.NoError(t, err)
+
+	// IDs can't contain the separators of prereleases and build metadata,
+	// but labels show the versions as they are.
+	mmd := viz.Mermaid(g, "foo", viz.MermaidConfig{})
+	assert.Contains(t, mmd, `1.0.0_pre_rc.1["1.0.0-rc.1"]`)
+	assert.Contains(t, mmd, `1.0.1_build_build.5["1.0.1+build.5"]`)
+	assert.Contains(t, mmd, "1.0.0_pre_rc.1 --> 1.0.0\n")
+	assert.Contains(t, mmd, "1.0.0 --> 1.0.1_build_build.5\n")
+}
+
+func TestHTML(t *testing.T) {
+	released := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var nodes []*graph.Node
+	for i, v := range []string{"1.0.0", "1.0.1"} {
+		nodes = append(nodes, &graph.Node{Name: "foo", Version: semver.MustParse(v), ReleaseDate: released.AddDate(0, i, 0)})
+	}
+	g, err := graph.NewGraph(t.Context(), graph.GraphConfig{
+		Packages: []graph.Package{{
+			Name: "foo",
+			Streams: []graph.VersionStream{{
+				Version: graph.MajorMinor{Major: 1},
+				LifecycleDates: graph.LifecycleDates{
+					FullSupport: graph.NewDate(2024, 1, 1),
+					Maintenance: graph.NewDate(2025, 1, 1),
+					EndOfLife:   graph.NewDate(2026, 1, 1),
+				},
+			}},
+			Nodes: nodes,
+		}},
+		AsOf: released.AddDate(0, 6, 0),
+	})
+	require.NoError(t, err)
+
+	// The diagram is escaped, and Mermaid unescapes it before drawing it.
+	page := viz.HTML(g, "foo", viz.MermaidConfig{})
+	assert.Contains(t, page, "<title>foo</title>")
+	assert.Contains(t, page, "<pre class=\"mermaid\">\ngraph LR\n")
+	assert.Contains(t, page, "1.0.0 --&gt; 1.0.1\n")
+	assert.Contains(t, page, `import mermaid from "https://`)
+}
+
+func TestPlatformNodeText(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		platforms []graph.MajorMinor
+		want      string
+	}{
+		{"none", nil, "1.0.0"},
+		{"one", []graph.MajorMinor{{Major: 4, Minor: 14}}, "1.0.0<br/>4.14"},
+		{"range", []graph.MajorMinor{{Major: 4, Minor: 16}, {Major: 4, Minor: 14}, {Major: 4, Minor: 15}}, "1.0.0<br/>4.14–4.16"},
+		{"gap", []graph.MajorMinor{{Major: 4, Minor: 14}, {Major: 4, Minor: 15}, {Major: 4, Minor: 16}, {Major: 4, Minor: 18}}, "1.0.0<br/>4.14–4.16, 4.18"},
+		{"majors", []graph.MajorMinor{{Major: 4, Minor: 20}, {Major: 5, Minor: 0}, {Major: 5, Minor: 1}}, "1.0.0<br/>4.20, 5.0–5.1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			n := &graph.Node{Name: "foo", Version: semver.MustParse("1.0.0"), SupportedPlatformVersions: sets.New(tc.platforms...)}
+			assert.Equal(t, tc.want, viz.PlatformNodeText()(nil, n))
+		})
+	}
+}
+
+func TestMermaidSummary(t *testing.T) {
+	released := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	dates := graph.LifecycleDates{
+		FullSupport: graph.NewDate(2024, 1, 1),
+		Maintenance: graph.NewDate(2025, 1, 1),
+		EndOfLife:   graph.NewDate(2026, 1, 1),
+	}
+	var nodes []*graph.Node
+	for i, v := range []string{"1.0.0", "1.0.1", "2.0.0"} {
+		nodes = append(nodes, &graph.Node{Name: "foo", Version: semver.MustParse(v), ReleaseDate: released.AddDate(0, i, 0)})
+	}
+	g, err := graph.NewGraph(t.Context(), graph.GraphConfig{
+		Packages: []graph.Package{{
+			Name: "foo",
+			Streams: []graph.VersionStream{
+				{Version: graph.MajorMinor{Major: 1}, LifecycleDates: dates},
+				{Version: graph.MajorMinor{Major: 2}, LifecycleDates: dates},
+			},
+			Nodes: nodes,
+		}},
+		AsOf: released.AddDate(0, 6, 0),
+	})
+	require.NoError(t, err)
+
+	assert.NotContains(t, viz.Mermaid(g, "foo", viz.MermaidConfig{}), "%% Summary")
+
+	// Updates don't cross majors, so the head of 1.0 is a dead end.
+	mmd := viz.Mermaid(g, "foo", viz.MermaidConfig{Summary: true})
+	assert.Contains(t, mmd, "\n%% Summary\n"+
+		"%%   Nodes: 3\n"+
+		"%%   Dead-end nodes: 1\n"+
+		"%%   Heads by stream:\n"+
+		"%%     1.0: 1.0.1\n"+
+		"%%     2.0: 2.0.0\n"+
+		"%%   As of: 2024-07-01\n")
+	assert.Regexp(t, `%%   Generated at: \d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}Z\n$`, mmd)
+
+	// Only the rendered nodes are summarized.
+	mmd = viz.Mermaid(g, "foo", viz.MermaidConfig{KeepNode: graph.NodeInRange(semver.MustParseRange("<2.0.0")), Summary: true})
+	assert.Contains(t, mmd, "%%   Nodes: 2\n%%   Dead-end nodes: 0\n%%   Heads by stream:\n%%     1.0: 1.0.1\n%%   As of:")
+}
@@ -0,0 +1,161 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/graphdb"
+	"github.com/joelanford/extensiondb/internal/models"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/joelanford/extensiondb/pkg/util"
+)
+
+// Tables returns the bundles and catalog_membership tables from the database,
+// and the edges and lifecycle_phases tables of the graphs of templates as of
+// asOf.
+func Tables(ctx context.Context, q *query.Query, builder *graphdb.Builder, templates []graph.Template, asOf time.Time) ([]Table, error) {
+	bundles, err := bundlesTable(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	membership, err := catalogMembershipTable(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	g, err := builder.Build(ctx, templates, asOf)
+	if err != nil {
+		return nil, fmt.Errorf("error building graph: %w", err)
+	}
+	return append([]Table{bundles, membership}, GraphTables(g, templates)...), nil
+}
+
+func bundlesTable(ctx context.Context, q *query.Query) (Table, error) {
+	t := Table{
+		Name: "bundles",
+		Columns: []Column{
+			{Name: "package", Type: String},
+			{Name: "version", Type: String},
+			{Name: "release", Type: String},
+			{Name: "digest", Type: String},
+			{Name: "build_time", Type: Timestamp},
+			{Name: "created_at", Type: Timestamp},
+		},
+	}
+	packages, err := q.ListPackages(ctx)
+	if err != nil {
+		return Table{}, fmt.Errorf("error listing packages: %w", err)
+	}
+	for _, p := range packages {
+		bundles, err := q.ListBundlesForPackage(ctx, p)
+		if err != nil {
+			return Table{}, fmt.Errorf("error listing bundles for package %q: %w", p.Name, err)
+		}
+		for _, b := range bundles {
+			var buildTime any
+			if b.Image.V != nil && b.Image.V.Created != nil {
+				buildTime = *b.Image.V.Created
+			}
+			var createdAt any
+			if b.CreatedAt.Valid {
+				createdAt = b.CreatedAt.Time
+			}
+			t.append(p.Name, b.Version, bundleRelease(b), bundleDigest(b), buildTime, createdAt)
+		}
+	}
+	return t, nil
+}
+
+func catalogMembershipTable(ctx context.Context, q *query.Query) (Table, error) {
+	t := Table{
+		Name: "catalog_membership",
+		Columns: []Column{
+			{Name: "catalog", Type: String},
+			{Name: "tag", Type: String},
+			{Name: "package", Type: String},
+			{Name: "version", Type: String},
+			{Name: "release", Type: String},
+			{Name: "digest", Type: String},
+			{Name: "image", Type: String},
+		},
+	}
+	catalogs, err := q.ListCatalogs(ctx)
+	if err != nil {
+		return Table{}, fmt.Errorf("error listing catalogs: %w", err)
+	}
+	for _, c := range catalogs {
+		bundles, err := q.GetBundlesInCatalog(ctx, c, nil)
+		if err != nil {
+			return Table{}, fmt.Errorf("error getting bundles in catalog %s:%s: %w", c.Name, c.Tag, err)
+		}
+		for _, cb := range bundles {
+			t.append(c.Name, c.Tag, cb.PackageName, cb.Bundle.Version, bundleRelease(cb.Bundle), bundleDigest(cb.Bundle), cb.Image)
+		}
+	}
+	return t, nil
+}
+
+func bundleRelease(b *models.Bundle) any {
+	if !b.Release.Valid {
+		return nil
+	}
+	return b.Release.String
+}
+
+func bundleDigest(b *models.Bundle) any {
+	if b.Descriptor.V == nil {
+		return nil
+	}
+	return b.Descriptor.V.Digest.String()
+}
+
+// GraphTables returns the edges and lifecycle_phases tables of the packages of
+// templates in g.
+func GraphTables(g *graph.Graph, templates []graph.Template) []Table {
+	edges := Table{
+		Name: "edges",
+		Columns: []Column{
+			{Name: "package", Type: String},
+			{Name: "from_version", Type: String},
+			{Name: "to_version", Type: String},
+			{Name: "weight", Type: Float64},
+		},
+	}
+	phases := Table{
+		Name: "lifecycle_phases",
+		Columns: []Column{
+			{Name: "package", Type: String},
+			{Name: "version", Type: String},
+			{Name: "stream", Type: String},
+			{Name: "lifecycle_phase", Type: String},
+			{Name: "release_date", Type: Timestamp},
+			{Name: "full_support", Type: Timestamp},
+			{Name: "maintenance", Type: Timestamp},
+			{Name: "end_of_life", Type: Timestamp},
+		},
+	}
+
+	for _, tmpl := range templates {
+		streams := make(map[graph.MajorMinor]graph.VersionStream, len(tmpl.VersionStreams))
+		for _, vs := range tmpl.VersionStreams {
+			streams[vs.Version] = vs
+		}
+		for _, from := range slices.SortedFunc(g.NodesMatching(graph.PackageNodes(tmpl.Name)), util.Compare) {
+			for _, to := range slices.SortedFunc(g.From(from), util.Compare) {
+				edges.append(tmpl.Name, from.VR(), to.VR(), g.EdgeWeight(from, to))
+			}
+
+			mm := graph.NewMajorMinorFromVersion(from.Version)
+			row := []any{tmpl.Name, from.VR(), mm.String(), from.LifecyclePhase.String(), from.ReleaseDate, nil, nil, nil}
+			if vs, ok := streams[mm]; ok {
+				row[5] = vs.LifecycleDates.FullSupport.Time()
+				row[6] = vs.LifecycleDates.Maintenance.Time()
+				row[7] = vs.LifecycleDates.EndOfLife.Time()
+			}
+			phases.append(row...)
+		}
+	}
+	return []Table{edges, phases}
+}
@@ -0,0 +1,60 @@
+package analytics_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/joelanford/extensiondb/internal/analytics"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/joelanford/extensiondb/pkg/graph/graphtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraphTables(t *testing.T) {
+	tmpl := graph.Template{
+		Name:           "foo",
+		VersionStreams: graphtest.Streams(graph.MajorMinor{Major: 1, Minor: 0}),
+	}
+	g := graphtest.New(t, graph.Package{Name: "foo", Streams: tmpl.VersionStreams, Nodes: graphtest.Nodes("foo", "1.0.0", "1.0.1")})
+
+	tables := analytics.GraphTables(g, []graph.Template{tmpl})
+	require.Len(t, tables, 2)
+
+	edges := tables[0]
+	assert.Equal(t, "edges", edges.Name)
+	require.Len(t, edges.Rows, 1)
+	assert.Equal(t, []any{"foo", "1.0.0", "1.0.1"}, edges.Rows[0][:3])
+
+	var buf bytes.Buffer
+	require.NoError(t, analytics.WriteCSV(&buf, tables[1]))
+	assert.Equal(t, `package,version,stream,lifecycle_phase,release_date,full_support,maintenance,end_of_life
+foo,1.0.0,1.0,Full Support,2024-01-01T00:00:00Z,2024-01-01T00:00:00Z,2029-01-01T00:00:00Z,2030-01-01T00:00:00Z
+foo,1.0.1,1.0,Full Support,2024-02-01T00:00:00Z,2024-01-01T00:00:00Z,2029-01-01T00:00:00Z,2030-01-01T00:00:00Z
+`, buf.String())
+}
+
+func TestWriteParquet(t *testing.T) {
+	table := analytics.Table{
+		Name: "example",
+		Columns: []analytics.Column{
+			{Name: "name", Type: analytics.String},
+			{Name: "count", Type: analytics.Int64},
+		},
+		Rows: [][]any{{"a", int64(1)}, {nil, int64(2)}},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, analytics.WriteParquet(&buf, table))
+	data := buf.Bytes()
+	require.Greater(t, len(data), 12)
+	assert.Equal(t, "PAR1", string(data[:4]))
+	assert.Equal(t, "PAR1", string(data[len(data)-4:]))
+	footerLen := binary.LittleEndian.Uint32(data[len(data)-8:])
+	assert.Less(t, int(footerLen), len(data)-12)
+	assert.Contains(t, string(data[len(data)-8-int(footerLen):]), "count")
+
+	table.Rows[0][1] = "not an int"
+	assert.ErrorContains(t, analytics.WriteParquet(&buf, table), "column count: unexpected value of type string")
+}
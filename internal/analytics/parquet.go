@@ -0,0 +1,296 @@
+package analytics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// WriteParquet writes t as a Parquet file. Every column is optional, and the
+// file has a single row group with one uncompressed, PLAIN-encoded data page
+// per column, which every Parquet reader supports. Strings are written as
+// UTF8 byte arrays and timestamps as TIMESTAMP_MILLIS.
+func WriteParquet(w io.Writer, t Table) error {
+	const magic = "PAR1"
+
+	var file bytes.Buffer
+	file.WriteString(magic)
+
+	chunks := make([]columnChunk, 0, len(t.Columns))
+	for i, c := range t.Columns {
+		page, err := dataPage(t, i)
+		if err != nil {
+			return fmt.Errorf("table %s: %w", t.Name, err)
+		}
+
+		var header thriftWriter
+		header.beginStruct()
+		header.i32Field(1, 0) // type: DATA_PAGE
+		header.i32Field(2, int32(len(page)))
+		header.i32Field(3, int32(len(page)))
+		header.structField(5) // data_page_header
+		header.i32Field(1, int32(len(t.Rows)))
+		header.i32Field(2, encodingPlain)
+		header.i32Field(3, encodingRLE) // definition_level_encoding
+		header.i32Field(4, encodingRLE) // repetition_level_encoding
+		header.endStruct()
+		header.endStruct()
+
+		chunks = append(chunks, columnChunk{
+			column: c,
+			offset: int64(file.Len()),
+			size:   int64(header.buf.Len() + len(page)),
+		})
+		file.Write(header.buf.Bytes())
+		file.Write(page)
+	}
+
+	footer := fileMetaData(t, chunks)
+	file.Write(footer)
+	_ = binary.Write(&file, binary.LittleEndian, uint32(len(footer)))
+	file.WriteString(magic)
+
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+// Parquet enum values, from the parquet-format Thrift definitions.
+const (
+	typeInt64     = 2
+	typeDouble    = 5
+	typeByteArray = 6
+
+	convertedTypeUTF8            = 0
+	convertedTypeTimestampMillis = 9
+
+	repetitionOptional = 1
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecUncompressed = 0
+)
+
+type columnChunk struct {
+	column Column
+	offset int64
+	size   int64
+}
+
+func physicalType(c Column) int32 {
+	switch c.Type {
+	case Int64, Timestamp:
+		return typeInt64
+	case Float64:
+		return typeDouble
+	}
+	return typeByteArray
+}
+
+// dataPage returns the definition levels and PLAIN-encoded non-null values of
+// column i.
+func dataPage(t Table, i int) ([]byte, error) {
+	c := t.Columns[i]
+	levels := make([]bool, len(t.Rows))
+	var values bytes.Buffer
+	for r, row := range t.Rows {
+		v := row[i]
+		if v == nil {
+			continue
+		}
+		levels[r] = true
+
+		var ok bool
+		switch c.Type {
+		case String:
+			var s string
+			if s, ok = v.(string); ok {
+				_ = binary.Write(&values, binary.LittleEndian, uint32(len(s)))
+				values.WriteString(s)
+			}
+		case Int64:
+			var n int64
+			if n, ok = v.(int64); ok {
+				_ = binary.Write(&values, binary.LittleEndian, n)
+			}
+		case Float64:
+			var f float64
+			if f, ok = v.(float64); ok {
+				_ = binary.Write(&values, binary.LittleEndian, math.Float64bits(f))
+			}
+		case Timestamp:
+			var ts time.Time
+			if ts, ok = v.(time.Time); ok {
+				_ = binary.Write(&values, binary.LittleEndian, ts.UnixMilli())
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("column %s: unexpected value of type %T", c.Name, v)
+		}
+	}
+
+	levelData := bitPackedLevels(levels)
+	page := make([]byte, 0, 4+len(levelData)+values.Len())
+	page = binary.LittleEndian.AppendUint32(page, uint32(len(levelData)))
+	page = append(page, levelData...)
+	return append(page, values.Bytes()...), nil
+}
+
+// bitPackedLevels encodes definition levels with a maximum of 1 as a single
+// bit-packed run of the RLE/bit-packing hybrid encoding.
+func bitPackedLevels(levels []bool) []byte {
+	groups := (len(levels) + 7) / 8
+	out := binary.AppendUvarint(nil, uint64(groups)<<1|1)
+	packed := make([]byte, groups)
+	for i, defined := range levels {
+		if defined {
+			packed[i/8] |= 1 << (i % 8)
+		}
+	}
+	return append(out, packed...)
+}
+
+func fileMetaData(t Table, chunks []columnChunk) []byte {
+	var tw thriftWriter
+	tw.beginStruct()
+	tw.i32Field(1, 1) // version
+
+	tw.listField(2, thriftStruct, len(t.Columns)+1) // schema
+	tw.beginStruct()
+	tw.binaryField(4, "schema")
+	tw.i32Field(5, int32(len(t.Columns)))
+	tw.endStruct()
+	for _, c := range t.Columns {
+		tw.beginStruct()
+		tw.i32Field(1, physicalType(c))
+		tw.i32Field(3, repetitionOptional)
+		tw.binaryField(4, c.Name)
+		switch c.Type {
+		case String:
+			tw.i32Field(6, convertedTypeUTF8)
+		case Timestamp:
+			tw.i32Field(6, convertedTypeTimestampMillis)
+		}
+		tw.endStruct()
+	}
+
+	tw.i64Field(3, int64(len(t.Rows)))
+
+	tw.listField(4, thriftStruct, 1) // row_groups
+	tw.beginStruct()
+	var totalSize int64
+	tw.listField(1, thriftStruct, len(chunks))
+	for _, ch := range chunks {
+		totalSize += ch.size
+		tw.beginStruct()
+		tw.i64Field(2, ch.offset) // file_offset
+		tw.structField(3)         // meta_data
+		tw.i32Field(1, physicalType(ch.column))
+		tw.listField(2, thriftI32, 2)
+		tw.i32(encodingPlain)
+		tw.i32(encodingRLE)
+		tw.listField(3, thriftBinary, 1)
+		tw.binary(ch.column.Name)
+		tw.i32Field(4, codecUncompressed)
+		tw.i64Field(5, int64(len(t.Rows)))
+		tw.i64Field(6, ch.size)
+		tw.i64Field(7, ch.size)
+		tw.i64Field(9, ch.offset) // data_page_offset
+		tw.endStruct()
+		tw.endStruct()
+	}
+	tw.i64Field(2, totalSize)
+	tw.i64Field(3, int64(len(t.Rows)))
+	tw.endStruct()
+
+	tw.binaryField(6, "extensiondb") // created_by
+	tw.endStruct()
+	return tw.buf.Bytes()
+}
+
+// Thrift compact protocol type IDs.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter writes the subset of the Thrift compact protocol that Parquet
+// metadata needs.
+type thriftWriter struct {
+	buf bytes.Buffer
+
+	// lastField holds the ID of the last field written to each open struct.
+	lastField []int16
+}
+
+func (tw *thriftWriter) beginStruct() {
+	tw.lastField = append(tw.lastField, 0)
+}
+
+func (tw *thriftWriter) endStruct() {
+	tw.buf.WriteByte(0) // stop
+	tw.lastField = tw.lastField[:len(tw.lastField)-1]
+}
+
+func (tw *thriftWriter) fieldHeader(id int16, typ byte) {
+	last := &tw.lastField[len(tw.lastField)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		tw.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		tw.buf.WriteByte(typ)
+		tw.varint(int64(id))
+	}
+	*last = id
+}
+
+func (tw *thriftWriter) varint(v int64) {
+	tw.buf.Write(binary.AppendVarint(nil, v))
+}
+
+func (tw *thriftWriter) i32(v int32) {
+	tw.varint(int64(v))
+}
+
+func (tw *thriftWriter) binary(s string) {
+	tw.buf.Write(binary.AppendUvarint(nil, uint64(len(s))))
+	tw.buf.WriteString(s)
+}
+
+func (tw *thriftWriter) i32Field(id int16, v int32) {
+	tw.fieldHeader(id, thriftI32)
+	tw.i32(v)
+}
+
+func (tw *thriftWriter) i64Field(id int16, v int64) {
+	tw.fieldHeader(id, thriftI64)
+	tw.varint(v)
+}
+
+func (tw *thriftWriter) binaryField(id int16, s string) {
+	tw.fieldHeader(id, thriftBinary)
+	tw.binary(s)
+}
+
+// structField writes the header of a struct field, whose fields follow and
+// are terminated by endStruct.
+func (tw *thriftWriter) structField(id int16) {
+	tw.fieldHeader(id, thriftStruct)
+	tw.beginStruct()
+}
+
+// listField writes the header of a list field, whose n elements follow.
+func (tw *thriftWriter) listField(id int16, elemType byte, n int) {
+	tw.fieldHeader(id, thriftList)
+	if n < 15 {
+		tw.buf.WriteByte(byte(n)<<4 | elemType)
+	} else {
+		tw.buf.WriteByte(0xf0 | elemType)
+		tw.buf.Write(binary.AppendUvarint(nil, uint64(n)))
+	}
+}
@@ -0,0 +1,97 @@
+// Package analytics exports the database as flat tables for loading into BI
+// tools and dataframes.
+package analytics
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// ColumnType is the type of the values of a column.
+type ColumnType int
+
+const (
+	// String columns hold string values.
+	String ColumnType = iota
+	// Int64 columns hold int64 values.
+	Int64
+	// Float64 columns hold float64 values.
+	Float64
+	// Timestamp columns hold time.Time values.
+	Timestamp
+)
+
+// Column describes a column of a table.
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// Table is a flat table. Each row holds one value per column, of the
+// column's type, or nil for a null value.
+type Table struct {
+	Name    string
+	Columns []Column
+	Rows    [][]any
+}
+
+func (t *Table) append(row ...any) {
+	t.Rows = append(t.Rows, row)
+}
+
+// WriteCSV writes t as CSV with a header row. Null values are written as empty
+// fields and timestamps in RFC 3339 format.
+func WriteCSV(w io.Writer, t Table) error {
+	cw := csv.NewWriter(w)
+	header := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		header[i] = c.Name
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(t.Columns))
+	for _, row := range t.Rows {
+		for i, v := range row {
+			s, err := formatCSV(t.Columns[i], v)
+			if err != nil {
+				return fmt.Errorf("table %s: %w", t.Name, err)
+			}
+			record[i] = s
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatCSV(c Column, v any) (string, error) {
+	if v == nil {
+		return "", nil
+	}
+	switch c.Type {
+	case String:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case Int64:
+		if i, ok := v.(int64); ok {
+			return strconv.FormatInt(i, 10), nil
+		}
+	case Float64:
+		if f, ok := v.(float64); ok {
+			return strconv.FormatFloat(f, 'g', -1, 64), nil
+		}
+	case Timestamp:
+		if t, ok := v.(time.Time); ok {
+			return t.UTC().Format(time.RFC3339), nil
+		}
+	}
+	return "", fmt.Errorf("column %s: unexpected value of type %T", c.Name, v)
+}
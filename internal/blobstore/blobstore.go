@@ -0,0 +1,197 @@
+// Package blobstore stores large, immutable blobs, such as the
+// ClusterServiceVersions of bundles, outside of the tables that refer to
+// them, so that they don't bloat the database. Blobs are addressed by the
+// digest of their content, which the referring tables record, so storing the
+// same blob twice stores it once.
+package blobstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/joelanford/extensiondb/internal/s3"
+	"github.com/opencontainers/go-digest"
+)
+
+// ErrNotFound is returned by Store.Get for digests that have no blob.
+var ErrNotFound = errors.New("blob not found")
+
+// Store stores blobs by the digest of their content.
+type Store interface {
+	// Put stores data, unless a blob with the same digest is already stored,
+	// and returns its digest.
+	Put(ctx context.Context, data []byte) (digest.Digest, error)
+
+	// Get returns the blob with digest d, or an error wrapping ErrNotFound if
+	// there is none.
+	Get(ctx context.Context, d digest.Digest) ([]byte, error)
+}
+
+// Open opens the store identified by a URL: postgres: for large objects in
+// db, file:///<dir> for files in a local directory, or s3://<bucket>/<prefix>
+// for objects in an S3 bucket. S3 buckets are configured by the environment
+// variables that s3.NewBucketFromEnv reads.
+func Open(rawURL string, db *sql.DB) (Store, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid blob store URL %q: %w", rawURL, err)
+	}
+	switch u.Scheme {
+	case "postgres":
+		return NewLargeObjects(db), nil
+	case "file":
+		return Dir(u.Path), nil
+	case "s3":
+		b, err := s3.NewBucketFromEnv(u.Host)
+		if err != nil {
+			return nil, err
+		}
+		return &Bucket{bucket: b, prefix: strings.Trim(u.Path, "/")}, nil
+	}
+	return nil, fmt.Errorf("unsupported blob store URL scheme %q; expected postgres, file, or s3", u.Scheme)
+}
+
+// LargeObjects stores blobs as PostgreSQL large objects, which are kept apart
+// from table rows and read in chunks, and records their object IDs in the
+// blobs table.
+type LargeObjects struct {
+	db *sql.DB
+}
+
+// NewLargeObjects returns a store of large objects in db.
+func NewLargeObjects(db *sql.DB) *LargeObjects {
+	return &LargeObjects{db: db}
+}
+
+func (s *LargeObjects) Put(ctx context.Context, data []byte) (digest.Digest, error) {
+	d := digest.FromBytes(data)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("error starting transaction: %w", err)
+	}
+	if err := func() error {
+		// Serialize puts of the same blob, so that concurrent puts don't
+		// create large objects that no row refers to.
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, d); err != nil {
+			return fmt.Errorf("error locking blob %s: %w", d, err)
+		}
+		if _, err := tx.ExecContext(ctx, `
+    INSERT INTO blobs (digest, oid, size)
+    SELECT $1, lo_from_bytea(0, $2), $3
+    WHERE NOT EXISTS (SELECT 1 FROM blobs WHERE digest = $1);`, d, data, len(data)); err != nil {
+			return fmt.Errorf("error inserting blob %s: %w", d, err)
+		}
+		return nil
+	}(); err != nil {
+		return "", errors.Join(err, tx.Rollback())
+	}
+	return d, tx.Commit()
+}
+
+func (s *LargeObjects) Get(ctx context.Context, d digest.Digest) ([]byte, error) {
+	var data []byte
+	err := s.db.QueryRowContext(ctx, `SELECT lo_get(oid) FROM blobs WHERE digest = $1`, d).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, d)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting blob %s: %w", d, err)
+	}
+	return data, verify(d, data)
+}
+
+// Dir stores blobs as files in a local directory, at
+// <dir>/<algorithm>/<encoded digest>.
+type Dir string
+
+func (dir Dir) Put(_ context.Context, data []byte) (digest.Digest, error) {
+	d := digest.FromBytes(data)
+	p := dir.path(d)
+	if _, err := os.Stat(p); err == nil {
+		return d, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return "", err
+	}
+	// Write to a temporary file and rename it, so that readers never see a
+	// partially written blob.
+	f, err := os.CreateTemp(filepath.Dir(p), ".tmp-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		return "", errors.Join(err, f.Close())
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		return "", err
+	}
+	return d, os.Rename(f.Name(), p)
+}
+
+func (dir Dir) Get(_ context.Context, d digest.Digest) ([]byte, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(dir.path(d))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, d)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, verify(d, data)
+}
+
+func (dir Dir) path(d digest.Digest) string {
+	return filepath.Join(string(dir), d.Algorithm().String(), d.Encoded())
+}
+
+// Bucket stores blobs as objects in an S3 bucket, with keys of the form
+// <prefix>/<algorithm>/<encoded digest>.
+type Bucket struct {
+	bucket *s3.Bucket
+	prefix string
+}
+
+func (b *Bucket) Put(ctx context.Context, data []byte) (digest.Digest, error) {
+	d := digest.FromBytes(data)
+	return d, b.bucket.Put(ctx, b.key(d), data, "application/octet-stream")
+}
+
+func (b *Bucket) Get(ctx context.Context, d digest.Digest) ([]byte, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	data, err := b.bucket.Get(ctx, b.key(d))
+	if errors.Is(err, s3.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, d)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, verify(d, data)
+}
+
+func (b *Bucket) key(d digest.Digest) string {
+	return path.Join(b.prefix, d.Algorithm().String(), d.Encoded())
+}
+
+// verify returns an error if data doesn't have digest d, so that blobs that
+// were modified or corrupted where they are stored are never returned.
+func verify(d digest.Digest, data []byte) error {
+	if actual := d.Algorithm().FromBytes(data); actual != d {
+		return fmt.Errorf("blob %s has digest %s", d, actual)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package blobstore_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joelanford/extensiondb/internal/blobstore"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDir(t *testing.T) {
+	dir := t.TempDir()
+	store, err := blobstore.Open("file://"+dir, nil)
+	require.NoError(t, err)
+
+	data := []byte(`{"kind":"ClusterServiceVersion"}`)
+	d, err := store.Put(t.Context(), data)
+	require.NoError(t, err)
+	assert.Equal(t, digest.FromBytes(data), d)
+	assert.FileExists(t, filepath.Join(dir, "sha256", d.Encoded()))
+
+	// Storing the same blob again is a no-op.
+	again, err := store.Put(t.Context(), data)
+	require.NoError(t, err)
+	assert.Equal(t, d, again)
+
+	got, err := store.Get(t.Context(), d)
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+
+	_, err = store.Get(t.Context(), digest.FromString("missing"))
+	assert.ErrorIs(t, err, blobstore.ErrNotFound)
+
+	_, err = store.Get(t.Context(), "sha256:../../etc/passwd")
+	assert.Error(t, err, "invalid digests are never read")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sha256", d.Encoded()), []byte("corrupted"), 0o644))
+	_, err = store.Get(t.Context(), d)
+	assert.ErrorContains(t, err, "has digest")
+}
+
+func TestOpen(t *testing.T) {
+	store, err := blobstore.Open("postgres:", nil)
+	require.NoError(t, err)
+	assert.IsType(t, &blobstore.LargeObjects{}, store)
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	_, err = blobstore.Open("s3://blobs/extensiondb", nil)
+	assert.ErrorContains(t, err, "AWS_ACCESS_KEY_ID")
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "id")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	store, err = blobstore.Open("s3://blobs/extensiondb", nil)
+	require.NoError(t, err)
+	assert.IsType(t, &blobstore.Bucket{}, store)
+
+	_, err = blobstore.Open("gs://blobs", nil)
+	assert.ErrorContains(t, err, "unsupported blob store URL scheme")
+}
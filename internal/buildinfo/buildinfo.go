@@ -0,0 +1,170 @@
+// Package buildinfo links bundles to the records of the builds that produced
+// them in build systems such as Brew and Konflux.
+package buildinfo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/joelanford/extensiondb/internal/models"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/opencontainers/go-digest"
+	"sigs.k8s.io/yaml"
+)
+
+// Record is a build-system record of a bundle image build.
+type Record struct {
+	// System is the build system, such as "brew" or "konflux".
+	System  string `json:"system"`
+	BuildID string `json:"buildID"`
+
+	// Digest and NVR identify the bundle image that the record is for. A
+	// record with a digest matches the image with that manifest digest. A
+	// record with an NVR matches images whose com.redhat.component, version,
+	// and release labels form that name-version-release.
+	Digest digest.Digest `json:"digest,omitempty"`
+	NVR    string        `json:"nvr,omitempty"`
+
+	SourceRepository string `json:"sourceRepository,omitempty"`
+	SourceCommit     string `json:"sourceCommit,omitempty"`
+	URL              string `json:"url,omitempty"`
+
+	// Errata are the advisories that shipped the image.
+	Errata []string `json:"errata,omitempty"`
+}
+
+// Records is an index of build records.
+type Records struct {
+	byDigest map[digest.Digest]Record
+	byNVR    map[string]Record
+}
+
+// NewRecords indexes records by digest and NVR.
+func NewRecords(records []Record) (*Records, error) {
+	r := &Records{
+		byDigest: map[digest.Digest]Record{},
+		byNVR:    map[string]Record{},
+	}
+	var errs []error
+	for i, rec := range records {
+		if rec.System == "" || rec.BuildID == "" {
+			errs = append(errs, fmt.Errorf("record %d: system and buildID are required", i))
+			continue
+		}
+		if rec.Digest == "" && rec.NVR == "" {
+			errs = append(errs, fmt.Errorf("record %d (%s build %s): one of digest or nvr is required", i, rec.System, rec.BuildID))
+			continue
+		}
+		if rec.Digest != "" {
+			if err := rec.Digest.Validate(); err != nil {
+				errs = append(errs, fmt.Errorf("record %d (%s build %s): invalid digest: %w", i, rec.System, rec.BuildID, err))
+				continue
+			}
+			r.byDigest[rec.Digest] = rec
+		}
+		if rec.NVR != "" {
+			r.byNVR[rec.NVR] = rec
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return r, nil
+}
+
+// ReadRecordsFile reads a YAML or JSON list of records.
+func ReadRecordsFile(path string) (*Records, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var records []Record
+	if err := yaml.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("error parsing build records file %q: %w", path, err)
+	}
+	r, err := NewRecords(records)
+	if err != nil {
+		return nil, fmt.Errorf("build records file %q: %w", path, err)
+	}
+	return r, nil
+}
+
+// Lookup returns the record of the bundle image with the given manifest
+// digest and labels, preferring a match by digest. Source fields that the
+// record leaves empty are filled from the image's labels.
+func (r *Records) Lookup(dgst digest.Digest, labels map[string]string) (Record, bool) {
+	rec, ok := r.byDigest[dgst]
+	if !ok {
+		rec, ok = r.byNVR[NVR(labels)]
+	}
+	if !ok {
+		return Record{}, false
+	}
+	if rec.SourceRepository == "" {
+		rec.SourceRepository = firstLabel(labels, "org.opencontainers.image.source", "vcs-url")
+	}
+	if rec.SourceCommit == "" {
+		rec.SourceCommit = firstLabel(labels, "org.opencontainers.image.revision", "vcs-ref")
+	}
+	return rec, true
+}
+
+// NVR returns the name-version-release of an image built by Brew or
+// Konflux, from its labels. It returns "" if any of the labels is missing.
+func NVR(labels map[string]string) string {
+	name, version, release := labels["com.redhat.component"], labels["version"], labels["release"]
+	if name == "" || version == "" || release == "" {
+		return ""
+	}
+	return name + "-" + version + "-" + release
+}
+
+func firstLabel(labels map[string]string, keys ...string) string {
+	for _, k := range keys {
+		if v := labels[k]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Plugin is an ingest plugin that links bundles to their build records.
+type Plugin struct {
+	Records *Records
+}
+
+// Name implements ingest.Plugin.
+func (Plugin) Name() string {
+	return "buildinfo"
+}
+
+// IngestBundle implements ingest.Plugin. Bundles without a matching record
+// are left unlinked.
+func (p Plugin) IngestBundle(ctx context.Context, q *query.Query, b *models.Bundle) error {
+	if b.Descriptor.V == nil {
+		return nil
+	}
+	var labels map[string]string
+	if b.Image.V != nil {
+		labels = b.Image.V.Config.Labels
+	}
+	rec, ok := p.Records.Lookup(b.Descriptor.V.Digest, labels)
+	if !ok {
+		return nil
+	}
+	return q.SetBundleBuild(ctx, &models.BundleBuild{
+		BundleID:         b.ID,
+		System:           rec.System,
+		BuildID:          rec.BuildID,
+		SourceRepository: nullString(rec.SourceRepository),
+		SourceCommit:     nullString(rec.SourceCommit),
+		URL:              nullString(rec.URL),
+	}, rec.Errata)
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
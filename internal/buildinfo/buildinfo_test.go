@@ -0,0 +1,108 @@
+package buildinfo_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/buildinfo"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/internal/query/querytest"
+	"github.com/joelanford/extensiondb/pkg/dbtest"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(dbtest.Main(m))
+}
+
+var nvrLabels = map[string]string{
+	"com.redhat.component": "foo-operator-bundle-container",
+	"version":              "v1.0.0",
+	"release":              "3",
+	"vcs-ref":              "0123abc",
+}
+
+func TestLookup(t *testing.T) {
+	byDigest := digest.FromString("by-digest")
+	r, err := buildinfo.NewRecords([]buildinfo.Record{
+		{System: "konflux", BuildID: "foo-on-push-abcde", Digest: byDigest, SourceCommit: "fedcba9"},
+		{System: "brew", BuildID: "1234", NVR: "foo-operator-bundle-container-v1.0.0-3", Errata: []string{"RHBA-2024:0001"}},
+	})
+	require.NoError(t, err)
+
+	rec, ok := r.Lookup(byDigest, nvrLabels)
+	require.True(t, ok)
+	assert.Equal(t, "konflux", rec.System, "digests take precedence over NVRs")
+	assert.Equal(t, "fedcba9", rec.SourceCommit, "records take precedence over labels")
+
+	rec, ok = r.Lookup(digest.FromString("other"), nvrLabels)
+	require.True(t, ok)
+	assert.Equal(t, "brew", rec.System)
+	assert.Equal(t, "0123abc", rec.SourceCommit)
+
+	_, ok = r.Lookup(digest.FromString("other"), nil)
+	assert.False(t, ok)
+}
+
+func TestNewRecordsInvalid(t *testing.T) {
+	for name, rec := range map[string]buildinfo.Record{
+		"no system":   {BuildID: "1", NVR: "foo-1-1"},
+		"no build ID": {System: "brew", NVR: "foo-1-1"},
+		"no key":      {System: "brew", BuildID: "1"},
+		"bad digest":  {System: "brew", BuildID: "1", Digest: "sha256:bad"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := buildinfo.NewRecords([]buildinfo.Record{rec})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestReadRecordsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "builds.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+- system: brew
+  buildID: "1234"
+  nvr: foo-operator-bundle-container-v1.0.0-3
+  errata: [RHBA-2024:0001]
+`), 0o600))
+	r, err := buildinfo.ReadRecordsFile(path)
+	require.NoError(t, err)
+	rec, ok := r.Lookup("", nvrLabels)
+	require.True(t, ok)
+	assert.Equal(t, []string{"RHBA-2024:0001"}, rec.Errata)
+}
+
+func TestPlugin(t *testing.T) {
+	db := dbtest.New(t)
+	q := query.New(db)
+	b := querytest.Bundle(t, db, "foo", "1.0.0", time.Now())
+	b.Image.V.Config.Labels = nvrLabels
+
+	records, err := buildinfo.NewRecords([]buildinfo.Record{
+		{System: "brew", BuildID: "1234", NVR: "foo-operator-bundle-container-v1.0.0-3", URL: "https://brew.example.com/buildinfo?buildID=1234", Errata: []string{"RHBA-2024:0002", "RHBA-2024:0001"}},
+	})
+	require.NoError(t, err)
+	p := buildinfo.Plugin{Records: records}
+	require.NoError(t, p.IngestBundle(t.Context(), q, b))
+	require.NoError(t, p.IngestBundle(t.Context(), q, b), "ingestion is idempotent")
+
+	bb, errata, err := q.GetBundleBuildByDigest(t.Context(), querytest.BundleImage("foo", "1.0.0").Digest())
+	require.NoError(t, err)
+	assert.Equal(t, b.ID, bb.BundleID)
+	assert.Equal(t, "brew", bb.System)
+	assert.Equal(t, "1234", bb.BuildID)
+	assert.Equal(t, "0123abc", bb.SourceCommit.String)
+	assert.False(t, bb.SourceRepository.Valid)
+	assert.Equal(t, "https://brew.example.com/buildinfo?buildID=1234", bb.URL.String)
+	assert.Equal(t, []string{"RHBA-2024:0001", "RHBA-2024:0002"}, errata)
+
+	other := querytest.Bundle(t, db, "foo", "1.0.1", time.Now())
+	require.NoError(t, p.IngestBundle(t.Context(), q, other))
+	_, _, err = q.GetBundleBuildByDigest(t.Context(), querytest.BundleImage("foo", "1.0.1").Digest())
+	assert.Error(t, err, "bundles without records are not linked")
+}
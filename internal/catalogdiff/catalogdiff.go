@@ -0,0 +1,226 @@
+// Package catalogdiff compares the contents of two catalogs, such as two
+// OpenShift versions of the same index.
+package catalogdiff
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/internal/models"
+	"github.com/joelanford/extensiondb/internal/query"
+)
+
+// Diff is the difference between the most recently ingested digests of two
+// catalogs.
+type Diff struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	PackagesAdded   []string `json:"packagesAdded"`
+	PackagesRemoved []string `json:"packagesRemoved"`
+
+	// BundlesAdded and BundlesRemoved include the bundles of added and
+	// removed packages.
+	BundlesAdded   []Bundle `json:"bundlesAdded"`
+	BundlesRemoved []Bundle `json:"bundlesRemoved"`
+
+	// DefaultChannelChanges lists the packages in both catalogs whose default
+	// channel changed. Packages whose default channel was not recorded in
+	// either catalog are not compared.
+	DefaultChannelChanges []DefaultChannelChange `json:"defaultChannelChanges"`
+}
+
+// Bundle is a bundle that was added to or removed from a catalog.
+type Bundle struct {
+	Package string `json:"package"`
+	Version string `json:"version"`
+	Image   string `json:"image"`
+}
+
+// DefaultChannelChange is a change of a package's default channel.
+type DefaultChannelChange struct {
+	Package string `json:"package"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+}
+
+// Catalog is the contents of a catalog to compare.
+type Catalog struct {
+	// Name identifies the catalog in the diff, such as <name>:<tag>.
+	Name            string
+	Bundles         []query.CatalogBundle
+	DefaultChannels map[string]string
+}
+
+// Compute reads the contents of both catalogs and compares them.
+func Compute(ctx context.Context, q *query.Query, from, to *models.Catalog) (*Diff, error) {
+	fromContents, err := read(ctx, q, from)
+	if err != nil {
+		return nil, err
+	}
+	toContents, err := read(ctx, q, to)
+	if err != nil {
+		return nil, err
+	}
+	return Compare(fromContents, toContents), nil
+}
+
+func read(ctx context.Context, q *query.Query, c *models.Catalog) (Catalog, error) {
+	name := c.Name + ":" + c.Tag
+	bundles, err := q.GetBundlesInCatalog(ctx, c, nil)
+	if err != nil {
+		return Catalog{}, fmt.Errorf("error getting bundles in catalog %s: %w", name, err)
+	}
+	channels, err := q.GetDefaultChannelsInCatalog(ctx, c)
+	if err != nil {
+		return Catalog{}, fmt.Errorf("error getting default channels in catalog %s: %w", name, err)
+	}
+	return Catalog{Name: name, Bundles: bundles, DefaultChannels: channels}, nil
+}
+
+// Compare compares two catalogs. Bundles are identified by the image
+// reference that the catalogs use for them.
+func Compare(from, to Catalog) *Diff {
+	d := &Diff{
+		From:                  from.Name,
+		To:                    to.Name,
+		PackagesAdded:         []string{},
+		PackagesRemoved:       []string{},
+		BundlesAdded:          []Bundle{},
+		BundlesRemoved:        []Bundle{},
+		DefaultChannelChanges: []DefaultChannelChange{},
+	}
+
+	fromBundles, fromPackages := index(from.Bundles)
+	toBundles, toPackages := index(to.Bundles)
+	for image, b := range toBundles {
+		if _, ok := fromBundles[image]; !ok {
+			d.BundlesAdded = append(d.BundlesAdded, b)
+		}
+	}
+	for image, b := range fromBundles {
+		if _, ok := toBundles[image]; !ok {
+			d.BundlesRemoved = append(d.BundlesRemoved, b)
+		}
+	}
+	for pkgName := range toPackages {
+		if _, ok := fromPackages[pkgName]; !ok {
+			d.PackagesAdded = append(d.PackagesAdded, pkgName)
+		}
+	}
+	for pkgName := range fromPackages {
+		if _, ok := toPackages[pkgName]; !ok {
+			d.PackagesRemoved = append(d.PackagesRemoved, pkgName)
+		}
+	}
+	for pkgName, toChannel := range to.DefaultChannels {
+		fromChannel, ok := from.DefaultChannels[pkgName]
+		if ok && fromChannel != toChannel {
+			d.DefaultChannelChanges = append(d.DefaultChannelChanges, DefaultChannelChange{Package: pkgName, From: fromChannel, To: toChannel})
+		}
+	}
+
+	slices.Sort(d.PackagesAdded)
+	slices.Sort(d.PackagesRemoved)
+	slices.SortFunc(d.BundlesAdded, compareBundles)
+	slices.SortFunc(d.BundlesRemoved, compareBundles)
+	slices.SortFunc(d.DefaultChannelChanges, func(a, b DefaultChannelChange) int {
+		return cmp.Compare(a.Package, b.Package)
+	})
+	return d
+}
+
+func index(cbs []query.CatalogBundle) (map[string]Bundle, map[string]struct{}) {
+	bundles := make(map[string]Bundle, len(cbs))
+	packages := map[string]struct{}{}
+	for _, cb := range cbs {
+		bundles[cb.Image] = Bundle{Package: cb.PackageName, Version: cb.Bundle.Version, Image: cb.Image}
+		packages[cb.PackageName] = struct{}{}
+	}
+	return bundles, packages
+}
+
+// compareBundles orders bundles by package, then by version, then by image.
+func compareBundles(a, b Bundle) int {
+	if v := cmp.Compare(a.Package, b.Package); v != 0 {
+		return v
+	}
+	av, aErr := semver.Parse(a.Version)
+	bv, bErr := semver.Parse(b.Version)
+	if aErr == nil && bErr == nil {
+		if v := av.Compare(bv); v != 0 {
+			return v
+		}
+	} else if v := cmp.Compare(a.Version, b.Version); v != 0 {
+		return v
+	}
+	return cmp.Compare(a.Image, b.Image)
+}
+
+// Formats describes the formats supported by Write, for use in flag help.
+const Formats = "json or markdown"
+
+// Write writes d to w in format, which is one of Formats.
+func (d *Diff) Write(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	case "markdown":
+		return d.WriteMarkdown(w)
+	}
+	return fmt.Errorf("unknown format %q; expected %s", format, Formats)
+}
+
+// WriteMarkdown writes d as a Markdown report.
+func (d *Diff) WriteMarkdown(w io.Writer) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Catalog diff: %s → %s\n", d.From, d.To)
+
+	writeList := func(title string, items []string) {
+		fmt.Fprintf(&sb, "\n## %s (%d)\n\n", title, len(items))
+		if len(items) == 0 {
+			sb.WriteString("None.\n")
+			return
+		}
+		for _, item := range items {
+			fmt.Fprintf(&sb, "- %s\n", item)
+		}
+	}
+	writeBundles := func(title string, bundles []Bundle) {
+		fmt.Fprintf(&sb, "\n## %s (%d)\n\n", title, len(bundles))
+		if len(bundles) == 0 {
+			sb.WriteString("None.\n")
+			return
+		}
+		sb.WriteString("| Package | Version | Image |\n| --- | --- | --- |\n")
+		for _, b := range bundles {
+			fmt.Fprintf(&sb, "| %s | %s | `%s` |\n", b.Package, b.Version, b.Image)
+		}
+	}
+
+	writeList("Packages added", d.PackagesAdded)
+	writeList("Packages removed", d.PackagesRemoved)
+	writeBundles("Bundles added", d.BundlesAdded)
+	writeBundles("Bundles removed", d.BundlesRemoved)
+
+	fmt.Fprintf(&sb, "\n## Default channel changes (%d)\n\n", len(d.DefaultChannelChanges))
+	if len(d.DefaultChannelChanges) == 0 {
+		sb.WriteString("None.\n")
+	} else {
+		sb.WriteString("| Package | From | To |\n| --- | --- | --- |\n")
+		for _, c := range d.DefaultChannelChanges {
+			fmt.Fprintf(&sb, "| %s | %s | %s |\n", c.Package, c.From, c.To)
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,133 @@
+package catalogdiff_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/catalogdiff"
+	"github.com/joelanford/extensiondb/internal/models"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/internal/query/querytest"
+	"github.com/joelanford/extensiondb/pkg/dbtest"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(dbtest.Main(m))
+}
+
+func catalogBundle(pkgName, version string) query.CatalogBundle {
+	return query.CatalogBundle{
+		PackageName: pkgName,
+		Bundle:      &models.Bundle{Version: version},
+		Image:       "quay.io/example/" + pkgName + "-bundle@" + digest.FromString(pkgName+version).String(),
+	}
+}
+
+func TestCompare(t *testing.T) {
+	from := catalogdiff.Catalog{
+		Name: "index:v4.18",
+		Bundles: []query.CatalogBundle{
+			catalogBundle("foo", "1.0.0"),
+			catalogBundle("foo", "1.1.0"),
+			catalogBundle("bar", "0.1.0"),
+			catalogBundle("baz", "2.0.0"),
+		},
+		DefaultChannels: map[string]string{"foo": "stable-1.0", "bar": "alpha"},
+	}
+	to := catalogdiff.Catalog{
+		Name: "index:v4.19",
+		Bundles: []query.CatalogBundle{
+			catalogBundle("foo", "1.1.0"),
+			catalogBundle("foo", "1.10.0"),
+			catalogBundle("foo", "1.2.0"),
+			catalogBundle("baz", "2.0.0"),
+			catalogBundle("qux", "0.0.1"),
+		},
+		DefaultChannels: map[string]string{"foo": "stable-1.1", "baz": "stable", "qux": "stable"},
+	}
+
+	d := catalogdiff.Compare(from, to)
+	assert.Equal(t, "index:v4.18", d.From)
+	assert.Equal(t, "index:v4.19", d.To)
+	assert.Equal(t, []string{"qux"}, d.PackagesAdded)
+	assert.Equal(t, []string{"bar"}, d.PackagesRemoved)
+
+	versions := func(bundles []catalogdiff.Bundle) []string {
+		var vs []string
+		for _, b := range bundles {
+			vs = append(vs, b.Package+"@"+b.Version)
+		}
+		return vs
+	}
+	assert.Equal(t, []string{"foo@1.2.0", "foo@1.10.0", "qux@0.0.1"}, versions(d.BundlesAdded), "bundles are ordered by semver")
+	assert.Equal(t, []string{"bar@0.1.0", "foo@1.0.0"}, versions(d.BundlesRemoved))
+	assert.Equal(t, []catalogdiff.DefaultChannelChange{{Package: "foo", From: "stable-1.0", To: "stable-1.1"}}, d.DefaultChannelChanges,
+		"packages without a recorded default channel in both catalogs are not compared")
+}
+
+func TestWrite(t *testing.T) {
+	d := catalogdiff.Compare(
+		catalogdiff.Catalog{Name: "index:v4.18", DefaultChannels: map[string]string{"foo": "stable-1.0"}},
+		catalogdiff.Catalog{
+			Name:            "index:v4.19",
+			Bundles:         []query.CatalogBundle{catalogBundle("foo", "1.1.0")},
+			DefaultChannels: map[string]string{"foo": "stable-1.1"},
+		},
+	)
+
+	var buf bytes.Buffer
+	require.NoError(t, d.Write(&buf, "markdown"))
+	md := buf.String()
+	assert.True(t, strings.HasPrefix(md, "# Catalog diff: index:v4.18 → index:v4.19\n"))
+	assert.Contains(t, md, "## Packages added (1)\n\n- foo\n")
+	assert.Contains(t, md, "## Packages removed (0)\n\nNone.\n")
+	assert.Contains(t, md, "| foo | 1.1.0 | `"+catalogBundle("foo", "1.1.0").Image+"` |\n")
+	assert.Contains(t, md, "| foo | stable-1.0 | stable-1.1 |\n")
+
+	buf.Reset()
+	require.NoError(t, d.Write(&buf, "json"))
+	var got catalogdiff.Diff
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(t, *d, got)
+	assert.Contains(t, buf.String(), `"bundlesRemoved": []`, "empty lists are written as arrays")
+
+	assert.Error(t, d.Write(&buf, "yaml"))
+}
+
+func TestCompute(t *testing.T) {
+	db := dbtest.New(t)
+	q := query.New(db)
+	built := time.Now()
+	querytest.Bundle(t, db, "foo", "1.0.0", built)
+	querytest.Bundle(t, db, "foo", "1.1.0", built)
+
+	catalog := func(tag string, defaultChannel string, images ...reference.Canonical) *models.Catalog {
+		c := querytest.Catalog(t, db, "index", tag, images...)
+		refs := make([]string, 0, len(images))
+		for _, image := range images {
+			refs = append(refs, image.String())
+		}
+		cd, err := q.GetOrCreateCatalogDigest(t.Context(), c, digest.FromString(strings.Join(refs, ",")).String())
+		require.NoError(t, err)
+		require.NoError(t, q.SetCatalogDigestDefaultChannels(t.Context(), cd, map[string]string{"foo": defaultChannel}))
+		return c
+	}
+	from := catalog("v4.18", "stable-1.0", querytest.BundleImage("foo", "1.0.0"))
+	to := catalog("v4.19", "stable-1.1", querytest.BundleImage("foo", "1.0.0"), querytest.BundleImage("foo", "1.1.0"))
+
+	d, err := catalogdiff.Compute(t.Context(), q, from, to)
+	require.NoError(t, err)
+	assert.Equal(t, "index:v4.18", d.From)
+	assert.Empty(t, d.PackagesAdded)
+	assert.Equal(t, []catalogdiff.Bundle{{Package: "foo", Version: "1.1.0", Image: querytest.BundleImage("foo", "1.1.0").String()}}, d.BundlesAdded)
+	assert.Empty(t, d.BundlesRemoved)
+	assert.Equal(t, []catalogdiff.DefaultChannelChange{{Package: "foo", From: "stable-1.0", To: "stable-1.1"}}, d.DefaultChannelChanges)
+}
@@ -0,0 +1,187 @@
+// Package compat reports which versions of a package are supported on which
+// OpenShift versions.
+package compat
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/joelanford/extensiondb/pkg/util"
+	"github.com/opencontainers/go-digest"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Status is the compatibility of a package version with a platform version.
+type Status string
+
+const (
+	// StatusSupported means that the version stream of the package version
+	// is supported on the platform version.
+	StatusSupported Status = "supported"
+
+	// StatusFunctional means that the package version works on the platform
+	// version, but must be updated before the platform is updated further.
+	StatusFunctional Status = "functional"
+
+	// StatusBlocked means that the package version would otherwise work on
+	// the platform version, but its maxOpenShiftVersion property blocks
+	// platform updates to it.
+	StatusBlocked Status = "blocked"
+
+	// StatusUnsupported means that the package version does not work on the
+	// platform version.
+	StatusUnsupported Status = "unsupported"
+)
+
+// Matrix is the compatibility of each version of a package with each platform
+// version that any of its version streams names.
+type Matrix struct {
+	Package   string             `json:"package"`
+	Platforms []graph.MajorMinor `json:"platforms"`
+
+	// Rows are ordered from the newest version to the oldest.
+	Rows []Row `json:"rows"`
+}
+
+// Row is the compatibility of a package version with each platform version
+// of its matrix.
+type Row struct {
+	Version             string `json:"version"`
+	LifecyclePhase      string `json:"lifecyclePhase"`
+	MaxOpenShiftVersion string `json:"maxOpenShiftVersion,omitempty"`
+
+	// Statuses has one status for each platform of the matrix, in the same
+	// order.
+	Statuses []Status `json:"statuses"`
+}
+
+// Build builds the compatibility matrix of the nodes of pkg in g.
+// maxOpenShiftVersions holds the maxOpenShiftVersion property of the package's
+// bundles, keyed by bundle digest; bundles without a valid property are not
+// blocked on any platform version.
+func Build(g *graph.Graph, pkg string, maxOpenShiftVersions map[digest.Digest]string) Matrix {
+	nodes := slices.SortedFunc(g.NodesMatching(graph.PackageNodes(pkg)), util.Compare)
+	slices.Reverse(nodes)
+
+	platforms := sets.New[graph.MajorMinor]()
+	for _, n := range nodes {
+		platforms.Insert(n.SupportedPlatformVersions.UnsortedList()...)
+		platforms.Insert(n.RequiresUpdatePlatformVersions.UnsortedList()...)
+	}
+
+	m := Matrix{
+		Package:   pkg,
+		Platforms: slices.SortedFunc(maps.Keys(platforms), util.Compare),
+		Rows:      make([]Row, 0, len(nodes)),
+	}
+	for _, n := range nodes {
+		row := Row{
+			Version:        n.VR(),
+			LifecyclePhase: n.LifecyclePhase.String(),
+			Statuses:       make([]Status, len(m.Platforms)),
+		}
+		var (
+			maxPlatform graph.MajorMinor
+			hasMax      bool
+		)
+		if n.ImageReference != nil {
+			row.MaxOpenShiftVersion = maxOpenShiftVersions[n.ImageReference.Digest()]
+			mm, err := graph.NewMajorMinorFromString(row.MaxOpenShiftVersion)
+			maxPlatform, hasMax = mm, err == nil
+		}
+		for i, p := range m.Platforms {
+			switch {
+			case !n.SupportedPlatformVersions.Has(p) && !n.RequiresUpdatePlatformVersions.Has(p):
+				row.Statuses[i] = StatusUnsupported
+			case hasMax && p.Compare(maxPlatform) > 0:
+				row.Statuses[i] = StatusBlocked
+			case n.SupportedPlatformVersions.Has(p):
+				row.Statuses[i] = StatusSupported
+			default:
+				row.Statuses[i] = StatusFunctional
+			}
+		}
+		m.Rows = append(m.Rows, row)
+	}
+	return m
+}
+
+func (m Matrix) header() []string {
+	header := []string{"VERSION", "PHASE", "MAX OCP"}
+	for _, p := range m.Platforms {
+		header = append(header, p.String())
+	}
+	return header
+}
+
+func (r Row) cells() []string {
+	cells := []string{r.Version, r.LifecyclePhase, r.MaxOpenShiftVersion}
+	for _, s := range r.Statuses {
+		cells = append(cells, string(s))
+	}
+	return cells
+}
+
+// WriteTable writes m to w as an aligned text table.
+func (m Matrix) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(m.header(), "\t"))
+	for _, r := range m.Rows {
+		cells := r.cells()
+		if cells[2] == "" {
+			cells[2] = "-"
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+// WriteCSV writes m to w as CSV, with a header row.
+func (m Matrix) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(m.header()); err != nil {
+		return err
+	}
+	for _, r := range m.Rows {
+		if err := cw.Write(r.cells()); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+//go:embed heatmap.html.tmpl
+var heatmapText string
+
+var heatmapTemplate = template.Must(template.New("heatmap").Parse(heatmapText))
+
+// WriteHTML writes m to w as a standalone HTML page with a heatmap of the
+// statuses.
+func (m Matrix) WriteHTML(w io.Writer) error {
+	return heatmapTemplate.Execute(w, m)
+}
+
+// Write writes m to w in format, which is one of Formats.
+func (m Matrix) Write(w io.Writer, format string) error {
+	switch format {
+	case "table", "":
+		return m.WriteTable(w)
+	case "csv":
+		return m.WriteCSV(w)
+	case "html":
+		return m.WriteHTML(w)
+	}
+	return fmt.Errorf("unknown format %q; expected %s", format, Formats)
+}
+
+// Formats describes the formats supported by Write, for use in flag help.
+const Formats = "table, csv, or html"
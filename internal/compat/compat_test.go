@@ -0,0 +1,73 @@
+package compat_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/joelanford/extensiondb/internal/compat"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/joelanford/extensiondb/pkg/graph/graphtest"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMatrix(t *testing.T) compat.Matrix {
+	t.Helper()
+	mm := func(minor uint64) graph.MajorMinor { return graph.MajorMinor{Major: 4, Minor: minor} }
+	streams := graphtest.Streams(graph.MajorMinor{Major: 1, Minor: 0}, graph.MajorMinor{Major: 1, Minor: 1})
+	streams[0].SupportedPlatformVersions = []graph.MajorMinor{mm(14), mm(15)}
+	streams[0].RequiresUpdatePlatformVersions = []graph.MajorMinor{mm(16)}
+	streams[1].SupportedPlatformVersions = []graph.MajorMinor{mm(16), mm(17)}
+	g := graphtest.New(t, graph.Package{Name: "foo", Streams: streams, Nodes: graphtest.WithBundleImages(graphtest.Nodes("foo", "1.0.0", "1.0.1", "1.1.0"))})
+
+	return compat.Build(g, "foo", map[digest.Digest]string{
+		graphtest.Digest(0): "4.15",
+		graphtest.Digest(1): "invalid",
+	})
+}
+
+func TestBuild(t *testing.T) {
+	m := testMatrix(t)
+	assert.Equal(t, "foo", m.Package)
+	assert.Equal(t, []graph.MajorMinor{{Major: 4, Minor: 14}, {Major: 4, Minor: 15}, {Major: 4, Minor: 16}, {Major: 4, Minor: 17}}, m.Platforms)
+
+	var (
+		s = compat.StatusSupported
+		f = compat.StatusFunctional
+		b = compat.StatusBlocked
+		u = compat.StatusUnsupported
+	)
+	assert.Equal(t, []compat.Row{
+		{Version: "1.1.0", LifecyclePhase: "Full Support", Statuses: []compat.Status{u, u, s, s}},
+		{Version: "1.0.1", LifecyclePhase: "Full Support", MaxOpenShiftVersion: "invalid", Statuses: []compat.Status{s, s, f, u}},
+		{Version: "1.0.0", LifecyclePhase: "Full Support", MaxOpenShiftVersion: "4.15", Statuses: []compat.Status{s, s, b, u}},
+	}, m.Rows)
+}
+
+func TestWrite(t *testing.T) {
+	m := testMatrix(t)
+
+	var buf bytes.Buffer
+	require.NoError(t, m.Write(&buf, "csv"))
+	assert.Equal(t, `VERSION,PHASE,MAX OCP,4.14,4.15,4.16,4.17
+1.1.0,Full Support,,unsupported,unsupported,supported,supported
+1.0.1,Full Support,invalid,supported,supported,functional,unsupported
+1.0.0,Full Support,4.15,supported,supported,blocked,unsupported
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, m.Write(&buf, "table"))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 4)
+	assert.Equal(t, []string{"VERSION", "PHASE", "MAX", "OCP", "4.14", "4.15", "4.16", "4.17"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"1.1.0", "Full", "Support", "-", "unsupported", "unsupported", "supported", "supported"}, strings.Fields(lines[1]))
+
+	buf.Reset()
+	require.NoError(t, m.Write(&buf, "html"))
+	assert.Contains(t, buf.String(), "<title>foo compatibility</title>")
+	assert.Contains(t, buf.String(), `<td class="blocked" title="blocked"></td>`)
+
+	assert.Error(t, m.Write(&buf, "xml"))
+}
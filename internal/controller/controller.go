@@ -0,0 +1,199 @@
+package controller
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/blang/semver/v4"
+	apiclient "github.com/joelanford/extensiondb/pkg/client"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	AnnotationAvailableUpdates = "extensiondb.operatorframework.io/available-updates"
+	AnnotationLifecyclePhase   = "extensiondb.operatorframework.io/lifecycle-phase"
+)
+
+// ErrNotServed is returned by Reconciler.SetupWithManager when the cluster
+// doesn't serve the resources of the Reconciler's Target, such as when OLM v1
+// is not installed.
+var ErrNotServed = errors.New("resource is not served by the cluster")
+
+// Target describes a kind of installed operator resource and how to find the
+// package name and installed version in it.
+type Target struct {
+	GroupVersionKind schema.GroupVersionKind
+	PackageName      func(*unstructured.Unstructured) string
+	InstalledVersion func(*unstructured.Unstructured) string
+}
+
+// Subscriptions targets OLM v0 Subscriptions. The installed version is parsed
+// from the name of the installed ClusterServiceVersion, which conventionally
+// has the form <name>.v<version>.
+var Subscriptions = Target{
+	GroupVersionKind: schema.GroupVersionKind{Group: "operators.coreos.com", Version: "v1alpha1", Kind: "Subscription"},
+	PackageName: func(u *unstructured.Unstructured) string {
+		name, _, _ := unstructured.NestedString(u.Object, "spec", "name")
+		return name
+	},
+	InstalledVersion: func(u *unstructured.Unstructured) string {
+		csvName, _, _ := unstructured.NestedString(u.Object, "status", "installedCSV")
+		if i := strings.LastIndex(csvName, ".v"); i >= 0 {
+			return csvName[i+2:]
+		}
+		return ""
+	},
+}
+
+// ClusterExtensions targets OLM v1 ClusterExtensions.
+var ClusterExtensions = Target{
+	GroupVersionKind: schema.GroupVersionKind{Group: "olm.operatorframework.io", Version: "v1", Kind: "ClusterExtension"},
+	PackageName: func(u *unstructured.Unstructured) string {
+		name, _, _ := unstructured.NestedString(u.Object, "spec", "source", "catalog", "packageName")
+		return name
+	},
+	InstalledVersion: func(u *unstructured.Unstructured) string {
+		version, _, _ := unstructured.NestedString(u.Object, "status", "install", "bundle", "version")
+		return version
+	},
+}
+
+// Reconciler annotates installed operator resources with the updates that are
+// available from their installed version and with the lifecycle phase of that
+// version, as reported by an extensiondb server.
+type Reconciler struct {
+	client.Client
+
+	API     *apiclient.Client
+	Target  Target
+	Channel string
+
+	// ResyncPeriod is how often each resource is checked again, so that new
+	// releases and lifecycle phase changes are noticed.
+	ResyncPeriod time.Duration
+}
+
+func (r *Reconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	l := log.FromContext(ctx)
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(r.Target.GroupVersionKind)
+	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	pkg, version := r.Target.PackageName(obj), r.Target.InstalledVersion(obj)
+	if pkg == "" || version == "" {
+		l.V(1).Info("skipping resource without an installed package version")
+		return ctrl.Result{RequeueAfter: r.ResyncPeriod}, nil
+	}
+
+	updates, phase, err := r.lookup(ctx, pkg, version)
+	var apiErr *apiclient.Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		l.V(1).Info("package is not known to extensiondb", "package", pkg)
+		return ctrl.Result{RequeueAfter: r.ResyncPeriod}, nil
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Patching the resource triggers another reconcile, so it is only
+	// patched when the annotations change.
+	annotations := obj.GetAnnotations()
+	want := map[string]string{
+		AnnotationAvailableUpdates: strings.Join(updates, ","),
+		AnnotationLifecyclePhase:   phase,
+	}
+	changed := false
+	for key, value := range want {
+		if current, ok := annotations[key]; !ok || current != value {
+			changed = true
+		}
+	}
+	if !changed {
+		return ctrl.Result{RequeueAfter: r.ResyncPeriod}, nil
+	}
+	patch := client.MergeFrom(obj.DeepCopy())
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	maps.Copy(annotations, want)
+	obj.SetAnnotations(annotations)
+	if err := r.Patch(ctx, obj, patch); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	return ctrl.Result{RequeueAfter: r.ResyncPeriod}, nil
+}
+
+// lookup returns the versions that the installed version can update to
+// directly, ordered by version, and the lifecycle phase of the installed version.
+func (r *Reconciler) lookup(ctx context.Context, pkg, version string) ([]string, string, error) {
+	cg, err := r.API.CincinnatiGraph(ctx, pkg, r.Channel, version)
+	if err != nil {
+		return nil, "", err
+	}
+
+	installed := -1
+	for i, n := range cg.Nodes {
+		if nodeVersion(n) == version {
+			installed = i
+			break
+		}
+	}
+	if installed < 0 {
+		return nil, "Unknown", nil
+	}
+
+	var updates []string
+	for _, e := range cg.Edges {
+		if e[0] == installed {
+			updates = append(updates, nodeVersion(cg.Nodes[e[1]]))
+		}
+	}
+	slices.SortFunc(updates, compareVersions)
+	return slices.Compact(updates), cg.Nodes[installed].Metadata[graph.MetadataKeyLifecyclePhase], nil
+}
+
+func compareVersions(a, b string) int {
+	av, aErr := semver.Parse(a)
+	bv, bErr := semver.Parse(b)
+	if aErr != nil || bErr != nil {
+		return cmp.Compare(a, b)
+	}
+	return av.Compare(bv)
+}
+
+// nodeVersion returns the semver version of a node, without its release.
+func nodeVersion(n graph.CincinnatiNode) string {
+	v, _, _ := strings.Cut(n.Version, "_")
+	return v
+}
+
+func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(r.Target.GroupVersionKind)
+	if _, err := mgr.GetRESTMapper().RESTMapping(r.Target.GroupVersionKind.GroupKind(), r.Target.GroupVersionKind.Version); err != nil {
+		if meta.IsNoMatchError(err) {
+			return fmt.Errorf("%s: %w: %w", r.Target.GroupVersionKind, ErrNotServed, err)
+		}
+		return err
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		Named(strings.ToLower(r.Target.GroupVersionKind.Kind)).
+		For(obj).
+		Complete(r)
+}
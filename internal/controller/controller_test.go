@@ -0,0 +1,89 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/controller"
+	apiclient "github.com/joelanford/extensiondb/pkg/client"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReconcile(t *testing.T) {
+	var lookups atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookups.Add(1)
+		assert.Equal(t, "foo:stable", r.URL.Query().Get("channel"))
+		assert.NoError(t, json.NewEncoder(w).Encode(graph.CincinnatiGraph{
+			Nodes: []graph.CincinnatiNode{
+				{Version: "1.0.0", Metadata: map[string]string{graph.MetadataKeyLifecyclePhase: "Maintenance"}},
+				{Version: "1.2.0"},
+				{Version: "1.1.0"},
+			},
+			Edges: [][2]int{{0, 1}, {0, 2}, {2, 1}},
+		}))
+	}))
+	t.Cleanup(srv.Close)
+	api, err := apiclient.New(srv.URL, nil)
+	require.NoError(t, err)
+
+	gvk := controller.Subscriptions.GroupVersionKind
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypeWithName(gvk, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(gvk.GroupVersion().WithKind(gvk.Kind+"List"), &unstructured.UnstructuredList{})
+	sub := &unstructured.Unstructured{Object: map[string]any{
+		"metadata": map[string]any{"name": "foo", "namespace": "operators"},
+		"spec":     map[string]any{"name": "foo"},
+		"status":   map[string]any{"installedCSV": "foo.v1.0.0"},
+	}}
+	sub.SetGroupVersionKind(gvk)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(sub).Build()
+
+	r := &controller.Reconciler{
+		Client:       c,
+		API:          api,
+		Target:       controller.Subscriptions,
+		Channel:      "stable",
+		ResyncPeriod: time.Hour,
+	}
+	key := types.NamespacedName{Namespace: "operators", Name: "foo"}
+	get := func() *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+		require.NoError(t, c.Get(t.Context(), key, obj))
+		return obj
+	}
+
+	res, err := r.Reconcile(t.Context(), ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, res.RequeueAfter)
+	obj := get()
+	assert.Equal(t, map[string]string{
+		controller.AnnotationAvailableUpdates: "1.1.0,1.2.0",
+		controller.AnnotationLifecyclePhase:   "Maintenance",
+	}, obj.GetAnnotations())
+
+	// Reconciling again, such as for the update made by the patch, looks the
+	// updates up again but doesn't patch the unchanged resource.
+	patched := obj.GetResourceVersion()
+	_, err = r.Reconcile(t.Context(), ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	assert.Equal(t, patched, get().GetResourceVersion())
+	assert.EqualValues(t, 2, lookups.Load())
+
+	// Resources that are gone are ignored.
+	require.NoError(t, c.Delete(t.Context(), obj))
+	_, err = r.Reconcile(t.Context(), ctrl.Request{NamespacedName: key})
+	assert.NoError(t, err)
+}
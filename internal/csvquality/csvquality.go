@@ -0,0 +1,77 @@
+// Package csvquality checks the ClusterServiceVersions of bundles for
+// problems that OLM only reports when the bundle is installed, if at all, and
+// names each problem with a quality flag that is recorded on the bundle when
+// it is ingested, so that catalogs can be checked for problematic bundles
+// without installing them.
+package csvquality
+
+import (
+	"slices"
+
+	"github.com/blang/semver/v4"
+	v1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+const (
+	// MissingVersion flags CSVs without a spec.version.
+	MissingVersion = "missing-version"
+
+	// InvalidInstallModes flags CSVs whose spec.installModes are missing,
+	// name an unknown or duplicate install mode type, or support no install
+	// mode, so that OLM can't install the bundle in any namespace.
+	InvalidInstallModes = "invalid-install-modes"
+
+	// BrokenRelatedImages flags CSVs with a spec.relatedImages entry whose
+	// image is missing or is not a valid image reference, so that it can't be
+	// mirrored.
+	BrokenRelatedImages = "broken-related-images"
+)
+
+// Flags are the quality flags that Check reports, in the order it reports
+// them.
+var Flags = []string{MissingVersion, InvalidInstallModes, BrokenRelatedImages}
+
+var installModeTypes = []v1alpha1.InstallModeType{
+	v1alpha1.InstallModeTypeOwnNamespace,
+	v1alpha1.InstallModeTypeSingleNamespace,
+	v1alpha1.InstallModeTypeMultiNamespace,
+	v1alpha1.InstallModeTypeAllNamespaces,
+}
+
+// Check returns the quality flags of a CSV, in the order of Flags. It
+// returns an empty, non-nil slice for CSVs without problems.
+func Check(csv v1alpha1.ClusterServiceVersion) []string {
+	flags := []string{}
+	if csv.Spec.Version.Version.Equals(semver.Version{}) {
+		flags = append(flags, MissingVersion)
+	}
+	if !validInstallModes(csv.Spec.InstallModes) {
+		flags = append(flags, InvalidInstallModes)
+	}
+	if slices.ContainsFunc(csv.Spec.RelatedImages, brokenRelatedImage) {
+		flags = append(flags, BrokenRelatedImages)
+	}
+	return flags
+}
+
+func validInstallModes(modes []v1alpha1.InstallMode) bool {
+	seen := map[v1alpha1.InstallModeType]bool{}
+	supported := false
+	for _, m := range modes {
+		if !slices.Contains(installModeTypes, m.Type) || seen[m.Type] {
+			return false
+		}
+		seen[m.Type] = true
+		supported = supported || m.Supported
+	}
+	return supported
+}
+
+func brokenRelatedImage(ri v1alpha1.RelatedImage) bool {
+	if ri.Image == "" {
+		return true
+	}
+	_, err := reference.ParseNormalizedNamed(ri.Image)
+	return err != nil
+}
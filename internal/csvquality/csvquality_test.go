@@ -0,0 +1,80 @@
+package csvquality_test
+
+import (
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/internal/csvquality"
+	"github.com/operator-framework/api/pkg/lib/version"
+	v1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	valid := func() v1alpha1.ClusterServiceVersion {
+		var csv v1alpha1.ClusterServiceVersion
+		csv.Spec.Version = version.OperatorVersion{Version: semver.MustParse("1.0.0")}
+		csv.Spec.InstallModes = []v1alpha1.InstallMode{
+			{Type: v1alpha1.InstallModeTypeOwnNamespace, Supported: true},
+			{Type: v1alpha1.InstallModeTypeAllNamespaces, Supported: false},
+		}
+		csv.Spec.RelatedImages = []v1alpha1.RelatedImage{
+			{Name: "operator", Image: "quay.io/example/foo-operator@sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"},
+			{Name: "operand", Image: "quay.io/example/foo-operand:latest"},
+		}
+		return csv
+	}
+
+	for name, tc := range map[string]struct {
+		modify func(*v1alpha1.ClusterServiceVersion)
+		want   []string
+	}{
+		"valid": {
+			modify: func(*v1alpha1.ClusterServiceVersion) {},
+			want:   []string{},
+		},
+		"missing version": {
+			modify: func(csv *v1alpha1.ClusterServiceVersion) { csv.Spec.Version = version.OperatorVersion{} },
+			want:   []string{csvquality.MissingVersion},
+		},
+		"no install modes": {
+			modify: func(csv *v1alpha1.ClusterServiceVersion) { csv.Spec.InstallModes = nil },
+			want:   []string{csvquality.InvalidInstallModes},
+		},
+		"no supported install mode": {
+			modify: func(csv *v1alpha1.ClusterServiceVersion) { csv.Spec.InstallModes[0].Supported = false },
+			want:   []string{csvquality.InvalidInstallModes},
+		},
+		"unknown install mode": {
+			modify: func(csv *v1alpha1.ClusterServiceVersion) { csv.Spec.InstallModes[1].Type = "ClusterWide" },
+			want:   []string{csvquality.InvalidInstallModes},
+		},
+		"duplicate install mode": {
+			modify: func(csv *v1alpha1.ClusterServiceVersion) {
+				csv.Spec.InstallModes[1].Type = v1alpha1.InstallModeTypeOwnNamespace
+			},
+			want: []string{csvquality.InvalidInstallModes},
+		},
+		"related image without an image": {
+			modify: func(csv *v1alpha1.ClusterServiceVersion) { csv.Spec.RelatedImages[1].Image = "" },
+			want:   []string{csvquality.BrokenRelatedImages},
+		},
+		"invalid related image": {
+			modify: func(csv *v1alpha1.ClusterServiceVersion) { csv.Spec.RelatedImages[0].Image = "quay.io/Example/foo:v1" },
+			want:   []string{csvquality.BrokenRelatedImages},
+		},
+		"every problem": {
+			modify: func(csv *v1alpha1.ClusterServiceVersion) {
+				*csv = v1alpha1.ClusterServiceVersion{}
+				csv.Spec.RelatedImages = []v1alpha1.RelatedImage{{Name: "operator"}}
+			},
+			want: csvquality.Flags,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			csv := valid()
+			tc.modify(&csv)
+			assert.Equal(t, tc.want, csvquality.Check(csv))
+		})
+	}
+}
@@ -1,12 +1,19 @@
 package db
 
 import (
+	"cmp"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 	_ "github.com/lib/pq"
 )
 
@@ -17,6 +24,10 @@ type DB struct {
 
 // Config holds database configuration
 type Config struct {
+	// URL is a postgres:// connection URL or a key/value connection
+	// string. If it is set, the other fields are ignored.
+	URL string
+
 	Host     string
 	Port     int
 	User     string
@@ -25,12 +36,80 @@ type Config struct {
 	SSLMode  string
 }
 
+// Environment variables that ConfigFromEnv reads each field of Config from.
+const (
+	EnvURL      = "EXTENSIONDB_DATABASE_URL"
+	EnvHost     = "EXTENSIONDB_DATABASE_HOST"
+	EnvPort     = "EXTENSIONDB_DATABASE_PORT"
+	EnvUser     = "EXTENSIONDB_DATABASE_USER"
+	EnvPassword = "EXTENSIONDB_DATABASE_PASSWORD"
+	EnvName     = "EXTENSIONDB_DATABASE_NAME"
+	EnvSSLMode  = "EXTENSIONDB_DATABASE_SSLMODE"
+)
+
+// DefaultConfig returns the configuration of the local database that
+// make db-up starts.
+func DefaultConfig() Config {
+	return Config{
+		Host:     "localhost",
+		Port:     5432,
+		User:     "postgres",
+		Password: "postgres",
+		DBName:   "extensiondb",
+		SSLMode:  "disable",
+	}
+}
+
+// ConfigFromEnv returns the configuration set by the environment variables.
+// Fields whose variables are unset or empty are left empty.
+func ConfigFromEnv() (Config, error) {
+	c := Config{
+		URL:      os.Getenv(EnvURL),
+		Host:     os.Getenv(EnvHost),
+		User:     os.Getenv(EnvUser),
+		Password: os.Getenv(EnvPassword),
+		DBName:   os.Getenv(EnvName),
+		SSLMode:  os.Getenv(EnvSSLMode),
+	}
+	if port := os.Getenv(EnvPort); port != "" {
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid %s %q: %w", EnvPort, port, err)
+		}
+		c.Port = p
+	}
+	return c, nil
+}
+
+// WithDefaults returns c with its empty fields set from defaults.
+func (c Config) WithDefaults(defaults Config) Config {
+	return Config{
+		URL:      cmp.Or(c.URL, defaults.URL),
+		Host:     cmp.Or(c.Host, defaults.Host),
+		Port:     cmp.Or(c.Port, defaults.Port),
+		User:     cmp.Or(c.User, defaults.User),
+		Password: cmp.Or(c.Password, defaults.Password),
+		DBName:   cmp.Or(c.DBName, defaults.DBName),
+		SSLMode:  cmp.Or(c.SSLMode, defaults.SSLMode),
+	}
+}
+
+// DSN returns the connection string of the database: URL, if it is set, or
+// else a key/value connection string of the other fields.
+func (c Config) DSN() string {
+	if c.URL != "" {
+		return c.URL
+	}
+	quote := func(v string) string {
+		return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+	}
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		quote(c.Host), c.Port, quote(c.User), quote(c.Password), quote(c.DBName), quote(c.SSLMode))
+}
+
 // NewDB creates a new database connection
 func NewDB(config Config) (*DB, error) {
-	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		config.Host, config.Port, config.User, config.Password, config.DBName, config.SSLMode)
-
-	db, err := sql.Open("postgres", dsn)
+	db, err := sql.Open("postgres", config.DSN())
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -65,3 +144,76 @@ func (db *DB) RunMigrations(migrationsPath string) error {
 	return nil
 }
 
+// RunMigrationsFS runs the database migrations in the root of fsys
+func (db *DB) RunMigrationsFS(fsys fs.FS) error {
+	driver, err := postgres.WithInstance(db.DB, &postgres.Config{})
+	if err != nil {
+		return fmt.Errorf("failed to create migration driver: %w", err)
+	}
+
+	source, err := iofs.New(fsys, ".")
+	if err != nil {
+		return fmt.Errorf("failed to create migration source: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "postgres", driver)
+	if err != nil {
+		return fmt.Errorf("failed to create migration instance: %w", err)
+	}
+
+	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+
+	return nil
+}
+
+// MigrationStatus is the version of a database's schema, relative to the
+// latest of a set of migrations.
+type MigrationStatus struct {
+	// Version is the version of the last migration applied, or 0 if none
+	// has been.
+	Version uint
+	// Dirty is true if the last migration failed partway, which must be
+	// fixed by hand before migrations can run again.
+	Dirty bool
+	// Latest is the version of the latest migration.
+	Latest uint
+}
+
+// MigrationStatus reports the version of the database's schema, relative to
+// the migrations in the root of fsys, without running any migrations.
+func (db *DB) MigrationStatus(fsys fs.FS) (*MigrationStatus, error) {
+	driver, err := postgres.WithInstance(db.DB, &postgres.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migration driver: %w", err)
+	}
+
+	source, err := iofs.New(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migration source: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "postgres", driver)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migration instance: %w", err)
+	}
+
+	var status MigrationStatus
+	status.Version, status.Dirty, err = m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return nil, fmt.Errorf("failed to read migration version: %w", err)
+	}
+
+	status.Latest, err = source.First()
+	for err == nil {
+		var next uint
+		if next, err = source.Next(status.Latest); err == nil {
+			status.Latest = next
+		}
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+	return &status, nil
+}
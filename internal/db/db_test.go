@@ -0,0 +1,27 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/joelanford/extensiondb/internal/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig(t *testing.T) {
+	t.Setenv(db.EnvHost, "db.example.com")
+	t.Setenv(db.EnvPort, "6543")
+	t.Setenv(db.EnvPassword, `it's a \secret`)
+	env, err := db.ConfigFromEnv()
+	require.NoError(t, err)
+
+	cfg := db.Config{User: "reader"}.WithDefaults(env).WithDefaults(db.DefaultConfig())
+	assert.Equal(t, `host='db.example.com' port=6543 user='reader' password='it\'s a \\secret' dbname='extensiondb' sslmode='disable'`, cfg.DSN())
+
+	cfg.URL = "postgres://reader@db.example.com/extensiondb"
+	assert.Equal(t, cfg.URL, cfg.DSN())
+
+	t.Setenv(db.EnvPort, "postgres")
+	_, err = db.ConfigFromEnv()
+	assert.ErrorContains(t, err, db.EnvPort)
+}
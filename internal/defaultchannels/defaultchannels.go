@@ -0,0 +1,98 @@
+// Package defaultchannels reports how the default channels of packages change
+// across the tags of a catalog, such as the OpenShift versions of an operator
+// index. A changed default channel silently changes what new installations
+// subscribe to, so changes are worth knowing about before a platform update.
+package defaultchannels
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/pkg/graph"
+)
+
+// Change is a change of the default channel of a package from one tag of a
+// catalog to another.
+type Change struct {
+	Package string `json:"package"`
+	FromTag string `json:"fromTag"`
+	ToTag   string `json:"toTag"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+}
+
+// Changes returns the changes of the default channel of each package between
+// the successive tags that have the package, ordered by package and tag. Tags
+// are ordered by CompareTags.
+func Changes(channels []query.TagDefaultChannel) []Change {
+	sorted := slices.SortedFunc(slices.Values(channels), func(a, b query.TagDefaultChannel) int {
+		return cmp.Or(cmp.Compare(a.Package, b.Package), CompareTags(a.Tag, b.Tag))
+	})
+	changes := []Change{}
+	for i := 1; i < len(sorted); i++ {
+		prev, cur := sorted[i-1], sorted[i]
+		if prev.Package == cur.Package && prev.DefaultChannel != cur.DefaultChannel {
+			changes = append(changes, newChange(prev, cur))
+		}
+	}
+	return changes
+}
+
+// Between returns the changes of the default channel of each package that is
+// in both fromTag and toTag, ignoring the tags between them, ordered by
+// package.
+func Between(channels []query.TagDefaultChannel, fromTag, toTag string) []Change {
+	from := map[string]query.TagDefaultChannel{}
+	for _, dc := range channels {
+		if dc.Tag == fromTag {
+			from[dc.Package] = dc
+		}
+	}
+	changes := []Change{}
+	for _, to := range channels {
+		if to.Tag != toTag {
+			continue
+		}
+		if prev, ok := from[to.Package]; ok && prev.DefaultChannel != to.DefaultChannel {
+			changes = append(changes, newChange(prev, to))
+		}
+	}
+	slices.SortFunc(changes, func(a, b Change) int { return cmp.Compare(a.Package, b.Package) })
+	return changes
+}
+
+func newChange(from, to query.TagDefaultChannel) Change {
+	return Change{
+		Package: to.Package,
+		FromTag: from.Tag,
+		ToTag:   to.Tag,
+		From:    from.DefaultChannel,
+		To:      to.DefaultChannel,
+	}
+}
+
+// CompareTags orders the tags of a catalog. Tags that name OpenShift
+// versions, such as v4.9 and v4.10, are ordered by version, before any other
+// tags, which are ordered as strings.
+func CompareTags(a, b string) int {
+	va, errA := graph.NewMajorMinorFromString(strings.TrimPrefix(a, "v"))
+	vb, errB := graph.NewMajorMinorFromString(strings.TrimPrefix(b, "v"))
+	switch {
+	case errA == nil && errB == nil:
+		return cmp.Or(va.Compare(vb), cmp.Compare(a, b))
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	default:
+		return cmp.Compare(a, b)
+	}
+}
+
+// PlatformTag returns the tag of a catalog for an OpenShift version, such as
+// v4.16 for 4.16.
+func PlatformTag(platform graph.MajorMinor) string {
+	return "v" + platform.String()
+}
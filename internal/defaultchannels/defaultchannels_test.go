@@ -0,0 +1,51 @@
+package defaultchannels_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/joelanford/extensiondb/internal/defaultchannels"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/stretchr/testify/assert"
+)
+
+var channels = []query.TagDefaultChannel{
+	{Tag: "v4.10", Package: "foo", DefaultChannel: "stable-1.2"},
+	{Tag: "v4.8", Package: "foo", DefaultChannel: "stable-1.0"},
+	{Tag: "v4.9", Package: "foo", DefaultChannel: "stable-1.1"},
+	{Tag: "v4.9", Package: "bar", DefaultChannel: "stable"},
+	{Tag: "v4.10", Package: "bar", DefaultChannel: "stable"},
+	{Tag: "v4.8", Package: "baz", DefaultChannel: "alpha"},
+	{Tag: "v4.10", Package: "baz", DefaultChannel: "beta"},
+}
+
+func TestChanges(t *testing.T) {
+	assert.Equal(t, []defaultchannels.Change{
+		{Package: "baz", FromTag: "v4.8", ToTag: "v4.10", From: "alpha", To: "beta"},
+		{Package: "foo", FromTag: "v4.8", ToTag: "v4.9", From: "stable-1.0", To: "stable-1.1"},
+		{Package: "foo", FromTag: "v4.9", ToTag: "v4.10", From: "stable-1.1", To: "stable-1.2"},
+	}, defaultchannels.Changes(channels))
+
+	assert.Equal(t, []defaultchannels.Change{}, defaultchannels.Changes(nil), "no changes are encoded as an empty array")
+}
+
+func TestBetween(t *testing.T) {
+	from := defaultchannels.PlatformTag(graph.MajorMinor{Major: 4, Minor: 8})
+	to := defaultchannels.PlatformTag(graph.MajorMinor{Major: 4, Minor: 10})
+	assert.Equal(t, []defaultchannels.Change{
+		{Package: "baz", FromTag: "v4.8", ToTag: "v4.10", From: "alpha", To: "beta"},
+		{Package: "foo", FromTag: "v4.8", ToTag: "v4.10", From: "stable-1.0", To: "stable-1.2"},
+	}, defaultchannels.Between(channels, from, to))
+
+	// bar's default channel doesn't change, and baz is not in v4.9.
+	assert.Equal(t, []defaultchannels.Change{
+		{Package: "foo", FromTag: "v4.9", ToTag: "v4.10", From: "stable-1.1", To: "stable-1.2"},
+	}, defaultchannels.Between(channels, "v4.9", "v4.10"))
+}
+
+func TestCompareTags(t *testing.T) {
+	tags := []string{"latest", "v4.10", "4.9", "v4.8", "dev"}
+	slices.SortFunc(tags, defaultchannels.CompareTags)
+	assert.Equal(t, []string{"v4.8", "4.9", "v4.10", "dev", "latest"}, tags)
+}
@@ -0,0 +1,207 @@
+// Package doctor diagnoses the environment that extensiondb runs in: whether
+// the database is reachable and migrated, whether catalog registries can be
+// reached with the available credentials, whether caches have room to grow,
+// and whether templates are valid.
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/db"
+	"github.com/joelanford/extensiondb/internal/registry"
+	"github.com/joelanford/extensiondb/internal/templateloader"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+// Status is the outcome of a check.
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusFail Status = "fail"
+	StatusSkip Status = "skip"
+)
+
+// ErrSkipped is wrapped by the errors of checks that could not run, such as
+// checks of a database that could not be reached. Skipped checks don't fail
+// the report, because the check that they depend on already has.
+var ErrSkipped = errors.New("skipped")
+
+// Check is a named diagnostic check.
+type Check struct {
+	Name string
+
+	// Run returns what the check found, or an error describing why it
+	// failed.
+	Run func(ctx context.Context) (string, error)
+}
+
+// Result is the outcome of a check.
+type Result struct {
+	Check  string `json:"check"`
+	Status Status `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// Run runs the checks in order and returns their results. Each check is
+// given at most timeout to run, or as long as it takes if timeout is 0, so
+// that an unreachable service doesn't hold up the report.
+func Run(ctx context.Context, timeout time.Duration, checks []Check) []Result {
+	results := make([]Result, 0, len(checks))
+	for _, c := range checks {
+		detail, err := runCheck(ctx, timeout, c)
+		r := Result{Check: c.Name, Status: StatusPass, Detail: detail}
+		switch {
+		case errors.Is(err, ErrSkipped):
+			r.Status, r.Detail = StatusSkip, err.Error()
+		case err != nil:
+			r.Status, r.Detail = StatusFail, err.Error()
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+func runCheck(ctx context.Context, timeout time.Duration, c Check) (string, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return c.Run(ctx)
+}
+
+// Failed returns the number of results of failed checks.
+func Failed(results []Result) int {
+	n := 0
+	for _, r := range results {
+		if r.Status == StatusFail {
+			n++
+		}
+	}
+	return n
+}
+
+// Migrations checks that every migration in the root of fsys has been applied
+// to the database, and that none failed partway. The check is skipped if pdb
+// is nil, because the database could not be reached.
+func Migrations(pdb *db.DB, fsys fs.FS) Check {
+	return Check{
+		Name: "database migrations",
+		Run: func(context.Context) (string, error) {
+			if pdb == nil {
+				return "", fmt.Errorf("%w: the database could not be reached", ErrSkipped)
+			}
+			status, err := pdb.MigrationStatus(fsys)
+			if err != nil {
+				return "", err
+			}
+			switch {
+			case status.Dirty:
+				return "", fmt.Errorf("migration %d failed partway and must be fixed by hand", status.Version)
+			case status.Version < status.Latest:
+				return "", fmt.Errorf("at version %d of %d; commands that write to the database, such as ingest and serve, apply pending migrations", status.Version, status.Latest)
+			case status.Version > status.Latest:
+				return "", fmt.Errorf("at version %d, newer than the latest known migration %d; this build is older than the database", status.Version, status.Latest)
+			}
+			return fmt.Sprintf("at version %d", status.Version), nil
+		},
+	}
+}
+
+// Registry checks that a catalog image's tag can be resolved, which requires
+// the registry to be reachable and, for registries that require it, valid
+// credentials.
+func Registry(ref reference.NamedTagged) Check {
+	return Check{
+		Name: "registry " + ref.String(),
+		Run: func(ctx context.Context) (string, error) {
+			canonical, err := registry.ResolveDigest(ctx, ref)
+			if errors.Is(err, registry.ErrNotFound) {
+				return "", fmt.Errorf("reachable, but the image was not found: %w", err)
+			}
+			if err != nil {
+				return "", err
+			}
+			return "resolved to " + canonical.Digest().String(), nil
+		},
+	}
+}
+
+// DiskSpace checks that the file system holding path has at least minFree
+// bytes free. If path doesn't exist yet, the file system of its nearest
+// existing parent is checked.
+func DiskSpace(name, path string, minFree uint64) Check {
+	return Check{
+		Name: "disk space for " + name,
+		Run: func(context.Context) (string, error) {
+			dir, err := existingParent(path)
+			if err != nil {
+				return "", err
+			}
+			free, err := freeSpace(dir)
+			if err != nil {
+				return "", fmt.Errorf("error checking free space of %s: %w", dir, err)
+			}
+			if free < minFree {
+				return "", fmt.Errorf("%s free in %s, less than %s", formatBytes(free), dir, formatBytes(minFree))
+			}
+			return fmt.Sprintf("%s free in %s", formatBytes(free), dir), nil
+		},
+	}
+}
+
+func existingParent(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	for {
+		_, err := os.Stat(path)
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return path, err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path, err
+		}
+		path = parent
+	}
+}
+
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// Templates checks that the olm.cincinnati templates in a directory, or in the
+// files matched by a glob pattern, are valid.
+func Templates(path string) Check {
+	return Check{
+		Name: "templates " + path,
+		Run: func(ctx context.Context) (string, error) {
+			templates, err := templateloader.Load(ctx, templateloader.Dir(path))
+			if err != nil {
+				return "", err
+			}
+			if len(templates) == 0 {
+				return "", errors.New("no templates found")
+			}
+			return fmt.Sprintf("%d valid templates", len(templates)), nil
+		},
+	}
+}
@@ -0,0 +1,111 @@
+package doctor_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/db"
+	"github.com/joelanford/extensiondb/internal/doctor"
+	"github.com/joelanford/extensiondb/internal/registry/registrytest"
+	"github.com/joelanford/extensiondb/migrations"
+	"github.com/joelanford/extensiondb/pkg/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(dbtest.Main(m))
+}
+
+func TestRun(t *testing.T) {
+	results := doctor.Run(t.Context(), time.Millisecond, []doctor.Check{
+		{Name: "ok", Run: func(context.Context) (string, error) { return "fine", nil }},
+		{Name: "broken", Run: func(context.Context) (string, error) { return "", errors.New("boom") }},
+		{Name: "dependent", Run: func(context.Context) (string, error) {
+			return "", fmt.Errorf("%w: nothing to check", doctor.ErrSkipped)
+		}},
+		{Name: "slow", Run: func(ctx context.Context) (string, error) {
+			<-ctx.Done()
+			return "", ctx.Err()
+		}},
+	})
+	assert.Equal(t, []doctor.Result{
+		{Check: "ok", Status: doctor.StatusPass, Detail: "fine"},
+		{Check: "broken", Status: doctor.StatusFail, Detail: "boom"},
+		{Check: "dependent", Status: doctor.StatusSkip, Detail: "skipped: nothing to check"},
+		{Check: "slow", Status: doctor.StatusFail, Detail: context.DeadlineExceeded.Error()},
+	}, results)
+	assert.Equal(t, 2, doctor.Failed(results))
+}
+
+func TestMigrations(t *testing.T) {
+	results := doctor.Run(t.Context(), 0, []doctor.Check{doctor.Migrations(nil, migrations.FS)})
+	assert.Equal(t, doctor.StatusSkip, results[0].Status)
+
+	pdb := &db.DB{DB: dbtest.New(t)}
+	results = doctor.Run(t.Context(), 0, []doctor.Check{doctor.Migrations(pdb, migrations.FS)})
+	assert.Equal(t, doctor.StatusPass, results[0].Status, results[0].Detail)
+
+	pending := fstest.MapFS{}
+	entries, err := migrations.FS.ReadDir(".")
+	require.NoError(t, err)
+	for _, e := range entries {
+		data, err := migrations.FS.ReadFile(e.Name())
+		require.NoError(t, err)
+		pending[e.Name()] = &fstest.MapFile{Data: data}
+	}
+	pending["999_future.up.sql"] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+	pending["999_future.down.sql"] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+	results = doctor.Run(t.Context(), 0, []doctor.Check{doctor.Migrations(pdb, pending)})
+	assert.Equal(t, doctor.StatusFail, results[0].Status)
+	assert.Contains(t, results[0].Detail, "of 999")
+}
+
+func TestRegistry(t *testing.T) {
+	r := registrytest.New(t)
+	ref := r.PushBundle(t, "example/index", "v4.16", registrytest.Bundle{Package: "foo", Version: "1.0.0"})
+
+	tagged, err := reference.WithTag(r.Named("example/index"), "v4.16")
+	require.NoError(t, err)
+	missing, err := reference.WithTag(r.Named("example/index"), "v4.17")
+	require.NoError(t, err)
+
+	results := doctor.Run(t.Context(), 0, []doctor.Check{doctor.Registry(tagged), doctor.Registry(missing)})
+	assert.Equal(t, doctor.StatusPass, results[0].Status, results[0].Detail)
+	assert.Contains(t, results[0].Detail, ref.Digest().String())
+	assert.Equal(t, doctor.StatusFail, results[1].Status)
+	assert.Contains(t, results[1].Detail, "not found")
+}
+
+func TestDiskSpace(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "not", "created")
+	results := doctor.Run(t.Context(), 0, []doctor.Check{
+		doctor.DiskSpace("cache", dir, 0),
+		doctor.DiskSpace("cache", dir, math.MaxUint64),
+	})
+	assert.Equal(t, doctor.StatusPass, results[0].Status, results[0].Detail)
+	assert.Equal(t, doctor.StatusFail, results[1].Status)
+	assert.Contains(t, results[1].Detail, "less than")
+}
+
+func TestTemplates(t *testing.T) {
+	dir := t.TempDir()
+	results := doctor.Run(t.Context(), 0, []doctor.Check{doctor.Templates(dir)})
+	assert.Equal(t, doctor.StatusFail, results[0].Status)
+	assert.Equal(t, "no templates found", results[0].Detail)
+
+	results = doctor.Run(t.Context(), 0, []doctor.Check{doctor.Templates("../../examples/cincinnati/product-templates")})
+	assert.Equal(t, doctor.StatusPass, results[0].Status, results[0].Detail)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "foo.yaml"), []byte("schema: olm.cincinnati\nname: foo\nversionStreams: nope\n"), 0o600))
+	results = doctor.Run(t.Context(), 0, []doctor.Check{doctor.Templates(dir)})
+	assert.Equal(t, doctor.StatusFail, results[0].Status)
+}
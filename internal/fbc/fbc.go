@@ -0,0 +1,122 @@
+package fbc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/internal/models"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/alpha/property"
+)
+
+// ChannelFunc returns the channels for a package's bundles and the name of the
+// package's default channel.
+type ChannelFunc func(pkg string, bundles []declcfg.Bundle) ([]declcfg.Channel, string, error)
+
+// Options configures rendering
+type Options struct {
+	// Packages limits the rendered config to these packages. If empty, every
+	// package in the catalog is rendered.
+	Packages []string
+
+	// Channels builds the channels for each package. If nil, LinearChannel is used.
+	Channels ChannelFunc
+}
+
+// Render generates a declarative config for the bundles that ship in the most
+// recently ingested digest of the catalog. The database does not store
+// bundle manifests, so each bundle only carries its olm.package property.
+// When several builds of the same version ship in the catalog, only the most
+// recently built one is rendered.
+func Render(ctx context.Context, q *query.Query, c *models.Catalog, opts Options) (*declcfg.DeclarativeConfig, error) {
+	if opts.Channels == nil {
+		opts.Channels = LinearChannel("stable")
+	}
+
+	cbs, err := q.GetBundlesInCatalog(ctx, c, opts.Packages)
+	if err != nil {
+		return nil, fmt.Errorf("error getting bundles in catalog %s:%s: %w", c.Name, c.Tag, err)
+	}
+
+	bundlesByPackage := map[string]map[string]declcfg.Bundle{}
+	var packageNames []string
+	for _, cb := range cbs {
+		if _, ok := bundlesByPackage[cb.PackageName]; !ok {
+			bundlesByPackage[cb.PackageName] = map[string]declcfg.Bundle{}
+			packageNames = append(packageNames, cb.PackageName)
+		}
+		// Bundles are ordered by build time, so later builds of the same version replace earlier ones.
+		bundlesByPackage[cb.PackageName][cb.Bundle.Version] = declcfg.Bundle{
+			Schema:  declcfg.SchemaBundle,
+			Name:    BundleName(cb.PackageName, cb.Bundle.Version),
+			Package: cb.PackageName,
+			Image:   cb.Image,
+			Properties: []property.Property{
+				property.MustBuildPackage(cb.PackageName, cb.Bundle.Version),
+			},
+		}
+	}
+
+	var fbc declcfg.DeclarativeConfig
+	for _, pkgName := range packageNames {
+		bundles := slices.SortedFunc(maps.Values(bundlesByPackage[pkgName]), compareBundleVersions)
+		channels, defaultChannel, err := opts.Channels(pkgName, bundles)
+		if err != nil {
+			return nil, fmt.Errorf("error building channels for package %q: %w", pkgName, err)
+		}
+		fbc.Packages = append(fbc.Packages, declcfg.Package{
+			Schema:         declcfg.SchemaPackage,
+			Name:           pkgName,
+			DefaultChannel: defaultChannel,
+		})
+		fbc.Channels = append(fbc.Channels, channels...)
+		fbc.Bundles = append(fbc.Bundles, bundles...)
+	}
+	return &fbc, nil
+}
+
+// BundleName returns the name used for a bundle in rendered configs.
+func BundleName(pkg, version string) string {
+	return fmt.Sprintf("%s.v%s", pkg, version)
+}
+
+// LinearChannel puts every bundle in a single channel in which each version
+// replaces the version before it.
+func LinearChannel(name string) ChannelFunc {
+	return func(pkg string, bundles []declcfg.Bundle) ([]declcfg.Channel, string, error) {
+		ch := declcfg.Channel{Schema: declcfg.SchemaChannel, Name: name, Package: pkg}
+		for i, b := range bundles {
+			entry := declcfg.ChannelEntry{Name: b.Name}
+			if i > 0 {
+				entry.Replaces = bundles[i-1].Name
+			}
+			ch.Entries = append(ch.Entries, entry)
+		}
+		return []declcfg.Channel{ch}, name, nil
+	}
+}
+
+func compareBundleVersions(a, b declcfg.Bundle) int {
+	return bundleVersion(a).Compare(bundleVersion(b))
+}
+
+func bundleVersion(b declcfg.Bundle) semver.Version {
+	for _, p := range b.Properties {
+		if p.Type != property.TypePackage {
+			continue
+		}
+		var pkg property.Package
+		if err := json.Unmarshal(p.Value, &pkg); err != nil {
+			continue
+		}
+		if v, err := semver.Parse(pkg.Version); err == nil {
+			return v
+		}
+	}
+	return semver.Version{}
+}
@@ -0,0 +1,99 @@
+package fbc
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/graphdb"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/joelanford/extensiondb/pkg/util"
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// GraphChannel derives a package's channel from its update graph. Each node
+// contributes only its best edge, the edge to its lowest-weight successor.
+// Every channel entry replaces the highest version among the nodes whose best
+// edge leads to it, and skips the rest. Bundles that are not in the graph
+// are included as entries with no predecessors, and graph nodes that are not
+// in the bundle list are ignored. Rebuilds of the same version share a single
+// bundle name, so edges between them are dropped.
+func GraphChannel(g *graph.Graph, name string) ChannelFunc {
+	return func(pkg string, bundles []declcfg.Bundle) ([]declcfg.Channel, string, error) {
+		bundleNames := sets.New[string]()
+		for _, b := range bundles {
+			bundleNames.Insert(b.Name)
+		}
+
+		predecessors := map[string]sets.Set[string]{}
+		for from := range g.NodesMatching(graph.PackageNodes(pkg)) {
+			to := bestSuccessor(g, from)
+			if to == nil {
+				continue
+			}
+			fromName, toName := BundleName(pkg, from.Version.String()), BundleName(pkg, to.Version.String())
+			if fromName == toName || !bundleNames.Has(fromName) || !bundleNames.Has(toName) {
+				continue
+			}
+			if _, ok := predecessors[toName]; !ok {
+				predecessors[toName] = sets.New[string]()
+			}
+			predecessors[toName].Insert(fromName)
+		}
+
+		versions := map[string]int{}
+		for i, b := range bundles {
+			versions[b.Name] = i
+		}
+		byVersion := func(a, b string) int {
+			return cmp.Compare(versions[a], versions[b])
+		}
+
+		ch := declcfg.Channel{Schema: declcfg.SchemaChannel, Name: name, Package: pkg}
+		for _, b := range bundles {
+			entry := declcfg.ChannelEntry{Name: b.Name}
+			froms := predecessors[b.Name].UnsortedList()
+			slices.SortFunc(froms, byVersion)
+			if len(froms) > 0 {
+				entry.Replaces = froms[len(froms)-1]
+				entry.Skips = froms[:len(froms)-1]
+			}
+			ch.Entries = append(ch.Entries, entry)
+		}
+		return []declcfg.Channel{ch}, name, nil
+	}
+}
+
+func bestSuccessor(g *graph.Graph, from *graph.Node) *graph.Node {
+	var (
+		best       *graph.Node
+		bestWeight float64
+	)
+	for _, to := range slices.SortedFunc(g.From(from), util.Compare) {
+		w := g.EdgeWeight(from, to)
+		if best == nil || w < bestWeight {
+			best, bestWeight = to, w
+		}
+	}
+	return best
+}
+
+// TemplateChannels derives channels from the update graph of packages that
+// have a template, and falls back to a linear channel for packages that don't.
+func TemplateChannels(ctx context.Context, builder *graphdb.Builder, templates []graph.Template, asOf time.Time) ChannelFunc {
+	const channelName = "stable"
+	byName := util.KeySlice(templates, func(t graph.Template) string { return t.Name })
+	return func(pkg string, bundles []declcfg.Bundle) ([]declcfg.Channel, string, error) {
+		tmpl, ok := byName[pkg]
+		if !ok {
+			return LinearChannel(channelName)(pkg, bundles)
+		}
+		g, err := builder.Build(ctx, []graph.Template{tmpl}, asOf)
+		if err != nil {
+			return nil, "", err
+		}
+		return GraphChannel(g, channelName)(pkg, bundles)
+	}
+}
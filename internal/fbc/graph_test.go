@@ -0,0 +1,54 @@
+package fbc_test
+
+import (
+	"testing"
+
+	"github.com/joelanford/extensiondb/internal/fbc"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/joelanford/extensiondb/pkg/graph/graphtest"
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraphChannel(t *testing.T) {
+	streams := []graph.VersionStream{
+		{
+			Version: graph.MajorMinor{Major: 1, Minor: 0},
+			LifecycleDates: graph.LifecycleDates{
+				FullSupport: graph.NewDate(2024, 1, 1),
+				Maintenance: graph.NewDate(2024, 6, 1),
+				EndOfLife:   graph.NewDate(2030, 1, 1),
+			},
+		},
+		{
+			Version: graph.MajorMinor{Major: 1, Minor: 1},
+			LifecycleDates: graph.LifecycleDates{
+				FullSupport: graph.NewDate(2024, 6, 1),
+				Maintenance: graph.NewDate(2029, 1, 1),
+				EndOfLife:   graph.NewDate(2030, 1, 1),
+			},
+		},
+	}
+	g := graphtest.New(t, graph.Package{Name: "foo", Streams: streams, Nodes: graphtest.Nodes("foo", "1.0.0", "1.0.1", "1.1.0")})
+
+	bundles := []declcfg.Bundle{
+		{Name: "foo.v1.0.0"},
+		{Name: "foo.v1.0.1"},
+		{Name: "foo.v1.1.0"},
+		{Name: "foo.v1.2.0"},
+	}
+	channels, defaultChannel, err := fbc.GraphChannel(g, "stable")("foo", bundles)
+	require.NoError(t, err)
+	assert.Equal(t, "stable", defaultChannel)
+	require.Len(t, channels, 1)
+
+	// Both 1.0.x versions are best served by updating straight to the fully
+	// supported 1.1.0, and 1.2.0 is not in the graph.
+	assert.Equal(t, []declcfg.ChannelEntry{
+		{Name: "foo.v1.0.0"},
+		{Name: "foo.v1.0.1"},
+		{Name: "foo.v1.1.0", Replaces: "foo.v1.0.1", Skips: []string{"foo.v1.0.0"}},
+		{Name: "foo.v1.2.0"},
+	}, channels[0].Entries)
+}
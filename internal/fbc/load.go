@@ -0,0 +1,182 @@
+package fbc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/alpha/property"
+	"go.podman.io/image/v5/docker/reference"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Load reads a rendered file-based catalog: a directory of catalog files,
+// such as the contents that catalogd serves for a ClusterCatalog, or a file
+// of opm render output.
+func Load(ctx context.Context, path string) (*declcfg.DeclarativeConfig, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return declcfg.LoadFS(ctx, os.DirFS(path))
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return declcfg.LoadReader(f)
+}
+
+// GraphOptions configures BuildGraph.
+type GraphOptions struct {
+	// AsOf is the time used to compute lifecycle phases.
+	AsOf time.Time
+
+	// Catalog, if set, is recorded as the catalog of every node.
+	Catalog *graph.Catalog
+
+	// InferStreams infers the version streams of packages that are in the
+	// catalog but have no template from their bundles, rather than leaving
+	// them out of the graph.
+	InferStreams bool
+
+	SkipInvalidNodes bool
+}
+
+// BuildGraph builds the update graph of the packages of a rendered catalog,
+// without the database, so that the catalog of a cluster can be examined
+// where the database can't be reached. Each package gets the version streams
+// of its template, but its nodes are its bundles in the catalog rather than
+// the template's images, which the catalog may refer to by mirrored
+// references. Only packages named in packageNames are included, if any are
+// given. BuildGraph also returns the templates of the graph's packages,
+// including inferred ones.
+//
+// A catalog records no build times, so each node's release date is the
+// createdAt annotation of its ClusterServiceVersion, if any. Catalogs may
+// carry several builds of a version, which are collapsed into the newest.
+func BuildGraph(ctx context.Context, cfg *declcfg.DeclarativeConfig, templates []graph.Template, opts GraphOptions, packageNames ...string) (*graph.Graph, []graph.Template, error) {
+	nodesByPackage := map[string][]*graph.Node{}
+	for _, b := range cfg.Bundles {
+		if len(packageNames) > 0 && !slices.Contains(packageNames, b.Package) {
+			continue
+		}
+		n, err := bundleNode(b)
+		if err != nil {
+			return nil, nil, fmt.Errorf("bundle %q: %w", b.Name, err)
+		}
+		if opts.Catalog != nil {
+			n.Catalogs = sets.New(*opts.Catalog)
+		}
+		nodesByPackage[b.Package] = append(nodesByPackage[b.Package], n)
+	}
+
+	var (
+		packages []graph.Package
+		used     []graph.Template
+	)
+	for _, name := range slices.Sorted(maps.Keys(nodesByPackage)) {
+		nodes := nodesByPackage[name]
+		i := slices.IndexFunc(templates, func(t graph.Template) bool { return t.Name == name })
+		var tmpl graph.Template
+		switch {
+		case i >= 0:
+			tmpl = templates[i]
+		case opts.InferStreams:
+			tmpl = graph.Template{Schema: graph.SchemaCincinnati, Name: name, VersionStreams: graph.InferVersionStreams(nodes)}
+		default:
+			continue
+		}
+		used = append(used, tmpl)
+		packages = append(packages, graph.Package{Name: name, Nodes: nodes, Streams: tmpl.VersionStreams, MajorVersionBridges: tmpl.MajorVersionBridges})
+	}
+	if len(packages) == 0 {
+		return nil, nil, fmt.Errorf("no templates found for the packages of the catalog")
+	}
+
+	g, err := graph.NewGraph(ctx, graph.GraphConfig{
+		Packages:         packages,
+		AsOf:             opts.AsOf,
+		SkipInvalidNodes: opts.SkipInvalidNodes,
+		PathScope:        graph.PathScopePackage,
+		CollapseRebuilds: true,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return g, used, nil
+}
+
+// bundleNode returns the graph node of a catalog bundle.
+func bundleNode(b declcfg.Bundle) (*graph.Node, error) {
+	props, err := property.Parse(b.Properties)
+	if err != nil {
+		return nil, err
+	}
+	if len(props.Packages) == 0 {
+		return nil, fmt.Errorf("no %s property", property.TypePackage)
+	}
+	n := &graph.Node{Name: b.Package}
+	if n.Version, err = semver.Parse(props.Packages[0].Version); err != nil {
+		return nil, err
+	}
+	named, err := reference.ParseNamed(b.Image)
+	if err != nil {
+		return nil, err
+	}
+	canonical, ok := named.(reference.Canonical)
+	if !ok {
+		return nil, fmt.Errorf("image reference %s is not a canonical reference", b.Image)
+	}
+	n.ImageReference = canonical
+	n.ReleaseDate = createdAt(props)
+	return n, nil
+}
+
+// createdAtLayouts are the layouts of createdAt annotations seen in
+// ClusterServiceVersions.
+var createdAtLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// createdAt returns the time in the createdAt annotation of a bundle's
+// ClusterServiceVersion, from its olm.csv.metadata property or its
+// olm.bundle.object property, or the zero time if it has none.
+func createdAt(props *property.Properties) time.Time {
+	var annotations []string
+	for _, m := range props.CSVMetadatas {
+		annotations = append(annotations, m.Annotations["createdAt"])
+	}
+	for _, o := range props.BundleObjects {
+		var obj struct {
+			Kind     string `json:"kind"`
+			Metadata struct {
+				Annotations map[string]string `json:"annotations"`
+			} `json:"metadata"`
+		}
+		if err := json.Unmarshal(o.Data, &obj); err != nil || obj.Kind != "ClusterServiceVersion" {
+			continue
+		}
+		annotations = append(annotations, obj.Metadata.Annotations["createdAt"])
+	}
+	for _, a := range annotations {
+		for _, layout := range createdAtLayouts {
+			if t, err := time.Parse(layout, a); err == nil {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,135 @@
+package fbc_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/fbc"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"github.com/operator-framework/operator-registry/alpha/property"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func catalogBundle(pkg, version, digestChar, createdAt string) declcfg.Bundle {
+	metadata, _ := json.Marshal(map[string]any{"annotations": map[string]string{"createdAt": createdAt}})
+	return declcfg.Bundle{
+		Schema:  declcfg.SchemaBundle,
+		Name:    pkg + ".v" + version,
+		Package: pkg,
+		Image:   "quay.io/example/" + pkg + "-bundle@sha256:" + strings.Repeat(digestChar, 64),
+		Properties: []property.Property{
+			property.MustBuildPackage(pkg, version),
+			{Type: property.TypeCSVMetadata, Value: metadata},
+		},
+	}
+}
+
+func testCatalog() *declcfg.DeclarativeConfig {
+	return &declcfg.DeclarativeConfig{
+		Packages: []declcfg.Package{
+			{Schema: declcfg.SchemaPackage, Name: "bar"},
+			{Schema: declcfg.SchemaPackage, Name: "foo"},
+		},
+		Bundles: []declcfg.Bundle{
+			catalogBundle("foo", "1.0.0", "a", "2024-01-01T00:00:00Z"),
+			catalogBundle("foo", "1.0.0", "b", "2024-03-01 00:00:00"),
+			catalogBundle("foo", "1.1.0", "c", "2024-07-01"),
+			catalogBundle("bar", "2.0.0", "d", "2024-02-01T00:00:00Z"),
+		},
+	}
+}
+
+func TestBuildGraph(t *testing.T) {
+	templates := []graph.Template{{
+		Schema: graph.SchemaCincinnati,
+		Name:   "foo",
+		VersionStreams: []graph.VersionStream{
+			{
+				Version: graph.MajorMinor{Major: 1, Minor: 0},
+				LifecycleDates: graph.LifecycleDates{
+					FullSupport: graph.NewDate(2024, 1, 1),
+					Maintenance: graph.NewDate(2024, 6, 1),
+					EndOfLife:   graph.NewDate(2030, 1, 1),
+				},
+			},
+			{
+				Version: graph.MajorMinor{Major: 1, Minor: 1},
+				LifecycleDates: graph.LifecycleDates{
+					FullSupport: graph.NewDate(2024, 6, 1),
+					Maintenance: graph.NewDate(2029, 1, 1),
+					EndOfLife:   graph.NewDate(2030, 1, 1),
+				},
+			},
+		},
+	}}
+	opts := fbc.GraphOptions{AsOf: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	g, used, err := fbc.BuildGraph(t.Context(), testCatalog(), templates, opts)
+	require.NoError(t, err)
+	assert.Equal(t, templates, used, "bar has no template, so it is left out")
+
+	nodes := slices.Collect(g.NodesMatching(graph.AllNodes()))
+	require.Len(t, nodes, 2, "the builds of 1.0.0 are collapsed")
+	var nvrs []string
+	for _, n := range nodes {
+		nvrs = append(nvrs, n.NVR())
+	}
+	assert.ElementsMatch(t, []string{"foo.v1.0.0", "foo.v1.1.0"}, nvrs)
+
+	older := g.NodeForDigest("sha256:" + strings.Repeat("a", 64))
+	newer := g.NodeForDigest("sha256:" + strings.Repeat("b", 64))
+	require.NotNil(t, newer)
+	assert.Same(t, newer, older, "both builds map to the collapsed node")
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), newer.ReleaseDate, "the newest build is kept")
+
+	latest := g.NodeForDigest("sha256:" + strings.Repeat("c", 64))
+	require.NotNil(t, latest)
+	_, _, ok := g.ShortestPath(newer, latest)
+	assert.True(t, ok)
+
+	// With inferred streams, bar is included too.
+	opts.InferStreams = true
+	g, used, err = fbc.BuildGraph(t.Context(), testCatalog(), templates, opts)
+	require.NoError(t, err)
+	require.Len(t, used, 2)
+	assert.Equal(t, "bar", used[0].Name)
+	assert.NotEmpty(t, used[0].VersionStreams)
+	assert.NotNil(t, g.NodeForDigest("sha256:"+strings.Repeat("d", 64)))
+
+	// Only the named packages are included.
+	_, used, err = fbc.BuildGraph(t.Context(), testCatalog(), templates, opts, "bar")
+	require.NoError(t, err)
+	require.Len(t, used, 1)
+	assert.Equal(t, "bar", used[0].Name)
+
+	_, _, err = fbc.BuildGraph(t.Context(), testCatalog(), nil, fbc.GraphOptions{AsOf: opts.AsOf})
+	assert.Error(t, err, "no package has a template")
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "catalog.json")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, declcfg.WriteJSON(*testCatalog(), f))
+	require.NoError(t, f.Close())
+
+	// A file of opm render output and a directory of catalog files load
+	// alike.
+	for _, p := range []string{path, dir} {
+		cfg, err := fbc.Load(t.Context(), p)
+		require.NoError(t, err, p)
+		assert.Len(t, cfg.Packages, 2, p)
+		assert.Len(t, cfg.Bundles, 4, p)
+	}
+
+	_, err = fbc.Load(t.Context(), filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,103 @@
+// Package fingerprint computes a fingerprint of the content of a bundle that
+// ignores the fields a rebuild changes without changing what the bundle
+// installs, so that bundles whose digests differ only because they were
+// rebuilt can be recognized by their equal fingerprints.
+package fingerprint
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/opencontainers/go-digest"
+	"sigs.k8s.io/yaml"
+)
+
+// ignoredAnnotations are the annotations that record when or how an object
+// was built rather than what it does.
+var ignoredAnnotations = []string{
+	"createdAt",
+}
+
+// Compute returns the fingerprint of a bundle's ClusterServiceVersion and its
+// other manifests, keyed by file name, each given as a YAML or JSON object.
+//
+// Before they are fingerprinted, the objects are normalized by removing their
+// metadata.creationTimestamp, metadata.labels, createdAt annotation and
+// status. The name of the CSV is also removed, because rebuilds with a
+// release append it to the name. File names are ignored, so that renaming a
+// manifest doesn't change the fingerprint.
+func Compute(csv []byte, manifests map[string][]byte) (digest.Digest, error) {
+	normalizedCSV, err := normalize(csv)
+	if err != nil {
+		return "", fmt.Errorf("error normalizing ClusterServiceVersion: %w", err)
+	}
+	if metadata, ok := normalizedCSV["metadata"].(map[string]any); ok {
+		delete(metadata, "name")
+	}
+
+	objects := make([][]byte, 0, len(manifests))
+	for _, name := range slices.Sorted(maps.Keys(manifests)) {
+		obj, err := normalize(manifests[name])
+		if err != nil {
+			return "", fmt.Errorf("error normalizing manifest %s: %w", name, err)
+		}
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return "", fmt.Errorf("error encoding manifest %s: %w", name, err)
+		}
+		objects = append(objects, data)
+	}
+	slices.SortFunc(objects, bytes.Compare)
+
+	data, err := json.Marshal(struct {
+		CSV       map[string]any    `json:"csv"`
+		Manifests []json.RawMessage `json:"manifests"`
+	}{
+		CSV:       normalizedCSV,
+		Manifests: rawMessages(objects),
+	})
+	if err != nil {
+		return "", err
+	}
+	return digest.FromBytes(data), nil
+}
+
+// normalize decodes an object and removes the fields that rebuilds change.
+// Objects are encoded with sorted keys once normalized, so equal objects have
+// equal encodings whatever the order of their fields.
+func normalize(data []byte) (map[string]any, error) {
+	var obj map[string]any
+	if err := yaml.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		obj = map[string]any{}
+	}
+	delete(obj, "status")
+	metadata, ok := obj["metadata"].(map[string]any)
+	if !ok {
+		return obj, nil
+	}
+	delete(metadata, "creationTimestamp")
+	delete(metadata, "labels")
+	if annotations, ok := metadata["annotations"].(map[string]any); ok {
+		for _, key := range ignoredAnnotations {
+			delete(annotations, key)
+		}
+		if len(annotations) == 0 {
+			delete(metadata, "annotations")
+		}
+	}
+	return obj, nil
+}
+
+func rawMessages(objects [][]byte) []json.RawMessage {
+	msgs := make([]json.RawMessage, 0, len(objects))
+	for _, obj := range objects {
+		msgs = append(msgs, obj)
+	}
+	return msgs
+}
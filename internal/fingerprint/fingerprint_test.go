@@ -0,0 +1,101 @@
+package fingerprint_test
+
+import (
+	"testing"
+
+	"github.com/joelanford/extensiondb/internal/fingerprint"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	csv = `apiVersion: operators.coreos.com/v1alpha1
+kind: ClusterServiceVersion
+metadata:
+  name: foo.v1.0.0
+  annotations:
+    createdAt: "2025-01-01T00:00:00Z"
+    containerImage: quay.io/example/foo-operator@sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae
+spec:
+  version: 1.0.0
+`
+	crd = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: foos.example.com
+  creationTimestamp: null
+spec:
+  group: example.com
+`
+)
+
+func TestCompute(t *testing.T) {
+	want, err := fingerprint.Compute([]byte(csv), map[string][]byte{"foos.crd.yaml": []byte(crd)})
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		csv       string
+		manifests map[string]string
+		same      bool
+	}{
+		"identical": {
+			csv:       csv,
+			manifests: map[string]string{"foos.crd.yaml": crd},
+			same:      true,
+		},
+		"rebuild with a release": {
+			csv: `kind: ClusterServiceVersion
+apiVersion: operators.coreos.com/v1alpha1
+metadata:
+  name: foo.v1.0.0-2
+  labels:
+    build: "2"
+  annotations:
+    createdAt: "2025-02-01T00:00:00Z"
+    containerImage: quay.io/example/foo-operator@sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae
+spec:
+  version: 1.0.0
+status:
+  phase: Succeeded
+`,
+			manifests: map[string]string{"example.com_foos.yaml": `{"apiVersion": "apiextensions.k8s.io/v1", "kind": "CustomResourceDefinition", "metadata": {"name": "foos.example.com", "creationTimestamp": "2025-02-01T00:00:00Z"}, "spec": {"group": "example.com"}}`},
+			same:      true,
+		},
+		"new operator image": {
+			csv: `apiVersion: operators.coreos.com/v1alpha1
+kind: ClusterServiceVersion
+metadata:
+  name: foo.v1.0.0
+  annotations:
+    containerImage: quay.io/example/foo-operator@sha256:fcde2b2edba56bf408601fb721fe9b5c338d10ee429ea04fae5511b68fbf8fb9
+spec:
+  version: 1.0.0
+`,
+			manifests: map[string]string{"foos.crd.yaml": crd},
+		},
+		"changed CRD": {
+			csv:       csv,
+			manifests: map[string]string{"foos.crd.yaml": crd + "  scope: Namespaced\n"},
+		},
+		"missing CRD": {
+			csv: csv,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			manifests := map[string][]byte{}
+			for name, data := range tc.manifests {
+				manifests[name] = []byte(data)
+			}
+			got, err := fingerprint.Compute([]byte(tc.csv), manifests)
+			require.NoError(t, err)
+			if tc.same {
+				assert.Equal(t, want, got)
+			} else {
+				assert.NotEqual(t, want, got)
+			}
+		})
+	}
+
+	_, err = fingerprint.Compute([]byte("- not an object"), nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,172 @@
+// Package freshness reports when each catalog's digest last changed and when
+// each catalog was last ingested, and flags catalogs for which either was
+// longer ago than a threshold.
+package freshness
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/notify"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Thresholds are how old a catalog's digest and last ingestion may be before
+// the catalog is stale. Zero thresholds disable the corresponding check.
+type Thresholds struct {
+	MaxDigestAge    time.Duration
+	MaxIngestionAge time.Duration
+
+	// Catalogs overrides the thresholds of catalogs, keyed by <name>:<tag>.
+	// Zero fields of an override fall back to the default thresholds, so
+	// that, for example, a catalog for an older release that no longer
+	// changes can be given a longer MaxDigestAge alone.
+	Catalogs map[string]Thresholds
+}
+
+// For returns the thresholds of a catalog.
+func (t Thresholds) For(catalog string) Thresholds {
+	o, ok := t.Catalogs[catalog]
+	if !ok {
+		return Thresholds{MaxDigestAge: t.MaxDigestAge, MaxIngestionAge: t.MaxIngestionAge}
+	}
+	return Thresholds{
+		MaxDigestAge:    cmpOr(o.MaxDigestAge, t.MaxDigestAge),
+		MaxIngestionAge: cmpOr(o.MaxIngestionAge, t.MaxIngestionAge),
+	}
+}
+
+func cmpOr(d, def time.Duration) time.Duration {
+	if d != 0 {
+		return d
+	}
+	return def
+}
+
+// Status is the freshness of a catalog.
+type Status struct {
+	// Catalog is the catalog's <name>:<tag>.
+	Catalog string `json:"catalog"`
+
+	// Digest, DigestChangedAt, and IngestedAt are unset for catalogs that
+	// have never been ingested successfully.
+	Digest          string     `json:"digest,omitempty"`
+	DigestChangedAt *time.Time `json:"digestChangedAt,omitempty"`
+	IngestedAt      *time.Time `json:"ingestedAt,omitempty"`
+
+	// StaleDigest and StaleIngestion report whether the digest changed or
+	// the catalog was ingested longer ago than the catalog's thresholds.
+	// Catalogs that have never been ingested have a stale ingestion if
+	// MaxIngestionAge is set.
+	StaleDigest    bool `json:"staleDigest"`
+	StaleIngestion bool `json:"staleIngestion"`
+}
+
+// Check returns the status of each catalog as of now.
+func Check(catalogs []query.CatalogFreshness, t Thresholds, now time.Time) []Status {
+	statuses := make([]Status, 0, len(catalogs))
+	for _, cf := range catalogs {
+		s := Status{Catalog: cf.Name + ":" + cf.Tag, Digest: cf.Digest.String}
+		th := t.For(s.Catalog)
+		if cf.DigestChangedAt.Valid {
+			s.DigestChangedAt = &cf.DigestChangedAt.Time
+			s.StaleDigest = th.MaxDigestAge > 0 && now.Sub(cf.DigestChangedAt.Time) > th.MaxDigestAge
+		}
+		if cf.IngestedAt.Valid {
+			s.IngestedAt = &cf.IngestedAt.Time
+		}
+		s.StaleIngestion = th.MaxIngestionAge > 0 && (!cf.IngestedAt.Valid || now.Sub(cf.IngestedAt.Time) > th.MaxIngestionAge)
+		statuses = append(statuses, s)
+	}
+	return statuses
+}
+
+// Events returns a notification event for each stale digest and ingestion.
+// Each event is dated by the last change or ingestion, so that it is sent
+// once for each time a catalog goes stale.
+func Events(statuses []Status) []notify.Event {
+	var events []notify.Event
+	for _, s := range statuses {
+		if s.StaleDigest {
+			events = append(events, notify.Event{Catalog: s.Catalog, Kind: notify.EventKindStaleDigest, Date: *s.DigestChangedAt})
+		}
+		if s.StaleIngestion {
+			e := notify.Event{Catalog: s.Catalog, Kind: notify.EventKindStaleIngestion}
+			if s.IngestedAt != nil {
+				e.Date = *s.IngestedAt
+			}
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+var (
+	digestAgeDesc = prometheus.NewDesc(
+		"extensiondb_catalog_digest_age_seconds",
+		"Seconds since the digest of a catalog last changed.",
+		[]string{"catalog"}, nil,
+	)
+	ingestionAgeDesc = prometheus.NewDesc(
+		"extensiondb_catalog_ingestion_age_seconds",
+		"Seconds since a catalog was last ingested successfully.",
+		[]string{"catalog"}, nil,
+	)
+	staleDesc = prometheus.NewDesc(
+		"extensiondb_catalog_stale",
+		"Whether a catalog's digest or ingestion is older than its threshold (1) or not (0).",
+		[]string{"catalog", "check"}, nil,
+	)
+)
+
+// Collector is a Prometheus collector that reports the freshness of every
+// catalog each time it is scraped.
+type Collector struct {
+	query      *query.Query
+	thresholds Thresholds
+}
+
+// NewCollector creates a collector that checks catalogs against thresholds.
+func NewCollector(q *query.Query, thresholds Thresholds) *Collector {
+	return &Collector{query: q, thresholds: thresholds}
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- digestAgeDesc
+	ch <- ingestionAgeDesc
+	ch <- staleDesc
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	// Collect has no context; give up before a typical scrape timeout.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	catalogs, err := c.query.ListCatalogFreshness(ctx)
+	if err != nil {
+		log.Printf("error listing catalog freshness: %v", err)
+		ch <- prometheus.NewInvalidMetric(digestAgeDesc, err)
+		return
+	}
+
+	now := time.Now()
+	for _, s := range Check(catalogs, c.thresholds, now) {
+		if s.DigestChangedAt != nil {
+			ch <- prometheus.MustNewConstMetric(digestAgeDesc, prometheus.GaugeValue, now.Sub(*s.DigestChangedAt).Seconds(), s.Catalog)
+		}
+		if s.IngestedAt != nil {
+			ch <- prometheus.MustNewConstMetric(ingestionAgeDesc, prometheus.GaugeValue, now.Sub(*s.IngestedAt).Seconds(), s.Catalog)
+		}
+		ch <- prometheus.MustNewConstMetric(staleDesc, prometheus.GaugeValue, boolValue(s.StaleDigest), s.Catalog, "digest")
+		ch <- prometheus.MustNewConstMetric(staleDesc, prometheus.GaugeValue, boolValue(s.StaleIngestion), s.Catalog, "ingestion")
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
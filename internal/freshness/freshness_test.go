@@ -0,0 +1,73 @@
+package freshness_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/freshness"
+	"github.com/joelanford/extensiondb/internal/notify"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	now       = time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	changedAt = now.Add(-10 * 24 * time.Hour)
+	ingestAt  = now.Add(-2 * time.Hour)
+)
+
+var catalogs = []query.CatalogFreshness{
+	{
+		Name:            "catalog",
+		Tag:             "v1",
+		Digest:          sql.NullString{String: "sha256:abc", Valid: true},
+		DigestChangedAt: sql.NullTime{Time: changedAt, Valid: true},
+		IngestedAt:      sql.NullTime{Time: ingestAt, Valid: true},
+	},
+	{Name: "catalog", Tag: "v2"},
+}
+
+func TestCheck(t *testing.T) {
+	// Without thresholds, nothing is stale.
+	statuses := freshness.Check(catalogs, freshness.Thresholds{}, now)
+	require.Len(t, statuses, 2)
+	assert.Equal(t, freshness.Status{
+		Catalog:         "catalog:v1",
+		Digest:          "sha256:abc",
+		DigestChangedAt: &changedAt,
+		IngestedAt:      &ingestAt,
+	}, statuses[0])
+	assert.Equal(t, freshness.Status{Catalog: "catalog:v2"}, statuses[1])
+
+	// Catalogs that have never been ingested have a stale ingestion.
+	thresholds := freshness.Thresholds{
+		MaxDigestAge:    7 * 24 * time.Hour,
+		MaxIngestionAge: time.Hour,
+	}
+	statuses = freshness.Check(catalogs, thresholds, now)
+	assert.True(t, statuses[0].StaleDigest)
+	assert.True(t, statuses[0].StaleIngestion)
+	assert.False(t, statuses[1].StaleDigest)
+	assert.True(t, statuses[1].StaleIngestion)
+
+	// Overrides fall back to the default thresholds for unset fields.
+	thresholds.Catalogs = map[string]freshness.Thresholds{
+		"catalog:v1": {MaxDigestAge: 30 * 24 * time.Hour},
+	}
+	statuses = freshness.Check(catalogs, thresholds, now)
+	assert.False(t, statuses[0].StaleDigest)
+	assert.True(t, statuses[0].StaleIngestion)
+}
+
+func TestEvents(t *testing.T) {
+	statuses := freshness.Check(catalogs, freshness.Thresholds{
+		MaxDigestAge:    7 * 24 * time.Hour,
+		MaxIngestionAge: 24 * time.Hour,
+	}, now)
+	assert.Equal(t, []notify.Event{
+		{Catalog: "catalog:v1", Kind: notify.EventKindStaleDigest, Date: changedAt},
+		{Catalog: "catalog:v2", Kind: notify.EventKindStaleIngestion},
+	}, freshness.Events(statuses))
+}
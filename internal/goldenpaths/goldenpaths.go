@@ -0,0 +1,214 @@
+// Package goldenpaths records the best update paths between key versions of a
+// package in golden files and verifies them against later graphs, so that
+// catalog changes that break paths which used to be valid are caught before
+// they ship.
+package goldenpaths
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/joelanford/extensiondb/pkg/util"
+)
+
+// Pair names the versions, as <version>[_<release>], between which a path is
+// recorded.
+type Pair struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Path is the best update path between a pair of versions when its golden
+// file was written.
+type Path struct {
+	Pair
+
+	// Nodes are the versions of the path, from From to To.
+	Nodes []string `json:"path"`
+}
+
+// File is the golden file of a package's update paths.
+type File struct {
+	Package string `json:"package"`
+	Paths   []Path `json:"paths"`
+}
+
+// Status is the result of verifying a golden path.
+type Status string
+
+const (
+	// StatusUnchanged means that the golden path is still the best path.
+	StatusUnchanged Status = "unchanged"
+
+	// StatusChanged means that the golden path is still a valid path, but
+	// a different path is now the best one.
+	StatusChanged Status = "changed"
+
+	// StatusBroken means that a version of the golden path is gone, or that
+	// an update between two of its versions is no longer in the graph.
+	StatusBroken Status = "broken"
+)
+
+// Result is the verification of a golden path.
+type Result struct {
+	Package string
+	Path    Path
+	Status  Status
+
+	// Current is the best path between the pair now, or nil if there is
+	// none.
+	Current []string
+
+	// Reason explains why a broken path is broken.
+	Reason string
+}
+
+// KeyPairs returns the pairs whose paths are recorded by default: from the
+// oldest build of each version stream, by major.minor, to the newest build of
+// that stream and to the newest build of the package. Pairs whose versions
+// are the same are left out.
+func KeyPairs(g *graph.Graph, pkg string) []Pair {
+	nodes := slices.SortedFunc(g.NodesMatching(graph.PackageNodes(pkg)), util.Compare)
+	if len(nodes) == 0 {
+		return nil
+	}
+	newest := nodes[len(nodes)-1]
+
+	var pairs []Pair
+	add := func(from, to *graph.Node) {
+		p := Pair{From: from.VR(), To: to.VR()}
+		if p.From != p.To && !slices.Contains(pairs, p) {
+			pairs = append(pairs, p)
+		}
+	}
+	for i := 0; i < len(nodes); {
+		j := i + 1
+		for j < len(nodes) && sameStream(nodes[i].Version, nodes[j].Version) {
+			j++
+		}
+		add(nodes[i], nodes[j-1])
+		add(nodes[i], newest)
+		i = j
+	}
+	return pairs
+}
+
+func sameStream(a, b semver.Version) bool {
+	return a.Major == b.Major && a.Minor == b.Minor
+}
+
+// Snapshot records the best path between each pair in the graph of pkg.
+// Pairs without a path are left out, since there is no valid path to protect.
+// It fails if a version of a pair is not in the graph.
+func Snapshot(g *graph.Graph, pkg string, pairs []Pair) (*File, error) {
+	f := &File{Package: pkg, Paths: []Path{}}
+	for _, p := range pairs {
+		from := findNode(g, pkg, p.From)
+		if from == nil {
+			return nil, fmt.Errorf("package %s has no version %s", pkg, p.From)
+		}
+		to := findNode(g, pkg, p.To)
+		if to == nil {
+			return nil, fmt.Errorf("package %s has no version %s", pkg, p.To)
+		}
+		nodes, _, ok := g.ShortestPath(from, to)
+		if !ok {
+			continue
+		}
+		f.Paths = append(f.Paths, Path{Pair: p, Nodes: versions(nodes)})
+	}
+	return f, nil
+}
+
+// Verify checks each golden path of f against g.
+func Verify(g *graph.Graph, f *File) []Result {
+	results := make([]Result, 0, len(f.Paths))
+	for _, p := range f.Paths {
+		r := Result{Package: f.Package, Path: p}
+		if from, to := findNode(g, f.Package, p.From), findNode(g, f.Package, p.To); from != nil && to != nil {
+			if nodes, _, ok := g.ShortestPath(from, to); ok {
+				r.Current = versions(nodes)
+			}
+		}
+		r.Reason = brokenReason(g, f.Package, p.Nodes)
+		switch {
+		case r.Reason != "":
+			r.Status = StatusBroken
+		case slices.Equal(r.Current, p.Nodes):
+			r.Status = StatusUnchanged
+		default:
+			r.Status = StatusChanged
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// brokenReason returns why a path of versions is no longer a valid path of
+// the graph of pkg, or "" if it still is.
+func brokenReason(g *graph.Graph, pkg string, path []string) string {
+	var prev *graph.Node
+	for _, version := range path {
+		n := findNode(g, pkg, version)
+		if n == nil {
+			return fmt.Sprintf("version %s is no longer in the graph", version)
+		}
+		if prev != nil && math.IsInf(g.EdgeWeight(prev, n), 1) {
+			return fmt.Sprintf("the update from %s to %s is no longer in the graph", prev.VR(), version)
+		}
+		prev = n
+	}
+	return ""
+}
+
+func findNode(g *graph.Graph, pkg, version string) *graph.Node {
+	return g.FirstNodeMatching(graph.AndNodes(graph.PackageNodes(pkg), func(_ *graph.Graph, n *graph.Node) bool {
+		return n.VR() == version
+	}))
+}
+
+func versions(nodes []*graph.Node) []string {
+	return util.MapSlice(nodes, (*graph.Node).VR)
+}
+
+// Broken returns the results whose paths are broken.
+func Broken(results []Result) []Result {
+	var broken []Result
+	for _, r := range results {
+		if r.Status == StatusBroken {
+			broken = append(broken, r)
+		}
+	}
+	return broken
+}
+
+// ReadFile reads a golden file.
+func ReadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("error decoding golden paths %s: %w", path, err)
+	}
+	if f.Package == "" {
+		return nil, fmt.Errorf("golden paths %s name no package", path)
+	}
+	return &f, nil
+}
+
+// WriteFile writes f to path as indented JSON, so that changes to it are
+// easy to review.
+func (f *File) WriteFile(path string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,76 @@
+package goldenpaths_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/joelanford/extensiondb/internal/goldenpaths"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/joelanford/extensiondb/pkg/graph/graphtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testGraph(t *testing.T, versions ...string) *graph.Graph {
+	t.Helper()
+	return graphtest.New(t, graph.Package{
+		Name:    "foo",
+		Streams: graphtest.Streams(graph.MajorMinor{Major: 1, Minor: 0}, graph.MajorMinor{Major: 1, Minor: 1}),
+		Nodes:   graphtest.WithBundleImages(graphtest.Nodes("foo", versions...)),
+	})
+}
+
+func TestSnapshotAndVerify(t *testing.T) {
+	g := testGraph(t, "1.0.0", "1.0.1", "1.1.0", "1.1.1")
+
+	pairs := goldenpaths.KeyPairs(g, "foo")
+	assert.Equal(t, []goldenpaths.Pair{
+		{From: "1.0.0", To: "1.0.1"},
+		{From: "1.0.0", To: "1.1.1"},
+		{From: "1.1.0", To: "1.1.1"},
+	}, pairs)
+	assert.Empty(t, goldenpaths.KeyPairs(g, "bar"))
+
+	_, err := goldenpaths.Snapshot(g, "foo", []goldenpaths.Pair{{From: "1.0.0", To: "2.0.0"}})
+	assert.ErrorContains(t, err, "no version 2.0.0")
+
+	f, err := goldenpaths.Snapshot(g, "foo", pairs)
+	require.NoError(t, err)
+	require.Len(t, f.Paths, 3)
+	for _, p := range f.Paths {
+		assert.Equal(t, p.From, p.Nodes[0])
+		assert.Equal(t, p.To, p.Nodes[len(p.Nodes)-1])
+	}
+
+	path := filepath.Join(t.TempDir(), "foo.json")
+	require.NoError(t, f.WriteFile(path))
+	read, err := goldenpaths.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, f, read)
+
+	for _, r := range goldenpaths.Verify(g, read) {
+		assert.Equal(t, goldenpaths.StatusUnchanged, r.Status, r.Path.Pair)
+		assert.Equal(t, r.Path.Nodes, r.Current)
+	}
+
+	// A golden path that is still valid but no longer the best is changed,
+	// and one with an update that is not in the graph is broken.
+	results := goldenpaths.Verify(g, &goldenpaths.File{Package: "foo", Paths: []goldenpaths.Path{
+		{Pair: goldenpaths.Pair{From: "1.0.0", To: "1.1.1"}, Nodes: []string{"1.0.0", "1.0.1", "1.1.1"}},
+		{Pair: goldenpaths.Pair{From: "1.1.0", To: "1.1.1"}, Nodes: []string{"1.1.0", "1.0.1", "1.1.1"}},
+	}})
+	require.Len(t, results, 2)
+	assert.Equal(t, goldenpaths.StatusChanged, results[0].Status)
+	assert.Equal(t, []string{"1.0.0", "1.1.1"}, results[0].Current)
+	assert.Equal(t, goldenpaths.StatusBroken, results[1].Status)
+	assert.Equal(t, "the update from 1.1.0 to 1.0.1 is no longer in the graph", results[1].Reason)
+
+	// Removing a version breaks the paths through it.
+	g = testGraph(t, "1.0.0", "1.1.0", "1.1.1")
+	results = goldenpaths.Verify(g, read)
+	require.Len(t, results, 3)
+	assert.Equal(t, goldenpaths.StatusBroken, results[0].Status)
+	assert.Equal(t, "version 1.0.1 is no longer in the graph", results[0].Reason)
+	assert.Nil(t, results[0].Current)
+	assert.Equal(t, []goldenpaths.Result{results[0]}, goldenpaths.Broken(results))
+}
@@ -0,0 +1,140 @@
+// Package graphdata renders update graphs in the repository layout used by
+// openshift/cincinnati-graph-data, so that the OpenShift Update Service (OSUS)
+// can serve graphs curated in extensiondb.
+package graphdata
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/joelanford/extensiondb/pkg/util"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sigs.k8s.io/yaml"
+)
+
+// Channel is a file in the channels/ directory. It lists the versions that
+// are available in the channel.
+type Channel struct {
+	Name     string   `json:"name"`
+	Versions []string `json:"versions"`
+}
+
+// BlockedEdge is a file in the blocked-edges/ directory. It removes the edges
+// to a version from every version that matches the From regular expression.
+type BlockedEdge struct {
+	To      string `json:"to"`
+	From    string `json:"from"`
+	URL     string `json:"url,omitempty"`
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
+
+// Options configures an export.
+type Options struct {
+	// URL is linked from every blocked edge, and should describe why
+	// extensiondb removes edges.
+	URL string
+}
+
+// File is a file in a cincinnati-graph-data repository, with a path relative
+// to the root of the repository.
+type File struct {
+	Path string
+	Data []byte
+}
+
+// Export returns the channels and blocked edges for a package in the graph.
+//
+// Every version of the package is in the "stable" channel, and each
+// "stable-<major>.<minor>" channel contains the versions up to and including
+// that minor version, matching the channels served by the Cincinnati
+// endpoint. The graph builder in OSUS derives candidate edges from release
+// metadata rather than from extensiondb, so every update from a lower version
+// that is not an edge in the graph is exported as a blocked edge.
+func Export(g *graph.Graph, pkg string, opts Options) ([]File, error) {
+	nodes := slices.SortedFunc(g.NodesMatching(graph.PackageNodes(pkg)), util.Compare)
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("package %q has no nodes in the graph", pkg)
+	}
+
+	var files []File
+	for _, ch := range channels(g, nodes) {
+		data, err := yaml.Marshal(ch)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{Path: path.Join("channels", ch.Name+".yaml"), Data: data})
+	}
+	for _, be := range blockedEdges(g, nodes, opts) {
+		data, err := yaml.Marshal(be)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{Path: path.Join("blocked-edges", be.Name+".yaml"), Data: data})
+	}
+	return files, nil
+}
+
+func channels(g *graph.Graph, nodes []*graph.Node) []Channel {
+	majorMinors := sets.New[graph.MajorMinor]()
+	for _, n := range nodes {
+		majorMinors.Insert(graph.NewMajorMinorFromVersion(n.Version))
+	}
+
+	chs := []Channel{newChannel(g, "stable", nodes, graph.AllNodes())}
+	mms := majorMinors.UnsortedList()
+	slices.SortFunc(mms, util.Compare)
+	for _, mm := range mms {
+		chs = append(chs, newChannel(g, fmt.Sprintf("stable-%s", mm), nodes, graph.NodesUpToMajorMinor(mm)))
+	}
+	return chs
+}
+
+func newChannel(g *graph.Graph, name string, nodes []*graph.Node, match graph.NodePredicate) Channel {
+	ch := Channel{Name: name, Versions: []string{}}
+	for _, n := range nodes {
+		if match(g, n) {
+			ch.Versions = append(ch.Versions, n.VR())
+		}
+	}
+	return ch
+}
+
+// blockedEdges returns, for each node, a blocked edge from every lower version
+// that does not have an edge to it. Nodes whose lower versions all have an
+// edge to them have no blocked edge.
+func blockedEdges(g *graph.Graph, nodes []*graph.Node, opts Options) []BlockedEdge {
+	var bes []BlockedEdge
+	for i, to := range nodes {
+		var froms []string
+		for _, from := range nodes[:i] {
+			if !hasEdge(g, from, to) {
+				froms = append(froms, regexp.QuoteMeta(from.VR()))
+			}
+		}
+		if len(froms) == 0 {
+			continue
+		}
+		bes = append(bes, BlockedEdge{
+			To:      to.VR(),
+			From:    fmt.Sprintf("^(%s)$", strings.Join(froms, "|")),
+			URL:     opts.URL,
+			Name:    fmt.Sprintf("%s-extensiondb", to.VR()),
+			Message: fmt.Sprintf("extensiondb does not recommend updating to %s from these versions.", to.VR()),
+		})
+	}
+	return bes
+}
+
+func hasEdge(g *graph.Graph, from, to *graph.Node) bool {
+	for n := range g.From(from) {
+		if n == to {
+			return true
+		}
+	}
+	return false
+}
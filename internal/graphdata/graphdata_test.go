@@ -0,0 +1,48 @@
+package graphdata_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/internal/graphdata"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/joelanford/extensiondb/pkg/graph/graphtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExport(t *testing.T) {
+	streams := graphtest.Streams(graph.MajorMinor{Major: 1, Minor: 0}, graph.MajorMinor{Major: 1, Minor: 1})
+	streams[1].MinimumUpdateVersion = semver.MustParse("1.0.1")
+	g := graphtest.New(t, graph.Package{Name: "foo", Streams: streams, Nodes: graphtest.Nodes("foo", "1.0.0", "1.0.1", "1.1.0")})
+
+	files, err := graphdata.Export(g, "foo", graphdata.Options{URL: "https://example.com/foo"})
+	require.NoError(t, err)
+
+	contents := map[string]string{}
+	for _, f := range files {
+		contents[f.Path] = string(f.Data)
+	}
+
+	// 1.0.0 is below the minimum update version of the 1.1 stream, so its
+	// update to 1.1.0 is blocked.
+	assert.Equal(t, map[string]string{
+		"channels/stable.yaml":     "name: stable\nversions:\n- 1.0.0\n- 1.0.1\n- 1.1.0\n",
+		"channels/stable-1.0.yaml": "name: stable-1.0\nversions:\n- 1.0.0\n- 1.0.1\n",
+		"channels/stable-1.1.yaml": "name: stable-1.1\nversions:\n- 1.0.0\n- 1.0.1\n- 1.1.0\n",
+		"blocked-edges/1.1.0-extensiondb.yaml": "from: ^(1\\.0\\.0)$\n" +
+			"message: extensiondb does not recommend updating to 1.1.0 from these versions.\n" +
+			"name: 1.1.0-extensiondb\n" +
+			"to: 1.1.0\n" +
+			"url: https://example.com/foo\n",
+	}, contents)
+}
+
+func TestExportUnknownPackage(t *testing.T) {
+	g, err := graph.NewGraph(t.Context(), graph.GraphConfig{AsOf: time.Now()})
+	require.NoError(t, err)
+
+	_, err = graphdata.Export(g, "foo", graphdata.Options{})
+	assert.Error(t, err)
+}
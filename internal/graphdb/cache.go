@@ -0,0 +1,164 @@
+package graphdb
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/pkg/graph"
+)
+
+// Cache stores the graphs that a Builder builds, encoded by graph.Graph.Encode,
+// so that they are only rebuilt once a new ingestion run finishes.
+type Cache interface {
+	// Get returns the graph cached for key by an ingestion run. It reports
+	// false if there is none.
+	Get(ctx context.Context, runID, key string) ([]byte, bool, error)
+
+	// Put caches a graph for key by an ingestion run. It may drop the graphs
+	// cached by other runs.
+	Put(ctx context.Context, runID, key string, data []byte) error
+}
+
+// DirCache caches graphs in files in a directory, with a subdirectory for
+// each ingestion run. Caching a graph removes the subdirectories of every
+// other run.
+type DirCache string
+
+func (d DirCache) Get(_ context.Context, runID, key string) ([]byte, bool, error) {
+	data, err := os.ReadFile(filepath.Join(string(d), runID, key+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+func (d DirCache) Put(_ context.Context, runID, key string, data []byte) error {
+	runDir := filepath.Join(string(d), runID)
+	if err := os.MkdirAll(runDir, 0o755); err != nil {
+		return err
+	}
+	// Write to a temporary file first, so that concurrent builds never read
+	// a partially written graph.
+	f, err := os.CreateTemp(runDir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return errors.Join(err, os.Remove(f.Name()))
+	}
+	if err := f.Close(); err != nil {
+		return errors.Join(err, os.Remove(f.Name()))
+	}
+	if err := os.Rename(f.Name(), filepath.Join(runDir, key+".json")); err != nil {
+		return errors.Join(err, os.Remove(f.Name()))
+	}
+
+	entries, err := os.ReadDir(string(d))
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, e := range entries {
+		if e.IsDir() && e.Name() != runID {
+			errs = append(errs, os.RemoveAll(filepath.Join(string(d), e.Name())))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DBCache caches graphs in the database.
+type DBCache struct {
+	Query *query.Query
+}
+
+func (c DBCache) Get(ctx context.Context, runID, key string) ([]byte, bool, error) {
+	data, err := c.Query.GetCachedGraph(ctx, runID, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+func (c DBCache) Put(ctx context.Context, runID, key string, data []byte) error {
+	return c.Query.PutCachedGraph(ctx, runID, key, data)
+}
+
+// cachedBuild returns the graph cached for the templates, options, and day of
+// asOf by the latest ingestion run, building and caching it if there is none.
+// Lifecycle phases change on dates, so a graph built on the same day has the
+// same phases. Graphs are always built if no ingestion run has finished.
+func (b *Builder) cachedBuild(ctx context.Context, templates []graph.Template, asOf time.Time) (*graph.Graph, error) {
+	run, err := query.New(b.db).GetLatestIngestionRun(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return b.build(ctx, templates, asOf)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting latest ingestion run: %w", err)
+	}
+	key, err := b.cacheKey(templates, asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	data, ok, err := b.Cache.Get(ctx, run.ID, key)
+	if err != nil {
+		return nil, fmt.Errorf("error reading cached graph: %w", err)
+	}
+	if ok {
+		// A graph that can't be decoded, such as one written by an older
+		// version, is built again and replaced.
+		g, err := graph.DecodeGraph(ctx, bytes.NewReader(data))
+		if err == nil {
+			return g, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+	}
+
+	g, err := b.build(ctx, templates, asOf)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := g.Encode(&buf); err != nil {
+		return nil, fmt.Errorf("error encoding graph: %w", err)
+	}
+	if err := b.Cache.Put(ctx, run.ID, key, buf.Bytes()); err != nil {
+		return nil, fmt.Errorf("error caching graph: %w", err)
+	}
+	return g, nil
+}
+
+// cacheKey identifies the graph built from the templates with the builder's
+// options on the day of asOf.
+func (b *Builder) cacheKey(templates []graph.Template, asOf time.Time) (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%t\x00%t\x00", asOf.UTC().Format(time.DateOnly), b.SkipInvalidNodes, b.CollapseRebuilds)
+	for _, tmpl := range templates {
+		data, err := json.Marshal(tmpl)
+		if err != nil {
+			return "", fmt.Errorf("error marshaling template %q: %w", tmpl.Name, err)
+		}
+		h.Write(data)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
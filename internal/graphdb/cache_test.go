@@ -0,0 +1,37 @@
+package graphdb_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/joelanford/extensiondb/internal/graphdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirCache(t *testing.T) {
+	dir := t.TempDir()
+	cache := graphdb.DirCache(dir)
+
+	_, ok, err := cache.Get(t.Context(), "run-1", "foo")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, cache.Put(t.Context(), "run-1", "foo", []byte("v1")))
+	require.NoError(t, cache.Put(t.Context(), "run-1", "foo", []byte("v2")))
+	require.NoError(t, cache.Put(t.Context(), "run-1", "bar", []byte("v3")))
+	data, ok, err := cache.Get(t.Context(), "run-1", "foo")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("v2"), data)
+
+	// Caching a graph for another run drops those of the earlier run.
+	require.NoError(t, cache.Put(t.Context(), "run-2", "foo", []byte("v4")))
+	_, ok, err = cache.Get(t.Context(), "run-1", "bar")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "run-2", entries[0].Name())
+}
@@ -0,0 +1,292 @@
+package graphdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/internal/templateloader"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/lib/pq"
+	"go.podman.io/image/v5/docker/reference"
+	"golang.org/x/sync/errgroup"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Builder builds update graphs from olm.cincinnati templates and the bundles
+// stored in the database.
+type Builder struct {
+	db *sql.DB
+
+	// SkipInvalidNodes builds graphs from the valid nodes alone, as with
+	// graph.GraphConfig.SkipInvalidNodes.
+	SkipInvalidNodes bool
+
+	// CollapseRebuilds makes a single node of each version that was built
+	// more than once, as with graph.GraphConfig.CollapseRebuilds.
+	CollapseRebuilds bool
+
+	// MinimumUpdateVersionOverrides applies the minimum update versions set
+	// by query.SetMinimumUpdateVersionOverride over those of the templates'
+	// version streams, so that they can be tightened without changing the
+	// templates.
+	MinimumUpdateVersionOverrides bool
+
+	// Concurrency is how many packages' bundles to query at once when
+	// building a graph of several packages. The queries share the builder's
+	// connection pool, so it should allow at least this many open
+	// connections. If zero, packages are queried one at a time.
+	Concurrency int
+
+	// Cache, if set, stores built graphs, which are reused until a new
+	// ingestion run finishes.
+	Cache Cache
+}
+
+// New creates a new graph builder that applies minimum update version
+// overrides.
+func New(db *sql.DB) *Builder {
+	return &Builder{db: db, MinimumUpdateVersionOverrides: true}
+}
+
+// ReadTemplatesDir reads and validates every olm.cincinnati template in a
+// directory, or in the files matched by a glob pattern.
+func ReadTemplatesDir(path string) ([]graph.Template, error) {
+	return templateloader.Load(context.Background(), templateloader.Dir(path))
+}
+
+// ImportVersionStreams stores the version streams of each template, and
+// their lifecycle dates, in the database, replacing those stored for the
+// template's package. The streams of packages without a template are kept.
+func ImportVersionStreams(ctx context.Context, q *query.Query, templates []graph.Template) error {
+	for _, tmpl := range templates {
+		if err := q.SetVersionStreams(ctx, tmpl.Name, tmpl.VersionStreams); err != nil {
+			return fmt.Errorf("error importing version streams of package %q: %w", tmpl.Name, err)
+		}
+	}
+	return nil
+}
+
+// Build builds a graph containing the packages described by the templates.
+func (b *Builder) Build(ctx context.Context, templates []graph.Template, asOf time.Time) (*graph.Graph, error) {
+	templates, err := b.ApplyOverrides(ctx, templates)
+	if err != nil {
+		return nil, err
+	}
+	if b.Cache != nil {
+		return b.cachedBuild(ctx, templates, asOf)
+	}
+	return b.build(ctx, templates, asOf)
+}
+
+func (b *Builder) build(ctx context.Context, templates []graph.Template, asOf time.Time) (*graph.Graph, error) {
+	packages, err := b.packages(ctx, templates)
+	if err != nil {
+		return nil, err
+	}
+
+	return graph.NewGraph(ctx, graph.GraphConfig{
+		Packages:         packages,
+		AsOf:             asOf,
+		IncludePreGA:     false,
+		SkipInvalidNodes: b.SkipInvalidNodes,
+		CollapseRebuilds: b.CollapseRebuilds,
+		PathScope:        graph.PathScopePackage,
+	})
+}
+
+// Package looks up the bundles for each of the template's images and returns
+// them as a graph package.
+func (b *Builder) Package(ctx context.Context, tmpl graph.Template) (graph.Package, error) {
+	templates, err := b.ApplyOverrides(ctx, []graph.Template{tmpl})
+	if err != nil {
+		return graph.Package{}, err
+	}
+	return b.pkg(ctx, templates[0])
+}
+
+// packages queries the packages of the templates, up to b.Concurrency at
+// once, and returns them in the order of the templates. The errors of every
+// package that could not be queried are returned together, in the same order.
+func (b *Builder) packages(ctx context.Context, templates []graph.Template) ([]graph.Package, error) {
+	packages := make([]graph.Package, len(templates))
+	errs := make([]error, len(templates))
+	var eg errgroup.Group
+	eg.SetLimit(max(b.Concurrency, 1))
+	for i, tmpl := range templates {
+		eg.Go(func() error {
+			packages[i], errs[i] = b.pkg(ctx, tmpl)
+			return nil
+		})
+	}
+	_ = eg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return packages, nil
+}
+
+func (b *Builder) pkg(ctx context.Context, tmpl graph.Template) (graph.Package, error) {
+	nodes, err := b.queryNodes(ctx, tmpl.Images)
+	if err != nil {
+		return graph.Package{}, fmt.Errorf("error querying nodes for package %q: %w", tmpl.Name, err)
+	}
+	return graph.Package{
+		Name:                tmpl.Name,
+		Nodes:               nodes,
+		Streams:             tmpl.VersionStreams,
+		MajorVersionBridges: tmpl.MajorVersionBridges,
+	}, nil
+}
+
+// ApplyOverrides returns the templates with the overrides enabled by the
+// builder's options applied, leaving the given templates unchanged. Graphs
+// are built from the templates it returns, so they also identify the graphs
+// that the builder builds.
+func (b *Builder) ApplyOverrides(ctx context.Context, templates []graph.Template) ([]graph.Template, error) {
+	if !b.MinimumUpdateVersionOverrides || len(templates) == 0 {
+		return templates, nil
+	}
+	packageNames := make([]string, 0, len(templates))
+	for _, tmpl := range templates {
+		packageNames = append(packageNames, tmpl.Name)
+	}
+	overrides, err := query.New(b.db).ListMinimumUpdateVersionOverrides(ctx, packageNames...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing minimum update version overrides: %w", err)
+	}
+	if len(overrides) == 0 {
+		return templates, nil
+	}
+	minVersions := map[string]map[graph.MajorMinor]semver.Version{}
+	for _, o := range overrides {
+		if minVersions[o.PackageName] == nil {
+			minVersions[o.PackageName] = map[graph.MajorMinor]semver.Version{}
+		}
+		minVersions[o.PackageName][o.Stream] = o.MinimumUpdateVersion
+	}
+
+	overridden := slices.Clone(templates)
+	for i, tmpl := range overridden {
+		byStream, ok := minVersions[tmpl.Name]
+		if !ok {
+			continue
+		}
+		tmpl.VersionStreams = slices.Clone(tmpl.VersionStreams)
+		for j, stream := range tmpl.VersionStreams {
+			if v, ok := byStream[stream.Version]; ok {
+				tmpl.VersionStreams[j].MinimumUpdateVersion = v
+			}
+		}
+		overridden[i] = tmpl
+	}
+	return overridden, nil
+}
+
+// InferTemplate returns a template for a package that has none, with every
+// stored image of the package and version streams inferred from its bundles
+// by graph.InferVersionStreams.
+func (b *Builder) InferTemplate(ctx context.Context, pkgName string) (graph.Template, error) {
+	refs, err := b.queryPackageReferences(ctx, pkgName)
+	if err != nil {
+		return graph.Template{}, fmt.Errorf("error querying images of package %q: %w", pkgName, err)
+	}
+	if len(refs) == 0 {
+		return graph.Template{}, fmt.Errorf("no images found for package %q", pkgName)
+	}
+	nodes, err := b.queryNodes(ctx, refs)
+	if err != nil {
+		return graph.Template{}, fmt.Errorf("error querying nodes for package %q: %w", pkgName, err)
+	}
+	return graph.Template{
+		Schema:         graph.SchemaCincinnati,
+		Name:           pkgName,
+		VersionStreams: graph.InferVersionStreams(nodes),
+		Images:         refs,
+	}, nil
+}
+
+func (b *Builder) queryPackageReferences(ctx context.Context, pkgName string) ([]graph.CanonicalReference, error) {
+	rows, err := b.db.QueryContext(ctx, `SELECT DISTINCT br.repo, br.digest FROM bundles as b JOIN packages as p ON p.id = b.package_id JOIN bundle_reference_bundles as brb ON brb.bundle_id = b.id JOIN bundle_references as br ON br.id = brb.bundle_reference_id WHERE p.name = $1 ORDER BY br.repo, br.digest`, pkgName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refs []graph.CanonicalReference
+	for rows.Next() {
+		var repo, dgst string
+		if err := rows.Scan(&repo, &dgst); err != nil {
+			return nil, err
+		}
+		named, err := reference.ParseNormalizedNamed(repo + "@" + dgst)
+		if err != nil {
+			return nil, err
+		}
+		canonical, ok := named.(reference.Canonical)
+		if !ok {
+			return nil, fmt.Errorf("%s@%s is not a canonical reference", repo, dgst)
+		}
+		refs = append(refs, graph.CanonicalReference{Canonical: canonical})
+	}
+	return refs, rows.Err()
+}
+
+func (b *Builder) queryNodes(ctx context.Context, refs []graph.CanonicalReference) ([]*graph.Node, error) {
+	if len(refs) == 0 {
+		return nil, nil
+	}
+
+	placeholders := make([]string, 0, len(refs))
+	params := make([]any, 0, len(refs)*2)
+	for i, ref := range refs {
+		a := i*2 + 1
+		b := a + 1
+		placeholders = append(placeholders, fmt.Sprintf("($%d,$%d)", a, b))
+		params = append(params, ref.Name(), ref.Digest().String())
+	}
+	refLookup := map[string]reference.Canonical{}
+	for _, ref := range refs {
+		refLookup[ref.String()] = ref
+	}
+
+	query := fmt.Sprintf(`SELECT p.name, b.version, b.release, (br.repo || '@' || br.digest) as reference, (b.image ->> 'created')::timestamp as built_at, COALESCE(bv.verified, false) as verified, COALESCE(pm.certified, false) as certified, ARRAY(SELECT DISTINCT c.name || ':' || c.tag FROM catalogs as c JOIN LATERAL (SELECT id FROM catalog_digests WHERE catalog_id = c.id ORDER BY created_at DESC LIMIT 1) as ld ON true JOIN catalog_digest_bundle_references as cdbr ON cdbr.catalog_digest_id = ld.id JOIN bundle_reference_bundles as cbrb ON cbrb.bundle_reference_id = cdbr.bundle_reference_id WHERE cbrb.bundle_id = b.id) as catalogs, COALESCE(b.content_fingerprint, '') as content_fingerprint FROM bundles as b JOIN packages as p ON p.id = b.package_id JOIN bundle_reference_bundles as brb ON brb.bundle_id = b.id JOIN bundle_references as br ON br.id = brb.bundle_reference_id LEFT JOIN bundle_verifications as bv ON bv.bundle_id = b.id LEFT JOIN bundle_pyxis_metadata as pm ON pm.bundle_id = b.id WHERE (br.repo, br.digest) IN (%s) ORDER BY built_at ASC`, strings.Join(placeholders, ","))
+	rows, err := b.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var nodes []*graph.Node
+	for rows.Next() {
+		var (
+			n        graph.Node
+			ref      string
+			catalogs []string
+		)
+		if err := rows.Scan(&n.Name, &n.Version, &n.Release, &ref, &n.ReleaseDate, &n.Verified, &n.Certified, pq.Array(&catalogs), &n.ContentFingerprint); err != nil {
+			return nil, err
+		}
+		n.ImageReference = refLookup[ref]
+		n.Catalogs = sets.New[graph.Catalog]()
+		for _, c := range catalogs {
+			catalog, err := graph.ParseCatalog(c)
+			if err != nil {
+				return nil, err
+			}
+			n.Catalogs.Insert(catalog)
+		}
+		nodes = append(nodes, &n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return nodes, nil
+}
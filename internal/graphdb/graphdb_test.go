@@ -0,0 +1,86 @@
+package graphdb_test
+
+import (
+	"context"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/internal/graphdb"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/internal/synthetic"
+	"github.com/joelanford/extensiondb/pkg/dbtest"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(dbtest.Main(m))
+}
+
+func TestApplyOverrides(t *testing.T) {
+	db := dbtest.New(t)
+	v10, v11 := graph.MajorMinor{Major: 1, Minor: 0}, graph.MajorMinor{Major: 1, Minor: 1}
+	templates := []graph.Template{
+		{Name: "foo", VersionStreams: []graph.VersionStream{
+			{Version: v10, MinimumUpdateVersion: semver.MustParse("1.0.0")},
+			{Version: v11, MinimumUpdateVersion: semver.MustParse("1.0.0")},
+		}},
+		{Name: "bar", VersionStreams: []graph.VersionStream{
+			{Version: v11, MinimumUpdateVersion: semver.MustParse("1.0.0")},
+		}},
+	}
+	require.NoError(t, query.New(db).SetMinimumUpdateVersionOverride(t.Context(), "foo", v11, semver.MustParse("1.0.3")))
+
+	builder := graphdb.New(db)
+	overridden, err := builder.ApplyOverrides(t.Context(), templates)
+	require.NoError(t, err)
+	assert.Equal(t, semver.MustParse("1.0.0"), overridden[0].VersionStreams[0].MinimumUpdateVersion)
+	assert.Equal(t, semver.MustParse("1.0.3"), overridden[0].VersionStreams[1].MinimumUpdateVersion)
+	assert.Equal(t, templates[1], overridden[1])
+	assert.Equal(t, semver.MustParse("1.0.0"), templates[0].VersionStreams[1].MinimumUpdateVersion, "the given templates are unchanged")
+
+	builder.MinimumUpdateVersionOverrides = false
+	overridden, err = builder.ApplyOverrides(t.Context(), templates)
+	require.NoError(t, err)
+	assert.Equal(t, templates, overridden)
+}
+
+func TestBuildConcurrency(t *testing.T) {
+	db := dbtest.New(t)
+	d, err := synthetic.Generate(synthetic.Config{Seed: 1, Packages: 6, MinorsPerMajor: 3})
+	require.NoError(t, err)
+	require.NoError(t, d.Load(t.Context(), query.New(db)))
+	asOf := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	serial, err := graphdb.New(db).Build(t.Context(), d.Templates(), asOf)
+	require.NoError(t, err)
+	builder := graphdb.New(db)
+	builder.Concurrency = 4
+	concurrent, err := builder.Build(t.Context(), d.Templates(), asOf)
+	require.NoError(t, err)
+
+	nvrs := func(g *graph.Graph) []string {
+		var out []string
+		for n := range g.NodesMatching(graph.AllNodes()) {
+			out = append(out, n.NVR())
+		}
+		slices.Sort(out)
+		return out
+	}
+	assert.NotEmpty(t, nvrs(serial))
+	assert.Equal(t, nvrs(serial), nvrs(concurrent))
+
+	// The errors of every package are returned, not just the first.
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	builder.MinimumUpdateVersionOverrides = false
+	_, err = builder.Build(ctx, d.Templates(), asOf)
+	require.ErrorIs(t, err, context.Canceled)
+	for _, tmpl := range d.Templates() {
+		assert.ErrorContains(t, err, tmpl.Name)
+	}
+}
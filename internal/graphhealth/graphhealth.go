@@ -0,0 +1,215 @@
+// Package graphhealth measures signs of rot in update graphs and exposes them
+// as Prometheus metrics.
+package graphhealth
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/graphdb"
+	"github.com/joelanford/extensiondb/internal/jira"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/joelanford/extensiondb/pkg/util"
+	"github.com/opencontainers/go-digest"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.podman.io/image/v5/docker/reference"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Report is the health of the update graphs of a set of templates.
+type Report struct {
+	// DeadEnds is the number of dead-end nodes of each package.
+	DeadEnds map[string]int
+
+	// Orphans is the number of orphaned nodes of each package.
+	Orphans map[string]int
+
+	// EndOfLifeInCatalogs counts the end-of-life nodes that still ship in
+	// each catalog.
+	EndOfLifeInCatalogs []CatalogCount
+
+	// Heads are the nodes without successors, other than pre-GA nodes.
+	Heads []Head
+}
+
+// CatalogCount is a count of the nodes of a package that ship in a catalog.
+type CatalogCount struct {
+	Catalog string
+	Package string
+	Count   int
+}
+
+// Head is a node without successors.
+type Head struct {
+	Package string
+	Version string
+
+	// Age is the time since the head was released.
+	Age time.Duration
+
+	// UntilEndOfLife is the time until the end of life of the head's version
+	// stream. It is negative once the stream has reached its end of life.
+	UntilEndOfLife time.Duration
+}
+
+// Measure measures the health of g, which was built from templates as of
+// asOf. shipped holds the digests of the bundles in each catalog, keyed by
+// <name>:<tag>.
+func Measure(g *graph.Graph, templates []graph.Template, shipped map[string]sets.Set[digest.Digest], asOf time.Time) Report {
+	r := Report{DeadEnds: make(map[string]int, len(templates)), Orphans: make(map[string]int, len(templates))}
+	for _, tmpl := range templates {
+		r.DeadEnds[tmpl.Name] = len(jira.DeadEnds(g, tmpl.Name))
+		r.Orphans[tmpl.Name] = len(jira.Orphans(g, tmpl.Name))
+
+		streams := make(map[graph.MajorMinor]graph.VersionStream, len(tmpl.VersionStreams))
+		for _, vs := range tmpl.VersionStreams {
+			streams[vs.Version] = vs
+		}
+		nodes := slices.SortedFunc(g.NodesMatching(graph.PackageNodes(tmpl.Name)), util.Compare)
+		for _, n := range nodes {
+			if !g.HeadsFor(tmpl.Name).Has(n) || n.LifecyclePhase == graph.LifecyclePhasePreGA {
+				continue
+			}
+			vs, ok := streams[graph.NewMajorMinorFromVersion(n.Version)]
+			if !ok {
+				continue
+			}
+			r.Heads = append(r.Heads, Head{
+				Package:        tmpl.Name,
+				Version:        n.VR(),
+				Age:            asOf.Sub(n.ReleaseDate),
+				UntilEndOfLife: vs.LifecycleDates.EndOfLife.Time().Sub(asOf),
+			})
+		}
+
+		for catalog, digests := range shipped {
+			count := 0
+			for _, n := range nodes {
+				if n.LifecyclePhase == graph.LifecyclePhaseEndOfLife && slices.ContainsFunc(n.ImageReferences(), func(ref reference.Canonical) bool {
+					return digests.Has(ref.Digest())
+				}) {
+					count++
+				}
+			}
+			r.EndOfLifeInCatalogs = append(r.EndOfLifeInCatalogs, CatalogCount{Catalog: catalog, Package: tmpl.Name, Count: count})
+		}
+	}
+	slices.SortFunc(r.EndOfLifeInCatalogs, func(a, b CatalogCount) int {
+		return cmp.Or(cmp.Compare(a.Catalog, b.Catalog), cmp.Compare(a.Package, b.Package))
+	})
+	return r
+}
+
+var (
+	deadEndsDesc = prometheus.NewDesc(
+		"extensiondb_graph_dead_end_nodes",
+		"Number of nodes without successors even though a newer version of the same major version exists.",
+		[]string{"package"}, nil,
+	)
+	orphansDesc = prometheus.NewDesc(
+		"extensiondb_graph_orphan_nodes",
+		"Number of nodes without predecessors even though an older version of the same major version exists.",
+		[]string{"package"}, nil,
+	)
+	endOfLifeInCatalogDesc = prometheus.NewDesc(
+		"extensiondb_graph_end_of_life_nodes_in_catalog",
+		"Number of end-of-life nodes that still ship in a catalog.",
+		[]string{"catalog", "package"}, nil,
+	)
+	headDaysUntilEndOfLifeDesc = prometheus.NewDesc(
+		"extensiondb_graph_head_days_until_end_of_life",
+		"Days until the end of life of a head's version stream; negative once it has passed.",
+		[]string{"package", "version"}, nil,
+	)
+	headAgeDaysDesc = prometheus.NewDesc(
+		"extensiondb_graph_head_age_days",
+		"Days since a head was released.",
+		[]string{"package", "version"}, nil,
+	)
+)
+
+// Collector is a Prometheus collector that measures the health of the update
+// graphs of a set of templates each time it is scraped.
+type Collector struct {
+	query     *query.Query
+	builder   *graphdb.Builder
+	templates []graph.Template
+}
+
+// NewCollector creates a collector for the graphs of templates.
+func NewCollector(q *query.Query, builder *graphdb.Builder, templates []graph.Template) *Collector {
+	return &Collector{query: q, builder: builder, templates: templates}
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- deadEndsDesc
+	ch <- orphansDesc
+	ch <- endOfLifeInCatalogDesc
+	ch <- headDaysUntilEndOfLifeDesc
+	ch <- headAgeDaysDesc
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	// Collect has no context; give up before a typical scrape timeout.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	r, err := c.measure(ctx, time.Now())
+	if err != nil {
+		log.Printf("error measuring graph health: %v", err)
+		ch <- prometheus.NewInvalidMetric(deadEndsDesc, err)
+		return
+	}
+
+	const day = 24 * time.Hour
+	for pkg, count := range r.DeadEnds {
+		ch <- prometheus.MustNewConstMetric(deadEndsDesc, prometheus.GaugeValue, float64(count), pkg)
+	}
+	for pkg, count := range r.Orphans {
+		ch <- prometheus.MustNewConstMetric(orphansDesc, prometheus.GaugeValue, float64(count), pkg)
+	}
+	for _, cc := range r.EndOfLifeInCatalogs {
+		ch <- prometheus.MustNewConstMetric(endOfLifeInCatalogDesc, prometheus.GaugeValue, float64(cc.Count), cc.Catalog, cc.Package)
+	}
+	for _, h := range r.Heads {
+		ch <- prometheus.MustNewConstMetric(headDaysUntilEndOfLifeDesc, prometheus.GaugeValue, h.UntilEndOfLife.Hours()/day.Hours(), h.Package, h.Version)
+		ch <- prometheus.MustNewConstMetric(headAgeDaysDesc, prometheus.GaugeValue, h.Age.Hours()/day.Hours(), h.Package, h.Version)
+	}
+}
+
+func (c *Collector) measure(ctx context.Context, now time.Time) (Report, error) {
+	g, err := c.builder.Build(ctx, c.templates, now)
+	if err != nil {
+		return Report{}, fmt.Errorf("error building graph: %w", err)
+	}
+
+	packageNames := make([]string, 0, len(c.templates))
+	for _, tmpl := range c.templates {
+		packageNames = append(packageNames, tmpl.Name)
+	}
+	catalogs, err := c.query.ListCatalogs(ctx)
+	if err != nil {
+		return Report{}, fmt.Errorf("error listing catalogs: %w", err)
+	}
+	shipped := make(map[string]sets.Set[digest.Digest], len(catalogs))
+	for _, cat := range catalogs {
+		bundles, err := c.query.GetBundlesInCatalog(ctx, cat, packageNames)
+		if err != nil {
+			return Report{}, fmt.Errorf("error getting bundles in catalog %s:%s: %w", cat.Name, cat.Tag, err)
+		}
+		digests := sets.New[digest.Digest]()
+		for _, cb := range bundles {
+			if _, dgst, ok := strings.Cut(cb.Image, "@"); ok {
+				digests.Insert(digest.Digest(dgst))
+			}
+		}
+		shipped[cat.Name+":"+cat.Tag] = digests
+	}
+	return Measure(g, c.templates, shipped, now), nil
+}
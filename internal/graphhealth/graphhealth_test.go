@@ -0,0 +1,52 @@
+package graphhealth_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/graphhealth"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/joelanford/extensiondb/pkg/graph/graphtest"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestMeasure(t *testing.T) {
+	tmpl := graph.Template{
+		Name: "foo",
+		VersionStreams: []graph.VersionStream{
+			{Version: graph.MajorMinor{Major: 1, Minor: 0}, LifecycleDates: graph.LifecycleDates{
+				FullSupport: graph.NewDate(2024, 1, 1),
+				Maintenance: graph.NewDate(2024, 6, 1),
+				EndOfLife:   graph.NewDate(2024, 12, 1),
+			}},
+			{Version: graph.MajorMinor{Major: 1, Minor: 1}, LifecycleDates: graph.LifecycleDates{
+				FullSupport: graph.NewDate(2024, 3, 1),
+				Maintenance: graph.NewDate(2029, 1, 1),
+				EndOfLife:   graph.NewDate(2030, 1, 1),
+			}},
+		},
+	}
+	g := graphtest.New(t, graph.Package{Name: "foo", Streams: tmpl.VersionStreams, Nodes: graphtest.WithBundleImages(graphtest.Nodes("foo", "1.0.0", "1.0.1", "1.1.0"))})
+	digests := map[string]digest.Digest{"1.0.0": graphtest.Digest(0), "1.0.1": graphtest.Digest(1), "1.1.0": graphtest.Digest(2)}
+	asOf := graphtest.AsOf
+
+	r := graphhealth.Measure(g, []graph.Template{tmpl}, map[string]sets.Set[digest.Digest]{
+		"example-index:v1": sets.New(digests["1.0.0"], digests["1.1.0"]),
+		"example-index:v2": sets.New(digests["1.1.0"]),
+	}, asOf)
+
+	assert.Equal(t, map[string]int{"foo": 0}, r.DeadEnds)
+	assert.Equal(t, map[string]int{"foo": 0}, r.Orphans)
+	assert.Equal(t, []graphhealth.CatalogCount{
+		{Catalog: "example-index:v1", Package: "foo", Count: 1},
+		{Catalog: "example-index:v2", Package: "foo", Count: 0},
+	}, r.EndOfLifeInCatalogs)
+	assert.Contains(t, r.Heads, graphhealth.Head{
+		Package:        "foo",
+		Version:        "1.1.0",
+		Age:            asOf.Sub(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)),
+		UntilEndOfLife: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC).Sub(asOf),
+	})
+}
@@ -0,0 +1,187 @@
+// Package helm ingests the charts of Helm chart repositories as extensions.
+package helm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/internal/ingest"
+	"github.com/joelanford/extensiondb/internal/models"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.podman.io/image/v5/docker/reference"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// ConfigMediaType and ChartMediaType are the media types of the config
+	// and content of a Helm chart stored as an OCI artifact. They are used
+	// to describe charts from chart repositories in the same way.
+	ConfigMediaType = "application/vnd.cncf.helm.config.v1+json"
+	ChartMediaType  = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+
+	// ChartPropertyType is the type of the bundle property that records a
+	// chart's appVersion and kubeVersion.
+	ChartPropertyType = "helm.chart"
+)
+
+// Repository is an ingest.Source of the charts in a Helm chart repository.
+// Each chart version is referenced as <host>/<path>/<chart>@<digest>, where
+// the digest is the one recorded in the repository's index.yaml.
+type Repository struct {
+	// URL is the base URL of the repository, which serves index.yaml.
+	URL string
+
+	// Client is the HTTP client used to fetch the index. If nil,
+	// http.DefaultClient is used.
+	Client *http.Client
+
+	// Packages selects the charts that are listed, by chart name.
+	Packages ingest.PackageFilter
+
+	mu       sync.Mutex
+	versions map[digest.Digest]chartVersion
+}
+
+type index struct {
+	Entries map[string][]chartVersion `json:"entries"`
+}
+
+type chartVersion struct {
+	Name        string            `json:"name"`
+	Version     string            `json:"version"`
+	AppVersion  string            `json:"appVersion,omitempty"`
+	KubeVersion string            `json:"kubeVersion,omitempty"`
+	Created     time.Time         `json:"created"`
+	Digest      string            `json:"digest"`
+	URLs        []string          `json:"urls"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ListReferences implements ingest.Source. The digest is that of the
+// repository's index.yaml. Chart versions without a digest are skipped.
+func (r *Repository) ListReferences(ctx context.Context) (digest.Digest, []reference.Canonical, error) {
+	data, err := r.fetchIndex(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	var idx index
+	if err := yaml.Unmarshal(data, &idx); err != nil {
+		return "", nil, fmt.Errorf("invalid index of %s: %w", r.URL, err)
+	}
+
+	versions := map[digest.Digest]chartVersion{}
+	var refs []reference.Canonical
+	for name, cvs := range idx.Entries {
+		if !r.Packages.Matches(name) {
+			continue
+		}
+		named, err := r.chartName(name)
+		if err != nil {
+			return "", nil, err
+		}
+		for _, cv := range cvs {
+			if cv.Digest == "" {
+				continue
+			}
+			dgst := digest.NewDigestFromEncoded(digest.SHA256, cv.Digest)
+			if err := dgst.Validate(); err != nil {
+				return "", nil, fmt.Errorf("chart %s version %s: invalid digest: %w", name, cv.Version, err)
+			}
+			ref, err := reference.WithDigest(named, dgst)
+			if err != nil {
+				return "", nil, err
+			}
+			cv.Name = name
+			versions[dgst] = cv
+			refs = append(refs, ref)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.versions = versions
+	return digest.FromBytes(data), refs, nil
+}
+
+// FetchMetadata implements ingest.Source from the chart's entry in the index
+// most recently read by ListReferences.
+func (r *Repository) FetchMetadata(_ context.Context, ref reference.Canonical) (*ingest.Metadata, error) {
+	r.mu.Lock()
+	cv, ok := r.versions[ref.Digest()]
+	r.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%s is not listed in the index of %s", ref, r.URL)
+	}
+
+	version, err := semver.ParseTolerant(cv.Version)
+	if err != nil {
+		return nil, fmt.Errorf("chart %s has an invalid version %q: %w", cv.Name, cv.Version, err)
+	}
+	prop, err := json.Marshal(struct {
+		AppVersion  string `json:"appVersion,omitempty"`
+		KubeVersion string `json:"kubeVersion,omitempty"`
+	}{cv.AppVersion, cv.KubeVersion})
+	if err != nil {
+		return nil, err
+	}
+
+	chart := ocispec.Descriptor{MediaType: ChartMediaType, Digest: ref.Digest(), URLs: cv.URLs}
+	return &ingest.Metadata{
+		PackageName: cv.Name,
+		Version:     version.String(),
+		Descriptor:  chart,
+		Manifest: ocispec.Manifest{
+			MediaType: ocispec.MediaTypeImageManifest,
+			Config:    ocispec.Descriptor{MediaType: ConfigMediaType},
+			Layers:    []ocispec.Descriptor{chart},
+		},
+		Image: ocispec.Image{
+			Created: &cv.Created,
+			Config:  ocispec.ImageConfig{Labels: cv.Annotations},
+		},
+		Properties: []models.BundleProperty{{Type: ChartPropertyType, Value: prop}},
+	}, nil
+}
+
+func (r *Repository) fetchIndex(ctx context.Context) ([]byte, error) {
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(r.URL, "/")+"/index.yaml", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching index of %s: %s", r.URL, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// chartName returns the reference name of a chart in the repository.
+func (r *Repository) chartName(chart string) (reference.Named, error) {
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository URL %q: %w", r.URL, err)
+	}
+	named, err := reference.ParseNamed(path.Join(u.Host, u.Path, chart))
+	if err != nil {
+		return nil, fmt.Errorf("chart %s of %s can't be referenced: %w", chart, r.URL, err)
+	}
+	return named, nil
+}
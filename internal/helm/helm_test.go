@@ -0,0 +1,118 @@
+package helm_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/helm"
+	"github.com/joelanford/extensiondb/internal/ingest"
+	"github.com/joelanford/extensiondb/internal/models"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/pkg/dbtest"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(dbtest.Main(m))
+}
+
+var chartDigest = digest.FromString("foo-1.0.0.tgz")
+
+var indexYAML = fmt.Sprintf(`apiVersion: v1
+entries:
+  foo:
+  - name: foo
+    version: v1.0.0
+    appVersion: "2.3"
+    kubeVersion: ">=1.27.0"
+    created: "2024-01-01T00:00:00Z"
+    digest: %s
+    urls: [https://charts.example.com/foo-1.0.0.tgz]
+    annotations:
+      category: Database
+  - name: foo
+    version: 0.9.0
+    created: "2023-06-01T00:00:00Z"
+    urls: [https://charts.example.com/foo-0.9.0.tgz]
+generated: "2024-01-02T00:00:00Z"
+`, chartDigest.Encoded())
+
+func newRepository(t *testing.T) *helm.Repository {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/stable/index.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(indexYAML))
+	}))
+	t.Cleanup(srv.Close)
+	return &helm.Repository{URL: srv.URL + "/stable", Client: srv.Client()}
+}
+
+func TestRepository(t *testing.T) {
+	r := newRepository(t)
+
+	indexDigest, refs, err := r.ListReferences(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, digest.FromString(indexYAML), indexDigest)
+	require.Len(t, refs, 1, "charts without digests are skipped")
+	assert.True(t, strings.HasSuffix(refs[0].Name(), "/stable/foo"))
+	assert.Equal(t, chartDigest, refs[0].Digest())
+
+	m, err := r.FetchMetadata(t.Context(), refs[0])
+	require.NoError(t, err)
+	assert.Equal(t, "foo", m.PackageName)
+	assert.Equal(t, "1.0.0", m.Version)
+	assert.Equal(t, helm.ChartMediaType, m.Descriptor.MediaType)
+	assert.Equal(t, []string{"https://charts.example.com/foo-1.0.0.tgz"}, m.Descriptor.URLs)
+	require.NotNil(t, m.Image.Created)
+	assert.True(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Equal(*m.Image.Created))
+	assert.Equal(t, "Database", m.Image.Config.Labels["category"])
+	require.Len(t, m.Properties, 1)
+	assert.Equal(t, helm.ChartPropertyType, m.Properties[0].Type)
+	assert.JSONEq(t, `{"appVersion": "2.3", "kubeVersion": ">=1.27.0"}`, string(m.Properties[0].Value))
+}
+
+func TestRepositoryPackages(t *testing.T) {
+	r := newRepository(t)
+	r.Packages = ingest.PackageFilter{Exclude: []string{"f*"}}
+
+	_, refs, err := r.ListReferences(t.Context())
+	require.NoError(t, err)
+	assert.Empty(t, refs)
+}
+
+func TestRepositoryIndexError(t *testing.T) {
+	r := newRepository(t)
+	r.URL += "/missing"
+	_, _, err := r.ListReferences(t.Context())
+	assert.Error(t, err)
+}
+
+func TestIngest(t *testing.T) {
+	q := query.New(dbtest.New(t))
+	r := newRepository(t)
+	_, refs, err := r.ListReferences(t.Context())
+	require.NoError(t, err)
+	require.Len(t, refs, 1)
+
+	br, err := q.GetOrCreateCanonicalBundleReference(t.Context(), refs[0])
+	require.NoError(t, err)
+	created, err := ingest.Bundle(t.Context(), q, r, br, refs[0], ingest.Origin{SourceType: models.SourceTypeCatalog, SourceID: "example-charts"})
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	b, err := q.GetBundleByDigest(t.Context(), chartDigest)
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0", b.Version)
+	pkg, err := q.GetPackageByName(t.Context(), "foo")
+	require.NoError(t, err)
+	assert.Equal(t, pkg.ID, b.PackageID.String)
+}
@@ -0,0 +1,183 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/joelanford/extensiondb/internal/registry"
+	"github.com/opencontainers/go-digest"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+// CatalogImage is a Source of the registry+v1 bundle images in the catalog
+// of a catalog image, such as
+// registry.redhat.io/redhat/redhat-operator-index:v4.19, so that catalogs are
+// ingested without extracting them first. Both file-based catalogs and the
+// SQLite catalogs of OpenShift 4.10 and earlier are read.
+//
+// The digest of the catalog is that of the image, which is resolved once, so
+// that the catalog that is listed is the one whose digest is recorded. The
+// catalog is pulled the first time its references or default channels are
+// listed, and extracted to CacheDir/<digest> in the layout of data/prepare.sh,
+// so that later ingestions of the same image don't pull it again. The
+// catalogs of other digests are removed from CacheDir once a new one is
+// extracted, so each CatalogImage needs a CacheDir of its own.
+type CatalogImage struct {
+	// Ref is the tagged or canonical reference of the image.
+	Ref      reference.Named
+	CacheDir string
+
+	// Packages selects the packages whose bundles and default channels are
+	// listed. The digest is that of the whole catalog either way.
+	Packages PackageFilter
+
+	// Registry resolves and pulls the image, and fetches the bundle images.
+	Registry registry.Client
+
+	mu       sync.Mutex
+	resolved reference.Canonical
+	catalog  CatalogSource
+}
+
+// ListReferences implements Source.
+func (s *CatalogImage) ListReferences(ctx context.Context) (digest.Digest, []reference.Canonical, error) {
+	catalog, err := s.pull(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	return catalog.ListReferences(ctx)
+}
+
+// Digest implements StreamingSource. It resolves the image's digest without
+// pulling it, so that unchanged catalogs are skipped cheaply.
+func (s *CatalogImage) Digest(ctx context.Context) (digest.Digest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ref, err := s.resolve(ctx)
+	if err != nil {
+		return "", err
+	}
+	return ref.Digest(), nil
+}
+
+// References implements StreamingSource.
+func (s *CatalogImage) References(ctx context.Context) iter.Seq2[reference.Canonical, error] {
+	return func(yield func(reference.Canonical, error) bool) {
+		catalog, err := s.pull(ctx)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		catalog.References(ctx)(yield)
+	}
+}
+
+// DefaultChannels implements DefaultChannelSource.
+func (s *CatalogImage) DefaultChannels(ctx context.Context) (map[string]string, error) {
+	catalog, err := s.pull(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return catalog.DefaultChannels(ctx)
+}
+
+// FetchMetadata implements Source by fetching the bundle image from its
+// registry.
+func (s *CatalogImage) FetchMetadata(ctx context.Context, ref reference.Canonical) (*Metadata, error) {
+	return FBC{Registry: s.Registry}.FetchMetadata(ctx, ref)
+}
+
+// resolve returns the canonical reference of the image, resolving its tag
+// the first time it is called. s.mu must be held.
+func (s *CatalogImage) resolve(ctx context.Context) (reference.Canonical, error) {
+	if s.resolved != nil {
+		return s.resolved, nil
+	}
+	switch ref := s.Ref.(type) {
+	case reference.Canonical:
+		s.resolved = ref
+	case reference.NamedTagged:
+		resolved, err := s.Registry.ResolveDigest(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		s.resolved = resolved
+	default:
+		return nil, fmt.Errorf("catalog image %s has neither a tag nor a digest", s.Ref)
+	}
+	return s.resolved, nil
+}
+
+// pull returns the catalog of the image, extracting it to CacheDir if it
+// isn't there already.
+func (s *CatalogImage) pull(ctx context.Context) (CatalogSource, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.catalog != nil {
+		return s.catalog, nil
+	}
+	ref, err := s.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(s.CacheDir, ref.Digest().Encoded())
+	if _, err := os.Stat(filepath.Join(dir, ".metadata", "digest")); errors.Is(err, os.ErrNotExist) {
+		if err := s.extract(ctx, ref, dir); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	s.catalog = CatalogDir(dir, s.Packages, s.Registry)
+	return s.catalog, nil
+}
+
+// extract pulls the catalog of the image at ref to dir, which is only created
+// once the catalog has been extracted completely, and removes the catalogs of
+// other digests from CacheDir.
+func (s *CatalogImage) extract(ctx context.Context, ref reference.Canonical, dir string) error {
+	if err := os.MkdirAll(s.CacheDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create catalog cache directory: %w", err)
+	}
+	tmpDir, err := os.MkdirTemp(s.CacheDir, ".pull-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := s.Registry.PullCatalog(ctx, ref, tmpDir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(tmpDir, ".metadata"), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, ".metadata", "digest"), []byte(ref.Digest().Encoded()), 0o644); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpDir, dir); err != nil {
+		return fmt.Errorf("failed to move extracted catalog into place: %w", err)
+	}
+
+	entries, err := os.ReadDir(s.CacheDir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.Name() == filepath.Base(dir) || !e.IsDir() || digest.NewDigestFromEncoded(digest.SHA256, e.Name()).Validate() != nil {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(s.CacheDir, e.Name())); err != nil {
+			return fmt.Errorf("failed to remove outdated catalog: %w", err)
+		}
+	}
+	return nil
+}
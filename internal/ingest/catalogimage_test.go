@@ -0,0 +1,67 @@
+package ingest_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joelanford/extensiondb/internal/ingest"
+	"github.com/joelanford/extensiondb/internal/registry/registrytest"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+func TestCatalogImage(t *testing.T) {
+	r := registrytest.New(t)
+	catalog := func(bundles ...string) registrytest.Catalog {
+		lines := []string{`{"schema": "olm.package", "name": "foo", "defaultChannel": "stable"}`}
+		for _, b := range bundles {
+			lines = append(lines, `{"schema": "olm.bundle", "name": "foo.v`+b+`", "package": "foo", "image": "quay.io/example/foo-bundle@`+digest.FromString(b).String()+`"}`)
+		}
+		return registrytest.Catalog{Files: map[string]string{"foo/catalog.json": strings.Join(lines, "\n")}}
+	}
+	first := r.PushCatalog(t, "example/index", "v4.19", catalog("1.0.0"))
+	tagged, err := reference.WithTag(r.Named("example/index"), "v4.19")
+	require.NoError(t, err)
+
+	cacheDir := t.TempDir()
+	src := &ingest.CatalogImage{Ref: tagged, CacheDir: cacheDir}
+	gotDigest, err := src.Digest(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, first.Digest(), gotDigest)
+	assert.NoDirExists(t, filepath.Join(cacheDir, first.Digest().Encoded()), "resolving the digest doesn't pull the catalog")
+
+	var refs []string
+	for ref, err := range src.References(t.Context()) {
+		require.NoError(t, err)
+		refs = append(refs, ref.String())
+	}
+	assert.Equal(t, []string{"quay.io/example/foo-bundle@" + digest.FromString("1.0.0").String()}, refs)
+	channels, err := src.DefaultChannels(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"foo": "stable"}, channels)
+
+	// Later ingestions reuse the extracted catalog, and a new digest
+	// replaces it.
+	gotDigest, gotRefs, err := ingest.FBC{Dir: filepath.Join(cacheDir, first.Digest().Encoded())}.ListReferences(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, first.Digest(), gotDigest)
+	assert.Len(t, gotRefs, 1)
+
+	second := r.PushCatalog(t, "example/index", "v4.19", catalog("1.0.0", "1.0.1"))
+	src = &ingest.CatalogImage{Ref: tagged, CacheDir: cacheDir, Packages: ingest.PackageFilter{Exclude: []string{"foo"}}}
+	gotDigest, gotRefs, err = src.ListReferences(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, second.Digest(), gotDigest)
+	assert.Empty(t, gotRefs, "filtered packages aren't listed")
+	entries, err := os.ReadDir(cacheDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, second.Digest().Encoded(), entries[0].Name())
+
+	_, err = (&ingest.CatalogImage{Ref: r.Named("example/index"), CacheDir: cacheDir}).Digest(t.Context())
+	assert.ErrorContains(t, err, "has neither a tag nor a digest")
+}
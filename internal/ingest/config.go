@@ -0,0 +1,219 @@
+package ingest
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go.podman.io/image/v5/docker/reference"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultCatalogRegistry is the repository namespace of the catalog images
+// of catalogs that don't name their repository.
+const DefaultCatalogRegistry = "registry.redhat.io/redhat"
+
+// Config declares what an ingestion ingests, so that large ingestions can be
+// managed in a file rather than with flags.
+type Config struct {
+	// Catalogs are the file-based catalogs to ingest, in order.
+	Catalogs []CatalogConfig `json:"catalogs"`
+
+	// HelmRepositories are the Helm chart repositories to ingest charts
+	// from, after the catalogs.
+	HelmRepositories []HelmRepositoryConfig `json:"helmRepositories,omitempty"`
+
+	// Concurrency is the maximum number of bundles to fetch at once from
+	// each registry host. If it is 0, the ingest command's default is used.
+	Concurrency int `json:"concurrency,omitempty"`
+
+	// HostConcurrency overrides Concurrency for individual registry hosts.
+	HostConcurrency map[string]int `json:"hostConcurrency,omitempty"`
+
+	// RateLimit is the maximum number of requests per second to each
+	// registry host, as registry.RateLimits. If it is 0, hosts are not
+	// limited.
+	RateLimit float64 `json:"rateLimit,omitempty"`
+
+	// HostRateLimit overrides RateLimit for individual registry hosts.
+	HostRateLimit map[string]float64 `json:"hostRateLimit,omitempty"`
+
+	// Packages selects the packages to ingest from every catalog and Helm
+	// repository.
+	Packages PackageFilter `json:"packages,omitempty"`
+}
+
+// CatalogConfig is a catalog whose tags are ingested from the file-based
+// catalogs extracted from its images.
+type CatalogConfig struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+
+	// Repository is the image repository of the catalog, such as
+	// quay.io/example/index. If it is empty, it is
+	// DefaultCatalogRegistry/<name>.
+	Repository string `json:"repository,omitempty"`
+
+	// Dir is the directory that the tags of the catalog are extracted to,
+	// each to <dir>/<tag> without the tag's leading "v". If it is empty, it
+	// is $CATALOGS_DIR/<name>, as data/prepare.sh extracts them.
+	Dir string `json:"dir,omitempty"`
+
+	// Pull ingests the tags of the catalog from their images, as
+	// CatalogImage sources, rather than from Dir.
+	Pull bool `json:"pull,omitempty"`
+
+	// Packages selects the packages to ingest from the catalog, along with
+	// Config.Packages, as combined by Config.Filter.
+	Packages PackageFilter `json:"packages,omitempty"`
+}
+
+// HelmRepositoryConfig is a Helm chart repository, whose charts are ingested
+// as the "latest" tag of the catalog Name.
+type HelmRepositoryConfig struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+
+	// Packages selects the charts to ingest from the repository, along with
+	// Config.Packages, as combined by Config.Filter.
+	Packages PackageFilter `json:"packages,omitempty"`
+}
+
+// DefaultConfig returns the configuration of an ingestion of the Red Hat and
+// certified operator catalogs of OpenShift 4.12 through 4.19.
+func DefaultConfig() Config {
+	tags := []string{"v4.19", "v4.18", "v4.17", "v4.16", "v4.15", "v4.14", "v4.13", "v4.12"}
+	return Config{
+		Catalogs: []CatalogConfig{
+			{Name: "redhat-operator-index", Tags: tags},
+			{Name: "certified-operator-index", Tags: slices.Clone(tags)},
+		},
+	}
+}
+
+// ReadConfigFile reads a YAML or JSON ingestion config from path and
+// validates it. Unknown fields are rejected, so that misspelled fields aren't
+// silently ignored.
+func ReadConfigFile(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	var c Config
+	if err := yaml.UnmarshalStrict(data, &c); err != nil {
+		return Config{}, fmt.Errorf("error parsing ingestion config file %q: %w", path, err)
+	}
+	if err := c.Validate(); err != nil {
+		return Config{}, fmt.Errorf("ingestion config file %q: %w", path, err)
+	}
+	return c, nil
+}
+
+// Validate reports every problem of the config.
+func (c Config) Validate() error {
+	var errs []error
+	if c.Concurrency < 0 {
+		errs = append(errs, fmt.Errorf("concurrency must not be negative, got %d", c.Concurrency))
+	}
+	for host, n := range c.HostConcurrency {
+		if n < 1 {
+			errs = append(errs, fmt.Errorf("hostConcurrency of %s must be at least 1, got %d", host, n))
+		}
+	}
+	if c.RateLimit < 0 {
+		errs = append(errs, fmt.Errorf("rateLimit must not be negative, got %g", c.RateLimit))
+	}
+	for host, r := range c.HostRateLimit {
+		if r < 0 {
+			errs = append(errs, fmt.Errorf("hostRateLimit of %s must not be negative, got %g", host, r))
+		}
+	}
+	if err := c.Packages.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	names := map[string]bool{}
+	checkName := func(kind, name string) {
+		switch {
+		case name == "":
+			errs = append(errs, fmt.Errorf("%s without a name", kind))
+		case names[name]:
+			errs = append(errs, fmt.Errorf("duplicate catalog %q", name))
+		}
+		names[name] = true
+	}
+	for _, cc := range c.Catalogs {
+		checkName("catalog", cc.Name)
+		if len(cc.Tags) == 0 {
+			errs = append(errs, fmt.Errorf("catalog %q has no tags", cc.Name))
+		}
+		for _, tag := range cc.Tags {
+			if tag == "" {
+				errs = append(errs, fmt.Errorf("catalog %q has an empty tag", cc.Name))
+				continue
+			}
+			if _, err := cc.ImageReference(tag); err != nil {
+				errs = append(errs, fmt.Errorf("catalog %q: %w", cc.Name, err))
+			}
+		}
+		if err := cc.Packages.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("catalog %q: %w", cc.Name, err))
+		}
+	}
+	for _, hr := range c.HelmRepositories {
+		checkName("Helm repository", hr.Name)
+		if hr.URL == "" {
+			errs = append(errs, fmt.Errorf("Helm repository %q has no URL", hr.Name))
+		}
+		if err := hr.Packages.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("Helm repository %q: %w", hr.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Filter returns the filter of the packages of a catalog or Helm repository
+// whose own filter is f. The inclusions of f replace those of Config.Packages
+// if there are any, and the exclusions of both apply.
+func (c Config) Filter(f PackageFilter) PackageFilter {
+	include := f.Include
+	if len(include) == 0 {
+		include = c.Packages.Include
+	}
+	return PackageFilter{
+		Include: slices.Clone(include),
+		Exclude: slices.Concat(c.Packages.Exclude, f.Exclude),
+	}
+}
+
+// Image returns the image of a tag of the catalog.
+func (cc CatalogConfig) Image(tag string) string {
+	return cmp.Or(cc.Repository, DefaultCatalogRegistry+"/"+cc.Name) + ":" + tag
+}
+
+// ImageReference parses the image of a tag of the catalog.
+func (cc CatalogConfig) ImageReference(tag string) (reference.NamedTagged, error) {
+	image := cc.Image(tag)
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image %q: %w", image, err)
+	}
+	tagged, ok := named.(reference.NamedTagged)
+	if !ok {
+		return nil, fmt.Errorf("invalid image %q: expected a tag", image)
+	}
+	return tagged, nil
+}
+
+// TagDir returns the directory that a tag of the catalog is extracted to.
+func (cc CatalogConfig) TagDir(tag string) string {
+	dir := cc.Dir
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("CATALOGS_DIR"), cc.Name)
+	}
+	return filepath.Join(dir, strings.TrimPrefix(tag, "v"))
+}
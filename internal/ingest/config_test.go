@@ -0,0 +1,101 @@
+package ingest_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joelanford/extensiondb/internal/ingest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ingest.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+catalogs:
+- name: redhat-operator-index
+  tags: [v4.19, v4.18]
+  packages:
+    include: [quay-*]
+- name: example-index
+  repository: quay.io/example/index
+  dir: /catalogs/example
+  pull: true
+  tags: [latest]
+helmRepositories:
+- name: example-charts
+  url: https://charts.example.com
+concurrency: 8
+hostConcurrency:
+  registry.redhat.io: 4
+rateLimit: 20
+hostRateLimit:
+  quay.io: 2.5
+packages:
+  include: ["*-operator"]
+  exclude: ["*-bridge-*"]
+`), 0o600))
+
+	c, err := ingest.ReadConfigFile(path)
+	require.NoError(t, err)
+	require.Len(t, c.Catalogs, 2)
+	assert.Equal(t, 8, c.Concurrency)
+	assert.Equal(t, map[string]int{"registry.redhat.io": 4}, c.HostConcurrency)
+	assert.Equal(t, 20.0, c.RateLimit)
+	assert.Equal(t, map[string]float64{"quay.io": 2.5}, c.HostRateLimit)
+	assert.Equal(t, []ingest.HelmRepositoryConfig{{Name: "example-charts", URL: "https://charts.example.com"}}, c.HelmRepositories)
+
+	rh, example := c.Catalogs[0], c.Catalogs[1]
+	assert.Equal(t, "registry.redhat.io/redhat/redhat-operator-index:v4.19", rh.Image("v4.19"))
+	t.Setenv("CATALOGS_DIR", "/data/catalogs")
+	assert.Equal(t, "/data/catalogs/redhat-operator-index/4.19", rh.TagDir("v4.19"))
+	assert.Equal(t, "quay.io/example/index:latest", example.Image("latest"))
+	assert.Equal(t, "/catalogs/example/latest", example.TagDir("latest"))
+	assert.True(t, example.Pull)
+	ref, err := example.ImageReference("latest")
+	require.NoError(t, err)
+	assert.Equal(t, "latest", ref.Tag())
+
+	assert.Equal(t, ingest.PackageFilter{Include: []string{"quay-*"}, Exclude: []string{"*-bridge-*"}}, c.Filter(rh.Packages),
+		"a catalog's inclusions replace the config's")
+	assert.Equal(t, ingest.PackageFilter{Include: []string{"*-operator"}, Exclude: []string{"*-bridge-*"}}, c.Filter(example.Packages))
+
+	require.NoError(t, os.WriteFile(path, []byte("catalogs:\n- name: foo\n  tag: [v1]\n"), 0o600))
+	_, err = ingest.ReadConfigFile(path)
+	assert.ErrorContains(t, err, "error parsing ingestion config file", "unknown fields are rejected")
+}
+
+func TestConfig_Validate(t *testing.T) {
+	assert.NoError(t, ingest.DefaultConfig().Validate())
+
+	err := ingest.Config{
+		Catalogs: []ingest.CatalogConfig{
+			{Name: "foo", Tags: []string{"v1"}},
+			{Name: "foo"},
+			{Tags: []string{""}},
+		},
+		HelmRepositories: []ingest.HelmRepositoryConfig{{Name: "bar"}},
+		Concurrency:      -1,
+		HostConcurrency:  map[string]int{"quay.io": 0},
+		RateLimit:        -1,
+		HostRateLimit:    map[string]float64{"quay.io": -0.5},
+		Packages:         ingest.PackageFilter{Include: []string{"quay-["}},
+	}.Validate()
+	require.Error(t, err)
+	for _, msg := range []string{
+		"concurrency must not be negative, got -1",
+		"hostConcurrency of quay.io must be at least 1, got 0",
+		"rateLimit must not be negative, got -1",
+		"hostRateLimit of quay.io must not be negative, got -0.5",
+		`invalid package pattern "quay-["`,
+		`duplicate catalog "foo"`,
+		`catalog "foo" has no tags`,
+		"catalog without a name",
+		`catalog "" has an empty tag`,
+		`Helm repository "bar" has no URL`,
+	} {
+		assert.ErrorContains(t, err, msg)
+	}
+}
@@ -0,0 +1,58 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"time"
+)
+
+// ErrDrainTimeout is the cause of the cancellation of a context returned by
+// WithDrain whose in-flight work didn't finish within its drain timeout.
+var ErrDrainTimeout = errors.New("drain timeout exceeded")
+
+// WithDrain returns a context for in-flight work that outlives ctx by up to
+// timeout, so that work which was started before ctx was canceled, such as a
+// shutdown signal, can finish its fetches and database writes instead of
+// being abandoned midway. Once ctx is done, callers should stop starting new
+// work; the returned context is canceled with ErrDrainTimeout as its cause if
+// the work is still running timeout later. A timeout of zero or less cancels
+// it as soon as ctx is done.
+//
+// The returned cancel function must be called once the work is done.
+func WithDrain(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	work, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-work.Done():
+			return
+		}
+		if timeout <= 0 {
+			cancel(context.Cause(ctx))
+			return
+		}
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			cancel(fmt.Errorf("%w: in-flight work still running %s after shutdown began", ErrDrainTimeout, timeout))
+		case <-work.Done():
+		}
+	}()
+	return work, func() { cancel(context.Canceled) }
+}
+
+// UntilDone yields the values of seq until ctx is done, and then stops
+// without an error, so that listing stops when a shutdown begins while the
+// values already yielded are still processed.
+func UntilDone[T any](ctx context.Context, seq iter.Seq2[T, error]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for v, err := range seq {
+			if ctx.Err() != nil || !yield(v, err) {
+				return
+			}
+		}
+	}
+}
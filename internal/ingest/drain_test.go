@@ -0,0 +1,77 @@
+package ingest_test
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"testing"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/ingest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithDrain(t *testing.T) {
+	t.Run("work outlives ctx until the timeout", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		work, stop := ingest.WithDrain(ctx, 50*time.Millisecond)
+		defer stop()
+
+		cancel()
+		assert.NoError(t, work.Err())
+		select {
+		case <-work.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("work was not canceled after the drain timeout")
+		}
+		assert.ErrorIs(t, context.Cause(work), ingest.ErrDrainTimeout)
+	})
+
+	t.Run("work that finishes is not canceled by the timeout", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		work, stop := ingest.WithDrain(ctx, time.Hour)
+		cancel()
+		assert.NoError(t, work.Err())
+		stop()
+		assert.ErrorIs(t, work.Err(), context.Canceled)
+		assert.NotErrorIs(t, context.Cause(work), ingest.ErrDrainTimeout)
+	})
+
+	t.Run("no timeout", func(t *testing.T) {
+		shutdown := errors.New("shutdown")
+		ctx, cancel := context.WithCancelCause(t.Context())
+		work, stop := ingest.WithDrain(ctx, 0)
+		defer stop()
+
+		cancel(shutdown)
+		select {
+		case <-work.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("work was not canceled with ctx")
+		}
+		assert.ErrorIs(t, context.Cause(work), shutdown)
+	})
+}
+
+func TestUntilDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	seq := func(yield func(int, error) bool) {
+		for i := range 5 {
+			if !yield(i, nil) {
+				return
+			}
+		}
+	}
+
+	var got []int
+	for v, err := range ingest.UntilDone(ctx, iter.Seq2[int, error](seq)) {
+		require.NoError(t, err)
+		got = append(got, v)
+		if v == 2 {
+			cancel()
+		}
+	}
+	assert.Equal(t, []int{0, 1, 2}, got)
+}
@@ -0,0 +1,231 @@
+package ingest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/joelanford/extensiondb/internal/csvquality"
+	"github.com/joelanford/extensiondb/internal/fingerprint"
+	"github.com/joelanford/extensiondb/internal/models"
+	"github.com/joelanford/extensiondb/internal/registry"
+	"github.com/opencontainers/go-digest"
+	v1alpha1 "github.com/operator-framework/api/pkg/operators/v1alpha1"
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+// FBC is a Source of the registry+v1 bundle images in a file-based catalog
+// extracted to Dir. The digest of the catalog image is read from
+// Dir/.metadata/digest. FetchMetadata does not use Dir, so FBC{} fetches
+// bundle images that are not listed in any catalog.
+type FBC struct {
+	Dir string
+
+	// Packages selects the packages whose bundles and default channels are
+	// listed. The digest is that of the whole catalog either way.
+	Packages PackageFilter
+
+	// Registry fetches the bundle images.
+	Registry registry.Client
+}
+
+// ListReferences implements Source.
+func (s FBC) ListReferences(ctx context.Context) (digest.Digest, []reference.Canonical, error) {
+	catalogDigest, err := s.Digest(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	var refs []reference.Canonical
+	for ref, err := range s.References(ctx) {
+		if err != nil {
+			return "", nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return catalogDigest, refs, nil
+}
+
+// Digest implements StreamingSource.
+func (s FBC) Digest(context.Context) (digest.Digest, error) {
+	digestBytes, err := os.ReadFile(filepath.Join(s.Dir, ".metadata", "digest"))
+	if err != nil {
+		return "", err
+	}
+	catalogDigest := digest.NewDigestFromEncoded(digest.SHA256, strings.TrimSpace(string(digestBytes)))
+	if err := catalogDigest.Validate(); err != nil {
+		return "", fmt.Errorf("invalid catalog digest: %w", err)
+	}
+	return catalogDigest, nil
+}
+
+// errStopReferences stops walking a catalog once the consumer of References
+// stops iterating.
+var errStopReferences = errors.New("stop listing references")
+
+// References implements StreamingSource. Only the catalog files being read
+// are held in memory, so catalogs of any size can be listed.
+func (s FBC) References(ctx context.Context) iter.Seq2[reference.Canonical, error] {
+	return func(yield func(reference.Canonical, error) bool) {
+		var (
+			mu      sync.Mutex
+			stopped bool
+		)
+		// Catalog files are read concurrently, so calls to yield are
+		// serialized.
+		emit := func(ref reference.Canonical, err error) bool {
+			mu.Lock()
+			defer mu.Unlock()
+			if stopped {
+				return false
+			}
+			stopped = !yield(ref, err)
+			return !stopped
+		}
+		err := declcfg.WalkMetasFS(ctx, os.DirFS(s.Dir), func(path string, meta *declcfg.Meta, err error) error {
+			if err != nil {
+				return err
+			}
+			if meta.Schema != declcfg.SchemaBundle {
+				return nil
+			}
+			if !s.Packages.Matches(meta.Package) {
+				return nil
+			}
+			var b struct {
+				Image string `json:"image"`
+			}
+			if err := json.Unmarshal(meta.Blob, &b); err != nil {
+				return err
+			}
+
+			namedRef, err := reference.ParseNamed(b.Image)
+			if err != nil {
+				return err
+			}
+			canonicalRef, ok := namedRef.(reference.Canonical)
+			if !ok {
+				return fmt.Errorf("image reference %s is not a canonical reference", b.Image)
+			}
+			if !emit(canonicalRef, nil) {
+				return errStopReferences
+			}
+			return nil
+		}, declcfg.WithConcurrency(16))
+		if err != nil && !errors.Is(err, errStopReferences) {
+			emit(nil, err)
+		}
+	}
+}
+
+// DefaultChannels implements DefaultChannelSource.
+func (s FBC) DefaultChannels(ctx context.Context) (map[string]string, error) {
+	var (
+		mu       sync.Mutex
+		channels = map[string]string{}
+	)
+	if err := declcfg.WalkMetasFS(ctx, os.DirFS(s.Dir), func(path string, meta *declcfg.Meta, err error) error {
+		if err != nil {
+			return err
+		}
+		if meta.Schema != declcfg.SchemaPackage || !s.Packages.Matches(meta.Name) {
+			return nil
+		}
+		var p struct {
+			DefaultChannel string `json:"defaultChannel"`
+		}
+		if err := json.Unmarshal(meta.Blob, &p); err != nil {
+			return err
+		}
+		if p.DefaultChannel == "" {
+			return nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		channels[meta.Name] = p.DefaultChannel
+		return nil
+	}, declcfg.WithConcurrency(16)); err != nil {
+		return nil, err
+	}
+	return channels, nil
+}
+
+// FetchMetadata implements Source by fetching the bundle image from its
+// registry, retrying transient errors with registry.DefaultBackoff.
+func (s FBC) FetchMetadata(ctx context.Context, ref reference.Canonical) (*Metadata, error) {
+	var imageInfo *registry.RegistryV1ImageInfo
+	if err := registry.Retry(ctx, registry.DefaultBackoff, func(ctx context.Context) error {
+		var err error
+		imageInfo, err = s.Registry.FetchRegistryV1Bundle(ctx, ref)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	// Bundles with malformed properties are still stored, because the
+	// properties are informational.
+	props, err := csvProperties(imageInfo.CSV)
+	if err != nil {
+		slog.WarnContext(ctx, "ignoring malformed properties", "reference", ref.String(), "error", err)
+	}
+	csv, err := json.Marshal(imageInfo.CSV)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding ClusterServiceVersion: %w", err)
+	}
+	fp, err := fingerprint.Compute(csv, imageInfo.Manifests)
+	if err != nil {
+		return nil, fmt.Errorf("error fingerprinting bundle content: %w", err)
+	}
+	return &Metadata{
+		PackageName:        imageInfo.PackageName,
+		Version:            imageInfo.CSV.Spec.Version.String(),
+		Release:            imageInfo.Release,
+		Descriptor:         imageInfo.ReferenceDescriptor,
+		Index:              imageInfo.Index,
+		Manifest:           imageInfo.Manifest,
+		Image:              imageInfo.ImageConfig,
+		Properties:         props,
+		RelatedImages:      relatedImages(imageInfo.CSV),
+		CSV:                csv,
+		QualityFlags:       csvquality.Check(imageInfo.CSV),
+		ContentFingerprint: fp,
+	}, nil
+}
+
+// relatedImages returns the images listed in the spec.relatedImages of a
+// ClusterServiceVersion.
+func relatedImages(csv v1alpha1.ClusterServiceVersion) []models.RelatedImage {
+	images := make([]models.RelatedImage, 0, len(csv.Spec.RelatedImages))
+	for _, ri := range csv.Spec.RelatedImages {
+		image := models.RelatedImage{Name: ri.Name, Image: ri.Image}
+		if ref, err := reference.ParseNormalizedNamed(ri.Image); err == nil {
+			if c, ok := ref.(reference.Canonical); ok {
+				image.Digest = sql.NullString{String: c.Digest().String(), Valid: true}
+			}
+		}
+		images = append(images, image)
+	}
+	return images
+}
+
+// csvProperties returns the properties declared in the olm.properties
+// annotation of a ClusterServiceVersion.
+func csvProperties(csv v1alpha1.ClusterServiceVersion) ([]models.BundleProperty, error) {
+	data, ok := csv.Annotations["olm.properties"]
+	if !ok {
+		return nil, nil
+	}
+	var props []models.BundleProperty
+	if err := json.Unmarshal([]byte(data), &props); err != nil {
+		return nil, fmt.Errorf("invalid olm.properties annotation: %w", err)
+	}
+	return props, nil
+}
@@ -0,0 +1,81 @@
+package ingest_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joelanford/extensiondb/internal/ingest"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFBC(t *testing.T) {
+	dir := t.TempDir()
+	catalogDigest := digest.FromString("catalog")
+	bundleImage := "quay.io/example/foo-bundle@" + digest.FromString("foo").String()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".metadata"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".metadata", "digest"), []byte(catalogDigest.Encoded()+"\n"), 0o600))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "foo"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "foo", "catalog.json"), []byte(strings.Join([]string{
+		`{"schema": "olm.package", "name": "foo", "defaultChannel": "stable"}`,
+		`{"schema": "olm.bundle", "name": "foo.v1.0.0", "package": "foo", "image": "` + bundleImage + `"}`,
+	}, "\n")), 0o600))
+
+	gotDigest, refs, err := ingest.FBC{Dir: dir}.ListReferences(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, catalogDigest, gotDigest)
+	require.Len(t, refs, 1)
+	assert.Equal(t, bundleImage, refs[0].String())
+
+	// References are streamed, and listing stops when iteration does.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "foo", "more.json"), []byte(
+		`{"schema": "olm.bundle", "name": "foo.v1.0.1", "package": "foo", "image": "quay.io/example/foo-bundle@`+digest.FromString("foo.v1.0.1").String()+`"}`,
+	), 0o600))
+	fbc := ingest.FBC{Dir: dir}
+	var streamed []string
+	for ref, err := range fbc.References(t.Context()) {
+		require.NoError(t, err)
+		streamed = append(streamed, ref.String())
+	}
+	assert.Len(t, streamed, 2)
+	n := 0
+	for range fbc.References(t.Context()) {
+		n++
+		break
+	}
+	assert.Equal(t, 1, n)
+	require.NoError(t, os.Remove(filepath.Join(dir, "foo", "more.json")))
+
+	channels, err := ingest.FBC{Dir: dir}.DefaultChannels(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"foo": "stable"}, channels)
+
+	// Filtered packages are neither listed nor have their default channels
+	// read.
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "bar"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bar", "catalog.json"), []byte(strings.Join([]string{
+		`{"schema": "olm.package", "name": "bar", "defaultChannel": "fast"}`,
+		`{"schema": "olm.bundle", "name": "bar.v1.0.0", "package": "bar", "image": "quay.io/example/bar-bundle@` + digest.FromString("bar").String() + `"}`,
+	}, "\n")), 0o600))
+	filtered := ingest.FBC{Dir: dir, Packages: ingest.PackageFilter{Include: []string{"b*"}}}
+	_, refs, err = filtered.ListReferences(t.Context())
+	require.NoError(t, err)
+	require.Len(t, refs, 1)
+	assert.Equal(t, "quay.io/example/bar-bundle", refs[0].Name())
+	channels, err = filtered.DefaultChannels(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"bar": "fast"}, channels)
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, "bar")))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "foo", "catalog.json"), []byte(
+		`{"schema": "olm.bundle", "name": "foo.v1.0.1", "package": "foo", "image": "quay.io/example/foo-bundle:v1.0.1"}`,
+	), 0o600))
+	_, _, err = ingest.FBC{Dir: dir}.ListReferences(t.Context())
+	assert.Error(t, err, "bundle images must be canonical references")
+
+	_, _, err = ingest.FBC{Dir: t.TempDir()}.ListReferences(t.Context())
+	assert.Error(t, err, "catalogs must have a digest")
+}
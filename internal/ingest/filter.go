@@ -0,0 +1,45 @@
+package ingest
+
+import (
+	"fmt"
+	"path"
+	"slices"
+)
+
+// PackageFilter selects the packages of a source to ingest by name, with glob
+// patterns as matched by path.Match. The zero filter matches every package.
+type PackageFilter struct {
+	// Include, if not empty, limits ingestion to the packages that match
+	// any of its patterns.
+	Include []string `json:"include,omitempty"`
+
+	// Exclude skips the packages that match any of its patterns, even if
+	// they match Include.
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// Validate reports the first malformed pattern of the filter.
+func (f PackageFilter) Validate() error {
+	for _, pattern := range slices.Concat(f.Include, f.Exclude) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid package pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether the package named name is selected by the filter.
+// Malformed patterns match nothing.
+func (f PackageFilter) Matches(name string) bool {
+	if len(f.Include) > 0 && !matchAny(f.Include, name) {
+		return false
+	}
+	return !matchAny(f.Exclude, name)
+}
+
+func matchAny(patterns []string, name string) bool {
+	return slices.ContainsFunc(patterns, func(pattern string) bool {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	})
+}
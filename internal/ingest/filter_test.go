@@ -0,0 +1,25 @@
+package ingest_test
+
+import (
+	"testing"
+
+	"github.com/joelanford/extensiondb/internal/ingest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackageFilter(t *testing.T) {
+	assert.True(t, ingest.PackageFilter{}.Matches("foo"), "the zero filter matches every package")
+
+	f := ingest.PackageFilter{Include: []string{"quay-*", "cluster-logging"}, Exclude: []string{"*-bridge-*"}}
+	assert.True(t, f.Matches("quay-operator"))
+	assert.True(t, f.Matches("cluster-logging"))
+	assert.False(t, f.Matches("cluster-logging-operator"))
+	assert.False(t, f.Matches("quay-bridge-operator"), "exclusions win over inclusions")
+
+	f = ingest.PackageFilter{Exclude: []string{"*-bridge-*"}}
+	assert.True(t, f.Matches("cluster-logging"))
+	assert.False(t, f.Matches("quay-bridge-operator"))
+
+	assert.NoError(t, f.Validate())
+	assert.ErrorContains(t, ingest.PackageFilter{Include: []string{"quay-["}}.Validate(), `invalid package pattern "quay-["`)
+}
@@ -0,0 +1,168 @@
+package ingest
+
+import (
+	"context"
+	"iter"
+
+	"go.podman.io/image/v5/docker/reference"
+	"golang.org/x/sync/errgroup"
+)
+
+// HostLimits limits how many references are ingested at once from each
+// registry host, so that a slow registry doesn't hold up the others.
+type HostLimits struct {
+	// Default is the limit of hosts that have no limit of their own.
+	Default int
+
+	// Hosts are the limits of individual registry hosts, such as quay.io.
+	Hosts map[string]int
+}
+
+// Limit returns the limit of a registry host, which is at least 1.
+func (l HostLimits) Limit(host string) int {
+	if n, ok := l.Hosts[host]; ok {
+		return max(n, 1)
+	}
+	return max(l.Default, 1)
+}
+
+// ForEachByHost calls fn for each reference. References are queued by
+// registry host, in the order they are given, and each host's queue is worked
+// through by up to limits.Limit(host) concurrent calls, independently of the
+// queues of other hosts. The first error cancels the context of the remaining
+// calls, and is returned.
+func ForEachByHost[T reference.Named](ctx context.Context, refs []T, limits HostLimits, fn func(context.Context, T) error) error {
+	var hosts []string
+	queues := map[string][]T{}
+	for _, ref := range refs {
+		host := reference.Domain(ref)
+		if _, ok := queues[host]; !ok {
+			hosts = append(hosts, host)
+		}
+		queues[host] = append(queues[host], ref)
+	}
+
+	eg, egCtx := errgroup.WithContext(ctx)
+	for _, host := range hosts {
+		queue := queues[host]
+		next := make(chan T)
+		eg.Go(func() error {
+			defer close(next)
+			for _, ref := range queue {
+				select {
+				case next <- ref:
+				case <-egCtx.Done():
+					return nil
+				}
+			}
+			return nil
+		})
+		for range min(limits.Limit(host), len(queue)) {
+			eg.Go(func() error {
+				for ref := range next {
+					if err := fn(egCtx, ref); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		}
+	}
+	return eg.Wait()
+}
+
+// hostQueueSize is how many references ForEachByHostSeq queues for the
+// calls of each registry host, and hostOverflowSize how many more it holds
+// for a host whose queue is full.
+const (
+	hostQueueSize    = 1024
+	hostOverflowSize = 64 * 1024
+)
+
+// ForEachByHostSeq is like ForEachByHost, but calls fn for references as they
+// are yielded, so that they are never all held in memory. Up to
+// hostQueueSize references are queued for each host, and references of a
+// host whose queue is full overflow into a list of up to hostOverflowSize,
+// so that listing, and the other hosts, carry on while a host falls behind.
+// Listing waits only while the overflow of the next reference's host is
+// full. An error yielded by refs cancels the context of the remaining calls,
+// and is returned.
+func ForEachByHostSeq[T reference.Named](ctx context.Context, refs iter.Seq2[T, error], limits HostLimits, fn func(context.Context, T) error) error {
+	eg, egCtx := errgroup.WithContext(ctx)
+	eg.Go(func() error {
+		overflows := map[string]chan T{}
+		defer func() {
+			for _, overflow := range overflows {
+				close(overflow)
+			}
+		}()
+		for ref, err := range refs {
+			if err != nil {
+				return err
+			}
+			host := reference.Domain(ref)
+			overflow, ok := overflows[host]
+			if !ok {
+				overflow = make(chan T)
+				overflows[host] = overflow
+				queue := make(chan T, hostQueueSize)
+				eg.Go(func() error {
+					defer close(queue)
+					feedQueue(egCtx, overflow, queue)
+					return nil
+				})
+				for range limits.Limit(host) {
+					eg.Go(func() error {
+						for ref := range queue {
+							if err := fn(egCtx, ref); err != nil {
+								return err
+							}
+						}
+						return nil
+					})
+				}
+			}
+			select {
+			case overflow <- ref:
+			case <-egCtx.Done():
+				return nil
+			}
+		}
+		return nil
+	})
+	return eg.Wait()
+}
+
+// feedQueue moves the references sent on in to queue, in order, holding up
+// to hostOverflowSize of them while queue is full, until in is closed and
+// every reference has been queued, or ctx is done.
+func feedQueue[T any](ctx context.Context, in <-chan T, queue chan<- T) {
+	var held []T
+	for in != nil || len(held) > 0 {
+		var (
+			recv <-chan T
+			send chan<- T
+			next T
+		)
+		if len(held) < hostOverflowSize {
+			recv = in
+		}
+		if len(held) > 0 {
+			send, next = queue, held[0]
+		}
+		select {
+		case ref, ok := <-recv:
+			if !ok {
+				in = nil
+				continue
+			}
+			held = append(held, ref)
+		case send <- next:
+			var zero T
+			held[0] = zero
+			held = held[1:]
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package ingest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"sync"
+	"testing"
+
+	"github.com/joelanford/extensiondb/internal/ingest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+func TestHostLimits(t *testing.T) {
+	limits := ingest.HostLimits{Default: 4, Hosts: map[string]int{"quay.io": 2, "registry.redhat.io": 0}}
+	assert.Equal(t, 2, limits.Limit("quay.io"))
+	assert.Equal(t, 1, limits.Limit("registry.redhat.io"))
+	assert.Equal(t, 4, limits.Limit("docker.io"))
+	assert.Equal(t, 1, ingest.HostLimits{}.Limit("docker.io"))
+}
+
+func TestForEachByHost(t *testing.T) {
+	var refs []reference.Named
+	for _, s := range []string{
+		"registry.redhat.io/example/a-bundle:v1",
+		"quay.io/example/b-bundle:v1",
+		"registry.redhat.io/example/c-bundle:v1",
+		"quay.io/example/d-bundle:v1",
+		"quay.io/example/e-bundle:v1",
+		"registry.redhat.io/example/f-bundle:v1",
+	} {
+		ref, err := reference.ParseNormalizedNamed(s)
+		require.NoError(t, err)
+		refs = append(refs, ref)
+	}
+
+	t.Run("hosts proceed independently", func(t *testing.T) {
+		// Fetches from registry.redhat.io hang until every quay.io reference
+		// has been handled, which only happens if they don't wait in line
+		// behind the hung fetches. With a limit of 1, each host's references
+		// are handled in order.
+		quayDone := make(chan struct{})
+		var (
+			mu   sync.Mutex
+			quay []string
+		)
+		limits := ingest.HostLimits{Default: 4, Hosts: map[string]int{"quay.io": 1}}
+		err := ingest.ForEachByHost(t.Context(), refs, limits, func(ctx context.Context, ref reference.Named) error {
+			if reference.Domain(ref) == "registry.redhat.io" {
+				select {
+				case <-quayDone:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			quay = append(quay, reference.Path(ref))
+			if len(quay) == 3 {
+				close(quayDone)
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"example/b-bundle", "example/d-bundle", "example/e-bundle"}, quay)
+	})
+
+	t.Run("errors cancel remaining calls", func(t *testing.T) {
+		boom := errors.New("boom")
+		err := ingest.ForEachByHost(t.Context(), refs, ingest.HostLimits{Default: 1}, func(ctx context.Context, ref reference.Named) error {
+			if reference.Path(ref) == "example/a-bundle" {
+				return boom
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		assert.ErrorIs(t, err, boom)
+	})
+}
+
+func TestForEachByHostSeq(t *testing.T) {
+	var refs []reference.Named
+	for _, s := range []string{
+		"registry.redhat.io/example/a-bundle:v1",
+		"quay.io/example/b-bundle:v1",
+		"quay.io/example/c-bundle:v1",
+	} {
+		ref, err := reference.ParseNormalizedNamed(s)
+		require.NoError(t, err)
+		refs = append(refs, ref)
+	}
+	seq := func(err error) iter.Seq2[reference.Named, error] {
+		return func(yield func(reference.Named, error) bool) {
+			for _, ref := range refs {
+				if !yield(ref, nil) {
+					return
+				}
+			}
+			if err != nil {
+				yield(nil, err)
+			}
+		}
+	}
+
+	var (
+		mu   sync.Mutex
+		seen []string
+	)
+	err := ingest.ForEachByHostSeq(t.Context(), seq(nil), ingest.HostLimits{Default: 1}, func(_ context.Context, ref reference.Named) error {
+		mu.Lock()
+		defer mu.Unlock()
+		seen = append(seen, reference.Path(ref))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"example/a-bundle", "example/b-bundle", "example/c-bundle"}, seen)
+
+	boom := errors.New("boom")
+	err = ingest.ForEachByHostSeq(t.Context(), seq(boom), ingest.HostLimits{Default: 1}, func(context.Context, reference.Named) error {
+		return nil
+	})
+	assert.ErrorIs(t, err, boom, "listing errors are returned")
+
+	err = ingest.ForEachByHostSeq(t.Context(), seq(nil), ingest.HostLimits{Default: 1}, func(_ context.Context, ref reference.Named) error {
+		if reference.Path(ref) == "example/b-bundle" {
+			return boom
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, boom)
+}
+
+func TestForEachByHostSeq_hungHost(t *testing.T) {
+	// registry.redhat.io hangs with more references than its queue holds,
+	// 1024, listed before those of quay.io, which must still be handled
+	// before registry.redhat.io is released.
+	refs := func(yield func(reference.Named, error) bool) {
+		for i := range 2000 {
+			ref, err := reference.ParseNormalizedNamed(fmt.Sprintf("registry.redhat.io/example/bundle-%d:v1", i))
+			if !yield(ref, err) {
+				return
+			}
+		}
+		for i := range 10 {
+			ref, err := reference.ParseNormalizedNamed(fmt.Sprintf("quay.io/example/bundle-%d:v1", i))
+			if !yield(ref, err) {
+				return
+			}
+		}
+	}
+
+	quayDone := make(chan struct{})
+	var (
+		mu         sync.Mutex
+		quay, rhio int
+	)
+	err := ingest.ForEachByHostSeq(t.Context(), refs, ingest.HostLimits{Default: 1}, func(ctx context.Context, ref reference.Named) error {
+		if reference.Domain(ref) == "registry.redhat.io" {
+			select {
+			case <-quayDone:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			rhio++
+			return nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		quay++
+		if quay == 10 {
+			close(quayDone)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 10, quay)
+	assert.Equal(t, 2000, rhio)
+}
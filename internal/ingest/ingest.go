@@ -0,0 +1,247 @@
+// Package ingest loads extensions, such as OLM bundles, into the database.
+package ingest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"iter"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/joelanford/extensiondb/internal/models"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/internal/registry"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+// ErrFetch is returned by Bundle when the metadata of an extension could not
+// be fetched from its source or could not be parsed.
+var ErrFetch = errors.New("failed to fetch extension metadata")
+
+// Plugin adds data from other systems to bundles as they are ingested.
+type Plugin interface {
+	// Name identifies the plugin in logs.
+	Name() string
+
+	// IngestBundle is called with every bundle that Bundle stores or
+	// associates with a bundle reference, so it must be idempotent.
+	IngestBundle(ctx context.Context, q *query.Query, b *models.Bundle) error
+}
+
+// Source is a source of extensions, such as a file-based catalog of OLM
+// bundles or a Helm chart repository.
+type Source interface {
+	// ListReferences returns the digest of the source's current contents and
+	// a canonical reference to each extension in it.
+	ListReferences(ctx context.Context) (digest.Digest, []reference.Canonical, error)
+
+	// FetchMetadata fetches the metadata of the extension at ref.
+	FetchMetadata(ctx context.Context, ref reference.Canonical) (*Metadata, error)
+}
+
+// StreamingSource is implemented by sources that can list their references
+// as they read them, such as file-based catalogs, so that large sources are
+// ingested without holding every reference in memory.
+type StreamingSource interface {
+	Source
+
+	// Digest returns the digest of the source's current contents.
+	Digest(ctx context.Context) (digest.Digest, error)
+
+	// References yields a canonical reference to each extension in the
+	// source. An error ends the listing.
+	References(ctx context.Context) iter.Seq2[reference.Canonical, error]
+}
+
+// DefaultChannelSource is implemented by sources whose packages declare a
+// default channel, such as file-based catalogs.
+type DefaultChannelSource interface {
+	// DefaultChannels returns the default channel of each package in the
+	// source, keyed by package name.
+	DefaultChannels(ctx context.Context) (map[string]string, error)
+}
+
+// CatalogSource is a catalog of OLM bundles, such as a file-based or SQLite
+// catalog, which lists its bundles as it reads them and declares the default
+// channels of its packages.
+type CatalogSource interface {
+	StreamingSource
+	DefaultChannelSource
+}
+
+// Metadata is the metadata of an extension, which is stored as a bundle.
+// Extensions that are not OCI images, such as Helm charts in a chart
+// repository, describe themselves with a synthesized manifest and image
+// config.
+type Metadata struct {
+	PackageName string
+	Version     string
+
+	// Release distinguishes rebuilds of the version. It is empty if the
+	// bundle has no release.
+	Release string
+
+	Descriptor ocispec.Descriptor
+	Index      *ocispec.Index
+	Manifest   ocispec.Manifest
+	Image      ocispec.Image
+
+	Properties    []models.BundleProperty
+	RelatedImages []models.RelatedImage
+
+	// CSV is the extension's ClusterServiceVersion as JSON, which is stored
+	// in the blob store of the query. It is nil for extensions without one.
+	CSV []byte
+
+	// QualityFlags are the problems that csvquality.Check found in the
+	// extension's ClusterServiceVersion. It is nil for extensions without
+	// one.
+	QualityFlags []string
+
+	// ContentFingerprint is the fingerprint of the extension's
+	// ClusterServiceVersion and other manifests computed by the fingerprint
+	// package, which is equal for rebuilds that change nothing that they
+	// install. It is empty for extensions without a CSV.
+	ContentFingerprint digest.Digest
+}
+
+// Origin identifies the ingestion path of a bundle, which Bundle records as
+// the bundle's provenance.
+type Origin struct {
+	// SourceType is one of the models.SourceType constants, and SourceID
+	// identifies the source, such as the catalog
+	// redhat-operator-index:v4.19 or the pushed image.
+	SourceType string
+	SourceID   string
+
+	// IngestionRunID is the ingestion run that ingests the bundle, if any.
+	IngestionRunID string
+}
+
+// provenance returns the provenance of b for an event of the origin.
+func (o Origin) provenance(b *models.Bundle, event string) *models.BundleProvenance {
+	return &models.BundleProvenance{
+		BundleID:       b.ID,
+		Event:          event,
+		SourceType:     o.SourceType,
+		SourceID:       o.SourceID,
+		IngestionRunID: sql.NullString{String: o.IngestionRunID, Valid: o.IngestionRunID != ""},
+	}
+}
+
+// Bundle ensures that the extension at ref is in the database and associated
+// with the bundle reference br. If the extension is already stored, it is
+// only associated with br, and origin is recorded as the last to update it.
+// Otherwise, its metadata is fetched from src and stored in one transaction,
+// along with origin as the one that created it. In both cases, the bundle is then
+// passed to the plugins. Bundle reports whether the bundle was created.
+func Bundle(ctx context.Context, q *query.Query, src Source, br *models.BundleReference, ref reference.Canonical, origin Origin, plugins ...Plugin) (bool, error) {
+	if b, err := q.GetBundleByDigest(ctx, ref.Digest()); err == nil {
+		if err := q.EnsureBundleReferenceBundle(ctx, b, br); err != nil {
+			return false, fmt.Errorf("error ensuring bundle reference %s: %w", ref, err)
+		}
+		if err := q.SetBundleProvenance(ctx, origin.provenance(b, "updated")); err != nil {
+			return false, fmt.Errorf("error recording bundle provenance: %w", err)
+		}
+		runPlugins(ctx, q, b, plugins)
+		return false, nil
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("error getting bundle: %w", err)
+	}
+
+	m, err := src.FetchMetadata(ctx, ref)
+	if err != nil {
+		if err := recordFetchFailure(ctx, q, ref, origin, err); err != nil {
+			return false, err
+		}
+		return false, fmt.Errorf("%w %v: %v", ErrFetch, ref, err)
+	}
+
+	p, err := q.GetOrCreatePackage(ctx, m.PackageName)
+	if err != nil {
+		return false, fmt.Errorf("error creating package %s: %w", m.PackageName, err)
+	}
+
+	b := &models.Bundle{
+		PackageID:  sql.NullString{String: p.ID, Valid: true},
+		Descriptor: models.JSONB[ocispec.Descriptor]{V: &m.Descriptor},
+		Index:      models.JSONB[ocispec.Index]{V: m.Index},
+		Manifest:   models.JSONB[ocispec.Manifest]{V: &m.Manifest},
+		Image:      models.JSONB[ocispec.Image]{V: &m.Image},
+		Version:    m.Version,
+		Release:    sql.NullString{String: m.Release, Valid: m.Release != ""},
+	}
+	if err := q.CreateBundle(ctx, query.NewBundle{
+		Bundle:             b,
+		Reference:          br,
+		Provenance:         origin.provenance(b, "created"),
+		FetchFailure:       ref.String(),
+		Properties:         m.Properties,
+		RelatedImages:      m.RelatedImages,
+		Labels:             bundleLabels(m.Image),
+		CSV:                m.CSV,
+		QualityFlags:       m.QualityFlags,
+		ContentFingerprint: m.ContentFingerprint,
+	}); err != nil {
+		return false, fmt.Errorf("error creating bundle: %w", err)
+	}
+	runPlugins(ctx, q, b, plugins)
+	return true, nil
+}
+
+// recordFetchFailure records the failure of src.FetchMetadata to fetch a
+// bundle image from its registry, so that it can be retried later. Failures
+// of sources that do not fetch from registries, such as Helm chart
+// repositories, and fetches interrupted by ctx are not recorded.
+func recordFetchFailure(ctx context.Context, q *query.Query, ref reference.Canonical, origin Origin, fetchErr error) error {
+	var retryErr *registry.RetryError
+	if !errors.As(fetchErr, &retryErr) || ctx.Err() != nil {
+		return nil
+	}
+	if err := q.RecordFetchFailure(ctx, &models.FetchFailure{
+		Reference:  ref.String(),
+		Error:      retryErr.Err.Error(),
+		Attempts:   retryErr.Attempts,
+		SourceType: origin.SourceType,
+		SourceID:   origin.SourceID,
+	}); err != nil {
+		return fmt.Errorf("error recording fetch failure of %s: %w", ref, err)
+	}
+	return nil
+}
+
+// labelPrefixes are the prefixes of the image labels that are stored as
+// bundle labels: the registry+v1 bundle labels and the labels that Red Hat
+// builds add, such as com.redhat.openshift.versions. The 018_bundle_labels
+// migration copies the same labels from bundles stored before it.
+var labelPrefixes = []string{
+	"operators.operatorframework.io.bundle.",
+	"com.redhat.",
+}
+
+// bundleLabels returns the labels of an image config that are stored as
+// bundle labels.
+func bundleLabels(img ocispec.Image) map[string]string {
+	labels := map[string]string{}
+	for key, value := range img.Config.Labels {
+		if slices.ContainsFunc(labelPrefixes, func(prefix string) bool { return strings.HasPrefix(key, prefix) }) {
+			labels[key] = value
+		}
+	}
+	return labels
+}
+
+// runPlugins passes b to each plugin. Plugin data is informational, so
+// failures are logged rather than failing the ingestion.
+func runPlugins(ctx context.Context, q *query.Query, b *models.Bundle, plugins []Plugin) {
+	for _, p := range plugins {
+		if err := p.IngestBundle(ctx, q, b); err != nil {
+			slog.WarnContext(ctx, "plugin failed to ingest bundle", "plugin", p.Name(), "digest", b.Descriptor.V.Digest, "error", err)
+		}
+	}
+}
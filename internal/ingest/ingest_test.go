@@ -0,0 +1,148 @@
+package ingest_test
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/csvquality"
+	"github.com/joelanford/extensiondb/internal/ingest"
+	"github.com/joelanford/extensiondb/internal/models"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/internal/registry/registrytest"
+	"github.com/joelanford/extensiondb/pkg/dbtest"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(dbtest.Main(m))
+}
+
+func TestBundle(t *testing.T) {
+	q := query.New(dbtest.New(t))
+	r := registrytest.New(t)
+	built := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	operatorImage := "quay.io/example/foo-operator@" + digest.FromString("operator").String()
+	ref := r.PushBundle(t, "example/foo-bundle", "v1.0.0", registrytest.Bundle{
+		Package:       "foo",
+		Version:       "1.0.0",
+		Created:       built,
+		Release:       "2",
+		RelatedImages: []string{operatorImage, "quay.io/example/foo-operand:latest"},
+	})
+
+	br, err := q.GetOrCreateCanonicalBundleReference(t.Context(), ref)
+	require.NoError(t, err)
+	run, err := q.CreateIngestionRun(t.Context())
+	require.NoError(t, err)
+	catalog := ingest.Origin{SourceType: models.SourceTypeCatalog, SourceID: "example-index:v1", IngestionRunID: run.ID}
+	created, err := ingest.Bundle(t.Context(), q, ingest.FBC{}, br, ref, catalog)
+	require.NoError(t, err)
+	assert.True(t, created)
+
+	webhook := ingest.Origin{SourceType: models.SourceTypeWebhook, SourceID: ref.String()}
+	created, err = ingest.Bundle(t.Context(), q, ingest.FBC{}, br, ref, webhook)
+	require.NoError(t, err)
+	assert.False(t, created, "bundles are only fetched once")
+
+	provenance, err := q.ListBundleProvenance(t.Context(), query.BundleProvenanceFilter{Digest: ref.Digest().String()})
+	require.NoError(t, err)
+	require.Len(t, provenance, 2)
+	assert.Equal(t, "created", provenance[0].Event)
+	assert.Equal(t, models.SourceTypeCatalog, provenance[0].SourceType)
+	assert.Equal(t, "example-index:v1", provenance[0].SourceID)
+	assert.Equal(t, sql.NullString{String: run.ID, Valid: true}, provenance[0].IngestionRunID)
+	assert.Equal(t, "updated", provenance[1].Event)
+	assert.Equal(t, models.SourceTypeWebhook, provenance[1].SourceType)
+	assert.False(t, provenance[1].IngestionRunID.Valid)
+	provenance, err = q.ListBundleProvenance(t.Context(), query.BundleProvenanceFilter{IngestionRunID: run.ID})
+	require.NoError(t, err)
+	assert.Len(t, provenance, 1)
+
+	b, err := q.GetBundleByDigest(t.Context(), ref.Digest())
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0", b.Version)
+	assert.Equal(t, sql.NullString{String: "2", Valid: true}, b.Release)
+	require.NotNil(t, b.Image.V.Created)
+	assert.True(t, built.Equal(*b.Image.V.Created))
+
+	csv, err := q.GetBundleCSV(t.Context(), b)
+	require.NoError(t, err)
+	assert.Contains(t, string(csv), `"kind":"ClusterServiceVersion"`)
+
+	// The test bundle's CSV declares no install modes.
+	flagged, err := q.ListFlaggedBundles(t.Context(), nil, []string{"foo"})
+	require.NoError(t, err)
+	require.Len(t, flagged, 1)
+	assert.Equal(t, []string{csvquality.InvalidInstallModes}, flagged[0].Flags)
+
+	pkg, err := q.GetPackageByName(t.Context(), "foo")
+	require.NoError(t, err)
+	assert.Equal(t, pkg.ID, b.PackageID.String)
+
+	labels, err := q.ListBundleLabels(t.Context(), query.BundleLabelFilter{Packages: []string{"foo"}})
+	require.NoError(t, err)
+	keys := make([]string, 0, len(labels))
+	for _, l := range labels {
+		keys = append(keys, l.Key)
+	}
+	assert.Contains(t, keys, "operators.operatorframework.io.bundle.package.v1")
+	assert.NotContains(t, keys, "release", "only selected labels are stored")
+
+	updated, err := q.SetImageVulnerabilityStatus(t.Context(), digest.FromString("operator"), "CVE-2024-0001", "affected")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), updated, "related images are stored")
+
+	// A rebuild whose only changes are its creation time and release has the
+	// same content fingerprint.
+	rebuildRef := r.PushBundle(t, "example/foo-bundle", "v1.0.0-3", registrytest.Bundle{
+		Package:       "foo",
+		Version:       "1.0.0",
+		Created:       built.Add(time.Hour),
+		Release:       "3",
+		RelatedImages: []string{operatorImage, "quay.io/example/foo-operand:latest"},
+	})
+	rebuildBR, err := q.GetOrCreateCanonicalBundleReference(t.Context(), rebuildRef)
+	require.NoError(t, err)
+	_, err = ingest.Bundle(t.Context(), q, ingest.FBC{}, rebuildBR, rebuildRef, catalog)
+	require.NoError(t, err)
+	rebuilds, err := q.ListIdenticalRebuilds(t.Context(), []string{"foo"})
+	require.NoError(t, err)
+	require.Len(t, rebuilds, 2)
+	assert.Equal(t, rebuilds[0].Fingerprint, rebuilds[1].Fingerprint)
+}
+
+func TestBundleFetchError(t *testing.T) {
+	q := query.New(dbtest.New(t))
+	r := registrytest.New(t)
+	ref, err := reference.WithDigest(r.Named("example/foo-bundle"), digest.FromString("missing"))
+	require.NoError(t, err)
+
+	br, err := q.GetOrCreateCanonicalBundleReference(t.Context(), ref)
+	require.NoError(t, err)
+	_, err = ingest.Bundle(t.Context(), q, ingest.FBC{}, br, ref, ingest.Origin{SourceType: models.SourceTypeWebhook, SourceID: ref.String()})
+	assert.ErrorIs(t, err, ingest.ErrFetch)
+
+	failures, err := q.ListFetchFailures(t.Context(), query.FetchFailureFilter{})
+	require.NoError(t, err)
+	require.Len(t, failures, 1)
+	assert.Equal(t, ref.String(), failures[0].Reference)
+	assert.Equal(t, 1, failures[0].Attempts, "missing images are not retried")
+	assert.Equal(t, models.SourceTypeWebhook, failures[0].SourceType)
+
+	// Once the image is pushed, ingesting it removes the failure.
+	ref = r.PushBundle(t, "example/foo-bundle", "v1.0.0", registrytest.Bundle{Package: "foo", Version: "1.0.0"})
+	br, err = q.GetOrCreateCanonicalBundleReference(t.Context(), ref)
+	require.NoError(t, err)
+	require.NoError(t, q.RecordFetchFailure(t.Context(), &models.FetchFailure{Reference: ref.String(), Error: "timeout", Attempts: 4, SourceType: models.SourceTypeWebhook, SourceID: ref.String()}))
+	_, err = ingest.Bundle(t.Context(), q, ingest.FBC{}, br, ref, ingest.Origin{SourceType: models.SourceTypeWebhook, SourceID: ref.String()})
+	require.NoError(t, err)
+	failures, err = q.ListFetchFailures(t.Context(), query.FetchFailureFilter{})
+	require.NoError(t, err)
+	require.Len(t, failures, 1)
+	assert.NotEqual(t, ref.String(), failures[0].Reference)
+}
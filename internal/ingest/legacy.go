@@ -0,0 +1,44 @@
+package ingest
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/joelanford/extensiondb/internal/registry"
+	"github.com/opencontainers/go-digest"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+// Legacy is a Source of the bundle images that the schema preceding the
+// packages and bundles tables recorded in a catalog. That schema recorded no
+// catalog digests, so the digest of a Legacy source is that of its sorted
+// references, which changes only when they do. Bundle images are fetched
+// from their registries, as by FBC.
+type Legacy struct {
+	Refs []reference.Canonical
+
+	// Registry fetches the bundle images.
+	Registry registry.Client
+}
+
+// ListReferences implements Source.
+func (s Legacy) ListReferences(context.Context) (digest.Digest, []reference.Canonical, error) {
+	refs := slices.SortedFunc(slices.Values(s.Refs), func(a, b reference.Canonical) int {
+		return strings.Compare(a.String(), b.String())
+	})
+	refs = slices.CompactFunc(refs, func(a, b reference.Canonical) bool {
+		return a.String() == b.String()
+	})
+	lines := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		lines = append(lines, ref.String())
+	}
+	return digest.FromString(strings.Join(lines, "\n")), refs, nil
+}
+
+// FetchMetadata implements Source by fetching the bundle image from its
+// registry.
+func (s Legacy) FetchMetadata(ctx context.Context, ref reference.Canonical) (*Metadata, error) {
+	return FBC{Registry: s.Registry}.FetchMetadata(ctx, ref)
+}
@@ -0,0 +1,34 @@
+package ingest_test
+
+import (
+	"testing"
+
+	"github.com/joelanford/extensiondb/internal/ingest"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+func TestLegacy(t *testing.T) {
+	var refs []reference.Canonical
+	for _, name := range []string{"foo", "bar", "foo"} {
+		ref, err := reference.ParseNamed("quay.io/example/" + name + "-bundle@" + digest.FromString(name).String())
+		require.NoError(t, err)
+		refs = append(refs, ref.(reference.Canonical))
+	}
+
+	gotDigest, gotRefs, err := ingest.Legacy{Refs: refs}.ListReferences(t.Context())
+	require.NoError(t, err)
+	require.Len(t, gotRefs, 2)
+	assert.Equal(t, refs[1].String(), gotRefs[0].String())
+	assert.Equal(t, refs[0].String(), gotRefs[1].String())
+
+	// The digest only depends on the set of references.
+	reordered, _, err := ingest.Legacy{Refs: []reference.Canonical{refs[1], refs[0]}}.ListReferences(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, gotDigest, reordered)
+	other, _, err := ingest.Legacy{Refs: refs[:1]}.ListReferences(t.Context())
+	require.NoError(t, err)
+	assert.NotEqual(t, gotDigest, other)
+}
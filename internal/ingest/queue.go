@@ -0,0 +1,146 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/joelanford/extensiondb/internal/models"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/internal/registry"
+	"go.podman.io/image/v5/docker/reference"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// Queue ingests bundle images one at a time, in the order they are enqueued.
+// Each ingestion is recorded as an ingestion run, so that cached responses
+// are revalidated once the bundle is stored.
+type Queue struct {
+	ingest func(context.Context, reference.Named, string) error
+
+	refs chan queuedRef
+
+	mu      sync.Mutex
+	pending sets.Set[string]
+}
+
+// NewQueue creates a queue that can hold up to size pending references, and
+// passes ingested bundles to the plugins.
+func NewQueue(q *query.Query, size int, plugins ...Plugin) *Queue {
+	return newQueue(func(ctx context.Context, ref reference.Named, sourceType string) error {
+		return ingestRun(ctx, q, ref, sourceType, plugins)
+	}, size)
+}
+
+func newQueue(ingest func(context.Context, reference.Named, string) error, size int) *Queue {
+	return &Queue{
+		ingest:  ingest,
+		refs:    make(chan queuedRef, size),
+		pending: sets.New[string](),
+	}
+}
+
+// queuedRef is an enqueued reference and the type of source that its bundle
+// is recorded as ingested from.
+type queuedRef struct {
+	ref        reference.Named
+	sourceType string
+}
+
+// Enqueue adds a tagged or canonical reference to the queue, whose bundle is
+// recorded as pushed, with models.SourceTypeWebhook. It reports whether the
+// reference was added; references that are already pending are not added
+// again, and nothing is added when the queue is full.
+func (q *Queue) Enqueue(ref reference.Named) bool {
+	return q.enqueue(ref, models.SourceTypeWebhook)
+}
+
+func (q *Queue) enqueue(ref reference.Named, sourceType string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pending.Has(ref.String()) {
+		return false
+	}
+	select {
+	case q.refs <- queuedRef{ref: ref, sourceType: sourceType}:
+		q.pending.Insert(ref.String())
+		return true
+	default:
+		return false
+	}
+}
+
+// QueueSource enqueues references to a Queue whose bundles are recorded as
+// ingested from a type of source.
+type QueueSource struct {
+	queue      *Queue
+	sourceType string
+}
+
+// Source returns a QueueSource that enqueues references to the queue whose
+// bundles are recorded as ingested from sourceType, such as
+// models.SourceTypeReadThrough.
+func (q *Queue) Source(sourceType string) *QueueSource {
+	return &QueueSource{queue: q, sourceType: sourceType}
+}
+
+// Enqueue is like Queue.Enqueue, but records the bundle of ref as ingested
+// from the QueueSource's type of source.
+func (s *QueueSource) Enqueue(ref reference.Named) bool {
+	return s.queue.enqueue(ref, s.sourceType)
+}
+
+// Run ingests enqueued references until ctx is done.
+func (q *Queue) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case queued := <-q.refs:
+			ref := queued.ref
+			q.mu.Lock()
+			q.pending.Delete(ref.String())
+			q.mu.Unlock()
+
+			if err := q.ingest(ctx, ref, queued.sourceType); err != nil {
+				slog.ErrorContext(ctx, "failed to ingest bundle", "reference", ref.String(), "error", err)
+				continue
+			}
+			slog.InfoContext(ctx, "ingested bundle", "reference", ref.String())
+		}
+	}
+}
+
+func ingestRun(ctx context.Context, q *query.Query, ref reference.Named, sourceType string, plugins []Plugin) error {
+	canonicalRef, ok := ref.(reference.Canonical)
+	if !ok {
+		tagged, ok := ref.(reference.NamedTagged)
+		if !ok {
+			return fmt.Errorf("reference %s has neither a tag nor a digest", ref)
+		}
+		var err error
+		canonicalRef, err = registry.ResolveDigest(ctx, tagged)
+		if err != nil {
+			return err
+		}
+	}
+
+	run, err := q.CreateIngestionRun(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create ingestion run: %w", err)
+	}
+	ingestErr := func() error {
+		br, err := q.GetOrCreateCanonicalBundleReference(ctx, canonicalRef)
+		if err != nil {
+			return fmt.Errorf("error creating bundle reference %s: %w", canonicalRef, err)
+		}
+		origin := Origin{SourceType: sourceType, SourceID: ref.String(), IngestionRunID: run.ID}
+		_, err = Bundle(ctx, q, FBC{}, br, canonicalRef, origin, plugins...)
+		return err
+	}()
+	if err := q.FinishIngestionRun(context.WithoutCancel(ctx), run, ingestErr); err != nil {
+		return err
+	}
+	return ingestErr
+}
@@ -0,0 +1,51 @@
+package ingest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+func TestQueue(t *testing.T) {
+	ingested := make(chan string)
+	q := newQueue(func(_ context.Context, ref reference.Named, sourceType string) error {
+		ingested <- sourceType + " " + ref.String()
+		return nil
+	}, 2)
+
+	foo, err := reference.ParseNormalizedNamed("quay.io/example/foo-bundle:v1.0.0")
+	require.NoError(t, err)
+	bar, err := reference.ParseNormalizedNamed("quay.io/example/bar-bundle:v1.0.0")
+	require.NoError(t, err)
+	baz, err := reference.ParseNormalizedNamed("quay.io/example/baz-bundle:v1.0.0")
+	require.NoError(t, err)
+
+	// Pending references are not enqueued twice, and nothing is enqueued when
+	// the queue is full.
+	assert.True(t, q.Enqueue(foo))
+	assert.False(t, q.Enqueue(foo))
+	assert.False(t, q.Source(models.SourceTypeReadThrough).Enqueue(foo))
+	assert.True(t, q.Source(models.SourceTypeReadThrough).Enqueue(bar))
+	assert.False(t, q.Enqueue(baz))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx)
+
+	for _, want := range []string{"webhook " + foo.String(), "read-through " + bar.String()} {
+		select {
+		case got := <-ingested:
+			assert.Equal(t, want, got)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s to be ingested", want)
+		}
+	}
+
+	// Once a reference has been ingested, it can be enqueued again.
+	assert.True(t, q.Enqueue(foo))
+}
@@ -0,0 +1,159 @@
+package ingest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
+
+	"github.com/joelanford/extensiondb/internal/registry"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/opencontainers/go-digest"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+// SQLite is a Source of the registry+v1 bundle images in the SQLite database
+// of a catalog from before file-based catalogs, such as those of OpenShift
+// 4.10 and earlier, extracted to Dir/index.db. Like FBC, the digest of the
+// catalog image is read from Dir/.metadata/digest, and bundle images are
+// fetched from their registries.
+type SQLite struct {
+	Dir string
+
+	// Packages selects the packages whose bundles and default channels are
+	// listed. The digest is that of the whole catalog either way.
+	Packages PackageFilter
+
+	// Registry fetches the bundle images.
+	Registry registry.Client
+}
+
+// CatalogDir returns the Source of the catalog extracted to dir: SQLite if
+// dir holds the database of a SQLite catalog, or else FBC. Bundle images are
+// fetched with reg.
+func CatalogDir(dir string, packages PackageFilter, reg registry.Client) CatalogSource {
+	if _, err := os.Stat(filepath.Join(dir, registry.SQLiteCatalogFile)); err == nil {
+		return SQLite{Dir: dir, Packages: packages, Registry: reg}
+	}
+	return FBC{Dir: dir, Packages: packages, Registry: reg}
+}
+
+// ListReferences implements Source.
+func (s SQLite) ListReferences(ctx context.Context) (digest.Digest, []reference.Canonical, error) {
+	catalogDigest, err := s.Digest(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	var refs []reference.Canonical
+	for ref, err := range s.References(ctx) {
+		if err != nil {
+			return "", nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return catalogDigest, refs, nil
+}
+
+// Digest implements StreamingSource.
+func (s SQLite) Digest(ctx context.Context) (digest.Digest, error) {
+	return FBC{Dir: s.Dir}.Digest(ctx)
+}
+
+// References implements StreamingSource. Bundles are listed once, although
+// the database has an entry for each channel they are in, and bundles
+// without an image, which older catalogs stored inline, are skipped.
+func (s SQLite) References(ctx context.Context) iter.Seq2[reference.Canonical, error] {
+	return func(yield func(reference.Canonical, error) bool) {
+		db, err := s.open()
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer db.Close()
+
+		rows, err := db.QueryContext(ctx, `
+			SELECT DISTINCT e.package_name, b.bundlepath
+			FROM channel_entry AS e
+			JOIN operatorbundle AS b ON b.name = e.operatorbundle_name
+			WHERE b.bundlepath != ''
+			ORDER BY e.package_name, b.bundlepath`)
+		if err != nil {
+			yield(nil, fmt.Errorf("error listing bundles: %w", err))
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var packageName, image string
+			if err := rows.Scan(&packageName, &image); err != nil {
+				yield(nil, err)
+				return
+			}
+			if !s.Packages.Matches(packageName) {
+				continue
+			}
+			namedRef, err := reference.ParseNamed(image)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			canonicalRef, ok := namedRef.(reference.Canonical)
+			if !ok {
+				yield(nil, fmt.Errorf("image reference %s is not a canonical reference", image))
+				return
+			}
+			if !yield(canonicalRef, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// DefaultChannels implements DefaultChannelSource.
+func (s SQLite) DefaultChannels(ctx context.Context) (map[string]string, error) {
+	db, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, `SELECT name, default_channel FROM package WHERE default_channel != ''`)
+	if err != nil {
+		return nil, fmt.Errorf("error listing packages: %w", err)
+	}
+	defer rows.Close()
+	channels := map[string]string{}
+	for rows.Next() {
+		var name, defaultChannel string
+		if err := rows.Scan(&name, &defaultChannel); err != nil {
+			return nil, err
+		}
+		if s.Packages.Matches(name) {
+			channels[name] = defaultChannel
+		}
+	}
+	return channels, rows.Err()
+}
+
+// FetchMetadata implements Source by fetching the bundle image from its
+// registry.
+func (s SQLite) FetchMetadata(ctx context.Context, ref reference.Canonical) (*Metadata, error) {
+	return FBC{Registry: s.Registry}.FetchMetadata(ctx, ref)
+}
+
+// open opens the catalog's database read-only.
+func (s SQLite) open() (*sql.DB, error) {
+	path := filepath.Join(s.Dir, registry.SQLiteCatalogFile)
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("error opening catalog database %s: %w", path, err)
+	}
+	return db, nil
+}
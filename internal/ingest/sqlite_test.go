@@ -0,0 +1,101 @@
+package ingest_test
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joelanford/extensiondb/internal/ingest"
+	"github.com/joelanford/extensiondb/internal/registry"
+	"github.com/joelanford/extensiondb/internal/registry/registrytest"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+// writeSQLiteCatalog writes a SQLite catalog with the tables of opm's
+// database schema that catalogs are read from to dir, along with the digest
+// of its image.
+func writeSQLiteCatalog(t *testing.T, dir string, catalogDigest digest.Digest) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(dir, registry.SQLiteCatalogFile))
+	require.NoError(t, err)
+	defer db.Close()
+	for _, stmt := range []string{
+		`CREATE TABLE package (name TEXT PRIMARY KEY, default_channel TEXT)`,
+		`CREATE TABLE operatorbundle (name TEXT PRIMARY KEY, csv TEXT, bundle TEXT, bundlepath TEXT, version TEXT)`,
+		`CREATE TABLE channel_entry (entry_id INTEGER PRIMARY KEY, channel_name TEXT, package_name TEXT, operatorbundle_name TEXT, replaces INTEGER, depth INTEGER)`,
+		`INSERT INTO package VALUES ('foo', 'stable'), ('bar', 'alpha'), ('baz', '')`,
+		`INSERT INTO operatorbundle (name, bundlepath, version) VALUES
+			('foo.v1.0.0', 'quay.io/example/foo-bundle@` + digest.FromString("foo.v1.0.0").String() + `', '1.0.0'),
+			('foo.v1.0.1', 'quay.io/example/foo-bundle@` + digest.FromString("foo.v1.0.1").String() + `', '1.0.1'),
+			('bar.v0.1.0', 'quay.io/example/bar-bundle@` + digest.FromString("bar.v0.1.0").String() + `', '0.1.0'),
+			('baz.v0.0.1', '', '0.0.1')`,
+		`INSERT INTO channel_entry (channel_name, package_name, operatorbundle_name) VALUES
+			('stable', 'foo', 'foo.v1.0.0'), ('stable', 'foo', 'foo.v1.0.1'), ('fast', 'foo', 'foo.v1.0.1'),
+			('alpha', 'bar', 'bar.v0.1.0'), ('alpha', 'baz', 'baz.v0.0.1')`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err)
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".metadata"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".metadata", "digest"), []byte(catalogDigest.Encoded()), 0o644))
+}
+
+func TestSQLite(t *testing.T) {
+	dir := t.TempDir()
+	catalogDigest := digest.FromString("index")
+	writeSQLiteCatalog(t, dir, catalogDigest)
+
+	src := ingest.CatalogDir(dir, ingest.PackageFilter{}, registry.Client{})
+	require.IsType(t, ingest.SQLite{}, src)
+	gotDigest, refs, err := src.ListReferences(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, catalogDigest, gotDigest)
+	var got []string
+	for _, ref := range refs {
+		got = append(got, ref.String())
+	}
+	assert.Equal(t, []string{
+		"quay.io/example/bar-bundle@" + digest.FromString("bar.v0.1.0").String(),
+		"quay.io/example/foo-bundle@" + digest.FromString("foo.v1.0.0").String(),
+		"quay.io/example/foo-bundle@" + digest.FromString("foo.v1.0.1").String(),
+	}, got, "bundles in several channels are listed once, and bundles without an image are skipped")
+
+	channels, err := src.DefaultChannels(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"foo": "stable", "bar": "alpha"}, channels)
+
+	src = ingest.CatalogDir(dir, ingest.PackageFilter{Include: []string{"bar"}}, registry.Client{})
+	_, refs, err = src.ListReferences(t.Context())
+	require.NoError(t, err)
+	require.Len(t, refs, 1)
+	assert.Equal(t, "quay.io/example/bar-bundle", refs[0].Name())
+	channels, err = src.DefaultChannels(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"bar": "alpha"}, channels)
+
+	assert.IsType(t, ingest.FBC{}, ingest.CatalogDir(t.TempDir(), ingest.PackageFilter{}, registry.Client{}), "directories without a database are file-based catalogs")
+}
+
+func TestCatalogImage_SQLite(t *testing.T) {
+	dir := t.TempDir()
+	writeSQLiteCatalog(t, dir, digest.FromString("index"))
+	database, err := os.ReadFile(filepath.Join(dir, registry.SQLiteCatalogFile))
+	require.NoError(t, err)
+
+	r := registrytest.New(t)
+	r.PushCatalog(t, "example/index", "v4.10", registrytest.Catalog{Database: database})
+	tagged, err := reference.WithTag(r.Named("example/index"), "v4.10")
+	require.NoError(t, err)
+
+	src := &ingest.CatalogImage{Ref: tagged, CacheDir: t.TempDir(), Packages: ingest.PackageFilter{Include: []string{"foo"}}}
+	_, refs, err := src.ListReferences(t.Context())
+	require.NoError(t, err)
+	assert.Len(t, refs, 2)
+	channels, err := src.DefaultChannels(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"foo": "stable"}, channels)
+}
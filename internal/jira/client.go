@@ -0,0 +1,115 @@
+// Package jira files and links Jira issues for problems detected in the
+// database, using the Jira projects and components configured for each
+// catalog and package.
+package jira
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Client creates issues with the Jira REST API.
+type Client struct {
+	// IssueType is the type of the issues that the client creates.
+	IssueType string
+
+	baseURL    *url.URL
+	username   string
+	token      string
+	httpClient *http.Client
+}
+
+// NewClient creates a client for the Jira server at baseURL. If username is
+// set, the client authenticates with basic authentication using the token as
+// the password, as Jira Cloud API tokens require. Otherwise, the token is sent
+// as a bearer token, as Jira Data Center personal access tokens require. If
+// httpClient is nil, http.DefaultClient is used.
+func NewClient(baseURL, username, token string, httpClient *http.Client) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Jira URL %q: %w", baseURL, err)
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		IssueType:  "Bug",
+		baseURL:    u,
+		username:   username,
+		token:      token,
+		httpClient: httpClient,
+	}, nil
+}
+
+// Issue is an issue to create.
+type Issue struct {
+	Project     string
+	Component   string
+	Summary     string
+	Description string
+}
+
+type nameField struct {
+	Name string `json:"name,omitempty"`
+	Key  string `json:"key,omitempty"`
+}
+
+type issueFields struct {
+	Project     nameField   `json:"project"`
+	IssueType   nameField   `json:"issuetype"`
+	Components  []nameField `json:"components,omitempty"`
+	Summary     string      `json:"summary"`
+	Description string      `json:"description"`
+}
+
+// CreateIssue creates an issue and returns its key.
+func (c *Client) CreateIssue(ctx context.Context, issue Issue) (string, error) {
+	fields := issueFields{
+		Project:     nameField{Key: issue.Project},
+		IssueType:   nameField{Name: c.IssueType},
+		Summary:     issue.Summary,
+		Description: issue.Description,
+	}
+	if issue.Component != "" {
+		fields.Components = []nameField{{Name: issue.Component}}
+	}
+	body, err := json.Marshal(map[string]any{"fields": fields})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL.JoinPath("/rest/api/2/issue").String(), bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.token)
+	} else if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return "", fmt.Errorf("jira responded with status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	var created struct {
+		Key string `json:"key"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", fmt.Errorf("error decoding response: %w", err)
+	}
+	return created.Key, nil
+}
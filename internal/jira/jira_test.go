@@ -0,0 +1,99 @@
+package jira_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/internal/jira"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/joelanford/extensiondb/pkg/graph/graphtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fooGraph returns the graph of package foo with versions 1.0.0, 1.0.1,
+// 1.1.0, and 2.0.0, in which 1.1.0 can be updated to from minimumUpdateVersion.
+func fooGraph(t *testing.T, minimumUpdateVersion string) *graph.Graph {
+	t.Helper()
+	streams := graphtest.Streams(graph.MajorMinor{Major: 1, Minor: 0}, graph.MajorMinor{Major: 1, Minor: 1}, graph.MajorMinor{Major: 2, Minor: 0})
+	streams[1].MinimumUpdateVersion = semver.MustParse(minimumUpdateVersion)
+	return graphtest.New(t, graph.Package{Name: "foo", Streams: streams, Nodes: graphtest.Nodes("foo", "1.0.0", "1.0.1", "1.1.0", "2.0.0")})
+}
+
+func TestDeadEnds(t *testing.T) {
+	// 1.0.0 can still update to 1.0.1, and the heads of each major version
+	// are not dead ends.
+	assert.Empty(t, jira.DeadEnds(fooGraph(t, "1.0.1"), "foo"))
+
+	var deadEnds []string
+	for _, n := range jira.DeadEnds(fooGraph(t, "1.0.2"), "foo") {
+		deadEnds = append(deadEnds, n.VR())
+	}
+	assert.Equal(t, []string{"1.0.1"}, deadEnds)
+}
+
+func TestOrphans(t *testing.T) {
+	// The lowest version of each major version is a root, but not an
+	// orphan.
+	g := fooGraph(t, "1.0.1")
+	assert.ElementsMatch(t, []string{"1.0.0", "2.0.0"}, versions(g.RootsFor("foo").UnsortedList()))
+	assert.Empty(t, jira.Orphans(g, "foo"))
+
+	// No version of 1.0 can update to 1.1.0.
+	g = fooGraph(t, "1.0.2")
+	assert.Equal(t, []string{"1.1.0"}, versions(jira.Orphans(g, "foo")))
+}
+
+func versions(nodes []*graph.Node) []string {
+	vs := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		vs = append(vs, n.VR())
+	}
+	return vs
+}
+
+type linker map[string]string
+
+func (l linker) LinkJiraIssue(_ context.Context, problem, issueKey string) error {
+	l[problem] = issueKey
+	return nil
+}
+
+func TestFile(t *testing.T) {
+	var created []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rest/api/2/issue", r.URL.Path)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		var body struct {
+			Fields map[string]any `json:"fields"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		created = append(created, body.Fields)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id": "10000", "key": "FOO-1"}`))
+	}))
+	defer srv.Close()
+
+	c, err := jira.NewClient(srv.URL, "", "secret", srv.Client())
+	require.NoError(t, err)
+
+	l := linker{}
+	problems, err := jira.File(context.Background(), c, l, []jira.Problem{
+		{ID: "dead-ends:foo", Summary: "foo has dead ends", Project: "FOO", Component: "Operator"},
+		{ID: "dead-ends:bar", Summary: "bar has dead ends"},
+		{ID: "dead-ends:baz", Summary: "baz has dead ends", Project: "BAZ", IssueKey: "BAZ-7"},
+	})
+	require.NoError(t, err)
+
+	// Only the problem with a project and without an issue is filed.
+	require.Len(t, created, 1)
+	assert.Equal(t, map[string]any{"key": "FOO"}, created[0]["project"])
+	assert.Equal(t, map[string]any{"name": "Bug"}, created[0]["issuetype"])
+	assert.Equal(t, []any{map[string]any{"name": "Operator"}}, created[0]["components"])
+	assert.Equal(t, linker{"dead-ends:foo": "FOO-1"}, l)
+	assert.Equal(t, []string{"FOO-1", "", "BAZ-7"}, []string{problems[0].IssueKey, problems[1].IssueKey, problems[2].IssueKey})
+}
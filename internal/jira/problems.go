@@ -0,0 +1,237 @@
+package jira
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/graphdb"
+	"github.com/joelanford/extensiondb/internal/models"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/joelanford/extensiondb/pkg/util"
+)
+
+const (
+	// ProblemKindMissingBundles is a catalog that references bundles whose
+	// images could not be ingested.
+	ProblemKindMissingBundles = "missing-bundles"
+
+	// ProblemKindDeadEnds is a package whose update graph has versions that
+	// cannot update to a newer version of the same major version.
+	ProblemKindDeadEnds = "dead-ends"
+
+	// ProblemKindOrphans is a package whose update graph has versions that
+	// no older version of the same major version can update to.
+	ProblemKindOrphans = "orphans"
+)
+
+// Problem is a problem detected in the database. Problems are filed in the
+// bug project and component of the catalog or package they affect.
+type Problem struct {
+	// ID identifies the problem across detections, so that each problem is
+	// tracked by a single issue.
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+
+	Catalog string `json:"catalog,omitempty"`
+	Package string `json:"package,omitempty"`
+
+	Summary     string `json:"summary"`
+	Description string `json:"description"`
+
+	Project   string `json:"project,omitempty"`
+	Component string `json:"component,omitempty"`
+
+	// IssueKey is the key of the issue that tracks the problem, if any.
+	IssueKey string `json:"issueKey,omitempty"`
+}
+
+// Detect returns the problems in the database: catalogs with missing bundles
+// and packages whose update graphs have dead ends or orphans. Only packages
+// that have a template are checked for dead ends and orphans.
+func Detect(ctx context.Context, q *query.Query, builder *graphdb.Builder, templates []graph.Template, asOf time.Time) ([]Problem, error) {
+	var problems []Problem
+
+	catalogs, err := q.ListCatalogs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range catalogs {
+		missing, err := q.GetMissingBundlesInCatalog(ctx, c)
+		if err != nil {
+			return nil, fmt.Errorf("error getting missing bundles for catalog %s:%s: %w", c.Name, c.Tag, err)
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		refs := make([]string, 0, len(missing))
+		for _, br := range missing {
+			refs = append(refs, fmt.Sprintf("* %s@%s", br.Repo, br.Digest.String))
+		}
+		ref := fmt.Sprintf("%s:%s", c.Name, c.Tag)
+		problems = append(problems, Problem{
+			ID:          fmt.Sprintf("%s:%s", ProblemKindMissingBundles, ref),
+			Kind:        ProblemKindMissingBundles,
+			Catalog:     ref,
+			Summary:     fmt.Sprintf("Catalog %s references %d bundles that could not be ingested", ref, len(missing)),
+			Description: fmt.Sprintf("The images of these bundles could not be fetched or parsed:\n%s", strings.Join(refs, "\n")),
+			Project:     c.JiraBugProject.String,
+			Component:   c.JiraBugComponent.String,
+		})
+	}
+
+	for _, tmpl := range templates {
+		pkg, err := q.GetPackageByName(ctx, tmpl.Name)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		g, err := builder.Build(ctx, []graph.Template{tmpl}, asOf)
+		if err != nil {
+			return nil, fmt.Errorf("error building graph for package %q: %w", tmpl.Name, err)
+		}
+		if p, ok := deadEndsProblem(g, pkg); ok {
+			problems = append(problems, p)
+		}
+		if p, ok := orphansProblem(g, pkg); ok {
+			problems = append(problems, p)
+		}
+	}
+
+	issues, err := q.ListJiraIssues(ctx)
+	if err != nil {
+		return nil, err
+	}
+	issueKeys := util.KeySlice(issues, func(ji *models.JiraIssue) string { return ji.Problem })
+	for i := range problems {
+		if ji, ok := issueKeys[problems[i].ID]; ok {
+			problems[i].IssueKey = ji.IssueKey
+		}
+	}
+	return problems, nil
+}
+
+func deadEndsProblem(g *graph.Graph, pkg *models.Package) (Problem, bool) {
+	deadEnds := DeadEnds(g, pkg.Name)
+	if len(deadEnds) == 0 {
+		return Problem{}, false
+	}
+	versions := make([]string, 0, len(deadEnds))
+	for _, n := range deadEnds {
+		versions = append(versions, fmt.Sprintf("* %s", n.VR()))
+	}
+	return Problem{
+		ID:          fmt.Sprintf("%s:%s", ProblemKindDeadEnds, pkg.Name),
+		Kind:        ProblemKindDeadEnds,
+		Package:     pkg.Name,
+		Summary:     fmt.Sprintf("Update graph for %s has %d dead-end versions", pkg.Name, len(deadEnds)),
+		Description: fmt.Sprintf("These versions cannot update to a newer version of the same major version:\n%s", strings.Join(versions, "\n")),
+		Project:     pkg.JiraBugProject.String,
+		Component:   pkg.JiraBugComponent.String,
+	}, true
+}
+
+// DeadEnds returns the nodes of a package, ordered by version, that have no
+// successors even though a newer version of the same major version is in the
+// graph. Pre-GA nodes are ignored, because they never have edges.
+func DeadEnds(g *graph.Graph, pkg string) []*graph.Node {
+	nodes := slices.SortedFunc(g.NodesMatching(graph.PackageNodes(pkg)), util.Compare)
+	nodes = slices.DeleteFunc(nodes, func(n *graph.Node) bool {
+		return n.LifecyclePhase == graph.LifecyclePhasePreGA
+	})
+
+	var deadEnds []*graph.Node
+	for i, n := range nodes {
+		if !g.HeadsFor(pkg).Has(n) {
+			continue
+		}
+		if slices.ContainsFunc(nodes[i+1:], func(newer *graph.Node) bool {
+			return newer.Version.Major == n.Version.Major && newer.Version.GT(n.Version)
+		}) {
+			deadEnds = append(deadEnds, n)
+		}
+	}
+	return deadEnds
+}
+
+func orphansProblem(g *graph.Graph, pkg *models.Package) (Problem, bool) {
+	orphans := Orphans(g, pkg.Name)
+	if len(orphans) == 0 {
+		return Problem{}, false
+	}
+	versions := make([]string, 0, len(orphans))
+	for _, n := range orphans {
+		versions = append(versions, fmt.Sprintf("* %s", n.VR()))
+	}
+	return Problem{
+		ID:          fmt.Sprintf("%s:%s", ProblemKindOrphans, pkg.Name),
+		Kind:        ProblemKindOrphans,
+		Package:     pkg.Name,
+		Summary:     fmt.Sprintf("Update graph for %s has %d orphaned versions", pkg.Name, len(orphans)),
+		Description: fmt.Sprintf("No older version of the same major version can update to these versions:\n%s", strings.Join(versions, "\n")),
+		Project:     pkg.JiraBugProject.String,
+		Component:   pkg.JiraBugComponent.String,
+	}, true
+}
+
+// Orphans returns the nodes of a package, ordered by version, that have no
+// predecessors even though an older version of the same major version is in
+// the graph, so that only new installations can reach them. Pre-GA nodes are
+// ignored, because they never have edges.
+func Orphans(g *graph.Graph, pkg string) []*graph.Node {
+	nodes := slices.SortedFunc(g.NodesMatching(graph.PackageNodes(pkg)), util.Compare)
+	nodes = slices.DeleteFunc(nodes, func(n *graph.Node) bool {
+		return n.LifecyclePhase == graph.LifecyclePhasePreGA
+	})
+
+	var orphans []*graph.Node
+	for i, n := range nodes {
+		if !g.RootsFor(pkg).Has(n) {
+			continue
+		}
+		if slices.ContainsFunc(nodes[:i], func(older *graph.Node) bool {
+			return older.Version.Major == n.Version.Major && older.Version.LT(n.Version)
+		}) {
+			orphans = append(orphans, n)
+		}
+	}
+	return orphans
+}
+
+// Linker records the issue that tracks a problem.
+type Linker interface {
+	LinkJiraIssue(ctx context.Context, problem, issueKey string) error
+}
+
+// File creates an issue for each problem that is not yet tracked by one and
+// whose catalog or package has a bug project, links the problem to it, and
+// returns the problems with their issue keys.
+func File(ctx context.Context, c *Client, l Linker, problems []Problem) ([]Problem, error) {
+	filed := slices.Clone(problems)
+	for i, p := range filed {
+		if p.IssueKey != "" || p.Project == "" {
+			continue
+		}
+		key, err := c.CreateIssue(ctx, Issue{
+			Project:     p.Project,
+			Component:   p.Component,
+			Summary:     p.Summary,
+			Description: p.Description,
+		})
+		if err != nil {
+			return filed, fmt.Errorf("error filing issue for problem %q: %w", p.ID, err)
+		}
+		if err := l.LinkJiraIssue(ctx, p.ID, key); err != nil {
+			return filed, fmt.Errorf("error linking problem %q to issue %s: %w", p.ID, key, err)
+		}
+		filed[i].IssueKey = key
+	}
+	return filed, nil
+}
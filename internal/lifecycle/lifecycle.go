@@ -0,0 +1,85 @@
+// Package lifecycle reports the lifecycle phases of the version streams of
+// every package as of a date.
+package lifecycle
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/joelanford/extensiondb/pkg/graph"
+)
+
+// Stream is the lifecycle of a version stream of a package as of a date.
+type Stream struct {
+	Package   string           `json:"package"`
+	Stream    graph.MajorMinor `json:"stream"`
+	Phase     string           `json:"phase"`
+	EndOfLife graph.Date       `json:"endOfLife"`
+
+	// NextPhase is the phase that follows Phase, and NextPhaseDate the date
+	// on which it begins. They are empty for streams that have reached their
+	// end of life.
+	NextPhase     string      `json:"nextPhase,omitempty"`
+	NextPhaseDate *graph.Date `json:"nextPhaseDate,omitempty"`
+
+	// DaysRemaining is the number of days until NextPhaseDate.
+	DaysRemaining *int `json:"daysRemaining,omitempty"`
+
+	// Successor is the stream to move to: the oldest newer stream of the
+	// package that is generally available and reaches its end of life after
+	// this stream does. It is nil if there is no such stream.
+	Successor *graph.MajorMinor `json:"successor,omitempty"`
+}
+
+// Streams returns the lifecycle of every version stream of the templates as
+// of asOf, ordered by package and stream.
+func Streams(templates []graph.Template, asOf time.Time) []Stream {
+	var streams []Stream
+	for _, tmpl := range templates {
+		vss := slices.SortedFunc(slices.Values(tmpl.VersionStreams), func(a, b graph.VersionStream) int {
+			return a.Version.Compare(b.Version)
+		})
+		for i, vs := range vss {
+			s := Stream{
+				Package:   tmpl.Name,
+				Stream:    vs.Version,
+				Phase:     vs.LifecycleDates.Phase(asOf).String(),
+				EndOfLife: vs.LifecycleDates.EndOfLife,
+			}
+			if next, date, ok := vs.LifecycleDates.NextPhase(asOf); ok {
+				days := int(math.Ceil(date.Time().Sub(asOf).Hours() / 24))
+				s.NextPhase, s.NextPhaseDate, s.DaysRemaining = next.String(), &date, &days
+			}
+			for _, newer := range vss[i+1:] {
+				if newer.LifecycleDates.Phase(asOf) != graph.LifecyclePhasePreGA &&
+					newer.LifecycleDates.EndOfLife.Time().After(vs.LifecycleDates.EndOfLife.Time()) {
+					s.Successor = &newer.Version
+					break
+				}
+			}
+			streams = append(streams, s)
+		}
+	}
+	slices.SortStableFunc(streams, func(a, b Stream) int {
+		return strings.Compare(a.Package, b.Package)
+	})
+	return streams
+}
+
+// EndingBefore returns the streams that have not reached their end of life
+// as of asOf, but will before the given date, ordered by end of life.
+func EndingBefore(streams []Stream, asOf, before time.Time) []Stream {
+	var ending []Stream
+	for _, s := range streams {
+		eol := s.EndOfLife.Time()
+		if !asOf.After(eol) && eol.Before(before) {
+			ending = append(ending, s)
+		}
+	}
+	slices.SortStableFunc(ending, func(a, b Stream) int {
+		return a.EndOfLife.Time().Compare(b.EndOfLife.Time())
+	})
+	return ending
+}
@@ -0,0 +1,82 @@
+package lifecycle_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/lifecycle"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreams(t *testing.T) {
+	stream := func(minor uint64, ga, maintenance, eol graph.Date) graph.VersionStream {
+		return graph.VersionStream{
+			Version: graph.MajorMinor{Major: 1, Minor: minor},
+			LifecycleDates: graph.LifecycleDates{
+				FullSupport: ga,
+				Maintenance: maintenance,
+				EndOfLife:   eol,
+			},
+		}
+	}
+	templates := []graph.Template{
+		{Name: "foo", VersionStreams: []graph.VersionStream{
+			stream(2, graph.NewDate(2025, 1, 1), graph.NewDate(2025, 6, 1), graph.NewDate(2026, 1, 1)),
+			stream(0, graph.NewDate(2023, 1, 1), graph.NewDate(2023, 6, 1), graph.NewDate(2024, 1, 1)),
+			stream(1, graph.NewDate(2024, 1, 1), graph.NewDate(2024, 6, 1), graph.NewDate(2024, 12, 1)),
+			stream(3, graph.NewDate(2024, 8, 1), graph.NewDate(2025, 6, 1), graph.NewDate(2027, 1, 1)),
+		}},
+		{Name: "bar", VersionStreams: []graph.VersionStream{
+			stream(0, graph.NewDate(2024, 1, 1), graph.NewDate(2024, 6, 1), graph.NewDate(2025, 1, 1)),
+		}},
+	}
+	asOf := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+
+	streams := lifecycle.Streams(templates, asOf)
+	require.Len(t, streams, 5)
+
+	assert.Equal(t, "bar", streams[0].Package)
+	assert.Nil(t, streams[0].Successor)
+
+	eol := streams[1]
+	assert.Equal(t, "foo", eol.Package)
+	assert.Equal(t, graph.MajorMinor{Major: 1, Minor: 0}, eol.Stream)
+	assert.Equal(t, "End of Life", eol.Phase)
+	assert.Empty(t, eol.NextPhase)
+	assert.Nil(t, eol.NextPhaseDate)
+	assert.Nil(t, eol.DaysRemaining)
+	assert.Equal(t, &graph.MajorMinor{Major: 1, Minor: 1}, eol.Successor)
+
+	maintenance := streams[2]
+	assert.Equal(t, graph.MajorMinor{Major: 1, Minor: 1}, maintenance.Stream)
+	assert.Equal(t, "Maintenance", maintenance.Phase)
+	assert.Equal(t, "End of Life", maintenance.NextPhase)
+	assert.Equal(t, graph.NewDate(2024, 12, 1), *maintenance.NextPhaseDate)
+	assert.Equal(t, 91, *maintenance.DaysRemaining)
+	assert.Equal(t, &graph.MajorMinor{Major: 1, Minor: 3}, maintenance.Successor, "pre-GA streams are skipped")
+
+	preGA := streams[3]
+	assert.Equal(t, "Pre-GA", preGA.Phase)
+	assert.Equal(t, "Full Support", preGA.NextPhase)
+	assert.Equal(t, graph.NewDate(2025, 1, 1), *preGA.NextPhaseDate)
+	assert.Equal(t, &graph.MajorMinor{Major: 1, Minor: 3}, preGA.Successor)
+
+	assert.Nil(t, streams[4].Successor)
+}
+
+func TestEndingBefore(t *testing.T) {
+	streams := []lifecycle.Stream{
+		{Package: "foo", Stream: graph.MajorMinor{Major: 1, Minor: 0}, EndOfLife: graph.NewDate(2024, 8, 1)},
+		{Package: "foo", Stream: graph.MajorMinor{Major: 1, Minor: 1}, EndOfLife: graph.NewDate(2024, 12, 1)},
+		{Package: "bar", Stream: graph.MajorMinor{Major: 1, Minor: 0}, EndOfLife: graph.NewDate(2024, 10, 1)},
+		{Package: "bar", Stream: graph.MajorMinor{Major: 1, Minor: 1}, EndOfLife: graph.NewDate(2025, 6, 1)},
+	}
+	asOf := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+
+	ending := lifecycle.EndingBefore(streams, asOf, asOf.AddDate(0, 4, 0))
+	require.Len(t, ending, 2)
+	assert.Equal(t, "bar", ending[0].Package)
+	assert.Equal(t, "foo", ending[1].Package)
+}
@@ -0,0 +1,149 @@
+package lifecycle
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/pkg/graph"
+)
+
+// Provider looks up the lifecycle dates of the version streams of packages.
+type Provider interface {
+	// LifecycleDates returns the lifecycle dates of a version stream of a
+	// package. It reports false if it has no dates for the stream.
+	LifecycleDates(ctx context.Context, pkg string, stream graph.MajorMinor) (graph.LifecycleDates, bool, error)
+}
+
+// Templates provides the lifecycle dates of the version streams of templates.
+type Templates []graph.Template
+
+func (ts Templates) LifecycleDates(_ context.Context, pkg string, stream graph.MajorMinor) (graph.LifecycleDates, bool, error) {
+	for _, tmpl := range ts {
+		if tmpl.Name != pkg {
+			continue
+		}
+		for _, vs := range tmpl.VersionStreams {
+			if vs.Version == stream {
+				return vs.LifecycleDates, true, nil
+			}
+		}
+	}
+	return graph.LifecycleDates{}, false, nil
+}
+
+// Database provides the lifecycle dates of the version streams stored in the
+// database.
+type Database struct {
+	Query *query.Query
+}
+
+func (d Database) LifecycleDates(ctx context.Context, pkg string, stream graph.MajorMinor) (graph.LifecycleDates, bool, error) {
+	dates, err := d.Query.GetLifecycleDates(ctx, pkg, stream)
+	if errors.Is(err, sql.ErrNoRows) {
+		return graph.LifecycleDates{}, false, nil
+	}
+	if err != nil {
+		return graph.LifecycleDates{}, false, err
+	}
+	return dates, true, nil
+}
+
+// HTTP provides lifecycle dates from an HTTP endpoint, such as a product
+// lifecycle API. The endpoint responds to GET requests with the dates of a
+// stream as JSON, in the form of the lifecycleDates of a template:
+//
+//	{"fullSupport": "2024-01-01", "maintenance": "2024-06-01", "extensions": ["2025-01-01"], "eol": "2025-06-01"}
+//
+// A 404 Not Found response reports that the endpoint has no dates for the
+// stream.
+type HTTP struct {
+	// URL is the endpoint's URL, in which "{package}" and "{stream}" are
+	// replaced by the package name and the stream's <major>.<minor>.
+	URL string
+
+	// Client sends the requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+func (h HTTP) LifecycleDates(ctx context.Context, pkg string, stream graph.MajorMinor) (graph.LifecycleDates, bool, error) {
+	u := strings.NewReplacer(
+		"{package}", url.PathEscape(pkg),
+		"{stream}", url.PathEscape(stream.String()),
+	).Replace(h.URL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return graph.LifecycleDates{}, false, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return graph.LifecycleDates{}, false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return graph.LifecycleDates{}, false, nil
+	default:
+		return graph.LifecycleDates{}, false, fmt.Errorf("fetching %s: %s", u, resp.Status)
+	}
+	var dates graph.LifecycleDates
+	if err := json.NewDecoder(resp.Body).Decode(&dates); err != nil {
+		return graph.LifecycleDates{}, false, fmt.Errorf("error decoding lifecycle dates from %s: %w", u, err)
+	}
+	if err := dates.ValidateOrder(); err != nil {
+		return graph.LifecycleDates{}, false, fmt.Errorf("invalid lifecycle dates from %s: %w", u, err)
+	}
+	return dates, true, nil
+}
+
+// Providers provides the lifecycle dates of the first of its providers that
+// has dates for a stream, so that more current providers can be listed
+// before ones that may lag behind, such as templates.
+type Providers []Provider
+
+func (ps Providers) LifecycleDates(ctx context.Context, pkg string, stream graph.MajorMinor) (graph.LifecycleDates, bool, error) {
+	for _, p := range ps {
+		dates, ok, err := p.LifecycleDates(ctx, pkg, stream)
+		if err != nil || ok {
+			return dates, ok, err
+		}
+	}
+	return graph.LifecycleDates{}, false, nil
+}
+
+// Apply returns copies of the templates in which the lifecycle dates of each
+// version stream are replaced by those of the provider. Streams that the
+// provider has no dates for keep the dates of their template.
+func Apply(ctx context.Context, p Provider, templates []graph.Template) ([]graph.Template, error) {
+	applied := make([]graph.Template, 0, len(templates))
+	for _, tmpl := range templates {
+		streams := make([]graph.VersionStream, 0, len(tmpl.VersionStreams))
+		for _, vs := range tmpl.VersionStreams {
+			dates, ok, err := p.LifecycleDates(ctx, tmpl.Name, vs.Version)
+			if err != nil {
+				return nil, fmt.Errorf("error looking up lifecycle dates of %s %s: %w", tmpl.Name, vs.Version, err)
+			}
+			if ok {
+				vs.LifecycleDates = dates
+			}
+			streams = append(streams, vs)
+		}
+		tmpl.VersionStreams = streams
+		applied = append(applied, tmpl)
+	}
+	return applied, nil
+}
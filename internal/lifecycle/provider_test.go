@@ -0,0 +1,71 @@
+package lifecycle_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/joelanford/extensiondb/internal/lifecycle"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProviders(t *testing.T) {
+	v1_0 := graph.MajorMinor{Major: 1, Minor: 0}
+	v1_1 := graph.MajorMinor{Major: 1, Minor: 1}
+	templateDates := graph.LifecycleDates{
+		FullSupport: graph.NewDate(2024, 1, 1),
+		Maintenance: graph.NewDate(2024, 6, 1),
+		EndOfLife:   graph.NewDate(2025, 1, 1),
+	}
+	templates := []graph.Template{{Name: "foo", VersionStreams: []graph.VersionStream{
+		{Version: v1_0, LifecycleDates: templateDates},
+		{Version: v1_1, LifecycleDates: templateDates},
+	}}}
+
+	// The endpoint has newer dates for foo 1.0, which the template lags
+	// behind, and none for foo 1.1.
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case "/products/foo/1.0":
+		case "/broken/foo/1.0":
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"fullSupport": "2024-01-01", "maintenance": "2024-06-01", "extensions": ["2025-01-01"], "eol": "2025-06-01"}`)
+	}))
+	defer srv.Close()
+
+	p := lifecycle.Providers{
+		lifecycle.HTTP{URL: srv.URL + "/products/{package}/{stream}"},
+		lifecycle.Templates(templates),
+	}
+	applied, err := lifecycle.Apply(t.Context(), p, templates)
+	require.NoError(t, err)
+	require.Len(t, applied, 1)
+	assert.Equal(t, graph.LifecycleDates{
+		FullSupport: graph.NewDate(2024, 1, 1),
+		Maintenance: graph.NewDate(2024, 6, 1),
+		Extensions:  []graph.Date{graph.NewDate(2025, 1, 1)},
+		EndOfLife:   graph.NewDate(2025, 6, 1),
+	}, applied[0].VersionStreams[0].LifecycleDates)
+	assert.Equal(t, templateDates, applied[0].VersionStreams[1].LifecycleDates)
+	assert.Equal(t, []string{"/products/foo/1.0", "/products/foo/1.1"}, paths)
+
+	// The templates are not modified.
+	assert.Equal(t, templateDates, templates[0].VersionStreams[0].LifecycleDates)
+
+	_, ok, err := lifecycle.Templates(templates).LifecycleDates(t.Context(), "bar", v1_0)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = lifecycle.Apply(t.Context(), lifecycle.HTTP{URL: srv.URL + "/broken/{package}/{stream}"}, templates)
+	assert.ErrorContains(t, err, "500 Internal Server Error")
+}
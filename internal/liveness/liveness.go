@@ -0,0 +1,179 @@
+// Package liveness re-validates that the images of stored bundles still exist
+// in their registries, and that the tags they were ingested from still refer
+// to them, so that bundles whose content has disappeared or been retagged are
+// flagged.
+package liveness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/containers/image/v5/manifest"
+	"github.com/joelanford/extensiondb/internal/models"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/internal/registry"
+	"github.com/opencontainers/go-digest"
+	"go.podman.io/image/v5/docker/reference"
+	"golang.org/x/sync/errgroup"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	// StatusLive is recorded for bundles whose image exists in every
+	// repository it was referenced from, and whose tags still refer to it.
+	StatusLive = "live"
+
+	// StatusMissing is recorded for bundles whose image no longer exists in
+	// at least one repository it was referenced from.
+	StatusMissing = "missing"
+
+	// StatusRetagged is recorded for bundles whose image still exists, but
+	// that at least one tag it was referenced by no longer refers to.
+	StatusRetagged = "retagged"
+)
+
+// imageMediaTypes are the media types of bundles stored from container
+// registries. Bundles of other sources, such as Helm charts, are not checked.
+var imageMediaTypes = []string{
+	ocispec.MediaTypeImageManifest,
+	ocispec.MediaTypeImageIndex,
+	manifest.DockerV2Schema2MediaType,
+	manifest.DockerV2ListMediaType,
+}
+
+// Options configure a check.
+type Options struct {
+	// Sample is how many bundles to check, least recently checked first. If
+	// zero, every bundle is checked.
+	Sample int
+
+	// Concurrency is how many bundles to check at once. If zero, bundles
+	// are checked one at a time.
+	Concurrency int
+}
+
+// Result counts the bundles that were checked, by status.
+type Result struct {
+	Live     int
+	Missing  int
+	Retagged int
+}
+
+// Check checks a sample of bundles against their registries and records the
+// status of each. Bundles that can't be checked, such as because their
+// registry is unreachable, are not recorded, so that they are checked first
+// the next time; their errors are returned together.
+func Check(ctx context.Context, q *query.Query, opts Options) (Result, error) {
+	bundles, err := q.ListBundlesForLivenessCheck(ctx, imageMediaTypes, opts.Sample)
+	if err != nil {
+		return Result{}, fmt.Errorf("error listing bundles: %w", err)
+	}
+
+	var (
+		mu     sync.Mutex
+		result Result
+		errs   []error
+	)
+	var eg errgroup.Group
+	eg.SetLimit(max(opts.Concurrency, 1))
+	for _, b := range bundles {
+		eg.Go(func() error {
+			bl, err := checkBundle(ctx, q, b)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return nil
+			}
+			switch bl.Status {
+			case StatusLive:
+				result.Live++
+			case StatusMissing:
+				result.Missing++
+			case StatusRetagged:
+				result.Retagged++
+			}
+			return nil
+		})
+	}
+	_ = eg.Wait()
+	return result, errors.Join(errs...)
+}
+
+func checkBundle(ctx context.Context, q *query.Query, b *models.Bundle) (*models.BundleLiveness, error) {
+	dgst := b.Descriptor.V.Digest
+	refs, err := q.GetBundleReferencesForBundle(ctx, b)
+	if err != nil {
+		return nil, fmt.Errorf("error getting references of bundle %s: %w", dgst, err)
+	}
+	status, message, err := Status(ctx, dgst, refs)
+	if err != nil {
+		return nil, fmt.Errorf("error checking bundle %s: %w", dgst, err)
+	}
+	bl := &models.BundleLiveness{BundleID: b.ID, Status: status}
+	if message != "" {
+		bl.Message.String, bl.Message.Valid = message, true
+	}
+	if err := q.SetBundleLiveness(ctx, bl); err != nil {
+		return nil, fmt.Errorf("error recording liveness of bundle %s: %w", dgst, err)
+	}
+	return bl, nil
+}
+
+// Status checks that the image with digest dgst exists in the repository of
+// each of refs, and that each tagged reference still refers to it. It
+// returns the bundle's status and, unless it is live, a message describing
+// each problem.
+func Status(ctx context.Context, dgst digest.Digest, refs []*models.BundleReference) (string, string, error) {
+	var missing, retagged []string
+	checked := map[string]bool{}
+	for _, br := range refs {
+		named, err := reference.ParseNormalizedNamed(br.Repo)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid repository %q: %w", br.Repo, err)
+		}
+
+		if !checked[named.Name()] {
+			checked[named.Name()] = true
+			canonical, err := reference.WithDigest(named, dgst)
+			if err != nil {
+				return "", "", err
+			}
+			if err := registry.CheckDigest(ctx, canonical); errors.Is(err, registry.ErrNotFound) {
+				missing = append(missing, fmt.Sprintf("%s not found", canonical))
+			} else if err != nil {
+				return "", "", err
+			}
+		}
+
+		if !br.Tag.Valid {
+			continue
+		}
+		tagged, err := reference.WithTag(named, br.Tag.String)
+		if err != nil {
+			return "", "", err
+		}
+		resolved, err := registry.ResolveDigest(ctx, tagged)
+		switch {
+		case errors.Is(err, registry.ErrNotFound):
+			retagged = append(retagged, fmt.Sprintf("%s not found", tagged))
+		case err != nil:
+			return "", "", err
+		case resolved.Digest() != dgst:
+			retagged = append(retagged, fmt.Sprintf("%s refers to %s", tagged, resolved.Digest()))
+		}
+	}
+
+	switch {
+	case len(missing) > 0:
+		return StatusMissing, strings.Join(append(missing, retagged...), "; "), nil
+	case len(retagged) > 0:
+		return StatusRetagged, strings.Join(retagged, "; "), nil
+	default:
+		return StatusLive, "", nil
+	}
+}
@@ -0,0 +1,54 @@
+package liveness_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/joelanford/extensiondb/internal/ingest"
+	"github.com/joelanford/extensiondb/internal/liveness"
+	"github.com/joelanford/extensiondb/internal/models"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/internal/registry/registrytest"
+	"github.com/joelanford/extensiondb/pkg/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(dbtest.Main(m))
+}
+
+func TestCheck(t *testing.T) {
+	q := query.New(dbtest.New(t))
+	r := registrytest.New(t)
+	ref := r.PushBundle(t, "example/foo-bundle", "v1.0.0", registrytest.Bundle{Package: "foo", Version: "1.0.0"})
+
+	// The bundle is ingested from its tag.
+	tagged, err := reference.WithTag(r.Named("example/foo-bundle"), "v1.0.0")
+	require.NoError(t, err)
+	br, err := q.GetOrCreateBundleReference(t.Context(), tagged)
+	require.NoError(t, err)
+	_, err = ingest.Bundle(t.Context(), q, ingest.FBC{}, br, ref, ingest.Origin{SourceType: models.SourceTypeWebhook, SourceID: tagged.String()})
+	require.NoError(t, err)
+
+	result, err := liveness.Check(t.Context(), q, liveness.Options{})
+	require.NoError(t, err)
+	assert.Equal(t, liveness.Result{Live: 1}, result)
+
+	newer := r.PushBundle(t, "example/foo-bundle", "v1.0.0", registrytest.Bundle{Package: "foo", Version: "1.0.1"})
+	result, err = liveness.Check(t.Context(), q, liveness.Options{})
+	require.NoError(t, err)
+	assert.Equal(t, liveness.Result{Retagged: 1}, result)
+
+	r.Delete("example/foo-bundle", ref.Digest())
+	result, err = liveness.Check(t.Context(), q, liveness.Options{})
+	require.NoError(t, err)
+	assert.Equal(t, liveness.Result{Missing: 1}, result)
+
+	results, err := q.ListBundleLiveness(t.Context(), []string{liveness.StatusMissing})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, ref.String()+" not found; "+tagged.String()+" refers to "+newer.Digest().String(), results[0].Message.String)
+	assert.True(t, results[0].LastVerifiedAt.Valid, "the last time the bundle was live is kept")
+}
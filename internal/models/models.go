@@ -5,6 +5,7 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 )
@@ -15,10 +16,10 @@ type Catalog struct {
 	Name string
 	Tag  string
 
-	//JiraFeatureProject   sql.NullString
-	//JiraFeatureComponent sql.NullString
-	//JiraBugProject       sql.NullString
-	//JiraBugComponent     sql.NullString
+	JiraFeatureProject   sql.NullString
+	JiraFeatureComponent sql.NullString
+	JiraBugProject       sql.NullString
+	JiraBugComponent     sql.NullString
 	//LastAcknowledged     sql.NullTime
 	//LastAcknowledgedBy   sql.NullString
 
@@ -39,10 +40,10 @@ type Package struct {
 
 	Name string
 
-	//JiraFeatureProject   sql.NullString
-	//JiraFeatureComponent sql.NullString
-	//JiraBugProject       sql.NullString
-	//JiraBugComponent     sql.NullString
+	JiraFeatureProject   sql.NullString
+	JiraFeatureComponent sql.NullString
+	JiraBugProject       sql.NullString
+	JiraBugComponent     sql.NullString
 	//LastAcknowledged     sql.NullTime
 	//LastAcknowledgedBy   sql.NullString
 
@@ -61,6 +62,10 @@ type Bundle struct {
 	Version string
 	Release sql.NullString
 
+	// CSVDigest is the digest of the bundle's ClusterServiceVersion in the
+	// blob store, if it was stored.
+	CSVDigest sql.NullString
+
 	CreatedAt sql.NullTime
 }
 
@@ -74,6 +79,142 @@ type BundleReference struct {
 	CreatedAt sql.NullTime
 }
 
+type IngestionRun struct {
+	ID string
+
+	StartedAt  time.Time
+	FinishedAt sql.NullTime
+	Error      sql.NullString
+}
+
+// JiraIssue links a problem detected in the database to the Jira issue that
+// tracks it.
+type JiraIssue struct {
+	Problem  string
+	IssueKey string
+
+	CreatedAt time.Time
+}
+
+// BundleProperty is a property declared by a bundle's ClusterServiceVersion
+// in its olm.properties annotation.
+type BundleProperty struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// RelatedImage is an image that a bundle's ClusterServiceVersion lists in
+// spec.relatedImages.
+type RelatedImage struct {
+	Name  string
+	Image string
+
+	// Digest is the digest of Image, if it is a canonical reference.
+	Digest sql.NullString
+}
+
+// BundleBuild links a bundle to the build-system record of the build that
+// produced it.
+type BundleBuild struct {
+	BundleID string
+
+	System  string
+	BuildID string
+
+	SourceRepository sql.NullString
+	SourceCommit     sql.NullString
+	URL              sql.NullString
+
+	CreatedAt sql.NullTime
+}
+
+// BundlePyxisMetadata is the metadata of a bundle image in the Red Hat
+// Ecosystem Catalog (Pyxis).
+type BundlePyxisMetadata struct {
+	BundleID string
+
+	Certified    bool
+	Vendor       sql.NullString
+	SupportLevel sql.NullString
+	Repository   sql.NullString
+	PublishedAt  sql.NullTime
+
+	FetchedAt sql.NullTime
+}
+
+// BundleVerification is the result of verifying the signature and provenance
+// attestations of a bundle image.
+type BundleVerification struct {
+	BundleID string
+
+	Verified bool
+	Signer   sql.NullString
+	Message  sql.NullString
+
+	VerifiedAt sql.NullTime
+}
+
+// BundleLiveness is the result of checking that a bundle image still exists
+// in its registries and that its tags still refer to it.
+type BundleLiveness struct {
+	BundleID string
+
+	// Status is "live", "missing", or "retagged".
+	Status  string
+	Message sql.NullString
+
+	CheckedAt      sql.NullTime
+	LastVerifiedAt sql.NullTime
+}
+
+// The types of sources that bundles are ingested from, as recorded in their
+// provenance.
+const (
+	SourceTypeCatalog     = "catalog"
+	SourceTypeWebhook     = "webhook"
+	SourceTypeReadThrough = "read-through"
+	SourceTypeBackfill    = "backfill"
+)
+
+// BundleProvenance records the ingestion that created or last updated a
+// bundle.
+type BundleProvenance struct {
+	BundleID string
+
+	// Event is "created" or "updated".
+	Event string
+
+	// SourceType is one of the SourceType constants, and SourceID
+	// identifies the source, such as the catalog redhat-operator-index:v4.19
+	// or the pushed image.
+	SourceType string
+	SourceID   string
+
+	// IngestionRunID is the ingestion run that recorded the event, if it
+	// hasn't been deleted since.
+	IngestionRunID sql.NullString
+
+	RecordedAt sql.NullTime
+}
+
+// FetchFailure is a bundle image that ingestion failed to fetch, after
+// retrying transient errors.
+type FetchFailure struct {
+	Reference string
+	Error     string
+
+	// Attempts counts the fetches of the reference since it first failed.
+	Attempts int
+
+	// SourceType and SourceID are those of the ingestion that last failed
+	// to fetch the reference, as in BundleProvenance.
+	SourceType string
+	SourceID   string
+
+	FirstFailedAt time.Time
+	LastAttemptAt time.Time
+}
+
 // JSONB represents a PostgreSQL JSONB field
 type JSONB[T any] struct {
 	V *T
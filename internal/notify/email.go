@@ -0,0 +1,49 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// EmailNotifier sends each event as an email through an SMTP server.
+type EmailNotifier struct {
+	// Addr is the host:port of the SMTP server.
+	Addr string
+
+	// Auth authenticates with the SMTP server. If nil, no authentication is
+	// performed.
+	Auth smtp.Auth
+
+	From string
+	To   []string
+}
+
+// NewEmailNotifier creates an email notifier. If username is set, it
+// authenticates with the SMTP server using PLAIN authentication.
+func NewEmailNotifier(addr, username, password, from string, to []string) (*EmailNotifier, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP address %q: %w", addr, err)
+	}
+	n := &EmailNotifier{Addr: addr, From: from, To: to}
+	if username != "" {
+		n.Auth = smtp.PlainAuth("", username, password, host)
+	}
+	return n, nil
+}
+
+func (n EmailNotifier) Notify(_ context.Context, e Event) error {
+	msg := strings.Join([]string{
+		"From: " + n.From,
+		"To: " + strings.Join(n.To, ", "),
+		"Subject: " + subject(e),
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		e.Message() + ".",
+		"",
+	}, "\r\n")
+	return smtp.SendMail(n.Addr, n.Auth, n.From, n.To, []byte(msg))
+}
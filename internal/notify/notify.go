@@ -0,0 +1,197 @@
+// Package notify detects when version streams cross lifecycle boundaries and
+// sends notifications about them and about other events, such as stale
+// catalogs.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/joelanford/extensiondb/pkg/graph"
+)
+
+// EventKind identifies the lifecycle boundary that an event is about.
+type EventKind string
+
+const (
+	// EventKindPhase is sent when a version stream enters a new lifecycle phase.
+	EventKindPhase EventKind = "phase"
+
+	// EventKindApproachingEndOfLife is sent when a version stream's end of
+	// life is near.
+	EventKindApproachingEndOfLife EventKind = "approaching-eol"
+
+	// EventKindStaleDigest is sent when a catalog's digest has not changed
+	// for longer than expected.
+	EventKindStaleDigest EventKind = "stale-digest"
+
+	// EventKindStaleIngestion is sent when a catalog has not been ingested
+	// successfully for longer than expected.
+	EventKindStaleIngestion EventKind = "stale-ingestion"
+)
+
+// Event is a lifecycle boundary crossed by a version stream of a package, or
+// a problem with a catalog.
+type Event struct {
+	Package string
+	Stream  graph.MajorMinor
+	Kind    EventKind
+
+	// Catalog is the <name>:<tag> of the catalog that a stale catalog event
+	// is about. Package and Stream are unset for such events.
+	Catalog string
+
+	// Phase is the phase the stream entered. For EventKindApproachingEndOfLife,
+	// it is always graph.LifecyclePhaseEndOfLife.
+	Phase graph.LifecyclePhase
+
+	// Date is the date on which the stream enters Phase. For stale catalog
+	// events, it is when the catalog's digest last changed or when the
+	// catalog was last ingested, or the zero time if it never was.
+	Date time.Time
+}
+
+// Message returns a human-readable description of the event.
+func (e Event) Message() string {
+	date := e.Date.Format("2006-01-02")
+	switch e.Kind {
+	case EventKindStaleDigest:
+		return fmt.Sprintf("catalog %s has not changed since %s", e.Catalog, date)
+	case EventKindStaleIngestion:
+		if e.Date.IsZero() {
+			return fmt.Sprintf("catalog %s has never been ingested successfully", e.Catalog)
+		}
+		return fmt.Sprintf("catalog %s has not been ingested successfully since %s", e.Catalog, date)
+	case EventKindApproachingEndOfLife:
+		return fmt.Sprintf("%s %s reaches %s on %s", e.Package, e.Stream, e.Phase, date)
+	default:
+		return fmt.Sprintf("%s %s entered %s on %s", e.Package, e.Stream, e.Phase, date)
+	}
+}
+
+// Options configures which events are detected.
+type Options struct {
+	// EndOfLifeWarning is how long before a stream's end of life an
+	// EventKindApproachingEndOfLife event is detected. If zero, no such events
+	// are detected.
+	EndOfLifeWarning time.Duration
+
+	// MaxAge is how long after a boundary is crossed that the event is still
+	// detected. It bounds how far back the first run reaches, and gives later
+	// runs time to catch up after an outage.
+	MaxAge time.Duration
+}
+
+// Events returns the events for the version streams of the templates that
+// are detected as of now, ordered by date.
+func Events(templates []graph.Template, now time.Time, opts Options) []Event {
+	var events []Event
+	for _, tmpl := range templates {
+		for _, stream := range tmpl.VersionStreams {
+			dates := stream.LifecycleDates
+
+			type boundary struct {
+				date  graph.Date
+				phase graph.LifecyclePhase
+			}
+			boundaries := []boundary{{dates.Maintenance, graph.LifecyclePhaseMaintenance}}
+			for i, d := range dates.Extensions {
+				boundaries = append(boundaries, boundary{d, graph.LifecycleExtensionPhase(i + 1)})
+			}
+			boundaries = append(boundaries, boundary{dates.EndOfLife, graph.LifecyclePhaseEndOfLife})
+			for _, b := range boundaries {
+				t := b.date.Time()
+				if t.After(now) || now.Sub(t) >= opts.MaxAge {
+					continue
+				}
+				events = append(events, Event{
+					Package: tmpl.Name,
+					Stream:  stream.Version,
+					Kind:    EventKindPhase,
+					Phase:   b.phase,
+					Date:    t,
+				})
+			}
+
+			eol := dates.EndOfLife.Time()
+			if opts.EndOfLifeWarning > 0 && !now.Before(eol.Add(-opts.EndOfLifeWarning)) && now.Before(eol) {
+				events = append(events, Event{
+					Package: tmpl.Name,
+					Stream:  stream.Version,
+					Kind:    EventKindApproachingEndOfLife,
+					Phase:   graph.LifecyclePhaseEndOfLife,
+					Date:    eol,
+				})
+			}
+		}
+	}
+	slices.SortFunc(events, func(a, b Event) int {
+		if v := a.Date.Compare(b.Date); v != 0 {
+			return v
+		}
+		return strings.Compare(a.Message(), b.Message())
+	})
+	return events
+}
+
+// Notifier sends notifications about lifecycle events.
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+// Notifiers sends each event to every notifier.
+type Notifiers []Notifier
+
+func (ns Notifiers) Notify(ctx context.Context, e Event) error {
+	var errs []error
+	for _, n := range ns {
+		if err := n.Notify(ctx, e); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Store records which events have been sent, so that each event is sent once.
+// Stale catalog events are recorded with the catalog as the package and an
+// empty stream.
+type Store interface {
+	HasLifecycleNotification(ctx context.Context, pkg, stream, kind string, date time.Time) (bool, error)
+	RecordLifecycleNotification(ctx context.Context, pkg, stream, kind string, date time.Time) error
+}
+
+// Send sends every event that has not already been sent and records it in
+// the store. Events that fail to send are not recorded, so that they are
+// retried by the next call.
+func Send(ctx context.Context, store Store, n Notifier, events []Event) error {
+	var errs []error
+	for _, e := range events {
+		name, stream, kind := e.Package, e.Stream.String(), string(e.Kind)
+		if e.Catalog != "" {
+			name, stream = e.Catalog, ""
+		}
+		sent, err := store.HasLifecycleNotification(ctx, name, stream, kind, e.Date)
+		if err != nil {
+			return err
+		}
+		if sent {
+			continue
+		}
+		if err := n.Notify(ctx, e); err != nil {
+			errs = append(errs, fmt.Errorf("error sending notification %q: %w", e.Message(), err))
+			continue
+		}
+		if err := store.RecordLifecycleNotification(ctx, name, stream, kind, e.Date); err != nil {
+			return err
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func subject(e Event) string {
+	return fmt.Sprintf("[extensiondb] %s", e.Message())
+}
@@ -0,0 +1,114 @@
+package notify_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/notify"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var templates = []graph.Template{{
+	Name: "foo",
+	VersionStreams: []graph.VersionStream{
+		{
+			Version: graph.MajorMinor{Major: 1, Minor: 0},
+			LifecycleDates: graph.LifecycleDates{
+				FullSupport: graph.NewDate(2024, 1, 1),
+				Maintenance: graph.NewDate(2025, 1, 1),
+				EndOfLife:   graph.NewDate(2025, 2, 1),
+			},
+		},
+		{
+			Version: graph.MajorMinor{Major: 1, Minor: 1},
+			LifecycleDates: graph.LifecycleDates{
+				FullSupport: graph.NewDate(2024, 6, 1),
+				Maintenance: graph.NewDate(2025, 6, 1),
+				EndOfLife:   graph.NewDate(2026, 1, 1),
+			},
+		},
+	},
+}}
+
+func TestEvents(t *testing.T) {
+	opts := notify.Options{
+		EndOfLifeWarning: 30 * 24 * time.Hour,
+		MaxAge:           7 * 24 * time.Hour,
+	}
+
+	events := notify.Events(templates, time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC), opts)
+	assert.Equal(t, []notify.Event{
+		{Package: "foo", Stream: graph.MajorMinor{Major: 1, Minor: 0}, Kind: notify.EventKindPhase, Phase: graph.LifecyclePhaseMaintenance, Date: graph.NewDate(2025, 1, 1).Time()},
+		{Package: "foo", Stream: graph.MajorMinor{Major: 1, Minor: 0}, Kind: notify.EventKindApproachingEndOfLife, Phase: graph.LifecyclePhaseEndOfLife, Date: graph.NewDate(2025, 2, 1).Time()},
+	}, events)
+
+	// Boundaries crossed longer ago than the maximum age are ignored.
+	events = notify.Events(templates, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), opts)
+	assert.Empty(t, events)
+}
+
+func TestEventMessage(t *testing.T) {
+	e := notify.Event{Package: "foo", Stream: graph.MajorMinor{Major: 1, Minor: 0}, Kind: notify.EventKindApproachingEndOfLife, Phase: graph.LifecyclePhaseEndOfLife, Date: graph.NewDate(2025, 2, 1).Time()}
+	assert.Equal(t, "foo 1.0 reaches End of Life on 2025-02-01", e.Message())
+
+	e = notify.Event{Catalog: "catalog:v1", Kind: notify.EventKindStaleDigest, Date: graph.NewDate(2025, 2, 1).Time()}
+	assert.Equal(t, "catalog catalog:v1 has not changed since 2025-02-01", e.Message())
+
+	e = notify.Event{Catalog: "catalog:v1", Kind: notify.EventKindStaleIngestion}
+	assert.Equal(t, "catalog catalog:v1 has never been ingested successfully", e.Message())
+}
+
+type memoryStore map[string]bool
+
+func key(pkg, stream, kind string, date time.Time) string {
+	return fmt.Sprintf("%s/%s/%s/%s", pkg, stream, kind, date.Format(time.DateOnly))
+}
+
+func (s memoryStore) HasLifecycleNotification(_ context.Context, pkg, stream, kind string, date time.Time) (bool, error) {
+	return s[key(pkg, stream, kind, date)], nil
+}
+
+func (s memoryStore) RecordLifecycleNotification(_ context.Context, pkg, stream, kind string, date time.Time) error {
+	s[key(pkg, stream, kind, date)] = true
+	return nil
+}
+
+type notifierFunc func(context.Context, notify.Event) error
+
+func (f notifierFunc) Notify(ctx context.Context, e notify.Event) error {
+	return f(ctx, e)
+}
+
+func TestSend(t *testing.T) {
+	events := notify.Events(templates, time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC), notify.Options{
+		EndOfLifeWarning: 30 * 24 * time.Hour,
+		MaxAge:           7 * 24 * time.Hour,
+	})
+	require.Len(t, events, 2)
+
+	store := memoryStore{}
+	var sent []string
+	failing := true
+	n := notifierFunc(func(_ context.Context, e notify.Event) error {
+		if failing && e.Kind == notify.EventKindApproachingEndOfLife {
+			return errors.New("unavailable")
+		}
+		sent = append(sent, e.Message())
+		return nil
+	})
+
+	// Events that fail to send are retried, and events that were sent are not.
+	require.Error(t, notify.Send(context.Background(), store, n, events))
+	failing = false
+	require.NoError(t, notify.Send(context.Background(), store, n, events))
+	require.NoError(t, notify.Send(context.Background(), store, n, events))
+	assert.Equal(t, []string{
+		"foo 1.0 entered Maintenance on 2025-01-01",
+		"foo 1.0 reaches End of Life on 2025-02-01",
+	}, sent)
+}
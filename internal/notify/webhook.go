@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// WebhookNotifier posts each event as JSON to a URL.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+type webhookPayload struct {
+	Package string    `json:"package,omitempty"`
+	Stream  string    `json:"stream,omitempty"`
+	Catalog string    `json:"catalog,omitempty"`
+	Kind    EventKind `json:"kind"`
+	Phase   string    `json:"phase,omitempty"`
+	Date    string    `json:"date,omitempty"`
+	Message string    `json:"message"`
+}
+
+func (n WebhookNotifier) Notify(ctx context.Context, e Event) error {
+	p := webhookPayload{
+		Kind:    e.Kind,
+		Message: e.Message(),
+	}
+	if e.Catalog != "" {
+		p.Catalog = e.Catalog
+	} else {
+		p.Package, p.Stream, p.Phase = e.Package, e.Stream.String(), e.Phase.String()
+	}
+	if !e.Date.IsZero() {
+		p.Date = e.Date.Format(time.DateOnly)
+	}
+	return postJSON(ctx, n.Client, n.URL, p)
+}
+
+// SlackNotifier posts each event to a Slack incoming webhook.
+type SlackNotifier struct {
+	WebhookURL string
+	Client     *http.Client
+}
+
+func (n SlackNotifier) Notify(ctx context.Context, e Event) error {
+	return postJSON(ctx, n.Client, n.WebhookURL, map[string]string{"text": subject(e)})
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook responded with status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+// Package printer prints lists of items in the output formats of the CLI.
+package printer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Formats describes the supported output formats, for use in flag help.
+const Formats = "table, json, yaml, go-template=<template>, or go-template-file=<path>"
+
+// Column is a column of table output.
+type Column[T any] struct {
+	Header string
+	Value  func(T) string
+}
+
+// Print prints items to w in format. Table output has one row per item with
+// the given columns. Templates are executed once with the list of items, as
+// it would be printed in JSON, so fields are referenced by their JSON names.
+func Print[T any](w io.Writer, format string, items []T, columns []Column[T]) error {
+	if items == nil {
+		items = []T{}
+	}
+	switch {
+	case format == "table" || format == "":
+		return printTable(w, items, columns)
+	case format == "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	case format == "yaml":
+		data, err := yaml.Marshal(items)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	case strings.HasPrefix(format, "go-template="):
+		return printTemplate(w, strings.TrimPrefix(format, "go-template="), items)
+	case strings.HasPrefix(format, "go-template-file="):
+		path := strings.TrimPrefix(format, "go-template-file=")
+		text, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading template file: %w", err)
+		}
+		return printTemplate(w, string(text), items)
+	}
+	return fmt.Errorf("unknown output format %q; expected %s", format, Formats)
+}
+
+func printTable[T any](w io.Writer, items []T, columns []Column[T]) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	headers := make([]string, len(columns))
+	for i, c := range columns {
+		headers[i] = strings.ToUpper(c.Header)
+	}
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+
+	cells := make([]string, len(columns))
+	for _, item := range items {
+		for i, c := range columns {
+			cells[i] = c.Value(item)
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+func printTemplate(w io.Writer, text string, items any) error {
+	tmpl, err := template.New("output").Parse(text)
+	if err != nil {
+		return fmt.Errorf("error parsing template: %w", err)
+	}
+
+	// Execute the template with the JSON form of the items, so that it sees
+	// the same field names as JSON output.
+	data, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	var generic any
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return err
+	}
+	return tmpl.Execute(w, generic)
+}
@@ -0,0 +1,52 @@
+package printer_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/joelanford/extensiondb/internal/printer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type item struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+var (
+	items   = []item{{Name: "foo", Version: "1.0.0"}, {Name: "barbaz", Version: "2.1.0"}}
+	columns = []printer.Column[item]{
+		{Header: "name", Value: func(i item) string { return i.Name }},
+		{Header: "version", Value: func(i item) string { return i.Version }},
+	}
+)
+
+func TestPrint(t *testing.T) {
+	for _, tc := range []struct {
+		format string
+		want   string
+	}{
+		{format: "table", want: "NAME     VERSION\nfoo      1.0.0\nbarbaz   2.1.0\n"},
+		{format: "json", want: "[\n  {\n    \"name\": \"foo\",\n    \"version\": \"1.0.0\"\n  },\n  {\n    \"name\": \"barbaz\",\n    \"version\": \"2.1.0\"\n  }\n]\n"},
+		{format: "yaml", want: "- name: foo\n  version: 1.0.0\n- name: barbaz\n  version: 2.1.0\n"},
+		{format: `go-template={{range .}}{{.name}}@{{.version}}{{"\n"}}{{end}}`, want: "foo@1.0.0\nbarbaz@2.1.0\n"},
+	} {
+		t.Run(tc.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, printer.Print(&buf, tc.format, items, columns))
+			assert.Equal(t, tc.want, buf.String())
+		})
+	}
+}
+
+func TestPrintEmptyJSON(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, printer.Print[item](&buf, "json", nil, columns))
+	assert.Equal(t, "[]\n", buf.String())
+}
+
+func TestPrintUnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+	assert.ErrorContains(t, printer.Print(&buf, "xml", items, columns), `unknown output format "xml"`)
+}
@@ -0,0 +1,58 @@
+package publish
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/joelanford/extensiondb/internal/s3"
+)
+
+// Bucket stores objects by key.
+type Bucket interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+}
+
+// OpenBucket opens the bucket identified by a URL of the form
+// s3://<bucket>/<prefix>, gs://<bucket>/<prefix>, or file:///<dir>, and
+// returns it along with the key prefix from the URL's path.
+//
+// S3 buckets use the standard AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY,
+// AWS_SESSION_TOKEN, and AWS_REGION environment variables, and
+// AWS_ENDPOINT_URL to use an S3-compatible service. GCS buckets use the
+// access token in GOOGLE_OAUTH_ACCESS_TOKEN, or the token of the instance's
+// service account from the metadata server.
+func OpenBucket(rawURL string) (Bucket, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid bucket URL %q: %w", rawURL, err)
+	}
+	prefix := strings.Trim(u.Path, "/")
+	switch u.Scheme {
+	case "s3":
+		b, err := s3.NewBucketFromEnv(u.Host)
+		if err != nil {
+			return nil, "", err
+		}
+		return b, prefix, nil
+	case "gs":
+		return newGCSBucket(u.Host), prefix, nil
+	case "file":
+		return dirBucket(u.Path), "", nil
+	}
+	return nil, "", fmt.Errorf("unsupported bucket URL scheme %q; expected s3, gs, or file", u.Scheme)
+}
+
+// dirBucket stores objects as files in a local directory.
+type dirBucket string
+
+func (d dirBucket) Put(_ context.Context, key string, data []byte, _ string) error {
+	path := filepath.Join(string(d), filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
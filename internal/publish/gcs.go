@@ -0,0 +1,77 @@
+package publish
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// gcsBucket uploads objects with the Cloud Storage JSON API.
+type gcsBucket struct {
+	bucket     string
+	httpClient *http.Client
+}
+
+func newGCSBucket(bucket string) *gcsBucket {
+	return &gcsBucket{bucket: bucket, httpClient: http.DefaultClient}
+}
+
+func (b *gcsBucket) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	token, err := b.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	u := fmt.Sprintf("https://storage.googleapis.com/upload/storage/v1/b/%s/o?uploadType=media&name=%s", url.PathEscape(b.bucket), url.QueryEscape(key))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("gcs responded with status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// accessToken returns the token in GOOGLE_OAUTH_ACCESS_TOKEN, or else the
+// token of the instance's default service account.
+func (b *gcsBucket) accessToken(ctx context.Context) (string, error) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error getting access token from metadata server (set GOOGLE_OAUTH_ACCESS_TOKEN outside of Google Cloud): %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server responded with status %d", resp.StatusCode)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("error decoding access token: %w", err)
+	}
+	return token.AccessToken, nil
+}
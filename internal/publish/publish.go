@@ -0,0 +1,263 @@
+package publish
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/internal/fbc"
+	"github.com/joelanford/extensiondb/internal/graphdb"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+	"sigs.k8s.io/yaml"
+)
+
+// Plan describes an OpenShift update of a cluster with a set of installed
+// bundles, for which a plan report is published.
+type Plan struct {
+	Name         string             `json:"name"`
+	FromPlatform graph.MajorMinor   `json:"fromPlatform"`
+	ToPlatform   graph.MajorMinor   `json:"toPlatform"`
+	Installed    []InstalledVersion `json:"installed"`
+}
+
+// InstalledVersion is the version of a package installed on a cluster.
+type InstalledVersion struct {
+	Package string         `json:"package"`
+	Version semver.Version `json:"version"`
+}
+
+// ReadPlansFile reads a YAML or JSON list of plans.
+func ReadPlansFile(path string) ([]Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var plans []Plan
+	if err := yaml.Unmarshal(data, &plans); err != nil {
+		return nil, fmt.Errorf("error parsing plans file %q: %w", path, err)
+	}
+	for _, p := range plans {
+		if p.Name == "" {
+			return nil, fmt.Errorf("plans file %q: every plan must have a name", path)
+		}
+	}
+	return plans, nil
+}
+
+// Manifest lists the objects of a published snapshot. It is written to
+// <prefix>/<version>/manifest.json after the snapshot's other objects, and
+// then to <prefix>/latest.json, so consumers that read latest.json only see
+// complete snapshots.
+type Manifest struct {
+	Version     string    `json:"version"`
+	PublishedAt time.Time `json:"publishedAt"`
+
+	// IngestionRun is the ID of the latest ingestion run that had finished when
+	// the snapshot was taken.
+	IngestionRun string `json:"ingestionRun,omitempty"`
+
+	// Objects are the keys of the snapshot's objects, relative to
+	// <prefix>/<version>.
+	Objects []string `json:"objects"`
+}
+
+// Version returns the version of a snapshot taken at t. Versions sort in the
+// order the snapshots were taken.
+func Version(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// Publisher publishes snapshots of the database to a bucket, for consumers
+// that can't reach the database. Each snapshot contains:
+//
+//   - cincinnati/<package>/<channel>.json: the Cincinnati graph of each channel
+//     served by the Cincinnati endpoint, for each template.
+//   - fbc/<catalog>/<tag>.json: each catalog, exported as a declarative config
+//     with channels derived from the templates.
+//   - plans/<name>.txt: the report of each plan.
+type Publisher struct {
+	Bucket Bucket
+	Prefix string
+
+	Query     *query.Query
+	Builder   *graphdb.Builder
+	Templates []graph.Template
+
+	// Catalogs limits the exported catalogs to these <name>:<tag> references.
+	// When empty, every catalog is exported.
+	Catalogs []string
+
+	Plans []Plan
+}
+
+type object struct {
+	key         string
+	data        []byte
+	contentType string
+}
+
+// Publish publishes a snapshot taken at now and returns its manifest.
+func (p *Publisher) Publish(ctx context.Context, now time.Time) (*Manifest, error) {
+	m := &Manifest{Version: Version(now), PublishedAt: now.UTC(), Objects: []string{}}
+
+	run, err := p.Query.GetLatestIngestionRun(ctx)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return nil, fmt.Errorf("error getting latest ingestion run: %w", err)
+	default:
+		m.IngestionRun = run.ID
+	}
+
+	var objects []object
+	for _, tmpl := range p.Templates {
+		g, err := p.Builder.Build(ctx, []graph.Template{tmpl}, now)
+		if err != nil {
+			return nil, fmt.Errorf("error building graph for package %q: %w", tmpl.Name, err)
+		}
+		objs, err := cincinnatiObjects(g, tmpl)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, objs...)
+	}
+
+	objs, err := p.fbcObjects(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	objects = append(objects, objs...)
+
+	if len(p.Plans) > 0 {
+		g, err := p.Builder.Build(ctx, p.Templates, now)
+		if err != nil {
+			return nil, fmt.Errorf("error building graph for plans: %w", err)
+		}
+		objs, err := planObjects(ctx, g, p.Plans)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, objs...)
+	}
+
+	for _, obj := range objects {
+		if err := p.Bucket.Put(ctx, p.key(m.Version, obj.key), obj.data, obj.contentType); err != nil {
+			return nil, fmt.Errorf("error publishing %s: %w", obj.key, err)
+		}
+		m.Objects = append(m.Objects, obj.key)
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.Bucket.Put(ctx, p.key(m.Version, "manifest.json"), data, "application/json"); err != nil {
+		return nil, fmt.Errorf("error publishing manifest: %w", err)
+	}
+	if err := p.Bucket.Put(ctx, p.key("latest.json"), data, "application/json"); err != nil {
+		return nil, fmt.Errorf("error publishing latest manifest: %w", err)
+	}
+	return m, nil
+}
+
+func (p *Publisher) key(elem ...string) string {
+	return path.Join(append([]string{p.Prefix}, elem...)...)
+}
+
+func (p *Publisher) fbcObjects(ctx context.Context, now time.Time) ([]object, error) {
+	catalogs, err := p.Query.ListCatalogs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing catalogs: %w", err)
+	}
+	channels := fbc.TemplateChannels(ctx, p.Builder, p.Templates, now)
+
+	var objects []object
+	for _, c := range catalogs {
+		if len(p.Catalogs) > 0 && !slices.Contains(p.Catalogs, c.Name+":"+c.Tag) {
+			continue
+		}
+		cfg, err := fbc.Render(ctx, p.Query, c, fbc.Options{Channels: channels})
+		if err != nil {
+			return nil, fmt.Errorf("error rendering catalog %s:%s: %w", c.Name, c.Tag, err)
+		}
+		var buf bytes.Buffer
+		if err := declcfg.WriteJSON(*cfg, &buf); err != nil {
+			return nil, err
+		}
+		objects = append(objects, object{
+			key:         path.Join("fbc", c.Name, c.Tag+".json"),
+			data:        buf.Bytes(),
+			contentType: "application/json",
+		})
+	}
+	return objects, nil
+}
+
+// cincinnatiObjects returns the Cincinnati graph of the "stable" channel and
+// of the "stable-<major>.<minor>" channel of each version stream of tmpl.
+func cincinnatiObjects(g *graph.Graph, tmpl graph.Template) ([]object, error) {
+	channels := map[string]graph.NodePredicate{"stable": graph.AllNodes()}
+	names := []string{"stable"}
+	for _, vs := range tmpl.VersionStreams {
+		name := fmt.Sprintf("stable-%s", vs.Version)
+		channels[name] = graph.NodesUpToMajorMinor(vs.Version)
+		names = append(names, name)
+	}
+
+	objects := make([]object, 0, len(names))
+	for _, name := range names {
+		data, err := json.Marshal(g.Cincinnati(graph.AndNodes(graph.PackageNodes(tmpl.Name), channels[name]), name))
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, object{
+			key:         path.Join("cincinnati", tmpl.Name, name+".json"),
+			data:        data,
+			contentType: "application/json",
+		})
+	}
+	return objects, nil
+}
+
+// Update plans the plan's OpenShift update in g.
+func (p Plan) Update(ctx context.Context, g *graph.Graph, opts ...graph.PlanOption) (*graph.PlatformUpdate, error) {
+	var froms []*graph.Node
+	for _, iv := range p.Installed {
+		n := g.FirstNodeMatching(graph.AndNodes(graph.PackageNodes(iv.Package), graph.NodeInRange(iv.Version.EQ)))
+		if n == nil {
+			return nil, fmt.Errorf("plan %q: %s version %s not found", p.Name, iv.Package, iv.Version)
+		}
+		froms = append(froms, n)
+	}
+	pu, err := g.PlanOpenShiftUpdate(ctx, froms, p.FromPlatform, p.ToPlatform, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("plan %q: %w", p.Name, err)
+	}
+	return pu, nil
+}
+
+func planObjects(ctx context.Context, g *graph.Graph, plans []Plan) ([]object, error) {
+	objects := make([]object, 0, len(plans))
+	for _, plan := range plans {
+		pu, err := plan.Update(ctx, g)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, object{
+			key:         path.Join("plans", plan.Name+".txt"),
+			data:        []byte(pu.PrettyReport()),
+			contentType: "text/plain; charset=utf-8",
+		})
+	}
+	return objects, nil
+}
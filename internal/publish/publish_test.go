@@ -0,0 +1,79 @@
+package publish
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/joelanford/extensiondb/pkg/graph/graphtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testGraph(t *testing.T) (*graph.Graph, graph.Template) {
+	tmpl := graph.Template{
+		Name:           "foo",
+		VersionStreams: graphtest.Streams(graph.MajorMinor{Major: 1, Minor: 0}, graph.MajorMinor{Major: 1, Minor: 1}),
+	}
+	return graphtest.New(t, graph.Package{Name: "foo", Streams: tmpl.VersionStreams, Nodes: graphtest.Nodes("foo", "1.0.0", "1.1.0")}), tmpl
+}
+
+func TestCincinnatiObjects(t *testing.T) {
+	g, tmpl := testGraph(t)
+
+	objects, err := cincinnatiObjects(g, tmpl)
+	require.NoError(t, err)
+
+	versions := map[string][]string{}
+	for _, obj := range objects {
+		assert.Equal(t, "application/json", obj.contentType)
+		var cg graph.CincinnatiGraph
+		require.NoError(t, json.Unmarshal(obj.data, &cg))
+		for _, n := range cg.Nodes {
+			versions[obj.key] = append(versions[obj.key], n.Version)
+		}
+	}
+	assert.Equal(t, map[string][]string{
+		"cincinnati/foo/stable.json":     {"1.0.0", "1.1.0"},
+		"cincinnati/foo/stable-1.0.json": {"1.0.0"},
+		"cincinnati/foo/stable-1.1.json": {"1.0.0", "1.1.0"},
+	}, versions)
+}
+
+func TestPlanObjects(t *testing.T) {
+	g, _ := testGraph(t)
+	plan := Plan{
+		Name:         "eus-4.12",
+		FromPlatform: graph.MajorMinor{Major: 4, Minor: 12},
+		ToPlatform:   graph.MajorMinor{Major: 4, Minor: 14},
+		Installed:    []InstalledVersion{{Package: "foo", Version: semver.MustParse("1.0.0")}},
+	}
+
+	objects, err := planObjects(t.Context(), g, []Plan{plan})
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	assert.Equal(t, "plans/eus-4.12.txt", objects[0].key)
+	assert.Contains(t, string(objects[0].data), "foo.v1.0.0")
+
+	plan.Installed[0].Version = semver.MustParse("2.0.0")
+	_, err = planObjects(t.Context(), g, []Plan{plan})
+	assert.ErrorContains(t, err, "foo version 2.0.0 not found")
+}
+
+func TestOpenBucketFile(t *testing.T) {
+	dir := t.TempDir()
+	b, prefix, err := OpenBucket("file://" + dir)
+	require.NoError(t, err)
+	assert.Empty(t, prefix)
+
+	require.NoError(t, b.Put(t.Context(), "a/b.json", []byte("{}"), "application/json"))
+	data, err := os.ReadFile(filepath.Join(dir, "a", "b.json"))
+	require.NoError(t, err)
+	assert.Equal(t, "{}", string(data))
+
+	_, _, err = OpenBucket("ftp://example.com/snapshots")
+	assert.ErrorContains(t, err, "unsupported bucket URL scheme")
+}
@@ -0,0 +1,195 @@
+// Package pyxis enriches bundles with their vendor, support level,
+// certification status, and publish date from the Red Hat Ecosystem Catalog,
+// which is served by the Pyxis API.
+package pyxis
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/models"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/opencontainers/go-digest"
+)
+
+// DefaultURL is the base URL of the public Pyxis API.
+const DefaultURL = "https://catalog.redhat.com/api/containers"
+
+// ErrNotFound is returned by Client.GetImage when the catalog has no image
+// with the given digest.
+var ErrNotFound = errors.New("image not found in the Red Hat Ecosystem Catalog")
+
+// Image is the catalog metadata of a bundle image.
+type Image struct {
+	Certified bool
+
+	// Vendor and SupportLevel are read from the image's repository.
+	// SupportLevel is the repository's first release category, such as
+	// "Generally Available" or "Tech Preview".
+	Vendor       string
+	SupportLevel string
+
+	// Repository is the <registry>/<repository> in which the image is
+	// published, and PublishedAt is when it was pushed there. Both are empty
+	// if the image is not published.
+	Repository  string
+	PublishedAt time.Time
+}
+
+// Client reads image metadata from the Pyxis API.
+type Client struct {
+	// URL is the base URL of the API. If empty, DefaultURL is used.
+	URL string
+
+	// HTTP is the client used for requests. If nil, http.DefaultClient is
+	// used.
+	HTTP *http.Client
+}
+
+type imageResponse struct {
+	Data []struct {
+		Certified    bool `json:"certified"`
+		Repositories []struct {
+			Registry   string    `json:"registry"`
+			Repository string    `json:"repository"`
+			Published  bool      `json:"published"`
+			PushDate   time.Time `json:"push_date"`
+		} `json:"repositories"`
+		ParsedData struct {
+			Labels []struct {
+				Name  string `json:"name"`
+				Value string `json:"value"`
+			} `json:"labels"`
+		} `json:"parsed_data"`
+	} `json:"data"`
+}
+
+type repositoryResponse struct {
+	VendorLabel       string   `json:"vendor_label"`
+	ReleaseCategories []string `json:"release_categories"`
+}
+
+// GetImage returns the catalog metadata of the image whose manifest or
+// manifest list has the digest dgst.
+func (c *Client) GetImage(ctx context.Context, dgst digest.Digest) (*Image, error) {
+	filter := fmt.Sprintf("docker_image_digest==%[1]s,repositories.manifest_list_digest==%[1]s", dgst)
+	var ir imageResponse
+	if err := c.get(ctx, "/v1/images?"+url.Values{"filter": {filter}, "page_size": {"1"}}.Encode(), &ir); err != nil {
+		return nil, err
+	}
+	if len(ir.Data) == 0 {
+		return nil, ErrNotFound
+	}
+	data := ir.Data[0]
+
+	img := &Image{Certified: data.Certified}
+	for _, l := range data.ParsedData.Labels {
+		if l.Name == "vendor" {
+			img.Vendor = l.Value
+		}
+	}
+	for _, r := range data.Repositories {
+		if !r.Published {
+			continue
+		}
+		img.Repository = r.Registry + "/" + r.Repository
+		img.PublishedAt = r.PushDate
+
+		var rr repositoryResponse
+		if err := c.get(ctx, "/v1/repositories/registry/"+url.PathEscape(r.Registry)+"/repository/"+r.Repository, &rr); err != nil {
+			return nil, fmt.Errorf("error getting repository %s: %w", img.Repository, err)
+		}
+		if rr.VendorLabel != "" {
+			img.Vendor = rr.VendorLabel
+		}
+		if len(rr.ReleaseCategories) > 0 {
+			img.SupportLevel = rr.ReleaseCategories[0]
+		}
+		break
+	}
+	return img, nil
+}
+
+func (c *Client) get(ctx context.Context, path string, v any) error {
+	base := c.URL
+	if base == "" {
+		base = DefaultURL
+	}
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(base, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", req.URL, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// Plugin is an ingest plugin that stores the catalog metadata of bundles.
+// Bundles that are not in the catalog are stored as not certified.
+type Plugin struct {
+	Client *Client
+
+	// RefreshAfter is how long stored metadata is used before it is fetched
+	// again. If zero, metadata is fetched every time a bundle is ingested.
+	RefreshAfter time.Duration
+}
+
+// Name implements ingest.Plugin.
+func (Plugin) Name() string {
+	return "pyxis"
+}
+
+// IngestBundle implements ingest.Plugin.
+func (p Plugin) IngestBundle(ctx context.Context, q *query.Query, b *models.Bundle) error {
+	if b.Descriptor.V == nil {
+		return nil
+	}
+	dgst := b.Descriptor.V.Digest
+	if p.RefreshAfter > 0 {
+		pm, err := q.GetBundlePyxisMetadataByDigest(ctx, dgst)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if err == nil && pm.FetchedAt.Valid && time.Since(pm.FetchedAt.Time) < p.RefreshAfter {
+			return nil
+		}
+	}
+
+	img, err := p.Client.GetImage(ctx, dgst)
+	if errors.Is(err, ErrNotFound) {
+		img, err = &Image{}, nil
+	}
+	if err != nil {
+		return err
+	}
+	return q.SetBundlePyxisMetadata(ctx, &models.BundlePyxisMetadata{
+		BundleID:     b.ID,
+		Certified:    img.Certified,
+		Vendor:       nullString(img.Vendor),
+		SupportLevel: nullString(img.SupportLevel),
+		Repository:   nullString(img.Repository),
+		PublishedAt:  sql.NullTime{Time: img.PublishedAt, Valid: !img.PublishedAt.IsZero()},
+	})
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
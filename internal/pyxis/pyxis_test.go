@@ -0,0 +1,106 @@
+package pyxis_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/pyxis"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/internal/query/querytest"
+	"github.com/joelanford/extensiondb/pkg/dbtest"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(dbtest.Main(m))
+}
+
+// newClient returns a client of a fake Pyxis API that knows the image with
+// digest certified, and counts the image lookups in lookups.
+func newClient(t *testing.T, certified digest.Digest, lookups *atomic.Int32) *pyxis.Client {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/images", func(w http.ResponseWriter, r *http.Request) {
+		lookups.Add(1)
+		want := fmt.Sprintf("docker_image_digest==%[1]s,repositories.manifest_list_digest==%[1]s", certified)
+		if r.URL.Query().Get("filter") != want {
+			_, _ = w.Write([]byte(`{"data": [], "total": 0}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data": [{
+			"certified": true,
+			"repositories": [
+				{"registry": "registry.example.com", "repository": "foo/foo-bundle", "published": false, "push_date": "2023-12-01T00:00:00+00:00"},
+				{"registry": "registry.connect.example.com", "repository": "foo/foo-bundle", "published": true, "push_date": "2024-01-01T00:00:00+00:00"}
+			],
+			"parsed_data": {"labels": [{"name": "vendor", "value": "Foo Labs"}]}
+		}], "total": 1}`))
+	})
+	mux.HandleFunc("GET /v1/repositories/registry/registry.connect.example.com/repository/foo/foo-bundle", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"vendor_label": "Foo, Inc.", "release_categories": ["Generally Available"]}`))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return &pyxis.Client{URL: srv.URL, HTTP: srv.Client()}
+}
+
+func TestGetImage(t *testing.T) {
+	dgst := digest.FromString("foo")
+	var lookups atomic.Int32
+	c := newClient(t, dgst, &lookups)
+
+	img, err := c.GetImage(t.Context(), dgst)
+	require.NoError(t, err)
+	assert.True(t, img.Certified)
+	assert.Equal(t, "Foo, Inc.", img.Vendor, "the repository's vendor takes precedence over the label")
+	assert.Equal(t, "Generally Available", img.SupportLevel)
+	assert.Equal(t, "registry.connect.example.com/foo/foo-bundle", img.Repository, "unpublished repositories are skipped")
+	assert.True(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Equal(img.PublishedAt))
+
+	_, err = c.GetImage(t.Context(), digest.FromString("other"))
+	assert.ErrorIs(t, err, pyxis.ErrNotFound)
+}
+
+func TestPlugin(t *testing.T) {
+	db := dbtest.New(t)
+	q := query.New(db)
+	certified := querytest.Bundle(t, db, "foo", "1.0.0", time.Now())
+	uncertified := querytest.Bundle(t, db, "foo", "1.0.1", time.Now())
+
+	var lookups atomic.Int32
+	p := pyxis.Plugin{
+		Client:       newClient(t, certified.Descriptor.V.Digest, &lookups),
+		RefreshAfter: time.Hour,
+	}
+	require.NoError(t, p.IngestBundle(t.Context(), q, certified))
+	require.NoError(t, p.IngestBundle(t.Context(), q, uncertified))
+
+	pm, err := q.GetBundlePyxisMetadataByDigest(t.Context(), certified.Descriptor.V.Digest)
+	require.NoError(t, err)
+	assert.Equal(t, certified.ID, pm.BundleID)
+	assert.True(t, pm.Certified)
+	assert.Equal(t, "Foo, Inc.", pm.Vendor.String)
+	assert.Equal(t, "Generally Available", pm.SupportLevel.String)
+	assert.True(t, pm.PublishedAt.Valid)
+
+	pm, err = q.GetBundlePyxisMetadataByDigest(t.Context(), uncertified.Descriptor.V.Digest)
+	require.NoError(t, err)
+	assert.False(t, pm.Certified, "bundles that are not in the catalog are recorded as not certified")
+	assert.False(t, pm.Vendor.Valid)
+
+	require.NoError(t, p.IngestBundle(t.Context(), q, certified))
+	assert.Equal(t, int32(2), lookups.Load(), "recently fetched metadata is not fetched again")
+
+	pkg, err := q.GetPackageByName(t.Context(), "foo")
+	require.NoError(t, err)
+	bundles, err := q.ListCertifiedBundlesForPackage(t.Context(), pkg)
+	require.NoError(t, err)
+	require.Len(t, bundles, 1)
+	assert.Equal(t, certified.ID, bundles[0].ID)
+}
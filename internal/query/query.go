@@ -1,24 +1,42 @@
 package query
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"time"
 
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/internal/blobstore"
 	"github.com/joelanford/extensiondb/internal/models"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/lib/pq"
 	"github.com/opencontainers/go-digest"
 	"go.podman.io/image/v5/docker/reference"
 )
 
 // Query provides database operations
 type Query struct {
-	db *sql.DB
+	db    *sql.DB
+	blobs blobstore.Store
 }
 
-// New creates a new repository
+// New creates a new repository. Blobs, such as the ClusterServiceVersions of
+// bundles, are stored as large objects in db, unless WithBlobStore configures
+// another store.
 func New(db *sql.DB) *Query {
-	return &Query{db: db}
+	return &Query{db: db, blobs: blobstore.NewLargeObjects(db)}
+}
+
+// WithBlobStore returns a copy of q that stores blobs in s. Blobs stored
+// earlier must have been stored in s too for q to read them.
+func (q Query) WithBlobStore(s blobstore.Store) *Query {
+	q.blobs = s
+	return &q
 }
 
 func (q Query) GetOrCreateCatalog(ctx context.Context, name, tag string) (*models.Catalog, error) {
@@ -27,7 +45,7 @@ func (q Query) GetOrCreateCatalog(ctx context.Context, name, tag string) (*model
 		return nil, fmt.Errorf("error starting transaction: %w", err)
 	}
 
-	row := tx.QueryRowContext(ctx, `INSERT INTO catalogs ("name", "tag") VALUES ($1, $2) ON CONFLICT ("name", "tag") DO NOTHING RETURNING *;`, name, tag)
+	row := tx.QueryRowContext(ctx, `INSERT INTO catalogs ("name", "tag") VALUES ($1, $2) ON CONFLICT ("name", "tag") DO NOTHING RETURNING `+catalogColumns, name, tag)
 
 	catalog, err := catalogFromRow(row)
 	if err == nil {
@@ -42,12 +60,14 @@ func (q Query) GetOrCreateCatalog(ctx context.Context, name, tag string) (*model
 		return nil, fmt.Errorf("error committing query: %w", err)
 	}
 
-	return catalogFromRow(q.db.QueryRowContext(ctx, `SELECT * FROM catalogs WHERE name = $1 AND tag = $2`, name, tag))
+	return catalogFromRow(q.db.QueryRowContext(ctx, `SELECT `+catalogColumns+` FROM catalogs WHERE name = $1 AND tag = $2`, name, tag))
 }
 
+const catalogColumns = `id, name, tag, jira_feature_project, jira_feature_component, jira_bug_project, jira_bug_component, created_at`
+
 func catalogFromRow(row *sql.Row) (*models.Catalog, error) {
 	var catalog models.Catalog
-	if err := row.Scan(&catalog.ID, &catalog.Name, &catalog.Tag, &catalog.CreatedAt); err != nil {
+	if err := row.Scan(&catalog.ID, &catalog.Name, &catalog.Tag, &catalog.JiraFeatureProject, &catalog.JiraFeatureComponent, &catalog.JiraBugProject, &catalog.JiraBugComponent, &catalog.CreatedAt); err != nil {
 		return nil, err
 	}
 	return &catalog, nil
@@ -91,7 +111,7 @@ func (q Query) GetOrCreatePackage(ctx context.Context, name string) (*models.Pac
 		return nil, fmt.Errorf("error starting transaction: %w", err)
 	}
 
-	row := tx.QueryRowContext(ctx, `INSERT INTO packages ("name") VALUES ($1) ON CONFLICT ("name") DO NOTHING RETURNING *;`, name)
+	row := tx.QueryRowContext(ctx, `INSERT INTO packages ("name") VALUES ($1) ON CONFLICT ("name") DO NOTHING RETURNING `+packageColumns, name)
 
 	pkg, err := packageFromRow(row)
 	if err == nil {
@@ -106,12 +126,14 @@ func (q Query) GetOrCreatePackage(ctx context.Context, name string) (*models.Pac
 		return nil, fmt.Errorf("error committing query: %w", err)
 	}
 
-	return packageFromRow(q.db.QueryRowContext(ctx, `SELECT * FROM packages WHERE name = $1`, name))
+	return packageFromRow(q.db.QueryRowContext(ctx, `SELECT `+packageColumns+` FROM packages WHERE name = $1`, name))
 }
 
+const packageColumns = `id, name, jira_feature_project, jira_feature_component, jira_bug_project, jira_bug_component, created_at`
+
 func packageFromRow(row *sql.Row) (*models.Package, error) {
 	var pkg models.Package
-	if err := row.Scan(&pkg.ID, &pkg.Name, &pkg.CreatedAt); err != nil {
+	if err := row.Scan(&pkg.ID, &pkg.Name, &pkg.JiraFeatureProject, &pkg.JiraFeatureComponent, &pkg.JiraBugProject, &pkg.JiraBugComponent, &pkg.CreatedAt); err != nil {
 		return nil, err
 	}
 	return &pkg, nil
@@ -181,6 +203,169 @@ func (q Query) GetOrCreateCanonicalBundleReference(ctx context.Context, ref refe
 	return bundleReferenceFromRow(q.db.QueryRowContext(ctx, `SELECT * FROM bundle_references WHERE repo = $1 and digest = $2`, ref.Name(), ref.Digest().String()))
 }
 
+// GetOrCreatePackages returns the packages with names, keyed by name,
+// creating those that don't exist. Unlike GetOrCreatePackage, it resolves
+// every name in a single statement.
+func (q Query) GetOrCreatePackages(ctx context.Context, names []string) (map[string]*models.Package, error) {
+	pkgs := make(map[string]*models.Package, len(names))
+	if len(names) == 0 {
+		return pkgs, nil
+	}
+
+	// Rows are inserted in name order, so that concurrent batches lock them
+	// in the same order.
+	rows, err := q.db.QueryContext(ctx, `
+    WITH input AS (
+        SELECT DISTINCT unnest($1::text[]) AS name
+    ), inserted AS (
+        INSERT INTO packages ("name")
+        SELECT name FROM input ORDER BY name
+        ON CONFLICT ("name") DO NOTHING
+        RETURNING `+packageColumns+`
+    )
+    SELECT `+packageColumns+` FROM inserted
+    UNION ALL
+    SELECT `+packageColumns+` FROM packages WHERE name IN (SELECT name FROM input);`, pq.Array(names))
+	if err != nil {
+		return nil, fmt.Errorf("error inserting packages: %w", err)
+	}
+	if err := collectPackages(rows, pkgs); err != nil {
+		return nil, fmt.Errorf("error inserting packages: %w", err)
+	}
+
+	// Packages that concurrent transactions created after the statement
+	// began are neither inserted nor seen by it.
+	var missing []string
+	for _, name := range names {
+		if pkgs[name] == nil {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return pkgs, nil
+	}
+	rows, err = q.db.QueryContext(ctx, `SELECT `+packageColumns+` FROM packages WHERE name = ANY($1)`, pq.Array(missing))
+	if err != nil {
+		return nil, fmt.Errorf("error getting packages: %w", err)
+	}
+	if err := collectPackages(rows, pkgs); err != nil {
+		return nil, fmt.Errorf("error getting packages: %w", err)
+	}
+	for _, name := range missing {
+		if pkgs[name] == nil {
+			return nil, fmt.Errorf("package %q was neither created nor found", name)
+		}
+	}
+	return pkgs, nil
+}
+
+func collectPackages(rows *sql.Rows, pkgs map[string]*models.Package) error {
+	ps, err := collectRows(rows, scanPackage)
+	if err != nil {
+		return err
+	}
+	for _, p := range ps {
+		pkgs[p.Name] = p
+	}
+	return nil
+}
+
+// GetOrCreateBundleReferences returns the bundle references of refs, in the
+// order of refs, creating those that don't exist. Unlike
+// GetOrCreateCanonicalBundleReference, it resolves every reference in a
+// single statement.
+func (q Query) GetOrCreateBundleReferences(ctx context.Context, refs []reference.Canonical) ([]*models.BundleReference, error) {
+	if len(refs) == 0 {
+		return nil, nil
+	}
+	repos := make([]string, 0, len(refs))
+	digests := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		repos = append(repos, ref.Name())
+		digests = append(digests, ref.Digest().String())
+	}
+	key := func(repo, digest string) string { return repo + "@" + digest }
+
+	brs := make(map[string]*models.BundleReference, len(refs))
+	collect := func(rows *sql.Rows) error {
+		found, err := collectRows(rows, scanBundleReference)
+		if err != nil {
+			return err
+		}
+		for _, br := range found {
+			brs[key(br.Repo, br.Digest.String)] = br
+		}
+		return nil
+	}
+
+	// Rows are inserted in (repo, digest) order, so that concurrent batches
+	// lock them in the same order.
+	rows, err := q.db.QueryContext(ctx, `
+    WITH input AS (
+        SELECT DISTINCT repo, digest FROM unnest($1::text[], $2::text[]) AS input(repo, digest)
+    ), inserted AS (
+        INSERT INTO bundle_references (repo, tag, digest)
+        SELECT repo, NULL, digest FROM input ORDER BY repo, digest
+        ON CONFLICT (repo, tag, digest) DO NOTHING
+        RETURNING id, repo, tag, digest
+    )
+    SELECT id, repo, tag, digest FROM inserted
+    UNION ALL
+    SELECT br.id, br.repo, br.tag, br.digest
+    FROM bundle_references AS br
+    JOIN input
+        ON br.repo = input.repo AND br.digest = input.digest
+    WHERE br.tag IS NULL;`, pq.Array(repos), pq.Array(digests))
+	if err != nil {
+		return nil, fmt.Errorf("error inserting bundle references: %w", err)
+	}
+	if err := collect(rows); err != nil {
+		return nil, fmt.Errorf("error inserting bundle references: %w", err)
+	}
+
+	// Bundle references that concurrent transactions created after the
+	// statement began are neither inserted nor seen by it.
+	var missingRepos, missingDigests []string
+	for i := range refs {
+		if brs[key(repos[i], digests[i])] == nil {
+			missingRepos = append(missingRepos, repos[i])
+			missingDigests = append(missingDigests, digests[i])
+		}
+	}
+	if len(missingRepos) > 0 {
+		rows, err := q.db.QueryContext(ctx, `
+    SELECT br.id, br.repo, br.tag, br.digest
+    FROM bundle_references AS br
+    JOIN unnest($1::text[], $2::text[]) AS input(repo, digest)
+        ON br.repo = input.repo AND br.digest = input.digest
+    WHERE br.tag IS NULL;`, pq.Array(missingRepos), pq.Array(missingDigests))
+		if err != nil {
+			return nil, fmt.Errorf("error getting bundle references: %w", err)
+		}
+		if err := collect(rows); err != nil {
+			return nil, fmt.Errorf("error getting bundle references: %w", err)
+		}
+	}
+
+	result := make([]*models.BundleReference, 0, len(refs))
+	for i, ref := range refs {
+		br := brs[key(repos[i], digests[i])]
+		if br == nil {
+			return nil, fmt.Errorf("bundle reference %s was neither created nor found", ref)
+		}
+		result = append(result, br)
+	}
+	return result, nil
+}
+
+func scanBundleReference(rows *sql.Rows) (*models.BundleReference, error) {
+	var br models.BundleReference
+	if err := rows.Scan(&br.ID, &br.Repo, &br.Tag, &br.Digest); err != nil {
+		return nil, err
+	}
+	return &br, nil
+}
+
 func bundleReferenceFromRow(row *sql.Row) (*models.BundleReference, error) {
 	var br models.BundleReference
 	if err := row.Scan(&br.ID, &br.Repo, &br.Tag, &br.Digest); err != nil {
@@ -190,10 +375,14 @@ func bundleReferenceFromRow(row *sql.Row) (*models.BundleReference, error) {
 }
 
 func (q Query) GetBundleByDigest(ctx context.Context, dig digest.Digest) (*models.Bundle, error) {
-	row := q.db.QueryRowContext(ctx, `SELECT * FROM bundles WHERE descriptor ->> 'digest' = $1`, dig)
+	row := q.db.QueryRowContext(ctx, `SELECT `+bundleColumns+` FROM bundles WHERE descriptor ->> 'digest' = $1`, dig)
 	return bundleFromRow(row)
 }
 
+// bundleColumns are the columns of bundles that models.Bundle holds, in the
+// order that bundleFromRow, rowToBundle, and scanBundle scan them.
+const bundleColumns = `id, package_id, descriptor, index, manifest, image, version, release, created_at, csv_digest`
+
 func bundleFromRow(row *sql.Row) (*models.Bundle, error) {
 	var b models.Bundle
 	if err := row.Scan(
@@ -205,7 +394,8 @@ func bundleFromRow(row *sql.Row) (*models.Bundle, error) {
 		&b.Image,
 		&b.Version,
 		&b.Release,
-		&b.CreatedAt); err != nil {
+		&b.CreatedAt,
+		&b.CSVDigest); err != nil {
 		return nil, err
 	}
 	return &b, nil
@@ -234,35 +424,89 @@ func (q Query) CreateBundleWithCatalogAndReference(ctx context.Context, b *model
 	if err != nil {
 		return fmt.Errorf("error starting transaction: %w", err)
 	}
-	if err := func() error {
-		row := tx.QueryRowContext(ctx, `INSERT INTO bundles (
-			package_id,  
-			descriptor, 
-			index, 
-			manifest, 
-			image, 
-			version, 
-			release
-		) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING *;`,
-			b.PackageID,
-			b.Descriptor,
-			b.Index,
-			b.Manifest,
-			b.Image,
-			b.Version,
-			b.Release)
-		updatedBundle, err := rowToBundle(row)
+	if err := createBundle(ctx, tx, b, br); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	return tx.Commit()
+}
+
+// NewBundle is a bundle stored by CreateBundle, along with what is recorded
+// about it when it is created. Empty fields are not recorded.
+type NewBundle struct {
+	Bundle    *models.Bundle
+	Reference *models.BundleReference
+
+	// Provenance is the ingestion that created the bundle. Its BundleID is
+	// set to the ID of the created bundle.
+	Provenance *models.BundleProvenance
+
+	// FetchFailure is the reference whose fetch failure is removed, now that
+	// its bundle has been fetched.
+	FetchFailure string
+
+	Properties         []models.BundleProperty
+	RelatedImages      []models.RelatedImage
+	Labels             map[string]string
+	CSV                []byte
+	QualityFlags       []string
+	ContentFingerprint digest.Digest
+}
+
+// CreateBundle stores a new bundle, associated with its reference, and
+// records everything about it in nb in one transaction, so that a bundle is
+// never stored without its provenance, properties, related images, labels,
+// ClusterServiceVersion, quality flags, and fingerprint, which are only
+// recorded when a bundle is created. The ClusterServiceVersion is stored in
+// the blob store first; blobs are content-addressed, so one left behind by a
+// failed transaction is stored again by the next attempt.
+func (q Query) CreateBundle(ctx context.Context, nb NewBundle) error {
+	b := nb.Bundle
+	if nb.CSV != nil {
+		d, err := q.blobs.Put(ctx, nb.CSV)
 		if err != nil {
-			return fmt.Errorf("error inserting bundle: %w", err)
+			return fmt.Errorf("error storing ClusterServiceVersion: %w", err)
 		}
-		*b = *updatedBundle
+		b.CSVDigest = sql.NullString{String: d.String(), Valid: true}
+	}
 
-		if _, err := tx.ExecContext(ctx, `INSERT INTO bundle_reference_bundles (
-        	bundle_id, bundle_reference_id
-        ) VALUES ($1, $2);`, b.ID, br.ID); err != nil {
-			return fmt.Errorf("error inserting bundle reference association: %w", err)
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	if err := func() error {
+		if err := createBundle(ctx, tx, b, nb.Reference); err != nil {
+			return err
+		}
+		if nb.Provenance != nil {
+			nb.Provenance.BundleID = b.ID
+			if err := setBundleProvenance(ctx, tx, nb.Provenance); err != nil {
+				return fmt.Errorf("error recording bundle provenance: %w", err)
+			}
+		}
+		if nb.FetchFailure != "" {
+			if err := deleteFetchFailure(ctx, tx, nb.FetchFailure); err != nil {
+				return fmt.Errorf("error deleting fetch failure: %w", err)
+			}
+		}
+		if err := createBundleProperties(ctx, tx, b, nb.Properties); err != nil {
+			return err
+		}
+		if err := createBundleRelatedImages(ctx, tx, b, nb.RelatedImages); err != nil {
+			return err
+		}
+		if err := createBundleLabels(ctx, tx, b, nb.Labels); err != nil {
+			return err
+		}
+		if nb.QualityFlags != nil {
+			if err := setBundleQualityFlags(ctx, tx, b, nb.QualityFlags); err != nil {
+				return err
+			}
+		}
+		if nb.ContentFingerprint != "" {
+			if err := setBundleContentFingerprint(ctx, tx, b, nb.ContentFingerprint); err != nil {
+				return err
+			}
 		}
-
 		return nil
 	}(); err != nil {
 		return errors.Join(err, tx.Rollback())
@@ -270,6 +514,45 @@ func (q Query) CreateBundleWithCatalogAndReference(ctx context.Context, b *model
 	return tx.Commit()
 }
 
+// execer runs statements on a database or in a transaction.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func createBundle(ctx context.Context, tx *sql.Tx, b *models.Bundle, br *models.BundleReference) error {
+	row := tx.QueryRowContext(ctx, `INSERT INTO bundles (
+		package_id,  
+		descriptor, 
+		index, 
+		manifest, 
+		image, 
+		version, 
+		release,
+		csv_digest
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING `+bundleColumns,
+		b.PackageID,
+		b.Descriptor,
+		b.Index,
+		b.Manifest,
+		b.Image,
+		b.Version,
+		b.Release,
+		b.CSVDigest)
+	updatedBundle, err := rowToBundle(row)
+	if err != nil {
+		return fmt.Errorf("error inserting bundle: %w", err)
+	}
+	*b = *updatedBundle
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO bundle_reference_bundles (
+        	bundle_id, bundle_reference_id
+        ) VALUES ($1, $2);`, b.ID, br.ID); err != nil {
+		return fmt.Errorf("error inserting bundle reference association: %w", err)
+	}
+
+	return nil
+}
+
 func rowToBundle(row *sql.Row) (*models.Bundle, error) {
 	var b models.Bundle
 	if err := row.Scan(
@@ -281,40 +564,2048 @@ func rowToBundle(row *sql.Row) (*models.Bundle, error) {
 		&b.Image,
 		&b.Version,
 		&b.Release,
-		&b.CreatedAt); err != nil {
+		&b.CreatedAt,
+		&b.CSVDigest); err != nil {
 		return nil, err
 	}
 	return &b, nil
 }
 
+// GetMissingBundlesInCatalog returns the bundle references in the most
+// recently ingested digest of the catalog whose bundles are not in the database.
 func (q Query) GetMissingBundlesInCatalog(ctx context.Context, c *models.Catalog) ([]*models.BundleReference, error) {
 	rows, err := q.db.QueryContext(ctx, `
-    SELECT 
-        t4.*
-    FROM catalogs AS t1
-    JOIN catalog_bundle_references AS t2
-    	ON t1.id = t2.catalog_id
-    LEFT JOIN bundle_reference_bundles AS t3
-        ON t2.bundle_reference_id = t3.bundle_reference_id
-    JOIN bundle_references AS t4
-    	ON t2.bundle_reference_id = t4.id
-    WHERE t1.id = $1 AND t3.bundle_reference_id IS NULL;`, c.ID)
+    WITH latest_digest AS (
+        SELECT id FROM catalog_digests WHERE catalog_id = $1 ORDER BY created_at DESC LIMIT 1
+    )
+    SELECT
+        br.id, br.repo, br.tag, br.digest
+    FROM latest_digest AS ld
+    JOIN catalog_digest_bundle_references AS cdbr
+        ON cdbr.catalog_digest_id = ld.id
+    LEFT JOIN bundle_reference_bundles AS brb
+        ON cdbr.bundle_reference_id = brb.bundle_reference_id
+    JOIN bundle_references AS br
+        ON cdbr.bundle_reference_id = br.id
+    WHERE brb.bundle_reference_id IS NULL
+    ORDER BY br.repo, br.digest;`, c.ID)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows(rows, func(rows *sql.Rows) (*models.BundleReference, error) {
+		var br models.BundleReference
+		if err := rows.Scan(&br.ID, &br.Repo, &br.Tag, &br.Digest); err != nil {
+			return nil, err
+		}
+		return &br, nil
+	})
+}
+
+func (q Query) ListCatalogs(ctx context.Context) ([]*models.Catalog, error) {
+	rows, err := q.db.QueryContext(ctx, `SELECT `+catalogColumns+` FROM catalogs ORDER BY name, tag`)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows(rows, scanCatalog)
+}
+
+func (q Query) GetCatalogsForBundle(ctx context.Context, b *models.Bundle) ([]*models.Catalog, error) {
+	rows, err := q.db.QueryContext(ctx, `
+    SELECT DISTINCT
+        c.id, c.name, c.tag, c.jira_feature_project, c.jira_feature_component, c.jira_bug_project, c.jira_bug_component, c.created_at
+    FROM bundle_reference_bundles AS brb
+    JOIN catalog_digest_bundle_references AS cdbr
+        ON brb.bundle_reference_id = cdbr.bundle_reference_id
+    JOIN catalog_digests AS cd
+        ON cdbr.catalog_digest_id = cd.id
+    JOIN catalogs AS c
+        ON cd.catalog_id = c.id
+    WHERE brb.bundle_id = $1
+    ORDER BY c.name, c.tag;`, b.ID)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows(rows, scanCatalog)
+}
+
+// CatalogShipment is a catalog that has contained an image, and when.
+type CatalogShipment struct {
+	Catalog *models.Catalog
+
+	// FirstShippedAt and LastShippedAt are when the first and last ingested
+	// digests of the catalog that contain the image were recorded.
+	FirstShippedAt time.Time
+	LastShippedAt  time.Time
+
+	// Current is true if the latest ingested digest of the catalog contains
+	// the image.
+	Current bool
+}
+
+// GetCatalogsForDigest returns the catalogs that have ever contained an image
+// with the digest, from any repository, ordered by name and tag. Unlike
+// GetCatalogsForBundle, it finds images that were never ingested as bundles,
+// such as those missing from their registries.
+func (q Query) GetCatalogsForDigest(ctx context.Context, dgst digest.Digest) ([]CatalogShipment, error) {
+	rows, err := q.db.QueryContext(ctx, `
+    SELECT
+        c.id, c.name, c.tag, c.jira_feature_project, c.jira_feature_component, c.jira_bug_project, c.jira_bug_component, c.created_at,
+        MIN(cd.created_at), MAX(cd.created_at),
+        bool_or(cd.id = (SELECT id FROM catalog_digests WHERE catalog_id = c.id ORDER BY created_at DESC LIMIT 1))
+    FROM bundle_references AS br
+    JOIN catalog_digest_bundle_references AS cdbr
+        ON cdbr.bundle_reference_id = br.id
+    JOIN catalog_digests AS cd
+        ON cdbr.catalog_digest_id = cd.id
+    JOIN catalogs AS c
+        ON cd.catalog_id = c.id
+    WHERE br.digest = $1
+    GROUP BY c.id
+    ORDER BY c.name, c.tag;`, dgst.String())
+	if err != nil {
+		return nil, err
+	}
+	return collectRows(rows, func(rows *sql.Rows) (CatalogShipment, error) {
+		var (
+			c  models.Catalog
+			cs = CatalogShipment{Catalog: &c}
+		)
+		err := rows.Scan(&c.ID, &c.Name, &c.Tag, &c.JiraFeatureProject, &c.JiraFeatureComponent, &c.JiraBugProject, &c.JiraBugComponent, &c.CreatedAt,
+			&cs.FirstShippedAt, &cs.LastShippedAt, &cs.Current)
+		return cs, err
+	})
+}
+
+func scanCatalog(rows *sql.Rows) (*models.Catalog, error) {
+	var c models.Catalog
+	if err := rows.Scan(&c.ID, &c.Name, &c.Tag, &c.JiraFeatureProject, &c.JiraFeatureComponent, &c.JiraBugProject, &c.JiraBugComponent, &c.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (q Query) ListPackages(ctx context.Context) ([]*models.Package, error) {
+	rows, err := q.db.QueryContext(ctx, `SELECT `+packageColumns+` FROM packages ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows(rows, scanPackage)
+}
+
+func (q Query) GetPackageByName(ctx context.Context, name string) (*models.Package, error) {
+	return packageFromRow(q.db.QueryRowContext(ctx, `SELECT `+packageColumns+` FROM packages WHERE name = $1`, name))
+}
+
+func (q Query) GetPackageByID(ctx context.Context, id string) (*models.Package, error) {
+	return packageFromRow(q.db.QueryRowContext(ctx, `SELECT `+packageColumns+` FROM packages WHERE id = $1`, id))
+}
+
+func scanPackage(rows *sql.Rows) (*models.Package, error) {
+	var p models.Package
+	if err := rows.Scan(&p.ID, &p.Name, &p.JiraFeatureProject, &p.JiraFeatureComponent, &p.JiraBugProject, &p.JiraBugComponent, &p.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (q Query) ListBundlesForPackage(ctx context.Context, p *models.Package) ([]*models.Bundle, error) {
+	rows, err := q.db.QueryContext(ctx, `
+    SELECT `+bundleColumns+`
+    FROM bundles
+    WHERE package_id = $1
+    ORDER BY (image ->> 'created') ASC;`, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	bundles, err := collectRows(rows, scanBundle)
+	if err != nil {
+		return nil, err
+	}
+	sortBundles(bundles)
+	return bundles, nil
+}
+
+// ListVerifiedBundlesForPackage is like ListBundlesForPackage, but only lists
+// the bundles whose signatures and attestations have been verified.
+func (q Query) ListVerifiedBundlesForPackage(ctx context.Context, p *models.Package) ([]*models.Bundle, error) {
+	rows, err := q.db.QueryContext(ctx, `
+    SELECT
+        b.id, b.package_id, b.descriptor, b.index, b.manifest, b.image, b.version, b.release, b.created_at, b.csv_digest
+    FROM bundles AS b
+    JOIN bundle_verifications AS bv ON bv.bundle_id = b.id
+    WHERE b.package_id = $1 AND bv.verified
+    ORDER BY (b.image ->> 'created') ASC;`, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	bundles, err := collectRows(rows, scanBundle)
+	if err != nil {
+		return nil, err
+	}
+	sortBundles(bundles)
+	return bundles, nil
+}
+
+// sortBundles orders bundles by build time, as the database does, and then by
+// version and release, so that rebuilds with the same build time, such as
+// reproducible images, are ordered by release instead of arbitrarily.
+func sortBundles(bundles []*models.Bundle) {
+	slices.SortStableFunc(bundles, compareBundles)
+}
+
+func compareBundles(a, b *models.Bundle) int {
+	return cmp.Or(compareBuildTimes(a, b), bundleVersionRelease(a).Compare(bundleVersionRelease(b)))
+}
+
+// compareBuildTimes orders bundles without a build time last, like the
+// database orders nulls.
+func compareBuildTimes(a, b *models.Bundle) int {
+	at, bt := buildTime(a), buildTime(b)
+	switch {
+	case at == nil && bt == nil:
+		return 0
+	case at == nil:
+		return 1
+	case bt == nil:
+		return -1
+	}
+	return at.Compare(*bt)
+}
+
+func buildTime(b *models.Bundle) *time.Time {
+	if b.Image.V == nil {
+		return nil
+	}
+	return b.Image.V.Created
+}
+
+func bundleVersionRelease(b *models.Bundle) graph.VersionRelease {
+	// Stored versions are valid semver, as the bundles table requires.
+	v, _ := semver.Parse(b.Version)
+	vr := graph.VersionRelease{Version: v}
+	if b.Release.Valid {
+		vr.Release = b.Release.String
+	}
+	return vr
+}
 
+func scanBundle(rows *sql.Rows) (*models.Bundle, error) {
+	var b models.Bundle
+	if err := rows.Scan(
+		&b.ID,
+		&b.PackageID,
+		&b.Descriptor,
+		&b.Index,
+		&b.Manifest,
+		&b.Image,
+		&b.Version,
+		&b.Release,
+		&b.CreatedAt,
+		&b.CSVDigest); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (q Query) GetBundleReferencesForBundle(ctx context.Context, b *models.Bundle) ([]*models.BundleReference, error) {
+	rows, err := q.db.QueryContext(ctx, `
+    SELECT
+        br.id, br.repo, br.tag, br.digest
+    FROM bundle_reference_bundles AS brb
+    JOIN bundle_references AS br
+        ON brb.bundle_reference_id = br.id
+    WHERE brb.bundle_id = $1
+    ORDER BY br.repo, br.tag, br.digest;`, b.ID)
 	if err != nil {
 		return nil, err
 	}
+	return collectRows(rows, func(rows *sql.Rows) (*models.BundleReference, error) {
+		var br models.BundleReference
+		if err := rows.Scan(&br.ID, &br.Repo, &br.Tag, &br.Digest); err != nil {
+			return nil, err
+		}
+		return &br, nil
+	})
+}
+
+func collectRows[T any](rows *sql.Rows, scan func(*sql.Rows) (T, error)) ([]T, error) {
 	defer rows.Close()
 
-	var result []*models.BundleReference
+	var result []T
 	for rows.Next() {
-		var br models.BundleReference
-		if err := rows.Scan(&br.ID, &br.Repo, &br.Tag, &br.Digest, &br.CreatedAt); err != nil {
+		v, err := scan(rows)
+		if err != nil {
 			return nil, err
 		}
-		result = append(result, &br)
+		result = append(result, v)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 	return result, nil
 }
+
+func (q Query) CreateIngestionRun(ctx context.Context) (*models.IngestionRun, error) {
+	return ingestionRunFromRow(q.db.QueryRowContext(ctx, `INSERT INTO ingestion_runs DEFAULT VALUES RETURNING id, started_at, finished_at, error`))
+}
+
+// FinishIngestionRun marks the run as finished, recording runErr if the run failed.
+func (q Query) FinishIngestionRun(ctx context.Context, run *models.IngestionRun, runErr error) error {
+	errString := sql.NullString{}
+	if runErr != nil {
+		errString = sql.NullString{String: runErr.Error(), Valid: true}
+	}
+	updated, err := ingestionRunFromRow(q.db.QueryRowContext(ctx, `UPDATE ingestion_runs SET finished_at = NOW(), error = $2 WHERE id = $1 RETURNING id, started_at, finished_at, error`, run.ID, errString))
+	if err != nil {
+		return fmt.Errorf("error updating ingestion run: %w", err)
+	}
+	*run = *updated
+	return nil
+}
+
+// GetLatestIngestionRun returns the most recently finished ingestion run, whether
+// or not it succeeded.
+func (q Query) GetLatestIngestionRun(ctx context.Context) (*models.IngestionRun, error) {
+	return ingestionRunFromRow(q.db.QueryRowContext(ctx, `SELECT id, started_at, finished_at, error FROM ingestion_runs WHERE finished_at IS NOT NULL ORDER BY finished_at DESC LIMIT 1`))
+}
+
+// DeleteIngestionRunsBefore deletes up to limit of the oldest ingestion runs
+// that started before t, with the graphs cached by them, and returns how many
+// were deleted. The latest finished run is never deleted, since cached graphs
+// and response validators are keyed by it.
+func (q Query) DeleteIngestionRunsBefore(ctx context.Context, t time.Time, limit int) (int64, error) {
+	res, err := q.db.ExecContext(ctx, `
+    DELETE FROM ingestion_runs WHERE id IN (
+        SELECT id FROM ingestion_runs
+        WHERE started_at < $1
+          AND id IS DISTINCT FROM (SELECT id FROM ingestion_runs WHERE finished_at IS NOT NULL ORDER BY finished_at DESC LIMIT 1)
+        ORDER BY started_at
+        LIMIT $2
+    )`, t, limit)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func ingestionRunFromRow(row *sql.Row) (*models.IngestionRun, error) {
+	var run models.IngestionRun
+	if err := row.Scan(&run.ID, &run.StartedAt, &run.FinishedAt, &run.Error); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// HasLifecycleNotification reports whether a lifecycle notification has
+// already been sent for the version stream of a package.
+func (q Query) HasLifecycleNotification(ctx context.Context, pkg, stream, kind string, date time.Time) (bool, error) {
+	var exists bool
+	if err := q.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM lifecycle_notifications WHERE package_name = $1 AND stream = $2 AND kind = $3 AND "date" = $4)`, pkg, stream, kind, date).Scan(&exists); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// RecordLifecycleNotification records that a lifecycle notification was sent
+// for the version stream of a package.
+func (q Query) RecordLifecycleNotification(ctx context.Context, pkg, stream, kind string, date time.Time) error {
+	_, err := q.db.ExecContext(ctx, `INSERT INTO lifecycle_notifications (package_name, stream, kind, "date") VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING`, pkg, stream, kind, date)
+	return err
+}
+
+// DeleteLifecycleNotificationsBefore deletes up to limit of the oldest records
+// of lifecycle notifications sent before t, and returns how many were
+// deleted. Notifications whose events are still detected after their records
+// are deleted are sent again.
+func (q Query) DeleteLifecycleNotificationsBefore(ctx context.Context, t time.Time, limit int) (int64, error) {
+	res, err := q.db.ExecContext(ctx, `
+    DELETE FROM lifecycle_notifications WHERE id IN (
+        SELECT id FROM lifecycle_notifications
+        WHERE sent_at < $1
+        ORDER BY sent_at
+        LIMIT $2
+    )`, t, limit)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// SetCatalogJira sets the Jira projects and components that track features and
+// bugs for a catalog. Empty values clear the mapping.
+func (q Query) SetCatalogJira(ctx context.Context, c *models.Catalog) error {
+	_, err := q.db.ExecContext(ctx, `UPDATE catalogs SET jira_feature_project = $2, jira_feature_component = $3, jira_bug_project = $4, jira_bug_component = $5 WHERE id = $1`,
+		c.ID, c.JiraFeatureProject, c.JiraFeatureComponent, c.JiraBugProject, c.JiraBugComponent)
+	return err
+}
+
+// SetPackageJira sets the Jira projects and components that track features and
+// bugs for a package. Empty values clear the mapping.
+func (q Query) SetPackageJira(ctx context.Context, p *models.Package) error {
+	_, err := q.db.ExecContext(ctx, `UPDATE packages SET jira_feature_project = $2, jira_feature_component = $3, jira_bug_project = $4, jira_bug_component = $5 WHERE id = $1`,
+		p.ID, p.JiraFeatureProject, p.JiraFeatureComponent, p.JiraBugProject, p.JiraBugComponent)
+	return err
+}
+
+// ListJiraIssues returns every link between a problem and a Jira issue.
+func (q Query) ListJiraIssues(ctx context.Context) ([]*models.JiraIssue, error) {
+	rows, err := q.db.QueryContext(ctx, `SELECT problem, issue_key, created_at FROM jira_issues ORDER BY problem`)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows(rows, func(rows *sql.Rows) (*models.JiraIssue, error) {
+		var ji models.JiraIssue
+		if err := rows.Scan(&ji.Problem, &ji.IssueKey, &ji.CreatedAt); err != nil {
+			return nil, err
+		}
+		return &ji, nil
+	})
+}
+
+// LinkJiraIssue links a problem to the Jira issue that tracks it, replacing
+// any existing link.
+func (q Query) LinkJiraIssue(ctx context.Context, problem, issueKey string) error {
+	_, err := q.db.ExecContext(ctx, `INSERT INTO jira_issues (problem, issue_key) VALUES ($1, $2) ON CONFLICT (problem) DO UPDATE SET issue_key = EXCLUDED.issue_key, created_at = NOW()`, problem, issueKey)
+	return err
+}
+
+// GetPackagesForRepository returns the names of the packages that have
+// bundles stored in a repository.
+func (q Query) GetPackagesForRepository(ctx context.Context, repo string) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, `
+    SELECT DISTINCT
+        p.name
+    FROM bundle_references AS br
+    JOIN bundle_reference_bundles AS brb
+        ON brb.bundle_reference_id = br.id
+    JOIN bundles AS b
+        ON brb.bundle_id = b.id
+    JOIN packages AS p
+        ON b.package_id = p.id
+    WHERE br.repo = $1
+    ORDER BY p.name;`, repo)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows(rows, func(rows *sql.Rows) (string, error) {
+		var name string
+		err := rows.Scan(&name)
+		return name, err
+	})
+}
+
+// PackageBuilds summarizes the bundles of a package that were built in a
+// time range.
+type PackageBuilds struct {
+	Name       string
+	Bundles    int
+	FirstBuilt time.Time
+	LastBuilt  time.Time
+}
+
+// ListPackagesBuiltBetween returns the packages that have bundles whose images
+// were built at or after since and before until, ordered by name.
+func (q Query) ListPackagesBuiltBetween(ctx context.Context, since, until time.Time) ([]PackageBuilds, error) {
+	rows, err := q.db.QueryContext(ctx, `
+    SELECT
+        p.name, COUNT(*), MIN((b.image ->> 'created')::timestamptz), MAX((b.image ->> 'created')::timestamptz)
+    FROM bundles AS b
+    JOIN packages AS p
+        ON b.package_id = p.id
+    WHERE (b.image ->> 'created')::timestamptz >= $1 AND (b.image ->> 'created')::timestamptz < $2
+    GROUP BY p.name
+    ORDER BY p.name;`, since, until)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows(rows, func(rows *sql.Rows) (PackageBuilds, error) {
+		var pb PackageBuilds
+		if err := rows.Scan(&pb.Name, &pb.Bundles, &pb.FirstBuilt, &pb.LastBuilt); err != nil {
+			return PackageBuilds{}, err
+		}
+		return pb, nil
+	})
+}
+
+// CreateBundleProperties stores properties declared by a bundle.
+func (q Query) CreateBundleProperties(ctx context.Context, b *models.Bundle, props []models.BundleProperty) error {
+	return createBundleProperties(ctx, q.db, b, props)
+}
+
+func createBundleProperties(ctx context.Context, db execer, b *models.Bundle, props []models.BundleProperty) error {
+	for _, p := range props {
+		if _, err := db.ExecContext(ctx, `INSERT INTO bundle_properties (bundle_id, type, value) VALUES ($1, $2, $3)`, b.ID, p.Type, string(p.Value)); err != nil {
+			return fmt.Errorf("error inserting %s property: %w", p.Type, err)
+		}
+	}
+	return nil
+}
+
+// GetMaxOpenShiftVersions returns the olm.maxOpenShiftVersion property of
+// each bundle of a package that declares one, keyed by the bundle's digest.
+func (q Query) GetMaxOpenShiftVersions(ctx context.Context, packageName string) (map[digest.Digest]string, error) {
+	rows, err := q.db.QueryContext(ctx, `
+    SELECT b.descriptor ->> 'digest', bp.value #>> '{}'
+    FROM bundle_properties AS bp
+    JOIN bundles AS b ON b.id = bp.bundle_id
+    JOIN packages AS p ON p.id = b.package_id
+    WHERE p.name = $1 AND bp.type = 'olm.maxOpenShiftVersion';`, packageName)
+	if err != nil {
+		return nil, err
+	}
+	type maxVersion struct {
+		digest  digest.Digest
+		version string
+	}
+	versions, err := collectRows(rows, func(rows *sql.Rows) (maxVersion, error) {
+		var mv maxVersion
+		err := rows.Scan(&mv.digest, &mv.version)
+		return mv, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	byDigest := make(map[digest.Digest]string, len(versions))
+	for _, mv := range versions {
+		byDigest[mv.digest] = mv.version
+	}
+	return byDigest, nil
+}
+
+// CreateBundleRelatedImages stores the related images of a bundle.
+func (q Query) CreateBundleRelatedImages(ctx context.Context, b *models.Bundle, images []models.RelatedImage) error {
+	return createBundleRelatedImages(ctx, q.db, b, images)
+}
+
+func createBundleRelatedImages(ctx context.Context, db execer, b *models.Bundle, images []models.RelatedImage) error {
+	for _, ri := range images {
+		if _, err := db.ExecContext(ctx, `INSERT INTO bundle_related_images (bundle_id, "name", image, digest) VALUES ($1, $2, $3, $4) ON CONFLICT (bundle_id, image) DO NOTHING`,
+			b.ID, ri.Name, ri.Image, ri.Digest); err != nil {
+			return fmt.Errorf("error inserting related image %s: %w", ri.Image, err)
+		}
+	}
+	return nil
+}
+
+// CreateBundleLabels stores labels of a bundle's image. Labels that are
+// already stored for the bundle keep their values.
+func (q Query) CreateBundleLabels(ctx context.Context, b *models.Bundle, labels map[string]string) error {
+	return createBundleLabels(ctx, q.db, b, labels)
+}
+
+func createBundleLabels(ctx context.Context, db execer, b *models.Bundle, labels map[string]string) error {
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		if _, err := db.ExecContext(ctx, `INSERT INTO bundle_labels (bundle_id, key, value) VALUES ($1, $2, $3) ON CONFLICT (bundle_id, key) DO NOTHING`,
+			b.ID, key, labels[key]); err != nil {
+			return fmt.Errorf("error inserting label %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// ErrNoCSV is returned by GetBundleCSV for bundles whose
+// ClusterServiceVersion is not stored.
+var ErrNoCSV = errors.New("bundle has no stored ClusterServiceVersion")
+
+// SetBundleCSV stores csv, a bundle's ClusterServiceVersion as JSON, in the
+// blob store and records its digest as the bundle's CSVDigest.
+func (q Query) SetBundleCSV(ctx context.Context, b *models.Bundle, csv []byte) error {
+	d, err := q.blobs.Put(ctx, csv)
+	if err != nil {
+		return fmt.Errorf("error storing ClusterServiceVersion: %w", err)
+	}
+	if _, err := q.db.ExecContext(ctx, `UPDATE bundles SET csv_digest = $2 WHERE id = $1`, b.ID, d); err != nil {
+		return fmt.Errorf("error recording ClusterServiceVersion digest: %w", err)
+	}
+	b.CSVDigest = sql.NullString{String: d.String(), Valid: true}
+	return nil
+}
+
+// GetBundleCSV returns a bundle's ClusterServiceVersion as JSON from the blob
+// store, or an error wrapping ErrNoCSV if it is not stored.
+func (q Query) GetBundleCSV(ctx context.Context, b *models.Bundle) ([]byte, error) {
+	if !b.CSVDigest.Valid {
+		return nil, ErrNoCSV
+	}
+	csv, err := q.blobs.Get(ctx, digest.Digest(b.CSVDigest.String))
+	if errors.Is(err, blobstore.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %w", ErrNoCSV, err)
+	}
+	return csv, err
+}
+
+// SetBundleQualityFlags records the quality flags found in a bundle's
+// ClusterServiceVersion, replacing any recorded before.
+func (q Query) SetBundleQualityFlags(ctx context.Context, b *models.Bundle, flags []string) error {
+	return setBundleQualityFlags(ctx, q.db, b, flags)
+}
+
+func setBundleQualityFlags(ctx context.Context, db execer, b *models.Bundle, flags []string) error {
+	if _, err := db.ExecContext(ctx, `UPDATE bundles SET quality_flags = $2 WHERE id = $1`, b.ID, pq.Array(flags)); err != nil {
+		return fmt.Errorf("error recording quality flags: %w", err)
+	}
+	return nil
+}
+
+// FlaggedBundle is a bundle with quality flags.
+type FlaggedBundle struct {
+	Package string
+	Version string
+	Release sql.NullString
+	Digest  string
+	Flags   []string
+}
+
+// ListFlaggedBundles returns the bundles with any of the quality flags in
+// flags, or with any quality flag if flags is empty, ordered by package and
+// version. Only the bundles of the packages in packageNames are listed, if any
+// are given.
+func (q Query) ListFlaggedBundles(ctx context.Context, flags, packageNames []string) ([]FlaggedBundle, error) {
+	rows, err := q.db.QueryContext(ctx, `
+    SELECT p.name, b.version, b.release, b.descriptor ->> 'digest', b.quality_flags
+    FROM bundles AS b
+    JOIN packages AS p ON p.id = b.package_id
+    WHERE cardinality(b.quality_flags) > 0
+        AND (COALESCE(cardinality($1::text[]), 0) = 0 OR b.quality_flags && $1::text[])
+        AND (COALESCE(cardinality($2::text[]), 0) = 0 OR p.name = ANY($2))
+    ORDER BY p.name, b.descriptor ->> 'digest';`, pq.Array(flags), pq.Array(packageNames))
+	if err != nil {
+		return nil, err
+	}
+	bundles, err := collectRows(rows, func(rows *sql.Rows) (FlaggedBundle, error) {
+		var fb FlaggedBundle
+		err := rows.Scan(&fb.Package, &fb.Version, &fb.Release, &fb.Digest, pq.Array(&fb.Flags))
+		return fb, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(bundles, func(a, b FlaggedBundle) int {
+		return cmp.Or(cmp.Compare(a.Package, b.Package), flaggedVersionRelease(a).Compare(flaggedVersionRelease(b)))
+	})
+	return bundles, nil
+}
+
+func flaggedVersionRelease(fb FlaggedBundle) graph.VersionRelease {
+	// Stored versions are valid semver, as the bundles table requires.
+	v, _ := semver.Parse(fb.Version)
+	return graph.VersionRelease{Version: v, Release: fb.Release.String}
+}
+
+// SetBundleContentFingerprint records the fingerprint of a bundle's content,
+// replacing any recorded before.
+func (q Query) SetBundleContentFingerprint(ctx context.Context, b *models.Bundle, fingerprint digest.Digest) error {
+	return setBundleContentFingerprint(ctx, q.db, b, fingerprint)
+}
+
+func setBundleContentFingerprint(ctx context.Context, db execer, b *models.Bundle, fingerprint digest.Digest) error {
+	if _, err := db.ExecContext(ctx, `UPDATE bundles SET content_fingerprint = $2 WHERE id = $1`, b.ID, fingerprint.String()); err != nil {
+		return fmt.Errorf("error recording content fingerprint: %w", err)
+	}
+	return nil
+}
+
+// IdenticalRebuild is a bundle whose content fingerprint is shared by another
+// bundle of the same package and version, so that one is a rebuild of the
+// other that changes nothing that they install.
+type IdenticalRebuild struct {
+	Package     string
+	Version     string
+	Release     sql.NullString
+	Digest      string
+	Fingerprint string
+}
+
+// ListIdenticalRebuilds returns the bundles that share their content
+// fingerprint with another bundle of the same package and version, ordered by
+// package, version, fingerprint, and release, so that each set of identical
+// rebuilds is listed together. Only the bundles of the packages in
+// packageNames are listed, if any are given.
+func (q Query) ListIdenticalRebuilds(ctx context.Context, packageNames []string) ([]IdenticalRebuild, error) {
+	rows, err := q.db.QueryContext(ctx, `
+    SELECT p.name, b.version, b.release, b.descriptor ->> 'digest', b.content_fingerprint
+    FROM bundles AS b
+    JOIN packages AS p ON p.id = b.package_id
+    WHERE b.content_fingerprint IS NOT NULL
+        AND EXISTS (
+            SELECT 1 FROM bundles AS o
+            WHERE o.package_id = b.package_id
+                AND o.version = b.version
+                AND o.content_fingerprint = b.content_fingerprint
+                AND o.id <> b.id
+        )
+        AND (COALESCE(cardinality($1::text[]), 0) = 0 OR p.name = ANY($1))
+    ORDER BY p.name, b.descriptor ->> 'digest';`, pq.Array(packageNames))
+	if err != nil {
+		return nil, err
+	}
+	rebuilds, err := collectRows(rows, func(rows *sql.Rows) (IdenticalRebuild, error) {
+		var r IdenticalRebuild
+		err := rows.Scan(&r.Package, &r.Version, &r.Release, &r.Digest, &r.Fingerprint)
+		return r, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(rebuilds, func(a, b IdenticalRebuild) int {
+		return cmp.Or(
+			cmp.Compare(a.Package, b.Package),
+			rebuildVersionRelease(a).Version.Compare(rebuildVersionRelease(b).Version),
+			cmp.Compare(a.Fingerprint, b.Fingerprint),
+			rebuildVersionRelease(a).Compare(rebuildVersionRelease(b)),
+		)
+	})
+	return rebuilds, nil
+}
+
+func rebuildVersionRelease(r IdenticalRebuild) graph.VersionRelease {
+	// Stored versions are valid semver, as the bundles table requires.
+	v, _ := semver.Parse(r.Version)
+	return graph.VersionRelease{Version: v, Release: r.Release.String}
+}
+
+// BundleLabel is a label of a bundle's image.
+type BundleLabel struct {
+	Package string
+	Version string
+	Digest  string
+
+	Key   string
+	Value string
+}
+
+// BundleLabelFilter selects the bundle labels listed by ListBundleLabels.
+// Empty fields select every label.
+type BundleLabelFilter struct {
+	Key      string
+	Value    string
+	Packages []string
+}
+
+// ListBundleLabels returns the stored labels of bundles that match the
+// filter, ordered by package, build time, and key.
+func (q Query) ListBundleLabels(ctx context.Context, f BundleLabelFilter) ([]BundleLabel, error) {
+	rows, err := q.db.QueryContext(ctx, `
+    SELECT p.name, b.version, b.descriptor ->> 'digest', bl.key, bl.value
+    FROM bundle_labels AS bl
+    JOIN bundles AS b ON b.id = bl.bundle_id
+    JOIN packages AS p ON p.id = b.package_id
+    WHERE ($1::text = '' OR bl.key = $1)
+      AND ($2::text = '' OR bl.value = $2)
+      AND (COALESCE(cardinality($3::text[]), 0) = 0 OR p.name = ANY($3))
+    ORDER BY p.name, (b.image ->> 'created') ASC, b.version, bl.key;`, f.Key, f.Value, pq.Array(f.Packages))
+	if err != nil {
+		return nil, err
+	}
+	return collectRows(rows, func(rows *sql.Rows) (BundleLabel, error) {
+		var l BundleLabel
+		err := rows.Scan(&l.Package, &l.Version, &l.Digest, &l.Key, &l.Value)
+		return l, err
+	})
+}
+
+// LabelValueCount is the number of bundles whose image has a label with a
+// value.
+type LabelValueCount struct {
+	Value   string
+	Bundles int
+}
+
+// CountBundleLabelValues returns the number of bundles with each value of a
+// label, most common first.
+func (q Query) CountBundleLabelValues(ctx context.Context, key string) ([]LabelValueCount, error) {
+	rows, err := q.db.QueryContext(ctx, `
+    SELECT value, COUNT(*)
+    FROM bundle_labels
+    WHERE key = $1
+    GROUP BY value
+    ORDER BY 2 DESC, 1;`, key)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows(rows, func(rows *sql.Rows) (LabelValueCount, error) {
+		var c LabelValueCount
+		err := rows.Scan(&c.Value, &c.Bundles)
+		return c, err
+	})
+}
+
+// SetImageVulnerabilityStatus records the status of a vulnerability in the
+// image with the given digest, for every bundle whose bundle image or related
+// images have that digest. It returns the number of bundles updated.
+func (q Query) SetImageVulnerabilityStatus(ctx context.Context, imageDigest digest.Digest, vulnerability, status string) (int64, error) {
+	res, err := q.db.ExecContext(ctx, `
+    INSERT INTO bundle_vulnerabilities (bundle_id, vulnerability, image_digest, status)
+    SELECT id, $2::text, $1::text, $3::text FROM bundles WHERE descriptor ->> 'digest' = $1
+    UNION
+    SELECT bundle_id, $2::text, $1::text, $3::text FROM bundle_related_images WHERE digest = $1
+    ON CONFLICT (bundle_id, vulnerability, image_digest) DO UPDATE SET
+        status = EXCLUDED.status,
+        updated_at = NOW();`, imageDigest.String(), vulnerability, status)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// GetAffectedBundles returns the vulnerabilities that affect each bundle of a
+// package, keyed by the bundle's digest. A bundle is affected by a
+// vulnerability if its bundle image or any of its related images is.
+func (q Query) GetAffectedBundles(ctx context.Context, packageName string) (map[digest.Digest][]string, error) {
+	rows, err := q.db.QueryContext(ctx, `
+    SELECT DISTINCT b.descriptor ->> 'digest', bv.vulnerability
+    FROM bundle_vulnerabilities AS bv
+    JOIN bundles AS b ON b.id = bv.bundle_id
+    JOIN packages AS p ON p.id = b.package_id
+    WHERE p.name = $1 AND bv.status = 'affected'
+    ORDER BY 1, 2;`, packageName)
+	if err != nil {
+		return nil, err
+	}
+	type affected struct {
+		digest        digest.Digest
+		vulnerability string
+	}
+	all, err := collectRows(rows, func(rows *sql.Rows) (affected, error) {
+		var a affected
+		err := rows.Scan(&a.digest, &a.vulnerability)
+		return a, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	byDigest := map[digest.Digest][]string{}
+	for _, a := range all {
+		byDigest[a.digest] = append(byDigest[a.digest], a.vulnerability)
+	}
+	return byDigest, nil
+}
+
+// SetBundleBuild links a bundle to the record of the build that produced it,
+// and to the errata advisories that shipped it, replacing any existing links.
+func (q Query) SetBundleBuild(ctx context.Context, bb *models.BundleBuild, errata []string) error {
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	if err := func() error {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO bundle_builds (
+			bundle_id, system, build_id, source_repository, source_commit, url
+		) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (bundle_id) DO UPDATE SET
+			system = EXCLUDED.system,
+			build_id = EXCLUDED.build_id,
+			source_repository = EXCLUDED.source_repository,
+			source_commit = EXCLUDED.source_commit,
+			url = EXCLUDED.url,
+			created_at = NOW();`,
+			bb.BundleID, bb.System, bb.BuildID, bb.SourceRepository, bb.SourceCommit, bb.URL); err != nil {
+			return fmt.Errorf("error inserting bundle build: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM bundle_errata WHERE bundle_id = $1`, bb.BundleID); err != nil {
+			return fmt.Errorf("error deleting bundle errata: %w", err)
+		}
+		for _, advisory := range errata {
+			if _, err := tx.ExecContext(ctx, `INSERT INTO bundle_errata (bundle_id, advisory) VALUES ($1, $2) ON CONFLICT DO NOTHING`, bb.BundleID, advisory); err != nil {
+				return fmt.Errorf("error inserting erratum %s: %w", advisory, err)
+			}
+		}
+		return nil
+	}(); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	return tx.Commit()
+}
+
+// GetBundleBuildByDigest returns the build record of the bundle with the
+// given digest, and the errata advisories that shipped it.
+func (q Query) GetBundleBuildByDigest(ctx context.Context, dgst digest.Digest) (*models.BundleBuild, []string, error) {
+	var bb models.BundleBuild
+	if err := q.db.QueryRowContext(ctx, `
+    SELECT bb.bundle_id, bb.system, bb.build_id, bb.source_repository, bb.source_commit, bb.url, bb.created_at
+    FROM bundle_builds AS bb
+    JOIN bundles AS b ON b.id = bb.bundle_id
+    WHERE b.descriptor ->> 'digest' = $1;`, dgst.String()).Scan(
+		&bb.BundleID,
+		&bb.System,
+		&bb.BuildID,
+		&bb.SourceRepository,
+		&bb.SourceCommit,
+		&bb.URL,
+		&bb.CreatedAt); err != nil {
+		return nil, nil, err
+	}
+	rows, err := q.db.QueryContext(ctx, `SELECT advisory FROM bundle_errata WHERE bundle_id = $1 ORDER BY advisory`, bb.BundleID)
+	if err != nil {
+		return nil, nil, err
+	}
+	errata, err := collectRows(rows, func(rows *sql.Rows) (string, error) {
+		var advisory string
+		err := rows.Scan(&advisory)
+		return advisory, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &bb, errata, nil
+}
+
+// ListCertifiedBundlesForPackage is like ListBundlesForPackage, but only
+// lists the bundles that the Red Hat Ecosystem Catalog reports as certified.
+func (q Query) ListCertifiedBundlesForPackage(ctx context.Context, p *models.Package) ([]*models.Bundle, error) {
+	rows, err := q.db.QueryContext(ctx, `
+    SELECT
+        b.id, b.package_id, b.descriptor, b.index, b.manifest, b.image, b.version, b.release, b.created_at, b.csv_digest
+    FROM bundles AS b
+    JOIN bundle_pyxis_metadata AS pm ON pm.bundle_id = b.id
+    WHERE b.package_id = $1 AND pm.certified
+    ORDER BY (b.image ->> 'created') ASC;`, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	bundles, err := collectRows(rows, scanBundle)
+	if err != nil {
+		return nil, err
+	}
+	sortBundles(bundles)
+	return bundles, nil
+}
+
+// ListBundlesForPackageInCatalog is like ListBundlesForPackage, but only
+// lists the bundles referenced by the most recently ingested digest of the
+// catalog.
+func (q Query) ListBundlesForPackageInCatalog(ctx context.Context, p *models.Package, c *models.Catalog) ([]*models.Bundle, error) {
+	rows, err := q.db.QueryContext(ctx, `
+    WITH latest_digest AS (
+        SELECT id FROM catalog_digests WHERE catalog_id = $2 ORDER BY created_at DESC LIMIT 1
+    )
+    SELECT
+        b.id, b.package_id, b.descriptor, b.index, b.manifest, b.image, b.version, b.release, b.created_at, b.csv_digest
+    FROM bundles AS b
+    WHERE b.package_id = $1 AND EXISTS (
+        SELECT 1
+        FROM latest_digest AS ld
+        JOIN catalog_digest_bundle_references AS cdbr ON cdbr.catalog_digest_id = ld.id
+        JOIN bundle_reference_bundles AS brb ON brb.bundle_reference_id = cdbr.bundle_reference_id
+        WHERE brb.bundle_id = b.id
+    )
+    ORDER BY (b.image ->> 'created') ASC;`, p.ID, c.ID)
+	if err != nil {
+		return nil, err
+	}
+	bundles, err := collectRows(rows, scanBundle)
+	if err != nil {
+		return nil, err
+	}
+	sortBundles(bundles)
+	return bundles, nil
+}
+
+// SetBundlePyxisMetadata records the Red Hat Ecosystem Catalog metadata of a
+// bundle, replacing any that was recorded before.
+func (q Query) SetBundlePyxisMetadata(ctx context.Context, pm *models.BundlePyxisMetadata) error {
+	_, err := q.db.ExecContext(ctx, `INSERT INTO bundle_pyxis_metadata (
+		bundle_id, certified, vendor, support_level, repository, published_at
+	) VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (bundle_id) DO UPDATE SET
+		certified = EXCLUDED.certified,
+		vendor = EXCLUDED.vendor,
+		support_level = EXCLUDED.support_level,
+		repository = EXCLUDED.repository,
+		published_at = EXCLUDED.published_at,
+		fetched_at = NOW();`,
+		pm.BundleID, pm.Certified, pm.Vendor, pm.SupportLevel, pm.Repository, pm.PublishedAt)
+	return err
+}
+
+// GetBundlePyxisMetadataByDigest returns the Red Hat Ecosystem Catalog
+// metadata of the bundle with the given digest. It returns sql.ErrNoRows if
+// the bundle has not been looked up.
+func (q Query) GetBundlePyxisMetadataByDigest(ctx context.Context, dgst digest.Digest) (*models.BundlePyxisMetadata, error) {
+	var pm models.BundlePyxisMetadata
+	if err := q.db.QueryRowContext(ctx, `
+    SELECT pm.bundle_id, pm.certified, pm.vendor, pm.support_level, pm.repository, pm.published_at, pm.fetched_at
+    FROM bundle_pyxis_metadata AS pm
+    JOIN bundles AS b ON b.id = pm.bundle_id
+    WHERE b.descriptor ->> 'digest' = $1;`, dgst.String()).Scan(
+		&pm.BundleID,
+		&pm.Certified,
+		&pm.Vendor,
+		&pm.SupportLevel,
+		&pm.Repository,
+		&pm.PublishedAt,
+		&pm.FetchedAt); err != nil {
+		return nil, err
+	}
+	return &pm, nil
+}
+
+// SetBundleVerification records the result of verifying a bundle's signature
+// and attestations, replacing any earlier result.
+func (q Query) SetBundleVerification(ctx context.Context, bv *models.BundleVerification) error {
+	_, err := q.db.ExecContext(ctx, `INSERT INTO bundle_verifications (
+		bundle_id, verified, signer, message
+	) VALUES ($1, $2, $3, $4)
+	ON CONFLICT (bundle_id) DO UPDATE SET
+		verified = EXCLUDED.verified,
+		signer = EXCLUDED.signer,
+		message = EXCLUDED.message,
+		verified_at = NOW();`,
+		bv.BundleID, bv.Verified, bv.Signer, bv.Message)
+	return err
+}
+
+// GetBundleVerificationByDigest returns the verification result of the
+// bundle with the given digest. It returns sql.ErrNoRows if the bundle has
+// not been verified.
+func (q Query) GetBundleVerificationByDigest(ctx context.Context, dgst digest.Digest) (*models.BundleVerification, error) {
+	var bv models.BundleVerification
+	if err := q.db.QueryRowContext(ctx, `
+    SELECT bv.bundle_id, bv.verified, bv.signer, bv.message, bv.verified_at
+    FROM bundle_verifications AS bv
+    JOIN bundles AS b ON b.id = bv.bundle_id
+    WHERE b.descriptor ->> 'digest' = $1;`, dgst.String()).Scan(
+		&bv.BundleID,
+		&bv.Verified,
+		&bv.Signer,
+		&bv.Message,
+		&bv.VerifiedAt); err != nil {
+		return nil, err
+	}
+	return &bv, nil
+}
+
+// SetBundleProvenance records the ingestion that created or updated a bundle,
+// replacing the record of the previous ingestion with the same event.
+func (q Query) SetBundleProvenance(ctx context.Context, bp *models.BundleProvenance) error {
+	return setBundleProvenance(ctx, q.db, bp)
+}
+
+func setBundleProvenance(ctx context.Context, db execer, bp *models.BundleProvenance) error {
+	_, err := db.ExecContext(ctx, `
+	INSERT INTO bundle_provenance (bundle_id, event, source_type, source_id, ingestion_run_id)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (bundle_id, event) DO UPDATE SET
+		source_type = EXCLUDED.source_type,
+		source_id = EXCLUDED.source_id,
+		ingestion_run_id = EXCLUDED.ingestion_run_id,
+		recorded_at = NOW();`,
+		bp.BundleID, bp.Event, bp.SourceType, bp.SourceID, bp.IngestionRunID)
+	return err
+}
+
+// BundleProvenanceRecord is the provenance of a bundle, along with the
+// bundle's package, version, and digest.
+type BundleProvenanceRecord struct {
+	Package string
+	Version string
+	Digest  string
+
+	models.BundleProvenance
+}
+
+// BundleProvenanceFilter selects the provenance listed by
+// ListBundleProvenance. Empty fields select every record.
+type BundleProvenanceFilter struct {
+	Digest         string
+	SourceType     string
+	SourceID       string
+	IngestionRunID string
+	Packages       []string
+}
+
+// ListBundleProvenance returns the provenance of the bundles that match the
+// filter, ordered by package, build time, and when it was recorded, so that
+// each bundle's creation is listed before its last update.
+func (q Query) ListBundleProvenance(ctx context.Context, f BundleProvenanceFilter) ([]BundleProvenanceRecord, error) {
+	rows, err := q.db.QueryContext(ctx, `
+    SELECT p.name, b.version, b.descriptor ->> 'digest',
+        bp.bundle_id, bp.event, bp.source_type, bp.source_id, bp.ingestion_run_id, bp.recorded_at
+    FROM bundle_provenance AS bp
+    JOIN bundles AS b ON b.id = bp.bundle_id
+    JOIN packages AS p ON p.id = b.package_id
+    WHERE ($1::text = '' OR b.descriptor ->> 'digest' = $1)
+      AND ($2::text = '' OR bp.source_type = $2)
+      AND ($3::text = '' OR bp.source_id = $3)
+      AND ($4::text = '' OR bp.ingestion_run_id::text = $4)
+      AND (COALESCE(cardinality($5::text[]), 0) = 0 OR p.name = ANY($5))
+    ORDER BY p.name, (b.image ->> 'created') ASC, b.version, bp.recorded_at, bp.event;`,
+		f.Digest, f.SourceType, f.SourceID, f.IngestionRunID, pq.Array(f.Packages))
+	if err != nil {
+		return nil, err
+	}
+	return collectRows(rows, func(rows *sql.Rows) (BundleProvenanceRecord, error) {
+		var r BundleProvenanceRecord
+		err := rows.Scan(&r.Package, &r.Version, &r.Digest,
+			&r.BundleID, &r.Event, &r.SourceType, &r.SourceID, &r.IngestionRunID, &r.RecordedAt)
+		return r, err
+	})
+}
+
+// RecordFetchFailure records that ingestion failed to fetch a reference
+// after ff.Attempts attempts, adding them to the attempts of any earlier
+// failure of the reference.
+func (q Query) RecordFetchFailure(ctx context.Context, ff *models.FetchFailure) error {
+	_, err := q.db.ExecContext(ctx, `
+	INSERT INTO fetch_failures (reference, error, attempts, source_type, source_id)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (reference) DO UPDATE SET
+		error = EXCLUDED.error,
+		attempts = fetch_failures.attempts + EXCLUDED.attempts,
+		source_type = EXCLUDED.source_type,
+		source_id = EXCLUDED.source_id,
+		last_attempt_at = NOW();`,
+		ff.Reference, ff.Error, ff.Attempts, ff.SourceType, ff.SourceID)
+	return err
+}
+
+// DeleteFetchFailure removes the fetch failure of a reference, once it has
+// been ingested. It is not an error if the reference has none.
+func (q Query) DeleteFetchFailure(ctx context.Context, reference string) error {
+	return deleteFetchFailure(ctx, q.db, reference)
+}
+
+func deleteFetchFailure(ctx context.Context, db execer, reference string) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM fetch_failures WHERE reference = $1`, reference)
+	return err
+}
+
+// FetchFailureFilter selects the fetch failures listed by ListFetchFailures.
+// Empty fields select every failure.
+type FetchFailureFilter struct {
+	SourceType string
+	SourceID   string
+
+	// AttemptedBefore selects the failures whose last attempt was before
+	// it.
+	AttemptedBefore time.Time
+
+	// Limit is the maximum number of failures to list, or 0 for all.
+	Limit int
+}
+
+// ListFetchFailures returns the fetch failures that match the filter, least
+// recently attempted first.
+func (q Query) ListFetchFailures(ctx context.Context, f FetchFailureFilter) ([]models.FetchFailure, error) {
+	var attemptedBefore sql.NullTime
+	if !f.AttemptedBefore.IsZero() {
+		attemptedBefore = sql.NullTime{Time: f.AttemptedBefore, Valid: true}
+	}
+	var limit sql.NullInt64
+	if f.Limit > 0 {
+		limit = sql.NullInt64{Int64: int64(f.Limit), Valid: true}
+	}
+	rows, err := q.db.QueryContext(ctx, `
+    SELECT reference, error, attempts, source_type, source_id, first_failed_at, last_attempt_at
+    FROM fetch_failures
+    WHERE ($1::text = '' OR source_type = $1)
+      AND ($2::text = '' OR source_id = $2)
+      AND ($3::timestamptz IS NULL OR last_attempt_at < $3)
+    ORDER BY last_attempt_at, reference
+    LIMIT $4;`,
+		f.SourceType, f.SourceID, attemptedBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows(rows, func(rows *sql.Rows) (models.FetchFailure, error) {
+		var ff models.FetchFailure
+		err := rows.Scan(&ff.Reference, &ff.Error, &ff.Attempts, &ff.SourceType, &ff.SourceID, &ff.FirstFailedAt, &ff.LastAttemptAt)
+		return ff, err
+	})
+}
+
+// GetPackageContentHash returns a hash of the digests of every bundle in the
+// package. The hash changes whenever a bundle is added to or removed from the package.
+func (q Query) GetPackageContentHash(ctx context.Context, name string) (string, error) {
+	var hash sql.NullString
+	if err := q.db.QueryRowContext(ctx, `
+    SELECT
+        md5(string_agg(b.descriptor ->> 'digest', ',' ORDER BY b.descriptor ->> 'digest'))
+    FROM bundles AS b
+    JOIN packages AS p
+        ON b.package_id = p.id
+    WHERE p.name = $1;`, name).Scan(&hash); err != nil {
+		return "", err
+	}
+	return hash.String, nil
+}
+
+func (q Query) GetCatalog(ctx context.Context, name, tag string) (*models.Catalog, error) {
+	return catalogFromRow(q.db.QueryRowContext(ctx, `SELECT `+catalogColumns+` FROM catalogs WHERE name = $1 AND tag = $2`, name, tag))
+}
+
+// SetCatalogDigestDefaultChannels records the default channel of each
+// package in a catalog digest, keyed by package name, replacing any that were
+// recorded before.
+func (q Query) SetCatalogDigestDefaultChannels(ctx context.Context, cd *models.CatalogDigest, channels map[string]string) error {
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	if err := func() error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM catalog_digest_default_channels WHERE catalog_digest_id = $1`, cd.ID); err != nil {
+			return fmt.Errorf("error deleting default channels: %w", err)
+		}
+		for pkgName, channel := range channels {
+			if _, err := tx.ExecContext(ctx, `INSERT INTO catalog_digest_default_channels (catalog_digest_id, package_name, default_channel) VALUES ($1, $2, $3)`,
+				cd.ID, pkgName, channel); err != nil {
+				return fmt.Errorf("error inserting default channel of package %s: %w", pkgName, err)
+			}
+		}
+		return nil
+	}(); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	return tx.Commit()
+}
+
+// GetDefaultChannelsInCatalog returns the default channel of each package in
+// the most recently ingested digest of the catalog, keyed by package name.
+// Packages whose default channel was not recorded are omitted.
+func (q Query) GetDefaultChannelsInCatalog(ctx context.Context, c *models.Catalog) (map[string]string, error) {
+	rows, err := q.db.QueryContext(ctx, `
+    WITH latest_digest AS (
+        SELECT id FROM catalog_digests WHERE catalog_id = $1 ORDER BY created_at DESC LIMIT 1
+    )
+    SELECT dc.package_name, dc.default_channel
+    FROM latest_digest AS ld
+    JOIN catalog_digest_default_channels AS dc
+        ON dc.catalog_digest_id = ld.id;`, c.ID)
+	if err != nil {
+		return nil, err
+	}
+	type defaultChannel struct {
+		pkgName, channel string
+	}
+	all, err := collectRows(rows, func(rows *sql.Rows) (defaultChannel, error) {
+		var dc defaultChannel
+		err := rows.Scan(&dc.pkgName, &dc.channel)
+		return dc, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	channels := make(map[string]string, len(all))
+	for _, dc := range all {
+		channels[dc.pkgName] = dc.channel
+	}
+	return channels, nil
+}
+
+// TagDefaultChannel is the default channel of a package in a tag of a
+// catalog.
+type TagDefaultChannel struct {
+	Tag            string
+	Package        string
+	DefaultChannel string
+}
+
+// ListDefaultChannelsByTag returns the default channel of each package in the
+// most recently ingested digest of each tag of the named catalog, ordered by
+// package and tag. Only the packages in packageNames are listed, if any are
+// given. Tags are ordered as strings, so v4.10 comes before v4.9.
+func (q Query) ListDefaultChannelsByTag(ctx context.Context, catalogName string, packageNames []string) ([]TagDefaultChannel, error) {
+	rows, err := q.db.QueryContext(ctx, `
+    WITH latest_digests AS (
+        SELECT DISTINCT ON (cd.catalog_id) c.tag, cd.id
+        FROM catalogs AS c
+        JOIN catalog_digests AS cd ON cd.catalog_id = c.id
+        WHERE c.name = $1
+        ORDER BY cd.catalog_id, cd.created_at DESC
+    )
+    SELECT ld.tag, dc.package_name, dc.default_channel
+    FROM latest_digests AS ld
+    JOIN catalog_digest_default_channels AS dc
+        ON dc.catalog_digest_id = ld.id
+    WHERE COALESCE(cardinality($2::text[]), 0) = 0 OR dc.package_name = ANY($2)
+    ORDER BY dc.package_name, ld.tag;`, catalogName, pq.Array(packageNames))
+	if err != nil {
+		return nil, err
+	}
+	return collectRows(rows, func(rows *sql.Rows) (TagDefaultChannel, error) {
+		var dc TagDefaultChannel
+		err := rows.Scan(&dc.Tag, &dc.Package, &dc.DefaultChannel)
+		return dc, err
+	})
+}
+
+// PackageCoverage is whether a package ships in a tag of a catalog, such as
+// an OpenShift version of an operator index.
+type PackageCoverage struct {
+	Catalog string
+	Tag     string
+
+	// Exists reports whether the most recently ingested digest of the tag
+	// references a bundle of the package. It is false for tags that were
+	// never ingested.
+	Exists bool
+
+	// HeadVersion is the highest version of the package's bundles in the
+	// tag, with its release, if any. It is empty if the package doesn't
+	// exist in the tag.
+	HeadVersion string
+}
+
+// GetPackagePlatformCoverage returns whether the named package ships in each
+// tag of each catalog, and its head version there, ordered by catalog name
+// and tag. Tags that are versions, such as v4.12 and v4.19, are ordered
+// numerically, so the tag in which a package stopped shipping is the first
+// that follows its last Exists.
+func (q Query) GetPackagePlatformCoverage(ctx context.Context, name string) ([]PackageCoverage, error) {
+	rows, err := q.db.QueryContext(ctx, `
+    WITH latest_digests AS (
+        SELECT DISTINCT ON (catalog_id) catalog_id, id
+        FROM catalog_digests
+        ORDER BY catalog_id, created_at DESC
+    ), package_bundles AS (
+        SELECT DISTINCT ld.catalog_id, b.version, b.release
+        FROM latest_digests AS ld
+        JOIN catalog_digest_bundle_references AS cdbr ON cdbr.catalog_digest_id = ld.id
+        JOIN bundle_reference_bundles AS brb ON brb.bundle_reference_id = cdbr.bundle_reference_id
+        JOIN bundles AS b ON brb.bundle_id = b.id
+        JOIN packages AS p ON b.package_id = p.id
+        WHERE p.name = $1
+    )
+    SELECT c.name, c.tag, pb.version, pb.release
+    FROM catalogs AS c
+    LEFT JOIN package_bundles AS pb ON pb.catalog_id = c.id;`, name)
+	if err != nil {
+		return nil, err
+	}
+	type catalogTag struct{ name, tag string }
+	type coverageRow struct {
+		catalogTag
+		version, release sql.NullString
+	}
+	coverageRows, err := collectRows(rows, func(rows *sql.Rows) (coverageRow, error) {
+		var r coverageRow
+		err := rows.Scan(&r.name, &r.tag, &r.version, &r.release)
+		return r, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		tags  []catalogTag
+		heads = map[catalogTag]*graph.VersionRelease{}
+	)
+	for _, r := range coverageRows {
+		head, seen := heads[r.catalogTag]
+		if !seen {
+			tags = append(tags, r.catalogTag)
+		}
+		if !r.version.Valid {
+			heads[r.catalogTag] = nil
+			continue
+		}
+		// Stored versions are valid semver, as the bundles table requires.
+		v, _ := semver.Parse(r.version.String)
+		vr := graph.VersionRelease{Version: v, Release: r.release.String}
+		if head == nil || vr.Compare(*head) > 0 {
+			heads[r.catalogTag] = &vr
+		}
+	}
+
+	slices.SortFunc(tags, func(a, b catalogTag) int {
+		return cmp.Or(cmp.Compare(a.name, b.name), compareTags(a.tag, b.tag))
+	})
+	coverage := make([]PackageCoverage, 0, len(tags))
+	for _, ct := range tags {
+		pc := PackageCoverage{Catalog: ct.name, Tag: ct.tag}
+		if head := heads[ct]; head != nil {
+			pc.Exists = true
+			pc.HeadVersion = head.String()
+		}
+		coverage = append(coverage, pc)
+	}
+	return coverage, nil
+}
+
+// compareTags orders catalog tags that are versions numerically, and other
+// tags as strings.
+func compareTags(a, b string) int {
+	av, aErr := semver.ParseTolerant(a)
+	bv, bErr := semver.ParseTolerant(b)
+	if aErr != nil || bErr != nil {
+		return cmp.Compare(a, b)
+	}
+	return av.Compare(bv)
+}
+
+// CatalogBundle is a bundle that ships in a catalog, along with the name of its
+// package and the image reference the catalog uses for it.
+type CatalogBundle struct {
+	PackageName string
+	Bundle      *models.Bundle
+	Image       string
+}
+
+// GetBundlesInCatalog returns the bundles referenced by the most recently
+// ingested digest of the catalog, ordered by package name and build time. If
+// packageNames is non-empty, only bundles from those packages are returned.
+func (q Query) GetBundlesInCatalog(ctx context.Context, c *models.Catalog, packageNames []string) ([]CatalogBundle, error) {
+	rows, err := q.db.QueryContext(ctx, `
+    WITH latest_digest AS (
+        SELECT id FROM catalog_digests WHERE catalog_id = $1 ORDER BY created_at DESC LIMIT 1
+    )
+    SELECT
+        p.name,
+        b.id, b.package_id, b.descriptor, b.index, b.manifest, b.image, b.version, b.release, b.created_at, b.csv_digest,
+        (br.repo || '@' || br.digest)
+    FROM latest_digest AS ld
+    JOIN catalog_digest_bundle_references AS cdbr
+        ON cdbr.catalog_digest_id = ld.id
+    JOIN bundle_references AS br
+        ON cdbr.bundle_reference_id = br.id
+    JOIN bundle_reference_bundles AS brb
+        ON brb.bundle_reference_id = br.id
+    JOIN bundles AS b
+        ON brb.bundle_id = b.id
+    JOIN packages AS p
+        ON b.package_id = p.id
+    WHERE cardinality($2::text[]) = 0 OR p.name = ANY($2::text[])
+    ORDER BY p.name, (b.image ->> 'created') ASC;`, c.ID, pq.Array(packageNames))
+	if err != nil {
+		return nil, err
+	}
+	bundles, err := collectRows(rows, func(rows *sql.Rows) (CatalogBundle, error) {
+		var (
+			cb CatalogBundle
+			b  models.Bundle
+		)
+		if err := rows.Scan(
+			&cb.PackageName,
+			&b.ID,
+			&b.PackageID,
+			&b.Descriptor,
+			&b.Index,
+			&b.Manifest,
+			&b.Image,
+			&b.Version,
+			&b.Release,
+			&b.CreatedAt,
+			&b.CSVDigest,
+			&cb.Image); err != nil {
+			return CatalogBundle{}, err
+		}
+		cb.Bundle = &b
+		return cb, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(bundles, func(a, b CatalogBundle) int {
+		return cmp.Or(cmp.Compare(a.PackageName, b.PackageName), compareBundles(a.Bundle, b.Bundle))
+	})
+	return bundles, nil
+}
+
+// BundleTimeline holds when a bundle was built and when it first shipped in
+// any catalog.
+type BundleTimeline struct {
+	PackageName string
+	Version     string
+
+	// BuiltAt is the creation time in the bundle's image config.
+	BuiltAt sql.NullTime
+
+	// FirstCatalogedAt is when the first ingested catalog digest that
+	// references the bundle was recorded. It is null if no catalog has
+	// referenced the bundle.
+	FirstCatalogedAt sql.NullTime
+}
+
+// ListBundleTimelines returns the timeline of every bundle, ordered by
+// package name and build time.
+func (q Query) ListBundleTimelines(ctx context.Context) ([]BundleTimeline, error) {
+	rows, err := q.db.QueryContext(ctx, `
+    SELECT
+        p.name,
+        b.version,
+        (b.image ->> 'created')::timestamptz AS built_at,
+        MIN(cd.created_at) AS first_cataloged_at
+    FROM bundles AS b
+    JOIN packages AS p
+        ON b.package_id = p.id
+    LEFT JOIN bundle_reference_bundles AS brb
+        ON brb.bundle_id = b.id
+    LEFT JOIN catalog_digest_bundle_references AS cdbr
+        ON cdbr.bundle_reference_id = brb.bundle_reference_id
+    LEFT JOIN catalog_digests AS cd
+        ON cd.id = cdbr.catalog_digest_id
+    GROUP BY p.name, b.id, b.version, built_at
+    ORDER BY p.name, built_at ASC;`)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows(rows, func(rows *sql.Rows) (BundleTimeline, error) {
+		var bt BundleTimeline
+		err := rows.Scan(&bt.PackageName, &bt.Version, &bt.BuiltAt, &bt.FirstCatalogedAt)
+		return bt, err
+	})
+}
+
+// UnreferencedImage is a stored bundle image that no catalog ships in its
+// latest ingested contents.
+type UnreferencedImage struct {
+	Repo        string
+	Digest      string
+	PackageName sql.NullString
+	Version     string
+
+	// BuiltAt is the creation time in the bundle's image config.
+	BuiltAt sql.NullTime
+
+	// LastShippedAt is when the last ingested catalog digest that contains
+	// the image was recorded. It is null if no catalog has contained it.
+	LastShippedAt sql.NullTime
+}
+
+// ListUnreferencedImages returns the bundle images that are in the latest
+// ingested digest of no catalog, were built before cutoff, and have not
+// shipped in any catalog since cutoff, ordered by repository and build time.
+// An image counts as shipping if its digest ships from any repository, since
+// mirrors share digests.
+func (q Query) ListUnreferencedImages(ctx context.Context, cutoff time.Time) ([]UnreferencedImage, error) {
+	rows, err := q.db.QueryContext(ctx, `
+    WITH latest_digests AS (
+        SELECT DISTINCT ON (catalog_id) id
+        FROM catalog_digests
+        ORDER BY catalog_id, created_at DESC
+    )
+    SELECT DISTINCT
+        br.repo,
+        br.digest,
+        p.name,
+        b.version,
+        (b.image ->> 'created')::timestamptz AS built_at,
+        shipped.last_shipped_at
+    FROM bundle_references AS br
+    JOIN bundle_reference_bundles AS brb
+        ON brb.bundle_reference_id = br.id
+    JOIN bundles AS b
+        ON b.id = brb.bundle_id
+    LEFT JOIN packages AS p
+        ON p.id = b.package_id
+    CROSS JOIN LATERAL (
+        SELECT MAX(cd.created_at) AS last_shipped_at
+        FROM bundle_references AS sbr
+        JOIN catalog_digest_bundle_references AS cdbr
+            ON cdbr.bundle_reference_id = sbr.id
+        JOIN catalog_digests AS cd
+            ON cd.id = cdbr.catalog_digest_id
+        WHERE sbr.digest = br.digest
+    ) AS shipped
+    WHERE br.digest IS NOT NULL
+        AND (b.image ->> 'created')::timestamptz < $1
+        AND (shipped.last_shipped_at IS NULL OR shipped.last_shipped_at < $1)
+        AND NOT EXISTS (
+            SELECT 1
+            FROM bundle_references AS sbr
+            JOIN catalog_digest_bundle_references AS cdbr
+                ON cdbr.bundle_reference_id = sbr.id
+            JOIN latest_digests AS ld
+                ON ld.id = cdbr.catalog_digest_id
+            WHERE sbr.digest = br.digest
+        )
+    ORDER BY br.repo, built_at, br.digest;`, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows(rows, func(rows *sql.Rows) (UnreferencedImage, error) {
+		var ui UnreferencedImage
+		err := rows.Scan(&ui.Repo, &ui.Digest, &ui.PackageName, &ui.Version, &ui.BuiltAt, &ui.LastShippedAt)
+		return ui, err
+	})
+}
+
+// RecordCatalogIngestion records that the catalog was ingested successfully
+// with the given digest. The digest's change time is kept unless the digest
+// differs from the one previously recorded.
+func (q Query) RecordCatalogIngestion(ctx context.Context, c *models.Catalog, digest string) error {
+	_, err := q.db.ExecContext(ctx, `INSERT INTO catalog_freshness (catalog_id, digest) VALUES ($1, $2)
+	ON CONFLICT (catalog_id) DO UPDATE SET
+		digest_changed_at = CASE
+			WHEN catalog_freshness.digest = EXCLUDED.digest THEN catalog_freshness.digest_changed_at
+			ELSE NOW()
+		END,
+		digest = EXCLUDED.digest,
+		ingested_at = NOW();`, c.ID, digest)
+	return err
+}
+
+// GetCatalogIngestedDigest returns the digest with which the catalog was
+// last ingested successfully, or sql.ErrNoRows if it never was.
+func (q Query) GetCatalogIngestedDigest(ctx context.Context, c *models.Catalog) (string, error) {
+	var digest string
+	err := q.db.QueryRowContext(ctx, `SELECT digest FROM catalog_freshness WHERE catalog_id = $1`, c.ID).Scan(&digest)
+	return digest, err
+}
+
+// CatalogFreshness is when a catalog's digest last changed and when the
+// catalog was last ingested successfully. The fields are null for catalogs
+// that have never been ingested successfully.
+type CatalogFreshness struct {
+	Name string
+	Tag  string
+
+	Digest          sql.NullString
+	DigestChangedAt sql.NullTime
+	IngestedAt      sql.NullTime
+}
+
+// ListCatalogFreshness returns the freshness of every catalog, ordered by
+// name and tag.
+func (q Query) ListCatalogFreshness(ctx context.Context) ([]CatalogFreshness, error) {
+	rows, err := q.db.QueryContext(ctx, `
+    SELECT c.name, c.tag, cf.digest, cf.digest_changed_at, cf.ingested_at
+    FROM catalogs AS c
+    LEFT JOIN catalog_freshness AS cf
+        ON cf.catalog_id = c.id
+    ORDER BY c.name, c.tag;`)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows(rows, func(rows *sql.Rows) (CatalogFreshness, error) {
+		var cf CatalogFreshness
+		err := rows.Scan(&cf.Name, &cf.Tag, &cf.Digest, &cf.DigestChangedAt, &cf.IngestedAt)
+		return cf, err
+	})
+}
+
+// ListBundlesForLivenessCheck lists the bundles with at least one reference
+// whose descriptors have one of the media types, least recently checked
+// first. Bundles that have never been checked come first. If limit is zero,
+// every such bundle is listed.
+func (q Query) ListBundlesForLivenessCheck(ctx context.Context, mediaTypes []string, limit int) ([]*models.Bundle, error) {
+	var limitArg sql.NullInt64
+	if limit > 0 {
+		limitArg = sql.NullInt64{Int64: int64(limit), Valid: true}
+	}
+	rows, err := q.db.QueryContext(ctx, `
+    SELECT
+        b.id, b.package_id, b.descriptor, b.index, b.manifest, b.image, b.version, b.release, b.created_at, b.csv_digest
+    FROM bundles AS b
+    LEFT JOIN bundle_liveness AS bl ON bl.bundle_id = b.id
+    WHERE b.descriptor ->> 'mediaType' = ANY($1)
+        AND EXISTS (SELECT 1 FROM bundle_reference_bundles AS brb WHERE brb.bundle_id = b.id)
+    ORDER BY bl.checked_at ASC NULLS FIRST, b.id
+    LIMIT $2;`, pq.Array(mediaTypes), limitArg)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows(rows, scanBundle)
+}
+
+// SetBundleLiveness records the result of checking a bundle's image in its
+// registries, replacing any earlier result. The bundle's last verified time
+// is only updated when it is live.
+func (q Query) SetBundleLiveness(ctx context.Context, bl *models.BundleLiveness) error {
+	_, err := q.db.ExecContext(ctx, `INSERT INTO bundle_liveness (
+		bundle_id, status, message, last_verified_at
+	) VALUES ($1, $2, $3, CASE WHEN $2 = 'live' THEN NOW() END)
+	ON CONFLICT (bundle_id) DO UPDATE SET
+		status = EXCLUDED.status,
+		message = EXCLUDED.message,
+		checked_at = NOW(),
+		last_verified_at = COALESCE(EXCLUDED.last_verified_at, bundle_liveness.last_verified_at);`,
+		bl.BundleID, bl.Status, bl.Message)
+	return err
+}
+
+// BundleLivenessResult is the latest liveness check of a bundle.
+type BundleLivenessResult struct {
+	PackageName string
+	Version     string
+	Digest      string
+
+	models.BundleLiveness
+}
+
+// ListBundleLiveness lists the latest liveness check of every checked bundle
+// with one of the statuses, or of every checked bundle if statuses is empty,
+// ordered by package and build time.
+func (q Query) ListBundleLiveness(ctx context.Context, statuses []string) ([]BundleLivenessResult, error) {
+	rows, err := q.db.QueryContext(ctx, `
+    SELECT
+        p.name, b.version, b.descriptor ->> 'digest',
+        bl.bundle_id, bl.status, bl.message, bl.checked_at, bl.last_verified_at
+    FROM bundle_liveness AS bl
+    JOIN bundles AS b ON b.id = bl.bundle_id
+    JOIN packages AS p ON p.id = b.package_id
+    WHERE COALESCE(cardinality($1::text[]), 0) = 0 OR bl.status = ANY($1)
+    ORDER BY p.name, (b.image ->> 'created') ASC;`, pq.Array(statuses))
+	if err != nil {
+		return nil, err
+	}
+	return collectRows(rows, func(rows *sql.Rows) (BundleLivenessResult, error) {
+		var r BundleLivenessResult
+		err := rows.Scan(&r.PackageName, &r.Version, &r.Digest,
+			&r.BundleID, &r.Status, &r.Message, &r.CheckedAt, &r.LastVerifiedAt)
+		return r, err
+	})
+}
+
+// SetVersionStreams replaces the version streams of a package, and their
+// lifecycle dates, with streams.
+func (q Query) SetVersionStreams(ctx context.Context, pkg string, streams []graph.VersionStream) error {
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	if err := func() error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM version_streams WHERE package_name = $1`, pkg); err != nil {
+			return fmt.Errorf("error deleting version streams: %w", err)
+		}
+		for _, vs := range streams {
+			var id string
+			if err := tx.QueryRowContext(ctx, `INSERT INTO version_streams (
+				package_name, version, minimum_update_version, supported_platform_versions, requires_update_platform_versions
+			) VALUES ($1, $2, $3, $4, $5) RETURNING id;`,
+				pkg,
+				vs.Version.String(),
+				vs.MinimumUpdateVersion.String(),
+				pq.Array(majorMinorStrings(vs.SupportedPlatformVersions)),
+				pq.Array(majorMinorStrings(vs.RequiresUpdatePlatformVersions)),
+			).Scan(&id); err != nil {
+				return fmt.Errorf("error inserting version stream %s: %w", vs.Version, err)
+			}
+
+			dates := vs.LifecycleDates
+			extensions := make([]string, 0, len(dates.Extensions))
+			for _, d := range dates.Extensions {
+				extensions = append(extensions, dateString(d))
+			}
+			if _, err := tx.ExecContext(ctx, `INSERT INTO lifecycle_dates (
+				version_stream_id, full_support, maintenance, extensions, end_of_life
+			) VALUES ($1, $2, $3, $4::date[], $5);`,
+				id,
+				dateString(dates.FullSupport),
+				dateString(dates.Maintenance),
+				pq.Array(extensions),
+				dateString(dates.EndOfLife),
+			); err != nil {
+				return fmt.Errorf("error inserting lifecycle dates of version stream %s: %w", vs.Version, err)
+			}
+		}
+		return nil
+	}(); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	return tx.Commit()
+}
+
+// ListVersionStreams returns the version streams of every package as
+// templates without images, ordered by package name, with each package's
+// streams ordered by version.
+func (q Query) ListVersionStreams(ctx context.Context) ([]graph.Template, error) {
+	rows, err := q.db.QueryContext(ctx, `
+    SELECT
+        vs.package_name, vs.version, vs.minimum_update_version,
+        vs.supported_platform_versions, vs.requires_update_platform_versions,
+        ld.full_support::text, ld.maintenance::text, ld.extensions::text[], ld.end_of_life::text
+    FROM version_streams AS vs
+    JOIN lifecycle_dates AS ld ON ld.version_stream_id = vs.id
+    ORDER BY vs.package_name;`)
+	if err != nil {
+		return nil, err
+	}
+	results, err := collectRows(rows, func(rows *sql.Rows) (versionStreamRow, error) {
+		var r versionStreamRow
+		err := rows.Scan(&r.pkg, &r.version, &r.minimumUpdateVersion,
+			pq.Array(&r.supported), pq.Array(&r.requiresUpdate),
+			&r.fullSupport, &r.maintenance, pq.Array(&r.extensions), &r.endOfLife)
+		return r, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var templates []graph.Template
+	for _, r := range results {
+		vs, err := r.versionStream()
+		if err != nil {
+			return nil, fmt.Errorf("invalid version stream %s of package %s: %w", r.version, r.pkg, err)
+		}
+		if len(templates) == 0 || templates[len(templates)-1].Name != r.pkg {
+			templates = append(templates, graph.Template{Schema: graph.SchemaCincinnati, Name: r.pkg})
+		}
+		tmpl := &templates[len(templates)-1]
+		tmpl.VersionStreams = append(tmpl.VersionStreams, vs)
+	}
+	for _, tmpl := range templates {
+		slices.SortFunc(tmpl.VersionStreams, func(a, b graph.VersionStream) int {
+			return a.Version.Compare(b.Version)
+		})
+	}
+	return templates, nil
+}
+
+// GetLifecycleDates returns the lifecycle dates of a version stream of a
+// package. It returns sql.ErrNoRows if the stream is not stored.
+func (q Query) GetLifecycleDates(ctx context.Context, pkg string, stream graph.MajorMinor) (graph.LifecycleDates, error) {
+	var r versionStreamRow
+	if err := q.db.QueryRowContext(ctx, `
+    SELECT ld.full_support::text, ld.maintenance::text, ld.extensions::text[], ld.end_of_life::text
+    FROM version_streams AS vs
+    JOIN lifecycle_dates AS ld ON ld.version_stream_id = vs.id
+    WHERE vs.package_name = $1 AND vs.version = $2;`, pkg, stream.String(),
+	).Scan(&r.fullSupport, &r.maintenance, pq.Array(&r.extensions), &r.endOfLife); err != nil {
+		return graph.LifecycleDates{}, err
+	}
+	dates, err := r.lifecycleDates()
+	if err != nil {
+		return graph.LifecycleDates{}, fmt.Errorf("invalid lifecycle dates of version stream %s of package %s: %w", stream, pkg, err)
+	}
+	return dates, nil
+}
+
+type versionStreamRow struct {
+	pkg, version, minimumUpdateVersion    string
+	supported, requiresUpdate, extensions []string
+	fullSupport, maintenance, endOfLife   string
+}
+
+func (r versionStreamRow) versionStream() (graph.VersionStream, error) {
+	var (
+		vs  graph.VersionStream
+		err error
+	)
+	if vs.Version, err = graph.NewMajorMinorFromString(r.version); err != nil {
+		return vs, err
+	}
+	if vs.MinimumUpdateVersion, err = semver.Parse(r.minimumUpdateVersion); err != nil {
+		return vs, err
+	}
+	if vs.SupportedPlatformVersions, err = parseMajorMinors(r.supported); err != nil {
+		return vs, err
+	}
+	if vs.RequiresUpdatePlatformVersions, err = parseMajorMinors(r.requiresUpdate); err != nil {
+		return vs, err
+	}
+	if vs.LifecycleDates, err = r.lifecycleDates(); err != nil {
+		return vs, err
+	}
+	return vs, nil
+}
+
+func (r versionStreamRow) lifecycleDates() (graph.LifecycleDates, error) {
+	var (
+		dates graph.LifecycleDates
+		err   error
+	)
+	if dates.FullSupport, err = parseDate(r.fullSupport); err != nil {
+		return dates, err
+	}
+	if dates.Maintenance, err = parseDate(r.maintenance); err != nil {
+		return dates, err
+	}
+	if dates.EndOfLife, err = parseDate(r.endOfLife); err != nil {
+		return dates, err
+	}
+	for _, e := range r.extensions {
+		d, err := parseDate(e)
+		if err != nil {
+			return dates, err
+		}
+		dates.Extensions = append(dates.Extensions, d)
+	}
+	return dates, nil
+}
+
+func majorMinorStrings(mms []graph.MajorMinor) []string {
+	s := make([]string, 0, len(mms))
+	for _, mm := range mms {
+		s = append(s, mm.String())
+	}
+	return s
+}
+
+func parseMajorMinors(s []string) ([]graph.MajorMinor, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+	mms := make([]graph.MajorMinor, 0, len(s))
+	for _, v := range s {
+		mm, err := graph.NewMajorMinorFromString(v)
+		if err != nil {
+			return nil, err
+		}
+		mms = append(mms, mm)
+	}
+	return mms, nil
+}
+
+func dateString(d graph.Date) string {
+	return d.Time().Format(time.DateOnly)
+}
+
+func parseDate(s string) (graph.Date, error) {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return graph.Date{}, err
+	}
+	return graph.NewDate(t.Date()), nil
+}
+
+// GetCachedGraph returns the encoded graph cached for key by an ingestion
+// run. It returns sql.ErrNoRows if there is none.
+func (q Query) GetCachedGraph(ctx context.Context, runID, key string) ([]byte, error) {
+	var data []byte
+	if err := q.db.QueryRowContext(ctx, `SELECT data FROM graph_cache WHERE ingestion_run_id = $1 AND key = $2`, runID, key).Scan(&data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// PutCachedGraph caches an encoded graph for key by an ingestion run,
+// replacing the graph cached for it before, and drops the graphs cached by
+// every other run, which no later build can use.
+func (q Query) PutCachedGraph(ctx context.Context, runID, key string, data []byte) error {
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	if err := func() error {
+		if _, err := tx.ExecContext(ctx, `
+    INSERT INTO graph_cache (ingestion_run_id, key, data) VALUES ($1, $2, $3)
+    ON CONFLICT (ingestion_run_id, key) DO UPDATE SET data = EXCLUDED.data, created_at = NOW();`, runID, key, data); err != nil {
+			return fmt.Errorf("error caching graph: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM graph_cache WHERE ingestion_run_id <> $1`, runID); err != nil {
+			return fmt.Errorf("error dropping graphs cached by earlier runs: %w", err)
+		}
+		return nil
+	}(); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	return tx.Commit()
+}
+
+// MinimumUpdateVersionOverride overrides the minimum update version of a
+// package's version stream.
+type MinimumUpdateVersionOverride struct {
+	PackageName          string
+	Stream               graph.MajorMinor
+	MinimumUpdateVersion semver.Version
+	UpdatedAt            time.Time
+}
+
+// SetMinimumUpdateVersionOverride sets the minimum update version of a
+// package's version stream, replacing any override already set for it.
+func (q Query) SetMinimumUpdateVersionOverride(ctx context.Context, pkg string, stream graph.MajorMinor, v semver.Version) error {
+	_, err := q.db.ExecContext(ctx, `
+    INSERT INTO minimum_update_version_overrides (package_name, version, minimum_update_version)
+    VALUES ($1, $2, $3)
+    ON CONFLICT (package_name, version) DO UPDATE
+    SET minimum_update_version = EXCLUDED.minimum_update_version, updated_at = NOW();`,
+		pkg, stream.String(), v.String())
+	return err
+}
+
+// DeleteMinimumUpdateVersionOverride removes the override of the minimum
+// update version of a package's version stream. It reports whether there
+// was one.
+func (q Query) DeleteMinimumUpdateVersionOverride(ctx context.Context, pkg string, stream graph.MajorMinor) (bool, error) {
+	res, err := q.db.ExecContext(ctx, `DELETE FROM minimum_update_version_overrides WHERE package_name = $1 AND version = $2`, pkg, stream.String())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ListMinimumUpdateVersionOverrides returns the minimum update version
+// overrides of the packages, or of every package if none are given, ordered
+// by package name and stream.
+func (q Query) ListMinimumUpdateVersionOverrides(ctx context.Context, packageNames ...string) ([]MinimumUpdateVersionOverride, error) {
+	rows, err := q.db.QueryContext(ctx, `
+    SELECT package_name, version, minimum_update_version, updated_at
+    FROM minimum_update_version_overrides
+    WHERE COALESCE(cardinality($1::text[]), 0) = 0 OR package_name = ANY($1)
+    ORDER BY package_name;`, pq.Array(packageNames))
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := collectRows(rows, func(rows *sql.Rows) (MinimumUpdateVersionOverride, error) {
+		var (
+			o                  MinimumUpdateVersionOverride
+			stream, minVersion string
+		)
+		if err := rows.Scan(&o.PackageName, &stream, &minVersion, &o.UpdatedAt); err != nil {
+			return o, err
+		}
+		var err error
+		if o.Stream, err = graph.NewMajorMinorFromString(stream); err != nil {
+			return o, fmt.Errorf("invalid stream %q of package %s: %w", stream, o.PackageName, err)
+		}
+		if o.MinimumUpdateVersion, err = semver.Parse(minVersion); err != nil {
+			return o, fmt.Errorf("invalid minimum update version %q of stream %s of package %s: %w", minVersion, stream, o.PackageName, err)
+		}
+		return o, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortStableFunc(overrides, func(a, b MinimumUpdateVersionOverride) int {
+		return cmp.Or(cmp.Compare(a.PackageName, b.PackageName), a.Stream.Compare(b.Stream))
+	})
+	return overrides, nil
+}
+
+// LegacyImage is an image recorded in the image_configs table of the schema
+// that preceded the packages and bundles tables.
+type LegacyImage struct {
+	Reference string
+
+	// Catalogs are the catalogs that the ocp_catalog_references table
+	// recorded the image in.
+	Catalogs []LegacyCatalog
+}
+
+// LegacyCatalog is a catalog that an image was recorded in by the legacy
+// schema, for an OpenShift version.
+type LegacyCatalog struct {
+	Name       string
+	OCPVersion string
+}
+
+// HasLegacySchema reports whether the database has the image_configs table
+// of the schema that preceded the packages and bundles tables.
+func (q Query) HasLegacySchema(ctx context.Context) (bool, error) {
+	var exists bool
+	err := q.db.QueryRowContext(ctx, `SELECT to_regclass('image_configs') IS NOT NULL`).Scan(&exists)
+	return exists, err
+}
+
+// ListLegacyImages returns the images of the legacy schema, ordered by
+// reference, with the catalogs they were recorded in. Use HasLegacySchema
+// first; it fails if the database has no legacy tables.
+func (q Query) ListLegacyImages(ctx context.Context) ([]LegacyImage, error) {
+	rows, err := q.db.QueryContext(ctx, `
+    SELECT
+        ic.image_reference,
+        COALESCE(array_agg(ocr.catalog_name ORDER BY ocr.catalog_name, ocr.ocp_version) FILTER (WHERE ocr.id IS NOT NULL), '{}'),
+        COALESCE(array_agg(ocr.ocp_version ORDER BY ocr.catalog_name, ocr.ocp_version) FILTER (WHERE ocr.id IS NOT NULL), '{}')
+    FROM image_configs ic
+    LEFT JOIN ocp_catalog_references ocr ON ocr.image_config_id = ic.id
+    GROUP BY ic.image_reference
+    ORDER BY ic.image_reference;`)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows(rows, func(rows *sql.Rows) (LegacyImage, error) {
+		var (
+			img                    LegacyImage
+			catalogNames, versions []string
+		)
+		if err := rows.Scan(&img.Reference, pq.Array(&catalogNames), pq.Array(&versions)); err != nil {
+			return img, err
+		}
+		for i := range catalogNames {
+			img.Catalogs = append(img.Catalogs, LegacyCatalog{Name: catalogNames[i], OCPVersion: versions[i]})
+		}
+		return img, nil
+	})
+}
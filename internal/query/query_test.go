@@ -0,0 +1,1028 @@
+package query_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/internal/blobstore"
+	"github.com/joelanford/extensiondb/internal/models"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/internal/query/querytest"
+	"github.com/joelanford/extensiondb/pkg/dbtest"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.podman.io/image/v5/docker/reference"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(dbtest.Main(m))
+}
+
+func TestGetOrCreateCatalog(t *testing.T) {
+	q := query.New(dbtest.New(t))
+
+	c, err := q.GetOrCreateCatalog(t.Context(), "registry.example.com/index", "v1")
+	require.NoError(t, err)
+	again, err := q.GetOrCreateCatalog(t.Context(), "registry.example.com/index", "v1")
+	require.NoError(t, err)
+	assert.Equal(t, c.ID, again.ID)
+
+	got, err := q.GetCatalog(t.Context(), "registry.example.com/index", "v1")
+	require.NoError(t, err)
+	assert.Equal(t, c.ID, got.ID)
+
+	catalogs, err := q.ListCatalogs(t.Context())
+	require.NoError(t, err)
+	require.Len(t, catalogs, 1)
+	assert.Equal(t, "v1", catalogs[0].Tag)
+}
+
+func TestGetOrCreatePackage(t *testing.T) {
+	q := query.New(dbtest.New(t))
+
+	p, err := q.GetOrCreatePackage(t.Context(), "foo")
+	require.NoError(t, err)
+	again, err := q.GetOrCreatePackage(t.Context(), "foo")
+	require.NoError(t, err)
+	assert.Equal(t, p.ID, again.ID)
+
+	got, err := q.GetPackageByName(t.Context(), "foo")
+	require.NoError(t, err)
+	assert.Equal(t, p.ID, got.ID)
+
+	got, err = q.GetPackageByID(t.Context(), p.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "foo", got.Name)
+
+	_, err = q.GetPackageByName(t.Context(), "bar")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestGetOrCreatePackages(t *testing.T) {
+	q := query.New(dbtest.New(t))
+
+	foo, err := q.GetOrCreatePackage(t.Context(), "foo")
+	require.NoError(t, err)
+
+	pkgs, err := q.GetOrCreatePackages(t.Context(), []string{"foo", "bar", "bar", "baz"})
+	require.NoError(t, err)
+	require.Len(t, pkgs, 3)
+	assert.Equal(t, foo.ID, pkgs["foo"].ID)
+	for _, name := range []string{"bar", "baz"} {
+		got, err := q.GetPackageByName(t.Context(), name)
+		require.NoError(t, err)
+		assert.Equal(t, got.ID, pkgs[name].ID)
+	}
+
+	again, err := q.GetOrCreatePackages(t.Context(), []string{"baz", "foo"})
+	require.NoError(t, err)
+	assert.Equal(t, pkgs["baz"].ID, again["baz"].ID)
+	assert.Equal(t, foo.ID, again["foo"].ID)
+
+	none, err := q.GetOrCreatePackages(t.Context(), nil)
+	require.NoError(t, err)
+	assert.Empty(t, none)
+}
+
+func TestGetOrCreateBundleReferences(t *testing.T) {
+	q := query.New(dbtest.New(t))
+	ref := func(repo, s string) reference.Canonical {
+		named, err := reference.ParseNormalizedNamed(repo)
+		require.NoError(t, err)
+		r, err := reference.WithDigest(named, digest.FromString(s))
+		require.NoError(t, err)
+		return r
+	}
+	a, b, c := ref("quay.io/example/a", "a"), ref("quay.io/example/b", "b"), ref("quay.io/example/a", "c")
+
+	existing, err := q.GetOrCreateCanonicalBundleReference(t.Context(), a)
+	require.NoError(t, err)
+
+	brs, err := q.GetOrCreateBundleReferences(t.Context(), []reference.Canonical{b, a, c, b})
+	require.NoError(t, err)
+	require.Len(t, brs, 4)
+	assert.Equal(t, existing.ID, brs[1].ID)
+	assert.Equal(t, brs[0].ID, brs[3].ID)
+	assert.NotEqual(t, brs[0].ID, brs[2].ID)
+	for i, r := range []reference.Canonical{b, a, c, b} {
+		assert.Equal(t, r.Name(), brs[i].Repo)
+		assert.Equal(t, r.Digest().String(), brs[i].Digest.String)
+		assert.False(t, brs[i].Tag.Valid)
+	}
+
+	single, err := q.GetOrCreateCanonicalBundleReference(t.Context(), c)
+	require.NoError(t, err)
+	assert.Equal(t, brs[2].ID, single.ID)
+}
+
+func TestBundles(t *testing.T) {
+	db := dbtest.New(t)
+	q := query.New(db)
+	built := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	querytest.Bundle(t, db, "foo", "1.0.1", built.AddDate(0, 1, 0))
+	first := querytest.Bundle(t, db, "foo", "1.0.0", built)
+
+	got, err := q.GetBundleByDigest(t.Context(), querytest.BundleImage("foo", "1.0.0").Digest())
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, got.ID)
+	assert.Equal(t, "1.0.0", got.Version)
+
+	pkg, err := q.GetPackageByName(t.Context(), "foo")
+	require.NoError(t, err)
+	bundles, err := q.ListBundlesForPackage(t.Context(), pkg)
+	require.NoError(t, err)
+	require.Len(t, bundles, 2)
+	assert.Equal(t, "1.0.0", bundles[0].Version, "bundles are ordered by build time")
+	assert.Equal(t, "1.0.1", bundles[1].Version)
+
+	refs, err := q.GetBundleReferencesForBundle(t.Context(), first)
+	require.NoError(t, err)
+	require.Len(t, refs, 1)
+	assert.Equal(t, querytest.BundleImage("foo", "1.0.0").Digest().String(), refs[0].Digest.String)
+
+	packages, err := q.GetPackagesForRepository(t.Context(), querytest.BundleImage("foo", "1.0.0").Name())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"foo"}, packages)
+
+	hash, err := q.GetPackageContentHash(t.Context(), "foo")
+	require.NoError(t, err)
+	assert.NotEmpty(t, hash)
+}
+
+func TestCreateBundle(t *testing.T) {
+	db := dbtest.New(t)
+	q := query.New(db)
+	pkg, err := q.GetOrCreatePackage(t.Context(), "foo")
+	require.NoError(t, err)
+	newBundle := func(version string, props []models.BundleProperty) query.NewBundle {
+		ref := querytest.BundleImage("foo", version)
+		br, err := q.GetOrCreateCanonicalBundleReference(t.Context(), ref)
+		require.NoError(t, err)
+		require.NoError(t, q.RecordFetchFailure(t.Context(), &models.FetchFailure{Reference: ref.String(), Error: "timeout", Attempts: 4}))
+		return query.NewBundle{
+			Bundle: &models.Bundle{
+				PackageID: sql.NullString{String: pkg.ID, Valid: true},
+				Descriptor: models.JSONB[ocispec.Descriptor]{V: &ocispec.Descriptor{
+					MediaType: ocispec.MediaTypeImageManifest,
+					Digest:    ref.Digest(),
+					Size:      1,
+				}},
+				Manifest: models.JSONB[ocispec.Manifest]{V: &ocispec.Manifest{MediaType: ocispec.MediaTypeImageManifest}},
+				Image:    models.JSONB[ocispec.Image]{V: &ocispec.Image{}},
+				Version:  version,
+			},
+			Reference:          br,
+			Provenance:         &models.BundleProvenance{Event: "created", SourceType: models.SourceTypeCatalog, SourceID: "index:v1"},
+			FetchFailure:       ref.String(),
+			Properties:         props,
+			CSV:                []byte(`{"kind":"ClusterServiceVersion"}`),
+			QualityFlags:       []string{"missing-version"},
+			ContentFingerprint: digest.FromString(version),
+		}
+	}
+
+	nb := newBundle("1.0.0", []models.BundleProperty{{Type: "olm.maxOpenShiftVersion", Value: json.RawMessage(`"4.15"`)}})
+	require.NoError(t, q.CreateBundle(t.Context(), nb))
+	b, err := q.GetBundleByDigest(t.Context(), querytest.BundleImage("foo", "1.0.0").Digest())
+	require.NoError(t, err)
+	assert.Equal(t, nb.Bundle.ID, b.ID)
+	csv, err := q.GetBundleCSV(t.Context(), b)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"kind":"ClusterServiceVersion"}`, string(csv))
+	versions, err := q.GetMaxOpenShiftVersions(t.Context(), "foo")
+	require.NoError(t, err)
+	assert.Len(t, versions, 1)
+	records, err := q.ListBundleProvenance(t.Context(), query.BundleProvenanceFilter{})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, b.ID, records[0].BundleID)
+	flagged, err := q.ListFlaggedBundles(t.Context(), nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, flagged, 1)
+
+	// A failed write stores none of the bundle, and keeps its fetch failure.
+	nb = newBundle("1.0.1", []models.BundleProperty{{Type: "olm.maxOpenShiftVersion", Value: json.RawMessage(`{`)}})
+	require.Error(t, q.CreateBundle(t.Context(), nb))
+	_, err = q.GetBundleByDigest(t.Context(), querytest.BundleImage("foo", "1.0.1").Digest())
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	records, err = q.ListBundleProvenance(t.Context(), query.BundleProvenanceFilter{})
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
+	failures, err := q.ListFetchFailures(t.Context(), query.FetchFailureFilter{})
+	require.NoError(t, err)
+	require.Len(t, failures, 1)
+	assert.Equal(t, querytest.BundleImage("foo", "1.0.1").String(), failures[0].Reference)
+}
+
+func TestBundleCSV(t *testing.T) {
+	db := dbtest.New(t)
+	b := querytest.Bundle(t, db, "foo", "1.0.0", time.Now())
+	csv := []byte(`{"kind":"ClusterServiceVersion","metadata":{"name":"foo.v1.0.0"}}`)
+
+	for name, q := range map[string]*query.Query{
+		"large objects": query.New(db),
+		"directory":     query.New(db).WithBlobStore(blobstore.Dir(t.TempDir())),
+	} {
+		t.Run(name, func(t *testing.T) {
+			b := *b
+			_, err := q.GetBundleCSV(t.Context(), &b)
+			assert.ErrorIs(t, err, query.ErrNoCSV)
+
+			require.NoError(t, q.SetBundleCSV(t.Context(), &b, csv))
+			assert.Equal(t, digest.FromBytes(csv).String(), b.CSVDigest.String)
+
+			stored, err := q.GetBundleByDigest(t.Context(), querytest.BundleImage("foo", "1.0.0").Digest())
+			require.NoError(t, err)
+			assert.Equal(t, b.CSVDigest, stored.CSVDigest)
+			got, err := q.GetBundleCSV(t.Context(), stored)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(csv), string(got))
+		})
+	}
+
+	// Storing the same CSV again, such as for a rebuild, stores one blob.
+	require.NoError(t, query.New(db).SetBundleCSV(t.Context(), b, csv))
+	var blobs int
+	require.NoError(t, db.QueryRowContext(t.Context(), `SELECT COUNT(*) FROM blobs`).Scan(&blobs))
+	assert.Equal(t, 1, blobs)
+}
+
+func TestBundleQualityFlags(t *testing.T) {
+	db := dbtest.New(t)
+	q := query.New(db)
+	built := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	foo10 := querytest.Bundle(t, db, "foo", "1.10.0", built)
+	foo9 := querytest.Bundle(t, db, "foo", "1.9.0", built)
+	bar := querytest.Bundle(t, db, "bar", "1.0.0", built)
+	querytest.Bundle(t, db, "baz", "1.0.0", built)
+
+	require.NoError(t, q.SetBundleQualityFlags(t.Context(), foo10, []string{"missing-version", "invalid-install-modes"}))
+	require.NoError(t, q.SetBundleQualityFlags(t.Context(), foo9, []string{"broken-related-images"}))
+	require.NoError(t, q.SetBundleQualityFlags(t.Context(), bar, []string{}))
+
+	flagged, err := q.ListFlaggedBundles(t.Context(), nil, nil)
+	require.NoError(t, err)
+	require.Len(t, flagged, 2, "bundles without flags, or that were never checked, are not listed")
+	assert.Equal(t, "1.9.0", flagged[0].Version, "bundles are ordered by version")
+	assert.Equal(t, []string{"broken-related-images"}, flagged[0].Flags)
+	assert.Equal(t, querytest.BundleImage("foo", "1.9.0").Digest().String(), flagged[0].Digest)
+	assert.Equal(t, []string{"missing-version", "invalid-install-modes"}, flagged[1].Flags)
+
+	flagged, err = q.ListFlaggedBundles(t.Context(), []string{"invalid-install-modes"}, []string{"foo"})
+	require.NoError(t, err)
+	require.Len(t, flagged, 1)
+	assert.Equal(t, "1.10.0", flagged[0].Version)
+
+	flagged, err = q.ListFlaggedBundles(t.Context(), nil, []string{"bar"})
+	require.NoError(t, err)
+	assert.Empty(t, flagged)
+}
+
+func TestIdenticalRebuilds(t *testing.T) {
+	db := dbtest.New(t)
+	q := query.New(db)
+	foo := querytest.Bundle(t, db, "foo", "1.0.0", time.Now())
+	bar := querytest.Bundle(t, db, "bar", "1.0.0", time.Now())
+	pkg, err := q.GetPackageByName(t.Context(), "foo")
+	require.NoError(t, err)
+	rebuild := func(release string) *models.Bundle {
+		ref, err := reference.WithDigest(reference.TrimNamed(querytest.BundleImage("foo", "1.0.0")), digest.FromString("foo-1.0.0-"+release))
+		require.NoError(t, err)
+		br, err := q.GetOrCreateCanonicalBundleReference(t.Context(), ref)
+		require.NoError(t, err)
+		b := &models.Bundle{
+			PackageID: sql.NullString{String: pkg.ID, Valid: true},
+			Descriptor: models.JSONB[ocispec.Descriptor]{V: &ocispec.Descriptor{
+				MediaType: ocispec.MediaTypeImageManifest,
+				Digest:    ref.Digest(),
+				Size:      1,
+			}},
+			Manifest: models.JSONB[ocispec.Manifest]{V: &ocispec.Manifest{MediaType: ocispec.MediaTypeImageManifest}},
+			Image:    models.JSONB[ocispec.Image]{V: &ocispec.Image{}},
+			Version:  "1.0.0",
+			Release:  sql.NullString{String: release, Valid: true},
+		}
+		require.NoError(t, q.CreateBundleWithCatalogAndReference(t.Context(), b, nil, br))
+		return b
+	}
+	foo2, foo3 := rebuild("2"), rebuild("3")
+	rebuild("4")
+
+	same, changed := digest.FromString("same"), digest.FromString("changed")
+	for _, b := range []*models.Bundle{foo, foo3, bar} {
+		require.NoError(t, q.SetBundleContentFingerprint(t.Context(), b, same))
+	}
+	require.NoError(t, q.SetBundleContentFingerprint(t.Context(), foo2, changed))
+
+	rebuilds, err := q.ListIdenticalRebuilds(t.Context(), nil)
+	require.NoError(t, err)
+	require.Len(t, rebuilds, 2, "bundles are only identical to rebuilds of the same package and version, and bundles without fingerprints are never listed")
+	assert.Equal(t, querytest.BundleImage("foo", "1.0.0").Digest().String(), rebuilds[0].Digest)
+	assert.Equal(t, foo3.Descriptor.V.Digest.String(), rebuilds[1].Digest)
+	assert.Equal(t, sql.NullString{String: "3", Valid: true}, rebuilds[1].Release)
+	assert.Equal(t, same.String(), rebuilds[1].Fingerprint)
+
+	rebuilds, err = q.ListIdenticalRebuilds(t.Context(), []string{"bar"})
+	require.NoError(t, err)
+	assert.Empty(t, rebuilds)
+}
+
+func TestBundleProperties(t *testing.T) {
+	db := dbtest.New(t)
+	q := query.New(db)
+	b := querytest.Bundle(t, db, "foo", "1.0.0", time.Now())
+	querytest.Bundle(t, db, "foo", "1.0.1", time.Now())
+
+	require.NoError(t, q.CreateBundleProperties(t.Context(), b, []models.BundleProperty{
+		{Type: "olm.maxOpenShiftVersion", Value: json.RawMessage(`"4.15"`)},
+		{Type: "olm.package", Value: json.RawMessage(`{"packageName": "foo", "version": "1.0.0"}`)},
+	}))
+
+	versions, err := q.GetMaxOpenShiftVersions(t.Context(), "foo")
+	require.NoError(t, err)
+	assert.Equal(t, map[digest.Digest]string{querytest.BundleImage("foo", "1.0.0").Digest(): "4.15"}, versions)
+
+	versions, err = q.GetMaxOpenShiftVersions(t.Context(), "bar")
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+}
+
+func TestBundleLabels(t *testing.T) {
+	db := dbtest.New(t)
+	q := query.New(db)
+	jan := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	foo := querytest.Bundle(t, db, "foo", "1.0.0", jan)
+	foo1 := querytest.Bundle(t, db, "foo", "1.0.1", jan.AddDate(0, 1, 0))
+	bar := querytest.Bundle(t, db, "bar", "1.0.0", jan)
+
+	const versions = "com.redhat.openshift.versions"
+	require.NoError(t, q.CreateBundleLabels(t.Context(), foo, map[string]string{versions: "v4.14-v4.16", "operators.operatorframework.io.bundle.package.v1": "foo"}))
+	require.NoError(t, q.CreateBundleLabels(t.Context(), foo1, map[string]string{versions: "v4.15"}))
+	require.NoError(t, q.CreateBundleLabels(t.Context(), bar, map[string]string{versions: "v4.15"}))
+	require.NoError(t, q.CreateBundleLabels(t.Context(), bar, map[string]string{versions: "v4.16"}), "stored labels are kept")
+
+	labels, err := q.ListBundleLabels(t.Context(), query.BundleLabelFilter{Key: versions})
+	require.NoError(t, err)
+	var got []string
+	for _, l := range labels {
+		got = append(got, l.Package+"@"+l.Version+"="+l.Value)
+	}
+	assert.Equal(t, []string{"bar@1.0.0=v4.15", "foo@1.0.0=v4.14-v4.16", "foo@1.0.1=v4.15"}, got)
+
+	labels, err = q.ListBundleLabels(t.Context(), query.BundleLabelFilter{Key: versions, Value: "v4.15", Packages: []string{"foo"}})
+	require.NoError(t, err)
+	require.Len(t, labels, 1)
+	assert.Equal(t, query.BundleLabel{
+		Package: "foo",
+		Version: "1.0.1",
+		Digest:  querytest.BundleImage("foo", "1.0.1").Digest().String(),
+		Key:     versions,
+		Value:   "v4.15",
+	}, labels[0])
+
+	labels, err = q.ListBundleLabels(t.Context(), query.BundleLabelFilter{Packages: []string{"foo"}})
+	require.NoError(t, err)
+	assert.Len(t, labels, 3)
+
+	counts, err := q.CountBundleLabelValues(t.Context(), versions)
+	require.NoError(t, err)
+	assert.Equal(t, []query.LabelValueCount{{Value: "v4.15", Bundles: 2}, {Value: "v4.14-v4.16", Bundles: 1}}, counts)
+}
+
+func TestListBundlesForPackageOrdersRebuildsByRelease(t *testing.T) {
+	db := dbtest.New(t)
+	q := query.New(db)
+	built := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Rebuilds of a reproducible image have the same version and build time.
+	// Build metadata keeps their digests apart without affecting the order.
+	for version, release := range map[string]string{"1.0.0+a": "10", "1.0.0+b": "9", "1.0.0+c": "9.1"} {
+		b := querytest.Bundle(t, db, "foo", version, built)
+		_, err := db.ExecContext(t.Context(), `UPDATE bundles SET release = $1 WHERE id = $2`, release, b.ID)
+		require.NoError(t, err)
+	}
+	querytest.Bundle(t, db, "foo", "0.9.0", built.AddDate(0, 1, 0))
+
+	pkg, err := q.GetPackageByName(t.Context(), "foo")
+	require.NoError(t, err)
+	bundles, err := q.ListBundlesForPackage(t.Context(), pkg)
+	require.NoError(t, err)
+	var releases []string
+	for _, b := range bundles {
+		releases = append(releases, b.Release.String)
+	}
+	assert.Equal(t, []string{"9", "9.1", "10", ""}, releases, "bundles are ordered by build time before version")
+}
+
+func TestBundleVerifications(t *testing.T) {
+	db := dbtest.New(t)
+	q := query.New(db)
+	built := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	verified := querytest.Bundle(t, db, "foo", "1.0.0", built)
+	failed := querytest.Bundle(t, db, "foo", "1.0.1", built.AddDate(0, 1, 0))
+	querytest.Bundle(t, db, "foo", "1.0.2", built.AddDate(0, 2, 0))
+
+	require.NoError(t, q.SetBundleVerification(t.Context(), &models.BundleVerification{BundleID: verified.ID, Verified: false}))
+	require.NoError(t, q.SetBundleVerification(t.Context(), &models.BundleVerification{
+		BundleID: verified.ID,
+		Verified: true,
+		Signer:   sql.NullString{String: "release@example.com", Valid: true},
+	}))
+	require.NoError(t, q.SetBundleVerification(t.Context(), &models.BundleVerification{
+		BundleID: failed.ID,
+		Message:  sql.NullString{String: "no matching signatures", Valid: true},
+	}))
+
+	pkg, err := q.GetPackageByName(t.Context(), "foo")
+	require.NoError(t, err)
+	bundles, err := q.ListVerifiedBundlesForPackage(t.Context(), pkg)
+	require.NoError(t, err)
+	require.Len(t, bundles, 1)
+	assert.Equal(t, "1.0.0", bundles[0].Version)
+
+	bv, err := q.GetBundleVerificationByDigest(t.Context(), querytest.BundleImage("foo", "1.0.0").Digest())
+	require.NoError(t, err)
+	assert.True(t, bv.Verified, "later results replace earlier ones")
+	assert.Equal(t, "release@example.com", bv.Signer.String)
+
+	_, err = q.GetBundleVerificationByDigest(t.Context(), querytest.BundleImage("foo", "1.0.2").Digest())
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestBundleLiveness(t *testing.T) {
+	db := dbtest.New(t)
+	q := query.New(db)
+	built := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	live := querytest.Bundle(t, db, "foo", "1.0.0", built)
+	missing := querytest.Bundle(t, db, "foo", "1.0.1", built.AddDate(0, 1, 0))
+	unchecked := querytest.Bundle(t, db, "foo", "1.0.2", built.AddDate(0, 2, 0))
+
+	require.NoError(t, q.SetBundleLiveness(t.Context(), &models.BundleLiveness{BundleID: live.ID, Status: "live"}))
+	require.NoError(t, q.SetBundleLiveness(t.Context(), &models.BundleLiveness{BundleID: missing.ID, Status: "live"}))
+	require.NoError(t, q.SetBundleLiveness(t.Context(), &models.BundleLiveness{
+		BundleID: missing.ID,
+		Status:   "missing",
+		Message:  sql.NullString{String: "not found", Valid: true},
+	}))
+
+	bundles, err := q.ListBundlesForLivenessCheck(t.Context(), []string{ocispec.MediaTypeImageManifest}, 2)
+	require.NoError(t, err)
+	require.Len(t, bundles, 2)
+	assert.Equal(t, unchecked.ID, bundles[0].ID, "unchecked bundles are checked first")
+	assert.Equal(t, live.ID, bundles[1].ID)
+
+	bundles, err = q.ListBundlesForLivenessCheck(t.Context(), []string{ocispec.MediaTypeImageIndex}, 0)
+	require.NoError(t, err)
+	assert.Empty(t, bundles)
+
+	results, err := q.ListBundleLiveness(t.Context(), nil)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "live", results[0].Status)
+	assert.True(t, results[0].LastVerifiedAt.Valid)
+
+	results, err = q.ListBundleLiveness(t.Context(), []string{"missing", "retagged"})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "1.0.1", results[0].Version)
+	assert.Equal(t, "not found", results[0].Message.String)
+	assert.True(t, results[0].LastVerifiedAt.Valid, "the last verified time is kept")
+}
+
+func TestCatalogMembership(t *testing.T) {
+	db := dbtest.New(t)
+	q := query.New(db)
+	b := querytest.Bundle(t, db, "foo", "1.0.0", time.Now())
+	missing := querytest.BundleImage("foo", "1.0.1")
+	c := querytest.Catalog(t, db, "registry.example.com/index", "v1", querytest.BundleImage("foo", "1.0.0"), missing)
+
+	shipped, err := q.GetBundlesInCatalog(t.Context(), c, nil)
+	require.NoError(t, err)
+	require.Len(t, shipped, 1)
+	assert.Equal(t, "foo", shipped[0].PackageName)
+	assert.Equal(t, b.ID, shipped[0].Bundle.ID)
+	assert.Equal(t, querytest.BundleImage("foo", "1.0.0").String(), shipped[0].Image)
+
+	shipped, err = q.GetBundlesInCatalog(t.Context(), c, []string{"bar"})
+	require.NoError(t, err)
+	assert.Empty(t, shipped)
+
+	missingRefs, err := q.GetMissingBundlesInCatalog(t.Context(), c)
+	require.NoError(t, err)
+	require.Len(t, missingRefs, 1)
+	assert.Equal(t, missing.Digest().String(), missingRefs[0].Digest.String)
+
+	catalogs, err := q.GetCatalogsForBundle(t.Context(), b)
+	require.NoError(t, err)
+	require.Len(t, catalogs, 1)
+	assert.Equal(t, c.ID, catalogs[0].ID)
+
+	shipments, err := q.GetCatalogsForDigest(t.Context(), missing.Digest())
+	require.NoError(t, err)
+	require.Len(t, shipments, 1)
+	assert.Equal(t, c.ID, shipments[0].Catalog.ID)
+	assert.True(t, shipments[0].Current)
+	assert.False(t, shipments[0].FirstShippedAt.After(shipments[0].LastShippedAt))
+
+	shipments, err = q.GetCatalogsForDigest(t.Context(), querytest.BundleImage("foo", "1.0.3").Digest())
+	require.NoError(t, err)
+	assert.Empty(t, shipments)
+
+	querytest.Bundle(t, db, "foo", "1.0.2", time.Now())
+	pkg, err := q.GetPackageByName(t.Context(), "foo")
+	require.NoError(t, err)
+	bundles, err := q.ListBundlesForPackageInCatalog(t.Context(), pkg, c)
+	require.NoError(t, err)
+	require.Len(t, bundles, 1)
+	assert.Equal(t, b.ID, bundles[0].ID)
+
+	// Only the latest digest of the catalog counts.
+	querytest.Catalog(t, db, "registry.example.com/index", "v1", querytest.BundleImage("foo", "1.0.2"))
+	bundles, err = q.ListBundlesForPackageInCatalog(t.Context(), pkg, c)
+	require.NoError(t, err)
+	require.Len(t, bundles, 1)
+	assert.Equal(t, "1.0.2", bundles[0].Version)
+
+	shipments, err = q.GetCatalogsForDigest(t.Context(), missing.Digest())
+	require.NoError(t, err)
+	require.Len(t, shipments, 1)
+	assert.False(t, shipments[0].Current, "the image is no longer in the catalog")
+}
+
+func TestListUnreferencedImages(t *testing.T) {
+	db := dbtest.New(t)
+	q := query.New(db)
+	built := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	querytest.Bundle(t, db, "foo", "1.0.0", built)
+	querytest.Bundle(t, db, "foo", "1.0.1", built.AddDate(0, 1, 0))
+	querytest.Bundle(t, db, "foo", "1.0.2", built.AddDate(0, 2, 0))
+	querytest.Bundle(t, db, "foo", "1.0.3", time.Now())
+	querytest.Catalog(t, db, "registry.example.com/index", "v1", querytest.BundleImage("foo", "1.0.0"), querytest.BundleImage("foo", "1.0.1"))
+	querytest.Catalog(t, db, "registry.example.com/index", "v1", querytest.BundleImage("foo", "1.0.1"))
+
+	versions := func(images []query.UnreferencedImage) []string {
+		var vs []string
+		for _, ui := range images {
+			assert.Equal(t, querytest.BundleImage("foo", ui.Version).Name(), ui.Repo)
+			assert.Equal(t, querytest.BundleImage("foo", ui.Version).Digest().String(), ui.Digest)
+			vs = append(vs, ui.Version)
+		}
+		return vs
+	}
+
+	images, err := q.ListUnreferencedImages(t.Context(), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.0.0", "1.0.2", "1.0.3"}, versions(images))
+	assert.True(t, images[0].LastShippedAt.Valid, "1.0.0 shipped in the first catalog digest")
+	assert.False(t, images[1].LastShippedAt.Valid, "1.0.2 never shipped")
+
+	// Recently built and recently shipped images are kept.
+	images, err = q.ListUnreferencedImages(t.Context(), time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.0.2"}, versions(images))
+}
+
+func TestListPackagesBuiltBetween(t *testing.T) {
+	db := dbtest.New(t)
+	q := query.New(db)
+	jan := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	querytest.Bundle(t, db, "foo", "1.0.0", jan)
+	querytest.Bundle(t, db, "foo", "1.1.0", jan.AddDate(0, 2, 0))
+	querytest.Bundle(t, db, "bar", "1.0.0", jan.AddDate(1, 0, 0))
+
+	packages, err := q.ListPackagesBuiltBetween(t.Context(), jan, jan.AddDate(0, 6, 0))
+	require.NoError(t, err)
+	require.Len(t, packages, 1)
+	assert.Equal(t, "foo", packages[0].Name)
+	assert.Equal(t, 2, packages[0].Bundles)
+	assert.True(t, jan.Equal(packages[0].FirstBuilt))
+	assert.True(t, jan.AddDate(0, 2, 0).Equal(packages[0].LastBuilt))
+}
+
+func TestListBundleTimelines(t *testing.T) {
+	db := dbtest.New(t)
+	q := query.New(db)
+	jan := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	querytest.Bundle(t, db, "foo", "1.0.0", jan)
+	querytest.Bundle(t, db, "foo", "1.0.1", jan.AddDate(0, 1, 0))
+	querytest.Catalog(t, db, "registry.example.com/index", "v1", querytest.BundleImage("foo", "1.0.0"))
+	querytest.Catalog(t, db, "registry.example.com/index", "v2", querytest.BundleImage("foo", "1.0.0"))
+
+	timelines, err := q.ListBundleTimelines(t.Context())
+	require.NoError(t, err)
+	require.Len(t, timelines, 2)
+	assert.Equal(t, "1.0.0", timelines[0].Version)
+	assert.True(t, jan.Equal(timelines[0].BuiltAt.Time))
+	assert.True(t, timelines[0].FirstCatalogedAt.Valid)
+	assert.Equal(t, "1.0.1", timelines[1].Version)
+	assert.False(t, timelines[1].FirstCatalogedAt.Valid, "bundles in no catalog have not been cataloged")
+}
+
+func TestCatalogFreshness(t *testing.T) {
+	q := query.New(dbtest.New(t))
+	c, err := q.GetOrCreateCatalog(t.Context(), "registry.example.com/index", "v1")
+	require.NoError(t, err)
+	_, err = q.GetOrCreateCatalog(t.Context(), "registry.example.com/index", "v2")
+	require.NoError(t, err)
+
+	freshness, err := q.ListCatalogFreshness(t.Context())
+	require.NoError(t, err)
+	require.Len(t, freshness, 2)
+	assert.False(t, freshness[0].IngestedAt.Valid, "catalogs that were never ingested have no freshness")
+	_, err = q.GetCatalogIngestedDigest(t.Context(), c)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	dgst := digest.FromString("v1").String()
+	require.NoError(t, q.RecordCatalogIngestion(t.Context(), c, dgst))
+	ingested, err := q.GetCatalogIngestedDigest(t.Context(), c)
+	require.NoError(t, err)
+	assert.Equal(t, dgst, ingested)
+	freshness, err = q.ListCatalogFreshness(t.Context())
+	require.NoError(t, err)
+	first := freshness[0]
+	assert.Equal(t, "v1", first.Tag)
+	assert.Equal(t, dgst, first.Digest.String)
+	require.True(t, first.IngestedAt.Valid)
+
+	require.NoError(t, q.RecordCatalogIngestion(t.Context(), c, dgst))
+	freshness, err = q.ListCatalogFreshness(t.Context())
+	require.NoError(t, err)
+	assert.True(t, first.DigestChangedAt.Time.Equal(freshness[0].DigestChangedAt.Time), "an unchanged digest keeps its change time")
+	assert.False(t, freshness[0].IngestedAt.Time.Before(first.IngestedAt.Time))
+
+	require.NoError(t, q.RecordCatalogIngestion(t.Context(), c, digest.FromString("v1.1").String()))
+	freshness, err = q.ListCatalogFreshness(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, digest.FromString("v1.1").String(), freshness[0].Digest.String)
+	assert.False(t, freshness[0].DigestChangedAt.Time.Before(first.DigestChangedAt.Time))
+}
+
+func TestListDefaultChannelsByTag(t *testing.T) {
+	q := query.New(dbtest.New(t))
+	ctx := t.Context()
+	for tag, channels := range map[string]map[string]string{
+		"v4.15": {"foo": "stable-1.0", "bar": "stable"},
+		"v4.16": {"foo": "stable-1.1", "bar": "stable"},
+	} {
+		c, err := q.GetOrCreateCatalog(ctx, "registry.example.com/index", tag)
+		require.NoError(t, err)
+		cd, err := q.GetOrCreateCatalogDigest(ctx, c, digest.FromString(tag).String())
+		require.NoError(t, err)
+		require.NoError(t, q.SetCatalogDigestDefaultChannels(ctx, cd, channels))
+	}
+	other, err := q.GetOrCreateCatalog(ctx, "registry.example.com/other", "v4.16")
+	require.NoError(t, err)
+	cd, err := q.GetOrCreateCatalogDigest(ctx, other, digest.FromString("other").String())
+	require.NoError(t, err)
+	require.NoError(t, q.SetCatalogDigestDefaultChannels(ctx, cd, map[string]string{"foo": "alpha"}))
+
+	channels, err := q.ListDefaultChannelsByTag(ctx, "registry.example.com/index", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []query.TagDefaultChannel{
+		{Tag: "v4.15", Package: "bar", DefaultChannel: "stable"},
+		{Tag: "v4.16", Package: "bar", DefaultChannel: "stable"},
+		{Tag: "v4.15", Package: "foo", DefaultChannel: "stable-1.0"},
+		{Tag: "v4.16", Package: "foo", DefaultChannel: "stable-1.1"},
+	}, channels)
+
+	channels, err = q.ListDefaultChannelsByTag(ctx, "registry.example.com/index", []string{"foo"})
+	require.NoError(t, err)
+	assert.Len(t, channels, 2)
+}
+
+func TestGetPackagePlatformCoverage(t *testing.T) {
+	db := dbtest.New(t)
+	q := query.New(db)
+	querytest.Bundle(t, db, "foo", "1.0.0", time.Now())
+	querytest.Bundle(t, db, "foo", "1.1.0", time.Now())
+	querytest.Bundle(t, db, "bar", "2.0.0", time.Now())
+	querytest.Catalog(t, db, "registry.example.com/index", "v4.9", querytest.BundleImage("foo", "1.0.0"))
+	querytest.Catalog(t, db, "registry.example.com/index", "v4.10", querytest.BundleImage("foo", "1.0.0"), querytest.BundleImage("foo", "1.1.0"))
+	querytest.Catalog(t, db, "registry.example.com/index", "v4.11", querytest.BundleImage("bar", "2.0.0"))
+	_, err := q.GetOrCreateCatalog(t.Context(), "registry.example.com/index", "v4.12")
+	require.NoError(t, err)
+
+	coverage, err := q.GetPackagePlatformCoverage(t.Context(), "foo")
+	require.NoError(t, err)
+	assert.Equal(t, []query.PackageCoverage{
+		{Catalog: "registry.example.com/index", Tag: "v4.9", Exists: true, HeadVersion: "1.0.0"},
+		{Catalog: "registry.example.com/index", Tag: "v4.10", Exists: true, HeadVersion: "1.1.0"},
+		{Catalog: "registry.example.com/index", Tag: "v4.11"},
+		{Catalog: "registry.example.com/index", Tag: "v4.12"},
+	}, coverage)
+}
+
+func TestBundleProvenance(t *testing.T) {
+	db := dbtest.New(t)
+	q := query.New(db)
+	foo := querytest.Bundle(t, db, "foo", "1.0.0", time.Now())
+	bar := querytest.Bundle(t, db, "bar", "1.0.0", time.Now())
+	run, err := q.CreateIngestionRun(t.Context())
+	require.NoError(t, err)
+
+	require.NoError(t, q.SetBundleProvenance(t.Context(), &models.BundleProvenance{
+		BundleID: foo.ID, Event: "created", SourceType: models.SourceTypeCatalog, SourceID: "index:v1",
+		IngestionRunID: sql.NullString{String: run.ID, Valid: true},
+	}))
+	require.NoError(t, q.SetBundleProvenance(t.Context(), &models.BundleProvenance{
+		BundleID: bar.ID, Event: "created", SourceType: models.SourceTypeWebhook, SourceID: "quay.io/example/bar-bundle:v1.0.0",
+	}))
+	for _, sourceID := range []string{"index:v1", "index:v2"} {
+		require.NoError(t, q.SetBundleProvenance(t.Context(), &models.BundleProvenance{
+			BundleID: foo.ID, Event: "updated", SourceType: models.SourceTypeCatalog, SourceID: sourceID,
+		}))
+	}
+
+	records, err := q.ListBundleProvenance(t.Context(), query.BundleProvenanceFilter{})
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, "bar", records[0].Package)
+	assert.Equal(t, models.SourceTypeWebhook, records[0].SourceType)
+	assert.Equal(t, "foo", records[1].Package)
+	assert.Equal(t, "created", records[1].Event)
+	assert.Equal(t, foo.Descriptor.V.Digest.String(), records[1].Digest)
+	assert.Equal(t, "updated", records[2].Event)
+	assert.Equal(t, "index:v2", records[2].SourceID, "only the last update is kept")
+
+	records, err = q.ListBundleProvenance(t.Context(), query.BundleProvenanceFilter{IngestionRunID: run.ID})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "index:v1", records[0].SourceID)
+	records, err = q.ListBundleProvenance(t.Context(), query.BundleProvenanceFilter{SourceType: models.SourceTypeCatalog, Packages: []string{"bar"}})
+	require.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestFetchFailures(t *testing.T) {
+	q := query.New(dbtest.New(t))
+	foo := "quay.io/example/foo-bundle@sha256:" + strings.Repeat("a", 64)
+	bar := "quay.io/example/bar-bundle@sha256:" + strings.Repeat("b", 64)
+
+	require.NoError(t, q.RecordFetchFailure(t.Context(), &models.FetchFailure{
+		Reference: foo, Error: "unauthorized", Attempts: 1, SourceType: models.SourceTypeCatalog, SourceID: "index:v1",
+	}))
+	require.NoError(t, q.RecordFetchFailure(t.Context(), &models.FetchFailure{
+		Reference: bar, Error: "timeout", Attempts: 4, SourceType: models.SourceTypeWebhook, SourceID: "quay.io/example/bar-bundle:v1",
+	}))
+	require.NoError(t, q.RecordFetchFailure(t.Context(), &models.FetchFailure{
+		Reference: foo, Error: "not found", Attempts: 2, SourceType: models.SourceTypeCatalog, SourceID: "index:v2",
+	}))
+
+	failures, err := q.ListFetchFailures(t.Context(), query.FetchFailureFilter{})
+	require.NoError(t, err)
+	require.Len(t, failures, 2)
+	assert.Equal(t, bar, failures[0].Reference, "the least recently attempted failure is listed first")
+	assert.Equal(t, foo, failures[1].Reference)
+	assert.Equal(t, "not found", failures[1].Error)
+	assert.Equal(t, 3, failures[1].Attempts, "attempts accumulate")
+	assert.Equal(t, "index:v2", failures[1].SourceID)
+	assert.False(t, failures[1].LastAttemptAt.Before(failures[1].FirstFailedAt))
+
+	failures, err = q.ListFetchFailures(t.Context(), query.FetchFailureFilter{SourceType: models.SourceTypeCatalog})
+	require.NoError(t, err)
+	require.Len(t, failures, 1)
+	assert.Equal(t, foo, failures[0].Reference)
+	failures, err = q.ListFetchFailures(t.Context(), query.FetchFailureFilter{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, failures, 1)
+	assert.Equal(t, bar, failures[0].Reference)
+	failures, err = q.ListFetchFailures(t.Context(), query.FetchFailureFilter{AttemptedBefore: time.Now().Add(-time.Hour)})
+	require.NoError(t, err)
+	assert.Empty(t, failures)
+
+	require.NoError(t, q.DeleteFetchFailure(t.Context(), foo))
+	require.NoError(t, q.DeleteFetchFailure(t.Context(), foo))
+	failures, err = q.ListFetchFailures(t.Context(), query.FetchFailureFilter{})
+	require.NoError(t, err)
+	require.Len(t, failures, 1)
+	assert.Equal(t, bar, failures[0].Reference)
+}
+
+func TestIngestionRuns(t *testing.T) {
+	q := query.New(dbtest.New(t))
+
+	_, err := q.GetLatestIngestionRun(t.Context())
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	run, err := q.CreateIngestionRun(t.Context())
+	require.NoError(t, err)
+	assert.False(t, run.FinishedAt.Valid)
+
+	require.NoError(t, q.FinishIngestionRun(t.Context(), run, errors.New("boom")))
+	assert.True(t, run.FinishedAt.Valid)
+
+	latest, err := q.GetLatestIngestionRun(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, run.ID, latest.ID)
+	assert.Equal(t, sql.NullString{String: "boom", Valid: true}, latest.Error)
+}
+
+func TestCachedGraphs(t *testing.T) {
+	q := query.New(dbtest.New(t))
+	first, err := q.CreateIngestionRun(t.Context())
+	require.NoError(t, err)
+	second, err := q.CreateIngestionRun(t.Context())
+	require.NoError(t, err)
+
+	_, err = q.GetCachedGraph(t.Context(), first.ID, "foo")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	require.NoError(t, q.PutCachedGraph(t.Context(), first.ID, "foo", []byte("v1")))
+	require.NoError(t, q.PutCachedGraph(t.Context(), first.ID, "foo", []byte("v2")))
+	data, err := q.GetCachedGraph(t.Context(), first.ID, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v2"), data)
+
+	// Caching a graph for a later run drops those of earlier runs.
+	require.NoError(t, q.PutCachedGraph(t.Context(), second.ID, "bar", []byte("v3")))
+	_, err = q.GetCachedGraph(t.Context(), first.ID, "foo")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	data, err = q.GetCachedGraph(t.Context(), second.ID, "bar")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v3"), data)
+}
+
+func TestLifecycleNotifications(t *testing.T) {
+	q := query.New(dbtest.New(t))
+	date := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	sent, err := q.HasLifecycleNotification(t.Context(), "foo", "1.0", "phase", date)
+	require.NoError(t, err)
+	assert.False(t, sent)
+
+	require.NoError(t, q.RecordLifecycleNotification(t.Context(), "foo", "1.0", "phase", date))
+	require.NoError(t, q.RecordLifecycleNotification(t.Context(), "foo", "1.0", "phase", date))
+
+	sent, err = q.HasLifecycleNotification(t.Context(), "foo", "1.0", "phase", date)
+	require.NoError(t, err)
+	assert.True(t, sent)
+}
+
+func TestVersionStreams(t *testing.T) {
+	q := query.New(dbtest.New(t))
+
+	streams := []graph.VersionStream{
+		{
+			Version:              graph.MajorMinor{Major: 1, Minor: 1},
+			MinimumUpdateVersion: semver.MustParse("1.0.0"),
+			LifecycleDates: graph.LifecycleDates{
+				FullSupport: graph.NewDate(2024, 6, 1),
+				Maintenance: graph.NewDate(2025, 1, 1),
+				Extensions:  []graph.Date{graph.NewDate(2025, 6, 1), graph.NewDate(2026, 1, 1)},
+				EndOfLife:   graph.NewDate(2026, 6, 1),
+			},
+			SupportedPlatformVersions:      []graph.MajorMinor{{Major: 4, Minor: 16}, {Major: 4, Minor: 17}},
+			RequiresUpdatePlatformVersions: []graph.MajorMinor{{Major: 4, Minor: 18}},
+		},
+		{
+			Version: graph.MajorMinor{Major: 1, Minor: 0},
+			LifecycleDates: graph.LifecycleDates{
+				FullSupport: graph.NewDate(2024, 1, 1),
+				Maintenance: graph.NewDate(2024, 6, 1),
+				EndOfLife:   graph.NewDate(2025, 1, 1),
+			},
+		},
+	}
+	require.NoError(t, q.SetVersionStreams(t.Context(), "foo", streams))
+	require.NoError(t, q.SetVersionStreams(t.Context(), "bar", streams[1:]))
+
+	templates, err := q.ListVersionStreams(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []graph.Template{
+		{Schema: graph.SchemaCincinnati, Name: "bar", VersionStreams: streams[1:]},
+		{Schema: graph.SchemaCincinnati, Name: "foo", VersionStreams: []graph.VersionStream{streams[1], streams[0]}},
+	}, templates)
+
+	dates, err := q.GetLifecycleDates(t.Context(), "foo", streams[0].Version)
+	require.NoError(t, err)
+	assert.Equal(t, streams[0].LifecycleDates, dates)
+	_, err = q.GetLifecycleDates(t.Context(), "bar", streams[0].Version)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// Setting a package's streams replaces them.
+	require.NoError(t, q.SetVersionStreams(t.Context(), "foo", streams[:1]))
+	templates, err = q.ListVersionStreams(t.Context())
+	require.NoError(t, err)
+	require.Len(t, templates, 2)
+	assert.Equal(t, streams[:1], templates[1].VersionStreams)
+}
+
+func TestMinimumUpdateVersionOverrides(t *testing.T) {
+	q := query.New(dbtest.New(t))
+
+	v11, v10 := graph.MajorMinor{Major: 1, Minor: 1}, graph.MajorMinor{Major: 1, Minor: 0}
+	require.NoError(t, q.SetMinimumUpdateVersionOverride(t.Context(), "foo", v11, semver.MustParse("1.0.5")))
+	require.NoError(t, q.SetMinimumUpdateVersionOverride(t.Context(), "foo", v10, semver.MustParse("1.0.0")))
+	require.NoError(t, q.SetMinimumUpdateVersionOverride(t.Context(), "bar", v10, semver.MustParse("1.0.0")))
+
+	// Setting an override again replaces it.
+	require.NoError(t, q.SetMinimumUpdateVersionOverride(t.Context(), "foo", v11, semver.MustParse("1.1.0")))
+
+	overrides, err := q.ListMinimumUpdateVersionOverrides(t.Context(), "foo")
+	require.NoError(t, err)
+	require.Len(t, overrides, 2)
+	assert.Equal(t, v10, overrides[0].Stream)
+	assert.Equal(t, v11, overrides[1].Stream)
+	assert.Equal(t, semver.MustParse("1.1.0"), overrides[1].MinimumUpdateVersion)
+
+	overrides, err = q.ListMinimumUpdateVersionOverrides(t.Context())
+	require.NoError(t, err)
+	assert.Len(t, overrides, 3)
+	assert.Equal(t, "bar", overrides[0].PackageName)
+
+	deleted, err := q.DeleteMinimumUpdateVersionOverride(t.Context(), "foo", v11)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	deleted, err = q.DeleteMinimumUpdateVersionOverride(t.Context(), "foo", v11)
+	require.NoError(t, err)
+	assert.False(t, deleted)
+
+	// Importing templates keeps overrides.
+	require.NoError(t, q.SetVersionStreams(t.Context(), "foo", nil))
+	overrides, err = q.ListMinimumUpdateVersionOverrides(t.Context(), "foo")
+	require.NoError(t, err)
+	assert.Len(t, overrides, 1)
+}
+
+func TestJira(t *testing.T) {
+	q := query.New(dbtest.New(t))
+
+	c, err := q.GetOrCreateCatalog(t.Context(), "registry.example.com/index", "v1")
+	require.NoError(t, err)
+	c.JiraBugProject = sql.NullString{String: "OCPBUGS", Valid: true}
+	require.NoError(t, q.SetCatalogJira(t.Context(), c))
+	c, err = q.GetCatalog(t.Context(), "registry.example.com/index", "v1")
+	require.NoError(t, err)
+	assert.Equal(t, "OCPBUGS", c.JiraBugProject.String)
+
+	p, err := q.GetOrCreatePackage(t.Context(), "foo")
+	require.NoError(t, err)
+	p.JiraFeatureProject = sql.NullString{String: "FOO", Valid: true}
+	require.NoError(t, q.SetPackageJira(t.Context(), p))
+	p, err = q.GetPackageByName(t.Context(), "foo")
+	require.NoError(t, err)
+	assert.Equal(t, "FOO", p.JiraFeatureProject.String)
+
+	require.NoError(t, q.LinkJiraIssue(t.Context(), "dead-ends/foo", "FOO-1"))
+	require.NoError(t, q.LinkJiraIssue(t.Context(), "dead-ends/foo", "FOO-2"))
+	issues, err := q.ListJiraIssues(t.Context())
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	assert.Equal(t, models.JiraIssue{Problem: "dead-ends/foo", IssueKey: "FOO-2", CreatedAt: issues[0].CreatedAt}, *issues[0])
+}
+
+func TestListLegacyImages(t *testing.T) {
+	db := dbtest.New(t)
+	q := query.New(db)
+
+	ok, err := q.HasLegacySchema(t.Context())
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// The tables of the schema that preceded packages and bundles.
+	_, err = db.ExecContext(t.Context(), `
+    CREATE TABLE image_configs (
+        id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+        image_reference TEXT NOT NULL UNIQUE,
+        blob JSONB NOT NULL,
+        bundle_version TEXT,
+        created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+    );
+    CREATE TABLE ocp_catalog_references (
+        id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+        image_config_id UUID NOT NULL REFERENCES image_configs(id) ON DELETE CASCADE,
+        ocp_version TEXT NOT NULL,
+        catalog_name TEXT NOT NULL,
+        first_seen TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+        UNIQUE (image_config_id, ocp_version, catalog_name)
+    );
+    INSERT INTO image_configs (image_reference, blob) VALUES ('quay.io/example/foo-bundle@sha256:aa', '{}'), ('quay.io/example/bar-bundle@sha256:bb', '{}');
+    INSERT INTO ocp_catalog_references (image_config_id, ocp_version, catalog_name)
+    SELECT id, v, 'redhat-operator-index' FROM image_configs, unnest(ARRAY['4.16', '4.15']) v
+    WHERE image_reference = 'quay.io/example/foo-bundle@sha256:aa';`)
+	require.NoError(t, err)
+
+	ok, err = q.HasLegacySchema(t.Context())
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	images, err := q.ListLegacyImages(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []query.LegacyImage{
+		{Reference: "quay.io/example/bar-bundle@sha256:bb"},
+		{Reference: "quay.io/example/foo-bundle@sha256:aa", Catalogs: []query.LegacyCatalog{
+			{Name: "redhat-operator-index", OCPVersion: "4.15"},
+			{Name: "redhat-operator-index", OCPVersion: "4.16"},
+		}},
+	}, images)
+}
@@ -0,0 +1,99 @@
+// Package querytest stores bundles and catalogs in test databases, such as
+// those of dbtest.New, through the query layer.
+package querytest
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/models"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+// BundleImage returns the image reference of the bundle created by Bundle for
+// a version of a package. It is in the repository
+// quay.io/extensiondb-test/<package>-bundle, and its digest is derived from
+// the package and version.
+func BundleImage(pkg, version string) reference.Canonical {
+	named, err := reference.ParseNormalizedNamed("quay.io/extensiondb-test/" + pkg + "-bundle")
+	if err != nil {
+		panic(err)
+	}
+	ref, err := reference.WithDigest(named, digest.FromString(pkg+"@"+version))
+	if err != nil {
+		panic(err)
+	}
+	return ref
+}
+
+// Bundle stores a bundle for a version of a package, built at built, along
+// with its package and its BundleImage reference.
+func Bundle(t testing.TB, db *sql.DB, pkg, version string, built time.Time) *models.Bundle {
+	t.Helper()
+	ctx := t.Context()
+	q := query.New(db)
+
+	p, err := q.GetOrCreatePackage(ctx, pkg)
+	if err != nil {
+		t.Fatalf("error creating package %q: %v", pkg, err)
+	}
+	ref := BundleImage(pkg, version)
+	br, err := q.GetOrCreateCanonicalBundleReference(ctx, ref)
+	if err != nil {
+		t.Fatalf("error creating bundle reference %s: %v", ref, err)
+	}
+
+	built = built.UTC()
+	b := &models.Bundle{
+		PackageID: sql.NullString{String: p.ID, Valid: true},
+		Descriptor: models.JSONB[ocispec.Descriptor]{V: &ocispec.Descriptor{
+			MediaType: ocispec.MediaTypeImageManifest,
+			Digest:    ref.Digest(),
+			Size:      1,
+		}},
+		Manifest: models.JSONB[ocispec.Manifest]{V: &ocispec.Manifest{MediaType: ocispec.MediaTypeImageManifest}},
+		Image:    models.JSONB[ocispec.Image]{V: &ocispec.Image{Created: &built}},
+		Version:  version,
+	}
+	if err := q.CreateBundleWithCatalogAndReference(ctx, b, nil, br); err != nil {
+		t.Fatalf("error creating bundle %s: %v", ref, err)
+	}
+	return b
+}
+
+// Catalog stores a catalog with a digest that references images, which need
+// not have been stored as bundles.
+func Catalog(t testing.TB, db *sql.DB, name, tag string, images ...reference.Canonical) *models.Catalog {
+	t.Helper()
+	ctx := t.Context()
+	q := query.New(db)
+
+	c, err := q.GetOrCreateCatalog(ctx, name, tag)
+	if err != nil {
+		t.Fatalf("error creating catalog %s:%s: %v", name, tag, err)
+	}
+
+	refs := make([]string, 0, len(images))
+	for _, image := range images {
+		refs = append(refs, image.String())
+	}
+	cd, err := q.GetOrCreateCatalogDigest(ctx, c, digest.FromString(strings.Join(refs, ",")).String())
+	if err != nil {
+		t.Fatalf("error creating catalog digest: %v", err)
+	}
+	for _, image := range images {
+		br, err := q.GetOrCreateCanonicalBundleReference(ctx, image)
+		if err != nil {
+			t.Fatalf("error creating bundle reference %s: %v", image, err)
+		}
+		if err := q.EnsureCatalogDigestBundleReference(ctx, cd, br); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c
+}
@@ -0,0 +1,149 @@
+package registry
+
+import (
+	"archive/tar"
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/containerd/containerd/archive"
+	"go.podman.io/image/v5/docker/reference"
+	"go.podman.io/image/v5/pkg/compression"
+)
+
+// CatalogConfigsLabel is the image label that holds the directory of the
+// file-based catalog in a catalog image.
+const CatalogConfigsLabel = "operators.operatorframework.io.index.configs.v1"
+
+// DefaultCatalogConfigsDir is the directory of the file-based catalog in
+// catalog images without a CatalogConfigsLabel.
+const DefaultCatalogConfigsDir = "/configs"
+
+// CatalogDatabaseLabel is the image label that holds the path of the SQLite
+// database in catalog images from before file-based catalogs, such as those
+// of OpenShift 4.10 and earlier.
+const CatalogDatabaseLabel = "operators.operatorframework.io.index.database.v1"
+
+// SQLiteCatalogFile is the file that PullCatalog extracts the database of a
+// SQLite catalog image to.
+const SQLiteCatalogFile = "index.db"
+
+// PullCatalog extracts the catalog of the catalog image at a canonical
+// reference to dir with the zero Client.
+func PullCatalog(ctx context.Context, canonicalRef reference.Canonical, dir string) error {
+	return Client{}.PullCatalog(ctx, canonicalRef, dir)
+}
+
+// PullCatalog extracts the catalog of the catalog image at a canonical
+// reference to dir, which must exist. Only the directories and regular files
+// of the file-based catalog are extracted; the rest of the image, such as the
+// opm binary that serves it, is skipped. Images with a CatalogDatabaseLabel
+// and no CatalogConfigsLabel are SQLite catalogs, whose database alone is
+// extracted, to dir/SQLiteCatalogFile.
+func (c Client) PullCatalog(ctx context.Context, canonicalRef reference.Canonical, dir string) error {
+	repo, err := c.repository(ctx, canonicalRef.String())
+	if err != nil {
+		return fmt.Errorf("failed to create repository for %s: %w", canonicalRef, err)
+	}
+	_, _, imageManifest, err := fetchManifest(ctx, repo, canonicalRef)
+	if err != nil {
+		return err
+	}
+	config, err := fetchConfig(ctx, repo, canonicalRef, imageManifest)
+	if err != nil {
+		return err
+	}
+	catalogPath := configsPath(config.Config.Labels[CatalogConfigsLabel])
+	if _, ok := config.Config.Labels[CatalogConfigsLabel]; !ok {
+		if databaseFile, ok := config.Config.Labels[CatalogDatabaseLabel]; ok {
+			catalogPath = databasePath(databaseFile)
+		}
+	}
+
+	for _, layer := range imageManifest.Layers {
+		if err := func() error {
+			layerReader, err := repo.Fetch(ctx, layer)
+			if err != nil {
+				return fmt.Errorf("failed to fetch layer for %s: %w", layer.Digest.String(), err)
+			}
+			defer layerReader.Close()
+
+			decompressedReader, _, err := compression.AutoDecompress(layerReader)
+			if err != nil {
+				return fmt.Errorf("failed to decompress layer: %w", err)
+			}
+			defer decompressedReader.Close()
+
+			// Later layers may remove files of earlier ones with
+			// whiteouts, which are regular files that archive.Apply
+			// applies rather than extracts.
+			_, err = archive.Apply(ctx, dir, decompressedReader, archive.WithFilter(func(h *tar.Header) (bool, error) {
+				rel, ok := catalogPath(h.Name)
+				if !ok {
+					return false, nil
+				}
+				switch h.Typeflag {
+				case tar.TypeDir:
+					h.Mode = 0o755
+				case tar.TypeReg:
+					h.Mode = 0o644
+				case tar.TypeLink:
+					link, ok := catalogPath(h.Linkname)
+					if !ok {
+						return false, nil
+					}
+					h.Linkname = "./" + link
+				default:
+					return false, nil
+				}
+				h.Name = "./" + rel
+				h.Uid = os.Getuid()
+				h.Gid = os.Getgid()
+				h.PAXRecords = nil
+				h.Xattrs = nil
+				return true, nil
+			}))
+			return err
+		}(); err != nil {
+			return fmt.Errorf("failed to extract catalog from %s: %w", canonicalRef, err)
+		}
+	}
+	return nil
+}
+
+// configsPath returns a function that returns the path of a layer entry
+// relative to the file-based catalog in configsDir, and whether the entry is
+// in the catalog.
+func configsPath(configsDir string) func(name string) (string, bool) {
+	configsDir = cleanLayerPath(cmp.Or(configsDir, DefaultCatalogConfigsDir))
+	return func(name string) (string, bool) {
+		name = cleanLayerPath(name)
+		if configsDir == "" {
+			return name, name != ""
+		}
+		rel, ok := strings.CutPrefix(name, configsDir+"/")
+		return rel, ok
+	}
+}
+
+// databasePath returns a function that maps the layer entry of the SQLite
+// database at databaseFile to SQLiteCatalogFile, and reports that no other
+// entry is in the catalog.
+func databasePath(databaseFile string) func(name string) (string, bool) {
+	databaseFile = cleanLayerPath(databaseFile)
+	return func(name string) (string, bool) {
+		if cleanLayerPath(name) != databaseFile {
+			return "", false
+		}
+		return SQLiteCatalogFile, true
+	}
+}
+
+// cleanLayerPath returns the path of a layer entry without leading slashes
+// or dot segments.
+func cleanLayerPath(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
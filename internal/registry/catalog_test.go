@@ -0,0 +1,51 @@
+package registry_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joelanford/extensiondb/internal/registry"
+	"github.com/joelanford/extensiondb/internal/registry/registrytest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullCatalog(t *testing.T) {
+	for _, configsDir := range []string{"", "/catalog"} {
+		r := registrytest.New(t)
+		ref := r.PushCatalog(t, "example/index", "v4.19", registrytest.Catalog{
+			ConfigsDir: configsDir,
+			Files: map[string]string{
+				"foo/catalog.yaml": "schema: olm.package\nname: foo\n",
+				"bar/catalog.json": `{"schema": "olm.package", "name": "bar"}`,
+			},
+			OtherFiles: map[string]string{"bin/opm": "#!/bin/sh\n"},
+		})
+
+		dir := t.TempDir()
+		require.NoError(t, registry.PullCatalog(t.Context(), ref, dir))
+		data, err := os.ReadFile(filepath.Join(dir, "foo", "catalog.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "schema: olm.package\nname: foo\n", string(data))
+		assert.FileExists(t, filepath.Join(dir, "bar", "catalog.json"))
+		assert.NoFileExists(t, filepath.Join(dir, "bin", "opm"), "only the catalog is extracted")
+	}
+}
+
+func TestPullCatalog_SQLite(t *testing.T) {
+	r := registrytest.New(t)
+	ref := r.PushCatalog(t, "example/index", "v4.10", registrytest.Catalog{
+		Database:   []byte("SQLite format 3\x00"),
+		OtherFiles: map[string]string{"bin/opm": "#!/bin/sh\n"},
+	})
+
+	dir := t.TempDir()
+	require.NoError(t, registry.PullCatalog(t.Context(), ref, dir))
+	data, err := os.ReadFile(filepath.Join(dir, registry.SQLiteCatalogFile))
+	require.NoError(t, err)
+	assert.Equal(t, "SQLite format 3\x00", string(data))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "only the database is extracted")
+}
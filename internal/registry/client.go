@@ -8,6 +8,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/containerd/containerd/archive"
 	"github.com/containers/image/v5/manifest"
@@ -18,6 +19,7 @@ import (
 	"go.podman.io/image/v5/pkg/compression"
 	"oras.land/oras-go/v2"
 	"oras.land/oras-go/v2/content"
+	"oras.land/oras-go/v2/errdef"
 	"sigs.k8s.io/yaml"
 
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
@@ -31,26 +33,133 @@ type RegistryV1ImageInfo struct {
 	Manifest            ocispec.Manifest               // Image manifest
 	ImageConfig         ocispec.Image                  // Image config blob as JSON
 	PackageName         string                         // Package name
+	Release             string                         // Release distinguishing rebuilds of the version, if any
 	CSV                 v1alpha1.ClusterServiceVersion // CSV
+
+	// Manifests are the bundle's other manifests, such as
+	// CustomResourceDefinitions, by file name in its manifests directory.
+	Manifests map[string][]byte
 }
 
-// FetchRegistryV1Bundle fetches manifest and config for a canonical image reference
+// ReleaseLabel is the image label that holds the release of a build, as set
+// on Red Hat builds.
+const ReleaseLabel = "release"
+
+// ErrNotFound is wrapped by the errors of ResolveDigest and CheckDigest when
+// the registry reports that the image does not exist.
+var ErrNotFound = errdef.ErrNotFound
+
+// Client resolves and fetches images from registries. The zero Client sends
+// its requests without limits, as the package's functions do.
+type Client struct {
+	// RateLimiter limits the rate of the requests to each registry host.
+	RateLimiter *RateLimiter
+}
+
+// repository returns the repository of an image reference, whose requests
+// are limited by c.RateLimiter.
+func (c Client) repository(ctx context.Context, ref string) (*remote.Repository, error) {
+	repo, err := remote.NewRepository(ctx, nil, ref)
+	if err != nil {
+		return nil, err
+	}
+	repo.Client = c.RateLimiter.client(repo.Client)
+	return repo, nil
+}
+
+// ResolveDigest resolves a tagged image reference to a canonical digest-based
+// reference with the zero Client.
+func ResolveDigest(ctx context.Context, taggedRef reference.NamedTagged) (reference.Canonical, error) {
+	return Client{}.ResolveDigest(ctx, taggedRef)
+}
+
+// ResolveDigest resolves a tagged image reference to a canonical digest-based reference
+func (c Client) ResolveDigest(ctx context.Context, taggedRef reference.NamedTagged) (reference.Canonical, error) {
+	repo, err := c.repository(ctx, taggedRef.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create repository for %s: %w", taggedRef, err)
+	}
+	desc, err := repo.Resolve(ctx, taggedRef.Tag())
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", taggedRef, err)
+	}
+	return reference.WithDigest(reference.TrimNamed(taggedRef), desc.Digest)
+}
+
+// CheckDigest checks that a canonical image reference still exists in its
+// registry without fetching it, with the zero Client.
+func CheckDigest(ctx context.Context, canonicalRef reference.Canonical) error {
+	return Client{}.CheckDigest(ctx, canonicalRef)
+}
+
+// CheckDigest checks that a canonical image reference still exists in its
+// registry without fetching it.
+func (c Client) CheckDigest(ctx context.Context, canonicalRef reference.Canonical) error {
+	repo, err := c.repository(ctx, canonicalRef.String())
+	if err != nil {
+		return fmt.Errorf("failed to create repository for %s: %w", canonicalRef, err)
+	}
+	if _, err := repo.Resolve(ctx, canonicalRef.Digest().String()); err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", canonicalRef, err)
+	}
+	return nil
+}
+
+// FetchRegistryV1Bundle fetches manifest and config for a canonical image
+// reference with the zero Client.
 func FetchRegistryV1Bundle(ctx context.Context, canonicalRef reference.Canonical) (*RegistryV1ImageInfo, error) {
+	return Client{}.FetchRegistryV1Bundle(ctx, canonicalRef)
+}
+
+// FetchRegistryV1Bundle fetches manifest and config for a canonical image reference
+func (c Client) FetchRegistryV1Bundle(ctx context.Context, canonicalRef reference.Canonical) (*RegistryV1ImageInfo, error) {
 	// Create repository from canonical reference
-	repo, err := remote.NewRepository(ctx, nil, canonicalRef.String())
+	repo, err := c.repository(ctx, canonicalRef.String())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create repository for %s: %w", canonicalRef, err)
 	}
 
+	refDesc, imageIndex, imageManifest, err := fetchManifest(ctx, repo, canonicalRef)
+	if err != nil {
+		return nil, err
+	}
+	config, err := fetchConfig(ctx, repo, canonicalRef, imageManifest)
+	if err != nil {
+		return nil, err
+	}
+
+	// Extract the CSV and other manifests from layers
+	csv, manifests, err := extractManifests(ctx, repo, imageManifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract manifests for %s: %w", canonicalRef, err)
+	}
+
+	return &RegistryV1ImageInfo{
+		Reference:           canonicalRef,
+		ReferenceDescriptor: refDesc,
+		Index:               imageIndex,
+		Manifest:            imageManifest,
+		ImageConfig:         config,
+		PackageName:         config.Config.Labels[bundle.PackageLabel],
+		Release:             bundleRelease(config.Config.Labels, *csv),
+		CSV:                 *csv,
+		Manifests:           manifests,
+	}, nil
+}
+
+// fetchManifest fetches the manifest of a canonical image reference, along
+// with the descriptor of the reference and, if the reference is of an index,
+// the index, whose first manifest is fetched.
+func fetchManifest(ctx context.Context, repo *remote.Repository, canonicalRef reference.Canonical) (ocispec.Descriptor, *ocispec.Index, ocispec.Manifest, error) {
 	refDesc, err := repo.Resolve(ctx, canonicalRef.Digest().String())
 	if err != nil {
-		return nil, fmt.Errorf("failed to get descriptor for canonical reference %s: %w", canonicalRef, err)
+		return ocispec.Descriptor{}, nil, ocispec.Manifest{}, fmt.Errorf("failed to get descriptor for canonical reference %s: %w", canonicalRef, err)
 	}
 
 	// Fetch the ref blob
 	refDesc, refBytes, err := oras.FetchBytes(ctx, repo, refDesc.Digest.String(), oras.FetchBytesOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch manifest for %s: %w", canonicalRef, err)
+		return ocispec.Descriptor{}, nil, ocispec.Manifest{}, fmt.Errorf("failed to fetch manifest for %s: %w", canonicalRef, err)
 	}
 
 	var (
@@ -60,64 +169,76 @@ func FetchRegistryV1Bundle(ctx context.Context, canonicalRef reference.Canonical
 	switch refDesc.MediaType {
 	case ocispec.MediaTypeImageManifest, manifest.DockerV2Schema2MediaType:
 		if err := json.Unmarshal(refBytes, &imageManifest); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal manifest for %s: %w", canonicalRef, err)
+			return ocispec.Descriptor{}, nil, ocispec.Manifest{}, fmt.Errorf("failed to unmarshal manifest for %s: %w", canonicalRef, err)
 		}
 	case ocispec.MediaTypeImageIndex, manifest.DockerV2ListMediaType:
 		imageIndex = &ocispec.Index{}
 		if err := json.Unmarshal(refBytes, &imageIndex); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal index for %s: %w", canonicalRef, err)
+			return ocispec.Descriptor{}, nil, ocispec.Manifest{}, fmt.Errorf("failed to unmarshal index for %s: %w", canonicalRef, err)
 		}
 
 		_, manifestBytes, err := oras.FetchBytes(ctx, repo, imageIndex.Manifests[0].Digest.String(), oras.FetchBytesOptions{})
 		if err != nil {
-			return nil, fmt.Errorf("failed to fetch manifest for %s: %w", canonicalRef, err)
+			return ocispec.Descriptor{}, nil, ocispec.Manifest{}, fmt.Errorf("failed to fetch manifest for %s: %w", canonicalRef, err)
 		}
 		if err := json.Unmarshal(manifestBytes, &imageManifest); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal manifest for %s: %w", canonicalRef, err)
+			return ocispec.Descriptor{}, nil, ocispec.Manifest{}, fmt.Errorf("failed to unmarshal manifest for %s: %w", canonicalRef, err)
 		}
 	}
+	return refDesc, imageIndex, imageManifest, nil
+}
 
-	// Fetch the config blob
+// fetchConfig fetches the config of an image manifest.
+func fetchConfig(ctx context.Context, repo *remote.Repository, canonicalRef reference.Canonical, imageManifest ocispec.Manifest) (ocispec.Image, error) {
 	configReader, err := repo.Fetch(ctx, imageManifest.Config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch config for %s: %w", canonicalRef, err)
+		return ocispec.Image{}, fmt.Errorf("failed to fetch config for %s: %w", canonicalRef, err)
 	}
 	defer configReader.Close()
 	configVerifyReader := content.NewVerifyReader(configReader, imageManifest.Config)
 	configBytes, err := io.ReadAll(configVerifyReader)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config for %s: %w", canonicalRef, err)
+		return ocispec.Image{}, fmt.Errorf("failed to read config for %s: %w", canonicalRef, err)
 	}
 
 	var config ocispec.Image
 	if err := json.Unmarshal(configBytes, &config); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal config blob for %s: %w", canonicalRef, err)
+		return ocispec.Image{}, fmt.Errorf("failed to unmarshal config blob for %s: %w", canonicalRef, err)
 	}
+	return config, nil
+}
 
-	// Extract CSV from layers
-	csv, err := extractClusterServiceVersion(ctx, repo, imageManifest)
-	if err != nil {
-		return nil, fmt.Errorf("failed to extract cluster service version for %s: %w", canonicalRef, err)
+// bundleRelease returns the release of a bundle from the release label of its
+// image or, failing that, from the suffix after the version in the name of its
+// CSV, as in foo.v1.2.3-4. It returns an empty string if there is neither.
+func bundleRelease(labels map[string]string, csv v1alpha1.ClusterServiceVersion) string {
+	if release := labels[ReleaseLabel]; release != "" {
+		return release
 	}
-
-	return &RegistryV1ImageInfo{
-		Reference:           canonicalRef,
-		ReferenceDescriptor: refDesc,
-		Index:               imageIndex,
-		Manifest:            imageManifest,
-		ImageConfig:         config,
-		PackageName:         config.Config.Labels[bundle.PackageLabel],
-		CSV:                 *csv,
-	}, nil
+	version := csv.Spec.Version.String()
+	i := strings.LastIndex(csv.Name, ".v"+version)
+	if i < 0 {
+		return ""
+	}
+	release, ok := strings.CutPrefix(csv.Name[i+len(".v"+version):], "-")
+	if !ok {
+		return ""
+	}
+	return release
 }
 
-// extractBundleVersion attempts to extract the bundle version from various label sources
-func extractClusterServiceVersion(ctx context.Context, repo *remote.Repository, manifest ocispec.Manifest) (*v1alpha1.ClusterServiceVersion, error) {
+// extractManifests extracts the CSV of a bundle image and its other
+// manifests, by file name, from the image's layers.
+func extractManifests(ctx context.Context, repo *remote.Repository, manifest ocispec.Manifest) (*v1alpha1.ClusterServiceVersion, map[string][]byte, error) {
 	tmpDir, err := os.MkdirTemp("", "extensiondb-bundle-extract-")
 	if err != nil {
-		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+		return nil, nil, fmt.Errorf("failed to create temporary directory: %w", err)
 	}
 	defer os.RemoveAll(tmpDir)
+	otherDir := filepath.Join(tmpDir, "other")
+	if err := os.Mkdir(otherDir, 0o700); err != nil {
+		return nil, nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
 
 	for _, layer := range manifest.Layers {
 		if err := func() error {
@@ -155,9 +276,12 @@ func extractClusterServiceVersion(ctx context.Context, repo *remote.Repository,
 				if err != nil {
 					return false, err
 				}
-				if isCSV {
+				switch {
+				case isCSV:
 					h.Name = "./csv.yaml"
-				} else {
+				case h.Typeflag == tar.TypeReg && filepath.Dir(name) == "manifests":
+					h.Name = "./other/" + filepath.Base(name)
+				default:
 					return false, nil
 				}
 
@@ -173,20 +297,33 @@ func extractClusterServiceVersion(ctx context.Context, repo *remote.Repository,
 			}))
 			return err
 		}(); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
 	csvPath := filepath.Join(tmpDir, "csv.yaml")
 	csvBytes, err := os.ReadFile(csvPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read CSV file: %w", err)
+		return nil, nil, fmt.Errorf("failed to read CSV file: %w", err)
 	}
 
 	var csv v1alpha1.ClusterServiceVersion
 	if err := yaml.Unmarshal(csvBytes, &csv); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal CSV file: %w", err)
+		return nil, nil, fmt.Errorf("failed to unmarshal CSV file: %w", err)
+	}
+
+	entries, err := os.ReadDir(otherDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read manifests: %w", err)
+	}
+	manifests := make(map[string][]byte, len(entries))
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(otherDir, e.Name()))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read manifest %s: %w", e.Name(), err)
+		}
+		manifests[e.Name()] = data
 	}
 
-	return &csv, nil
+	return &csv, manifests, nil
 }
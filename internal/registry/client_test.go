@@ -0,0 +1,113 @@
+package registry_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/registry"
+	"github.com/joelanford/extensiondb/internal/registry/registrytest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+func TestResolveDigest(t *testing.T) {
+	r := registrytest.New(t)
+	want := r.PushBundle(t, "example/foo-bundle", "v1.0.0", registrytest.Bundle{Package: "foo", Version: "1.0.0"})
+
+	tagged, err := reference.WithTag(r.Named("example/foo-bundle"), "v1.0.0")
+	require.NoError(t, err)
+	got, err := registry.ResolveDigest(t.Context(), tagged)
+	require.NoError(t, err)
+	assert.Equal(t, want.String(), got.String())
+}
+
+func TestCheckDigest(t *testing.T) {
+	r := registrytest.New(t)
+	ref := r.PushBundle(t, "example/foo-bundle", "v1.0.0", registrytest.Bundle{Package: "foo", Version: "1.0.0"})
+	require.NoError(t, registry.CheckDigest(t.Context(), ref))
+
+	r.Delete("example/foo-bundle", ref.Digest())
+	assert.ErrorIs(t, registry.CheckDigest(t.Context(), ref), registry.ErrNotFound)
+
+	tagged, err := reference.WithTag(r.Named("example/foo-bundle"), "v1.0.0")
+	require.NoError(t, err)
+	_, err = registry.ResolveDigest(t.Context(), tagged)
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+}
+
+func TestFetchRegistryV1Bundle(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, index := range []bool{false, true} {
+		r := registrytest.New(t)
+		ref := r.PushBundle(t, "example/foo-bundle", "v1.2.3", registrytest.Bundle{
+			Package: "foo",
+			Version: "1.2.3",
+			Created: created,
+			Index:   index,
+			Manifests: map[string]string{
+				"example.com_foos.yaml": "kind: CustomResourceDefinition\n",
+			},
+		})
+
+		info, err := registry.FetchRegistryV1Bundle(t.Context(), ref)
+		require.NoError(t, err)
+		assert.Equal(t, ref.Digest(), info.ReferenceDescriptor.Digest)
+		assert.Equal(t, index, info.Index != nil)
+		assert.Equal(t, "foo", info.PackageName)
+		assert.Equal(t, "1.2.3", info.CSV.Spec.Version.String())
+		assert.Equal(t, "foo.v1.2.3", info.CSV.Name)
+		require.NotNil(t, info.ImageConfig.Created)
+		assert.True(t, created.Equal(*info.ImageConfig.Created))
+		assert.Empty(t, info.Release)
+		assert.Equal(t, map[string][]byte{"example.com_foos.yaml": []byte("kind: CustomResourceDefinition\n")}, info.Manifests, "the CSV and metadata are not included")
+	}
+}
+
+func TestFetchRegistryV1BundleRelease(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		bundle registrytest.Bundle
+		want   string
+	}{
+		{
+			name:   "label",
+			bundle: registrytest.Bundle{Package: "foo", Version: "1.2.3", Release: "4", CSVName: "foo.v1.2.3-2"},
+			want:   "4",
+		},
+		{
+			name:   "CSV name suffix",
+			bundle: registrytest.Bundle{Package: "foo", Version: "1.2.3", CSVName: "foo.v1.2.3-0.1700000000.p"},
+			want:   "0.1700000000.p",
+		},
+		{
+			name:   "prerelease version",
+			bundle: registrytest.Bundle{Package: "foo", Version: "1.2.3-rc.1"},
+			want:   "",
+		},
+		{
+			name:   "CSV name without version",
+			bundle: registrytest.Bundle{Package: "foo", Version: "1.2.3", CSVName: "foo-4"},
+			want:   "",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := registrytest.New(t)
+			ref := r.PushBundle(t, "example/foo-bundle", "v1.2.3", tc.bundle)
+
+			info, err := registry.FetchRegistryV1Bundle(t.Context(), ref)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, info.Release)
+		})
+	}
+}
+
+func TestFetchRegistryV1BundleNotFound(t *testing.T) {
+	r := registrytest.New(t)
+	ref := r.PushBundle(t, "example/foo-bundle", "v1.0.0", registrytest.Bundle{Package: "foo", Version: "1.0.0"})
+
+	other, err := reference.WithDigest(r.Named("example/bar-bundle"), ref.Digest())
+	require.NoError(t, err)
+	_, err = registry.FetchRegistryV1Bundle(t.Context(), other)
+	assert.Error(t, err)
+}
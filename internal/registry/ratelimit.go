@@ -0,0 +1,127 @@
+package registry
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+
+	"golang.org/x/time/rate"
+	orasremote "oras.land/oras-go/v2/registry/remote"
+	"oras.land/oras-go/v2/registry/remote/auth"
+)
+
+// RateLimits limits how many requests per second are made to each registry
+// host, so that large ingestions stay within the request limits of
+// registries such as quay.io and registry.redhat.io. A limit of 0 doesn't
+// limit the host.
+type RateLimits struct {
+	// Default is the limit of hosts that have no limit of their own.
+	Default float64
+
+	// Hosts are the limits of individual registry hosts, such as quay.io.
+	Hosts map[string]float64
+}
+
+// Limit returns the limit of a registry host.
+func (l RateLimits) Limit(host string) float64 {
+	if r, ok := l.Hosts[host]; ok {
+		return r
+	}
+	return l.Default
+}
+
+// RateLimiter limits the rate of the requests to each registry host, as
+// configured by its RateLimits. Each host's limit allows bursts of up to one
+// second's worth of requests. A nil *RateLimiter doesn't limit any host.
+type RateLimiter struct {
+	limits RateLimits
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+// NewRateLimiter returns a RateLimiter with the given limits.
+func NewRateLimiter(limits RateLimits) *RateLimiter {
+	return &RateLimiter{limits: limits, limiters: map[string]*rate.Limiter{}}
+}
+
+// Wait waits until a request to host is allowed by its limit, or returns an
+// error if ctx is done first.
+func (l *RateLimiter) Wait(ctx context.Context, host string) error {
+	if l == nil {
+		return nil
+	}
+	r := l.limits.Limit(host)
+	if r <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	limiter, ok := l.limiters[host]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(r), int(max(math.Ceil(r), 1)))
+		l.limiters[host] = limiter
+	}
+	l.mu.Unlock()
+	return limiter.Wait(ctx)
+}
+
+// Transport returns an http.RoundTripper that waits for the limit of the
+// host of each request before sending it with base.
+func (l *RateLimiter) Transport(base http.RoundTripper) http.RoundTripper {
+	if l == nil {
+		return base
+	}
+	return &rateLimitedTransport{limiter: l, base: base}
+}
+
+type rateLimitedTransport struct {
+	limiter *RateLimiter
+	base    http.RoundTripper
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context(), req.URL.Host); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// client returns an oras client that sends the requests of c, including
+// those for auth tokens, through the limiter's Transport. A nil c is oras's
+// default client, auth.DefaultClient.
+func (l *RateLimiter) client(c orasremote.Client) orasremote.Client {
+	if l == nil {
+		return c
+	}
+	if c == nil {
+		c = auth.DefaultClient
+	}
+	authClient, ok := c.(*auth.Client)
+	if !ok {
+		return doerFunc(func(req *http.Request) (*http.Response, error) {
+			if err := l.Wait(req.Context(), req.URL.Host); err != nil {
+				return nil, err
+			}
+			return c.Do(req)
+		})
+	}
+	httpClient := http.Client{}
+	if authClient.Client != nil {
+		httpClient = *authClient.Client
+	}
+	base := httpClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	httpClient.Transport = l.Transport(base)
+	limited := *authClient
+	limited.Client = &httpClient
+	return &limited
+}
+
+type doerFunc func(*http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
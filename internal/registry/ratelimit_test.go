@@ -0,0 +1,78 @@
+package registry_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimits_Limit(t *testing.T) {
+	limits := registry.RateLimits{Default: 10, Hosts: map[string]float64{"quay.io": 2, "localhost": 0}}
+	assert.Equal(t, 10.0, limits.Limit("registry.redhat.io"))
+	assert.Equal(t, 2.0, limits.Limit("quay.io"))
+	assert.Equal(t, 0.0, limits.Limit("localhost"))
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := registry.NewRateLimiter(registry.RateLimits{Hosts: map[string]float64{"quay.io": 20}})
+
+	// The first second's worth of requests are allowed at once, and the
+	// rest wait for the limit.
+	start := time.Now()
+	for range 25 {
+		require.NoError(t, l.Wait(t.Context(), "quay.io"))
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+
+	// Hosts without a limit, and nil limiters, don't wait.
+	start = time.Now()
+	for range 100 {
+		require.NoError(t, l.Wait(t.Context(), "registry.redhat.io"))
+		require.NoError(t, (*registry.RateLimiter)(nil).Wait(t.Context(), "quay.io"))
+	}
+	assert.Less(t, time.Since(start), 200*time.Millisecond)
+
+	l = registry.NewRateLimiter(registry.RateLimits{Default: 1})
+	require.NoError(t, l.Wait(t.Context(), "quay.io"))
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	assert.Error(t, l.Wait(ctx, "quay.io"), "waiting requests are canceled with their context")
+}
+
+func TestRateLimiter_Transport(t *testing.T) {
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+	}))
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	// Every request is limited, not only the first of an operation.
+	l := registry.NewRateLimiter(registry.RateLimits{Hosts: map[string]float64{u.Host: 10}})
+	client := &http.Client{Transport: l.Transport(http.DefaultTransport)}
+	start := time.Now()
+	for range 15 {
+		resp, err := client.Get(srv.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+	assert.EqualValues(t, 15, requests.Load())
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	_, err = client.Do(req)
+	assert.Error(t, err)
+	assert.EqualValues(t, 15, requests.Load(), "canceled requests are not sent")
+}
@@ -0,0 +1,201 @@
+package registrytest
+
+import (
+	"archive/tar"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Bundle describes a synthetic registry+v1 bundle image. Its only layer holds
+// a ClusterServiceVersion, any other manifests, and the bundle annotations,
+// and its config carries the bundle labels.
+type Bundle struct {
+	Package string
+	Version string
+
+	// Created is the creation time recorded in the image config.
+	Created time.Time
+
+	// Release, if set, is recorded in the image config's release label.
+	Release string
+
+	// CSVName overrides the name of the CSV, which defaults to
+	// <package>.v<version>.
+	CSVName string
+
+	// Index serves the image as an index of a single linux/amd64 manifest,
+	// like multi-arch bundle images, instead of as a plain manifest.
+	Index bool
+
+	// RelatedImages are listed in the CSV's spec.relatedImages.
+	RelatedImages []string
+
+	// Manifests are added to the manifests directory alongside the CSV,
+	// keyed by file name, such as CustomResourceDefinitions.
+	Manifests map[string]string
+}
+
+type image struct {
+	digest    digest.Digest
+	manifests []manifest
+	blobs     map[digest.Digest][]byte
+}
+
+func (b Bundle) image() (*image, error) {
+	labels := map[string]string{
+		"operators.operatorframework.io.bundle.mediatype.v1": "registry+v1",
+		"operators.operatorframework.io.bundle.manifests.v1": "manifests/",
+		"operators.operatorframework.io.bundle.metadata.v1":  "metadata/",
+		"operators.operatorframework.io.bundle.package.v1":   b.Package,
+	}
+	files, err := b.files(labels)
+	if err != nil {
+		return nil, err
+	}
+	if b.Release != "" {
+		labels["release"] = b.Release
+	}
+	return newImage(labels, b.Created, files, b.Index)
+}
+
+// newImage returns a linux/amd64 image with the labels and a single layer of
+// the files, served as an index of that image if index is set.
+func newImage(labels map[string]string, created time.Time, files []file, index bool) (*image, error) {
+	img := &image{blobs: map[digest.Digest][]byte{}}
+	addBlob := func(mediaType string, data []byte) ocispec.Descriptor {
+		dgst := digest.FromBytes(data)
+		img.blobs[dgst] = data
+		return ocispec.Descriptor{MediaType: mediaType, Digest: dgst, Size: int64(len(data))}
+	}
+	addManifest := func(mediaType string, v any) (ocispec.Descriptor, error) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		img.manifests = append(img.manifests, manifest{mediaType: mediaType, data: data})
+		return ocispec.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(data), Size: int64(len(data))}, nil
+	}
+
+	layer, diffID, err := gzipLayer(files, created)
+	if err != nil {
+		return nil, err
+	}
+	created = created.UTC()
+	config, err := json.Marshal(ocispec.Image{
+		Created:  &created,
+		Platform: ocispec.Platform{Architecture: "amd64", OS: "linux"},
+		Config:   ocispec.ImageConfig{Labels: labels},
+		RootFS:   ocispec.RootFS{Type: "layers", DiffIDs: []digest.Digest{diffID}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	desc, err := addManifest(ocispec.MediaTypeImageManifest, ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    addBlob(ocispec.MediaTypeImageConfig, config),
+		Layers:    []ocispec.Descriptor{addBlob(ocispec.MediaTypeImageLayerGzip, layer)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if index {
+		desc.Platform = &ocispec.Platform{Architecture: "amd64", OS: "linux"}
+		desc, err = addManifest(ocispec.MediaTypeImageIndex, ocispec.Index{
+			Versioned: specs.Versioned{SchemaVersion: 2},
+			MediaType: ocispec.MediaTypeImageIndex,
+			Manifests: []ocispec.Descriptor{desc},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	img.digest = desc.Digest
+	return img, nil
+}
+
+// files returns the files of the bundle's layer.
+func (b Bundle) files(labels map[string]string) ([]file, error) {
+	relatedImages := make([]map[string]string, 0, len(b.RelatedImages))
+	for i, image := range b.RelatedImages {
+		relatedImages = append(relatedImages, map[string]string{"name": fmt.Sprintf("image-%d", i), "image": image})
+	}
+	csv, err := yaml.Marshal(map[string]any{
+		"apiVersion": "operators.coreos.com/v1alpha1",
+		"kind":       "ClusterServiceVersion",
+		"metadata":   map[string]any{"name": cmp.Or(b.CSVName, b.Package+".v"+b.Version)},
+		"spec": map[string]any{
+			"displayName":   b.Package,
+			"version":       b.Version,
+			"relatedImages": relatedImages,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	annotations, err := yaml.Marshal(map[string]any{"annotations": labels})
+	if err != nil {
+		return nil, err
+	}
+
+	files := []file{
+		{"manifests/" + b.Package + ".clusterserviceversion.yaml", csv},
+		{"metadata/annotations.yaml", annotations},
+	}
+	for _, name := range slices.Sorted(maps.Keys(b.Manifests)) {
+		files = append(files, file{"manifests/" + name, []byte(b.Manifests[name])})
+	}
+	return files, nil
+}
+
+// file is a regular file of an image layer.
+type file struct {
+	name string
+	data []byte
+}
+
+// gzipLayer returns a gzipped layer of the files and the digest of its
+// uncompressed tar.
+func gzipLayer(files []file, modTime time.Time) ([]byte, digest.Digest, error) {
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	for _, f := range files {
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     f.name,
+			Mode:     0o644,
+			Size:     int64(len(f.data)),
+			ModTime:  modTime,
+		}); err != nil {
+			return nil, "", err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, "", err
+	}
+
+	var gzBuf bytes.Buffer
+	gw := gzip.NewWriter(&gzBuf)
+	if _, err := gw.Write(tarBuf.Bytes()); err != nil {
+		return nil, "", err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, "", err
+	}
+	return gzBuf.Bytes(), digest.FromBytes(tarBuf.Bytes()), nil
+}
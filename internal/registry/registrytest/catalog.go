@@ -0,0 +1,75 @@
+package registrytest
+
+import (
+	"cmp"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.podman.io/image/v5/docker/reference"
+)
+
+// Catalog describes a synthetic catalog image, whose only layer holds a
+// file-based catalog, or the SQLite database of a catalog from before
+// file-based catalogs.
+type Catalog struct {
+	// Files are the files of the file-based catalog, keyed by their path
+	// relative to ConfigsDir, such as foo/catalog.yaml.
+	Files map[string]string
+
+	// ConfigsDir is the directory of the file-based catalog in the image,
+	// recorded in the image's configs label. It defaults to /configs.
+	ConfigsDir string
+
+	// Created is the creation time recorded in the image config.
+	Created time.Time
+
+	// OtherFiles are added to the layer outside of ConfigsDir, keyed by
+	// their path in the image, such as bin/opm.
+	OtherFiles map[string]string
+
+	// Database, if set, makes the image a SQLite catalog image. It is the
+	// content of the database at /database/index.db, which is recorded in
+	// the image's database label instead of a configs label, and Files and
+	// ConfigsDir are ignored.
+	Database []byte
+}
+
+func (c Catalog) image() (*image, error) {
+	var (
+		labels map[string]string
+		files  []file
+	)
+	if c.Database != nil {
+		labels = map[string]string{
+			"operators.operatorframework.io.index.database.v1": "/database/index.db",
+		}
+		files = append(files, file{"database/index.db", c.Database})
+	} else {
+		configsDir := cmp.Or(c.ConfigsDir, "/configs")
+		labels = map[string]string{
+			"operators.operatorframework.io.index.configs.v1": configsDir,
+		}
+		for _, name := range slices.Sorted(maps.Keys(c.Files)) {
+			files = append(files, file{strings.TrimPrefix(path.Join(configsDir, name), "/"), []byte(c.Files[name])})
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.OtherFiles)) {
+		files = append(files, file{name, []byte(c.OtherFiles[name])})
+	}
+	return newImage(labels, c.Created, files, false)
+}
+
+// PushCatalog adds a synthetic catalog image to a repository, tags it, and
+// returns its canonical reference.
+func (r *Registry) PushCatalog(t testing.TB, repo, tag string, c Catalog) reference.Canonical {
+	t.Helper()
+	img, err := c.image()
+	if err != nil {
+		t.Fatalf("error building catalog image: %v", err)
+	}
+	return r.push(t, repo, tag, img)
+}
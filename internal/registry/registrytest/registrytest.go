@@ -0,0 +1,194 @@
+// Package registrytest provides an in-process OCI distribution server that
+// serves synthetic bundle and catalog images, so that fetching and ingesting
+// bundles can be tested without network access.
+package registrytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+// Registry is a read-only OCI distribution server. Images are added with
+// PushBundle or PushCatalog and served from every repository they were pushed
+// to.
+type Registry struct {
+	server *httptest.Server
+
+	mu        sync.RWMutex
+	blobs     map[digest.Digest][]byte
+	manifests map[digest.Digest]manifest
+	tags      map[string]map[string]digest.Digest // repository -> tag -> digest
+	repos     map[string]map[digest.Digest]bool   // repository -> manifest digests
+}
+
+type manifest struct {
+	mediaType string
+	data      []byte
+}
+
+// New starts a registry that is stopped when the test finishes. The registry
+// serves plain HTTP, so New marks it as insecure in a registries.conf file
+// that it points the CONTAINERS_REGISTRIES_CONF environment variable at.
+// Tests that use New can't run in parallel.
+func New(t testing.TB) *Registry {
+	t.Helper()
+	r := &Registry{
+		blobs:     map[digest.Digest][]byte{},
+		manifests: map[digest.Digest]manifest{},
+		tags:      map[string]map[string]digest.Digest{},
+		repos:     map[string]map[digest.Digest]bool{},
+	}
+	r.server = httptest.NewServer(r)
+	t.Cleanup(r.server.Close)
+
+	conf := filepath.Join(t.TempDir(), "registries.conf")
+	data := fmt.Sprintf("[[registry]]\nlocation = %q\ninsecure = true\n", r.Host())
+	if err := os.WriteFile(conf, []byte(data), 0o644); err != nil {
+		t.Fatalf("error writing registries.conf: %v", err)
+	}
+	t.Setenv("CONTAINERS_REGISTRIES_CONF", conf)
+	return r
+}
+
+// Host returns the host:port of the registry, for use in image references.
+func (r *Registry) Host() string {
+	return r.server.Listener.Addr().String()
+}
+
+// Named returns the reference of a repository of the registry.
+func (r *Registry) Named(repo string) reference.Named {
+	named, err := reference.ParseNormalizedNamed(r.Host() + "/" + repo)
+	if err != nil {
+		panic(err)
+	}
+	return named
+}
+
+// PushBundle adds a synthetic bundle image to a repository, tags it, and
+// returns its canonical reference.
+func (r *Registry) PushBundle(t testing.TB, repo, tag string, b Bundle) reference.Canonical {
+	t.Helper()
+	img, err := b.image()
+	if err != nil {
+		t.Fatalf("error building bundle image: %v", err)
+	}
+	return r.push(t, repo, tag, img)
+}
+
+// push adds an image to a repository, tags it, and returns its canonical
+// reference.
+func (r *Registry) push(t testing.TB, repo, tag string, img *image) reference.Canonical {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for dgst, data := range img.blobs {
+		r.blobs[dgst] = data
+	}
+	if r.repos[repo] == nil {
+		r.repos[repo] = map[digest.Digest]bool{}
+	}
+	for _, m := range img.manifests {
+		dgst := digest.FromBytes(m.data)
+		r.manifests[dgst] = m
+		r.repos[repo][dgst] = true
+	}
+	if r.tags[repo] == nil {
+		r.tags[repo] = map[string]digest.Digest{}
+	}
+	r.tags[repo][tag] = img.digest
+
+	ref, err := reference.WithDigest(r.Named(repo), img.digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ref
+}
+
+// Delete removes an image from a repository and untags it there, as if it
+// had been deleted or garbage collected.
+func (r *Registry) Delete(repo string, dgst digest.Digest) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.repos[repo], dgst)
+	for tag, d := range r.tags[repo] {
+		if d == dgst {
+			delete(r.tags[repo], tag)
+		}
+	}
+}
+
+var (
+	manifestPath = regexp.MustCompile(`^/v2/(.+)/manifests/([^/]+)$`)
+	blobPath     = regexp.MustCompile(`^/v2/(.+)/blobs/([^/]+)$`)
+)
+
+// ServeHTTP implements the pull endpoints of the OCI distribution spec.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "the registry is read-only")
+		return
+	}
+	if req.URL.Path == "/v2/" || req.URL.Path == "/v2" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if m := manifestPath.FindStringSubmatch(req.URL.Path); m != nil {
+		repo, ref := m[1], m[2]
+		dgst, err := digest.Parse(ref)
+		if err != nil {
+			dgst = r.tags[repo][ref]
+		}
+		mf, ok := r.manifests[dgst]
+		if !ok || !r.repos[repo][dgst] {
+			writeError(w, http.StatusNotFound, "MANIFEST_UNKNOWN", fmt.Sprintf("manifest %s not found in %s", ref, repo))
+			return
+		}
+		writeContent(w, req, mf.mediaType, dgst, mf.data)
+		return
+	}
+	if m := blobPath.FindStringSubmatch(req.URL.Path); m != nil {
+		repo, ref := m[1], m[2]
+		dgst, err := digest.Parse(ref)
+		data, ok := r.blobs[dgst]
+		if err != nil || !ok || r.repos[repo] == nil {
+			writeError(w, http.StatusNotFound, "BLOB_UNKNOWN", fmt.Sprintf("blob %s not found in %s", ref, repo))
+			return
+		}
+		writeContent(w, req, "application/octet-stream", dgst, data)
+		return
+	}
+	writeError(w, http.StatusNotFound, "NAME_UNKNOWN", "not found")
+}
+
+func writeContent(w http.ResponseWriter, req *http.Request, mediaType string, dgst digest.Digest, data []byte) {
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Docker-Content-Digest", dgst.String())
+	w.WriteHeader(http.StatusOK)
+	if req.Method == http.MethodGet {
+		_, _ = w.Write(data)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"errors": []map[string]string{{"code": code, "message": message}},
+	})
+}
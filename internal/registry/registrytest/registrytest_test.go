@@ -0,0 +1,86 @@
+package registrytest_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/registry/registrytest"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func get(t *testing.T, method, url string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(t.Context(), method, url, nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, body
+}
+
+func TestRegistry(t *testing.T) {
+	r := registrytest.New(t)
+	ref := r.PushBundle(t, "example/foo-bundle", "v1.0.0", registrytest.Bundle{
+		Package: "foo",
+		Version: "1.0.0",
+		Created: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	})
+	assert.Equal(t, r.Host()+"/example/foo-bundle", ref.Name())
+	base := "http://" + r.Host() + "/v2/example/foo-bundle"
+
+	resp, _ := get(t, http.MethodGet, "http://"+r.Host()+"/v2/")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, body := get(t, http.MethodHead, base+"/manifests/v1.0.0")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, ref.Digest().String(), resp.Header.Get("Docker-Content-Digest"))
+	assert.Equal(t, ocispec.MediaTypeImageManifest, resp.Header.Get("Content-Type"))
+	assert.Empty(t, body)
+
+	resp, body = get(t, http.MethodGet, base+"/manifests/"+ref.Digest().String())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, ref.Digest(), digest.FromBytes(body))
+	var m ocispec.Manifest
+	require.NoError(t, json.Unmarshal(body, &m))
+	require.Len(t, m.Layers, 1)
+
+	resp, body = get(t, http.MethodGet, base+"/blobs/"+m.Config.Digest.String())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var config ocispec.Image
+	require.NoError(t, json.Unmarshal(body, &config))
+	assert.Equal(t, "foo", config.Config.Labels["operators.operatorframework.io.bundle.package.v1"])
+
+	resp, body = get(t, http.MethodGet, base+"/blobs/"+m.Layers[0].Digest.String())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, m.Layers[0].Digest, digest.FromBytes(body))
+
+	resp, _ = get(t, http.MethodGet, "http://"+r.Host()+"/v2/example/bar-bundle/manifests/"+ref.Digest().String())
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "manifests are only served from the repositories they were pushed to")
+	resp, _ = get(t, http.MethodGet, base+"/manifests/v2.0.0")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, _ = get(t, http.MethodPut, base+"/manifests/v2.0.0")
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestBundleIndex(t *testing.T) {
+	r := registrytest.New(t)
+	ref := r.PushBundle(t, "example/foo-bundle", "v1.0.0", registrytest.Bundle{Package: "foo", Version: "1.0.0", Index: true})
+
+	resp, body := get(t, http.MethodGet, "http://"+r.Host()+"/v2/example/foo-bundle/manifests/"+ref.Digest().String())
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, ocispec.MediaTypeImageIndex, resp.Header.Get("Content-Type"))
+	var idx ocispec.Index
+	require.NoError(t, json.Unmarshal(body, &idx))
+	require.Len(t, idx.Manifests, 1)
+
+	resp, _ = get(t, http.MethodGet, "http://"+r.Host()+"/v2/example/foo-bundle/manifests/"+idx.Manifests[0].Digest.String())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
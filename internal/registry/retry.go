@@ -0,0 +1,88 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"oras.land/oras-go/v2/registry/remote/errcode"
+)
+
+// Backoff configures how Retry retries an operation that fails with a
+// transient error. The delay before each retry doubles, starting at Initial,
+// up to Max.
+type Backoff struct {
+	// Attempts is the maximum number of attempts, including the first.
+	Attempts int
+	Initial  time.Duration
+	Max      time.Duration
+}
+
+// DefaultBackoff is the Backoff of registry fetches.
+var DefaultBackoff = Backoff{Attempts: 4, Initial: time.Second, Max: 30 * time.Second}
+
+// RetryError is returned by Retry when an operation fails, either with an
+// error that is not transient or on its last attempt.
+type RetryError struct {
+	// Attempts is the number of attempts that were made.
+	Attempts int
+	Err      error
+}
+
+func (e *RetryError) Error() string {
+	if e.Attempts == 1 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%v (after %d attempts)", e.Err, e.Attempts)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// Retry calls fn until it succeeds, fails with an error that is not
+// transient, or has been called b.Attempts times, waiting between attempts
+// as configured by b. Errors are returned as a *RetryError.
+func Retry(ctx context.Context, b Backoff, fn func(context.Context) error) error {
+	delay := b.Initial
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		if attempt >= b.Attempts || !IsTransient(err) || ctx.Err() != nil {
+			return &RetryError{Attempts: attempt, Err: err}
+		}
+		select {
+		case <-ctx.Done():
+			return &RetryError{Attempts: attempt, Err: err}
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, b.Max)
+	}
+}
+
+// IsTransient reports whether err may succeed if retried: timeouts, reset,
+// refused, and prematurely closed connections, and registry responses that
+// ask the client to back off or report a server error. Other network errors,
+// such as failed TLS verification or unknown hosts, are not transient,
+// although they are net.Errors too.
+func IsTransient(err error) bool {
+	var errResp *errcode.ErrorResponse
+	if errors.As(err, &errResp) {
+		return errResp.StatusCode == http.StatusTooManyRequests || errResp.StatusCode >= http.StatusInternalServerError
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED)
+}
@@ -0,0 +1,84 @@
+package registry_test
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"oras.land/oras-go/v2/registry/remote/errcode"
+)
+
+func TestIsTransient(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"too many requests", &errcode.ErrorResponse{StatusCode: http.StatusTooManyRequests}, true},
+		{"server error", &errcode.ErrorResponse{StatusCode: http.StatusBadGateway}, true},
+		{"not found", &errcode.ErrorResponse{StatusCode: http.StatusNotFound}, false},
+		{"unauthorized", &errcode.ErrorResponse{StatusCode: http.StatusUnauthorized}, false},
+		{"unexpected EOF", io.ErrUnexpectedEOF, true},
+		{"connection reset", syscall.ECONNRESET, true},
+		{"wrapped", errors.Join(errors.New("failed to fetch"), syscall.ECONNREFUSED), true},
+		{"timeout", &url.Error{Op: "Get", URL: "https://quay.io/v2/", Err: &net.OpError{Op: "dial", Err: timeoutError{}}}, true},
+		{"reset url error", &url.Error{Op: "Get", URL: "https://quay.io/v2/", Err: &net.OpError{Op: "read", Err: syscall.ECONNRESET}}, true},
+		{"tls verification", &url.Error{Op: "Get", URL: "https://quay.io/v2/", Err: x509.UnknownAuthorityError{}}, false},
+		{"unknown host", &url.Error{Op: "Get", URL: "https://nxdomain.example/v2/", Err: &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "nxdomain.example", IsNotFound: true}}}, false},
+		{"other", errors.New("invalid manifest"), false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, registry.IsTransient(tc.err))
+		})
+	}
+}
+
+// timeoutError is a net.Error that reports a timeout.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestRetry(t *testing.T) {
+	b := registry.Backoff{Attempts: 3, Initial: time.Millisecond, Max: time.Millisecond}
+
+	calls := 0
+	require.NoError(t, registry.Retry(t.Context(), b, func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return io.ErrUnexpectedEOF
+		}
+		return nil
+	}))
+	assert.Equal(t, 3, calls, "transient errors are retried")
+
+	calls = 0
+	err := registry.Retry(t.Context(), b, func(context.Context) error {
+		calls++
+		return io.ErrUnexpectedEOF
+	})
+	var retryErr *registry.RetryError
+	require.ErrorAs(t, err, &retryErr)
+	assert.Equal(t, 3, retryErr.Attempts, "attempts stop at the backoff's limit")
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	calls = 0
+	err = registry.Retry(t.Context(), b, func(context.Context) error {
+		calls++
+		return registry.ErrNotFound
+	})
+	require.ErrorAs(t, err, &retryErr)
+	assert.Equal(t, 1, retryErr.Attempts, "errors that aren't transient aren't retried")
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+}
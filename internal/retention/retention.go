@@ -0,0 +1,155 @@
+// Package retention deletes the rows of the audit tables, ingestion runs and
+// the records of sent lifecycle notifications, once they are older than their
+// retention period, and reports how many rows it reclaims as Prometheus
+// metrics.
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultBatchSize is how many rows are deleted by each statement if
+// Policy.BatchSize is zero.
+const DefaultBatchSize = 1000
+
+// The audit tables, as reported in the table label of the metrics.
+const (
+	TableIngestionRuns          = "ingestion_runs"
+	TableLifecycleNotifications = "lifecycle_notifications"
+)
+
+// Policy configures how long the rows of each audit table are kept.
+type Policy struct {
+	// IngestionRuns is how long ingestion runs are kept after they start.
+	// The latest finished run is always kept. If zero, runs are kept
+	// forever.
+	IngestionRuns time.Duration
+
+	// Notifications is how long the records of sent lifecycle notifications
+	// are kept. Notifications whose events are still detected once their
+	// records are deleted are sent again, so it should be longer than the
+	// notify command's --max-age. If zero, records are kept forever.
+	Notifications time.Duration
+
+	// BatchSize is how many rows are deleted by each statement, oldest
+	// first, so that no statement holds its locks for long. If zero,
+	// DefaultBatchSize is used.
+	BatchSize int
+}
+
+// Result counts the rows that were deleted, by table.
+type Result struct {
+	IngestionRuns          int64
+	LifecycleNotifications int64
+}
+
+// Pruner deletes the rows of the audit tables that are older than the
+// policy's retention periods. It is a Prometheus collector that reports how
+// many rows it has reclaimed.
+type Pruner struct {
+	query  *query.Query
+	policy Policy
+
+	reclaimed   *prometheus.CounterVec
+	lastSuccess prometheus.Gauge
+}
+
+// NewPruner creates a pruner that applies the policy to the database of q.
+func NewPruner(q *query.Query, policy Policy) *Pruner {
+	p := &Pruner{
+		query:  q,
+		policy: policy,
+		reclaimed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "extensiondb_retention_reclaimed_rows_total",
+			Help: "Rows deleted from each audit table because they were older than its retention period.",
+		}, []string{"table"}),
+		lastSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "extensiondb_retention_last_success_timestamp_seconds",
+			Help: "Unix time at which the audit tables were last pruned without errors.",
+		}),
+	}
+	for _, table := range []string{TableIngestionRuns, TableLifecycleNotifications} {
+		p.reclaimed.WithLabelValues(table)
+	}
+	return p
+}
+
+// Prune deletes the rows that are older than their retention period as of
+// now. Each table is pruned even if another fails; their errors are returned
+// together, with the rows that were deleted before them.
+func (p *Pruner) Prune(ctx context.Context, now time.Time) (Result, error) {
+	var (
+		result Result
+		errs   []error
+		err    error
+	)
+	if p.policy.IngestionRuns > 0 {
+		result.IngestionRuns, err = p.prune(ctx, TableIngestionRuns, p.query.DeleteIngestionRunsBefore, now.Add(-p.policy.IngestionRuns))
+		errs = append(errs, err)
+	}
+	if p.policy.Notifications > 0 {
+		result.LifecycleNotifications, err = p.prune(ctx, TableLifecycleNotifications, p.query.DeleteLifecycleNotificationsBefore, now.Add(-p.policy.Notifications))
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return result, err
+	}
+	p.lastSuccess.Set(float64(now.Unix()))
+	return result, nil
+}
+
+func (p *Pruner) prune(ctx context.Context, table string, deleteBefore func(context.Context, time.Time, int) (int64, error), before time.Time) (int64, error) {
+	batchSize := p.policy.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	var total int64
+	for {
+		n, err := deleteBefore(ctx, before, batchSize)
+		total += n
+		p.reclaimed.WithLabelValues(table).Add(float64(n))
+		if err != nil {
+			return total, fmt.Errorf("error pruning %s: %w", table, err)
+		}
+		if n < int64(batchSize) {
+			return total, nil
+		}
+	}
+}
+
+// Run prunes the audit tables on interval until ctx is done, starting
+// immediately. Errors are logged and the tables are pruned again at the next
+// tick.
+func (p *Pruner) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		r, err := p.Prune(ctx, time.Now())
+		if err != nil {
+			log.Printf("error pruning audit tables: %v", err)
+		}
+		log.Printf("pruned audit tables: %d ingestion runs, %d lifecycle notifications", r.IngestionRuns, r.LifecycleNotifications)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Pruner) Describe(ch chan<- *prometheus.Desc) {
+	p.reclaimed.Describe(ch)
+	p.lastSuccess.Describe(ch)
+}
+
+func (p *Pruner) Collect(ch chan<- prometheus.Metric) {
+	p.reclaimed.Collect(ch)
+	p.lastSuccess.Collect(ch)
+}
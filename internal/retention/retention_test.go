@@ -0,0 +1,80 @@
+package retention_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/internal/retention"
+	"github.com/joelanford/extensiondb/pkg/dbtest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(dbtest.Main(m))
+}
+
+func TestPrune(t *testing.T) {
+	q := query.New(dbtest.New(t))
+	date := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for range 3 {
+		run, err := q.CreateIngestionRun(t.Context())
+		require.NoError(t, err)
+		require.NoError(t, q.FinishIngestionRun(t.Context(), run, nil))
+	}
+	latest, err := q.GetLatestIngestionRun(t.Context())
+	require.NoError(t, err)
+	for _, pkg := range []string{"foo", "bar", "baz"} {
+		require.NoError(t, q.RecordLifecycleNotification(t.Context(), pkg, "1.0", "phase", date))
+	}
+
+	// Every row is older than an hour from now, so each is reclaimed, in
+	// batches of two.
+	later := time.Now().Add(time.Hour)
+	pruner := retention.NewPruner(q, retention.Policy{IngestionRuns: time.Minute, BatchSize: 2})
+	r, err := pruner.Prune(t.Context(), later)
+	require.NoError(t, err)
+	assert.Equal(t, retention.Result{IngestionRuns: 2}, r, "the latest finished run is kept, as are notifications without a retention period")
+
+	run, err := q.GetLatestIngestionRun(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, latest.ID, run.ID)
+	sent, err := q.HasLifecycleNotification(t.Context(), "foo", "1.0", "phase", date)
+	require.NoError(t, err)
+	assert.True(t, sent)
+
+	pruner = retention.NewPruner(q, retention.Policy{IngestionRuns: time.Minute, Notifications: time.Minute})
+	r, err = pruner.Prune(t.Context(), later)
+	require.NoError(t, err)
+	assert.Equal(t, retention.Result{LifecycleNotifications: 3}, r)
+	sent, err = q.HasLifecycleNotification(t.Context(), "foo", "1.0", "phase", date)
+	require.NoError(t, err)
+	assert.False(t, sent)
+
+	// Rows within their retention period are kept.
+	require.NoError(t, q.RecordLifecycleNotification(t.Context(), "foo", "1.0", "phase", date))
+	r, err = pruner.Prune(t.Context(), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, retention.Result{}, r)
+
+	reg := prometheus.NewRegistry()
+	require.NoError(t, reg.Register(pruner))
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	reclaimed := map[string]float64{}
+	for _, f := range families {
+		if f.GetName() != "extensiondb_retention_reclaimed_rows_total" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			reclaimed[m.GetLabel()[0].GetValue()] = m.GetCounter().GetValue()
+		}
+	}
+	assert.Equal(t, map[string]float64{
+		retention.TableIngestionRuns:          0,
+		retention.TableLifecycleNotifications: 3,
+	}, reclaimed)
+}
@@ -0,0 +1,192 @@
+// Package s3 stores and reads objects with the S3 API, signing requests with
+// AWS Signature Version 4, so that S3 and S3-compatible services can be used
+// without an SDK.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned by Get for keys that have no object.
+var ErrNotFound = errors.New("object not found")
+
+// emptyPayloadHash is the SHA-256 hash of an empty request body.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Bucket is an S3 bucket.
+type Bucket struct {
+	bucket   string
+	endpoint *url.URL
+	region   string
+
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+
+	httpClient *http.Client
+}
+
+// NewBucketFromEnv returns the named bucket, using the standard
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN, and AWS_REGION
+// environment variables, and AWS_ENDPOINT_URL to use an S3-compatible
+// service.
+func NewBucketFromEnv(bucket string) (*Bucket, error) {
+	b := &Bucket{
+		bucket:          bucket,
+		region:          os.Getenv("AWS_REGION"),
+		accessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		httpClient:      http.DefaultClient,
+	}
+	if b.accessKeyID == "" || b.secretAccessKey == "" {
+		return nil, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set to use S3")
+	}
+	if b.region == "" {
+		b.region = "us-east-1"
+	}
+
+	endpoint := os.Getenv("AWS_ENDPOINT_URL")
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", b.region)
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid AWS_ENDPOINT_URL %q: %w", endpoint, err)
+	}
+	b.endpoint = u
+	return b, nil
+}
+
+// Put stores data as the object at key with the PutObject API.
+func (b *Bucket) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	req, err := b.newRequest(ctx, http.MethodPut, key, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	payloadHash := sha256.Sum256(data)
+	b.sign(req, hex.EncodeToString(payloadHash[:]), time.Now())
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return statusError(resp)
+	}
+	return nil
+}
+
+// Get returns the object at key with the GetObject API, or an error wrapping
+// ErrNotFound if there is none.
+func (b *Bucket) Get(ctx context.Context, key string) ([]byte, error) {
+	req, err := b.newRequest(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	b.sign(req, emptyPayloadHash, time.Now())
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, statusError(resp)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (b *Bucket) newRequest(ctx context.Context, method, key string, body io.Reader) (*http.Request, error) {
+	// Path-style URLs work for every bucket name and for S3-compatible services.
+	u := b.endpoint.JoinPath(b.bucket, key)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if b.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", b.sessionToken)
+	}
+	return req, nil
+}
+
+func statusError(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return fmt.Errorf("s3 responded with status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+}
+
+// sign adds the headers of an AWS Signature Version 4 signature to req. Every
+// header already set on req is signed.
+func (b *Bucket) sign(req *http.Request, payloadHash string, now time.Time) {
+	const service = "s3"
+
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.Join(values, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, strings.TrimSpace(headers[name]))
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", date, b.region, service)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalRequestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+b.secretAccessKey), date)
+	key = hmacSHA256(key, b.region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", b.accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// Role is the level of access granted to an authenticated client. Each role
+// includes the access of the roles below it.
+type Role int
+
+const (
+	RoleNone Role = iota
+	RoleReader
+	RoleAdmin
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleNone:
+		return "none"
+	case RoleReader:
+		return "reader"
+	case RoleAdmin:
+		return "admin"
+	}
+	return fmt.Sprintf("Role(%d)", int(r))
+}
+
+func parseRole(s string) (Role, error) {
+	switch s {
+	case "reader":
+		return RoleReader, nil
+	case "admin":
+		return RoleAdmin, nil
+	}
+	return RoleNone, fmt.Errorf("unknown role %q; expected reader or admin", s)
+}
+
+// errUnauthenticated is returned by an Authenticator when the request does not
+// carry credentials that it understands.
+var errUnauthenticated = errors.New("unauthenticated")
+
+// Authenticator determines the role of the client that sent a request.
+type Authenticator interface {
+	Authenticate(r *http.Request) (Role, error)
+}
+
+// Authenticators tries each authenticator in order and uses the first one
+// that recognizes the request's credentials.
+type Authenticators []Authenticator
+
+func (as Authenticators) Authenticate(r *http.Request) (Role, error) {
+	for _, a := range as {
+		role, err := a.Authenticate(r)
+		if errors.Is(err, errUnauthenticated) {
+			continue
+		}
+		return role, err
+	}
+	return RoleNone, errUnauthenticated
+}
+
+// TokenAuthenticator authenticates requests with static bearer tokens.
+type TokenAuthenticator struct {
+	tokens map[string]Role
+}
+
+// NewTokenAuthenticatorFromFile reads bearer tokens from a file. Each
+// non-empty line that is not a comment has the form "<token> <role>".
+func NewTokenAuthenticatorFromFile(path string) (*TokenAuthenticator, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ta := &TokenAuthenticator{tokens: map[string]Role{}}
+	s := bufio.NewScanner(f)
+	for lineNum := 1; s.Scan(); lineNum++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected \"<token> <role>\"", path, lineNum)
+		}
+		role, err := parseRole(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNum, err)
+		}
+		ta.tokens[fields[0]] = role
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return ta, nil
+}
+
+func (ta *TokenAuthenticator) Authenticate(r *http.Request) (Role, error) {
+	token, ok := bearerToken(r)
+	if !ok {
+		return RoleNone, errUnauthenticated
+	}
+	for t, role := range ta.tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return role, nil
+		}
+	}
+	return RoleNone, errUnauthenticated
+}
+
+// OIDCAuthenticator authenticates requests with OIDC ID tokens passed as
+// bearer tokens. Every valid token is granted the reader role, and tokens
+// whose groups claim includes one of the admin groups are granted the admin role.
+type OIDCAuthenticator struct {
+	verifier    *oidc.IDTokenVerifier
+	adminGroups []string
+}
+
+// NewOIDCAuthenticator discovers the issuer's configuration and returns an
+// authenticator that verifies tokens issued for clientID.
+func NewOIDCAuthenticator(ctx context.Context, issuerURL, clientID string, adminGroups []string) (*OIDCAuthenticator, error) {
+	provider, err := oidc.NewProvider(ctx, issuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("error discovering OIDC provider %s: %w", issuerURL, err)
+	}
+	return &OIDCAuthenticator{
+		verifier:    provider.Verifier(&oidc.Config{ClientID: clientID}),
+		adminGroups: adminGroups,
+	}, nil
+}
+
+func (oa *OIDCAuthenticator) Authenticate(r *http.Request) (Role, error) {
+	token, ok := bearerToken(r)
+	if !ok {
+		return RoleNone, errUnauthenticated
+	}
+	idToken, err := oa.verifier.Verify(r.Context(), token)
+	if err != nil {
+		return RoleNone, errUnauthenticated
+	}
+
+	var claims struct {
+		Groups []string `json:"groups"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return RoleNone, fmt.Errorf("error parsing token claims: %w", err)
+	}
+	for _, group := range claims.Groups {
+		if slices.Contains(oa.adminGroups, group) {
+			return RoleAdmin, nil
+		}
+	}
+	return RoleReader, nil
+}
+
+// ClientCertAuthenticator authenticates requests with TLS client certificates
+// that were verified by the server's client CA. Every verified certificate is
+// granted the reader role, and certificates whose subject common name is one
+// of the admin names are granted the admin role.
+type ClientCertAuthenticator struct {
+	AdminCommonNames []string
+}
+
+func (ca ClientCertAuthenticator) Authenticate(r *http.Request) (Role, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return RoleNone, errUnauthenticated
+	}
+	if slices.Contains(ca.AdminCommonNames, r.TLS.VerifiedChains[0][0].Subject.CommonName) {
+		return RoleAdmin, nil
+	}
+	return RoleReader, nil
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+// requireRole wraps a handler so that it is only served to clients that have
+// at least the given role. When the server has no authenticator, every client
+// is allowed.
+func (s *Server) requireRole(role Role, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.auth == nil {
+			h(w, r)
+			return
+		}
+		actual, err := s.auth.Authenticate(r)
+		switch {
+		case errors.Is(err, errUnauthenticated):
+			w.Header().Set("WWW-Authenticate", `Bearer realm="extensiondb"`)
+			httpError(w, http.StatusUnauthorized, errors.New("authentication required"))
+			return
+		case err != nil:
+			httpError(w, http.StatusUnauthorized, err)
+			return
+		case actual < role:
+			httpError(w, http.StatusForbidden, fmt.Errorf("%s role required", role))
+			return
+		}
+		h(w, r)
+	}
+}
@@ -0,0 +1,253 @@
+package server_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/query/querytest"
+	"github.com/joelanford/extensiondb/internal/server"
+	"github.com/joelanford/extensiondb/pkg/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+// tokenAuthenticator returns an authenticator for the tokens "reader-token"
+// and "admin-token", with the roles they are named for.
+func tokenAuthenticator(t *testing.T) *server.TokenAuthenticator {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tokens")
+	require.NoError(t, os.WriteFile(path, []byte("# tokens\nreader-token reader\n\nadmin-token admin\n"), 0o600))
+	ta, err := server.NewTokenAuthenticatorFromFile(path)
+	require.NoError(t, err)
+	return ta
+}
+
+// withClientCert returns r as if it were sent over TLS with a verified
+// client certificate for commonName.
+func withClientCert(r *http.Request, commonName string) *http.Request {
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: commonName}}}}}
+	return r
+}
+
+func TestTokenAuthenticator(t *testing.T) {
+	ta := tokenAuthenticator(t)
+	for _, tc := range []struct {
+		header string
+		role   server.Role
+		err    bool
+	}{
+		{header: "Bearer reader-token", role: server.RoleReader},
+		{header: "Bearer admin-token", role: server.RoleAdmin},
+		{header: "Bearer unknown-token", err: true},
+		{header: "admin-token", err: true},
+		{header: "Bearer ", err: true},
+		{header: "", err: true},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Authorization", tc.header)
+		role, err := ta.Authenticate(r)
+		if tc.err {
+			assert.Error(t, err, tc.header)
+			assert.Equal(t, server.RoleNone, role, tc.header)
+			continue
+		}
+		assert.NoError(t, err, tc.header)
+		assert.Equal(t, tc.role, role, tc.header)
+	}
+
+	for name, content := range map[string]string{
+		"missing role": "token\n",
+		"extra field":  "token reader admin\n",
+		"unknown role": "token owner\n",
+	} {
+		path := filepath.Join(t.TempDir(), "tokens")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		_, err := server.NewTokenAuthenticatorFromFile(path)
+		assert.ErrorContains(t, err, path+":1", name)
+	}
+	_, err := server.NewTokenAuthenticatorFromFile(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestClientCertAuthenticator(t *testing.T) {
+	ca := server.ClientCertAuthenticator{AdminCommonNames: []string{"ops"}}
+
+	role, err := ca.Authenticate(withClientCert(httptest.NewRequest(http.MethodGet, "/", nil), "ops"))
+	require.NoError(t, err)
+	assert.Equal(t, server.RoleAdmin, role)
+	role, err = ca.Authenticate(withClientCert(httptest.NewRequest(http.MethodGet, "/", nil), "dashboard"))
+	require.NoError(t, err)
+	assert.Equal(t, server.RoleReader, role)
+
+	_, err = ca.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Error(t, err, "requests without TLS are not authenticated")
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.TLS = &tls.ConnectionState{}
+	_, err = ca.Authenticate(r)
+	assert.Error(t, err, "requests without a verified certificate are not authenticated")
+}
+
+type authenticatorFunc func(*http.Request) (server.Role, error)
+
+func (f authenticatorFunc) Authenticate(r *http.Request) (server.Role, error) {
+	return f(r)
+}
+
+func TestAuthenticators(t *testing.T) {
+	as := server.Authenticators{tokenAuthenticator(t), server.ClientCertAuthenticator{AdminCommonNames: []string{"ops"}}}
+	request := func(token, commonName string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		if commonName != "" {
+			withClientCert(r, commonName)
+		}
+		return r
+	}
+
+	role, err := as.Authenticate(request("reader-token", "ops"))
+	require.NoError(t, err)
+	assert.Equal(t, server.RoleReader, role, "the first authenticator that recognizes the request is used")
+	role, err = as.Authenticate(request("unknown-token", "ops"))
+	require.NoError(t, err)
+	assert.Equal(t, server.RoleAdmin, role, "unrecognized credentials fall through to the next authenticator")
+	_, err = as.Authenticate(request("unknown-token", ""))
+	assert.Error(t, err)
+	_, err = server.Authenticators{}.Authenticate(request("admin-token", ""))
+	assert.Error(t, err)
+
+	boom := errors.New("boom")
+	failing := server.Authenticators{
+		authenticatorFunc(func(*http.Request) (server.Role, error) { return server.RoleNone, boom }),
+		tokenAuthenticator(t),
+	}
+	_, err = failing.Authenticate(request("admin-token", ""))
+	assert.ErrorIs(t, err, boom, "errors other than unrecognized credentials stop the search")
+}
+
+// queue is a server.BundleQueue that records the enqueued references.
+type queue struct {
+	enqueued []string
+}
+
+func (q *queue) Enqueue(ref reference.Named) bool {
+	q.enqueued = append(q.enqueued, ref.String())
+	return true
+}
+
+// route is an endpoint of the server and the role that it requires. The
+// requests have no parameters or bodies, so that the endpoints that are
+// served reject them, but never with 401 or 403.
+type route struct {
+	method, target string
+	role           server.Role
+}
+
+var routes = []route{
+	{http.MethodGet, "/api/upgrades_info/graph", server.RoleReader},
+	{http.MethodGet, "/api/graphql", server.RoleReader},
+	{http.MethodPost, "/api/graphql", server.RoleReader},
+	{http.MethodGet, "/catalogs/index/v1/api/v1/all", server.RoleReader},
+	{http.MethodGet, "/api/bundles", server.RoleReader},
+	{http.MethodGet, "/api/catalogs/diff", server.RoleReader},
+	{http.MethodGet, "/api/catalogs/default-channel-changes", server.RoleReader},
+	{http.MethodGet, "/api/catalogs/freshness", server.RoleReader},
+	{http.MethodPut, "/api/catalogs/index/v1/jira", server.RoleAdmin},
+	{http.MethodPut, "/api/packages/foo/jira", server.RoleAdmin},
+	{http.MethodGet, "/api/packages/foo/minimum-update-versions", server.RoleReader},
+	{http.MethodPut, "/api/packages/foo/streams/1.0/minimum-update-version", server.RoleAdmin},
+	{http.MethodDelete, "/api/packages/foo/streams/1.0/minimum-update-version", server.RoleAdmin},
+	{http.MethodGet, "/api/packages/foo/compatibility", server.RoleReader},
+	{http.MethodGet, "/api/packages/foo/updates", server.RoleReader},
+	{http.MethodPost, "/api/updates", server.RoleReader},
+	{http.MethodGet, "/api/lifecycle", server.RoleReader},
+	{http.MethodGet, "/api/trends", server.RoleReader},
+	{http.MethodGet, "/api/jira/problems", server.RoleReader},
+	{http.MethodPost, "/api/jira/problems/file", server.RoleAdmin},
+	{http.MethodPut, "/api/jira/problems/1/issue", server.RoleAdmin},
+	{http.MethodPost, "/api/webhooks/quay", server.RoleAdmin},
+	{http.MethodPost, "/api/webhooks/harbor", server.RoleAdmin},
+	{http.MethodGet, "/metrics", server.RoleReader},
+	{http.MethodGet, "/api/openapi.yaml", server.RoleNone},
+}
+
+func TestRequireRole(t *testing.T) {
+	db := dbtest.New(t)
+	s := newServer(t, db, server.Config{Auth: tokenAuthenticator(t), BundleQueue: &queue{}})
+	open := newServer(t, db, server.Config{BundleQueue: &queue{}})
+
+	for _, rt := range routes {
+		for _, client := range []struct {
+			role    server.Role
+			headers []string
+		}{
+			{role: server.RoleNone},
+			{role: server.RoleNone, headers: []string{"Authorization: Bearer unknown-token"}},
+			{role: server.RoleReader, headers: []string{"Authorization: Bearer reader-token"}},
+			{role: server.RoleAdmin, headers: []string{"Authorization: Bearer admin-token"}},
+		} {
+			name := rt.method + " " + rt.target + " as " + client.role.String()
+			w := serve(s, rt.method, rt.target, "", client.headers...)
+			switch {
+			case client.role >= rt.role:
+				assert.NotContains(t, []int{http.StatusUnauthorized, http.StatusForbidden}, w.Code, name)
+			case client.role == server.RoleNone:
+				assert.Equal(t, http.StatusUnauthorized, w.Code, name)
+				assert.Equal(t, `Bearer realm="extensiondb"`, w.Header().Get("WWW-Authenticate"), name)
+			default:
+				assert.Equal(t, http.StatusForbidden, w.Code, name)
+			}
+		}
+
+		w := serve(open, rt.method, rt.target, "")
+		assert.NotContains(t, []int{http.StatusUnauthorized, http.StatusForbidden}, w.Code, "servers without an authenticator serve every client: "+rt.method+" "+rt.target)
+	}
+}
+
+func TestRequireWebhookSecret(t *testing.T) {
+	db := dbtest.New(t)
+	querytest.Bundle(t, db, "foo", "1.0.0", time.Now())
+	pushed := &queue{}
+	s := newServer(t, db, server.Config{Auth: tokenAuthenticator(t), BundleQueue: pushed, WebhookSecret: "s3cret"})
+	event := `{"docker_url": "quay.io/extensiondb-test/foo-bundle", "updated_tags": ["v1.0.1"]}`
+
+	for _, tc := range []struct {
+		name    string
+		target  string
+		headers []string
+		status  int
+	}{
+		{name: "bearer token", target: "/api/webhooks/quay", headers: []string{"Authorization: Bearer s3cret"}, status: http.StatusAccepted},
+		{name: "raw Authorization header", target: "/api/webhooks/quay", headers: []string{"Authorization: s3cret"}, status: http.StatusAccepted},
+		{name: "token query parameter", target: "/api/webhooks/quay?token=s3cret", status: http.StatusAccepted},
+		{name: "wrong secret", target: "/api/webhooks/quay", headers: []string{"Authorization: Bearer wrong"}, status: http.StatusUnauthorized},
+		{name: "wrong query parameter", target: "/api/webhooks/quay?token=wrong", status: http.StatusUnauthorized},
+		{name: "headers before the query parameter", target: "/api/webhooks/quay?token=s3cret", headers: []string{"Authorization: Bearer wrong"}, status: http.StatusUnauthorized},
+		{name: "no secret", target: "/api/webhooks/quay", status: http.StatusUnauthorized},
+		{name: "admin token", target: "/api/webhooks/quay", headers: []string{"Authorization: Bearer admin-token"}, status: http.StatusUnauthorized},
+	} {
+		w := serve(s, http.MethodPost, tc.target, event, tc.headers...)
+		assert.Equal(t, tc.status, w.Code, tc.name)
+	}
+	assert.Equal(t, []string{
+		"quay.io/extensiondb-test/foo-bundle:v1.0.1",
+		"quay.io/extensiondb-test/foo-bundle:v1.0.1",
+		"quay.io/extensiondb-test/foo-bundle:v1.0.1",
+	}, pushed.enqueued)
+
+	// Without a secret, webhooks require the admin role.
+	s = newServer(t, db, server.Config{Auth: tokenAuthenticator(t), BundleQueue: &queue{}})
+	assert.Equal(t, http.StatusAccepted, serve(s, http.MethodPost, "/api/webhooks/quay", event, "Authorization: Bearer admin-token").Code)
+	assert.Equal(t, http.StatusForbidden, serve(s, http.MethodPost, "/api/webhooks/quay", event, "Authorization: Bearer reader-token").Code)
+	assert.Equal(t, http.StatusUnauthorized, serve(s, http.MethodPost, "/api/webhooks/quay?token=s3cret", event).Code)
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.podman.io/image/v5/docker/reference"
+)
+
+// readThroughRetryAfter is how long clients are asked to wait before looking
+// up a bundle again once it has been queued for ingestion.
+const readThroughRetryAfter = 30 * time.Second
+
+// Bundle describes a bundle stored in the database.
+type Bundle struct {
+	Package string     `json:"package,omitempty"`
+	Version string     `json:"version"`
+	Release string     `json:"release,omitempty"`
+	Digest  string     `json:"digest"`
+	BuiltAt *time.Time `json:"builtAt,omitempty"`
+}
+
+// PendingBundle reports that a bundle that is not in the database has been
+// queued for ingestion.
+type PendingBundle struct {
+	Image string `json:"image"`
+
+	// RetryAfterSeconds is how long to wait before looking up the bundle
+	// again, as also given by the Retry-After header.
+	RetryAfterSeconds int `json:"retryAfterSeconds"`
+}
+
+// handleBundle serves the bundle of the image named by the image parameter,
+// which must be referenced by digest. With read-through enabled, images that
+// are not in the database but are in the repository of a known package are
+// queued for ingestion, and a 202 Accepted response asks the client to retry
+// once they have been ingested, so that gaps found by clients are filled.
+func (s *Server) handleBundle(w http.ResponseWriter, r *http.Request) {
+	imageParam := r.URL.Query().Get("image")
+	if imageParam == "" {
+		httpError(w, http.StatusBadRequest, errors.New("image is required"))
+		return
+	}
+	named, err := reference.ParseNormalizedNamed(imageParam)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, fmt.Errorf("invalid image %q: %v", imageParam, err))
+		return
+	}
+	ref, ok := named.(reference.Canonical)
+	if !ok {
+		httpError(w, http.StatusBadRequest, fmt.Errorf("image %q must be referenced by digest", imageParam))
+		return
+	}
+
+	b, err := s.query.GetBundleByDigest(r.Context(), ref.Digest())
+	if errors.Is(err, sql.ErrNoRows) {
+		s.readThrough(w, r, ref)
+		return
+	}
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	v, err := s.ingestionValidators(r.Context(), time.Now(), ref.Digest().String())
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if s.writeCacheHeaders(w, r, v) {
+		return
+	}
+
+	result := Bundle{
+		Version: b.Version,
+		Release: b.Release.String,
+		Digest:  ref.Digest().String(),
+	}
+	if b.PackageID.Valid {
+		pkg, err := s.query.GetPackageByID(r.Context(), b.PackageID.String)
+		if err != nil {
+			httpError(w, http.StatusInternalServerError, err)
+			return
+		}
+		result.Package = pkg.Name
+	}
+	if b.Image.V != nil {
+		result.BuiltAt = b.Image.V.Created
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// readThrough queues an image that is not in the database for ingestion, if
+// read-through is enabled and the image is in the repository of a known
+// package. Images are accepted even if they are already waiting to be
+// ingested or the queue is full, so that clients keep retrying until they are
+// ingested.
+func (s *Server) readThrough(w http.ResponseWriter, r *http.Request, ref reference.Canonical) {
+	notFound := fmt.Errorf("unknown bundle %s", ref)
+	if s.readThroughQueue == nil {
+		httpError(w, http.StatusNotFound, notFound)
+		return
+	}
+	packages, err := s.query.GetPackagesForRepository(r.Context(), ref.Name())
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if len(packages) == 0 {
+		httpError(w, http.StatusNotFound, fmt.Errorf("%w, and %s is not the repository of a known package", notFound, ref.Name()))
+		return
+	}
+	s.readThroughQueue.Enqueue(ref)
+
+	retryAfter := int(readThroughRetryAfter.Seconds())
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	writeJSON(w, http.StatusAccepted, PendingBundle{Image: ref.String(), RetryAfterSeconds: retryAfter})
+}
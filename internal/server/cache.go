@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/joelanford/extensiondb/pkg/graph"
+)
+
+// validators identify a version of a response so that clients and caches can
+// make conditional requests.
+type validators struct {
+	etag         string
+	lastModified time.Time
+}
+
+// ingestionValidators returns validators for responses that change whenever a
+// new ingestion run finishes. Lifecycle phases are computed relative to the
+// current date, so the date is also part of the entity tag.
+func (s *Server) ingestionValidators(ctx context.Context, now time.Time, extra ...string) (*validators, error) {
+	h := sha256.New()
+	v := &validators{}
+
+	run, err := s.query.GetLatestIngestionRun(ctx)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return nil, fmt.Errorf("error getting latest ingestion run: %w", err)
+	default:
+		h.Write([]byte(run.ID))
+		v.lastModified = run.FinishedAt.Time
+	}
+
+	today := now.UTC().Truncate(24 * time.Hour)
+	if v.lastModified.Before(today) {
+		v.lastModified = today
+	}
+	h.Write([]byte(today.Format(time.DateOnly)))
+	for _, e := range extra {
+		h.Write([]byte{0})
+		h.Write([]byte(e))
+	}
+	v.etag = fmt.Sprintf(`"%s"`, hex.EncodeToString(h.Sum(nil))[:32])
+	return v, nil
+}
+
+// packageValidators returns validators for responses derived from a package's
+// template, including the overrides that graphs are built with, and bundles.
+func (s *Server) packageValidators(ctx context.Context, now time.Time, tmpl graph.Template, extra ...string) (*validators, error) {
+	contentHash, err := s.query.GetPackageContentHash(ctx, tmpl.Name)
+	if err != nil {
+		return nil, fmt.Errorf("error getting content hash for package %q: %w", tmpl.Name, err)
+	}
+	overridden, err := s.builder.ApplyOverrides(ctx, []graph.Template{tmpl})
+	if err != nil {
+		return nil, err
+	}
+	tmplData, err := json.Marshal(overridden[0])
+	if err != nil {
+		return nil, err
+	}
+	tmplHash := sha256.Sum256(tmplData)
+	return s.ingestionValidators(ctx, now, append([]string{contentHash, hex.EncodeToString(tmplHash[:])}, extra...)...)
+}
+
+// writeCacheHeaders sets the caching headers for the response and reports
+// whether the client's cached copy is still fresh. If it is, a 304 Not
+// Modified response has already been written and the handler should return.
+func (s *Server) writeCacheHeaders(w http.ResponseWriter, r *http.Request, v *validators) bool {
+	w.Header().Set("ETag", v.etag)
+	if !v.lastModified.IsZero() {
+		w.Header().Set("Last-Modified", v.lastModified.UTC().Format(http.TimeFormat))
+	}
+	// Shared caches must not serve the responses of a server that
+	// authenticates its clients to other clients.
+	visibility := "public"
+	if s.auth != nil {
+		visibility = "private"
+	}
+	w.Header().Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", visibility, int(s.cacheMaxAge.Seconds())))
+	w.Header().Add("Vary", "Authorization")
+
+	if notModified(r, v) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+func notModified(r *http.Request, v *validators) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == v.etag {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !v.lastModified.IsZero() {
+		t, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		return !v.lastModified.Truncate(time.Second).After(t)
+	}
+	return false
+}
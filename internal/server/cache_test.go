@@ -0,0 +1,65 @@
+package server_test
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/server"
+	"github.com/joelanford/extensiondb/pkg/dbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheHeaders(t *testing.T) {
+	db := dbtest.New(t)
+	d := loadDataset(t, db)
+	s := newServer(t, db, server.Config{Templates: d.Templates(), CacheMaxAge: 5 * time.Minute})
+	target := "/api/upgrades_info/graph?" + url.Values{"channel": {d.Packages[0].Template.Name + ":stable"}}.Encode()
+
+	w := serve(s, http.MethodGet, target, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	etag, lastModified := w.Header().Get("ETag"), w.Header().Get("Last-Modified")
+	require.NotEmpty(t, etag)
+	require.NotEmpty(t, lastModified)
+	assert.Equal(t, "public, max-age=300", w.Header().Get("Cache-Control"))
+	assert.Equal(t, "Authorization", w.Header().Get("Vary"))
+	modified, err := http.ParseTime(lastModified)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name    string
+		headers []string
+		status  int
+	}{
+		{name: "matching entity tag", headers: []string{"If-None-Match: " + etag}, status: http.StatusNotModified},
+		{name: "weak entity tag", headers: []string{"If-None-Match: W/" + etag}, status: http.StatusNotModified},
+		{name: "one of several entity tags", headers: []string{`If-None-Match: "stale", ` + etag}, status: http.StatusNotModified},
+		{name: "any entity tag", headers: []string{"If-None-Match: *"}, status: http.StatusNotModified},
+		{name: "other entity tag", headers: []string{`If-None-Match: "stale"`}, status: http.StatusOK},
+		{name: "not modified since", headers: []string{"If-Modified-Since: " + lastModified}, status: http.StatusNotModified},
+		{name: "modified since", headers: []string{"If-Modified-Since: " + modified.Add(-time.Second).Format(http.TimeFormat)}, status: http.StatusOK},
+		{name: "invalid date", headers: []string{"If-Modified-Since: yesterday"}, status: http.StatusOK},
+		{name: "entity tags take precedence", headers: []string{`If-None-Match: "stale"`, "If-Modified-Since: " + lastModified}, status: http.StatusOK},
+	} {
+		w := serve(s, http.MethodGet, target, "", tc.headers...)
+		assert.Equal(t, tc.status, w.Code, tc.name)
+		assert.Equal(t, etag, w.Header().Get("ETag"), tc.name)
+		if tc.status == http.StatusNotModified {
+			assert.Empty(t, w.Body.String(), tc.name)
+		}
+	}
+
+	// Template changes change the entity tag.
+	templates := d.Templates()
+	templates[0].VersionStreams = templates[0].VersionStreams[:1]
+	changed := newServer(t, db, server.Config{Templates: templates})
+	assert.Equal(t, http.StatusOK, serve(changed, http.MethodGet, target, "", "If-None-Match: "+etag).Code)
+
+	// Responses of servers that authenticate their clients are not shared.
+	authenticated := newServer(t, db, server.Config{Templates: d.Templates(), Auth: tokenAuthenticator(t), CacheMaxAge: time.Minute})
+	w = serve(authenticated, http.MethodGet, target, "", "Authorization: Bearer reader-token")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "private, max-age=60", w.Header().Get("Cache-Control"))
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/catalogdiff"
+	"github.com/joelanford/extensiondb/internal/defaultchannels"
+	"github.com/joelanford/extensiondb/internal/fbc"
+	"github.com/joelanford/extensiondb/internal/freshness"
+	"github.com/joelanford/extensiondb/internal/models"
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+)
+
+// handleCatalogAll serves a declarative config rendered from the database for
+// a catalog, in the same format as catalogd's api/v1/all endpoint. The
+// optional, repeatable package parameter limits the config to those packages.
+// Channels of packages with a template are derived from their update graph.
+func (s *Server) handleCatalogAll(w http.ResponseWriter, r *http.Request) {
+	name, tag := r.PathValue("name"), r.PathValue("tag")
+	packages := r.URL.Query()["package"]
+
+	c, err := s.query.GetCatalog(r.Context(), name, tag)
+	if errors.Is(err, sql.ErrNoRows) {
+		httpError(w, http.StatusNotFound, fmt.Errorf("unknown catalog %s:%s", name, tag))
+		return
+	}
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	now := time.Now()
+	sortedPackages := slices.Sorted(slices.Values(packages))
+	v, err := s.ingestionValidators(r.Context(), now, c.ID, strings.Join(sortedPackages, ","), s.templatesHash)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if s.writeCacheHeaders(w, r, v) {
+		return
+	}
+
+	cfg, err := fbc.Render(r.Context(), s.query, c, fbc.Options{
+		Packages: packages,
+		Channels: fbc.TemplateChannels(r.Context(), s.builder, slices.Collect(maps.Values(s.templates)), now),
+	})
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/jsonl")
+	if err := declcfg.WriteJSON(*cfg, w); err != nil {
+		log.Printf("error writing catalog %s:%s: %v", name, tag, err)
+	}
+}
+
+// handleCatalogDiff serves the difference between two catalogs, named by the
+// from and to parameters as <name>:<tag>, as JSON or, with format=markdown,
+// as a Markdown report.
+func (s *Server) handleCatalogDiff(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "markdown" {
+		httpError(w, http.StatusBadRequest, fmt.Errorf("unknown format %q; expected %s", format, catalogdiff.Formats))
+		return
+	}
+
+	var catalogs []*models.Catalog
+	for _, param := range []string{"from", "to"} {
+		catalogRef := r.URL.Query().Get(param)
+		name, tag, ok := strings.Cut(catalogRef, ":")
+		if !ok {
+			httpError(w, http.StatusBadRequest, fmt.Errorf("%s must be of the form <name>:<tag>, got %q", param, catalogRef))
+			return
+		}
+		c, err := s.query.GetCatalog(r.Context(), name, tag)
+		if errors.Is(err, sql.ErrNoRows) {
+			httpError(w, http.StatusNotFound, fmt.Errorf("unknown catalog %s:%s", name, tag))
+			return
+		}
+		if err != nil {
+			httpError(w, http.StatusInternalServerError, err)
+			return
+		}
+		catalogs = append(catalogs, c)
+	}
+
+	v, err := s.ingestionValidators(r.Context(), time.Now(), catalogs[0].ID, catalogs[1].ID, format)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if s.writeCacheHeaders(w, r, v) {
+		return
+	}
+
+	d, err := catalogdiff.Compute(r.Context(), s.query, catalogs[0], catalogs[1])
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if format != "markdown" {
+		writeJSON(w, http.StatusOK, d)
+		return
+	}
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	if err := d.WriteMarkdown(w); err != nil {
+		log.Printf("error writing response: %v", err)
+	}
+}
+
+// handleDefaultChannelChanges serves the changes of the default channels of
+// packages between the successive tags of the catalog named by the catalog
+// parameter. The optional, repeatable package parameter limits the changes to
+// those packages.
+func (s *Server) handleDefaultChannelChanges(w http.ResponseWriter, r *http.Request) {
+	catalogName := r.URL.Query().Get("catalog")
+	if catalogName == "" {
+		httpError(w, http.StatusBadRequest, errors.New("catalog is required"))
+		return
+	}
+	packages := slices.Sorted(slices.Values(r.URL.Query()["package"]))
+
+	v, err := s.ingestionValidators(r.Context(), time.Now(), catalogName, strings.Join(packages, ","))
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if s.writeCacheHeaders(w, r, v) {
+		return
+	}
+
+	channels, err := s.query.ListDefaultChannelsByTag(r.Context(), catalogName, packages)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, defaultchannels.Changes(channels))
+}
+
+// handleCatalogFreshness lists when the digest of each catalog last changed
+// and when each catalog was last ingested, and whether either is older than
+// the configured thresholds. The response is not cached, because staleness
+// changes with time alone.
+func (s *Server) handleCatalogFreshness(w http.ResponseWriter, r *http.Request) {
+	catalogs, err := s.query.ListCatalogFreshness(r.Context())
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, freshness.Check(catalogs, s.freshnessThresholds, time.Now()))
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/compat"
+	"github.com/joelanford/extensiondb/pkg/graph"
+)
+
+// compatContentTypes are the content types of the compatibility matrix
+// formats other than JSON.
+var compatContentTypes = map[string]string{
+	"csv":  "text/csv; charset=utf-8",
+	"html": "text/html; charset=utf-8",
+}
+
+// handleCompatibility serves the compatibility matrix of a package, as JSON
+// or, with the format parameter, as CSV or an HTML heatmap.
+func (s *Server) handleCompatibility(w http.ResponseWriter, r *http.Request) {
+	pkgName := r.PathValue("name")
+	format := r.URL.Query().Get("format")
+	contentType, ok := compatContentTypes[format]
+	if !ok && format != "" && format != "json" {
+		httpError(w, http.StatusBadRequest, fmt.Errorf("unknown format %q; expected json, csv, or html", format))
+		return
+	}
+
+	tmpl, ok := s.templates[pkgName]
+	if !ok {
+		httpError(w, http.StatusNotFound, fmt.Errorf("unknown package %q", pkgName))
+		return
+	}
+
+	now := time.Now()
+	v, err := s.packageValidators(r.Context(), now, tmpl, "compatibility", format)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if s.writeCacheHeaders(w, r, v) {
+		return
+	}
+
+	g, err := s.builder.Build(r.Context(), []graph.Template{tmpl}, now)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, fmt.Errorf("error building graph for package %q: %v", pkgName, err))
+		return
+	}
+	maxOCP, err := s.query.GetMaxOpenShiftVersions(r.Context(), pkgName)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, fmt.Errorf("error getting maxOpenShiftVersions for package %q: %v", pkgName, err))
+		return
+	}
+	m := compat.Build(g, pkgName, maxOCP)
+
+	if contentType == "" {
+		writeJSON(w, http.StatusOK, m)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	if err := m.Write(w, format); err != nil {
+		log.Printf("error writing response: %v", err)
+	}
+}
@@ -0,0 +1,403 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/joelanford/extensiondb/internal/models"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/joelanford/extensiondb/pkg/util"
+	"github.com/opencontainers/go-digest"
+)
+
+type graphqlRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+func (s *Server) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req graphqlRequest
+	switch r.Method {
+	case http.MethodGet:
+		// Only GET requests are cacheable. Any query may read any package, so the
+		// response is valid until the next ingestion run finishes.
+		v, err := s.ingestionValidators(r.Context(), time.Now(), r.URL.RawQuery)
+		if err != nil {
+			httpError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if s.writeCacheHeaders(w, r, v) {
+			return
+		}
+
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if v := r.URL.Query().Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				httpError(w, http.StatusBadRequest, fmt.Errorf("invalid variables: %v", err))
+				return
+			}
+		}
+	default:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			httpError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+			return
+		}
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         s.schema,
+		RequestString:  req.Query,
+		OperationName:  req.OperationName,
+		VariableValues: req.Variables,
+		Context:        r.Context(),
+	})
+	writeJSON(w, http.StatusOK, result)
+}
+
+// graphqlChannel is a channel of a package's update graph, as served by the
+// Cincinnati endpoint.
+type graphqlChannel struct {
+	name  string
+	graph *graph.Graph
+	nodes graph.NodePredicate
+}
+
+// graphqlBuild is the build record of a bundle, with the errata that
+// shipped it.
+type graphqlBuild struct {
+	build  *models.BundleBuild
+	errata []string
+}
+
+// resolveBuild returns the build record of the bundle with the given digest,
+// or nil if the bundle is not linked to one.
+func (s *Server) resolveBuild(ctx context.Context, dgst digest.Digest) (any, error) {
+	bb, errata, err := s.query.GetBundleBuildByDigest(ctx, dgst)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return graphqlBuild{build: bb, errata: errata}, nil
+}
+
+type graphqlEdge struct {
+	from, to *graph.Node
+	weight   float64
+}
+
+func (s *Server) newSchema() (graphql.Schema, error) {
+	referenceType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Reference",
+		Fields: graphql.Fields{
+			"repo": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(*models.BundleReference).Repo, nil
+			}},
+			"tag": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (any, error) {
+				return nullString(p.Source.(*models.BundleReference).Tag), nil
+			}},
+			"digest": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (any, error) {
+				return nullString(p.Source.(*models.BundleReference).Digest), nil
+			}},
+		},
+	})
+
+	catalogType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Catalog",
+		Fields: graphql.Fields{
+			"name": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(*models.Catalog).Name, nil
+			}},
+			"tag": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(*models.Catalog).Tag, nil
+			}},
+		},
+	})
+
+	buildType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Build",
+		Fields: graphql.Fields{
+			"system": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(graphqlBuild).build.System, nil
+			}},
+			"buildID": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(graphqlBuild).build.BuildID, nil
+			}},
+			"sourceRepository": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (any, error) {
+				return nullString(p.Source.(graphqlBuild).build.SourceRepository), nil
+			}},
+			"sourceCommit": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (any, error) {
+				return nullString(p.Source.(graphqlBuild).build.SourceCommit), nil
+			}},
+			"url": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (any, error) {
+				return nullString(p.Source.(graphqlBuild).build.URL), nil
+			}},
+			"errata": &graphql.Field{Type: graphql.NewList(graphql.String), Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(graphqlBuild).errata, nil
+			}},
+		},
+	})
+
+	pyxisMetadataType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PyxisMetadata",
+		Fields: graphql.Fields{
+			"certified": &graphql.Field{Type: graphql.Boolean, Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(*models.BundlePyxisMetadata).Certified, nil
+			}},
+			"vendor": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (any, error) {
+				return nullString(p.Source.(*models.BundlePyxisMetadata).Vendor), nil
+			}},
+			"supportLevel": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (any, error) {
+				return nullString(p.Source.(*models.BundlePyxisMetadata).SupportLevel), nil
+			}},
+			"repository": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (any, error) {
+				return nullString(p.Source.(*models.BundlePyxisMetadata).Repository), nil
+			}},
+			"publishedAt": &graphql.Field{Type: graphql.DateTime, Resolve: func(p graphql.ResolveParams) (any, error) {
+				pm := p.Source.(*models.BundlePyxisMetadata)
+				if !pm.PublishedAt.Valid {
+					return nil, nil
+				}
+				return pm.PublishedAt.Time, nil
+			}},
+		},
+	})
+
+	bundleType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Bundle",
+		Fields: graphql.Fields{
+			"version": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(*models.Bundle).Version, nil
+			}},
+			"release": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (any, error) {
+				return nullString(p.Source.(*models.Bundle).Release), nil
+			}},
+			"digest": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (any, error) {
+				b := p.Source.(*models.Bundle)
+				if b.Descriptor.V == nil {
+					return nil, nil
+				}
+				return b.Descriptor.V.Digest.String(), nil
+			}},
+			"builtAt": &graphql.Field{Type: graphql.DateTime, Resolve: func(p graphql.ResolveParams) (any, error) {
+				b := p.Source.(*models.Bundle)
+				if b.Image.V == nil || b.Image.V.Created == nil {
+					return nil, nil
+				}
+				return *b.Image.V.Created, nil
+			}},
+			"catalogs": &graphql.Field{Type: graphql.NewList(catalogType), Resolve: func(p graphql.ResolveParams) (any, error) {
+				return s.query.GetCatalogsForBundle(p.Context, p.Source.(*models.Bundle))
+			}},
+			"references": &graphql.Field{Type: graphql.NewList(referenceType), Resolve: func(p graphql.ResolveParams) (any, error) {
+				return s.query.GetBundleReferencesForBundle(p.Context, p.Source.(*models.Bundle))
+			}},
+			"build": &graphql.Field{Type: buildType, Resolve: func(p graphql.ResolveParams) (any, error) {
+				b := p.Source.(*models.Bundle)
+				if b.Descriptor.V == nil {
+					return nil, nil
+				}
+				return s.resolveBuild(p.Context, b.Descriptor.V.Digest)
+			}},
+			"pyxis": &graphql.Field{Type: pyxisMetadataType, Resolve: func(p graphql.ResolveParams) (any, error) {
+				b := p.Source.(*models.Bundle)
+				if b.Descriptor.V == nil {
+					return nil, nil
+				}
+				pm, err := s.query.GetBundlePyxisMetadataByDigest(p.Context, b.Descriptor.V.Digest)
+				if errors.Is(err, sql.ErrNoRows) {
+					return nil, nil
+				}
+				return pm, err
+			}},
+		},
+	})
+
+	graphNodeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "GraphNode",
+		Fields: graphql.Fields{
+			"version": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(*graph.Node).VR(), nil
+			}},
+			"reference": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (any, error) {
+				n := p.Source.(*graph.Node)
+				if n.ImageReference == nil {
+					return nil, nil
+				}
+				return n.ImageReference.String(), nil
+			}},
+			"lifecyclePhase": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(*graph.Node).LifecyclePhase.String(), nil
+			}},
+			"verified": &graphql.Field{Type: graphql.Boolean, Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(*graph.Node).Verified, nil
+			}},
+			"certified": &graphql.Field{Type: graphql.Boolean, Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(*graph.Node).Certified, nil
+			}},
+			"contentFingerprint": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (any, error) {
+				n := p.Source.(*graph.Node)
+				if n.ContentFingerprint == "" {
+					return nil, nil
+				}
+				return n.ContentFingerprint.String(), nil
+			}},
+			"build": &graphql.Field{Type: buildType, Resolve: func(p graphql.ResolveParams) (any, error) {
+				n := p.Source.(*graph.Node)
+				if n.ImageReference == nil {
+					return nil, nil
+				}
+				return s.resolveBuild(p.Context, n.ImageReference.Digest())
+			}},
+		},
+	})
+
+	graphEdgeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "GraphEdge",
+		Fields: graphql.Fields{
+			"from": &graphql.Field{Type: graphNodeType, Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(graphqlEdge).from, nil
+			}},
+			"to": &graphql.Field{Type: graphNodeType, Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(graphqlEdge).to, nil
+			}},
+			"weight": &graphql.Field{Type: graphql.Float, Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(graphqlEdge).weight, nil
+			}},
+		},
+	})
+
+	channelType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Channel",
+		Fields: graphql.Fields{
+			"name": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(graphqlChannel).name, nil
+			}},
+			"nodes": &graphql.Field{Type: graphql.NewList(graphNodeType), Resolve: func(p graphql.ResolveParams) (any, error) {
+				c := p.Source.(graphqlChannel)
+				return slices.SortedFunc(c.graph.NodesMatching(c.nodes), util.Compare), nil
+			}},
+			"edges": &graphql.Field{Type: graphql.NewList(graphEdgeType), Resolve: func(p graphql.ResolveParams) (any, error) {
+				c := p.Source.(graphqlChannel)
+				var edges []graphqlEdge
+				for e := range c.graph.EdgesMatching(func(g *graph.Graph, from, to *graph.Node, _ float64) bool {
+					return c.nodes(g, from) && c.nodes(g, to)
+				}) {
+					edges = append(edges, graphqlEdge{from: e.From, to: e.To, weight: e.Weight})
+				}
+				return edges, nil
+			}},
+		},
+	})
+
+	packageType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Package",
+		Fields: graphql.Fields{
+			"name": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (any, error) {
+				return p.Source.(*models.Package).Name, nil
+			}},
+			"bundles": &graphql.Field{
+				Type: graphql.NewList(bundleType),
+				Args: graphql.FieldConfigArgument{
+					"verified":  &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false, Description: "only list bundles whose signatures and attestations have been verified"},
+					"certified": &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: false, Description: "only list bundles that are certified in the Red Hat Ecosystem Catalog"},
+					"inCatalog": &graphql.ArgumentConfig{Type: graphql.String, Description: "only list bundles that ship in the latest contents of this catalog, as <name>:<tag>"},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					verified, _ := p.Args["verified"].(bool)
+					certified, _ := p.Args["certified"].(bool)
+					inCatalog, _ := p.Args["inCatalog"].(string)
+					switch {
+					case verified && certified:
+						return nil, errors.New("verified and certified can't be combined")
+					case inCatalog != "" && (verified || certified):
+						return nil, errors.New("inCatalog can't be combined with verified or certified")
+					case inCatalog != "":
+						c, err := graph.ParseCatalog(inCatalog)
+						if err != nil {
+							return nil, err
+						}
+						catalog, err := s.query.GetCatalog(p.Context, c.Name, c.Tag)
+						if errors.Is(err, sql.ErrNoRows) {
+							return nil, nil
+						}
+						if err != nil {
+							return nil, err
+						}
+						return s.query.ListBundlesForPackageInCatalog(p.Context, p.Source.(*models.Package), catalog)
+					case verified:
+						return s.query.ListVerifiedBundlesForPackage(p.Context, p.Source.(*models.Package))
+					case certified:
+						return s.query.ListCertifiedBundlesForPackage(p.Context, p.Source.(*models.Package))
+					}
+					return s.query.ListBundlesForPackage(p.Context, p.Source.(*models.Package))
+				},
+			},
+			"channels": &graphql.Field{Type: graphql.NewList(channelType), Resolve: func(p graphql.ResolveParams) (any, error) {
+				pkg := p.Source.(*models.Package)
+				tmpl, ok := s.templates[pkg.Name]
+				if !ok {
+					return nil, nil
+				}
+				g, err := s.builder.Build(p.Context, []graph.Template{tmpl}, time.Now())
+				if err != nil {
+					return nil, err
+				}
+				channels := []graphqlChannel{{name: "stable", graph: g, nodes: graph.PackageNodes(pkg.Name)}}
+				for _, stream := range tmpl.VersionStreams {
+					name := fmt.Sprintf("stable-%s", stream.Version)
+					inChannel, err := graph.ChannelNodes(name)
+					if err != nil {
+						return nil, err
+					}
+					channels = append(channels, graphqlChannel{name: name, graph: g, nodes: graph.AndNodes(graph.PackageNodes(pkg.Name), inChannel)})
+				}
+				return channels, nil
+			}},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"packages": &graphql.Field{Type: graphql.NewList(packageType), Resolve: func(p graphql.ResolveParams) (any, error) {
+					return s.query.ListPackages(p.Context)
+				}},
+				"package": &graphql.Field{
+					Type: packageType,
+					Args: graphql.FieldConfigArgument{
+						"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+					},
+					Resolve: func(p graphql.ResolveParams) (any, error) {
+						pkg, err := s.query.GetPackageByName(p.Context, p.Args["name"].(string))
+						if errors.Is(err, sql.ErrNoRows) {
+							return nil, nil
+						}
+						return pkg, err
+					},
+				},
+				"catalogs": &graphql.Field{Type: graphql.NewList(catalogType), Resolve: func(p graphql.ResolveParams) (any, error) {
+					return s.query.ListCatalogs(p.Context)
+				}},
+			},
+		}),
+	})
+}
+
+func nullString(s sql.NullString) any {
+	if !s.Valid {
+		return nil
+	}
+	return s.String
+}
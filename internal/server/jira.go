@@ -0,0 +1,141 @@
+package server
+
+import (
+	"cmp"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/jira"
+	"github.com/joelanford/extensiondb/pkg/graph"
+)
+
+// JiraMapping is the Jira projects and components that track features and
+// bugs for a catalog or package.
+type JiraMapping struct {
+	FeatureProject   string `json:"featureProject,omitempty"`
+	FeatureComponent string `json:"featureComponent,omitempty"`
+	BugProject       string `json:"bugProject,omitempty"`
+	BugComponent     string `json:"bugComponent,omitempty"`
+}
+
+func sqlNullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func (s *Server) handleSetCatalogJira(w http.ResponseWriter, r *http.Request) {
+	name, tag := r.PathValue("name"), r.PathValue("tag")
+	var m JiraMapping
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		httpError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+
+	c, err := s.query.GetCatalog(r.Context(), name, tag)
+	if errors.Is(err, sql.ErrNoRows) {
+		httpError(w, http.StatusNotFound, fmt.Errorf("unknown catalog %s:%s", name, tag))
+		return
+	}
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	c.JiraFeatureProject, c.JiraFeatureComponent = sqlNullString(m.FeatureProject), sqlNullString(m.FeatureComponent)
+	c.JiraBugProject, c.JiraBugComponent = sqlNullString(m.BugProject), sqlNullString(m.BugComponent)
+	if err := s.query.SetCatalogJira(r.Context(), c); err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+func (s *Server) handleSetPackageJira(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	var m JiraMapping
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		httpError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+
+	p, err := s.query.GetPackageByName(r.Context(), name)
+	if errors.Is(err, sql.ErrNoRows) {
+		httpError(w, http.StatusNotFound, fmt.Errorf("unknown package %q", name))
+		return
+	}
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	p.JiraFeatureProject, p.JiraFeatureComponent = sqlNullString(m.FeatureProject), sqlNullString(m.FeatureComponent)
+	p.JiraBugProject, p.JiraBugComponent = sqlNullString(m.BugProject), sqlNullString(m.BugComponent)
+	if err := s.query.SetPackageJira(r.Context(), p); err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+func (s *Server) detectProblems(r *http.Request) ([]jira.Problem, error) {
+	templates := slices.SortedFunc(maps.Values(s.templates), func(a, b graph.Template) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	return jira.Detect(r.Context(), s.query, s.builder, templates, time.Now())
+}
+
+// handleListProblems lists the problems detected in the database, along with
+// the Jira issues that track them.
+func (s *Server) handleListProblems(w http.ResponseWriter, r *http.Request) {
+	problems, err := s.detectProblems(r)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, problems)
+}
+
+// handleFileProblems files Jira issues for the detected problems that are not
+// yet tracked by one.
+func (s *Server) handleFileProblems(w http.ResponseWriter, r *http.Request) {
+	if s.jira == nil {
+		httpError(w, http.StatusServiceUnavailable, errors.New("jira is not configured"))
+		return
+	}
+	problems, err := s.detectProblems(r)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	problems, err = jira.File(r.Context(), s.jira, s.query, problems)
+	if err != nil {
+		httpError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, problems)
+}
+
+// handleLinkProblem links a problem to an existing Jira issue, so that no new
+// issue is filed for it.
+func (s *Server) handleLinkProblem(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		IssueKey string `json:"issueKey"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		httpError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+	if body.IssueKey == "" {
+		httpError(w, http.StatusBadRequest, errors.New("issueKey is required"))
+		return
+	}
+	id := r.PathValue("id")
+	if err := s.query.LinkJiraIssue(r.Context(), id, body.IssueKey); err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"id": id, "issueKey": body.IssueKey})
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/lifecycle"
+)
+
+// handleLifecycle lists the lifecycle of every version stream stored in the
+// database as of the date parameter, which defaults to today. With the
+// endOfLifeBefore parameter, it lists only the streams that reach their end
+// of life between the two dates, soonest first.
+func (s *Server) handleLifecycle(w http.ResponseWriter, r *http.Request) {
+	asOf := time.Now().UTC().Truncate(24 * time.Hour)
+	if d := r.URL.Query().Get("date"); d != "" {
+		t, err := time.Parse(time.DateOnly, d)
+		if err != nil {
+			httpError(w, http.StatusBadRequest, fmt.Errorf("invalid date %q: %v", d, err))
+			return
+		}
+		asOf = t
+	}
+
+	templates, err := s.query.ListVersionStreams(r.Context())
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	streams := lifecycle.Streams(templates, asOf)
+	if d := r.URL.Query().Get("endOfLifeBefore"); d != "" {
+		before, err := time.Parse(time.DateOnly, d)
+		if err != nil {
+			httpError(w, http.StatusBadRequest, fmt.Errorf("invalid endOfLifeBefore %q: %v", d, err))
+			return
+		}
+		streams = lifecycle.EndingBefore(streams, asOf, before)
+	}
+	if streams == nil {
+		streams = []lifecycle.Stream{}
+	}
+	writeJSON(w, http.StatusOK, streams)
+}
@@ -0,0 +1,17 @@
+package server
+
+import (
+	_ "embed"
+	"log"
+	"net/http"
+)
+
+//go:embed openapi.yaml
+var openAPIDocument []byte
+
+func handleOpenAPI(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	if _, err := w.Write(openAPIDocument); err != nil {
+		log.Printf("error writing response: %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/pkg/graph"
+)
+
+// MinimumUpdateVersionOverride overrides the minimum update version of a
+// version stream of a package's template.
+type MinimumUpdateVersionOverride struct {
+	Stream               string     `json:"stream,omitempty"`
+	MinimumUpdateVersion string     `json:"minimumUpdateVersion"`
+	UpdatedAt            *time.Time `json:"updatedAt,omitempty"`
+}
+
+func newMinimumUpdateVersionOverride(o query.MinimumUpdateVersionOverride) MinimumUpdateVersionOverride {
+	return MinimumUpdateVersionOverride{
+		Stream:               o.Stream.String(),
+		MinimumUpdateVersion: o.MinimumUpdateVersion.String(),
+		UpdatedAt:            &o.UpdatedAt,
+	}
+}
+
+// templateStream returns the version stream named by the request's path in
+// the template of the package named by the request's path. It writes an
+// error response and reports false if there is none.
+func (s *Server) templateStream(w http.ResponseWriter, r *http.Request) (string, graph.VersionStream, bool) {
+	name := r.PathValue("name")
+	tmpl, ok := s.templates[name]
+	if !ok {
+		httpError(w, http.StatusNotFound, fmt.Errorf("no template for package %q", name))
+		return "", graph.VersionStream{}, false
+	}
+	version, err := graph.NewMajorMinorFromString(r.PathValue("stream"))
+	if err != nil {
+		httpError(w, http.StatusBadRequest, fmt.Errorf("invalid stream %q: %v", r.PathValue("stream"), err))
+		return "", graph.VersionStream{}, false
+	}
+	for _, stream := range tmpl.VersionStreams {
+		if stream.Version == version {
+			return name, stream, true
+		}
+	}
+	httpError(w, http.StatusNotFound, fmt.Errorf("package %q has no version stream %s", name, version))
+	return "", graph.VersionStream{}, false
+}
+
+// handleListMinimumUpdateVersionOverrides lists the minimum update version
+// overrides of a package's version streams.
+func (s *Server) handleListMinimumUpdateVersionOverrides(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if _, ok := s.templates[name]; !ok {
+		httpError(w, http.StatusNotFound, fmt.Errorf("no template for package %q", name))
+		return
+	}
+	overrides, err := s.query.ListMinimumUpdateVersionOverrides(r.Context(), name)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	results := make([]MinimumUpdateVersionOverride, 0, len(overrides))
+	for _, o := range overrides {
+		results = append(results, newMinimumUpdateVersionOverride(o))
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// handleSetMinimumUpdateVersionOverride overrides the minimum update version
+// of a version stream of a package's template. The override can't be above
+// the stream's version, which would leave the stream's bundles with no
+// updates to them.
+func (s *Server) handleSetMinimumUpdateVersionOverride(w http.ResponseWriter, r *http.Request) {
+	name, stream, ok := s.templateStream(w, r)
+	if !ok {
+		return
+	}
+	var o MinimumUpdateVersionOverride
+	if err := json.NewDecoder(r.Body).Decode(&o); err != nil {
+		httpError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+	v, err := semver.Parse(o.MinimumUpdateVersion)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, fmt.Errorf("invalid minimum update version %q: %v", o.MinimumUpdateVersion, err))
+		return
+	}
+	if graph.NewMajorMinorFromVersion(v).Compare(stream.Version) > 0 {
+		httpError(w, http.StatusBadRequest, fmt.Errorf("minimum update version %s is above the stream's version %s", v, stream.Version))
+		return
+	}
+	if err := s.query.SetMinimumUpdateVersionOverride(r.Context(), name, stream.Version, v); err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, MinimumUpdateVersionOverride{Stream: stream.Version.String(), MinimumUpdateVersion: v.String()})
+}
+
+// handleDeleteMinimumUpdateVersionOverride removes the override of the
+// minimum update version of a version stream, restoring the template's.
+func (s *Server) handleDeleteMinimumUpdateVersionOverride(w http.ResponseWriter, r *http.Request) {
+	name, stream, ok := s.templateStream(w, r)
+	if !ok {
+		return
+	}
+	deleted, err := s.query.DeleteMinimumUpdateVersionOverride(r.Context(), name, stream.Version)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !deleted {
+		httpError(w, http.StatusNotFound, fmt.Errorf("version stream %s of package %q has no minimum update version override", stream.Version, name))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
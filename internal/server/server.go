@@ -0,0 +1,239 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/graphql-go/graphql"
+	"github.com/joelanford/extensiondb/internal/freshness"
+	"github.com/joelanford/extensiondb/internal/graphdb"
+	"github.com/joelanford/extensiondb/internal/graphhealth"
+	"github.com/joelanford/extensiondb/internal/jira"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/internal/retention"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Server serves extensiondb data over HTTP
+type Server struct {
+	query     *query.Query
+	builder   *graphdb.Builder
+	templates map[string]graph.Template
+	auth      Authenticator
+	jira      *jira.Client
+
+	bundleQueue      BundleQueue
+	webhookSecret    string
+	readThroughQueue BundleQueue
+
+	// templatesHash changes whenever any template changes.
+	templatesHash string
+
+	cacheMaxAge time.Duration
+
+	freshnessThresholds freshness.Thresholds
+
+	schema graphql.Schema
+	mux    *http.ServeMux
+}
+
+// Config holds server configuration
+type Config struct {
+	Query     *query.Query
+	Builder   *graphdb.Builder
+	Templates []graph.Template
+
+	// Auth authenticates clients. If nil, every client is allowed to use
+	// every endpoint.
+	Auth Authenticator
+
+	// CacheMaxAge is how long clients and shared caches may reuse responses
+	// without revalidating them.
+	CacheMaxAge time.Duration
+
+	// Jira files issues for detected problems. If nil, problems can only be
+	// linked to existing issues.
+	Jira *jira.Client
+
+	// BundleQueue ingests images pushed to the repositories of known
+	// packages, as reported by registry webhooks. If nil, the webhook
+	// endpoints are disabled.
+	BundleQueue BundleQueue
+
+	// WebhookSecret authenticates registry webhooks. If empty, webhooks
+	// require the admin role.
+	WebhookSecret string
+
+	// ReadThrough ingests the bundle images that clients look up but that
+	// are not in the database, if they are in the repository of a known
+	// package. If nil, such bundles are not found.
+	ReadThrough BundleQueue
+
+	// FreshnessThresholds are how old the digest and last ingestion of each
+	// catalog may be before the catalog is reported as stale.
+	FreshnessThresholds freshness.Thresholds
+
+	// Retention prunes the audit tables. If set, the rows it reclaims are
+	// reported at /metrics.
+	Retention *retention.Pruner
+}
+
+// New creates a new server that builds graphs for the configured templates
+func New(cfg Config) (*Server, error) {
+	s := &Server{
+		query:     cfg.Query,
+		builder:   cfg.Builder,
+		templates: make(map[string]graph.Template, len(cfg.Templates)),
+		auth:      cfg.Auth,
+		jira:      cfg.Jira,
+
+		bundleQueue:      cfg.BundleQueue,
+		webhookSecret:    cfg.WebhookSecret,
+		readThroughQueue: cfg.ReadThrough,
+
+		cacheMaxAge: cfg.CacheMaxAge,
+
+		freshnessThresholds: cfg.FreshnessThresholds,
+
+		mux: http.NewServeMux(),
+	}
+	for _, tmpl := range cfg.Templates {
+		s.templates[tmpl.Name] = tmpl
+	}
+	templatesData, err := json.Marshal(cfg.Templates)
+	if err != nil {
+		return nil, err
+	}
+	templatesHash := sha256.Sum256(templatesData)
+	s.templatesHash = hex.EncodeToString(templatesHash[:])
+
+	schema, err := s.newSchema()
+	if err != nil {
+		return nil, fmt.Errorf("error creating GraphQL schema: %w", err)
+	}
+	s.schema = schema
+
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(graphhealth.NewCollector(s.query, s.builder, cfg.Templates)); err != nil {
+		return nil, fmt.Errorf("error registering graph health collector: %w", err)
+	}
+	if err := registry.Register(freshness.NewCollector(s.query, cfg.FreshnessThresholds)); err != nil {
+		return nil, fmt.Errorf("error registering catalog freshness collector: %w", err)
+	}
+	if cfg.Retention != nil {
+		if err := registry.Register(cfg.Retention); err != nil {
+			return nil, fmt.Errorf("error registering retention collector: %w", err)
+		}
+	}
+
+	s.mux.HandleFunc("GET /api/upgrades_info/graph", s.requireRole(RoleReader, s.handleCincinnatiGraph))
+	s.mux.HandleFunc("GET /api/graphql", s.requireRole(RoleReader, s.handleGraphQL))
+	s.mux.HandleFunc("POST /api/graphql", s.requireRole(RoleReader, s.handleGraphQL))
+	s.mux.HandleFunc("GET /catalogs/{name}/{tag}/api/v1/all", s.requireRole(RoleReader, s.handleCatalogAll))
+	s.mux.HandleFunc("GET /api/bundles", s.requireRole(RoleReader, s.handleBundle))
+	s.mux.HandleFunc("GET /api/catalogs/diff", s.requireRole(RoleReader, s.handleCatalogDiff))
+	s.mux.HandleFunc("GET /api/catalogs/default-channel-changes", s.requireRole(RoleReader, s.handleDefaultChannelChanges))
+	s.mux.HandleFunc("GET /api/catalogs/freshness", s.requireRole(RoleReader, s.handleCatalogFreshness))
+	s.mux.HandleFunc("PUT /api/catalogs/{name}/{tag}/jira", s.requireRole(RoleAdmin, s.handleSetCatalogJira))
+	s.mux.HandleFunc("PUT /api/packages/{name}/jira", s.requireRole(RoleAdmin, s.handleSetPackageJira))
+	s.mux.HandleFunc("GET /api/packages/{name}/minimum-update-versions", s.requireRole(RoleReader, s.handleListMinimumUpdateVersionOverrides))
+	s.mux.HandleFunc("PUT /api/packages/{name}/streams/{stream}/minimum-update-version", s.requireRole(RoleAdmin, s.handleSetMinimumUpdateVersionOverride))
+	s.mux.HandleFunc("DELETE /api/packages/{name}/streams/{stream}/minimum-update-version", s.requireRole(RoleAdmin, s.handleDeleteMinimumUpdateVersionOverride))
+	s.mux.HandleFunc("GET /api/packages/{name}/compatibility", s.requireRole(RoleReader, s.handleCompatibility))
+	s.mux.HandleFunc("GET /api/packages/{name}/updates", s.requireRole(RoleReader, s.handleUpdates))
+	s.mux.HandleFunc("POST /api/updates", s.requireRole(RoleReader, s.handleFleetUpdates))
+	s.mux.HandleFunc("GET /api/lifecycle", s.requireRole(RoleReader, s.handleLifecycle))
+	s.mux.HandleFunc("GET /api/trends", s.requireRole(RoleReader, s.handleTrends))
+	s.mux.HandleFunc("GET /api/jira/problems", s.requireRole(RoleReader, s.handleListProblems))
+	s.mux.HandleFunc("POST /api/jira/problems/file", s.requireRole(RoleAdmin, s.handleFileProblems))
+	s.mux.HandleFunc("PUT /api/jira/problems/{id}/issue", s.requireRole(RoleAdmin, s.handleLinkProblem))
+	if s.bundleQueue != nil {
+		s.mux.HandleFunc("POST /api/webhooks/quay", s.requireWebhookSecret(s.handleQuayPush))
+		s.mux.HandleFunc("POST /api/webhooks/harbor", s.requireWebhookSecret(s.handleHarborPush))
+	}
+	s.mux.HandleFunc("GET /metrics", s.requireRole(RoleReader, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP))
+	s.mux.HandleFunc("GET /api/openapi.yaml", handleOpenAPI)
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// handleCincinnatiGraph implements the Cincinnati graph endpoint. The channel
+// parameter has the form <package>:<channel>, where channel is either "stable"
+// (every version of the package) or "stable-<major>.<minor>" (every version up
+// to and including that minor version). The optional version parameter is the
+// version the client currently has installed; if it's given, only the nodes of
+// the channel that can be reached from it are returned.
+func (s *Server) handleCincinnatiGraph(w http.ResponseWriter, r *http.Request) {
+	channelParam := r.URL.Query().Get("channel")
+	pkgName, channel, ok := strings.Cut(channelParam, ":")
+	if !ok || pkgName == "" || channel == "" {
+		httpError(w, http.StatusBadRequest, fmt.Errorf("channel must be of the form <package>:<channel>, got %q", channelParam))
+		return
+	}
+	versionParam := r.URL.Query().Get("version")
+	var installed semver.Version
+	if versionParam != "" {
+		var err error
+		if installed, err = semver.Parse(versionParam); err != nil {
+			httpError(w, http.StatusBadRequest, fmt.Errorf("invalid version %q: %v", versionParam, err))
+			return
+		}
+	}
+
+	tmpl, ok := s.templates[pkgName]
+	if !ok {
+		httpError(w, http.StatusNotFound, fmt.Errorf("unknown package %q", pkgName))
+		return
+	}
+	inChannel, err := graph.ChannelNodes(channel)
+	if err != nil {
+		httpError(w, http.StatusNotFound, err)
+		return
+	}
+
+	now := time.Now()
+	v, err := s.packageValidators(r.Context(), now, tmpl, channel, versionParam)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if s.writeCacheHeaders(w, r, v) {
+		return
+	}
+
+	g, err := s.builder.Build(r.Context(), []graph.Template{tmpl}, now)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, fmt.Errorf("error building graph for package %q: %v", pkgName, err))
+		return
+	}
+
+	match := graph.AndNodes(graph.PackageNodes(pkgName), inChannel)
+	if versionParam != "" {
+		match = g.ReachableFrom(graph.NodeInRange(installed.EQ), match)
+	}
+	writeJSON(w, http.StatusOK, g.Cincinnati(match, channel))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("error writing response: %v", err)
+	}
+}
+
+func httpError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,282 @@
+package server_test
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/internal/graphdb"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/internal/query/querytest"
+	"github.com/joelanford/extensiondb/internal/server"
+	"github.com/joelanford/extensiondb/internal/synthetic"
+	"github.com/joelanford/extensiondb/pkg/dbtest"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(dbtest.Main(m))
+}
+
+// newServer returns a server for the data in db, configured by cfg.
+func newServer(t *testing.T, db *sql.DB, cfg server.Config) *server.Server {
+	t.Helper()
+	cfg.Query = query.New(db)
+	cfg.Builder = graphdb.New(db)
+	s, err := server.New(cfg)
+	require.NoError(t, err)
+	return s
+}
+
+// serve sends a request to s and returns the response. Each header is given
+// as "<name>: <value>".
+func serve(s *server.Server, method, target, body string, headers ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	for _, h := range headers {
+		name, value, _ := strings.Cut(h, ": ")
+		r.Header.Add(name, value)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+// loadDataset stores a synthetic dataset of two packages, each with three
+// version streams, in db.
+func loadDataset(t *testing.T, db *sql.DB) *synthetic.Dataset {
+	t.Helper()
+	d, err := synthetic.Generate(synthetic.Config{Seed: 1, Packages: 2, MinorsPerMajor: 3})
+	require.NoError(t, err)
+	require.NoError(t, d.Load(t.Context(), query.New(db)))
+	return d
+}
+
+// decode decodes the JSON body of a response into a value of type T.
+func decode[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &v), w.Body.String())
+	return v
+}
+
+func TestCincinnatiGraph(t *testing.T) {
+	db := dbtest.New(t)
+	d := loadDataset(t, db)
+	s := newServer(t, db, server.Config{Templates: d.Templates()})
+	pkg := d.Packages[0].Template.Name
+	graphURL := func(channel string) string {
+		return "/api/upgrades_info/graph?" + url.Values{"channel": {channel}}.Encode()
+	}
+	versions := func(cg graph.CincinnatiGraph) []semver.Version {
+		var vs []semver.Version
+		for _, n := range cg.Nodes {
+			assert.Equal(t, pkg, n.Metadata[graph.MetadataKeyPackage])
+			v, _, _ := strings.Cut(n.Version, "_")
+			vs = append(vs, semver.MustParse(v))
+		}
+		return vs
+	}
+
+	w := serve(s, http.MethodGet, graphURL(pkg+":stable"), "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	stable := decode[graph.CincinnatiGraph](t, w)
+	var bundles []semver.Version
+	for _, b := range d.Packages[0].Bundles {
+		bundles = append(bundles, b.Version)
+	}
+	assert.NotEmpty(t, stable.Nodes)
+	assert.Subset(t, bundles, versions(stable))
+	for _, e := range stable.Edges {
+		assert.Less(t, e[0], len(stable.Nodes))
+		assert.Less(t, e[1], len(stable.Nodes))
+	}
+
+	first := d.Packages[0].Template.VersionStreams[0].Version
+	w = serve(s, http.MethodGet, graphURL(pkg+":stable-"+first.String()), "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	minor := versions(decode[graph.CincinnatiGraph](t, w))
+	assert.NotEmpty(t, minor)
+	assert.Less(t, len(minor), len(stable.Nodes))
+	for _, v := range minor {
+		assert.Equal(t, first, graph.MajorMinor{Major: v.Major, Minor: v.Minor}, "minor channels only have the versions up to their minor version")
+	}
+
+	oldest, newest := slices.MinFunc(versions(stable), semver.Version.Compare), slices.MaxFunc(versions(stable), semver.Version.Compare)
+	for _, tc := range []struct {
+		installed semver.Version
+		check     func([]semver.Version)
+	}{
+		{oldest, func(vs []semver.Version) {
+			assert.Contains(t, vs, oldest)
+			assert.Greater(t, len(vs), 1, "later versions can be reached from the oldest")
+		}},
+		{newest, func(vs []semver.Version) {
+			assert.NotEmpty(t, vs)
+			for _, v := range vs {
+				assert.True(t, v.EQ(newest), "nothing but the newest version can be reached from it")
+			}
+		}},
+		{semver.MustParse("99.0.0"), func(vs []semver.Version) {
+			assert.Empty(t, vs, "nothing can be reached from a version that isn't in the channel")
+		}},
+	} {
+		w = serve(s, http.MethodGet, graphURL(pkg+":stable")+"&version="+tc.installed.String(), "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		vs := versions(decode[graph.CincinnatiGraph](t, w))
+		for _, v := range vs {
+			assert.True(t, v.GTE(tc.installed), "%s can't be reached from %s", v, tc.installed)
+		}
+		tc.check(vs)
+	}
+
+	for target, status := range map[string]int{
+		graphURL(pkg):                          http.StatusBadRequest,
+		graphURL(":stable"):                    http.StatusBadRequest,
+		graphURL(pkg+":stable") + "&version=1": http.StatusBadRequest,
+		graphURL("unknown:stable"):             http.StatusNotFound,
+		graphURL(pkg + ":fast"):                http.StatusNotFound,
+		graphURL(pkg + ":stable-one"):          http.StatusNotFound,
+	} {
+		assert.Equal(t, status, serve(s, http.MethodGet, target, "").Code, target)
+	}
+}
+
+func TestGraphQL(t *testing.T) {
+	db := dbtest.New(t)
+	d := loadDataset(t, db)
+	s := newServer(t, db, server.Config{Templates: d.Templates()})
+	pkg := d.Packages[0].Template.Name
+
+	type response struct {
+		Data struct {
+			Package struct {
+				Name    string `json:"name"`
+				Bundles []struct {
+					Version string `json:"version"`
+				} `json:"bundles"`
+			} `json:"package"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	body, err := json.Marshal(map[string]any{
+		"query":     `query($name: String!) { package(name: $name) { name bundles { version } } }`,
+		"variables": map[string]any{"name": pkg},
+	})
+	require.NoError(t, err)
+	w := serve(s, http.MethodPost, "/api/graphql", string(body))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	resp := decode[response](t, w)
+	assert.Empty(t, resp.Errors)
+	assert.Equal(t, pkg, resp.Data.Package.Name)
+	assert.Len(t, resp.Data.Package.Bundles, len(d.Packages[0].Bundles))
+	assert.Empty(t, w.Header().Get("ETag"), "POST requests are not cacheable")
+
+	target := "/api/graphql?" + url.Values{
+		"query":     {`query($name: String!) { package(name: $name) { name } }`},
+		"variables": {`{"name": "` + pkg + `"}`},
+	}.Encode()
+	w = serve(s, http.MethodGet, target, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, pkg, decode[response](t, w).Data.Package.Name)
+	require.NotEmpty(t, w.Header().Get("ETag"))
+	assert.Equal(t, http.StatusNotModified, serve(s, http.MethodGet, target, "", "If-None-Match: "+w.Header().Get("ETag")).Code)
+
+	resp = decode[response](t, serve(s, http.MethodPost, "/api/graphql", `{"query": "{ unknown }"}`))
+	assert.NotEmpty(t, resp.Errors, "query errors are reported in the response")
+	assert.Equal(t, http.StatusBadRequest, serve(s, http.MethodPost, "/api/graphql", "{").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(s, http.MethodGet, "/api/graphql?query=%7B%7D&variables=%7B", "").Code)
+}
+
+func TestCatalogAll(t *testing.T) {
+	db := dbtest.New(t)
+	d := loadDataset(t, db)
+	_, err := d.LoadCatalog(t.Context(), query.New(db), "index", "v1")
+	require.NoError(t, err)
+	s := newServer(t, db, server.Config{Templates: d.Templates()})
+
+	// packages returns the names of the olm.package blobs of a catalog.
+	packages := func(w *httptest.ResponseRecorder) []string {
+		t.Helper()
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "application/jsonl", w.Header().Get("Content-Type"))
+		var names []string
+		lines := bufio.NewScanner(w.Body)
+		lines.Buffer(nil, 1<<20)
+		for lines.Scan() {
+			var blob struct {
+				Schema string `json:"schema"`
+				Name   string `json:"name"`
+			}
+			require.NoError(t, json.Unmarshal(lines.Bytes(), &blob))
+			if blob.Schema == "olm.package" {
+				names = append(names, blob.Name)
+			}
+		}
+		require.NoError(t, lines.Err())
+		return names
+	}
+
+	all := serve(s, http.MethodGet, "/catalogs/index/v1/api/v1/all", "")
+	assert.ElementsMatch(t, []string{d.Packages[0].Template.Name, d.Packages[1].Template.Name}, packages(all))
+	assert.NotEmpty(t, all.Header().Get("ETag"))
+
+	one := serve(s, http.MethodGet, "/catalogs/index/v1/api/v1/all?package="+d.Packages[1].Template.Name, "")
+	assert.Equal(t, []string{d.Packages[1].Template.Name}, packages(one))
+	assert.NotEqual(t, all.Header().Get("ETag"), one.Header().Get("ETag"), "the packages are part of the entity tag")
+
+	assert.Equal(t, http.StatusNotFound, serve(s, http.MethodGet, "/catalogs/index/v2/api/v1/all", "").Code)
+}
+
+func TestBundleReadThrough(t *testing.T) {
+	db := dbtest.New(t)
+	d := loadDataset(t, db)
+	pending := &queue{}
+	s := newServer(t, db, server.Config{Templates: d.Templates(), ReadThrough: pending})
+	stored := d.Packages[0].Bundles[0]
+	missing, err := reference.WithDigest(reference.TrimNamed(stored.Image), digest.FromString("missing"))
+	require.NoError(t, err)
+	unknown, err := reference.WithDigest(reference.TrimNamed(querytest.BundleImage("unknown", "1.0.0")), digest.FromString("unknown"))
+	require.NoError(t, err)
+	bundleURL := func(ref reference.Canonical) string {
+		return "/api/bundles?" + url.Values{"image": {ref.String()}}.Encode()
+	}
+
+	w := serve(s, http.MethodGet, bundleURL(stored.Image), "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	b := decode[server.Bundle](t, w)
+	assert.Equal(t, stored.Package, b.Package)
+	assert.Equal(t, stored.Version.String(), b.Version)
+
+	// Bundles in the repositories of known packages are queued until they
+	// are ingested.
+	for range 2 {
+		w = serve(s, http.MethodGet, bundleURL(missing), "")
+		require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+		assert.Equal(t, "30", w.Header().Get("Retry-After"))
+		assert.Equal(t, server.PendingBundle{Image: missing.String(), RetryAfterSeconds: 30}, decode[server.PendingBundle](t, w))
+	}
+	assert.Equal(t, []string{missing.String(), missing.String()}, pending.enqueued)
+
+	assert.Equal(t, http.StatusNotFound, serve(s, http.MethodGet, bundleURL(unknown), "").Code)
+	assert.Len(t, pending.enqueued, 2)
+	s = newServer(t, db, server.Config{Templates: d.Templates()})
+	assert.Equal(t, http.StatusNotFound, serve(s, http.MethodGet, bundleURL(missing), "").Code, "without read-through, missing bundles are not found")
+
+	for _, target := range []string{"/api/bundles", "/api/bundles?image=quay.io/example/foo-bundle:v1", "/api/bundles?image=%3A"} {
+		assert.Equal(t, http.StatusBadRequest, serve(s, http.MethodGet, target, "").Code, target)
+	}
+}
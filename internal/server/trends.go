@@ -0,0 +1,57 @@
+package server
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/trends"
+)
+
+// handleTrends reports release, z-stream, time-to-catalog, and graph health
+// trends of every package from the from date up to, but not including, the to
+// date. to defaults to tomorrow, so that today is included, and from to a
+// year before to.
+func (s *Server) handleTrends(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	to := now.UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	if d := r.URL.Query().Get("to"); d != "" {
+		t, err := time.Parse(time.DateOnly, d)
+		if err != nil {
+			httpError(w, http.StatusBadRequest, fmt.Errorf("invalid to %q: %v", d, err))
+			return
+		}
+		to = t
+	}
+	from := to.AddDate(-1, 0, 0)
+	if d := r.URL.Query().Get("from"); d != "" {
+		t, err := time.Parse(time.DateOnly, d)
+		if err != nil {
+			httpError(w, http.StatusBadRequest, fmt.Errorf("invalid from %q: %v", d, err))
+			return
+		}
+		from = t
+	}
+	if !from.Before(to) {
+		httpError(w, http.StatusBadRequest, fmt.Errorf("from (%s) must be before to (%s)", from.Format(time.DateOnly), to.Format(time.DateOnly)))
+		return
+	}
+
+	v, err := s.ingestionValidators(r.Context(), now, s.templatesHash, from.Format(time.DateOnly), to.Format(time.DateOnly))
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if s.writeCacheHeaders(w, r, v) {
+		return
+	}
+
+	report, err := trends.Compute(r.Context(), s.query, s.builder, slices.Collect(maps.Values(s.templates)), from, to)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
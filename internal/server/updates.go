@@ -0,0 +1,118 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/internal/updates"
+	"github.com/joelanford/extensiondb/pkg/graph"
+)
+
+// handleUpdates serves the updates available from the installed version of
+// a package given by the from parameter: its direct successors and the
+// recommended update. With the platform parameter, only versions supported
+// on that OpenShift version are offered.
+func (s *Server) handleUpdates(w http.ResponseWriter, r *http.Request) {
+	pkgName := r.PathValue("name")
+	fromParam := r.URL.Query().Get("from")
+	if fromParam == "" {
+		httpError(w, http.StatusBadRequest, errors.New("from is required"))
+		return
+	}
+	from, err := semver.Parse(fromParam)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, fmt.Errorf("invalid from %q: %v", fromParam, err))
+		return
+	}
+	platformParam := r.URL.Query().Get("platform")
+	var platform *graph.MajorMinor
+	if platformParam != "" {
+		mm, err := graph.NewMajorMinorFromString(platformParam)
+		if err != nil {
+			httpError(w, http.StatusBadRequest, fmt.Errorf("invalid platform %q: %v", platformParam, err))
+			return
+		}
+		platform = &mm
+	}
+
+	tmpl, ok := s.templates[pkgName]
+	if !ok {
+		httpError(w, http.StatusNotFound, fmt.Errorf("unknown package %q", pkgName))
+		return
+	}
+
+	now := time.Now()
+	v, err := s.packageValidators(r.Context(), now, tmpl, "updates", fromParam, platformParam)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if s.writeCacheHeaders(w, r, v) {
+		return
+	}
+
+	g, err := s.builder.Build(r.Context(), []graph.Template{tmpl}, now)
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, fmt.Errorf("error building graph for package %q: %v", pkgName, err))
+		return
+	}
+	u, err := updates.Find(g, pkgName, from, platform)
+	if errors.Is(err, updates.ErrUnknownVersion) {
+		httpError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, u)
+}
+
+// FleetUpdatesRequest is the body of the fleet updates endpoint: the versions
+// installed across a fleet of clusters.
+type FleetUpdatesRequest struct {
+	Platform  *graph.MajorMinor   `json:"platform,omitempty"`
+	Installed []updates.Installed `json:"installed"`
+}
+
+// handleFleetUpdates assesses the updates of every installed version in the
+// request in one call: the recommended update, whether the version is a dead
+// end, and the days until its end of life. Versions of unknown packages are
+// reported with an error rather than failing the request.
+func (s *Server) handleFleetUpdates(w http.ResponseWriter, r *http.Request) {
+	var req FleetUpdatesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		httpError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+	if len(req.Installed) == 0 {
+		httpError(w, http.StatusBadRequest, errors.New("installed is required"))
+		return
+	}
+
+	var templates []graph.Template
+	seen := map[string]bool{}
+	for _, i := range req.Installed {
+		tmpl, ok := s.templates[i.Package]
+		if !ok || seen[i.Package] {
+			continue
+		}
+		seen[i.Package] = true
+		templates = append(templates, tmpl)
+	}
+	if len(templates) == 0 {
+		httpError(w, http.StatusNotFound, errors.New("none of the installed packages is known"))
+		return
+	}
+
+	g, err := s.builder.Build(r.Context(), templates, time.Now())
+	if err != nil {
+		httpError(w, http.StatusInternalServerError, fmt.Errorf("error building graph: %v", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, updates.Assess(g, req.Installed, req.Platform))
+}
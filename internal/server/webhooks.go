@@ -0,0 +1,152 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/opencontainers/go-digest"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+// BundleQueue ingests pushed bundle images in the background.
+type BundleQueue interface {
+	Enqueue(ref reference.Named) bool
+}
+
+// quayPushEvent is the payload of a Quay repository push notification.
+type quayPushEvent struct {
+	DockerURL   string   `json:"docker_url"`
+	UpdatedTags []string `json:"updated_tags"`
+}
+
+// harborPushEvent is the payload of a Harbor PUSH_ARTIFACT webhook.
+type harborPushEvent struct {
+	Type      string `json:"type"`
+	EventData struct {
+		Resources []struct {
+			Digest      string `json:"digest"`
+			ResourceURL string `json:"resource_url"`
+		} `json:"resources"`
+	} `json:"event_data"`
+}
+
+// PushResult reports which pushed images were enqueued for ingestion.
+type PushResult struct {
+	// Enqueued lists the images in repositories of known packages.
+	Enqueued []string `json:"enqueued"`
+
+	// Ignored lists the images in repositories of unknown packages, and images
+	// that could not be enqueued because they are already waiting to be
+	// ingested or the queue is full.
+	Ignored []string `json:"ignored"`
+}
+
+// requireWebhookSecret wraps a webhook handler so that it is only served to
+// registries that present the webhook secret, either as a bearer token or
+// raw Authorization header (as Harbor sends it) or as the token query
+// parameter (for registries such as Quay that can't set headers). When no
+// secret is configured, the admin role is required instead.
+func (s *Server) requireWebhookSecret(h http.HandlerFunc) http.HandlerFunc {
+	if s.webhookSecret == "" {
+		return s.requireRole(RoleAdmin, h)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		presented, ok := bearerToken(r)
+		if !ok {
+			presented = r.Header.Get("Authorization")
+		}
+		if presented == "" {
+			presented = r.URL.Query().Get("token")
+		}
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(s.webhookSecret)) != 1 {
+			httpError(w, http.StatusUnauthorized, errors.New("invalid webhook secret"))
+			return
+		}
+		h(w, r)
+	}
+}
+
+func (s *Server) handleQuayPush(w http.ResponseWriter, r *http.Request) {
+	var event quayPushEvent
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		httpError(w, http.StatusBadRequest, fmt.Errorf("invalid push event: %v", err))
+		return
+	}
+	repo, err := reference.ParseNormalizedNamed(event.DockerURL)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, fmt.Errorf("invalid repository %q: %v", event.DockerURL, err))
+		return
+	}
+
+	refs := make([]reference.Named, 0, len(event.UpdatedTags))
+	for _, tag := range event.UpdatedTags {
+		ref, err := reference.WithTag(reference.TrimNamed(repo), tag)
+		if err != nil {
+			httpError(w, http.StatusBadRequest, fmt.Errorf("invalid tag %q: %v", tag, err))
+			return
+		}
+		refs = append(refs, ref)
+	}
+	s.enqueuePushed(w, r, refs)
+}
+
+func (s *Server) handleHarborPush(w http.ResponseWriter, r *http.Request) {
+	var event harborPushEvent
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		httpError(w, http.StatusBadRequest, fmt.Errorf("invalid push event: %v", err))
+		return
+	}
+	if event.Type != "PUSH_ARTIFACT" {
+		writeJSON(w, http.StatusOK, PushResult{Enqueued: []string{}, Ignored: []string{}})
+		return
+	}
+
+	refs := make([]reference.Named, 0, len(event.EventData.Resources))
+	for _, res := range event.EventData.Resources {
+		named, err := reference.ParseNormalizedNamed(res.ResourceURL)
+		if err != nil {
+			httpError(w, http.StatusBadRequest, fmt.Errorf("invalid resource URL %q: %v", res.ResourceURL, err))
+			return
+		}
+		dgst, err := digest.Parse(res.Digest)
+		if err != nil {
+			httpError(w, http.StatusBadRequest, fmt.Errorf("invalid digest %q: %v", res.Digest, err))
+			return
+		}
+		ref, err := reference.WithDigest(reference.TrimNamed(named), dgst)
+		if err != nil {
+			httpError(w, http.StatusBadRequest, err)
+			return
+		}
+		refs = append(refs, ref)
+	}
+	s.enqueuePushed(w, r, refs)
+}
+
+// enqueuePushed enqueues the pushed images that are in the repository of a
+// known package. Pushes to other repositories are ignored, because the
+// catalog ingestion discovers new packages.
+func (s *Server) enqueuePushed(w http.ResponseWriter, r *http.Request, refs []reference.Named) {
+	result := PushResult{Enqueued: []string{}, Ignored: []string{}}
+	known := map[string]bool{}
+	for _, ref := range refs {
+		repo := ref.Name()
+		if _, ok := known[repo]; !ok {
+			packages, err := s.query.GetPackagesForRepository(r.Context(), repo)
+			if err != nil {
+				httpError(w, http.StatusInternalServerError, err)
+				return
+			}
+			known[repo] = len(packages) > 0
+		}
+		if known[repo] && s.bundleQueue.Enqueue(ref) {
+			result.Enqueued = append(result.Enqueued, ref.String())
+		} else {
+			result.Ignored = append(result.Ignored, ref.String())
+		}
+	}
+	writeJSON(w, http.StatusAccepted, result)
+}
@@ -0,0 +1,257 @@
+// Package snapshot reads and writes SQLite snapshots of the data needed to
+// build update graphs: the olm.cincinnati templates and the bundles of their
+// images. Snapshots let graphs be built and explored offline, without access
+// to the database or to the templates directory.
+package snapshot
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/internal/graphdb"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	_ "github.com/mattn/go-sqlite3"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+const schema = `
+CREATE TABLE metadata (
+	created_at TEXT NOT NULL
+);
+CREATE TABLE templates (
+	name TEXT PRIMARY KEY,
+	template TEXT NOT NULL
+);
+CREATE TABLE nodes (
+	package TEXT NOT NULL,
+	version TEXT NOT NULL,
+	release TEXT,
+	image TEXT NOT NULL,
+	built_at TEXT NOT NULL,
+	verified INTEGER NOT NULL DEFAULT 0,
+	certified INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX nodes_package ON nodes (package);
+`
+
+// Create writes a snapshot of the templates, and of the bundles that builder
+// finds for their images, to a new SQLite database at path.
+func Create(ctx context.Context, builder *graphdb.Builder, templates []graph.Template, path string, now time.Time) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	if err := write(ctx, db, builder, templates, now); err != nil {
+		db.Close()
+		return errors.Join(err, os.Remove(path))
+	}
+	return db.Close()
+}
+
+func write(ctx context.Context, db *sql.DB, builder *graphdb.Builder, templates []graph.Template, now time.Time) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := func() error {
+		if _, err := tx.ExecContext(ctx, schema); err != nil {
+			return fmt.Errorf("error creating schema: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO metadata (created_at) VALUES (?)`, now.UTC().Format(time.RFC3339)); err != nil {
+			return err
+		}
+		for _, tmpl := range templates {
+			data, err := json.Marshal(tmpl)
+			if err != nil {
+				return fmt.Errorf("error marshaling template %q: %w", tmpl.Name, err)
+			}
+			if _, err := tx.ExecContext(ctx, `INSERT INTO templates (name, template) VALUES (?, ?)`, tmpl.Name, string(data)); err != nil {
+				return fmt.Errorf("error inserting template %q: %w", tmpl.Name, err)
+			}
+
+			pkg, err := builder.Package(ctx, tmpl)
+			if err != nil {
+				return err
+			}
+			for _, n := range pkg.Nodes {
+				if _, err := tx.ExecContext(ctx, `INSERT INTO nodes (package, version, release, image, built_at, verified, certified) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+					n.Name, n.Version.String(), n.Release, n.ImageReference.String(), n.ReleaseDate.UTC().Format(time.RFC3339Nano), n.Verified, n.Certified,
+				); err != nil {
+					return fmt.Errorf("error inserting bundle %s: %w", n.ImageReference, err)
+				}
+			}
+		}
+		return nil
+	}(); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	return tx.Commit()
+}
+
+// Snapshot is an open snapshot.
+type Snapshot struct {
+	db        *sql.DB
+	createdAt time.Time
+	templates []graph.Template
+	cleanup   func() error
+}
+
+// Open opens the snapshot at path for reading.
+func Open(ctx context.Context, path string) (*Snapshot, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	s := &Snapshot{db: db}
+	if err := s.load(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error reading snapshot %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// OpenBytes opens a snapshot from its contents, such as a snapshot embedded
+// in the binary. The contents are written to a temporary file, which is
+// removed when the snapshot is closed.
+func OpenBytes(ctx context.Context, data []byte) (*Snapshot, error) {
+	f, err := os.CreateTemp("", "extensiondb-snapshot-*.sqlite")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return nil, errors.Join(err, os.Remove(f.Name()))
+	}
+	if err := f.Close(); err != nil {
+		return nil, errors.Join(err, os.Remove(f.Name()))
+	}
+	s, err := Open(ctx, f.Name())
+	if err != nil {
+		return nil, errors.Join(err, os.Remove(f.Name()))
+	}
+	s.cleanup = func() error { return os.Remove(f.Name()) }
+	return s, nil
+}
+
+func (s *Snapshot) load(ctx context.Context) error {
+	var createdAt string
+	if err := s.db.QueryRowContext(ctx, `SELECT created_at FROM metadata`).Scan(&createdAt); err != nil {
+		return err
+	}
+	var err error
+	if s.createdAt, err = time.Parse(time.RFC3339, createdAt); err != nil {
+		return err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT name, template FROM templates ORDER BY name`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, data string
+		if err := rows.Scan(&name, &data); err != nil {
+			return err
+		}
+		var tmpl graph.Template
+		if err := json.Unmarshal([]byte(data), &tmpl); err != nil {
+			return fmt.Errorf("error parsing template %q: %w", name, err)
+		}
+		s.templates = append(s.templates, tmpl)
+	}
+	return rows.Err()
+}
+
+// Close closes the snapshot.
+func (s *Snapshot) Close() error {
+	err := s.db.Close()
+	if s.cleanup != nil {
+		err = errors.Join(err, s.cleanup())
+	}
+	return err
+}
+
+// CreatedAt returns the time the snapshot was created.
+func (s *Snapshot) CreatedAt() time.Time {
+	return s.createdAt
+}
+
+// Templates returns the snapshot's templates, ordered by package name.
+func (s *Snapshot) Templates() []graph.Template {
+	return s.templates
+}
+
+// Build builds a graph containing the snapshot's packages, or only the named
+// packages if any are given. It is the graph that graphdb.Builder built from
+// the same templates when the snapshot was created.
+func (s *Snapshot) Build(ctx context.Context, asOf time.Time, packageNames ...string) (*graph.Graph, error) {
+	var packages []graph.Package
+	for _, tmpl := range s.templates {
+		if len(packageNames) > 0 && !slices.Contains(packageNames, tmpl.Name) {
+			continue
+		}
+		nodes, err := s.nodes(ctx, tmpl.Name)
+		if err != nil {
+			return nil, fmt.Errorf("error reading nodes for package %q: %w", tmpl.Name, err)
+		}
+		packages = append(packages, graph.Package{Name: tmpl.Name, Nodes: nodes, Streams: tmpl.VersionStreams, MajorVersionBridges: tmpl.MajorVersionBridges})
+	}
+	if len(packages) == 0 {
+		return nil, fmt.Errorf("no packages found in snapshot")
+	}
+	return graph.NewGraph(ctx, graph.GraphConfig{Packages: packages, AsOf: asOf, PathScope: graph.PathScopePackage})
+}
+
+func (s *Snapshot) nodes(ctx context.Context, pkg string) ([]*graph.Node, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT version, release, image, built_at, verified, certified FROM nodes WHERE package = ? ORDER BY rowid`, pkg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var nodes []*graph.Node
+	for rows.Next() {
+		var (
+			version, image, builtAt string
+			release                 sql.NullString
+			verified, certified     bool
+		)
+		if err := rows.Scan(&version, &release, &image, &builtAt, &verified, &certified); err != nil {
+			return nil, err
+		}
+		n := &graph.Node{Name: pkg, Verified: verified, Certified: certified}
+		if n.Version, err = semver.Parse(version); err != nil {
+			return nil, err
+		}
+		if release.Valid {
+			n.Release = &release.String
+		}
+		ref, err := reference.ParseNamed(image)
+		if err != nil {
+			return nil, err
+		}
+		canonical, ok := ref.(reference.Canonical)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a canonical reference", image)
+		}
+		n.ImageReference = canonical
+		if n.ReleaseDate, err = time.Parse(time.RFC3339Nano, builtAt); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, rows.Err()
+}
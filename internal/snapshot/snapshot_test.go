@@ -0,0 +1,92 @@
+package snapshot_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/graphdb"
+	"github.com/joelanford/extensiondb/internal/models"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/internal/snapshot"
+	"github.com/joelanford/extensiondb/internal/synthetic"
+	"github.com/joelanford/extensiondb/pkg/dbtest"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(dbtest.Main(m))
+}
+
+func TestSnapshot(t *testing.T) {
+	db := dbtest.New(t)
+	d, err := synthetic.Generate(synthetic.Config{Seed: 1, Packages: 3, Extensions: 1})
+	require.NoError(t, err)
+	q := query.New(db)
+	require.NoError(t, d.Load(t.Context(), q))
+	b, err := q.GetBundleByDigest(t.Context(), d.Packages[0].Bundles[0].Image.Digest())
+	require.NoError(t, err)
+	require.NoError(t, q.SetBundleVerification(t.Context(), &models.BundleVerification{BundleID: b.ID, Verified: true}))
+	require.NoError(t, q.SetBundlePyxisMetadata(t.Context(), &models.BundlePyxisMetadata{BundleID: b.ID, Certified: true}))
+	builder := graphdb.New(db)
+
+	path := filepath.Join(t.TempDir(), "snapshot.sqlite")
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, snapshot.Create(t.Context(), builder, d.Templates(), path, now))
+	assert.Error(t, snapshot.Create(t.Context(), builder, d.Templates(), path, now), "existing snapshots are not overwritten")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	for name, open := range map[string]func() (*snapshot.Snapshot, error){
+		"file":  func() (*snapshot.Snapshot, error) { return snapshot.Open(t.Context(), path) },
+		"bytes": func() (*snapshot.Snapshot, error) { return snapshot.OpenBytes(t.Context(), data) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			s, err := open()
+			require.NoError(t, err)
+			defer s.Close()
+
+			assert.True(t, now.Equal(s.CreatedAt()))
+			assert.Equal(t, d.Templates(), s.Templates())
+
+			want, err := builder.Build(t.Context(), d.Templates(), now)
+			require.NoError(t, err)
+			got, err := s.Build(t.Context(), now)
+			require.NoError(t, err)
+			for _, tmpl := range d.Templates() {
+				wantNodes := nodeVersions(want, tmpl.Name)
+				assert.NotEmpty(t, wantNodes)
+				assert.Equal(t, wantNodes, nodeVersions(got, tmpl.Name))
+			}
+
+			name := d.Packages[0].Template.Name
+			only, err := s.Build(t.Context(), now, name)
+			require.NoError(t, err)
+			assert.Equal(t, nodeVersions(got, name), nodeVersions(only, name))
+			assert.Empty(t, nodeVersions(only, d.Packages[1].Template.Name))
+
+			_, err = s.Build(t.Context(), now, "unknown")
+			assert.Error(t, err)
+		})
+	}
+}
+
+// nodeVersions returns the versions, lifecycle phases, and verification and
+// certification statuses of a package's nodes, and their successors.
+func nodeVersions(g *graph.Graph, pkg string) map[string][]string {
+	versions := map[string][]string{}
+	for n := range g.NodesMatching(graph.PackageNodes(pkg)) {
+		key := fmt.Sprintf("%s %s verified=%t certified=%t", n.VR(), n.LifecyclePhase, n.Verified, n.Certified)
+		versions[key] = []string{}
+		for to := range g.From(n) {
+			versions[key] = append(versions[key], to.VR())
+		}
+		slices.Sort(versions[key])
+	}
+	return versions
+}
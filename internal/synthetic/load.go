@@ -0,0 +1,115 @@
+package synthetic
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/joelanford/extensiondb/internal/models"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/operator-framework/operator-registry/pkg/lib/bundle"
+	"go.podman.io/image/v5/docker/reference"
+	"sigs.k8s.io/yaml"
+)
+
+// Load stores the dataset's packages and bundles in the database, as if their
+// images had been ingested. Bundles that are already stored are skipped.
+func (d *Dataset) Load(ctx context.Context, q *query.Query) error {
+	names := make([]string, 0, len(d.Packages))
+	for _, p := range d.Packages {
+		names = append(names, p.Template.Name)
+	}
+	pkgs, err := q.GetOrCreatePackages(ctx, names)
+	if err != nil {
+		return fmt.Errorf("error creating packages: %w", err)
+	}
+	for _, p := range d.Packages {
+		pkg := pkgs[p.Template.Name]
+		for _, b := range p.Bundles {
+			if _, err := q.GetBundleByDigest(ctx, b.Image.Digest()); err == nil {
+				continue
+			} else if !errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("error getting bundle %s: %w", b.Image, err)
+			}
+
+			br, err := q.GetOrCreateCanonicalBundleReference(ctx, b.Image)
+			if err != nil {
+				return fmt.Errorf("error creating bundle reference %s: %w", b.Image, err)
+			}
+			built := b.Built.UTC()
+			mb := &models.Bundle{
+				PackageID: sql.NullString{String: pkg.ID, Valid: true},
+				Descriptor: models.JSONB[ocispec.Descriptor]{V: &ocispec.Descriptor{
+					MediaType: ocispec.MediaTypeImageManifest,
+					Digest:    b.Image.Digest(),
+					Size:      1,
+				}},
+				Manifest: models.JSONB[ocispec.Manifest]{V: &ocispec.Manifest{MediaType: ocispec.MediaTypeImageManifest}},
+				Image: models.JSONB[ocispec.Image]{V: &ocispec.Image{
+					Created: &built,
+					Config:  ocispec.ImageConfig{Labels: map[string]string{bundle.PackageLabel: b.Package}},
+				}},
+				Version: b.Version.String(),
+			}
+			if err := q.CreateBundleWithCatalogAndReference(ctx, mb, nil, br); err != nil {
+				return fmt.Errorf("error creating bundle %s: %w", b.Image, err)
+			}
+		}
+	}
+	return nil
+}
+
+// LoadCatalog stores a catalog that contains every bundle of the dataset.
+func (d *Dataset) LoadCatalog(ctx context.Context, q *query.Query, name, tag string) (*models.Catalog, error) {
+	c, err := q.GetOrCreateCatalog(ctx, name, tag)
+	if err != nil {
+		return nil, fmt.Errorf("error creating catalog %s:%s: %w", name, tag, err)
+	}
+
+	bundles := d.Bundles()
+	refs := make([]string, 0, len(bundles))
+	images := make([]reference.Canonical, 0, len(bundles))
+	for _, b := range bundles {
+		refs = append(refs, b.Image.String())
+		images = append(images, b.Image)
+	}
+	cd, err := q.GetOrCreateCatalogDigest(ctx, c, digest.FromString(strings.Join(refs, ",")).String())
+	if err != nil {
+		return nil, fmt.Errorf("error creating catalog digest: %w", err)
+	}
+	brs, err := q.GetOrCreateBundleReferences(ctx, images)
+	if err != nil {
+		return nil, fmt.Errorf("error creating bundle references: %w", err)
+	}
+	for i, br := range brs {
+		if err := q.EnsureCatalogDigestBundleReference(ctx, cd, br); err != nil {
+			return nil, fmt.Errorf("error adding bundle reference %s to catalog: %w", images[i], err)
+		}
+	}
+	return c, nil
+}
+
+// WriteTemplates writes the dataset's templates to a directory, as
+// <package>.cincinnati.yaml files that can be read with
+// graphdb.ReadTemplatesDir.
+func (d *Dataset) WriteTemplates(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, tmpl := range d.Templates() {
+		data, err := yaml.Marshal(tmpl)
+		if err != nil {
+			return fmt.Errorf("error marshaling template %s: %w", tmpl.Name, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, tmpl.Name+".cincinnati.yaml"), data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
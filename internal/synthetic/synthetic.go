@@ -0,0 +1,268 @@
+// Package synthetic generates realistic fake packages, with version ladders,
+// release cadences, and lifecycle templates modeled on real operators, so that
+// update graphs and plans can be tested at scale without catalog data.
+package synthetic
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/opencontainers/go-digest"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+const day = 24 * time.Hour
+
+// Config describes the packages to generate. Zero values are replaced by the
+// defaults noted on each field. Intervals are averages: each generated
+// interval is jittered by up to half of its length in either direction.
+type Config struct {
+	// Seed seeds the random number generator. Equal configs generate
+	// equal datasets.
+	Seed uint64
+
+	// Packages is the number of packages. Defaults to 10.
+	Packages int
+
+	// Majors is the number of major versions of each package. Defaults to 1.
+	Majors int
+
+	// MinorsPerMajor is the number of version streams of each major version.
+	// Defaults to 6.
+	MinorsPerMajor int
+
+	// Start is the general availability date of the first version stream of
+	// each package. Defaults to 2020-01-01.
+	Start time.Time
+
+	// Until stops releases after this time. By default, streams get patch
+	// releases until their end of life.
+	Until time.Time
+
+	// MinorInterval is the time between the general availability of
+	// consecutive version streams. Defaults to 120 days.
+	MinorInterval time.Duration
+
+	// PatchInterval is the time between patch releases of a stream in full
+	// support. Streams in maintenance get patch releases half as often.
+	// Defaults to 30 days.
+	PatchInterval time.Duration
+
+	// FullSupport and Maintenance are the lengths of the full support and
+	// maintenance phases of each stream. They default to 180 and 365 days.
+	FullSupport time.Duration
+	Maintenance time.Duration
+
+	// Extensions is the number of extended support phases of streams with
+	// even minor versions, each ExtensionLength long (defaulting to 180
+	// days). Streams with extended support can be updated to directly from
+	// the previous stream with extended support.
+	Extensions      int
+	ExtensionLength time.Duration
+
+	// FirstPlatform is the oldest platform version supported by the first
+	// stream; each later stream moves the window of supported platform
+	// versions forward by one minor version. Defaults to 4.10.
+	FirstPlatform graph.MajorMinor
+
+	// Platforms is the number of platform versions supported by each stream.
+	// Defaults to 4.
+	Platforms int
+
+	// Repository is the repository prefix of the bundle images, which are
+	// named <Repository>/<package>-bundle. Defaults to
+	// quay.io/extensiondb-synthetic.
+	Repository string
+}
+
+func (c Config) withDefaults() Config {
+	zeroDefault(&c.Packages, 10)
+	zeroDefault(&c.Majors, 1)
+	zeroDefault(&c.MinorsPerMajor, 6)
+	zeroDefault(&c.MinorInterval, 120*day)
+	zeroDefault(&c.PatchInterval, 30*day)
+	zeroDefault(&c.FullSupport, 180*day)
+	zeroDefault(&c.Maintenance, 365*day)
+	zeroDefault(&c.ExtensionLength, 180*day)
+	zeroDefault(&c.FirstPlatform, graph.MajorMinor{Major: 4, Minor: 10})
+	zeroDefault(&c.Platforms, 4)
+	zeroDefault(&c.Repository, "quay.io/extensiondb-synthetic")
+	if c.Start.IsZero() {
+		c.Start = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return c
+}
+
+func zeroDefault[T comparable](v *T, def T) {
+	var zero T
+	if *v == zero {
+		*v = def
+	}
+}
+
+// Dataset is a set of generated packages.
+type Dataset struct {
+	Packages []Package
+}
+
+// Package is a generated package: its olm.cincinnati template and the
+// bundles of every image in the template.
+type Package struct {
+	Template graph.Template
+	Bundles  []Bundle
+}
+
+// Bundle is a generated bundle.
+type Bundle struct {
+	Package string
+	Version semver.Version
+	Built   time.Time
+	Image   reference.Canonical
+}
+
+// Generate generates a dataset.
+func Generate(cfg Config) (*Dataset, error) {
+	cfg = cfg.withDefaults()
+	rng := rand.New(rand.NewPCG(cfg.Seed, uint64(cfg.Packages)))
+
+	d := &Dataset{}
+	for i := range cfg.Packages {
+		pkg, err := generatePackage(cfg, rng, fmt.Sprintf("synthetic-%03d-operator", i))
+		if err != nil {
+			return nil, err
+		}
+		d.Packages = append(d.Packages, pkg)
+	}
+	return d, nil
+}
+
+func generatePackage(cfg Config, rng *rand.Rand, name string) (Package, error) {
+	jitter := func(d time.Duration) time.Duration {
+		return d/2 + time.Duration(rng.Int64N(int64(d)))
+	}
+	named, err := reference.ParseNormalizedNamed(cfg.Repository + "/" + name + "-bundle")
+	if err != nil {
+		return Package{}, fmt.Errorf("invalid repository %q: %w", cfg.Repository, err)
+	}
+
+	pkg := Package{Template: graph.Template{Schema: graph.SchemaCincinnati, Name: name}}
+	ga := cfg.Start
+	index := 0
+	for major := uint64(1); major <= uint64(cfg.Majors); major++ {
+		for minor := uint64(0); minor < uint64(cfg.MinorsPerMajor); minor++ {
+			stream := graph.VersionStream{
+				Version:              graph.MajorMinor{Major: major, Minor: minor},
+				MinimumUpdateVersion: semver.Version{Major: major},
+			}
+			if minor > 0 {
+				stream.MinimumUpdateVersion.Minor = minor - 1
+			}
+
+			gaDate := truncateDay(ga)
+			maintenance := truncateDay(gaDate.Add(cfg.FullSupport))
+			eol := truncateDay(maintenance.Add(cfg.Maintenance))
+			stream.LifecycleDates = graph.LifecycleDates{
+				FullSupport: graph.NewDate(gaDate.Date()),
+				Maintenance: graph.NewDate(maintenance.Date()),
+			}
+			if minor%2 == 0 && cfg.Extensions > 0 {
+				for range cfg.Extensions {
+					stream.LifecycleDates.Extensions = append(stream.LifecycleDates.Extensions, graph.NewDate(eol.Date()))
+					eol = truncateDay(eol.Add(cfg.ExtensionLength))
+				}
+				if minor >= 2 {
+					stream.MinimumUpdateVersion.Minor = minor - 2
+				}
+			}
+			stream.LifecycleDates.EndOfLife = graph.NewDate(eol.Date())
+
+			for p := range cfg.Platforms {
+				stream.SupportedPlatformVersions = append(stream.SupportedPlatformVersions, graph.MajorMinor{
+					Major: cfg.FirstPlatform.Major,
+					Minor: cfg.FirstPlatform.Minor + uint64(index+p),
+				})
+			}
+			pkg.Template.VersionStreams = append(pkg.Template.VersionStreams, stream)
+
+			// The first release of a stream is built shortly before its
+			// general availability, and later releases are built until
+			// its end of life.
+			built := gaDate.Add(-jitter(2 * day))
+			for patch := uint64(0); built.Before(eol) && (cfg.Until.IsZero() || built.Before(cfg.Until)); patch++ {
+				v := semver.Version{Major: major, Minor: minor, Patch: patch}
+				ref, err := reference.WithDigest(named, digest.FromString(fmt.Sprintf("%d/%s@%s", cfg.Seed, name, v)))
+				if err != nil {
+					return Package{}, err
+				}
+				pkg.Bundles = append(pkg.Bundles, Bundle{Package: name, Version: v, Built: built, Image: ref})
+				pkg.Template.Images = append(pkg.Template.Images, graph.CanonicalReference{Canonical: ref})
+
+				interval := cfg.PatchInterval
+				if !built.Before(maintenance) {
+					interval *= 2
+				}
+				built = built.Add(jitter(interval))
+			}
+
+			ga = ga.Add(jitter(cfg.MinorInterval))
+			index++
+		}
+	}
+	return pkg, nil
+}
+
+func truncateDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// Templates returns the templates of the dataset's packages.
+func (d *Dataset) Templates() []graph.Template {
+	templates := make([]graph.Template, 0, len(d.Packages))
+	for _, p := range d.Packages {
+		templates = append(templates, p.Template)
+	}
+	return templates
+}
+
+// Bundles returns the bundles of every package of the dataset, in order of
+// their build times.
+func (d *Dataset) Bundles() []Bundle {
+	var bundles []Bundle
+	for _, p := range d.Packages {
+		bundles = append(bundles, p.Bundles...)
+	}
+	slices.SortStableFunc(bundles, func(a, b Bundle) int {
+		return a.Built.Compare(b.Built)
+	})
+	return bundles
+}
+
+// Graph builds the update graph of the dataset as of a time, without a
+// database. It is equivalent to the graph built by graphdb.Builder from the
+// dataset's templates once the dataset has been loaded into the database.
+func (d *Dataset) Graph(ctx context.Context, asOf time.Time) (*graph.Graph, error) {
+	packages := make([]graph.Package, 0, len(d.Packages))
+	for _, p := range d.Packages {
+		nodes := make([]*graph.Node, 0, len(p.Bundles))
+		for _, b := range p.Bundles {
+			nodes = append(nodes, &graph.Node{
+				Name:           b.Package,
+				Version:        b.Version,
+				ReleaseDate:    b.Built,
+				ImageReference: b.Image,
+			})
+		}
+		packages = append(packages, graph.Package{
+			Name:    p.Template.Name,
+			Streams: p.Template.VersionStreams,
+			Nodes:   nodes,
+		})
+	}
+	return graph.NewGraph(ctx, graph.GraphConfig{Packages: packages, AsOf: asOf, PathScope: graph.PathScopePackage})
+}
@@ -0,0 +1,132 @@
+package synthetic_test
+
+import (
+	"iter"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/graphdb"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/internal/synthetic"
+	"github.com/joelanford/extensiondb/pkg/dbtest"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(dbtest.Main(m))
+}
+
+func count[T any](seq iter.Seq[T]) int {
+	n := 0
+	for range seq {
+		n++
+	}
+	return n
+}
+
+func TestGenerate(t *testing.T) {
+	cfg := synthetic.Config{Seed: 1, Packages: 3, Majors: 2, MinorsPerMajor: 4, Extensions: 2}
+	d, err := synthetic.Generate(cfg)
+	require.NoError(t, err)
+	again, err := synthetic.Generate(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, d, again, "equal configs generate equal datasets")
+
+	other, err := synthetic.Generate(synthetic.Config{Seed: 2, Packages: 3, Majors: 2, MinorsPerMajor: 4, Extensions: 2})
+	require.NoError(t, err)
+	assert.NotEqual(t, d.Packages[0].Bundles[0].Image, other.Packages[0].Bundles[0].Image)
+
+	require.Len(t, d.Packages, 3)
+	for _, p := range d.Packages {
+		require.NoError(t, p.Template.Validate())
+		require.Len(t, p.Template.VersionStreams, 8)
+		assert.Len(t, p.Template.Images, len(p.Bundles))
+
+		s := p.Template.VersionStreams[2]
+		assert.Equal(t, graph.MajorMinor{Major: 1, Minor: 2}, s.Version)
+		assert.Equal(t, "1.0.0", s.MinimumUpdateVersion.String(), "streams with extended support can be updated to from the previous one")
+		assert.Len(t, s.LifecycleDates.Extensions, 2)
+		assert.Empty(t, p.Template.VersionStreams[3].LifecycleDates.Extensions)
+		assert.Equal(t, "1.2.0", p.Template.VersionStreams[3].MinimumUpdateVersion.String())
+		assert.Equal(t, graph.MajorMinor{Major: 4, Minor: 12}, s.SupportedPlatformVersions[0])
+
+		for i := 1; i < len(p.Template.VersionStreams); i++ {
+			assert.True(t, p.Template.VersionStreams[i-1].LifecycleDates.FullSupport.Time().Before(p.Template.VersionStreams[i].LifecycleDates.FullSupport.Time()))
+		}
+	}
+
+	bundles := d.Bundles()
+	for i := 1; i < len(bundles); i++ {
+		assert.False(t, bundles[i].Built.Before(bundles[i-1].Built))
+	}
+}
+
+func TestGenerateUntil(t *testing.T) {
+	until := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	d, err := synthetic.Generate(synthetic.Config{Packages: 1, Until: until})
+	require.NoError(t, err)
+	for _, b := range d.Bundles() {
+		assert.True(t, b.Built.Before(until))
+	}
+}
+
+func TestGraph(t *testing.T) {
+	d, err := synthetic.Generate(synthetic.Config{Seed: 1, Packages: 5, Extensions: 1})
+	require.NoError(t, err)
+
+	g, err := d.Graph(t.Context(), time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	for _, p := range d.Packages {
+		assert.NotZero(t, count(g.NodesMatching(graph.PackageNodes(p.Template.Name))))
+	}
+	assert.NotEmpty(t, g.Heads())
+}
+
+func TestWriteTemplates(t *testing.T) {
+	d, err := synthetic.Generate(synthetic.Config{Seed: 1, Packages: 2, Extensions: 1})
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	require.NoError(t, d.WriteTemplates(dir))
+	templates, err := graphdb.ReadTemplatesDir(dir)
+	require.NoError(t, err)
+	assert.Equal(t, d.Templates(), templates)
+}
+
+func TestLoad(t *testing.T) {
+	db := dbtest.New(t)
+	q := query.New(db)
+	d, err := synthetic.Generate(synthetic.Config{Seed: 1, Packages: 2, MinorsPerMajor: 3})
+	require.NoError(t, err)
+
+	require.NoError(t, d.Load(t.Context(), q))
+	require.NoError(t, d.Load(t.Context(), q), "loading a dataset again is a no-op")
+	c, err := d.LoadCatalog(t.Context(), q, "synthetic-index", "v1")
+	require.NoError(t, err)
+
+	shipped, err := q.GetBundlesInCatalog(t.Context(), c, nil)
+	require.NoError(t, err)
+	assert.Len(t, shipped, len(d.Bundles()))
+
+	asOf := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	fromDB, err := graphdb.New(db).Build(t.Context(), d.Templates(), asOf)
+	require.NoError(t, err)
+	generated, err := d.Graph(t.Context(), asOf)
+	require.NoError(t, err)
+	assert.Equal(t, count(generated.NodesMatching(graph.AllNodes())), count(fromDB.NodesMatching(graph.AllNodes())))
+}
+
+func BenchmarkGraph(b *testing.B) {
+	d, err := synthetic.Generate(synthetic.Config{Seed: 1, Packages: 100, Majors: 2, MinorsPerMajor: 8, Extensions: 2})
+	require.NoError(b, err)
+	asOf := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for b.Loop() {
+		if _, err := d.Graph(b.Context(), asOf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
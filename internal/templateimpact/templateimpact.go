@@ -0,0 +1,210 @@
+// Package templateimpact reports how proposed changes to olm.cincinnati
+// templates would change the update graphs of their packages, so that
+// product lifecycle owners can review them before they are merged.
+package templateimpact
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/joelanford/extensiondb/internal/jira"
+	"github.com/joelanford/extensiondb/pkg/graph"
+)
+
+// Report is the impact of proposed templates on the graphs of their
+// packages.
+type Report struct {
+	Packages []Package `json:"packages"`
+}
+
+// Package is the impact of a proposed template on the graph of its package.
+// Versions are written as <version>[_<release>].
+type Package struct {
+	Name string `json:"name"`
+
+	EdgesAdded   []Edge `json:"edgesAdded"`
+	EdgesRemoved []Edge `json:"edgesRemoved"`
+
+	// NodesIncluded are the nodes that are only in the proposed graph, and
+	// NodesExcluded are those that are only in the current one.
+	NodesIncluded []string       `json:"nodesIncluded"`
+	NodesExcluded []ExcludedNode `json:"nodesExcluded"`
+
+	// PhaseChanges are the nodes whose lifecycle phases differ, such as
+	// those of streams whose dates changed.
+	PhaseChanges []PhaseChange `json:"phaseChanges"`
+
+	// NewDeadEnds are the nodes that are dead ends in the proposed graph,
+	// but not in the current one: nodes without successors even though a
+	// newer version of the same major version is in the graph.
+	NewDeadEnds []string `json:"newDeadEnds"`
+}
+
+// Edge is an update from one version to another.
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// ExcludedNode is a node that the proposed template leaves out of the graph,
+// and why.
+type ExcludedNode struct {
+	Version string `json:"version"`
+	Reason  string `json:"reason"`
+}
+
+// PhaseChange is a change of a node's lifecycle phase.
+type PhaseChange struct {
+	Version string `json:"version"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+}
+
+// Empty reports whether the proposed template changes nothing in the graph.
+func (p Package) Empty() bool {
+	return len(p.EdgesAdded) == 0 && len(p.EdgesRemoved) == 0 && len(p.NodesIncluded) == 0 &&
+		len(p.NodesExcluded) == 0 && len(p.PhaseChanges) == 0 && len(p.NewDeadEnds) == 0
+}
+
+// NewDeadEnds returns the number of new dead ends of every package.
+func (r *Report) NewDeadEnds() int {
+	n := 0
+	for _, p := range r.Packages {
+		n += len(p.NewDeadEnds)
+	}
+	return n
+}
+
+// Compare compares the graphs of packages built from their current and
+// proposed templates. The proposed graph should be built with
+// GraphConfig.SkipInvalidNodes, so that the nodes its templates leave out
+// are reported with the reason they were.
+func Compare(current, proposed *graph.Graph, packages []string) *Report {
+	d := graph.Diff(current, proposed)
+	r := &Report{Packages: make([]Package, 0, len(packages))}
+	skipped := map[graph.NodeKey]error{}
+	for _, s := range proposed.SkippedNodes() {
+		skipped[s.Node.Key()] = s.Err
+	}
+	for _, name := range slices.Sorted(slices.Values(packages)) {
+		p := Package{
+			Name:          name,
+			EdgesAdded:    edges(proposed, d.AddedEdges, name),
+			EdgesRemoved:  edges(current, d.RemovedEdges, name),
+			NodesIncluded: []string{},
+			NodesExcluded: []ExcludedNode{},
+			PhaseChanges:  []PhaseChange{},
+			NewDeadEnds:   []string{},
+		}
+		for _, k := range d.AddedNodes {
+			if k.Package == name {
+				p.NodesIncluded = append(p.NodesIncluded, proposed.NodeForKey(k).VR())
+			}
+		}
+		for _, k := range d.RemovedNodes {
+			if k.Package != name {
+				continue
+			}
+			reason := "not in the proposed template's images"
+			if err, ok := skipped[k]; ok {
+				reason = err.Error()
+			}
+			p.NodesExcluded = append(p.NodesExcluded, ExcludedNode{Version: current.NodeForKey(k).VR(), Reason: reason})
+		}
+		for n := range proposed.NodesMatching(graph.PackageNodes(name)) {
+			if c := current.NodeForKey(n.Key()); c != nil && c.LifecyclePhase != n.LifecyclePhase {
+				p.PhaseChanges = append(p.PhaseChanges, PhaseChange{Version: n.VR(), From: c.LifecyclePhase.String(), To: n.LifecyclePhase.String()})
+			}
+		}
+		wasDeadEnd := map[graph.NodeKey]bool{}
+		for _, n := range jira.DeadEnds(current, name) {
+			wasDeadEnd[n.Key()] = true
+		}
+		for _, n := range jira.DeadEnds(proposed, name) {
+			if !wasDeadEnd[n.Key()] {
+				p.NewDeadEnds = append(p.NewDeadEnds, n.VR())
+			}
+		}
+		r.Packages = append(r.Packages, p)
+	}
+	return r
+}
+
+// edges returns the edges of g between the nodes of a package, in the order
+// of the diff.
+func edges(g *graph.Graph, keys []graph.EdgeKey, pkg string) []Edge {
+	es := []Edge{}
+	for _, k := range keys {
+		if k.From.Package == pkg && k.To.Package == pkg {
+			es = append(es, Edge{From: g.NodeForKey(k.From).VR(), To: g.NodeForKey(k.To).VR()})
+		}
+	}
+	return es
+}
+
+// Formats describes the formats supported by Write, for use in flag help.
+const Formats = "json or markdown"
+
+// Write writes r to w in format, which is one of Formats.
+func (r *Report) Write(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case "markdown":
+		return r.WriteMarkdown(w)
+	}
+	return fmt.Errorf("unknown format %q; expected %s", format, Formats)
+}
+
+// WriteMarkdown writes r as a Markdown report, with a section for each
+// package that the proposed templates change.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("# Template impact\n")
+	for _, p := range r.Packages {
+		fmt.Fprintf(&sb, "\n## %s\n", p.Name)
+		if p.Empty() {
+			sb.WriteString("\nNo changes.\n")
+			continue
+		}
+		writeList := func(title string, items []string) {
+			if len(items) == 0 {
+				return
+			}
+			fmt.Fprintf(&sb, "\n### %s (%d)\n\n", title, len(items))
+			for _, item := range items {
+				fmt.Fprintf(&sb, "- %s\n", item)
+			}
+		}
+		edgeItems := func(es []Edge) []string {
+			items := make([]string, 0, len(es))
+			for _, e := range es {
+				items = append(items, e.From+" → "+e.To)
+			}
+			return items
+		}
+		writeList("New dead ends", p.NewDeadEnds)
+		writeList("Updates removed", edgeItems(p.EdgesRemoved))
+		writeList("Updates added", edgeItems(p.EdgesAdded))
+		if len(p.NodesExcluded) > 0 {
+			fmt.Fprintf(&sb, "\n### Versions excluded (%d)\n\n| Version | Reason |\n| --- | --- |\n", len(p.NodesExcluded))
+			for _, n := range p.NodesExcluded {
+				fmt.Fprintf(&sb, "| %s | %s |\n", n.Version, n.Reason)
+			}
+		}
+		writeList("Versions included", p.NodesIncluded)
+		if len(p.PhaseChanges) > 0 {
+			fmt.Fprintf(&sb, "\n### Lifecycle phase changes (%d)\n\n| Version | From | To |\n| --- | --- | --- |\n", len(p.PhaseChanges))
+			for _, c := range p.PhaseChanges {
+				fmt.Fprintf(&sb, "| %s | %s | %s |\n", c.Version, c.From, c.To)
+			}
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,96 @@
+package templateimpact_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/internal/templateimpact"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/joelanford/extensiondb/pkg/graph/graphtest"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+var maintenance = graph.LifecycleDates{
+	FullSupport: graph.NewDate(2023, 1, 1),
+	Maintenance: graph.NewDate(2024, 6, 1),
+	EndOfLife:   graph.NewDate(2030, 1, 1),
+}
+
+// buildGraph builds the graph of package foo with new nodes of versions, so
+// that the nodes of each graph have their own lifecycle phases. The image
+// digest of each version is the same in every graph.
+func buildGraph(t *testing.T, streams []graph.VersionStream, versions ...string) *graph.Graph {
+	t.Helper()
+	nodes := graphtest.Nodes("foo", versions...)
+	for _, n := range nodes {
+		ref, err := reference.ParseNormalizedNamed("quay.io/example/foo-bundle@" + digest.FromString(n.Version.String()).String())
+		require.NoError(t, err)
+		n.ImageReference = ref.(reference.Canonical)
+	}
+	g, err := graph.NewGraph(t.Context(), graph.GraphConfig{
+		Packages:         []graph.Package{{Name: "foo", Streams: streams, Nodes: nodes}},
+		AsOf:             graphtest.AsOf,
+		SkipInvalidNodes: true,
+	})
+	require.NoError(t, err)
+	return g
+}
+
+func TestCompare(t *testing.T) {
+	current := buildGraph(t, []graph.VersionStream{
+		{Version: graph.MajorMinor{Major: 1, Minor: 0}, MinimumUpdateVersion: semver.MustParse("1.0.0"), LifecycleDates: graphtest.FullSupport},
+		{Version: graph.MajorMinor{Major: 1, Minor: 1}, MinimumUpdateVersion: semver.MustParse("1.0.0"), LifecycleDates: graphtest.FullSupport},
+	}, "1.0.0", "1.0.1", "1.1.0", "1.1.1")
+
+	r := templateimpact.Compare(current, buildGraph(t, []graph.VersionStream{
+		{Version: graph.MajorMinor{Major: 1, Minor: 0}, MinimumUpdateVersion: semver.MustParse("1.0.0"), LifecycleDates: graphtest.FullSupport},
+		{Version: graph.MajorMinor{Major: 1, Minor: 1}, MinimumUpdateVersion: semver.MustParse("1.0.0"), LifecycleDates: graphtest.FullSupport},
+	}, "1.0.0", "1.0.1", "1.1.0", "1.1.1"), []string{"foo"})
+	require.Len(t, r.Packages, 1)
+	assert.True(t, r.Packages[0].Empty())
+
+	// The proposed template drops 1.0.0 from its images and the 1.0 stream,
+	// adds 1.1.2, moves 1.1 into maintenance, and raises its minimum update
+	// version, which strands 1.1.0.
+	proposed := buildGraph(t, []graph.VersionStream{
+		{Version: graph.MajorMinor{Major: 1, Minor: 1}, MinimumUpdateVersion: semver.MustParse("1.1.1"), LifecycleDates: maintenance},
+	}, "1.0.1", "1.1.0", "1.1.1", "1.1.2")
+	r = templateimpact.Compare(current, proposed, []string{"foo"})
+	require.Len(t, r.Packages, 1)
+	p := r.Packages[0]
+	assert.False(t, p.Empty())
+	assert.Equal(t, "foo", p.Name)
+	assert.Equal(t, []templateimpact.Edge{{From: "1.1.1", To: "1.1.2"}}, p.EdgesAdded)
+	assert.Equal(t, []templateimpact.Edge{
+		{From: "1.0.0", To: "1.0.1"},
+		{From: "1.0.0", To: "1.1.0"},
+		{From: "1.0.0", To: "1.1.1"},
+		{From: "1.0.1", To: "1.1.0"},
+		{From: "1.0.1", To: "1.1.1"},
+		{From: "1.1.0", To: "1.1.1"},
+	}, p.EdgesRemoved)
+	assert.Equal(t, []string{"1.1.2"}, p.NodesIncluded)
+	require.Len(t, p.NodesExcluded, 2)
+	assert.Equal(t, templateimpact.ExcludedNode{Version: "1.0.0", Reason: "not in the proposed template's images"}, p.NodesExcluded[0])
+	assert.Equal(t, "1.0.1", p.NodesExcluded[1].Version)
+	assert.Contains(t, p.NodesExcluded[1].Reason, "not in an available stream")
+	assert.Equal(t, []templateimpact.PhaseChange{
+		{Version: "1.1.0", From: "Full Support", To: "Maintenance"},
+		{Version: "1.1.1", From: "Full Support", To: "Maintenance"},
+	}, p.PhaseChanges)
+	assert.Equal(t, []string{"1.1.0"}, p.NewDeadEnds)
+	assert.Equal(t, 1, r.NewDeadEnds())
+
+	var buf bytes.Buffer
+	require.NoError(t, r.Write(&buf, "markdown"))
+	assert.Contains(t, buf.String(), "### New dead ends (1)\n\n- 1.1.0\n")
+	assert.Contains(t, buf.String(), "| 1.0.0 | not in the proposed template's images |\n")
+	buf.Reset()
+	require.NoError(t, r.Write(&buf, "json"))
+	assert.Contains(t, buf.String(), `"newDeadEnds": [`)
+	assert.Error(t, r.Write(&buf, "yaml"))
+}
@@ -0,0 +1,52 @@
+package templateloader
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ConfigMap reads the templates stored in a Kubernetes ConfigMap. Each key
+// with a .yaml, .yml, or .json extension holds a template file.
+type ConfigMap struct {
+	Client    client.Reader
+	Namespace string
+	Name      string
+}
+
+var configMapGVK = schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+
+func (c ConfigMap) String() string {
+	return fmt.Sprintf("configmap %s/%s", c.Namespace, c.Name)
+}
+
+// Read gets the ConfigMap. Its version is the ConfigMap's resource version.
+func (c ConfigMap) Read(ctx context.Context, since string) (map[string][]byte, string, error) {
+	cm := &unstructured.Unstructured{}
+	cm.SetGroupVersionKind(configMapGVK)
+	if err := c.Client.Get(ctx, types.NamespacedName{Namespace: c.Namespace, Name: c.Name}, cm); err != nil {
+		return nil, "", err
+	}
+	version := cm.GetResourceVersion()
+	if version == since {
+		return nil, version, nil
+	}
+
+	data, _, err := unstructured.NestedStringMap(cm.Object, "data")
+	if err != nil {
+		return nil, "", err
+	}
+	files := make(map[string][]byte, len(data))
+	for key, value := range data {
+		switch path.Ext(key) {
+		case ".yaml", ".yml", ".json":
+			files[key] = []byte(value)
+		}
+	}
+	return files, version, nil
+}
@@ -0,0 +1,79 @@
+package templateloader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Dir reads the template files matched by a glob pattern, such as
+// "templates/*.yaml". If the pattern is a directory, every file in it is
+// read. Subdirectories are never read.
+type Dir string
+
+func (d Dir) String() string {
+	return string(d)
+}
+
+// Read reads the matched files. Their version is derived from their names,
+// sizes, and modification times, so unchanged files are not read again.
+func (d Dir) Read(_ context.Context, since string) (map[string][]byte, string, error) {
+	paths, err := d.paths()
+	if err != nil {
+		return nil, "", err
+	}
+
+	h := sha256.New()
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, "", err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%d\x00", path, info.Size(), info.ModTime().UnixNano())
+	}
+	version := hex.EncodeToString(h.Sum(nil))
+	if version == since {
+		return nil, version, nil
+	}
+
+	files := make(map[string][]byte, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", err
+		}
+		files[path] = data
+	}
+	return files, version, nil
+}
+
+func (d Dir) paths() ([]string, error) {
+	pattern := string(d)
+	info, err := os.Stat(pattern)
+	switch {
+	case err == nil && info.IsDir():
+		pattern = filepath.Join(pattern, "*")
+	case err != nil && !strings.ContainsAny(pattern, `*?[\`):
+		// A missing directory or file is an error, not an empty match.
+		return nil, err
+	}
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	paths := matches[:0]
+	for _, path := range matches {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
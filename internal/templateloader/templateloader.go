@@ -0,0 +1,98 @@
+// Package templateloader reads olm.cincinnati templates from directories,
+// HTTPS URLs, and Kubernetes ConfigMaps. A Loader caches the templates of a
+// source and only reads and parses them again when the source changes.
+package templateloader
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"sigs.k8s.io/yaml"
+)
+
+// Source is a location that template files are read from.
+type Source interface {
+	// Read returns the contents of each template file, keyed by name, and a
+	// version that changes whenever any of them change. If the version is
+	// the same as since, Read may return no files.
+	Read(ctx context.Context, since string) (files map[string][]byte, version string, err error)
+
+	// String describes the source in errors.
+	String() string
+}
+
+// Loader loads the templates of a source, caching them between loads.
+type Loader struct {
+	source Source
+
+	mu        sync.Mutex
+	loaded    bool
+	version   string
+	templates []graph.Template
+}
+
+// New creates a loader for the templates of source.
+func New(source Source) *Loader {
+	return &Loader{source: source}
+}
+
+// Load returns the templates of the source, and whether they changed since
+// the previous load. The first load always reports a change. The templates
+// are only parsed again when the source's version changes; if they fail to
+// parse, they are read and parsed again by the next load.
+func (l *Loader) Load(ctx context.Context) ([]graph.Template, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	files, version, err := l.source.Read(ctx, l.version)
+	if err != nil {
+		return nil, false, fmt.Errorf("error reading templates from %s: %w", l.source, err)
+	}
+	if l.loaded && version == l.version {
+		return l.templates, false, nil
+	}
+
+	templates, err := Parse(files)
+	if err != nil {
+		return nil, false, err
+	}
+	l.loaded, l.version, l.templates = true, version, templates
+	return templates, true, nil
+}
+
+// Load reads and parses the templates of source once.
+func Load(ctx context.Context, source Source) ([]graph.Template, error) {
+	templates, _, err := New(source).Load(ctx)
+	return templates, err
+}
+
+var documentSeparator = regexp.MustCompile(`(?m)^---[ \t]*$`)
+
+// Parse parses and validates the templates in files, in order of file name.
+// Each file holds one template, or several separated by YAML document
+// separators.
+func Parse(files map[string][]byte) ([]graph.Template, error) {
+	templates := make([]graph.Template, 0, len(files))
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		for _, doc := range documentSeparator.Split(string(files[name]), -1) {
+			if strings.TrimSpace(doc) == "" {
+				continue
+			}
+			var tmpl graph.Template
+			if err := yaml.Unmarshal([]byte(doc), &tmpl); err != nil {
+				return nil, fmt.Errorf("error parsing template %s: %w", name, err)
+			}
+			if err := tmpl.Validate(); err != nil {
+				return nil, fmt.Errorf("invalid template %s: %w", name, err)
+			}
+			templates = append(templates, tmpl)
+		}
+	}
+	return templates, nil
+}
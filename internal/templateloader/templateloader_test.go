@@ -0,0 +1,112 @@
+package templateloader_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joelanford/extensiondb/internal/templateloader"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func template(name string) string {
+	return fmt.Sprintf(`schema: olm.cincinnati
+name: %s
+versionStreams:
+  - version: "1.0"
+    lifecycleDates:
+      fullSupport: 2024-01-01
+      maintenance: 2024-06-01
+      eol: 2025-01-01
+images:
+  - quay.io/example/%s-bundle@sha256:%064d
+`, name, name, 0)
+}
+
+func names(templates []graph.Template) []string {
+	var names []string
+	for _, tmpl := range templates {
+		names = append(names, tmpl.Name)
+	}
+	return names
+}
+
+func TestDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "foo.yaml"), []byte(template("foo")), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bar.yaml"), []byte(template("bar")+"---\n"+template("baz")), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a template"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "subdir"), 0o755))
+
+	templates, err := templateloader.Load(t.Context(), templateloader.Dir(filepath.Join(dir, "*.yaml")))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bar", "baz", "foo"}, names(templates))
+
+	_, err = templateloader.Load(t.Context(), templateloader.Dir(dir))
+	assert.ErrorContains(t, err, "README.md")
+
+	_, err = templateloader.Load(t.Context(), templateloader.Dir(filepath.Join(dir, "missing")))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestLoaderDetectsChanges(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "foo.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(template("foo")), 0o644))
+	l := templateloader.New(templateloader.Dir(dir))
+
+	templates, changed, err := l.Load(t.Context())
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, []string{"foo"}, names(templates))
+
+	templates, changed, err = l.Load(t.Context())
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, []string{"foo"}, names(templates))
+
+	require.NoError(t, os.WriteFile(path, []byte(template("foobar")), 0o644))
+	templates, changed, err = l.Load(t.Context())
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, []string{"foobar"}, names(templates))
+}
+
+func TestURL(t *testing.T) {
+	var requests, notModified int
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, template("foo")+"---\n"+template("bar"))
+	}))
+	defer srv.Close()
+
+	u, err := templateloader.NewURL(srv.URL+"/templates.yaml", srv.Client())
+	require.NoError(t, err)
+	l := templateloader.New(u)
+
+	templates, changed, err := l.Load(t.Context())
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, []string{"foo", "bar"}, names(templates))
+
+	templates, changed, err = l.Load(t.Context())
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, []string{"foo", "bar"}, names(templates))
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, 1, notModified)
+
+	_, err = templateloader.NewURL("http://example.com/templates.yaml", nil)
+	assert.ErrorContains(t, err, "only https URLs are supported")
+}
@@ -0,0 +1,89 @@
+package templateloader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+)
+
+// URL reads the templates served at an HTTPS URL, either a single template or
+// several separated by YAML document separators. A URL must not be read
+// concurrently.
+type URL struct {
+	URL string
+
+	// Client sends the requests. If nil, http.DefaultClient is used.
+	Client *http.Client
+
+	// etag and lastModified are the validators of the last response, sent
+	// to ask the server whether it has changed.
+	etag, lastModified string
+}
+
+// NewURL creates a source for the templates served at rawURL, which must be
+// an HTTPS URL.
+func NewURL(rawURL string, client *http.Client) (*URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid template URL %q: only https URLs are supported", rawURL)
+	}
+	return &URL{URL: rawURL, Client: client}, nil
+}
+
+func (u *URL) String() string {
+	return u.URL
+}
+
+// Read fetches the templates. When the previous response had an ETag or
+// Last-Modified header, the request is conditional, and a 304 Not Modified
+// response reports that the templates are unchanged. Otherwise, the version
+// is the digest of the response body.
+func (u *URL) Read(ctx context.Context, since string) (map[string][]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.URL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if since != "" {
+		if u.etag != "" {
+			req.Header.Set("If-None-Match", u.etag)
+		}
+		if u.lastModified != "" {
+			req.Header.Set("If-Modified-Since", u.lastModified)
+		}
+	}
+
+	client := u.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return nil, since, nil
+	case http.StatusOK:
+	default:
+		return nil, "", fmt.Errorf("fetching %s: %s", u.URL, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	u.etag, u.lastModified = resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+
+	sum := sha256.Sum256(data)
+	name := path.Base(req.URL.Path)
+	return map[string][]byte{name: data}, hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,247 @@
+// Package trends reports how the release history of packages and the health
+// of their update graphs change over time, from the build and ingestion
+// times already stored for bundles.
+package trends
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/internal/graphdb"
+	"github.com/joelanford/extensiondb/internal/graphhealth"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/pkg/graph"
+)
+
+// monthFormat is the format of the Month fields of the report.
+const monthFormat = "2006-01"
+
+// Report holds the trends of every package between From and To. Bundles are
+// counted in the month in which they were built.
+type Report struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+
+	Releases      []MonthlyReleases      `json:"releases"`
+	ZStreams      []ZStreamCadence       `json:"zStreams"`
+	TimeToCatalog []MonthlyTimeToCatalog `json:"timeToCatalog"`
+	Health        []MonthlyHealth        `json:"health"`
+}
+
+// MonthlyReleases is the number of bundles of a package built in a month.
+type MonthlyReleases struct {
+	Package string `json:"package"`
+	Month   string `json:"month"`
+	Bundles int    `json:"bundles"`
+}
+
+// ZStreamCadence is how often the z-stream releases of a major.minor version
+// of a package were built. Streams with fewer than two releases in the
+// report's range are omitted.
+type ZStreamCadence struct {
+	Package  string `json:"package"`
+	Stream   string `json:"stream"`
+	Releases int    `json:"releases"`
+
+	// AverageDays is the average number of days between consecutive builds.
+	AverageDays float64 `json:"averageDays"`
+}
+
+// MonthlyTimeToCatalog is the time between the build of a package's bundles
+// and their first appearance in an ingested catalog, for the bundles built in
+// a month that have appeared in a catalog.
+type MonthlyTimeToCatalog struct {
+	Package     string  `json:"package"`
+	Month       string  `json:"month"`
+	Bundles     int     `json:"bundles"`
+	AverageDays float64 `json:"averageDays"`
+	MaxDays     float64 `json:"maxDays"`
+}
+
+// MonthlyHealth is the health of a package's update graph at the end of a
+// month, counting only the bundles built by then.
+type MonthlyHealth struct {
+	Package  string `json:"package"`
+	Month    string `json:"month"`
+	Heads    int    `json:"heads"`
+	DeadEnds int    `json:"deadEnds"`
+}
+
+// Compute computes the trends of every package between from and to. Graph
+// health is measured for the packages of templates.
+func Compute(ctx context.Context, q *query.Query, builder *graphdb.Builder, templates []graph.Template, from, to time.Time) (*Report, error) {
+	timelines, err := q.ListBundleTimelines(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing bundle timelines: %w", err)
+	}
+	packages := make([]graph.Package, 0, len(templates))
+	for _, tmpl := range templates {
+		pkg, err := builder.Package(ctx, tmpl)
+		if err != nil {
+			return nil, err
+		}
+		packages = append(packages, pkg)
+	}
+	health, err := Health(ctx, packages, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	r := Analyze(timelines, from, to)
+	r.Health = health
+	return r, nil
+}
+
+// Analyze computes the release trends of bundles built between from and to.
+// It does not measure graph health.
+func Analyze(timelines []query.BundleTimeline, from, to time.Time) *Report {
+	r := &Report{
+		From:          from,
+		To:            to,
+		Releases:      []MonthlyReleases{},
+		ZStreams:      []ZStreamCadence{},
+		TimeToCatalog: []MonthlyTimeToCatalog{},
+		Health:        []MonthlyHealth{},
+	}
+
+	type key struct{ pkg, group string }
+	var (
+		releases  = map[key]int{}
+		streams   = map[key][]time.Time{}
+		latencies = map[key][]time.Duration{}
+	)
+	for _, bt := range timelines {
+		if !bt.BuiltAt.Valid || bt.BuiltAt.Time.Before(from) || !bt.BuiltAt.Time.Before(to) {
+			continue
+		}
+		built := bt.BuiltAt.Time.UTC()
+		month := key{bt.PackageName, built.Format(monthFormat)}
+		releases[month]++
+		if v, err := semver.Parse(bt.Version); err == nil {
+			stream := key{bt.PackageName, fmt.Sprintf("%d.%d", v.Major, v.Minor)}
+			streams[stream] = append(streams[stream], built)
+		}
+		if bt.FirstCatalogedAt.Valid {
+			// Catalogs ingested before the build date was recorded can make
+			// the difference negative; those bundles shipped on arrival.
+			latencies[month] = append(latencies[month], max(bt.FirstCatalogedAt.Time.Sub(built), 0))
+		}
+	}
+
+	for k, n := range releases {
+		r.Releases = append(r.Releases, MonthlyReleases{Package: k.pkg, Month: k.group, Bundles: n})
+	}
+	for k, builds := range streams {
+		if len(builds) < 2 {
+			continue
+		}
+		first, last := slices.MinFunc(builds, time.Time.Compare), slices.MaxFunc(builds, time.Time.Compare)
+		r.ZStreams = append(r.ZStreams, ZStreamCadence{
+			Package:     k.pkg,
+			Stream:      k.group,
+			Releases:    len(builds),
+			AverageDays: days(last.Sub(first)) / float64(len(builds)-1),
+		})
+	}
+	for k, ls := range latencies {
+		var total time.Duration
+		for _, l := range ls {
+			total += l
+		}
+		r.TimeToCatalog = append(r.TimeToCatalog, MonthlyTimeToCatalog{
+			Package:     k.pkg,
+			Month:       k.group,
+			Bundles:     len(ls),
+			AverageDays: days(total) / float64(len(ls)),
+			MaxDays:     days(slices.Max(ls)),
+		})
+	}
+
+	slices.SortFunc(r.Releases, func(a, b MonthlyReleases) int {
+		return cmp.Or(cmp.Compare(a.Package, b.Package), cmp.Compare(a.Month, b.Month))
+	})
+	slices.SortFunc(r.ZStreams, func(a, b ZStreamCadence) int {
+		return cmp.Or(cmp.Compare(a.Package, b.Package), compareStreams(a.Stream, b.Stream))
+	})
+	slices.SortFunc(r.TimeToCatalog, func(a, b MonthlyTimeToCatalog) int {
+		return cmp.Or(cmp.Compare(a.Package, b.Package), cmp.Compare(a.Month, b.Month))
+	})
+	return r
+}
+
+// Health measures the update graphs of packages at the end of each month
+// between from and to, or at to for the last month. Each measurement uses
+// only the nodes released by then, and packages without such nodes are
+// skipped.
+func Health(ctx context.Context, packages []graph.Package, from, to time.Time) ([]MonthlyHealth, error) {
+	health := []MonthlyHealth{}
+	for month := monthStart(from); month.Before(to); month = month.AddDate(0, 1, 0) {
+		asOf := month.AddDate(0, 1, 0)
+		if asOf.After(to) {
+			asOf = to
+		}
+
+		var (
+			released  []graph.Package
+			templates []graph.Template
+		)
+		for _, pkg := range packages {
+			nodes := slices.DeleteFunc(slices.Clone(pkg.Nodes), func(n *graph.Node) bool {
+				return n.ReleaseDate.After(asOf)
+			})
+			if len(nodes) == 0 {
+				continue
+			}
+			released = append(released, graph.Package{Name: pkg.Name, Streams: pkg.Streams, Nodes: nodes, MajorVersionBridges: pkg.MajorVersionBridges})
+			templates = append(templates, graph.Template{Name: pkg.Name, VersionStreams: pkg.Streams, MajorVersionBridges: pkg.MajorVersionBridges})
+		}
+		if len(released) == 0 {
+			continue
+		}
+
+		g, err := graph.NewGraph(ctx, graph.GraphConfig{Packages: released, AsOf: asOf, PathScope: graph.PathScopePackage})
+		if err != nil {
+			return nil, fmt.Errorf("error building graph as of %s: %w", asOf.Format(time.DateOnly), err)
+		}
+		report := graphhealth.Measure(g, templates, nil, asOf)
+		heads := map[string]int{}
+		for _, h := range report.Heads {
+			heads[h.Package]++
+		}
+		for _, tmpl := range templates {
+			health = append(health, MonthlyHealth{
+				Package:  tmpl.Name,
+				Month:    month.Format(monthFormat),
+				Heads:    heads[tmpl.Name],
+				DeadEnds: report.DeadEnds[tmpl.Name],
+			})
+		}
+	}
+	slices.SortStableFunc(health, func(a, b MonthlyHealth) int {
+		return cmp.Compare(a.Package, b.Package)
+	})
+	return health, nil
+}
+
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func days(d time.Duration) float64 {
+	return d.Hours() / 24
+}
+
+// compareStreams orders major.minor streams numerically.
+func compareStreams(a, b string) int {
+	av, aErr := semver.ParseTolerant(a)
+	bv, bErr := semver.ParseTolerant(b)
+	if aErr != nil || bErr != nil {
+		return cmp.Compare(a, b)
+	}
+	return av.Compare(bv)
+}
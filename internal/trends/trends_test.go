@@ -0,0 +1,83 @@
+package trends_test
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/internal/trends"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func timeline(pkg, version string, built time.Time, cataloged time.Time) query.BundleTimeline {
+	return query.BundleTimeline{
+		PackageName:      pkg,
+		Version:          version,
+		BuiltAt:          sql.NullTime{Time: built, Valid: true},
+		FirstCatalogedAt: sql.NullTime{Time: cataloged, Valid: !cataloged.IsZero()},
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	r := trends.Analyze([]query.BundleTimeline{
+		timeline("foo", "1.0.0", date(2024, 1, 5), date(2024, 1, 7)),
+		timeline("foo", "1.0.1", date(2024, 1, 15), date(2024, 1, 19)),
+		timeline("foo", "1.0.2", date(2024, 2, 4), time.Time{}),
+		timeline("foo", "1.10.0", date(2024, 2, 10), date(2024, 2, 9)),
+		timeline("foo", "1.10.1", date(2024, 2, 20), date(2024, 2, 21)),
+		timeline("bar", "0.1.0", date(2023, 12, 31), date(2024, 1, 2)),
+		{PackageName: "bar", Version: "0.2.0"},
+	}, date(2024, 1, 1), date(2024, 3, 1))
+
+	assert.Equal(t, []trends.MonthlyReleases{
+		{Package: "foo", Month: "2024-01", Bundles: 2},
+		{Package: "foo", Month: "2024-02", Bundles: 3},
+	}, r.Releases, "bundles built outside the range or without a build time are not counted")
+
+	assert.Equal(t, []trends.ZStreamCadence{
+		{Package: "foo", Stream: "1.0", Releases: 3, AverageDays: 15},
+		{Package: "foo", Stream: "1.10", Releases: 2, AverageDays: 10},
+	}, r.ZStreams)
+
+	assert.Equal(t, []trends.MonthlyTimeToCatalog{
+		{Package: "foo", Month: "2024-01", Bundles: 2, AverageDays: 3, MaxDays: 4},
+		{Package: "foo", Month: "2024-02", Bundles: 2, AverageDays: 0.5, MaxDays: 1},
+	}, r.TimeToCatalog, "uncataloged bundles are skipped and bundles cataloged before their build count as zero")
+	assert.Empty(t, r.Health)
+}
+
+func TestHealth(t *testing.T) {
+	dates := graph.LifecycleDates{
+		FullSupport: graph.NewDate(2024, 1, 1),
+		Maintenance: graph.NewDate(2029, 1, 1),
+		EndOfLife:   graph.NewDate(2030, 1, 1),
+	}
+	streams := []graph.VersionStream{
+		{Version: graph.MajorMinor{Major: 1, Minor: 0}, LifecycleDates: dates},
+		{Version: graph.MajorMinor{Major: 1, Minor: 1}, LifecycleDates: dates, MinimumUpdateVersion: semver.MustParse("1.0.1")},
+	}
+	var nodes []*graph.Node
+	for v, released := range map[string]time.Time{
+		"1.0.0": date(2024, 2, 15),
+		"1.1.0": date(2024, 3, 15),
+		"1.0.1": date(2024, 4, 15),
+	} {
+		nodes = append(nodes, &graph.Node{Name: "foo", Version: semver.MustParse(v), ReleaseDate: released})
+	}
+
+	health, err := trends.Health(t.Context(), []graph.Package{{Name: "foo", Streams: streams, Nodes: nodes}}, date(2024, 1, 10), date(2024, 4, 20))
+	require.NoError(t, err)
+	assert.Equal(t, []trends.MonthlyHealth{
+		{Package: "foo", Month: "2024-02", Heads: 1, DeadEnds: 0},
+		{Package: "foo", Month: "2024-03", Heads: 2, DeadEnds: 1},
+		{Package: "foo", Month: "2024-04", Heads: 2, DeadEnds: 1},
+	}, health, "months without released nodes are skipped, and 1.1.0 can't be reached from older 1.0 releases")
+}
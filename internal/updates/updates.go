@@ -0,0 +1,216 @@
+// Package updates reports the updates available from an installed version of
+// a package: its direct successors and the update that the graph recommends.
+package updates
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/joelanford/extensiondb/pkg/util"
+)
+
+// ErrUnknownVersion is returned by Find for versions that are not in the
+// graph.
+var ErrUnknownVersion = errors.New("unknown version")
+
+// Version is a version of a package and its lifecycle.
+type Version struct {
+	Version         string                `json:"version"`
+	LifecyclePhase  string                `json:"lifecyclePhase"`
+	LifecycleDates  graph.LifecycleDates  `json:"lifecycleDates"`
+	PhaseBoundaries graph.PhaseBoundaries `json:"phaseBoundaries"`
+}
+
+// Update is an update to a direct successor of the installed version.
+type Update struct {
+	Version
+	Weight float64 `json:"weight"`
+}
+
+// Recommendation is the recommended update from the installed version.
+type Recommendation struct {
+	Version
+
+	// Weight is the weight of Path, the lowest of any path to a newer
+	// version.
+	Weight float64 `json:"weight"`
+
+	// Path holds the versions to update through, from the installed version
+	// to the recommended one.
+	Path []string `json:"path"`
+}
+
+// Updates are the updates available from an installed version of a package.
+type Updates struct {
+	Package  string            `json:"package"`
+	From     Version           `json:"from"`
+	Platform *graph.MajorMinor `json:"platform,omitempty"`
+
+	// Updates are the direct successors of the installed version, lowest
+	// weight first.
+	Updates []Update `json:"updates"`
+
+	// Recommended is the version with the lowest weight path from the
+	// installed version, which edge weights make the newest version in the
+	// best lifecycle phase. It is nil if there is no update.
+	Recommended *Recommendation `json:"recommended,omitempty"`
+}
+
+// Find returns the updates available in g from version of pkg. If platform is
+// not nil, only updates to versions supported on that OpenShift version are
+// included, through versions that are at least functional on it.
+func Find(g *graph.Graph, pkg string, version semver.Version, platform *graph.MajorMinor) (Updates, error) {
+	from, err := findNode(g, pkg, version)
+	if err != nil {
+		return Updates{}, err
+	}
+	return findFrom(g, from, platform), nil
+}
+
+// findNode returns the node of version of pkg in g, with the highest release
+// if there are several.
+func findNode(g *graph.Graph, pkg string, version semver.Version) (*graph.Node, error) {
+	var from *graph.Node
+	for n := range g.NodesMatching(graph.PackageNodes(pkg)) {
+		// Nodes are yielded in order, so the highest release is kept.
+		if n.Version.EQ(version) {
+			from = n
+		}
+	}
+	if from == nil {
+		return nil, fmt.Errorf("%w %s of package %q", ErrUnknownVersion, version, pkg)
+	}
+	return from, nil
+}
+
+func findFrom(g *graph.Graph, from *graph.Node, platform *graph.MajorMinor) Updates {
+	pkg := from.Name
+	supported := func(n *graph.Node) bool {
+		return platform == nil || n.SupportedPlatformVersions.Has(*platform)
+	}
+	functional := func(n *graph.Node) bool {
+		return supported(n) || n.RequiresUpdatePlatformVersions.Has(*platform)
+	}
+
+	u := Updates{
+		Package:  pkg,
+		From:     newVersion(from),
+		Platform: platform,
+		Updates:  []Update{},
+	}
+	for to := range g.From(from) {
+		if to.Name != pkg || !supported(to) {
+			continue
+		}
+		u.Updates = append(u.Updates, Update{Version: newVersion(to), Weight: g.EdgeWeight(from, to)})
+	}
+	slices.SortStableFunc(u.Updates, func(a, b Update) int { return cmp.Compare(a.Weight, b.Weight) })
+
+	var (
+		best       []*graph.Node
+		bestWeight float64
+	)
+	for to := range g.NodesMatching(graph.PackageNodes(pkg)) {
+		if to == from || !supported(to) {
+			continue
+		}
+		path, w, ok := g.ShortestPath(from, to)
+		if !ok || slices.ContainsFunc(path[1:], func(n *graph.Node) bool { return !functional(n) }) {
+			continue
+		}
+		if best == nil || cmp.Or(
+			cmp.Compare(w, bestWeight),
+			cmp.Compare(len(path), len(best)),
+			best[len(best)-1].Compare(to),
+		) < 0 {
+			best, bestWeight = path, w
+		}
+	}
+	if best != nil {
+		u.Recommended = &Recommendation{
+			Version: newVersion(best[len(best)-1]),
+			Weight:  bestWeight,
+			Path:    util.MapSlice(best, (*graph.Node).VR),
+		}
+	}
+	return u
+}
+
+func newVersion(n *graph.Node) Version {
+	return Version{
+		Version:         n.VR(),
+		LifecyclePhase:  n.LifecyclePhase.String(),
+		LifecycleDates:  n.LifecycleDates,
+		PhaseBoundaries: n.PhaseBoundaries,
+	}
+}
+
+// Installed is a version of a package installed on a cluster.
+type Installed struct {
+	Package string         `json:"package"`
+	Version semver.Version `json:"version"`
+}
+
+// Assessment is the assessment of the updates of an installed version.
+type Assessment struct {
+	Package        string `json:"package"`
+	Version        string `json:"version"`
+	LifecyclePhase string `json:"lifecyclePhase,omitempty"`
+
+	// DaysToEndOfLife is the number of days until the version's stream
+	// reaches its end of life, or zero if it has.
+	DaysToEndOfLife int `json:"daysToEndOfLife"`
+
+	// DeadEnd is true if the version has no updates even though a newer
+	// version of its major version is in the graph.
+	DeadEnd bool `json:"deadEnd"`
+
+	Recommended *Recommendation `json:"recommended,omitempty"`
+
+	// Error is why the version could not be assessed, such as it not being
+	// in the graph.
+	Error string `json:"error,omitempty"`
+}
+
+// Assess assesses the updates of each installed version in g, in order, as
+// of the graph's as-of time. If platform is not nil, only updates to versions
+// supported on that OpenShift version are recommended, as by Find.
+func Assess(g *graph.Graph, installed []Installed, platform *graph.MajorMinor) []Assessment {
+	assessments := make([]Assessment, 0, len(installed))
+	for _, i := range installed {
+		a := Assessment{Package: i.Package, Version: i.Version.String()}
+		from, err := findNode(g, i.Package, i.Version)
+		if err != nil {
+			a.Error = err.Error()
+			assessments = append(assessments, a)
+			continue
+		}
+		a.LifecyclePhase = from.LifecyclePhase.String()
+		eol := from.LifecycleDates.EndOfLife.Time()
+		a.DaysToEndOfLife = max(0, int(math.Ceil(eol.Sub(g.AsOf()).Hours()/24)))
+		a.DeadEnd = isDeadEnd(g, from)
+		a.Recommended = findFrom(g, from, platform).Recommended
+		assessments = append(assessments, a)
+	}
+	return assessments
+}
+
+// isDeadEnd reports whether a node has no successors in its package even
+// though a newer version of its major version is in the graph. Pre-GA nodes
+// are ignored, because they never have edges.
+func isDeadEnd(g *graph.Graph, n *graph.Node) bool {
+	if n.LifecyclePhase == graph.LifecyclePhasePreGA || !g.HeadsFor(n.Name).Has(n) {
+		return false
+	}
+	for newer := range g.NodesMatching(graph.PackageNodes(n.Name)) {
+		if newer.LifecyclePhase != graph.LifecyclePhasePreGA && newer.Version.Major == n.Version.Major && newer.Version.GT(n.Version) {
+			return true
+		}
+	}
+	return false
+}
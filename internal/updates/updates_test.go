@@ -0,0 +1,92 @@
+package updates_test
+
+import (
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/internal/updates"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/joelanford/extensiondb/pkg/graph/graphtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testGraph(t *testing.T, minimumUpdateVersion semver.Version) *graph.Graph {
+	t.Helper()
+	mm := func(minor uint64) graph.MajorMinor { return graph.MajorMinor{Major: 4, Minor: minor} }
+	streams := graphtest.Streams(graph.MajorMinor{Major: 1, Minor: 0}, graph.MajorMinor{Major: 1, Minor: 1})
+	streams[0].SupportedPlatformVersions = []graph.MajorMinor{mm(14), mm(15)}
+	streams[0].RequiresUpdatePlatformVersions = []graph.MajorMinor{mm(16)}
+	streams[1].SupportedPlatformVersions = []graph.MajorMinor{mm(16), mm(17)}
+	streams[1].MinimumUpdateVersion = minimumUpdateVersion
+	return graphtest.New(t, graph.Package{Name: "foo", Streams: streams, Nodes: graphtest.Nodes("foo", "1.0.0", "1.0.1", "1.1.0")})
+}
+
+func versions(us []updates.Update) []string {
+	var vs []string
+	for _, u := range us {
+		vs = append(vs, u.Version.Version)
+	}
+	return vs
+}
+
+func TestFind(t *testing.T) {
+	g := testGraph(t, semver.Version{})
+
+	u, err := updates.Find(g, "foo", semver.MustParse("1.0.0"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0", u.From.Version)
+	assert.Equal(t, "Full Support", u.From.LifecyclePhase)
+	assert.Equal(t, graph.NewDate(2030, 1, 1), u.From.LifecycleDates.EndOfLife)
+	assert.Equal(t, []string{"1.1.0", "1.0.1"}, versions(u.Updates), "the newest version has the lowest weight")
+	assert.Less(t, u.Updates[0].Weight, u.Updates[1].Weight)
+	require.NotNil(t, u.Recommended)
+	assert.Equal(t, "1.1.0", u.Recommended.Version.Version)
+	assert.Equal(t, []string{"1.0.0", "1.1.0"}, u.Recommended.Path)
+	assert.Equal(t, u.Updates[0].Weight, u.Recommended.Weight)
+
+	// 1.1 is not supported on 4.15.
+	platform := graph.MajorMinor{Major: 4, Minor: 15}
+	u, err = updates.Find(g, "foo", semver.MustParse("1.0.0"), &platform)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.0.1"}, versions(u.Updates))
+	require.NotNil(t, u.Recommended)
+	assert.Equal(t, "1.0.1", u.Recommended.Version.Version)
+
+	// The newest version has nowhere to go.
+	u, err = updates.Find(g, "foo", semver.MustParse("1.1.0"), nil)
+	require.NoError(t, err)
+	assert.Empty(t, u.Updates)
+	assert.NotNil(t, u.Updates, "updates are encoded as an empty array")
+	assert.Nil(t, u.Recommended)
+
+	_, err = updates.Find(g, "foo", semver.MustParse("2.0.0"), nil)
+	assert.ErrorIs(t, err, updates.ErrUnknownVersion)
+}
+
+func TestAssess(t *testing.T) {
+	// Neither 1.0.x version can update to 1.1.0, which makes 1.0.1 a dead
+	// end.
+	g := testGraph(t, semver.MustParse("1.0.2"))
+	platform := graph.MajorMinor{Major: 4, Minor: 15}
+	assessments := updates.Assess(g, []updates.Installed{
+		{Package: "foo", Version: semver.MustParse("1.0.0")},
+		{Package: "foo", Version: semver.MustParse("1.0.1")},
+		{Package: "bar", Version: semver.MustParse("1.0.0")},
+	}, &platform)
+	require.Len(t, assessments, 3)
+
+	assert.Equal(t, "1.0.0", assessments[0].Version)
+	assert.Equal(t, "Full Support", assessments[0].LifecyclePhase)
+	assert.Equal(t, 1826, assessments[0].DaysToEndOfLife)
+	assert.False(t, assessments[0].DeadEnd)
+	require.NotNil(t, assessments[0].Recommended)
+	assert.Equal(t, "1.0.1", assessments[0].Recommended.Version.Version)
+
+	assert.True(t, assessments[1].DeadEnd)
+	assert.Nil(t, assessments[1].Recommended)
+	assert.Empty(t, assessments[1].Error)
+
+	assert.Equal(t, "bar", assessments[2].Package)
+	assert.Contains(t, assessments[2].Error, "unknown version")
+}
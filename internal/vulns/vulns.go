@@ -0,0 +1,215 @@
+// Package vulns ingests vulnerability feeds in the OpenVEX and OSV formats,
+// and records which bundles they affect through the bundle image or its
+// related images.
+package vulns
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/opencontainers/go-digest"
+)
+
+// Status is the status of a vulnerability in an image, as defined by
+// OpenVEX.
+type Status string
+
+const (
+	StatusAffected           Status = "affected"
+	StatusNotAffected        Status = "not_affected"
+	StatusFixed              Status = "fixed"
+	StatusUnderInvestigation Status = "under_investigation"
+)
+
+// Statement is the status of a vulnerability in the image with a digest.
+type Statement struct {
+	Vulnerability string
+	Digest        digest.Digest
+	Status        Status
+}
+
+// Parse parses an OpenVEX document, a single OSV entry, a list of OSV
+// entries, or an OSV query response of the form {"vulns": [...]}. Products
+// and packages that do not identify an image by digest are ignored. OSV
+// entries only report affected images.
+func Parse(data []byte) ([]Statement, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var entries []osvEntry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("invalid OSV entries: %w", err)
+		}
+		return osvStatements(entries), nil
+	}
+
+	var doc struct {
+		Statements []json.RawMessage `json:"statements"`
+		Vulns      []osvEntry        `json:"vulns"`
+		Affected   json.RawMessage   `json:"affected"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid vulnerability feed: %w", err)
+	}
+	switch {
+	case doc.Statements != nil:
+		return parseOpenVEX(data)
+	case doc.Vulns != nil:
+		return osvStatements(doc.Vulns), nil
+	case doc.Affected != nil:
+		var entry osvEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, fmt.Errorf("invalid OSV entry: %w", err)
+		}
+		return osvStatements([]osvEntry{entry}), nil
+	}
+	return nil, errors.New("unrecognized vulnerability feed: expected an OpenVEX document or OSV entries")
+}
+
+// openVEXDocument is an OpenVEX document. Vulnerabilities and products are
+// objects since v0.2.0, and were plain strings before.
+type openVEXDocument struct {
+	Statements []struct {
+		Vulnerability json.RawMessage   `json:"vulnerability"`
+		Products      []json.RawMessage `json:"products"`
+		Status        Status            `json:"status"`
+	} `json:"statements"`
+}
+
+func parseOpenVEX(data []byte) ([]Statement, error) {
+	var doc openVEXDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid OpenVEX document: %w", err)
+	}
+	var statements []Statement
+	for i, s := range doc.Statements {
+		vuln, err := stringOrField(s.Vulnerability, "name")
+		if err != nil || vuln == "" {
+			return nil, fmt.Errorf("OpenVEX statement %d: invalid vulnerability", i)
+		}
+		switch s.Status {
+		case StatusAffected, StatusNotAffected, StatusFixed, StatusUnderInvestigation:
+		default:
+			return nil, fmt.Errorf("OpenVEX statement %d: invalid status %q", i, s.Status)
+		}
+		for _, p := range s.Products {
+			id, err := stringOrField(p, "@id")
+			if err != nil {
+				return nil, fmt.Errorf("OpenVEX statement %d: invalid product: %w", i, err)
+			}
+			if dgst, ok := productDigest(id); ok {
+				statements = append(statements, Statement{Vulnerability: vuln, Digest: dgst, Status: s.Status})
+			}
+		}
+	}
+	return statements, nil
+}
+
+// stringOrField returns data if it is a JSON string, or the string field of
+// data if it is a JSON object.
+func stringOrField(data json.RawMessage, field string) (string, error) {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		return s, nil
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return "", err
+	}
+	if err := json.Unmarshal(obj[field], &s); err != nil {
+		return "", fmt.Errorf("invalid %q: %w", field, err)
+	}
+	return s, nil
+}
+
+type osvEntry struct {
+	ID       string `json:"id"`
+	Affected []struct {
+		Package struct {
+			PURL string `json:"purl"`
+		} `json:"package"`
+	} `json:"affected"`
+}
+
+func osvStatements(entries []osvEntry) []Statement {
+	var statements []Statement
+	for _, e := range entries {
+		for _, a := range e.Affected {
+			if dgst, ok := productDigest(a.Package.PURL); ok {
+				statements = append(statements, Statement{Vulnerability: e.ID, Digest: dgst, Status: StatusAffected})
+			}
+		}
+	}
+	return statements
+}
+
+var digestPattern = regexp.MustCompile(`sha256:[a-f0-9]{64}`)
+
+// productDigest returns the image digest in a product identifier, such as
+// pkg:oci/foo@sha256%3A...?repository_url=quay.io/example or
+// quay.io/example/foo@sha256:....
+func productDigest(id string) (digest.Digest, bool) {
+	if unescaped, err := url.PathUnescape(id); err == nil {
+		id = unescaped
+	}
+	match := digestPattern.FindString(id)
+	if match == "" {
+		return "", false
+	}
+	return digest.Digest(match), true
+}
+
+// Fetch reads a feed from an http(s) URL or a local file.
+func Fetch(ctx context.Context, client *http.Client, source string) ([]byte, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.ReadFile(source)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", source, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// Ingest records the statements for the bundles whose bundle image or
+// related images they are about. Later statements about the same image and
+// vulnerability replace earlier ones. It returns the number of bundle
+// statuses recorded.
+func Ingest(ctx context.Context, q *query.Query, statements []Statement) (int64, error) {
+	var total int64
+	for _, s := range statements {
+		n, err := q.SetImageVulnerabilityStatus(ctx, s.Digest, s.Vulnerability, string(s.Status))
+		if err != nil {
+			return total, fmt.Errorf("error recording %s for %s: %w", s.Vulnerability, s.Digest, err)
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// UnaffectedNodes matches the nodes whose bundles are not affected by any
+// vulnerability. affected holds the vulnerabilities that affect each bundle,
+// keyed by bundle digest, as returned by query.Query.GetAffectedBundles.
+func UnaffectedNodes(affected map[digest.Digest][]string) graph.NodePredicate {
+	return func(_ *graph.Graph, n *graph.Node) bool {
+		return n.ImageReference == nil || len(affected[n.ImageReference.Digest()]) == 0
+	}
+}
@@ -0,0 +1,131 @@
+package vulns_test
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/models"
+	"github.com/joelanford/extensiondb/internal/query"
+	"github.com/joelanford/extensiondb/internal/query/querytest"
+	"github.com/joelanford/extensiondb/internal/vulns"
+	"github.com/joelanford/extensiondb/pkg/dbtest"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(dbtest.Main(m))
+}
+
+var (
+	operatorDigest = digest.FromString("operator")
+	operandDigest  = digest.FromString("operand")
+)
+
+func TestParseOpenVEX(t *testing.T) {
+	statements, err := vulns.Parse([]byte(`{
+  "@context": "https://openvex.dev/ns/v0.2.0",
+  "statements": [
+    {
+      "vulnerability": {"name": "CVE-2024-0001"},
+      "products": [
+        {"@id": "pkg:oci/foo-operator@` + url(operatorDigest) + `?repository_url=quay.io/example/foo-operator"},
+        {"@id": "pkg:golang/example.com/foo@v1.0.0"}
+      ],
+      "status": "affected"
+    },
+    {
+      "vulnerability": "CVE-2024-0002",
+      "products": ["quay.io/example/foo-operand@` + operandDigest.String() + `"],
+      "status": "fixed"
+    }
+  ]
+}`))
+	require.NoError(t, err)
+	assert.Equal(t, []vulns.Statement{
+		{Vulnerability: "CVE-2024-0001", Digest: operatorDigest, Status: vulns.StatusAffected},
+		{Vulnerability: "CVE-2024-0002", Digest: operandDigest, Status: vulns.StatusFixed},
+	}, statements)
+
+	_, err = vulns.Parse([]byte(`{"statements": [{"vulnerability": {"name": "CVE-2024-0001"}, "status": "unknown"}]}`))
+	assert.Error(t, err)
+}
+
+func TestParseOSV(t *testing.T) {
+	entry := `{"id": "GHSA-xxxx", "affected": [{"package": {"ecosystem": "OCI", "name": "foo-operator", "purl": "pkg:oci/foo-operator@` + url(operatorDigest) + `"}}]}`
+	want := []vulns.Statement{{Vulnerability: "GHSA-xxxx", Digest: operatorDigest, Status: vulns.StatusAffected}}
+	for name, feed := range map[string]string{
+		"entry":    entry,
+		"list":     "[" + entry + "]",
+		"response": `{"vulns": [` + entry + `]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			statements, err := vulns.Parse([]byte(feed))
+			require.NoError(t, err)
+			assert.Equal(t, want, statements)
+		})
+	}
+
+	_, err := vulns.Parse([]byte(`{"kind": "unknown"}`))
+	assert.Error(t, err)
+}
+
+func TestFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/feed.json" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"vulns": []}`))
+	}))
+	defer srv.Close()
+
+	data, err := vulns.Fetch(t.Context(), srv.Client(), srv.URL+"/feed.json")
+	require.NoError(t, err)
+	assert.Equal(t, `{"vulns": []}`, string(data))
+	_, err = vulns.Fetch(t.Context(), srv.Client(), srv.URL+"/missing.json")
+	assert.Error(t, err)
+
+	path := filepath.Join(t.TempDir(), "feed.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[]`), 0o600))
+	data, err = vulns.Fetch(t.Context(), srv.Client(), path)
+	require.NoError(t, err)
+	assert.Equal(t, `[]`, string(data))
+}
+
+func TestIngest(t *testing.T) {
+	db := dbtest.New(t)
+	q := query.New(db)
+	old := querytest.Bundle(t, db, "foo", "1.0.0", time.Now())
+	require.NoError(t, q.CreateBundleRelatedImages(t.Context(), old, []models.RelatedImage{
+		{Name: "operator", Image: "quay.io/example/foo-operator@" + operatorDigest.String(), Digest: sql.NullString{String: operatorDigest.String(), Valid: true}},
+	}))
+	querytest.Bundle(t, db, "foo", "1.0.1", time.Now())
+	bundleDigest := querytest.BundleImage("foo", "1.0.1").Digest()
+
+	n, err := vulns.Ingest(t.Context(), q, []vulns.Statement{
+		{Vulnerability: "CVE-2024-0001", Digest: operatorDigest, Status: vulns.StatusAffected},
+		{Vulnerability: "CVE-2024-0002", Digest: bundleDigest, Status: vulns.StatusAffected},
+		{Vulnerability: "CVE-2024-0002", Digest: bundleDigest, Status: vulns.StatusFixed},
+		{Vulnerability: "CVE-2024-0003", Digest: digest.FromString("unknown"), Status: vulns.StatusAffected},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	affected, err := q.GetAffectedBundles(t.Context(), "foo")
+	require.NoError(t, err)
+	assert.Equal(t, map[digest.Digest][]string{
+		querytest.BundleImage("foo", "1.0.0").Digest(): {"CVE-2024-0001"},
+	}, affected, "later statements replace earlier ones")
+}
+
+// url returns a digest as it appears in a package URL.
+func url(d digest.Digest) string {
+	return d.Algorithm().String() + "%3A" + d.Encoded()
+}
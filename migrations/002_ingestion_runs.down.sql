@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_ingestion_runs_finished_at;
+DROP TABLE IF EXISTS ingestion_runs;
@@ -0,0 +1,8 @@
+CREATE TABLE ingestion_runs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMP WITH TIME ZONE,
+    error TEXT
+);
+CREATE INDEX idx_ingestion_runs_finished_at ON ingestion_runs (finished_at);
@@ -0,0 +1,11 @@
+CREATE TABLE lifecycle_notifications (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    package_name TEXT NOT NULL,
+    stream TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    "date" DATE NOT NULL,
+
+    sent_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    UNIQUE (package_name, stream, kind, "date")
+);
@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS jira_issues;
+
+ALTER TABLE packages
+    DROP COLUMN IF EXISTS jira_feature_project,
+    DROP COLUMN IF EXISTS jira_feature_component,
+    DROP COLUMN IF EXISTS jira_bug_project,
+    DROP COLUMN IF EXISTS jira_bug_component;
+
+ALTER TABLE catalogs
+    DROP COLUMN IF EXISTS jira_feature_project,
+    DROP COLUMN IF EXISTS jira_feature_component,
+    DROP COLUMN IF EXISTS jira_bug_project,
+    DROP COLUMN IF EXISTS jira_bug_component;
@@ -0,0 +1,19 @@
+ALTER TABLE catalogs
+    ADD COLUMN jira_feature_project TEXT,
+    ADD COLUMN jira_feature_component TEXT,
+    ADD COLUMN jira_bug_project TEXT,
+    ADD COLUMN jira_bug_component TEXT;
+
+ALTER TABLE packages
+    ADD COLUMN jira_feature_project TEXT,
+    ADD COLUMN jira_feature_component TEXT,
+    ADD COLUMN jira_bug_project TEXT,
+    ADD COLUMN jira_bug_component TEXT;
+
+CREATE TABLE jira_issues (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    problem TEXT NOT NULL UNIQUE,
+    issue_key TEXT NOT NULL,
+
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1 @@
+ALTER TABLE catalogs ADD COLUMN digest TEXT;
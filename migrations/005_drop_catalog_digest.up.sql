@@ -0,0 +1,3 @@
+-- Catalog digests are tracked in catalog_digests. The original column was
+-- never written, so inserting a catalog violated its NOT NULL constraint.
+ALTER TABLE catalogs DROP COLUMN digest;
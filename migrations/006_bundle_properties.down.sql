@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_bundle_properties_type;
+DROP INDEX IF EXISTS idx_bundle_properties_bundle_id;
+DROP TABLE IF EXISTS bundle_properties;
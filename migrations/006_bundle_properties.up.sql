@@ -0,0 +1,9 @@
+-- Properties declared by a bundle's ClusterServiceVersion in its olm.properties
+-- annotation, such as olm.maxOpenShiftVersion.
+CREATE TABLE bundle_properties (
+    bundle_id UUID NOT NULL REFERENCES bundles(id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    value JSONB NOT NULL
+);
+CREATE INDEX idx_bundle_properties_bundle_id ON bundle_properties (bundle_id);
+CREATE INDEX idx_bundle_properties_type ON bundle_properties (type);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS bundle_errata;
+DROP TABLE IF EXISTS bundle_builds;
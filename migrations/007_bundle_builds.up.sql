@@ -0,0 +1,21 @@
+-- Records of the builds that produced bundles, from build systems such as
+-- Brew and Konflux.
+CREATE TABLE bundle_builds (
+    bundle_id UUID PRIMARY KEY REFERENCES bundles(id) ON DELETE CASCADE,
+
+    system TEXT NOT NULL,
+    build_id TEXT NOT NULL,
+    source_repository TEXT,
+    source_commit TEXT,
+    url TEXT,
+
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Errata advisories that shipped a bundle.
+CREATE TABLE bundle_errata (
+    bundle_id UUID NOT NULL REFERENCES bundles(id) ON DELETE CASCADE,
+    advisory TEXT NOT NULL,
+
+    PRIMARY KEY (bundle_id, advisory)
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS bundle_vulnerabilities;
+DROP INDEX IF EXISTS idx_bundle_related_images_digest;
+DROP TABLE IF EXISTS bundle_related_images;
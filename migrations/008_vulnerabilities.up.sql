@@ -0,0 +1,24 @@
+-- Images that a bundle's ClusterServiceVersion lists in spec.relatedImages.
+-- digest is the digest of the image reference, if it has one.
+CREATE TABLE bundle_related_images (
+    bundle_id UUID NOT NULL REFERENCES bundles(id) ON DELETE CASCADE,
+    "name" TEXT,
+    image TEXT NOT NULL,
+    digest TEXT,
+
+    PRIMARY KEY (bundle_id, image)
+);
+CREATE INDEX idx_bundle_related_images_digest ON bundle_related_images (digest);
+
+-- Status of a vulnerability in a bundle, as reported by a vulnerability feed
+-- for the bundle image or one of its related images.
+CREATE TABLE bundle_vulnerabilities (
+    bundle_id UUID NOT NULL REFERENCES bundles(id) ON DELETE CASCADE,
+    vulnerability TEXT NOT NULL,
+    image_digest TEXT NOT NULL,
+    status TEXT NOT NULL,
+
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    PRIMARY KEY (bundle_id, vulnerability, image_digest)
+);
@@ -0,0 +1,11 @@
+-- Results of verifying the signatures and provenance attestations of bundle
+-- images. Bundles without a row have not been verified.
+CREATE TABLE bundle_verifications (
+    bundle_id UUID PRIMARY KEY REFERENCES bundles(id) ON DELETE CASCADE,
+
+    verified BOOLEAN NOT NULL,
+    signer TEXT,
+    message TEXT,
+
+    verified_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS catalog_digest_default_channels;
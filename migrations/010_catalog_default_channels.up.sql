@@ -0,0 +1,9 @@
+-- The default channel of each package in a catalog digest, as declared by
+-- the package's olm.package blob.
+CREATE TABLE catalog_digest_default_channels (
+    catalog_digest_id UUID NOT NULL REFERENCES catalog_digests(id) ON DELETE CASCADE,
+    package_name TEXT NOT NULL,
+    default_channel TEXT NOT NULL,
+
+    PRIMARY KEY (catalog_digest_id, package_name)
+);
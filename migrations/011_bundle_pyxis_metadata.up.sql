@@ -0,0 +1,15 @@
+-- Metadata about bundles from the Red Hat Ecosystem Catalog (Pyxis). Bundles
+-- that were looked up but are not in the catalog have a row with only
+-- certified = false, so that they are not looked up again until the row is
+-- stale.
+CREATE TABLE bundle_pyxis_metadata (
+    bundle_id UUID PRIMARY KEY REFERENCES bundles(id) ON DELETE CASCADE,
+
+    certified BOOLEAN NOT NULL,
+    vendor TEXT,
+    support_level TEXT,
+    repository TEXT,
+    published_at TIMESTAMP WITH TIME ZONE,
+
+    fetched_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
@@ -0,0 +1,8 @@
+-- When each catalog's digest last changed and when the catalog was last
+-- ingested successfully.
+CREATE TABLE catalog_freshness (
+    catalog_id UUID PRIMARY KEY REFERENCES catalogs(id) ON DELETE CASCADE,
+    digest TEXT NOT NULL,
+    digest_changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    ingested_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
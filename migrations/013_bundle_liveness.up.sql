@@ -0,0 +1,18 @@
+-- Results of checking that the images of bundles still exist in their
+-- registries and that their tags still refer to them. Bundles without a row
+-- have not been checked. last_verified_at is the last time the bundle was
+-- found live, and is kept when a later check finds it missing or retagged.
+CREATE TABLE bundle_liveness (
+    bundle_id UUID PRIMARY KEY REFERENCES bundles(id) ON DELETE CASCADE,
+
+    status TEXT NOT NULL,
+    message TEXT,
+
+    checked_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_verified_at TIMESTAMP WITH TIME ZONE,
+
+    CONSTRAINT bundle_liveness_status CHECK (
+        status IN ('live', 'missing', 'retagged')
+    )
+);
+CREATE INDEX idx_bundle_liveness_checked_at ON bundle_liveness (checked_at);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS lifecycle_dates;
+DROP TABLE IF EXISTS version_streams;
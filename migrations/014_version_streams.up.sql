@@ -0,0 +1,24 @@
+-- Version streams of packages, as described by olm.cincinnati templates, so
+-- that lifecycle phases can be computed without access to the templates.
+-- version is the stream's <major>.<minor>, and the platform versions are
+-- <major>.<minor> too.
+CREATE TABLE version_streams (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    package_name TEXT NOT NULL,
+    version TEXT NOT NULL,
+    minimum_update_version TEXT NOT NULL,
+    supported_platform_versions TEXT[] NOT NULL DEFAULT '{}',
+    requires_update_platform_versions TEXT[] NOT NULL DEFAULT '{}',
+
+    UNIQUE (package_name, version)
+);
+
+-- The dates on which each version stream enters each lifecycle phase.
+-- extensions are the dates on which it enters each extension phase, in order.
+CREATE TABLE lifecycle_dates (
+    version_stream_id UUID PRIMARY KEY REFERENCES version_streams(id) ON DELETE CASCADE,
+    full_support DATE NOT NULL,
+    maintenance DATE NOT NULL,
+    extensions DATE[] NOT NULL DEFAULT '{}',
+    end_of_life DATE NOT NULL
+);
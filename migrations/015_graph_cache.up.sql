@@ -0,0 +1,12 @@
+-- Graphs built by graphdb.Builder, encoded by graph.Graph.Encode, so that
+-- they are only rebuilt once a new ingestion run finishes. key identifies the
+-- templates, options, and date the graph was built with.
+CREATE TABLE graph_cache (
+    ingestion_run_id UUID NOT NULL REFERENCES ingestion_runs(id) ON DELETE CASCADE,
+    key TEXT NOT NULL,
+    data BYTEA NOT NULL,
+
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (ingestion_run_id, key)
+);
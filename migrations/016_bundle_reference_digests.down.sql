@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_bundle_references_digest;
@@ -0,0 +1,3 @@
+-- Looks up the references of an image by digest alone, whatever repository it
+-- was pulled from, such as when finding the catalogs that shipped it.
+CREATE INDEX idx_bundle_references_digest ON bundle_references (digest);
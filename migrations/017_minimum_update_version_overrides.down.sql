@@ -0,0 +1 @@
+DROP TABLE IF EXISTS minimum_update_version_overrides;
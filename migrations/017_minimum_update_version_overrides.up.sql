@@ -0,0 +1,14 @@
+-- Minimum update versions that override those of the version streams of
+-- olm.cincinnati templates, so that they can be tightened without shipping
+-- a new template. Overrides are kept separately from version_streams so that
+-- importing templates doesn't drop them. version is the stream's
+-- <major>.<minor>.
+CREATE TABLE minimum_update_version_overrides (
+    package_name TEXT NOT NULL,
+    version TEXT NOT NULL,
+    minimum_update_version TEXT NOT NULL,
+
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (package_name, version)
+);
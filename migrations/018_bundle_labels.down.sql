@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_bundle_labels_key_value;
+DROP TABLE IF EXISTS bundle_labels;
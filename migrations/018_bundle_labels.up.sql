@@ -0,0 +1,19 @@
+-- Labels of bundle images that are useful for analysis, so that they can be
+-- queried without reading the image config in bundles.image. Only the
+-- operators.operatorframework.io.bundle.* and com.redhat.* labels are kept,
+-- the same prefixes that ingestion keeps. Labels of bundles that were already
+-- stored are copied from their image configs.
+CREATE TABLE bundle_labels (
+    bundle_id UUID NOT NULL REFERENCES bundles(id) ON DELETE CASCADE,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+
+    PRIMARY KEY (bundle_id, key)
+);
+CREATE INDEX idx_bundle_labels_key_value ON bundle_labels (key, value);
+
+INSERT INTO bundle_labels (bundle_id, key, value)
+SELECT b.id, l.key, l.value
+FROM bundles AS b,
+    jsonb_each_text(CASE WHEN jsonb_typeof(b.image -> 'config' -> 'Labels') = 'object' THEN b.image -> 'config' -> 'Labels' ELSE '{}' END) AS l
+WHERE l.key LIKE 'operators.operatorframework.io.bundle.%' OR l.key LIKE 'com.redhat.%';
@@ -0,0 +1,9 @@
+ALTER TABLE bundles
+    DROP CONSTRAINT IF EXISTS bundles_csv_digest,
+    DROP COLUMN IF EXISTS csv_digest;
+
+DROP TRIGGER IF EXISTS blobs_unlink ON blobs;
+DROP FUNCTION IF EXISTS unlink_blob();
+
+SELECT lo_unlink(oid) FROM blobs;
+DROP TABLE IF EXISTS blobs;
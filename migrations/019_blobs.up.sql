@@ -0,0 +1,33 @@
+-- Blobs stored as large objects by the postgres blob store, by the digest of
+-- their content. Large objects are unlinked when their row is deleted.
+CREATE TABLE blobs (
+    digest TEXT PRIMARY KEY,
+    oid OID NOT NULL,
+    size BIGINT NOT NULL,
+
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT valid_digest CHECK (
+        digest ~ '^sha256:[a-f0-9]{64}$'
+    )
+);
+
+CREATE FUNCTION unlink_blob() RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM lo_unlink(OLD.oid);
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER blobs_unlink AFTER DELETE ON blobs
+    FOR EACH ROW EXECUTE FUNCTION unlink_blob();
+
+-- The digest of each bundle's ClusterServiceVersion, as JSON, in the blob
+-- store it was ingested with. Bundles ingested before CSVs were stored, and
+-- bundles without a CSV, have none.
+ALTER TABLE bundles
+    ADD COLUMN csv_digest TEXT,
+    ADD CONSTRAINT bundles_csv_digest CHECK (
+        csv_digest IS NULL OR
+        csv_digest ~ '^sha256:[a-f0-9]{64}$'
+    );
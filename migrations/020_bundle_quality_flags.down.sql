@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_bundles_quality_flags;
+ALTER TABLE bundles DROP COLUMN IF EXISTS quality_flags;
@@ -0,0 +1,6 @@
+-- Problems found in each bundle's ClusterServiceVersion when it was ingested,
+-- such as missing-version, named by the flags of the csvquality package.
+-- Bundles without problems have no flags, and bundles ingested before CSVs
+-- were checked, or without a CSV, have NULL.
+ALTER TABLE bundles ADD COLUMN quality_flags TEXT[];
+CREATE INDEX idx_bundles_quality_flags ON bundles USING GIN (quality_flags);
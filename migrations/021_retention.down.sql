@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_ingestion_runs_started_at;
+DROP INDEX IF EXISTS idx_lifecycle_notifications_sent_at;
@@ -0,0 +1,4 @@
+-- Retention deletes ingestion runs and the records of sent notifications
+-- oldest first, by when they started and when they were sent.
+CREATE INDEX idx_lifecycle_notifications_sent_at ON lifecycle_notifications (sent_at);
+CREATE INDEX idx_ingestion_runs_started_at ON ingestion_runs (started_at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_bundles_content_fingerprint;
+ALTER TABLE bundles DROP COLUMN IF EXISTS content_fingerprint;
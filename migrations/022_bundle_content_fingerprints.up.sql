@@ -0,0 +1,8 @@
+-- A fingerprint of each bundle's content, computed by the fingerprint
+-- package from its ClusterServiceVersion and other manifests with the fields
+-- that rebuilds change, such as createdAt, removed. Bundles of the same
+-- package and version with the same fingerprint are rebuilds that change
+-- nothing that they install. Bundles ingested before fingerprints were
+-- computed, or without a CSV, have NULL.
+ALTER TABLE bundles ADD COLUMN content_fingerprint TEXT;
+CREATE INDEX idx_bundles_content_fingerprint ON bundles (content_fingerprint);
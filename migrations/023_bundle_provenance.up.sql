@@ -0,0 +1,23 @@
+-- Which ingestion created each bundle and which last updated it, so that
+-- data quality problems can be traced back to the ingestion path that wrote
+-- them. source_type is catalog for catalog walks, webhook for pushed images,
+-- read-through for images that clients looked up, or backfill for migrations
+-- of legacy data, and source_id identifies the catalog tag, image, or
+-- backfill. Bundles ingested before provenance was recorded have no rows.
+CREATE TABLE bundle_provenance (
+    bundle_id UUID NOT NULL REFERENCES bundles(id) ON DELETE CASCADE,
+    event TEXT NOT NULL,
+
+    source_type TEXT NOT NULL,
+    source_id TEXT NOT NULL,
+    ingestion_run_id UUID REFERENCES ingestion_runs(id) ON DELETE SET NULL,
+
+    recorded_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (bundle_id, event),
+    CONSTRAINT bundle_provenance_event CHECK (
+        event IN ('created', 'updated')
+    )
+);
+CREATE INDEX idx_bundle_provenance_ingestion_run_id ON bundle_provenance (ingestion_run_id);
+CREATE INDEX idx_bundle_provenance_source ON bundle_provenance (source_type, source_id);
@@ -0,0 +1,18 @@
+-- Bundle images that ingestion failed to fetch, after retrying transient
+-- errors, so that they can be retried later rather than waiting for the next
+-- walk of their catalog. attempts counts every fetch of the reference since it
+-- first failed, and source_type and source_id are those of the ingestion that
+-- last failed to fetch it, as in bundle_provenance. Rows are removed once the
+-- bundle is ingested.
+CREATE TABLE fetch_failures (
+    reference TEXT PRIMARY KEY,
+    error TEXT NOT NULL,
+    attempts INTEGER NOT NULL CHECK (attempts > 0),
+
+    source_type TEXT NOT NULL,
+    source_id TEXT NOT NULL,
+
+    first_failed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+CREATE INDEX idx_fetch_failures_last_attempt_at ON fetch_failures (last_attempt_at);
@@ -0,0 +1,10 @@
+// Package migrations embeds the database migrations, so that programs and
+// tests outside of the repository can migrate a database.
+package migrations
+
+import "embed"
+
+// FS holds the migrations.
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,163 @@
+// Package client is a typed client for the extensiondb HTTP API. The API is
+// described by the OpenAPI document served at /api/openapi.yaml.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/operator-framework/operator-registry/alpha/declcfg"
+)
+
+// Client calls the extensiondb HTTP API
+type Client struct {
+	// BearerToken, if set, is sent with every request to authenticate the
+	// client. Clients that authenticate with TLS client certificates should
+	// instead configure the certificate in the http.Client's transport.
+	BearerToken string
+
+	baseURL    *url.URL
+	httpClient *http.Client
+}
+
+// New creates a new client for the server at baseURL. If httpClient is nil,
+// http.DefaultClient is used.
+func New(baseURL string, httpClient *http.Client) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL %q: %w", baseURL, err)
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{baseURL: u, httpClient: httpClient}, nil
+}
+
+// Error is returned when the server responds with a non-successful status code.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("server responded with status %d: %s", e.StatusCode, e.Message)
+}
+
+// CincinnatiGraph returns the update graph for a channel of a package. The
+// version is optional and identifies the version the caller has installed.
+func (c *Client) CincinnatiGraph(ctx context.Context, pkg, channel, version string) (*graph.CincinnatiGraph, error) {
+	params := url.Values{}
+	params.Set("channel", fmt.Sprintf("%s:%s", pkg, channel))
+	if version != "" {
+		params.Set("version", version)
+	}
+
+	var cg graph.CincinnatiGraph
+	if err := c.do(ctx, http.MethodGet, "/api/upgrades_info/graph", params, nil, &cg); err != nil {
+		return nil, err
+	}
+	return &cg, nil
+}
+
+// GraphQLError is an error reported in a GraphQL response.
+type GraphQLError struct {
+	Message string `json:"message"`
+}
+
+// GraphQLResponse is the result of a GraphQL query.
+type GraphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []GraphQLError  `json:"errors,omitempty"`
+}
+
+// GraphQL executes a GraphQL query and returns the raw response.
+func (c *Client) GraphQL(ctx context.Context, query string, variables map[string]any) (*GraphQLResponse, error) {
+	body, err := json.Marshal(map[string]any{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp GraphQLResponse
+	if err := c.do(ctx, http.MethodPost, "/api/graphql", nil, body, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CatalogAll returns the declarative config the server renders for a catalog.
+// If packages is non-empty, the config is limited to those packages.
+func (c *Client) CatalogAll(ctx context.Context, name, tag string, packages ...string) (*declcfg.DeclarativeConfig, error) {
+	params := url.Values{}
+	for _, pkg := range packages {
+		params.Add("package", pkg)
+	}
+
+	var cfg *declcfg.DeclarativeConfig
+	if err := c.doFunc(ctx, http.MethodGet, fmt.Sprintf("/catalogs/%s/%s/api/v1/all", url.PathEscape(name), url.PathEscape(tag)), params, nil, func(r io.Reader) error {
+		var err error
+		cfg, err = declcfg.LoadReader(r)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, params url.Values, body []byte, out any) error {
+	return c.doFunc(ctx, method, path, params, body, func(r io.Reader) error {
+		if err := json.NewDecoder(r).Decode(out); err != nil {
+			return fmt.Errorf("error decoding response: %w", err)
+		}
+		return nil
+	})
+}
+
+func (c *Client) doFunc(ctx context.Context, method, path string, params url.Values, body []byte, readBody func(io.Reader) error) error {
+	u := c.baseURL.JoinPath(path)
+	u.RawQuery = params.Encode()
+
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bodyReader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.BearerToken)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		msg := string(respBody)
+		if err := json.Unmarshal(respBody, &apiErr); err == nil && apiErr.Error != "" {
+			msg = apiErr.Error
+		}
+		return &Error{StatusCode: resp.StatusCode, Message: msg}
+	}
+
+	return readBody(resp.Body)
+}
@@ -0,0 +1,296 @@
+// Package dbtest provides migrated Postgres databases for tests.
+//
+// Tests get a database from a Postgres server named by the
+// EXTENSIONDB_TEST_DATABASE_URL environment variable, or else from a Postgres
+// container started with docker or podman. Tests are skipped when neither is
+// available, including when an installed docker or podman can't start the
+// container, such as docker without a running daemon, unless the runtime was
+// named by EnvContainerRuntime. Packages whose tests use this package should stop the container
+// once their tests finish by calling Main from TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(dbtest.Main(m))
+//	}
+//
+// The container is tied to the standard input of the attached docker run or
+// podman run process, and stops and removes itself once that input closes.
+// Containers of test binaries that are killed before Main stops them are
+// therefore reaped as well, without a reaper container such as the Ryuk
+// container of testcontainers-go, which rootless podman can't run.
+package dbtest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joelanford/extensiondb/internal/db"
+	"github.com/joelanford/extensiondb/migrations"
+	"github.com/lib/pq"
+)
+
+const (
+	// EnvDatabaseURL names a Postgres server, as a postgres:// URL of a
+	// database the user can create databases from, to use instead of a
+	// container.
+	EnvDatabaseURL = "EXTENSIONDB_TEST_DATABASE_URL"
+
+	// EnvContainerRuntime overrides the container runtime used to start
+	// Postgres. By default, docker is used if it is installed, and podman
+	// otherwise.
+	EnvContainerRuntime = "EXTENSIONDB_TEST_CONTAINER_RUNTIME"
+
+	// EnvPostgresImage overrides the Postgres image.
+	EnvPostgresImage = "EXTENSIONDB_TEST_POSTGRES_IMAGE"
+
+	defaultPostgresImage = "docker.io/library/postgres:15"
+)
+
+var errUnavailable = errors.New("no Postgres server available")
+
+var server struct {
+	once sync.Once
+	url  string
+	err  error
+
+	runtime     string
+	containerID string
+
+	// run is the attached run command of the container, and tether is its
+	// standard input, which stops the container when closed.
+	run    *exec.Cmd
+	exited chan struct{}
+	tether io.WriteCloser
+}
+
+// tetherScript is the command of the Postgres container. It starts the
+// image's entrypoint and stops Postgres once standard input closes, so that
+// the container stops, and is removed, with the process that started it.
+const tetherScript = `docker-entrypoint.sh postgres & pid=$!; cat >/dev/null; kill -INT $pid; wait $pid`
+
+// stopTimeout is how long Main waits for the container to stop after
+// closing its standard input before removing it forcibly.
+const stopTimeout = 30 * time.Second
+
+// Main runs the tests and then stops the Postgres container started for
+// them, if any. It returns the exit code of the tests.
+func Main(m *testing.M) int {
+	code := m.Run()
+	if server.run != nil {
+		server.tether.Close()
+		select {
+		case <-server.exited:
+		case <-time.After(stopTimeout):
+			if out, err := exec.Command(server.runtime, "rm", "-f", server.containerID).CombinedOutput(); err != nil {
+				fmt.Fprintf(os.Stderr, "error removing postgres container %s: %v: %s\n", server.containerID, err, out)
+			}
+		}
+	}
+	return code
+}
+
+// New creates a database with every migration applied and returns a
+// connection to it. The database is dropped when the test finishes.
+func New(t testing.TB) *sql.DB {
+	t.Helper()
+
+	server.once.Do(func() {
+		server.url, server.err = startServer()
+	})
+	if errors.Is(server.err, errUnavailable) {
+		t.Skipf("skipping database test: %v; set %s or install docker or podman", server.err, EnvDatabaseURL)
+	}
+	if server.err != nil {
+		t.Fatalf("error starting postgres: %v", server.err)
+	}
+
+	admin, err := sql.Open("postgres", server.url)
+	if err != nil {
+		t.Fatalf("error connecting to postgres: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	suffix := make([]byte, 8)
+	_, _ = rand.Read(suffix)
+	name := "extensiondb_test_" + hex.EncodeToString(suffix)
+	if _, err := admin.Exec(`CREATE DATABASE ` + pq.QuoteIdentifier(name)); err != nil {
+		t.Fatalf("error creating database: %v", err)
+	}
+
+	u, err := url.Parse(server.url)
+	if err != nil {
+		t.Fatalf("invalid postgres URL: %v", err)
+	}
+	u.Path = "/" + name
+	conn, err := sql.Open("postgres", u.String())
+	if err != nil {
+		t.Fatalf("error connecting to database: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		if _, err := admin.Exec(`DROP DATABASE ` + pq.QuoteIdentifier(name) + ` WITH (FORCE)`); err != nil {
+			t.Errorf("error dropping database %s: %v", name, err)
+		}
+	})
+
+	if err := (&db.DB{DB: conn}).RunMigrationsFS(migrations.FS); err != nil {
+		t.Fatalf("error migrating database: %v", err)
+	}
+	return conn
+}
+
+func startServer() (_ string, err error) {
+	if u := os.Getenv(EnvDatabaseURL); u != "" {
+		return u, nil
+	}
+
+	runtime := os.Getenv(EnvContainerRuntime)
+	if runtime == "" {
+		for _, candidate := range []string{"docker", "podman"} {
+			if _, err := exec.LookPath(candidate); err == nil {
+				runtime = candidate
+				break
+			}
+		}
+		if runtime == "" {
+			return "", errUnavailable
+		}
+		// A runtime that is merely installed may not work, such as docker
+		// without a running daemon, so Postgres is unavailable rather than
+		// broken if it can't be started with it.
+		defer func() {
+			if err != nil {
+				err = fmt.Errorf("%w: %s can't start it: %v", errUnavailable, runtime, err)
+			}
+		}()
+	}
+	image := os.Getenv(EnvPostgresImage)
+	if image == "" {
+		image = defaultPostgresImage
+	}
+
+	containerID, err := startContainer(runtime, image)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// The ID is written when the container is created, and its port is
+	// published once it starts.
+	var hostPort string
+	err = poll(ctx, func() error {
+		out, err := exec.Command(runtime, "port", containerID, "5432/tcp").Output()
+		if err != nil {
+			return commandError(err)
+		}
+		hostPort, _, _ = strings.Cut(strings.TrimSpace(string(out)), "\n")
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("error getting postgres port: %w", err)
+	}
+	u := fmt.Sprintf("postgres://postgres:postgres@%s/postgres?sslmode=disable", hostPort)
+
+	// The image's entrypoint initializes the database with a server that
+	// doesn't listen on TCP, so the first successful ping is from the final
+	// server.
+	conn, err := sql.Open("postgres", u)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if err := poll(ctx, func() error { return conn.PingContext(ctx) }); err != nil {
+		return "", fmt.Errorf("postgres did not become ready: %w", err)
+	}
+	return u, nil
+}
+
+// poll calls fn until it succeeds, returning its last error if ctx is done
+// or the container exits first.
+func poll(ctx context.Context, fn func() error) error {
+	for {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-server.exited:
+			return fmt.Errorf("the container exited: %w", err)
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+}
+
+// startContainer starts a Postgres container with an attached run command
+// of runtime, whose standard input tethers the container to this process,
+// and returns the container's ID once it is created.
+func startContainer(runtime, image string) (string, error) {
+	dir, err := os.MkdirTemp("", "extensiondb-dbtest-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	cidFile := filepath.Join(dir, "cid")
+
+	var stderr bytes.Buffer
+	run := exec.Command(runtime, "run", "-i", "--rm",
+		"--cidfile", cidFile,
+		"-e", "POSTGRES_PASSWORD=postgres",
+		"-p", "127.0.0.1::5432",
+		"--entrypoint", "sh",
+		image, "-c", tetherScript,
+	)
+	run.Stderr = &stderr
+	tether, err := run.StdinPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := run.Start(); err != nil {
+		return "", fmt.Errorf("error starting %s with %s: %w", image, runtime, err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		_ = run.Wait()
+		close(exited)
+	}()
+	server.runtime, server.run, server.exited, server.tether = runtime, run, exited, tether
+
+	// Images are pulled before the container is created, so the ID is
+	// waited for as long as it takes the container to exit.
+	for {
+		if id, err := os.ReadFile(cidFile); err == nil && len(bytes.TrimSpace(id)) > 0 {
+			server.containerID = string(bytes.TrimSpace(id))
+			return server.containerID, nil
+		}
+		select {
+		case <-exited:
+			return "", fmt.Errorf("error starting %s with %s: %s", image, runtime, strings.TrimSpace(stderr.String()))
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func commandError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return err
+}
@@ -0,0 +1,77 @@
+package graph
+
+import (
+	"slices"
+	"time"
+
+	"github.com/joelanford/extensiondb/pkg/util"
+)
+
+// CincinnatiGraph is the graph document served by the Cincinnati update protocol.
+// Edges are pairs of indexes into Nodes, from the source node to the target node.
+type CincinnatiGraph struct {
+	Nodes []CincinnatiNode `json:"nodes"`
+	Edges [][2]int         `json:"edges"`
+}
+
+type CincinnatiNode struct {
+	Version  string            `json:"version"`
+	Payload  string            `json:"payload"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+const (
+	MetadataKeyChannels       = "io.openshift.upgrades.graph.release.channels"
+	MetadataKeyPackage        = "io.operatorframework.extensiondb.package"
+	MetadataKeyLifecyclePhase = "io.operatorframework.extensiondb.lifecycle-phase"
+
+	// MetadataKeyLifecyclePhaseEnd is the date, as YYYY-MM-DD, on which the
+	// node's next lifecycle phase begins. Nodes that are End of Life don't
+	// have it.
+	MetadataKeyLifecyclePhaseEnd = "io.operatorframework.extensiondb.lifecycle-phase-end"
+)
+
+// Cincinnati returns the Cincinnati representation of the nodes matching the
+// predicate and all edges between them. Nodes are ordered by version so that
+// repeated calls produce identical documents.
+func (g *Graph) Cincinnati(match NodePredicate, channel string) CincinnatiGraph {
+	nodes := slices.SortedFunc(g.NodesMatching(match), util.Compare)
+
+	indexes := make(map[*Node]int, len(nodes))
+	cg := CincinnatiGraph{
+		Nodes: make([]CincinnatiNode, 0, len(nodes)),
+		Edges: [][2]int{},
+	}
+	for i, n := range nodes {
+		indexes[n] = i
+
+		payload := ""
+		if n.ImageReference != nil {
+			payload = n.ImageReference.String()
+		}
+		cn := CincinnatiNode{
+			Version: n.VR(),
+			Payload: payload,
+			Metadata: map[string]string{
+				MetadataKeyPackage:        n.Name,
+				MetadataKeyLifecyclePhase: n.LifecyclePhase.String(),
+			},
+		}
+		if end := n.PhaseBoundaries.End; end != nil {
+			cn.Metadata[MetadataKeyLifecyclePhaseEnd] = end.Time().Format(time.DateOnly)
+		}
+		if channel != "" {
+			cn.Metadata[MetadataKeyChannels] = channel
+		}
+		cg.Nodes = append(cg.Nodes, cn)
+	}
+
+	for i, from := range nodes {
+		for _, to := range slices.SortedFunc(g.From(from), util.Compare) {
+			if j, ok := indexes[to]; ok {
+				cg.Edges = append(cg.Edges, [2]int{i, j})
+			}
+		}
+	}
+	return cg
+}
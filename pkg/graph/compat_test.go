@@ -0,0 +1,155 @@
+package graph_test
+
+import (
+	"context"
+	"io"
+	"iter"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// The declarations below pin the signatures of the v1 API. Programs built on
+// the package stop compiling if any of them change, so a change that breaks
+// one of them needs a new major version of the package instead.
+var (
+	_ func(context.Context, graph.GraphConfig) (*graph.Graph, error) = graph.NewGraph
+	_ func(context.Context, io.Reader) (*graph.Graph, error)         = graph.DecodeGraph
+	_ func(from, to *graph.Graph) graph.GraphDiff                    = graph.Diff
+
+	_ func(semver.Version, *string) graph.VersionRelease         = graph.NewVersionRelease
+	_ func(a, b string) int                                      = graph.CompareReleases
+	_ func(semver.Version) graph.MajorMinor                      = graph.NewMajorMinorFromVersion
+	_ func(string) (graph.MajorMinor, error)                     = graph.NewMajorMinorFromString
+	_ func(string) (graph.Catalog, error)                        = graph.ParseCatalog
+	_ func(string) (graph.NodeKey, error)                        = graph.ParseNodeKey
+	_ func([]*graph.Node) []graph.VersionStream                  = graph.InferVersionStreams
+	_ func(int) graph.LifecyclePhase                             = graph.LifecycleExtensionPhase
+	_ func(year int, month time.Month, day int) graph.Date       = graph.NewDate
+	_ func() graph.NodePredicate                                 = graph.AllNodes
+	_ func(...graph.NodePredicate) graph.NodePredicate           = graph.AndNodes
+	_ func(...graph.NodePredicate) graph.NodePredicate           = graph.OrNodes
+	_ func(string) graph.NodePredicate                           = graph.PackageNodes
+	_ func(string) (graph.NodePredicate, error)                  = graph.ChannelNodes
+	_ func(name, tag string) graph.NodePredicate                 = graph.InCatalog
+	_ func(semver.Range) graph.NodePredicate                     = graph.NodeInRange
+	_ func(graph.MajorMinor) graph.NodePredicate                 = graph.NodesUpToMajorMinor
+	_ func() graph.NodePredicate                                 = graph.VerifiedNodes
+	_ func() graph.NodePredicate                                 = graph.CertifiedNodes
+	_ func(from, to time.Time) graph.NodePredicate               = graph.BuiltBetween
+	_ func(time.Duration) graph.NodePredicate                    = graph.OlderThan
+	_ func() graph.EdgePredicate                                 = graph.AllEdges
+	_ func(...graph.EdgePredicate) graph.EdgePredicate           = graph.AndEdges
+	_ func() graph.EdgePredicate                                 = graph.ForbidEOLTargets
+	_ func() graph.EdgePredicate                                 = graph.ForbidCrossMinorIntoEOL
+	_ func() graph.Ranking                                       = graph.PhaseFirst
+	_ func() graph.Ranking                                       = graph.NewestFirst
+	_ func(graph.NodePredicate) graph.PlanOption                 = graph.PreferNodes
+	_ func() graph.PlanOption                                    = graph.PreferEUS
+	_ func(graph.NodePredicate) graph.PlanOption                 = graph.ExcludeNodes
+	_ func(graph.EdgePredicate) graph.PlanOption                 = graph.KeepEdges
+	_ func(*graph.Graph, *graph.Node, *graph.Node, float64) bool = graph.EdgePredicate(nil)
+	_ func(*graph.Graph, *graph.Node) bool                       = graph.NodePredicate(nil)
+
+	_ func(*graph.Graph) time.Time                                                   = (*graph.Graph).AsOf
+	_ func(*graph.Graph, io.Writer) error                                            = (*graph.Graph).Encode
+	_ func(*graph.Graph, graph.NodePredicate, string) graph.CincinnatiGraph          = (*graph.Graph).Cincinnati
+	_ func(*graph.Graph, *graph.Node, *graph.Node) float64                           = (*graph.Graph).EdgeWeight
+	_ func(*graph.Graph) iter.Seq[graph.WeightedEdge]                                = (*graph.Graph).Edges
+	_ func(*graph.Graph, graph.EdgePredicate) iter.Seq[graph.WeightedEdge]           = (*graph.Graph).EdgesMatching
+	_ func(*graph.Graph, graph.NodePredicate) iter.Seq[*graph.Node]                  = (*graph.Graph).NodesMatching
+	_ func(*graph.Graph, graph.NodePredicate) *graph.Node                            = (*graph.Graph).FirstNodeMatching
+	_ func(*graph.Graph, *graph.Node) iter.Seq[*graph.Node]                          = (*graph.Graph).From
+	_ func(*graph.Graph, *graph.Node) iter.Seq[*graph.Node]                          = (*graph.Graph).To
+	_ func(*graph.Graph) sets.Set[*graph.Node]                                       = (*graph.Graph).Heads
+	_ func(*graph.Graph, string) sets.Set[*graph.Node]                               = (*graph.Graph).HeadsFor
+	_ func(*graph.Graph) sets.Set[*graph.Node]                                       = (*graph.Graph).Roots
+	_ func(*graph.Graph, string) sets.Set[*graph.Node]                               = (*graph.Graph).RootsFor
+	_ func(*graph.Graph, digest.Digest) *graph.Node                                  = (*graph.Graph).NodeForDigest
+	_ func(*graph.Graph, graph.NodeKey) *graph.Node                                  = (*graph.Graph).NodeForKey
+	_ func(*graph.Graph, *graph.Node, *graph.Node) ([]*graph.Node, float64, bool)    = (*graph.Graph).ShortestPath
+	_ func(*graph.Graph) []graph.SkippedNode                                         = (*graph.Graph).SkippedNodes
+	_ func(*graph.GraphConfig) error                                                 = (*graph.GraphConfig).Validate
+	_ func(*graph.Template) error                                                    = (*graph.Template).Validate
+	_ func(*graph.Node) graph.NodeKey                                                = (*graph.Node).Key
+	_ func(*graph.Node) graph.VersionRelease                                         = (*graph.Node).VersionRelease
+	_ func(*graph.Node) string                                                       = (*graph.Node).NVR
+	_ func(*graph.Node, *graph.Node) int                                             = (*graph.Node).Compare
+	_ func(*graph.PlatformUpdate) string                                             = (*graph.PlatformUpdate).PrettyReport
+	_ func(graph.LifecycleDates, time.Time) graph.LifecyclePhase                     = graph.LifecycleDates.Phase
+	_ func(graph.LifecycleDates, time.Time) (graph.LifecyclePhase, graph.Date, bool) = graph.LifecycleDates.NextPhase
+
+	_ graph.WeightStrategy = graph.WeightStrategyFunc(nil)
+	_ graph.WeightStrategy = graph.SemverDistance{}
+	_ graph.WeightStrategy = graph.Recency{}
+	_ graph.Ranking        = graph.RankingFunc(nil)
+	_ graph.Ranking        = graph.Weighted{}
+)
+
+// v1Graph is a graph encoded by the first release of the v1 API. Graphs are
+// cached and exchanged in this form, so later releases must decode it.
+const v1Graph = `{
+  "asOf": "2030-01-01T00:00:00Z",
+  "pathScope": 0,
+  "nodes": [
+    {
+      "key": "foo.v1.0.0",
+      "name": "foo",
+      "version": "1.0.0",
+      "releaseDate": "2020-01-01T01:00:00Z",
+      "lifecyclePhase": 1,
+      "lifecycleDates": {"fullSupport": "2020-01-01", "maintenance": "2021-01-01", "eol": "2035-01-01"},
+      "phaseBoundaries": {"start": "2021-01-01", "end": "2035-01-01", "daysRemaining": 1826}
+    },
+    {
+      "key": "foo.v1.1.0",
+      "name": "foo",
+      "version": "1.1.0",
+      "releaseDate": "2020-01-01T02:00:00Z",
+      "verified": true,
+      "lifecyclePhase": 0,
+      "supportedPlatformVersions": ["4.18"],
+      "catalogs": [{"name": "registry.example.com/index", "tag": "v4.18"}]
+    }
+  ],
+  "edges": [{"from": 0, "to": 1, "weight": 0.01}]
+}`
+
+func TestDecodeGraph_v1(t *testing.T) {
+	g, err := graph.DecodeGraph(t.Context(), strings.NewReader(v1Graph))
+	require.NoError(t, err)
+
+	assert.Equal(t, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), g.AsOf().UTC())
+	from := g.NodeForKey(graph.NodeKey{Package: "foo", Version: "1.0.0"})
+	to := g.NodeForKey(graph.NodeKey{Package: "foo", Version: "1.1.0"})
+	require.NotNil(t, from)
+	require.NotNil(t, to)
+
+	assert.Equal(t, graph.LifecyclePhaseMaintenance, from.LifecyclePhase)
+	assert.Equal(t, graph.NewDate(2035, 1, 1), from.LifecycleDates.EndOfLife)
+	assert.True(t, to.Verified)
+	assert.True(t, to.SupportedPlatformVersions.Has(graph.MajorMinor{Major: 4, Minor: 18}))
+	assert.True(t, to.Catalogs.Has(graph.Catalog{Name: "registry.example.com/index", Tag: "v4.18"}))
+
+	assert.Equal(t, 0.01, g.EdgeWeight(from, to))
+	path, _, ok := g.ShortestPath(from, to)
+	require.True(t, ok)
+	assert.Equal(t, []string{"foo.v1.0.0", "foo.v1.1.0"}, nvrs(path))
+	assert.Equal(t, []string{"foo.v1.1.0"}, nvrs(g.HeadsFor("foo").UnsortedList()))
+}
+
+func TestCincinnatiMetadataKeys_v1(t *testing.T) {
+	// Cincinnati graphs are read by clients outside of Go, which only see the
+	// metadata keys' values.
+	assert.Equal(t, "io.openshift.upgrades.graph.release.channels", graph.MetadataKeyChannels)
+	assert.Equal(t, "io.operatorframework.extensiondb.package", graph.MetadataKeyPackage)
+	assert.Equal(t, "io.operatorframework.extensiondb.lifecycle-phase", graph.MetadataKeyLifecyclePhase)
+	assert.Equal(t, "io.operatorframework.extensiondb.lifecycle-phase-end", graph.MetadataKeyLifecyclePhaseEnd)
+}
@@ -0,0 +1,87 @@
+package graph
+
+import (
+	"cmp"
+	"slices"
+)
+
+// EdgeKey identifies an edge by the keys of the nodes it is from and to.
+type EdgeKey struct {
+	From NodeKey `json:"from"`
+	To   NodeKey `json:"to"`
+}
+
+func (k EdgeKey) Compare(other EdgeKey) int {
+	return cmp.Or(k.From.Compare(other.From), k.To.Compare(other.To))
+}
+
+// ReweightedEdge is an edge whose weight differs between two graphs.
+type ReweightedEdge struct {
+	EdgeKey
+	FromWeight float64 `json:"fromWeight"`
+	ToWeight   float64 `json:"toWeight"`
+}
+
+// GraphDiff is the difference between two graphs, whose nodes are matched by
+// their keys, so that graphs built or decoded by different processes can be
+// compared. Each list is ordered by key.
+type GraphDiff struct {
+	AddedNodes      []NodeKey        `json:"addedNodes,omitempty"`
+	RemovedNodes    []NodeKey        `json:"removedNodes,omitempty"`
+	AddedEdges      []EdgeKey        `json:"addedEdges,omitempty"`
+	RemovedEdges    []EdgeKey        `json:"removedEdges,omitempty"`
+	ReweightedEdges []ReweightedEdge `json:"reweightedEdges,omitempty"`
+}
+
+// Empty reports whether the graphs have the same nodes and edges, with the
+// same weights.
+func (d GraphDiff) Empty() bool {
+	return len(d.AddedNodes) == 0 && len(d.RemovedNodes) == 0 && len(d.AddedEdges) == 0 && len(d.RemovedEdges) == 0 && len(d.ReweightedEdges) == 0
+}
+
+// Diff returns the nodes and edges that were added to, removed from, or
+// reweighted in the graph to since the graph from.
+func Diff(from, to *Graph) GraphDiff {
+	var d GraphDiff
+	for k := range to.byKey {
+		if from.byKey[k] == nil {
+			d.AddedNodes = append(d.AddedNodes, k)
+		}
+	}
+	for k := range from.byKey {
+		if to.byKey[k] == nil {
+			d.RemovedNodes = append(d.RemovedNodes, k)
+		}
+	}
+
+	fromEdges, toEdges := edgesByKey(from), edgesByKey(to)
+	for k, w := range toEdges {
+		fromWeight, ok := fromEdges[k]
+		switch {
+		case !ok:
+			d.AddedEdges = append(d.AddedEdges, k)
+		case fromWeight != w:
+			d.ReweightedEdges = append(d.ReweightedEdges, ReweightedEdge{EdgeKey: k, FromWeight: fromWeight, ToWeight: w})
+		}
+	}
+	for k := range fromEdges {
+		if _, ok := toEdges[k]; !ok {
+			d.RemovedEdges = append(d.RemovedEdges, k)
+		}
+	}
+
+	slices.SortFunc(d.AddedNodes, NodeKey.Compare)
+	slices.SortFunc(d.RemovedNodes, NodeKey.Compare)
+	slices.SortFunc(d.AddedEdges, EdgeKey.Compare)
+	slices.SortFunc(d.RemovedEdges, EdgeKey.Compare)
+	slices.SortFunc(d.ReweightedEdges, func(a, b ReweightedEdge) int { return a.EdgeKey.Compare(b.EdgeKey) })
+	return d
+}
+
+func edgesByKey(g *Graph) map[EdgeKey]float64 {
+	edges := map[EdgeKey]float64{}
+	for e := range g.Edges() {
+		edges[EdgeKey{From: e.From.Key(), To: e.To.Key()}] = e.Weight
+	}
+	return edges
+}
@@ -0,0 +1,208 @@
+package graph
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/opencontainers/go-digest"
+	"gonum.org/v1/gonum/graph/simple"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// encodedGraph is the form in which Graph.Encode writes a graph. Edges refer
+// to nodes by their index in Nodes, and nodes are written with their keys, so
+// that the nodes of graphs encoded by different processes can be correlated.
+type encodedGraph struct {
+	AsOf      time.Time            `json:"asOf"`
+	PathScope PathScope            `json:"pathScope"`
+	Nodes     []encodedNode        `json:"nodes"`
+	Edges     []encodedEdge        `json:"edges"`
+	Skipped   []encodedSkippedNode `json:"skipped,omitempty"`
+}
+
+type encodedNode struct {
+	Key                            NodeKey             `json:"key"`
+	Name                           string              `json:"name"`
+	Version                        semver.Version      `json:"version"`
+	Release                        *string             `json:"release,omitempty"`
+	ReleaseDate                    time.Time           `json:"releaseDate"`
+	ImageReference                 *CanonicalReference `json:"imageReference,omitempty"`
+	Verified                       bool                `json:"verified,omitempty"`
+	Certified                      bool                `json:"certified,omitempty"`
+	LifecyclePhase                 LifecyclePhase      `json:"lifecyclePhase"`
+	LifecycleDates                 *LifecycleDates     `json:"lifecycleDates,omitempty"`
+	PhaseBoundaries                *PhaseBoundaries    `json:"phaseBoundaries,omitempty"`
+	SupportedPlatformVersions      []MajorMinor        `json:"supportedPlatformVersions,omitempty"`
+	RequiresUpdatePlatformVersions []MajorMinor        `json:"requiresUpdatePlatformVersions,omitempty"`
+	Catalogs                       []Catalog           `json:"catalogs,omitempty"`
+	Rebuilds                       []encodedNode       `json:"rebuilds,omitempty"`
+	ContentFingerprint             digest.Digest       `json:"contentFingerprint,omitempty"`
+}
+
+type encodedEdge struct {
+	From   int     `json:"from"`
+	To     int     `json:"to"`
+	Weight float64 `json:"weight"`
+}
+
+type encodedSkippedNode struct {
+	Node  encodedNode `json:"node"`
+	Error string      `json:"error"`
+}
+
+// Encode writes the graph's nodes, edges, and edge weights to w as JSON, so
+// that DecodeGraph can restore the graph without building its edges again.
+func (g *Graph) Encode(w io.Writer) error {
+	nodes := slices.Collect(g.NodesMatching(AllNodes()))
+	index := make(map[int64]int, len(nodes))
+	eg := encodedGraph{
+		AsOf:      g.asOf,
+		PathScope: g.pathScope,
+		Nodes:     make([]encodedNode, 0, len(nodes)),
+	}
+	for i, n := range nodes {
+		index[n.ID()] = i
+		eg.Nodes = append(eg.Nodes, encodeNode(n))
+	}
+	for e := range g.Edges() {
+		eg.Edges = append(eg.Edges, encodedEdge{From: index[e.From.ID()], To: index[e.To.ID()], Weight: e.Weight})
+	}
+	for _, s := range g.skipped {
+		eg.Skipped = append(eg.Skipped, encodedSkippedNode{Node: encodeNode(s.Node), Error: s.Err.Error()})
+	}
+	return json.NewEncoder(w).Encode(eg)
+}
+
+// DecodeGraph restores a graph written by Graph.Encode. Its nodes keep the
+// lifecycle phases they had when the graph was built, and its shortest paths
+// are computed again within the scope the graph was built with. It stops with
+// ctx's error once ctx is done.
+func DecodeGraph(ctx context.Context, r io.Reader) (*Graph, error) {
+	var eg encodedGraph
+	if err := json.NewDecoder(r).Decode(&eg); err != nil {
+		return nil, fmt.Errorf("error decoding graph: %w", err)
+	}
+
+	wg := simple.NewWeightedDirectedGraph(0, math.Inf(1))
+	nodes := make([]*Node, 0, len(eg.Nodes))
+	var (
+		packages     []Package
+		packageNames = sets.New[string]()
+	)
+	for _, en := range eg.Nodes {
+		n := en.node()
+		if wg.Node(n.ID()) != nil {
+			return nil, fmt.Errorf("error decoding graph: duplicate node %s", n.NVR())
+		}
+		wg.AddNode(n)
+		nodes = append(nodes, n)
+		if !packageNames.Has(n.Name) {
+			packageNames.Insert(n.Name)
+			packages = append(packages, Package{Name: n.Name})
+		}
+	}
+	for _, e := range eg.Edges {
+		if e.From < 0 || e.From >= len(nodes) || e.To < 0 || e.To >= len(nodes) || e.From == e.To {
+			return nil, fmt.Errorf("error decoding graph: invalid edge from node %d to node %d", e.From, e.To)
+		}
+		wg.SetWeightedEdge(wg.NewWeightedEdge(nodes[e.From], nodes[e.To], e.Weight))
+	}
+
+	g := &Graph{wg: *wg, asOf: eg.AsOf, pathScope: eg.PathScope}
+	for _, s := range eg.Skipped {
+		g.skipped = append(g.skipped, SkippedNode{Node: s.Node.node(), Err: errors.New(s.Error)})
+	}
+	paths, err := g.computePaths(ctx, GraphConfig{Packages: packages, PathScope: eg.PathScope})
+	if err != nil {
+		return nil, err
+	}
+	g.paths = paths
+	g.findHeads()
+	g.findRoots()
+	g.indexDigests()
+	g.indexKeys()
+	return g, nil
+}
+
+func encodeNode(n *Node) encodedNode {
+	en := encodedNode{
+		Key:                            n.Key(),
+		Name:                           n.Name,
+		Version:                        n.Version,
+		Release:                        n.Release,
+		ReleaseDate:                    n.ReleaseDate,
+		Verified:                       n.Verified,
+		Certified:                      n.Certified,
+		LifecyclePhase:                 n.LifecyclePhase,
+		SupportedPlatformVersions:      sortedMajorMinors(n.SupportedPlatformVersions),
+		RequiresUpdatePlatformVersions: sortedMajorMinors(n.RequiresUpdatePlatformVersions),
+		ContentFingerprint:             n.ContentFingerprint,
+	}
+	if !n.LifecycleDates.FullSupport.t.IsZero() {
+		en.LifecycleDates = &n.LifecycleDates
+		en.PhaseBoundaries = &n.PhaseBoundaries
+	}
+	if n.ImageReference != nil {
+		en.ImageReference = &CanonicalReference{Canonical: n.ImageReference}
+	}
+	if n.Catalogs != nil {
+		en.Catalogs = slices.SortedFunc(maps.Keys(n.Catalogs), func(a, b Catalog) int {
+			return cmp.Or(cmp.Compare(a.Name, b.Name), cmp.Compare(a.Tag, b.Tag))
+		})
+	}
+	for _, r := range n.Rebuilds {
+		en.Rebuilds = append(en.Rebuilds, encodeNode(r))
+	}
+	return en
+}
+
+func sortedMajorMinors(s sets.Set[MajorMinor]) []MajorMinor {
+	if s == nil {
+		return nil
+	}
+	return slices.SortedFunc(maps.Keys(s), MajorMinor.Compare)
+}
+
+func (en encodedNode) node() *Node {
+	n := &Node{
+		Name:               en.Name,
+		Version:            en.Version,
+		Release:            en.Release,
+		ReleaseDate:        en.ReleaseDate,
+		Verified:           en.Verified,
+		Certified:          en.Certified,
+		LifecyclePhase:     en.LifecyclePhase,
+		ContentFingerprint: en.ContentFingerprint,
+	}
+	if en.LifecycleDates != nil {
+		n.LifecycleDates = *en.LifecycleDates
+	}
+	if en.PhaseBoundaries != nil {
+		n.PhaseBoundaries = *en.PhaseBoundaries
+	}
+	if en.ImageReference != nil {
+		n.ImageReference = en.ImageReference.Canonical
+	}
+	if en.SupportedPlatformVersions != nil {
+		n.SupportedPlatformVersions = sets.New(en.SupportedPlatformVersions...)
+	}
+	if en.RequiresUpdatePlatformVersions != nil {
+		n.RequiresUpdatePlatformVersions = sets.New(en.RequiresUpdatePlatformVersions...)
+	}
+	if en.Catalogs != nil {
+		n.Catalogs = sets.New(en.Catalogs...)
+	}
+	for _, r := range en.Rebuilds {
+		n.Rebuilds = append(n.Rebuilds, r.node())
+	}
+	return n
+}
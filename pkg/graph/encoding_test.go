@@ -0,0 +1,108 @@
+package graph_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.podman.io/image/v5/docker/reference"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// nodesByNVR returns the nodes of a graph by their NVR, so that the nodes of
+// a decoded graph can be compared with those of the graph it was encoded from.
+func nodesByNVR(g *graph.Graph) map[string]*graph.Node {
+	nodes := map[string]*graph.Node{}
+	for n := range g.NodesMatching(graph.AllNodes()) {
+		nodes[n.NVR()] = n
+	}
+	return nodes
+}
+
+func TestEncodeDecodeGraph(t *testing.T) {
+	phases := []graph.LifecyclePhase{graph.LifecyclePhaseEndOfLife, graph.LifecyclePhaseMaintenance, graph.LifecyclePhaseFullSupport}
+	pkg := phasedPackage(phases, 3)
+	for i := range pkg.Streams {
+		pkg.Streams[i].SupportedPlatformVersions = []graph.MajorMinor{{Major: 4, Minor: 14 + uint64(i)}, {Major: 4, Minor: 15 + uint64(i)}}
+		pkg.Streams[i].RequiresUpdatePlatformVersions = []graph.MajorMinor{{Major: 4, Minor: 15 + uint64(i)}}
+	}
+	ref, err := reference.ParseNamed("quay.io/example/foo-bundle@sha256:" + strings.Repeat("a", 64))
+	require.NoError(t, err)
+	release := "1"
+	pkg.Nodes[0].ImageReference = ref.(reference.Canonical)
+	pkg.Nodes[0].Release = &release
+	pkg.Nodes[0].Catalogs = sets.New(graph.Catalog{Name: "registry.example.com/index", Tag: "v1"}, graph.Catalog{Name: "localhost:5000/index", Tag: "v2"})
+	pkg.Nodes[1].Verified = true
+	pkg.Nodes[2].Certified = true
+	pkg.Nodes = append(pkg.Nodes, &graph.Node{Name: "foo", Version: semver.MustParse("9.0.0"), ReleaseDate: edgeWeightsAsOf, ImageReference: ref.(reference.Canonical)})
+
+	want, err := graph.NewGraph(t.Context(), graph.GraphConfig{
+		Packages:         []graph.Package{pkg, catalogPackages(1, 2, 3)[0]},
+		AsOf:             edgeWeightsAsOf,
+		SkipInvalidNodes: true,
+		PathScope:        graph.PathScopeComponent,
+	})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, want.Encode(&buf))
+	got, err := graph.DecodeGraph(t.Context(), &buf)
+	require.NoError(t, err)
+
+	assert.Equal(t, want.AsOf(), got.AsOf())
+	wantNodes, gotNodes := nodesByNVR(want), nodesByNVR(got)
+	require.Len(t, gotNodes, len(wantNodes))
+	for nvr, w := range wantNodes {
+		g := gotNodes[nvr]
+		require.NotNil(t, g, nvr)
+		assert.Equal(t, w.ReleaseDate.UTC(), g.ReleaseDate.UTC(), nvr)
+		assert.Equal(t, w.Verified, g.Verified, nvr)
+		assert.Equal(t, w.Certified, g.Certified, nvr)
+		assert.Equal(t, w.Catalogs, g.Catalogs, nvr)
+		assert.Equal(t, w.LifecyclePhase, g.LifecyclePhase, nvr)
+		assert.Equal(t, w.LifecycleDates, g.LifecycleDates, nvr)
+		assert.Equal(t, w.PhaseBoundaries, g.PhaseBoundaries, nvr)
+		assert.ElementsMatch(t, w.SupportedPlatformVersions.UnsortedList(), g.SupportedPlatformVersions.UnsortedList(), nvr)
+		assert.ElementsMatch(t, w.RequiresUpdatePlatformVersions.UnsortedList(), g.RequiresUpdatePlatformVersions.UnsortedList(), nvr)
+		if w.ImageReference != nil {
+			assert.Equal(t, w.ImageReference.String(), g.ImageReference.String(), nvr)
+		}
+
+		for wantTo := range wantNodes {
+			assert.Equal(t, want.EdgeWeight(w, wantNodes[wantTo]), got.EdgeWeight(g, gotNodes[wantTo]), "%s -> %s", nvr, wantTo)
+			wantPath, wantWeight, wantOK := want.ShortestPath(w, wantNodes[wantTo])
+			gotPath, gotWeight, gotOK := got.ShortestPath(g, gotNodes[wantTo])
+			assert.Equal(t, nvrs(wantPath), nvrs(gotPath), "%s -> %s", nvr, wantTo)
+			assert.Equal(t, wantWeight, gotWeight, "%s -> %s", nvr, wantTo)
+			assert.Equal(t, wantOK, gotOK, "%s -> %s", nvr, wantTo)
+		}
+	}
+	assert.ElementsMatch(t, nvrs(want.Heads().UnsortedList()), nvrs(got.Heads().UnsortedList()))
+	assert.ElementsMatch(t, nvrs(want.Roots().UnsortedList()), nvrs(got.Roots().UnsortedList()))
+
+	require.Len(t, got.SkippedNodes(), 1)
+	assert.Equal(t, "foo.v9.0.0", got.SkippedNodes()[0].Node.NVR())
+	assert.EqualError(t, got.SkippedNodes()[0].Err, want.SkippedNodes()[0].Err.Error())
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	require.NoError(t, want.Encode(&buf))
+	_, err = graph.DecodeGraph(ctx, &buf)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = graph.DecodeGraph(t.Context(), strings.NewReader(`{"nodes": [], "edges": [{"from": 0, "to": 1}]}`))
+	assert.ErrorContains(t, err, "invalid edge")
+}
+
+func nvrs(nodes []*graph.Node) []string {
+	names := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		names = append(names, n.NVR())
+	}
+	return names
+}
@@ -0,0 +1,640 @@
+// Package graph builds update graphs of operator bundles from their versions,
+// lifecycle dates, and version streams, and plans updates through them.
+//
+// The package's API is v1. Later releases keep its exported identifiers, their
+// signatures, and the documents written by Graph.Encode and Graph.Cincinnati
+// compatible with this release: they may add identifiers, struct fields,
+// options, and optional fields of encoded documents, but they don't remove or
+// change existing ones. Changes that can't be made compatibly are made in a
+// new major version of the package at a new import path.
+//
+// The package was previously at
+// github.com/joelanford/extensiondb/examples/cincinnati/pkg/graph, which now
+// aliases it and is deprecated.
+package graph
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/pkg/util"
+	"github.com/opencontainers/go-digest"
+	"gonum.org/v1/gonum/graph"
+	"gonum.org/v1/gonum/graph/simple"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+type Graph struct {
+	wg simple.WeightedDirectedGraph
+
+	paths     pathFinder
+	pathScope PathScope
+	heads     map[string]sets.Set[*Node]
+	roots     map[string]sets.Set[*Node]
+	byDigest  map[digest.Digest]*Node
+	byKey     map[NodeKey]*Node
+	asOf      time.Time
+	skipped   []SkippedNode
+}
+
+// SkippedNode is a node that was left out of a graph built with
+// GraphConfig.SkipInvalidNodes, and the reason it was.
+type SkippedNode struct {
+	Node *Node
+	Err  error
+}
+
+type Package struct {
+	Name    string
+	Streams []VersionStream
+	Nodes   []*Node
+
+	// MajorVersionBridges are the updates across major versions that the
+	// package permits. Without them, updates never cross major versions.
+	MajorVersionBridges []MajorVersionBridge
+}
+
+type GraphConfig struct {
+	Packages     []Package
+	AsOf         time.Time
+	IncludePreGA bool
+
+	// SkipInvalidNodes builds the graph from the valid nodes alone, leaving
+	// out nodes that would otherwise fail the build, such as nodes whose
+	// major.minor is not in any of their package's streams. The nodes that
+	// were left out are reported by Graph.SkippedNodes.
+	SkipInvalidNodes bool
+
+	// PathScope is the scope within which shortest paths are computed. All
+	// scopes find the same paths, but smaller ones take less memory.
+	PathScope PathScope
+
+	// EdgeWeightDelta is the difference between the edge weights of
+	// successive nodes of a lifecycle phase. Nodes are ranked in units of it,
+	// so it scales every edge weight. If zero, DefaultEdgeWeightDelta is used.
+	EdgeWeightDelta float64
+
+	// CollapseRebuilds makes a single node of each version of a package
+	// that was built more than once: the newest build, which carries the
+	// others as its Rebuilds. Graph.NodeForDigest maps the digests of every
+	// build to that node.
+	CollapseRebuilds bool
+
+	// Prereleases is how nodes with prerelease versions, such as 1.2.3-rc.1,
+	// are placed in the graph. By default, they are updated to and from like
+	// any other node.
+	Prereleases PrereleasePolicy
+
+	// BuildMetadata is how nodes whose versions have build metadata, such as
+	// 1.2.3+build.5, are placed in the graph. By default, they are placed as
+	// builds of their version without it.
+	BuildMetadata BuildMetadataPolicy
+
+	// WeightStrategy, if set, adjusts the weight of each edge after edges
+	// have been ranked by lifecycle phase and version, such as SemverDistance
+	// or Recency.
+	WeightStrategy WeightStrategy
+
+	// Ranking orders each package's nodes from best to worst to weight the
+	// edges to them, such as NewestFirst() or Weighted. If nil, PhaseFirst()
+	// is used.
+	Ranking Ranking
+
+	// KeepEdge, if set, leaves out of the graph the edges that don't match
+	// it, such as ForbidEOLTargets(), once they have been weighted. Rules
+	// can be combined with AndEdges.
+	KeepEdge EdgePredicate
+}
+
+// DefaultEdgeWeightDelta is the edge weight delta used when
+// GraphConfig.EdgeWeightDelta is zero.
+const DefaultEdgeWeightDelta = 0.01
+
+// maxEdgeWeightRank is the largest rank, and sum of ranks, that is exactly
+// representable as a float64. Beyond it, the ranks of different nodes and
+// phases could collide.
+const maxEdgeWeightRank = 1 << 53
+
+// NewGraph builds the graph of the packages of cfg and computes its shortest
+// paths. Building the graph of a large catalog can take a while, so it stops
+// with ctx's error once ctx is done.
+func NewGraph(ctx context.Context, cfg GraphConfig) (*Graph, error) {
+	wg := simple.NewWeightedDirectedGraph(0, math.Inf(1))
+	for _, pkg := range cfg.Packages {
+		nodes := pkg.Nodes
+		if cfg.CollapseRebuilds {
+			nodes = collapseRebuilds(nodes)
+		} else {
+			for _, node := range nodes {
+				node.Rebuilds = nil
+			}
+		}
+		for _, node := range nodes {
+			wg.AddNode(node)
+		}
+	}
+
+	g := &Graph{wg: *wg, asOf: cfg.AsOf, pathScope: cfg.PathScope}
+	if err := g.buildEdges(ctx, cfg); err != nil {
+		return nil, err
+	}
+	paths, err := g.computePaths(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	g.paths = paths
+	g.findHeads()
+	g.findRoots()
+	g.indexDigests()
+	g.indexKeys()
+	return g, nil
+}
+
+// SkippedNodes returns the nodes that were left out of the graph because
+// they were invalid, in the order they were found. It is empty unless the
+// graph was built with GraphConfig.SkipInvalidNodes.
+func (g *Graph) SkippedNodes() []SkippedNode {
+	return g.skipped
+}
+
+// AsOf returns the time used to compute the lifecycle phases of the graph's nodes.
+func (g *Graph) AsOf() time.Time {
+	return g.asOf
+}
+
+// ShortestPath returns a lightest update path from one node to another,
+// starting with from and ending with to, and its weight. It reports false if
+// to can't be reached from from. The path from a node to itself is the node
+// alone.
+func (g *Graph) ShortestPath(from, to *Node) ([]*Node, float64, bool) {
+	p, w, _ := g.paths.Between(from.ID(), to.ID())
+	if len(p) == 0 {
+		return nil, math.Inf(1), false
+	}
+	return util.MapSlice(p, func(n graph.Node) *Node { return n.(*Node) }), w, true
+}
+
+// To yields the nodes with edges to a node, in the order of NodesMatching.
+func (g *Graph) To(to *Node) iter.Seq[*Node] {
+	return slices.Values(g.sortedNodes(g.wg.To(to.ID()), AllNodes()))
+}
+
+// From yields the nodes with edges from a node, in the order of
+// NodesMatching.
+func (g *Graph) From(from *Node) iter.Seq[*Node] {
+	return slices.Values(g.sortedNodes(g.wg.From(from.ID()), AllNodes()))
+}
+
+// ReachableFrom returns a predicate that matches the nodes that match from and
+// within, and the nodes that can be updated to from them through nodes that
+// match within.
+func (g *Graph) ReachableFrom(from, within NodePredicate) NodePredicate {
+	queue := slices.Collect(g.NodesMatching(AndNodes(from, within)))
+	reachable := sets.New(queue...)
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for to := range g.From(n) {
+			if !reachable.Has(to) && within(g, to) {
+				reachable.Insert(to)
+				queue = append(queue, to)
+			}
+		}
+	}
+	return func(_ *Graph, node *Node) bool {
+		return reachable.Has(node)
+	}
+}
+
+// WeightedEdge is an update edge from one node to another, and its weight.
+type WeightedEdge struct {
+	From, To *Node
+	Weight   float64
+}
+
+// EdgeWeight returns the weight of the edge from one node to another, or
+// +Inf if there is no such edge.
+func (g *Graph) EdgeWeight(from, to *Node) float64 {
+	w := g.wg.WeightedEdge(from.ID(), to.ID())
+	if w == nil {
+		return math.Inf(1)
+	}
+	return w.Weight()
+}
+
+// Edges yields every edge of the graph, ordered by the node it is from and
+// then by the node it is to, each in the order of NodesMatching.
+func (g *Graph) Edges() iter.Seq[WeightedEdge] {
+	return g.EdgesMatching(AllEdges())
+}
+
+// EdgesMatching yields the edges that match, in the order of Edges. The edges
+// are collected in a single pass over the graph, so it is cheaper than
+// looking up the weight of each edge from or to each node.
+func (g *Graph) EdgesMatching(match EdgePredicate) iter.Seq[WeightedEdge] {
+	return func(yield func(WeightedEdge) bool) {
+		var edges []WeightedEdge
+		it := g.wg.WeightedEdges()
+		for it.Next() {
+			we := it.WeightedEdge()
+			e := WeightedEdge{From: we.From().(*Node), To: we.To().(*Node), Weight: we.Weight()}
+			if match(g, e.From, e.To, e.Weight) {
+				edges = append(edges, e)
+			}
+		}
+		slices.SortFunc(edges, func(a, b WeightedEdge) int {
+			return cmp.Or(compareNodes(a.From, b.From), compareNodes(a.To, b.To))
+		})
+		for _, e := range edges {
+			if !yield(e) {
+				return
+			}
+		}
+	}
+}
+
+// FirstNodeMatching returns the first node that NodesMatching yields for
+// match, or nil if no node matches. When several releases of a version match,
+// it is the lowest release.
+func (g *Graph) FirstNodeMatching(match NodePredicate) *Node {
+	var first *Node
+	for n := range nodeIterator(g.wg.Nodes()) {
+		if match(g, n) && (first == nil || compareNodes(n, first) < 0) {
+			first = n
+		}
+	}
+	return first
+}
+
+// NodesMatching yields the nodes that match, ordered by name, version,
+// release, and release date, so that iterating a graph gives the same results
+// in every run.
+func (g *Graph) NodesMatching(match NodePredicate) iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		for _, n := range g.sortedNodes(g.wg.Nodes(), match) {
+			if !yield(n) {
+				return
+			}
+		}
+	}
+}
+
+// sortedNodes returns the nodes of it that match, ordered by compareNodes.
+func (g *Graph) sortedNodes(it graph.Nodes, match NodePredicate) []*Node {
+	var nodes []*Node
+	for n := range nodeIterator(it) {
+		if match(g, n) {
+			nodes = append(nodes, n)
+		}
+	}
+	slices.SortFunc(nodes, compareNodes)
+	return nodes
+}
+
+// Heads returns the heads of every package in the graph.
+func (g *Graph) Heads() sets.Set[*Node] {
+	heads := sets.New[*Node]()
+	for _, h := range g.heads {
+		heads = heads.Union(h)
+	}
+	return heads
+}
+
+// HeadsFor returns the heads of a package: its nodes that can't be updated to
+// any other node of the package. It is empty if the package isn't in the
+// graph.
+func (g *Graph) HeadsFor(pkgName string) sets.Set[*Node] {
+	if h, ok := g.heads[pkgName]; ok {
+		return h
+	}
+	return sets.New[*Node]()
+}
+
+func (g *Graph) findHeads() {
+	g.heads = map[string]sets.Set[*Node]{}
+	for n := range g.NodesMatching(isHead) {
+		if g.heads[n.Name] == nil {
+			g.heads[n.Name] = sets.New[*Node]()
+		}
+		g.heads[n.Name].Insert(n)
+	}
+}
+
+// Roots returns the roots of every package in the graph.
+func (g *Graph) Roots() sets.Set[*Node] {
+	roots := sets.New[*Node]()
+	for _, r := range g.roots {
+		roots = roots.Union(r)
+	}
+	return roots
+}
+
+// RootsFor returns the roots of a package: its nodes that no other node of the
+// package can be updated to. The lowest version of each major version is
+// usually a root, as an entry point to the package's graph; other roots are
+// usually versions that the template leaves unreachable by mistake. It is
+// empty if the package isn't in the graph.
+func (g *Graph) RootsFor(pkgName string) sets.Set[*Node] {
+	if r, ok := g.roots[pkgName]; ok {
+		return r
+	}
+	return sets.New[*Node]()
+}
+
+func (g *Graph) findRoots() {
+	g.roots = map[string]sets.Set[*Node]{}
+	for n := range g.NodesMatching(isRoot) {
+		if g.roots[n.Name] == nil {
+			g.roots[n.Name] = sets.New[*Node]()
+		}
+		g.roots[n.Name].Insert(n)
+	}
+}
+
+// isRoot reports whether a node has no predecessors in its own package, as
+// isHead does for successors.
+func isRoot(g *Graph, n *Node) bool {
+	for from := range nodeIterator(g.wg.To(n.ID())) {
+		if from.Name == n.Name {
+			return false
+		}
+	}
+	return true
+}
+
+// isHead reports whether a node has no successors in its own package, so that
+// each package's heads are found independently of the other packages.
+func isHead(g *Graph, n *Node) bool {
+	for to := range nodeIterator(g.wg.From(n.ID())) {
+		if to.Name == n.Name {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *Graph) buildEdges(ctx context.Context, cfg GraphConfig) error {
+	delta := cmp.Or(cfg.EdgeWeightDelta, DefaultEdgeWeightDelta)
+	if delta < 0 || math.IsInf(delta, 0) || math.IsNaN(delta) {
+		return fmt.Errorf("invalid edge weight delta %v: must be a positive number", cfg.EdgeWeightDelta)
+	}
+
+	var errs []error
+	for _, pkg := range cfg.Packages {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var (
+			streamsByMajorMinor = util.KeySlice(pkg.Streams, func(s VersionStream) MajorMinor { return s.Version })
+			nodesByReleaseDate  = slices.SortedFunc(
+				g.NodesMatching(PackageNodes(pkg.Name)),
+				func(a, b *Node) int {
+					// Rebuilds released at the same time, such as those of
+					// reproducible images, update to higher releases.
+					return cmp.Or(a.ReleaseDate.Compare(b.ReleaseDate), a.Compare(b), cmp.Compare(a.VR(), b.VR()))
+				},
+			)
+			froms = make([]*Node, 0, len(nodesByReleaseDate))
+		)
+		for _, to := range nodesByReleaseDate {
+			if err := cfg.excluded(to); err != nil {
+				g.skipped = append(g.skipped, SkippedNode{Node: to, Err: err})
+				g.wg.RemoveNode(to.ID())
+				continue
+			}
+			toMM := NewMajorMinorFromVersion(to.Version)
+			stream, ok := streamsByMajorMinor[toMM]
+			if !ok {
+				err := fmt.Errorf("node with reference %s has major.minor version %s, but that version is not in an available stream", to.ImageReference.String(), toMM)
+				if cfg.SkipInvalidNodes {
+					g.skipped = append(g.skipped, SkippedNode{Node: to, Err: err})
+					g.wg.RemoveNode(to.ID())
+					continue
+				}
+				errs = append(errs, err)
+				continue
+			}
+
+			to.SupportedPlatformVersions = sets.New[MajorMinor](stream.SupportedPlatformVersions...)
+			to.RequiresUpdatePlatformVersions = sets.New[MajorMinor](stream.RequiresUpdatePlatformVersions...)
+			to.LifecyclePhase = stream.LifecycleDates.Phase(cfg.AsOf)
+			to.LifecycleDates = stream.LifecycleDates
+			to.PhaseBoundaries = stream.LifecycleDates.Boundaries(cfg.AsOf)
+
+			if !cfg.IncludePreGA && to.LifecyclePhase == LifecyclePhasePreGA {
+				continue
+			}
+
+			g.initializeEdgesTo(froms, to, stream.MinimumUpdateVersion, pkg.MajorVersionBridges, cfg.Prereleases)
+			froms = append(froms, to)
+		}
+		if err := g.assignEdgeWeights(pkg, delta, cfg.Ranking, cfg.WeightStrategy); err != nil {
+			errs = append(errs, err)
+		}
+		if cfg.KeepEdge != nil {
+			g.removeEdges(pkg, cfg.KeepEdge)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	return nil
+}
+
+// removeEdges removes the edges between the nodes of pkg that don't match
+// keep.
+func (g *Graph) removeEdges(pkg Package, keep EdgePredicate) {
+	var remove []WeightedEdge
+	for to := range g.NodesMatching(PackageNodes(pkg.Name)) {
+		for from := range nodeIterator(g.wg.To(to.ID())) {
+			if w := g.EdgeWeight(from, to); !keep(g, from, to, w) {
+				remove = append(remove, WeightedEdge{From: from, To: to, Weight: w})
+			}
+		}
+	}
+	for _, e := range remove {
+		g.wg.RemoveEdge(e.From.ID(), e.To.ID())
+	}
+}
+
+func nodeIterator(it graph.Nodes) iter.Seq[*Node] {
+	return func(yield func(*Node) bool) {
+		for it.Next() {
+			n := it.Node().(*Node)
+			if !yield(n) {
+				return
+			}
+		}
+	}
+}
+
+func (cfg *GraphConfig) Validate() error {
+	// TODO: collect errors
+	if len(cfg.Packages) == 0 {
+		return errors.New("no packages specified")
+	}
+	for _, pkg := range cfg.Packages {
+		if len(pkg.Streams) == 0 {
+			return fmt.Errorf("no streams specified")
+		}
+		for _, stream := range pkg.Streams {
+			if err := stream.LifecycleDates.ValidateOrder(); err != nil {
+				return err
+			}
+		}
+		if err := validateStreams(pkg.Streams); err != nil {
+			return fmt.Errorf("package %s: %w", pkg.Name, err)
+		}
+		if err := validateBridges(pkg.Streams, pkg.MajorVersionBridges); err != nil {
+			return fmt.Errorf("package %s: %w", pkg.Name, err)
+		}
+		if len(pkg.Nodes) == 0 {
+			return fmt.Errorf("no nodes specified")
+		}
+		if err := validateNodeNames(pkg.Nodes); err != nil {
+			return err
+		}
+	}
+	if cfg.AsOf.IsZero() {
+		return fmt.Errorf("no as-of timestamp specified")
+	}
+	return nil
+}
+
+func validateNodeNames(nodes []*Node) error {
+	names := sets.New[string]()
+	for _, n := range nodes {
+		if n.Name != "" {
+			names.Insert(n.Name)
+		}
+	}
+	if len(names) == 0 {
+		return errors.New("invalid nodes: no nodes are have a name")
+	}
+	if len(names) != 1 {
+		return fmt.Errorf("invalid nodes: found more than one name in the set of node names, expected exactly one: %v", sets.List(names))
+	}
+	return nil
+}
+
+func (g *Graph) initializeEdgesTo(froms []*Node, to *Node, minimumUpdateVersion semver.Version, bridges []MajorVersionBridge, prereleases PrereleasePolicy) {
+	for _, from := range froms {
+		// Don't update to a lower version
+		if from.Compare(to) > 0 {
+			continue
+		}
+
+		// Don't update to a prerelease, unless the policy permits it
+		if !prereleases.permitsEdge(from, to) {
+			continue
+		}
+
+		if from.Version.Major != to.Version.Major {
+			// Don't update to a different major version, unless a bridge
+			// permits it
+			if !slices.ContainsFunc(bridges, func(b MajorVersionBridge) bool { return b.permits(from, to) }) {
+				continue
+			}
+		} else if from.Version.LT(minimumUpdateVersion) {
+			// Don't update from a version below the minimum update version
+			continue
+		}
+
+		// We don't know from's full set of successors yet. For now, set weight to 1.
+		// Once all edges have been set, we can make a second pass to set better weights.
+		edge := simple.WeightedEdge{F: from, T: to, W: 1}
+		g.wg.SetWeightedEdge(edge)
+	}
+}
+
+// assignEdgeWeights assigns edge weights to prioritize updating through supported nodes and to higher versions
+// (in that order, with the default PhaseFirst ranking). It assigns a rank to each node in the order of ranking (higher
+// nodes have better support phase and higher versions), and then assigns all incoming edge weights as that node's
+// rank, in units of delta.
+//
+// In order to guarantee that all paths with worse support are worse than all paths with better support,
+// assignEdgeWeights create gaps between ranks when the ranking's tiers are crossed. For example, if there are 3 nodes with
+// "full" support with ranks 1, 2, and 3, then traversing upgrades 3 -> 2 -> 1 would have a total sum of 6. Therefore,
+// the best "maintenance" support node needs rank 7 to ensure that all paths through a single "maintenance" support
+// node are worse than the worst path through all "full" supports nodes. Since updates only cross major versions over
+// the package's bridges, each major version is ranked separately, together with the major versions it is bridged to,
+// which keeps ranks small for packages with many major versions.
+//
+// Ranks are counted as integers so that they never collide, however many nodes there are. Because each tier's ranks
+// grow with the sum of the tiers before it, packages with many large tiers can exceed the ranks that edge weights can
+// represent exactly, in which case an error is returned.
+//
+// If strategy is set, it adjusts each edge's weight after ranking, and an error is returned if it produces a weight
+// that shortest paths can't be computed with.
+func (g *Graph) assignEdgeWeights(pkg Package, delta float64, ranking Ranking, strategy WeightStrategy) error {
+	if ranking == nil {
+		ranking = PhaseFirst()
+	}
+	nodes := slices.Collect(g.NodesMatching(PackageNodes(pkg.Name)))
+	tiers := ranking.Tiers(slices.Clone(nodes))
+	tierOf := make(map[*Node]int, len(nodes))
+	for _, n := range nodes {
+		tierOf[n] = -1
+	}
+	for i, tier := range tiers {
+		for _, n := range tier {
+			t, ok := tierOf[n]
+			if !ok {
+				return fmt.Errorf("ranking of package %s ranked %s, which is not one of its nodes", pkg.Name, n.NVR())
+			}
+			if t >= 0 {
+				return fmt.Errorf("ranking of package %s ranked %s more than once", pkg.Name, n.NVR())
+			}
+			tierOf[n] = i
+		}
+	}
+	for _, n := range nodes {
+		if tierOf[n] < 0 {
+			return fmt.Errorf("ranking of package %s did not rank %s", pkg.Name, n.NVR())
+		}
+	}
+
+	// Ranks are kept for each major version separately, or for each group of
+	// bridged major versions, keyed by the lowest major version of the group.
+	// pathRanks holds the sum of the ranks of a major version's nodes so far,
+	// which bounds the weight of any path through them.
+	rankGroup := bridgedMajorVersions(pkg.MajorVersionBridges)
+	var (
+		ranks     = map[uint64]uint64{}
+		pathRanks = map[uint64]uint64{}
+		curTiers  = map[uint64]int{}
+	)
+	for _, to := range slices.Concat(tiers...) {
+		major := rankGroup(to.Version.Major)
+		if tier, ok := curTiers[major]; !ok || tier != tierOf[to] {
+			curTiers[major] = tierOf[to]
+			ranks[major] = pathRanks[major]
+		}
+		ranks[major]++
+		pathRanks[major] += ranks[major]
+		if pathRanks[major] > maxEdgeWeightRank {
+			return fmt.Errorf("package %s has too many nodes in the lifecycle phases of major version %d to rank them with exact edge weights", pkg.Name, major)
+		}
+		rank := ranks[major]
+		for from := range nodeIterator(g.wg.To(to.ID())) {
+			w := float64(rank) * delta
+			if strategy != nil {
+				w = strategy.EdgeWeight(WeightedEdge{From: from, To: to, Weight: w}, g.asOf)
+				if !validEdgeWeight(w) {
+					return fmt.Errorf("invalid weight %v for edge from %s to %s: must be a non-negative number", w, from.NVR(), to.NVR())
+				}
+			}
+			g.wg.RemoveEdge(from.ID(), to.ID())
+			g.wg.SetWeightedEdge(simple.WeightedEdge{F: from, T: to, W: w})
+		}
+	}
+	return nil
+}
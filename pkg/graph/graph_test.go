@@ -0,0 +1,572 @@
+package graph_test
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+var edgeWeightsAsOf = time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// phasedPackage returns a package with a major version 1 stream for each of
+// phases, worst first, each with nodesPerStream nodes. Each stream can be
+// updated to from the previous one.
+func phasedPackage(phases []graph.LifecyclePhase, nodesPerStream int) graph.Package {
+	pkg := graph.Package{Name: "foo"}
+	released := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for minor, phase := range phases {
+		mm := graph.MajorMinor{Major: 1, Minor: uint64(minor)}
+		pkg.Streams = append(pkg.Streams, graph.VersionStream{
+			Version:              mm,
+			MinimumUpdateVersion: semver.Version{Major: 1, Minor: uint64(max(minor-1, 0))},
+			LifecycleDates:       datesInPhase(phase, len(phases)),
+		})
+		for patch := range nodesPerStream {
+			released = released.Add(time.Hour)
+			pkg.Nodes = append(pkg.Nodes, &graph.Node{
+				Name:        "foo",
+				Version:     semver.Version{Major: 1, Minor: uint64(minor), Patch: uint64(patch)},
+				ReleaseDate: released,
+			})
+		}
+	}
+	return pkg
+}
+
+// datesInPhase returns lifecycle dates with the given number of extensions
+// that are in phase as of edgeWeightsAsOf.
+func datesInPhase(phase graph.LifecyclePhase, extensions int) graph.LifecycleDates {
+	boundaries := make([]graph.Date, 0, extensions+3)
+	for i := range extensions + 3 {
+		year := 2031 + i
+		if i <= int(phase) {
+			year = 2020 + i
+		}
+		boundaries = append(boundaries, graph.NewDate(year, 1, 1))
+	}
+	return graph.LifecycleDates{
+		FullSupport: boundaries[0],
+		Maintenance: boundaries[1],
+		Extensions:  boundaries[2 : len(boundaries)-1],
+		EndOfLife:   boundaries[len(boundaries)-1],
+	}
+}
+
+// nodeWeights returns the weight of the incoming edges of each node that has
+// any, by phase.
+func nodeWeights(g *graph.Graph, pkg graph.Package) map[graph.LifecyclePhase][]float64 {
+	weights := map[graph.LifecyclePhase][]float64{}
+	for _, to := range pkg.Nodes {
+		for from := range g.To(to) {
+			weights[to.LifecyclePhase] = append(weights[to.LifecyclePhase], g.EdgeWeight(from, to))
+			break
+		}
+	}
+	return weights
+}
+
+func TestEdgeWeightsOrderLargePhases(t *testing.T) {
+	// More than 100 nodes per phase, which collided with the next phase
+	// when ranks were accumulated as floats.
+	phases := []graph.LifecyclePhase{
+		graph.LifecycleExtensionPhase(1),
+		graph.LifecyclePhaseMaintenance,
+		graph.LifecyclePhaseFullSupport,
+	}
+	pkg := phasedPackage(phases, 120)
+	g, err := graph.NewGraph(t.Context(), graph.GraphConfig{Packages: []graph.Package{pkg}, AsOf: edgeWeightsAsOf})
+	require.NoError(t, err)
+
+	weights := nodeWeights(g, pkg)
+	betterSum := 0.0
+	for i := len(phases) - 1; i >= 0; i-- {
+		w := weights[phases[i]]
+		require.NotEmpty(t, w, phases[i].String())
+
+		// Nodes are in ascending version order, so within a phase, updating
+		// to a higher version must be cheaper.
+		for j := 1; j < len(w); j++ {
+			assert.Less(t, w[j], w[j-1], "%s node %d", phases[i], j)
+		}
+
+		// A single edge into a phase must cost more than a path through
+		// every node of the phases that are better than it.
+		assert.Greater(t, w[len(w)-1], betterSum, phases[i].String())
+		for _, x := range w {
+			betterSum += x
+		}
+	}
+}
+
+func TestEdgeWeightsDelta(t *testing.T) {
+	phases := []graph.LifecyclePhase{graph.LifecyclePhaseMaintenance, graph.LifecyclePhaseFullSupport}
+	newWeights := func(delta float64) map[graph.LifecyclePhase][]float64 {
+		pkg := phasedPackage(phases, 3)
+		g, err := graph.NewGraph(t.Context(), graph.GraphConfig{Packages: []graph.Package{pkg}, AsOf: edgeWeightsAsOf, EdgeWeightDelta: delta})
+		require.NoError(t, err)
+		return nodeWeights(g, pkg)
+	}
+
+	def, scaled := newWeights(0), newWeights(1)
+	assert.InDeltaSlice(t, []float64{0.03, 0.02, 0.01}, def[graph.LifecyclePhaseFullSupport], 1e-9)
+	for phase, w := range def {
+		require.Len(t, scaled[phase], len(w))
+		for i := range w {
+			assert.InDelta(t, w[i]/graph.DefaultEdgeWeightDelta, scaled[phase][i], 1e-9)
+		}
+	}
+
+	_, err := graph.NewGraph(t.Context(), graph.GraphConfig{Packages: []graph.Package{phasedPackage(phases, 3)}, AsOf: edgeWeightsAsOf, EdgeWeightDelta: -1})
+	assert.ErrorContains(t, err, "invalid edge weight delta")
+}
+
+func TestEdgeWeightsOverflow(t *testing.T) {
+	// Each phase's ranks grow with the sum of the ranks of the phases before
+	// it, so many large phases exceed what edge weights represent exactly.
+	var phases []graph.LifecyclePhase
+	for i := 10; i > 0; i-- {
+		phases = append(phases, graph.LifecycleExtensionPhase(i))
+	}
+	phases = append(phases, graph.LifecyclePhaseMaintenance, graph.LifecyclePhaseFullSupport)
+
+	_, err := graph.NewGraph(t.Context(), graph.GraphConfig{Packages: []graph.Package{phasedPackage(phases, 30)}, AsOf: edgeWeightsAsOf})
+	assert.ErrorContains(t, err, "package foo has too many nodes")
+
+	// Updates never cross major versions, so the same nodes spread across
+	// major versions can be ranked.
+	pkg := phasedPackage(phases, 30)
+	for _, n := range pkg.Nodes {
+		n.Version.Major = n.Version.Minor + 1
+	}
+	var streams []graph.VersionStream
+	for _, s := range pkg.Streams {
+		s.Version.Major = s.Version.Minor + 1
+		s.MinimumUpdateVersion = semver.Version{Major: s.Version.Major, Minor: s.Version.Minor}
+		streams = append(streams, s)
+	}
+	pkg.Streams = streams
+	_, err = graph.NewGraph(t.Context(), graph.GraphConfig{Packages: []graph.Package{pkg}, AsOf: edgeWeightsAsOf})
+	assert.NoError(t, err)
+}
+
+func TestSkipInvalidNodes(t *testing.T) {
+	pkg := phasedPackage([]graph.LifecyclePhase{graph.LifecyclePhaseMaintenance, graph.LifecyclePhaseFullSupport}, 2)
+	ref, err := reference.ParseNormalizedNamed("quay.io/example/foo-bundle@sha256:" + strings.Repeat("0", 64))
+	require.NoError(t, err)
+	orphan := &graph.Node{
+		Name:           "foo",
+		Version:        semver.MustParse("2.0.0"),
+		ReleaseDate:    time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+		ImageReference: ref.(reference.Canonical),
+	}
+	pkg.Nodes = append(pkg.Nodes, orphan)
+
+	_, err = graph.NewGraph(t.Context(), graph.GraphConfig{Packages: []graph.Package{pkg}, AsOf: edgeWeightsAsOf})
+	assert.ErrorContains(t, err, "major.minor version 2.0, but that version is not in an available stream")
+
+	g, err := graph.NewGraph(t.Context(), graph.GraphConfig{Packages: []graph.Package{pkg}, AsOf: edgeWeightsAsOf, SkipInvalidNodes: true})
+	require.NoError(t, err)
+	require.Len(t, g.SkippedNodes(), 1)
+	assert.Same(t, orphan, g.SkippedNodes()[0].Node)
+	assert.ErrorContains(t, g.SkippedNodes()[0].Err, "major.minor version 2.0")
+
+	var versions []string
+	for n := range g.NodesMatching(graph.AllNodes()) {
+		versions = append(versions, n.Version.String())
+	}
+	assert.ElementsMatch(t, []string{"1.0.0", "1.0.1", "1.1.0", "1.1.1"}, versions)
+	assert.NotContains(t, g.Heads(), orphan)
+}
+
+// catalogPackages returns packages, each with majors major versions of a
+// single full support stream with nodesPerMajor nodes, as in a catalog of
+// many packages.
+func catalogPackages(packages, majors, nodesPerMajor int) []graph.Package {
+	var pkgs []graph.Package
+	for p := range packages {
+		name := fmt.Sprintf("pkg-%d", p)
+		pkg := graph.Package{Name: name}
+		released := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		for major := range majors {
+			pkg.Streams = append(pkg.Streams, graph.VersionStream{
+				Version:        graph.MajorMinor{Major: uint64(major)},
+				LifecycleDates: datesInPhase(graph.LifecyclePhaseFullSupport, 0),
+			})
+			for patch := range nodesPerMajor {
+				released = released.Add(time.Hour)
+				pkg.Nodes = append(pkg.Nodes, &graph.Node{
+					Name:        name,
+					Version:     semver.Version{Major: uint64(major), Patch: uint64(patch)},
+					ReleaseDate: released,
+				})
+			}
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs
+}
+
+func TestPathScopes(t *testing.T) {
+	pkgs := catalogPackages(3, 2, 4)
+	newGraph := func(scope graph.PathScope) *graph.Graph {
+		g, err := graph.NewGraph(t.Context(), graph.GraphConfig{Packages: pkgs, AsOf: edgeWeightsAsOf, PathScope: scope})
+		require.NoError(t, err)
+		return g
+	}
+	want := newGraph(graph.PathScopeGraph)
+	for _, scope := range []graph.PathScope{graph.PathScopePackage, graph.PathScopeComponent} {
+		got := newGraph(scope)
+		for _, from := range pkgs {
+			for _, u := range from.Nodes {
+				for _, to := range pkgs {
+					for _, v := range to.Nodes {
+						wantPath, wantWeight, wantOK := want.ShortestPath(u, v)
+						gotPath, gotWeight, gotOK := got.ShortestPath(u, v)
+						assert.Equal(t, wantPath, gotPath, "%d: %s -> %s", scope, u.NVR(), v.NVR())
+						assert.Equal(t, wantWeight, gotWeight, "%d: %s -> %s", scope, u.NVR(), v.NVR())
+						assert.Equal(t, wantOK, gotOK, "%d: %s -> %s", scope, u.NVR(), v.NVR())
+					}
+				}
+			}
+		}
+	}
+}
+
+// BenchmarkNewGraphPathScopes compares the memory that each path scope takes
+// for a graph of 100 packages with 2 major versions of 10 nodes each:
+//
+//	BenchmarkNewGraphPathScopes/graph      ~135 MB/op
+//	BenchmarkNewGraphPathScopes/package     ~11 MB/op
+//	BenchmarkNewGraphPathScopes/component   ~13 MB/op
+func BenchmarkNewGraphPathScopes(b *testing.B) {
+	pkgs := catalogPackages(100, 2, 10)
+	for _, bc := range []struct {
+		name  string
+		scope graph.PathScope
+	}{
+		{"graph", graph.PathScopeGraph},
+		{"package", graph.PathScopePackage},
+		{"component", graph.PathScopeComponent},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := graph.NewGraph(b.Context(), graph.GraphConfig{Packages: pkgs, AsOf: edgeWeightsAsOf, PathScope: bc.scope}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestCanceledContext(t *testing.T) {
+	pkgs := catalogPackages(2, 1, 3)
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	for _, scope := range []graph.PathScope{graph.PathScopeGraph, graph.PathScopePackage, graph.PathScopeComponent} {
+		_, err := graph.NewGraph(ctx, graph.GraphConfig{Packages: pkgs, AsOf: edgeWeightsAsOf, PathScope: scope})
+		assert.ErrorIs(t, err, context.Canceled)
+	}
+
+	g, err := graph.NewGraph(t.Context(), graph.GraphConfig{Packages: pkgs, AsOf: edgeWeightsAsOf})
+	require.NoError(t, err)
+	_, err = g.PlanOpenShiftUpdate(ctx, pkgs[0].Nodes, graph.MajorMinor{Major: 4, Minor: 14}, graph.MajorMinor{Major: 4, Minor: 15})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestNodeOrdering(t *testing.T) {
+	newPackage := func(name string, releases ...string) graph.Package {
+		pkg := graph.Package{Name: name, Streams: []graph.VersionStream{{
+			Version:        graph.MajorMinor{Major: 1},
+			LifecycleDates: datesInPhase(graph.LifecyclePhaseFullSupport, 0),
+		}}}
+		released := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		for i, r := range releases {
+			pkg.Nodes = append(pkg.Nodes, &graph.Node{
+				Name:        name,
+				Version:     semver.MustParse("1.0.0"),
+				Release:     &r,
+				ReleaseDate: released.Add(time.Duration(i) * time.Hour),
+			})
+		}
+		return pkg
+	}
+
+	for range 10 {
+		g, err := graph.NewGraph(t.Context(), graph.GraphConfig{
+			Packages: []graph.Package{newPackage("foo", "9", "2", "10"), newPackage("bar", "3", "1")},
+			AsOf:     edgeWeightsAsOf,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"bar.v1.0.0_1", "bar.v1.0.0_3", "foo.v1.0.0_2", "foo.v1.0.0_9", "foo.v1.0.0_10"}, nvrs(slices.Collect(g.NodesMatching(graph.AllNodes()))))
+		assert.Equal(t, "foo.v1.0.0_2", g.FirstNodeMatching(graph.AndNodes(graph.PackageNodes("foo"), graph.NodeInRange(semver.MustParseRange("1.0.0")))).NVR())
+
+		last := g.FirstNodeMatching(graph.AndNodes(graph.PackageNodes("foo"), func(_ *graph.Graph, n *graph.Node) bool { return *n.Release == "10" }))
+		assert.Equal(t, []string{"foo.v1.0.0_2", "foo.v1.0.0_9"}, nvrs(slices.Collect(g.To(last))))
+	}
+}
+
+func TestHeadsFor(t *testing.T) {
+	pkgs := catalogPackages(2, 2, 3)
+	g, err := graph.NewGraph(t.Context(), graph.GraphConfig{Packages: pkgs, AsOf: edgeWeightsAsOf})
+	require.NoError(t, err)
+
+	// Updates never cross major versions, so each major version has a head.
+	assert.ElementsMatch(t, []string{"pkg-0.v0.0.2", "pkg-0.v1.0.2"}, nvrs(g.HeadsFor("pkg-0").UnsortedList()))
+	assert.ElementsMatch(t, []string{"pkg-1.v0.0.2", "pkg-1.v1.0.2"}, nvrs(g.HeadsFor("pkg-1").UnsortedList()))
+	assert.ElementsMatch(t, []string{"pkg-0.v0.0.2", "pkg-0.v1.0.2", "pkg-1.v0.0.2", "pkg-1.v1.0.2"}, nvrs(g.Heads().UnsortedList()))
+	assert.Empty(t, g.HeadsFor("missing"))
+}
+
+func TestRootsFor(t *testing.T) {
+	pkgs := catalogPackages(2, 2, 3)
+	g, err := graph.NewGraph(t.Context(), graph.GraphConfig{Packages: pkgs, AsOf: edgeWeightsAsOf})
+	require.NoError(t, err)
+
+	// Updates never cross major versions, so each major version has a root.
+	assert.ElementsMatch(t, []string{"pkg-0.v0.0.0", "pkg-0.v1.0.0"}, nvrs(g.RootsFor("pkg-0").UnsortedList()))
+	assert.ElementsMatch(t, []string{"pkg-1.v0.0.0", "pkg-1.v1.0.0"}, nvrs(g.RootsFor("pkg-1").UnsortedList()))
+	assert.ElementsMatch(t, []string{"pkg-0.v0.0.0", "pkg-0.v1.0.0", "pkg-1.v0.0.0", "pkg-1.v1.0.0"}, nvrs(g.Roots().UnsortedList()))
+	assert.Empty(t, g.RootsFor("missing"))
+}
+
+func TestEdges(t *testing.T) {
+	pkgs := catalogPackages(2, 1, 3)
+	g, err := graph.NewGraph(t.Context(), graph.GraphConfig{Packages: pkgs, AsOf: edgeWeightsAsOf})
+	require.NoError(t, err)
+
+	var got []string
+	for e := range g.Edges() {
+		assert.Equal(t, g.EdgeWeight(e.From, e.To), e.Weight)
+		got = append(got, e.From.VR()+"->"+e.To.VR())
+	}
+	want := []string{"0.0.0->0.0.1", "0.0.0->0.0.2", "0.0.1->0.0.2"}
+	assert.Equal(t, append(want, want...), got, "edges are ordered by package, from, and to")
+
+	var matched []graph.WeightedEdge
+	for e := range g.EdgesMatching(func(_ *graph.Graph, from, to *graph.Node, _ float64) bool {
+		return from.Name == "pkg-1" && to.Version.Patch == 2
+	}) {
+		matched = append(matched, e)
+	}
+	require.Len(t, matched, 2)
+	assert.Equal(t, []string{"pkg-1.v0.0.0", "pkg-1.v0.0.1"}, nvrs([]*graph.Node{matched[0].From, matched[1].From}))
+
+	// Iteration stops when the loop breaks.
+	n := 0
+	for range g.Edges() {
+		n++
+		break
+	}
+	assert.Equal(t, 1, n)
+}
+
+func TestMajorVersionBridges(t *testing.T) {
+	newPackage := func(bridges ...graph.MajorVersionBridge) graph.Package {
+		pkg := graph.Package{Name: "foo", MajorVersionBridges: bridges}
+		released := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		for _, v := range []string{"1.0.0", "1.1.0", "2.0.0"} {
+			version := semver.MustParse(v)
+			platforms := []graph.MajorMinor{{Major: 4, Minor: 14}}
+			if version.Major == 2 {
+				platforms = append(platforms, graph.MajorMinor{Major: 4, Minor: 15})
+			}
+			pkg.Streams = append(pkg.Streams, graph.VersionStream{
+				Version:                   graph.NewMajorMinorFromVersion(version),
+				MinimumUpdateVersion:      semver.Version{Major: version.Major},
+				LifecycleDates:            datesInPhase(graph.LifecyclePhaseFullSupport, 0),
+				SupportedPlatformVersions: platforms,
+			})
+			released = released.Add(time.Hour)
+			pkg.Nodes = append(pkg.Nodes, &graph.Node{Name: "foo", Version: version, ReleaseDate: released})
+		}
+		return pkg
+	}
+	fromPlatform, toPlatform := graph.MajorMinor{Major: 4, Minor: 14}, graph.MajorMinor{Major: 4, Minor: 15}
+
+	pkg := newPackage()
+	g, err := graph.NewGraph(t.Context(), graph.GraphConfig{Packages: []graph.Package{pkg}, AsOf: edgeWeightsAsOf})
+	require.NoError(t, err)
+	_, _, ok := g.ShortestPath(pkg.Nodes[0], pkg.Nodes[2])
+	assert.False(t, ok)
+	plan, err := g.PlanOpenShiftUpdate(t.Context(), pkg.Nodes[:1], fromPlatform, toPlatform)
+	require.NoError(t, err)
+	assert.Error(t, plan.NodeUpdates[0].Error)
+
+	pkg = newPackage(graph.MajorVersionBridge{From: semver.MustParse("1.1.0"), To: graph.MajorMinor{Major: 2}})
+	g, err = graph.NewGraph(t.Context(), graph.GraphConfig{Packages: []graph.Package{pkg}, AsOf: edgeWeightsAsOf})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"foo.v1.1.0"}, nvrs(slices.Collect(g.To(pkg.Nodes[2]))))
+	path, _, ok := g.ShortestPath(pkg.Nodes[0], pkg.Nodes[2])
+	require.True(t, ok)
+	assert.Equal(t, []string{"foo.v1.0.0", "foo.v1.1.0", "foo.v2.0.0"}, nvrs(path))
+	assert.ElementsMatch(t, []string{"foo.v2.0.0"}, nvrs(g.HeadsFor("foo").UnsortedList()))
+
+	plan, err = g.PlanOpenShiftUpdate(t.Context(), pkg.Nodes[:1], fromPlatform, toPlatform)
+	require.NoError(t, err)
+	require.NoError(t, plan.NodeUpdates[0].Error)
+	assert.Equal(t, []string{"foo.v1.0.0", "foo.v1.1.0", "foo.v2.0.0"}, nvrs(plan.NodeUpdates[0].Before))
+
+	// The only path to 2.0.0 goes through the bridge.
+	plan, err = g.PlanOpenShiftUpdate(t.Context(), pkg.Nodes[:1], fromPlatform, toPlatform, graph.ExcludeNodes(graph.NodeInRange(semver.MustParseRange("1.1.0"))))
+	require.NoError(t, err)
+	assert.Error(t, plan.NodeUpdates[0].Error)
+}
+
+func TestPreferEUS(t *testing.T) {
+	pkg := graph.Package{Name: "foo"}
+	released := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for minor, extensions := range []int{0, 1, 0} {
+		// 1.0 isn't supported on 4.16, so clusters updating to it update to
+		// 1.1, which is an EUS stream, or 1.2, which is not.
+		platforms := []graph.MajorMinor{{Major: 4, Minor: 14}, {Major: 4, Minor: 15}}
+		if minor > 0 {
+			platforms = append(platforms, graph.MajorMinor{Major: 4, Minor: 16})
+		}
+		pkg.Streams = append(pkg.Streams, graph.VersionStream{
+			Version:                   graph.MajorMinor{Major: 1, Minor: uint64(minor)},
+			MinimumUpdateVersion:      semver.Version{Major: 1},
+			LifecycleDates:            datesInPhase(graph.LifecyclePhaseFullSupport, extensions),
+			SupportedPlatformVersions: platforms,
+		})
+		released = released.Add(time.Hour)
+		pkg.Nodes = append(pkg.Nodes, &graph.Node{Name: "foo", Version: semver.Version{Major: 1, Minor: uint64(minor)}, ReleaseDate: released})
+	}
+	g, err := graph.NewGraph(t.Context(), graph.GraphConfig{Packages: []graph.Package{pkg}, AsOf: edgeWeightsAsOf})
+	require.NoError(t, err)
+	assert.Len(t, pkg.Nodes[1].LifecycleDates.Extensions, 1)
+
+	eus414, eus416 := graph.MajorMinor{Major: 4, Minor: 14}, graph.MajorMinor{Major: 4, Minor: 16}
+	plan, err := g.PlanOpenShiftUpdate(t.Context(), pkg.Nodes[:1], eus414, eus416)
+	require.NoError(t, err)
+	require.NoError(t, plan.NodeUpdates[0].Error)
+	assert.Equal(t, "foo.v1.2.0", plan.NodeUpdates[0].Before[len(plan.NodeUpdates[0].Before)-1].NVR())
+
+	plan, err = g.PlanOpenShiftUpdate(t.Context(), pkg.Nodes[:1], eus414, eus416, graph.PreferEUS())
+	require.NoError(t, err)
+	require.NoError(t, plan.NodeUpdates[0].Error)
+	assert.Equal(t, "foo.v1.1.0", plan.NodeUpdates[0].Before[len(plan.NodeUpdates[0].Before)-1].NVR())
+
+	// Updates to platform versions without EUS aren't affected.
+	plan, err = g.PlanOpenShiftUpdate(t.Context(), pkg.Nodes[:1], eus414, graph.MajorMinor{Major: 4, Minor: 15}, graph.PreferEUS())
+	require.NoError(t, err)
+	require.NoError(t, plan.NodeUpdates[0].Error)
+	assert.Equal(t, "foo.v1.0.0", plan.NodeUpdates[0].Before[len(plan.NodeUpdates[0].Before)-1].NVR())
+}
+
+// eolPackage returns a package whose 1.0 and 1.1 streams are end of life and
+// whose 1.2 stream is fully supported. Only 1.1 is supported on 4.16.
+func eolPackage() graph.Package {
+	pkg := graph.Package{Name: "foo"}
+	released := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for minor, phase := range []graph.LifecyclePhase{graph.LifecyclePhaseEndOfLife, graph.LifecyclePhaseEndOfLife, graph.LifecyclePhaseFullSupport} {
+		platforms := []graph.MajorMinor{{Major: 4, Minor: 14}, {Major: 4, Minor: 15}}
+		if minor == 1 {
+			platforms = append(platforms, graph.MajorMinor{Major: 4, Minor: 16})
+		}
+		pkg.Streams = append(pkg.Streams, graph.VersionStream{
+			Version:                   graph.MajorMinor{Major: 1, Minor: uint64(minor)},
+			MinimumUpdateVersion:      semver.Version{Major: 1},
+			LifecycleDates:            datesInPhase(phase, 0),
+			SupportedPlatformVersions: platforms,
+		})
+		patches := 2
+		if phase != graph.LifecyclePhaseEndOfLife {
+			patches = 1
+		}
+		for patch := range patches {
+			released = released.Add(time.Hour)
+			pkg.Nodes = append(pkg.Nodes, &graph.Node{Name: "foo", Version: semver.Version{Major: 1, Minor: uint64(minor), Patch: uint64(patch)}, ReleaseDate: released})
+		}
+	}
+	return pkg
+}
+
+func TestEOLEdgeRules(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		keep graph.EdgePredicate
+
+		// from100 and from110 are the nodes that 1.0.0 and 1.1.0 update to.
+		from100, from110 []string
+
+		// planned is where 1.0.0 is updated to across an update from 4.14
+		// to 4.16, or "" if it can't be. preferred is where 1.1.0 is
+		// updated to when 1.1.1 is preferred.
+		planned, preferred string
+	}{
+		{
+			name:      "no rules",
+			from100:   []string{"foo.v1.0.1", "foo.v1.1.0", "foo.v1.1.1", "foo.v1.2.0"},
+			from110:   []string{"foo.v1.1.1", "foo.v1.2.0"},
+			planned:   "foo.v1.1.1",
+			preferred: "foo.v1.1.1",
+		},
+		{
+			name:      "ForbidEOLTargets",
+			keep:      graph.ForbidEOLTargets(),
+			from100:   []string{"foo.v1.2.0"},
+			from110:   []string{"foo.v1.2.0"},
+			preferred: "foo.v1.1.0",
+		},
+		{
+			name:      "ForbidCrossMinorIntoEOL",
+			keep:      graph.ForbidCrossMinorIntoEOL(),
+			from100:   []string{"foo.v1.0.1", "foo.v1.2.0"},
+			from110:   []string{"foo.v1.1.1", "foo.v1.2.0"},
+			preferred: "foo.v1.1.1",
+		},
+		{
+			name:      "both",
+			keep:      graph.AndEdges(graph.ForbidEOLTargets(), graph.ForbidCrossMinorIntoEOL()),
+			from100:   []string{"foo.v1.2.0"},
+			from110:   []string{"foo.v1.2.0"},
+			preferred: "foo.v1.1.0",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			plannedTo := func(t *testing.T, g *graph.Graph, from *graph.Node, opts ...graph.PlanOption) string {
+				t.Helper()
+				plan, err := g.PlanOpenShiftUpdate(t.Context(), []*graph.Node{from}, graph.MajorMinor{Major: 4, Minor: 14}, graph.MajorMinor{Major: 4, Minor: 16}, opts...)
+				require.NoError(t, err)
+				if plan.NodeUpdates[0].Error != nil {
+					return ""
+				}
+				return plan.NodeUpdates[0].Before[len(plan.NodeUpdates[0].Before)-1].NVR()
+			}
+			prefer111 := graph.PreferNodes(func(_ *graph.Graph, n *graph.Node) bool { return n.NVR() == "foo.v1.1.1" })
+
+			t.Run("graph", func(t *testing.T) {
+				pkg := eolPackage()
+				g, err := graph.NewGraph(t.Context(), graph.GraphConfig{Packages: []graph.Package{pkg}, AsOf: edgeWeightsAsOf, KeepEdge: tc.keep})
+				require.NoError(t, err)
+				assert.Equal(t, tc.from100, nvrs(slices.Collect(g.From(pkg.Nodes[0]))))
+				assert.Equal(t, tc.from110, nvrs(slices.Collect(g.From(pkg.Nodes[2]))))
+				assert.Equal(t, tc.planned, plannedTo(t, g, pkg.Nodes[0]))
+				assert.Equal(t, tc.preferred, plannedTo(t, g, pkg.Nodes[2], prefer111))
+			})
+
+			t.Run("plan", func(t *testing.T) {
+				pkg := eolPackage()
+				g, err := graph.NewGraph(t.Context(), graph.GraphConfig{Packages: []graph.Package{pkg}, AsOf: edgeWeightsAsOf})
+				require.NoError(t, err)
+				var opts []graph.PlanOption
+				if tc.keep != nil {
+					opts = append(opts, graph.KeepEdges(tc.keep))
+				}
+				assert.Equal(t, tc.planned, plannedTo(t, g, pkg.Nodes[0], opts...))
+				assert.Equal(t, tc.preferred, plannedTo(t, g, pkg.Nodes[2], append(opts, prefer111)...))
+			})
+		})
+	}
+}
@@ -0,0 +1,88 @@
+// Package graphtest builds small update graphs for tests, as of AsOf, from
+// streams in full support and nodes released on the first of successive
+// months of 2024:
+//
+//	streams := graphtest.Streams(graph.MajorMinor{Major: 1, Minor: 0}, graph.MajorMinor{Major: 1, Minor: 1})
+//	streams[1].MinimumUpdateVersion = semver.MustParse("1.0.1")
+//	g := graphtest.New(t, graph.Package{Name: "foo", Streams: streams, Nodes: graphtest.Nodes("foo", "1.0.0", "1.0.1", "1.1.0")})
+package graphtest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/opencontainers/go-digest"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+// AsOf is the time graphs are built as of.
+var AsOf = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// FullSupport are the lifecycle dates of a stream that is in full support as
+// of AsOf, from 2024 until its maintenance in 2029 and its end of life in 2030.
+var FullSupport = graph.LifecycleDates{
+	FullSupport: graph.NewDate(2024, 1, 1),
+	Maintenance: graph.NewDate(2029, 1, 1),
+	EndOfLife:   graph.NewDate(2030, 1, 1),
+}
+
+// Streams returns a stream in FullSupport for each version.
+func Streams(versions ...graph.MajorMinor) []graph.VersionStream {
+	streams := make([]graph.VersionStream, 0, len(versions))
+	for _, v := range versions {
+		streams = append(streams, graph.VersionStream{Version: v, LifecycleDates: FullSupport})
+	}
+	return streams
+}
+
+// Nodes returns a node of the named package for each version, in order. The
+// i'th node is released on the first of the (i+1)'th month of 2024.
+func Nodes(name string, versions ...string) []*graph.Node {
+	nodes := make([]*graph.Node, 0, len(versions))
+	for i, v := range versions {
+		nodes = append(nodes, &graph.Node{
+			Name:        name,
+			Version:     semver.MustParse(v),
+			ReleaseDate: time.Date(2024, time.Month(i+1), 1, 0, 0, 0, 0, time.UTC),
+		})
+	}
+	return nodes
+}
+
+// WithBundleImages sets the image reference of each node to an image in the
+// repository quay.io/example/<package>-bundle, whose digest is Digest of the
+// node's index, and returns the nodes.
+func WithBundleImages(nodes []*graph.Node) []*graph.Node {
+	for i, n := range nodes {
+		named, err := reference.ParseNormalizedNamed("quay.io/example/" + n.Name + "-bundle")
+		if err != nil {
+			panic(err)
+		}
+		ref, err := reference.WithDigest(named, Digest(i))
+		if err != nil {
+			panic(err)
+		}
+		n.ImageReference = ref
+	}
+	return nodes
+}
+
+// Digest returns a digest whose hex encoding repeats the last decimal digit
+// of i.
+func Digest(i int) digest.Digest {
+	return digest.Digest("sha256:" + strings.Repeat(fmt.Sprint(i%10), 64))
+}
+
+// New builds the graph of pkgs as of AsOf.
+func New(t testing.TB, pkgs ...graph.Package) *graph.Graph {
+	t.Helper()
+	g, err := graph.NewGraph(t.Context(), graph.GraphConfig{Packages: pkgs, AsOf: AsOf})
+	if err != nil {
+		t.Fatalf("error building graph: %v", err)
+	}
+	return g
+}
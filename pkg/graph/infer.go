@@ -0,0 +1,75 @@
+package graph
+
+import (
+	"slices"
+	"time"
+
+	"github.com/blang/semver/v4"
+)
+
+// InferredOpenEnded is the date used for the lifecycle boundaries of inferred
+// version streams that have not been reached yet because no later stream has
+// been released.
+var InferredOpenEnded = NewDate(9999, 12, 31)
+
+// InferVersionStreams derives version streams from the nodes of a package,
+// for packages that have no template. There is one stream for each
+// major.minor of the nodes, and its support window is inferred from the
+// release dates of the streams that follow it:
+//
+//   - Full Support begins on the stream's first release.
+//   - Maintenance begins on the first release of the next stream.
+//   - End of Life begins on the first release of the stream after that, so
+//     that the two newest streams are supported.
+//
+// Boundaries that no later stream has reached yet are InferredOpenEnded. Each
+// stream can be updated to from the .0 release of the stream before it in the
+// same major version.
+func InferVersionStreams(nodes []*Node) []VersionStream {
+	firstReleases := map[MajorMinor]time.Time{}
+	for _, n := range nodes {
+		mm := NewMajorMinorFromVersion(n.Version)
+		if first, ok := firstReleases[mm]; !ok || n.ReleaseDate.Before(first) {
+			firstReleases[mm] = n.ReleaseDate
+		}
+	}
+	versions := make([]MajorMinor, 0, len(firstReleases))
+	for mm := range firstReleases {
+		versions = append(versions, mm)
+	}
+	slices.SortFunc(versions, MajorMinor.Compare)
+
+	boundary := func(i int) Date {
+		if i >= len(versions) {
+			return InferredOpenEnded
+		}
+		return NewDate(firstReleases[versions[i]].UTC().Date())
+	}
+
+	streams := make([]VersionStream, 0, len(versions))
+	for i, mm := range versions {
+		minimumUpdate := mm
+		if i > 0 && versions[i-1].Major == mm.Major {
+			minimumUpdate = versions[i-1]
+		}
+		// A later stream may have been released first, such as a new
+		// major version released before the last minor of the previous
+		// one, so boundaries never precede the ones before them.
+		dates := LifecycleDates{FullSupport: boundary(i)}
+		dates.Maintenance = latestDate(dates.FullSupport, boundary(i+1))
+		dates.EndOfLife = latestDate(dates.Maintenance, boundary(i+2))
+		streams = append(streams, VersionStream{
+			Version:              mm,
+			MinimumUpdateVersion: semver.Version{Major: minimumUpdate.Major, Minor: minimumUpdate.Minor},
+			LifecycleDates:       dates,
+		})
+	}
+	return streams
+}
+
+func latestDate(a, b Date) Date {
+	if b.t.After(a.t) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,91 @@
+package graph_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInferVersionStreams(t *testing.T) {
+	released := func(v string, year int, month time.Month) *graph.Node {
+		return &graph.Node{
+			Name:        "foo",
+			Version:     semver.MustParse(v),
+			ReleaseDate: time.Date(year, month, 15, 12, 0, 0, 0, time.UTC),
+		}
+	}
+	nodes := []*graph.Node{
+		released("1.0.1", 2023, 3),
+		released("1.0.0", 2023, 1),
+		released("1.1.0", 2023, 6),
+		released("1.1.1", 2024, 2),
+		released("1.2.0", 2024, 1),
+		released("2.0.0", 2024, 6),
+	}
+
+	streams := graph.InferVersionStreams(nodes)
+	assert.Equal(t, []graph.VersionStream{
+		{
+			Version:              graph.MajorMinor{Major: 1, Minor: 0},
+			MinimumUpdateVersion: semver.MustParse("1.0.0"),
+			LifecycleDates: graph.LifecycleDates{
+				FullSupport: graph.NewDate(2023, 1, 15),
+				Maintenance: graph.NewDate(2023, 6, 15),
+				EndOfLife:   graph.NewDate(2024, 1, 15),
+			},
+		},
+		{
+			Version:              graph.MajorMinor{Major: 1, Minor: 1},
+			MinimumUpdateVersion: semver.MustParse("1.0.0"),
+			LifecycleDates: graph.LifecycleDates{
+				FullSupport: graph.NewDate(2023, 6, 15),
+				Maintenance: graph.NewDate(2024, 1, 15),
+				EndOfLife:   graph.NewDate(2024, 6, 15),
+			},
+		},
+		{
+			Version:              graph.MajorMinor{Major: 1, Minor: 2},
+			MinimumUpdateVersion: semver.MustParse("1.1.0"),
+			LifecycleDates: graph.LifecycleDates{
+				FullSupport: graph.NewDate(2024, 1, 15),
+				Maintenance: graph.NewDate(2024, 6, 15),
+				EndOfLife:   graph.InferredOpenEnded,
+			},
+		},
+		{
+			Version:              graph.MajorMinor{Major: 2, Minor: 0},
+			MinimumUpdateVersion: semver.MustParse("2.0.0"),
+			LifecycleDates: graph.LifecycleDates{
+				FullSupport: graph.NewDate(2024, 6, 15),
+				Maintenance: graph.InferredOpenEnded,
+				EndOfLife:   graph.InferredOpenEnded,
+			},
+		},
+	}, streams)
+
+	// The inferred streams build a graph in which the newest streams are
+	// supported.
+	g, err := graph.NewGraph(t.Context(), graph.GraphConfig{
+		Packages: []graph.Package{{Name: "foo", Streams: streams, Nodes: nodes}},
+		AsOf:     time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC),
+	})
+	require.NoError(t, err)
+	phases := map[string]graph.LifecyclePhase{}
+	for n := range g.NodesMatching(graph.AllNodes()) {
+		phases[n.Version.String()] = n.LifecyclePhase
+	}
+	assert.Equal(t, map[string]graph.LifecyclePhase{
+		"1.0.0": graph.LifecyclePhaseEndOfLife,
+		"1.0.1": graph.LifecyclePhaseEndOfLife,
+		"1.1.0": graph.LifecyclePhaseEndOfLife,
+		"1.1.1": graph.LifecyclePhaseEndOfLife,
+		"1.2.0": graph.LifecyclePhaseMaintenance,
+		"2.0.0": graph.LifecyclePhaseFullSupport,
+	}, phases)
+
+	assert.Empty(t, graph.InferVersionStreams(nil))
+}
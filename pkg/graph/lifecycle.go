@@ -87,6 +87,64 @@ func (l LifecycleDates) Phase(asOf time.Time) LifecyclePhase {
 	return LifecycleExtensionPhase(len(l.Extensions))
 }
 
+// NextPhase returns the phase that follows the phase as of asOf, and the date
+// on which it begins. It reports false if the phase as of asOf is End of Life.
+func (l LifecycleDates) NextPhase(asOf time.Time) (LifecyclePhase, Date, bool) {
+	switch phase := l.Phase(asOf); phase {
+	case LifecyclePhasePreGA:
+		return LifecyclePhaseFullSupport, l.FullSupport, true
+	case LifecyclePhaseFullSupport:
+		return LifecyclePhaseMaintenance, l.Maintenance, true
+	case LifecyclePhaseEndOfLife:
+		return LifeCyclePhaseUnknown, Date{}, false
+	default:
+		// Maintenance is followed by the first extension, and each extension
+		// by the next, until the last is followed by End of Life.
+		i := int(phase - LifecyclePhaseMaintenance)
+		if i < len(l.Extensions) {
+			return LifecycleExtensionPhase(i + 1), l.Extensions[i], true
+		}
+		return LifecyclePhaseEndOfLife, l.EndOfLife, true
+	}
+}
+
+// PhaseBoundaries are the dates on which a node's lifecycle phase as of a
+// date began and ends.
+type PhaseBoundaries struct {
+	// Start is the date on which the phase began. It is nil for Pre-GA.
+	Start *Date `json:"start,omitempty"`
+
+	// End is the date on which the next phase begins. It is nil for End of
+	// Life.
+	End *Date `json:"end,omitempty"`
+
+	// DaysRemaining is the number of days from the date as of which the
+	// phase was computed until End, rounded up. It is nil for End of Life.
+	DaysRemaining *int `json:"daysRemaining,omitempty"`
+}
+
+// Boundaries returns the boundaries of the phase as of asOf.
+func (l LifecycleDates) Boundaries(asOf time.Time) PhaseBoundaries {
+	var b PhaseBoundaries
+	switch phase := l.Phase(asOf); phase {
+	case LifecyclePhasePreGA:
+	case LifecyclePhaseFullSupport:
+		b.Start = &l.FullSupport
+	case LifecyclePhaseMaintenance:
+		b.Start = &l.Maintenance
+	case LifecyclePhaseEndOfLife:
+		b.Start = &l.EndOfLife
+	default:
+		start := l.Extensions[phase-LifecyclePhaseMaintenance-1]
+		b.Start = &start
+	}
+	if _, end, ok := l.NextPhase(asOf); ok {
+		days := int(math.Ceil(end.t.Sub(asOf).Hours() / 24))
+		b.End, b.DaysRemaining = &end, &days
+	}
+	return b
+}
+
 type Date struct {
 	t time.Time
 }
@@ -111,3 +169,8 @@ func (d *Date) UnmarshalJSON(data []byte) error {
 func (d Date) MarshalJSON() ([]byte, error) {
 	return json.Marshal(d.t.Format("2006-01-02"))
 }
+
+// Time returns the start of the date, in UTC.
+func (d Date) Time() time.Time {
+	return d.t
+}
@@ -3,8 +3,9 @@ package graph_test
 import (
 	"fmt"
 	"testing"
+	"time"
 
-	"github.com/joelanford/extensiondb/examples/cincinnati/pkg/graph"
+	"github.com/joelanford/extensiondb/pkg/graph"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -425,3 +426,132 @@ func TestLifecyclePhase_String(t *testing.T) {
 		})
 	}
 }
+
+func TestLifecycleDates_NextPhase(t *testing.T) {
+	dates := graph.LifecycleDates{
+		FullSupport: graph.NewDate(2024, 1, 1),
+		Maintenance: graph.NewDate(2024, 6, 1),
+		Extensions:  []graph.Date{graph.NewDate(2025, 1, 1), graph.NewDate(2025, 6, 1)},
+		EndOfLife:   graph.NewDate(2026, 1, 1),
+	}
+
+	tests := []struct {
+		name          string
+		asOf          graph.Date
+		expectedPhase graph.LifecyclePhase
+		expectedDate  graph.Date
+	}{
+		{
+			name:          "Pre-GA is followed by Full Support",
+			asOf:          graph.NewDate(2023, 12, 1),
+			expectedPhase: graph.LifecyclePhaseFullSupport,
+			expectedDate:  dates.FullSupport,
+		},
+		{
+			name:          "Full Support is followed by Maintenance",
+			asOf:          graph.NewDate(2024, 1, 1),
+			expectedPhase: graph.LifecyclePhaseMaintenance,
+			expectedDate:  dates.Maintenance,
+		},
+		{
+			name:          "Maintenance is followed by the first extension",
+			asOf:          graph.NewDate(2024, 7, 1),
+			expectedPhase: graph.LifecycleExtensionPhase(1),
+			expectedDate:  dates.Extensions[0],
+		},
+		{
+			name:          "An extension is followed by the next extension",
+			asOf:          graph.NewDate(2025, 2, 1),
+			expectedPhase: graph.LifecycleExtensionPhase(2),
+			expectedDate:  dates.Extensions[1],
+		},
+		{
+			name:          "The last extension is followed by End of Life",
+			asOf:          graph.NewDate(2025, 7, 1),
+			expectedPhase: graph.LifecyclePhaseEndOfLife,
+			expectedDate:  dates.EndOfLife,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			phase, date, ok := dates.NextPhase(test.asOf.Time())
+			assert.True(t, ok)
+			assert.Equal(t, test.expectedPhase, phase)
+			assert.Equal(t, test.expectedDate, date)
+		})
+	}
+
+	t.Run("Maintenance without extensions is followed by End of Life", func(t *testing.T) {
+		noExtensions := dates
+		noExtensions.Extensions = nil
+		phase, date, ok := noExtensions.NextPhase(graph.NewDate(2024, 7, 1).Time())
+		assert.True(t, ok)
+		assert.Equal(t, graph.LifecyclePhaseEndOfLife, phase)
+		assert.Equal(t, dates.EndOfLife, date)
+	})
+
+	t.Run("End of Life is the last phase", func(t *testing.T) {
+		_, _, ok := dates.NextPhase(graph.NewDate(2026, 2, 1).Time())
+		assert.False(t, ok)
+	})
+}
+
+func TestLifecycleDates_Boundaries(t *testing.T) {
+	dates := graph.LifecycleDates{
+		FullSupport: graph.NewDate(2024, 1, 1),
+		Maintenance: graph.NewDate(2024, 6, 1),
+		Extensions:  []graph.Date{graph.NewDate(2025, 1, 1), graph.NewDate(2025, 6, 1)},
+		EndOfLife:   graph.NewDate(2026, 1, 1),
+	}
+	date := func(d graph.Date) *graph.Date { return &d }
+	days := func(n int) *int { return &n }
+
+	tests := []struct {
+		name     string
+		asOf     time.Time
+		expected graph.PhaseBoundaries
+	}{
+		{
+			name:     "Pre-GA has no start",
+			asOf:     graph.NewDate(2023, 12, 1).Time(),
+			expected: graph.PhaseBoundaries{End: date(dates.FullSupport), DaysRemaining: days(31)},
+		},
+		{
+			name:     "Full Support",
+			asOf:     graph.NewDate(2024, 5, 31).Time(),
+			expected: graph.PhaseBoundaries{Start: date(dates.FullSupport), End: date(dates.Maintenance), DaysRemaining: days(1)},
+		},
+		{
+			name:     "Partial days are rounded up",
+			asOf:     graph.NewDate(2024, 5, 30).Time().Add(time.Hour),
+			expected: graph.PhaseBoundaries{Start: date(dates.FullSupport), End: date(dates.Maintenance), DaysRemaining: days(2)},
+		},
+		{
+			name:     "Maintenance",
+			asOf:     graph.NewDate(2024, 12, 1).Time(),
+			expected: graph.PhaseBoundaries{Start: date(dates.Maintenance), End: date(dates.Extensions[0]), DaysRemaining: days(31)},
+		},
+		{
+			name:     "The last extension",
+			asOf:     graph.NewDate(2025, 12, 1).Time(),
+			expected: graph.PhaseBoundaries{Start: date(dates.Extensions[1]), End: date(dates.EndOfLife), DaysRemaining: days(31)},
+		},
+		{
+			name:     "End of Life has no end",
+			asOf:     graph.NewDate(2026, 2, 1).Time(),
+			expected: graph.PhaseBoundaries{Start: date(dates.EndOfLife)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, dates.Boundaries(test.asOf))
+		})
+	}
+
+	n := &graph.Node{LifecyclePhase: graph.LifecyclePhaseMaintenance, PhaseBoundaries: dates.Boundaries(graph.NewDate(2024, 12, 1).Time())}
+	assert.Equal(t, "Maintenance until 2025-01-01", n.PhaseSummary())
+	n = &graph.Node{LifecyclePhase: graph.LifecyclePhaseEndOfLife, PhaseBoundaries: dates.Boundaries(graph.NewDate(2026, 2, 1).Time())}
+	assert.Equal(t, "End of Life since 2026-01-01", n.PhaseSummary())
+}
@@ -0,0 +1,127 @@
+package graph
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/pkg/util"
+	"github.com/opencontainers/go-digest"
+	"go.podman.io/image/v5/docker/reference"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+type Node struct {
+	Name           string
+	Version        semver.Version
+	Release        *string
+	ReleaseDate    time.Time
+	ImageReference reference.Canonical
+
+	// Verified is true if the image's signature and provenance attestations
+	// have been verified.
+	Verified bool
+
+	// Certified is true if the Red Hat Ecosystem Catalog reports the image
+	// as certified.
+	Certified bool
+
+	LifecyclePhase LifecyclePhase
+
+	// LifecycleDates are the lifecycle dates of the node's version stream.
+	LifecycleDates LifecycleDates
+
+	// PhaseBoundaries are the boundaries of LifecyclePhase, as of the date
+	// as of which the graph was built.
+	PhaseBoundaries PhaseBoundaries
+
+	SupportedPlatformVersions      sets.Set[MajorMinor]
+	RequiresUpdatePlatformVersions sets.Set[MajorMinor]
+
+	// Catalogs are the catalogs whose latest contents ship the node's image.
+	Catalogs sets.Set[Catalog]
+
+	// Rebuilds are the older builds of the node's version, newest first,
+	// that were collapsed into the node by GraphConfig.CollapseRebuilds.
+	Rebuilds []*Node
+
+	// ContentFingerprint is the fingerprint of the content of the node's
+	// bundle, which is equal for builds that change nothing that they
+	// install. It is empty if the bundle was not fingerprinted.
+	ContentFingerprint digest.Digest
+
+	id     int64
+	idOnce sync.Once
+}
+
+// Catalog identifies a catalog image by its name and tag, such as
+// registry.redhat.io/redhat/certified-operator-index and v4.16.
+type Catalog struct {
+	Name string `json:"name"`
+	Tag  string `json:"tag"`
+}
+
+func (c Catalog) String() string {
+	return c.Name + ":" + c.Tag
+}
+
+// ParseCatalog parses a catalog of the form <name>:<tag>. Tags can't contain
+// colons, but names can, before a registry's port.
+func ParseCatalog(s string) (Catalog, error) {
+	i := strings.LastIndex(s, ":")
+	if i <= 0 || i == len(s)-1 || strings.Contains(s[i+1:], "/") {
+		return Catalog{}, fmt.Errorf("invalid catalog %q: expected <name>:<tag>", s)
+	}
+	return Catalog{Name: s[:i], Tag: s[i+1:]}, nil
+}
+
+func (n *Node) ID() int64 {
+	n.idOnce.Do(func() {
+		n.id = int64(util.HashString(n.NVR()))
+	})
+	return n.id
+}
+
+func (n *Node) NVR() string {
+	return fmt.Sprintf("%s.v%s", n.Name, n.VR())
+}
+
+func (n *Node) VR() string {
+	return n.VersionRelease().String()
+}
+
+func (n *Node) VersionRelease() VersionRelease {
+	return NewVersionRelease(n.Version, n.Release)
+}
+
+// PhaseSummary describes the node's lifecycle phase and when it ends, such as
+// "Maintenance until 2025-06-30", or since when it is End of Life.
+func (n *Node) PhaseSummary() string {
+	switch b := n.PhaseBoundaries; {
+	case b.End != nil:
+		return fmt.Sprintf("%s until %s", n.LifecyclePhase, b.End.t.Format(time.DateOnly))
+	case b.Start != nil:
+		return fmt.Sprintf("%s since %s", n.LifecyclePhase, b.Start.t.Format(time.DateOnly))
+	}
+	return n.LifecyclePhase.String()
+}
+
+func (n *Node) Compare(other *Node) int {
+	return n.VersionRelease().Compare(other.VersionRelease())
+}
+
+// compareNodes orders nodes by name, version, release, and release date, and
+// then by their versions as strings, which differ only in build metadata. A
+// graph's nodes have distinct NVRs, so this is the order in which a graph's
+// iteration methods yield them.
+func compareNodes(a, b *Node) int {
+	return cmp.Or(
+		cmp.Compare(a.Name, b.Name),
+		a.Compare(b),
+		a.ReleaseDate.Compare(b.ReleaseDate),
+		cmp.Compare(a.VR(), b.VR()),
+	)
+}
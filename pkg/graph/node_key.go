@@ -0,0 +1,123 @@
+package graph
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver/v4"
+	"github.com/opencontainers/go-digest"
+)
+
+// NodeKey identifies a node by its package, version, release, and image
+// digest. Unlike a node's ID, which only has to be unique within a graph, it
+// is stable across graphs built by different processes and at different
+// times, so that the nodes of encoded graphs, diffs, and caches can be
+// correlated.
+//
+// Keys are comparable, so they can be used as map keys.
+type NodeKey struct {
+	Package string
+
+	// Version is the node's semantic version, including any build
+	// metadata.
+	Version string
+	Release string
+
+	// Digest is the digest of the node's image, or empty if the node has
+	// no image reference.
+	Digest digest.Digest
+}
+
+// Key returns the node's key.
+func (n *Node) Key() NodeKey {
+	k := NodeKey{Package: n.Name, Version: n.Version.String()}
+	if n.Release != nil {
+		k.Release = *n.Release
+	}
+	if n.ImageReference != nil {
+		k.Digest = n.ImageReference.Digest()
+	}
+	return k
+}
+
+// String returns the key as <package>.v<version>[_<release>][@<digest>], the
+// node's NVR followed by its digest, which ParseNodeKey parses.
+func (k NodeKey) String() string {
+	s := fmt.Sprintf("%s.v%s", k.Package, k.Version)
+	if k.Release != "" {
+		s += "_" + k.Release
+	}
+	if k.Digest != "" {
+		s += "@" + k.Digest.String()
+	}
+	return s
+}
+
+// ParseNodeKey parses a key written by NodeKey.String.
+func ParseNodeKey(s string) (NodeKey, error) {
+	var k NodeKey
+	nvr := s
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		dgst, err := digest.Parse(s[i+1:])
+		if err != nil {
+			return NodeKey{}, fmt.Errorf("invalid node key %q: %w", s, err)
+		}
+		nvr, k.Digest = s[:i], dgst
+	}
+
+	// Package names can contain ".v", but versions can't, so the version
+	// starts after the last one that is followed by a valid version.
+	for i := strings.LastIndex(nvr, ".v"); i > 0; i = strings.LastIndex(nvr[:i], ".v") {
+		version, release, _ := strings.Cut(nvr[i+2:], "_")
+		if _, err := semver.Parse(version); err != nil {
+			continue
+		}
+		k.Package, k.Version, k.Release = nvr[:i], version, release
+		return k, nil
+	}
+	return NodeKey{}, fmt.Errorf("invalid node key %q: expected <package>.v<version>[_<release>][@<digest>]", s)
+}
+
+func (k NodeKey) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *NodeKey) UnmarshalText(data []byte) error {
+	parsed, err := ParseNodeKey(string(data))
+	if err != nil {
+		return err
+	}
+	*k = parsed
+	return nil
+}
+
+// Compare orders keys by package, version and release, and then digest.
+func (k NodeKey) Compare(other NodeKey) int {
+	return cmp.Or(
+		cmp.Compare(k.Package, other.Package),
+		k.versionRelease().Compare(other.versionRelease()),
+		cmp.Compare(k.Version, other.Version),
+		cmp.Compare(k.Digest, other.Digest),
+	)
+}
+
+func (k NodeKey) versionRelease() VersionRelease {
+	// The versions of keys are valid, whether they are a node's or parsed.
+	v, _ := semver.Parse(k.Version)
+	return VersionRelease{Version: v, Release: k.Release}
+}
+
+// NodeForKey returns the node with a key, or nil if there is none. Nodes
+// whose rebuilds were collapsed are found by their own keys only; use
+// NodeForDigest to find them by the digests of their rebuilds.
+func (g *Graph) NodeForKey(k NodeKey) *Node {
+	return g.byKey[k]
+}
+
+func (g *Graph) indexKeys() {
+	g.byKey = map[NodeKey]*Node{}
+	for n := range g.NodesMatching(AllNodes()) {
+		g.byKey[n.Key()] = n
+	}
+}
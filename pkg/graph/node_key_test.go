@@ -0,0 +1,97 @@
+package graph_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+func TestNodeKey(t *testing.T) {
+	ref, err := reference.ParseNamed("quay.io/example/foo-bundle@sha256:" + strings.Repeat("a", 64))
+	require.NoError(t, err)
+	release := "2"
+	n := &graph.Node{Name: "foo.v2-operator", Version: semver.MustParse("1.2.3-rc.1+build.5"), Release: &release, ImageReference: ref.(reference.Canonical)}
+
+	k := n.Key()
+	assert.Equal(t, graph.NodeKey{Package: "foo.v2-operator", Version: "1.2.3-rc.1+build.5", Release: "2", Digest: ref.(reference.Canonical).Digest()}, k)
+	assert.Equal(t, "foo.v2-operator.v1.2.3-rc.1+build.5_2@sha256:"+strings.Repeat("a", 64), k.String())
+
+	for _, want := range []graph.NodeKey{
+		k,
+		{Package: "foo", Version: "1.0.0"},
+		{Package: "foo", Version: "1.0.0", Digest: digest.Digest("sha256:" + strings.Repeat("b", 64))},
+	} {
+		got, err := graph.ParseNodeKey(want.String())
+		require.NoError(t, err, want.String())
+		assert.Equal(t, want, got)
+
+		data, err := json.Marshal(map[graph.NodeKey]int{want: 1})
+		require.NoError(t, err)
+		var decoded map[graph.NodeKey]int
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, map[graph.NodeKey]int{want: 1}, decoded)
+	}
+
+	for _, s := range []string{"", "foo", "foo.v1", "foo.vx.y.z", "foo.v1.0.0@sha256:abc"} {
+		_, err := graph.ParseNodeKey(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestNodeForKeyAndDiff(t *testing.T) {
+	phases := []graph.LifecyclePhase{graph.LifecyclePhaseMaintenance, graph.LifecyclePhaseFullSupport}
+	pkg := phasedPackage(phases, 2)
+	from, err := graph.NewGraph(t.Context(), graph.GraphConfig{Packages: []graph.Package{pkg}, AsOf: edgeWeightsAsOf})
+	require.NoError(t, err)
+
+	// A graph decoded in another process has other nodes with the same
+	// keys, and no differences.
+	var buf bytes.Buffer
+	require.NoError(t, from.Encode(&buf))
+	decoded, err := graph.DecodeGraph(t.Context(), &buf)
+	require.NoError(t, err)
+	for _, n := range pkg.Nodes {
+		got := decoded.NodeForKey(n.Key())
+		require.NotNil(t, got, n.NVR())
+		assert.NotSame(t, n, got)
+		assert.Equal(t, n.NVR(), got.NVR())
+	}
+	assert.Nil(t, decoded.NodeForKey(graph.NodeKey{Package: "foo", Version: "9.0.0"}))
+	assert.True(t, graph.Diff(from, decoded).Empty())
+
+	// Adding a node adds it and its edges, and reweights the edges to the
+	// nodes it outranks.
+	last := pkg.Nodes[len(pkg.Nodes)-1]
+	pkg.Nodes = append(pkg.Nodes, &graph.Node{Name: "foo", Version: semver.MustParse("1.1.2"), ReleaseDate: last.ReleaseDate.Add(time.Hour)})
+	to, err := graph.NewGraph(t.Context(), graph.GraphConfig{Packages: []graph.Package{pkg}, AsOf: edgeWeightsAsOf})
+	require.NoError(t, err)
+	d := graph.Diff(decoded, to)
+	assert.False(t, d.Empty())
+	assert.Equal(t, []graph.NodeKey{{Package: "foo", Version: "1.1.2"}}, d.AddedNodes)
+	assert.Empty(t, d.RemovedNodes)
+	var added []string
+	for _, e := range d.AddedEdges {
+		assert.Equal(t, "1.1.2", e.To.Version)
+		added = append(added, e.From.Version)
+	}
+	assert.Equal(t, []string{"1.0.0", "1.0.1", "1.1.0", "1.1.1"}, added)
+	assert.Empty(t, d.RemovedEdges)
+	assert.NotEmpty(t, d.ReweightedEdges)
+	for _, e := range d.ReweightedEdges {
+		assert.Less(t, e.FromWeight, e.ToWeight, "%s -> %s", e.From, e.To)
+	}
+
+	d = graph.Diff(to, decoded)
+	assert.Equal(t, []graph.NodeKey{{Package: "foo", Version: "1.1.2"}}, d.RemovedNodes)
+	assert.Len(t, d.RemovedEdges, 4)
+	assert.Empty(t, d.AddedEdges)
+}
@@ -0,0 +1,164 @@
+package graph
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/blang/semver/v4"
+)
+
+type NodePredicate func(*Graph, *Node) bool
+
+func AllNodes() NodePredicate {
+	return func(_ *Graph, _ *Node) bool {
+		return true
+	}
+}
+
+func PackageNodes(pkgName string) NodePredicate {
+	return func(g *Graph, node *Node) bool {
+		return node.Name == pkgName
+	}
+}
+
+func NodeInRange(rng semver.Range) NodePredicate {
+	return func(_ *Graph, actual *Node) bool {
+		return rng(actual.Version)
+	}
+}
+
+// VerifiedNodes matches nodes whose image signature and provenance
+// attestations have been verified.
+func VerifiedNodes() NodePredicate {
+	return func(_ *Graph, node *Node) bool {
+		return node.Verified
+	}
+}
+
+// InCatalog matches nodes that ship in the latest contents of a catalog, or
+// whose collapsed rebuilds do.
+func InCatalog(name, tag string) NodePredicate {
+	c := Catalog{Name: name, Tag: tag}
+	return func(_ *Graph, node *Node) bool {
+		return slices.ContainsFunc(append([]*Node{node}, node.Rebuilds...), func(n *Node) bool {
+			return n.Catalogs.Has(c)
+		})
+	}
+}
+
+// CertifiedNodes matches nodes whose image is certified in the Red Hat
+// Ecosystem Catalog.
+func CertifiedNodes() NodePredicate {
+	return func(_ *Graph, node *Node) bool {
+		return node.Certified
+	}
+}
+
+// BuiltBetween matches nodes released at or after from and before to. A zero
+// from or to leaves that end of the window open. Nodes without a release date
+// never match.
+func BuiltBetween(from, to time.Time) NodePredicate {
+	return func(_ *Graph, node *Node) bool {
+		if node.ReleaseDate.IsZero() {
+			return false
+		}
+		return (from.IsZero() || !node.ReleaseDate.Before(from)) && (to.IsZero() || node.ReleaseDate.Before(to))
+	}
+}
+
+// OlderThan matches nodes released more than d before the time the graph was
+// built as of. Nodes without a release date never match.
+func OlderThan(d time.Duration) NodePredicate {
+	return func(g *Graph, node *Node) bool {
+		return !node.ReleaseDate.IsZero() && node.ReleaseDate.Before(g.AsOf().Add(-d))
+	}
+}
+
+type EdgePredicate func(*Graph, *Node, *Node, float64) bool
+
+func AllEdges() EdgePredicate {
+	return func(*Graph, *Node, *Node, float64) bool { return true }
+}
+
+// AndEdges matches edges that match every one of ps, so that edge rules
+// such as ForbidEOLTargets and ForbidCrossMinorIntoEOL can be combined.
+func AndEdges(ps ...EdgePredicate) EdgePredicate {
+	return func(graph *Graph, from, to *Node, weight float64) bool {
+		for _, p := range ps {
+			if !p(graph, from, to, weight) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// ForbidEOLTargets matches edges to nodes that are not end of life, so that
+// updates never go to or through end of life nodes. Updates from them are
+// still allowed, so that clusters on them can leave.
+func ForbidEOLTargets() EdgePredicate {
+	return func(_ *Graph, _, to *Node, _ float64) bool {
+		return to.LifecyclePhase != LifecyclePhaseEndOfLife
+	}
+}
+
+// ForbidCrossMinorIntoEOL matches edges other than those into end of life
+// nodes from a different major.minor version, so that updates never move into
+// an end of life stream while updates within one, such as to its latest
+// z-stream, are still allowed.
+func ForbidCrossMinorIntoEOL() EdgePredicate {
+	return func(_ *Graph, from, to *Node, _ float64) bool {
+		return to.LifecyclePhase != LifecyclePhaseEndOfLife ||
+			NewMajorMinorFromVersion(from.Version) == NewMajorMinorFromVersion(to.Version)
+	}
+}
+
+func AndNodes(ps ...NodePredicate) NodePredicate {
+	return func(graph *Graph, node *Node) bool {
+		for _, p := range ps {
+			if !p(graph, node) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func OrNodes(ps ...NodePredicate) NodePredicate {
+	return func(graph *Graph, node *Node) bool {
+		for _, p := range ps {
+			if p(graph, node) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// NodesUpToMajorMinor matches nodes whose major.minor version is less than or
+// equal to maxMM.
+func NodesUpToMajorMinor(maxMM MajorMinor) NodePredicate {
+	return func(_ *Graph, n *Node) bool {
+		return NewMajorMinorFromVersion(n.Version).Compare(maxMM) <= 0
+	}
+}
+
+// ChannelNodes matches the nodes of a channel: either "stable" (every node) or
+// "stable-<major>.<minor>" (nodes up to and including that major.minor
+// version).
+func ChannelNodes(channel string) (NodePredicate, error) {
+	if channel == "stable" {
+		return AllNodes(), nil
+	}
+	mmString, ok := strings.CutPrefix(channel, "stable-")
+	if !ok {
+		return nil, fmt.Errorf("unknown channel %q", channel)
+	}
+	maxMM, err := NewMajorMinorFromString(mmString)
+	if err != nil {
+		return nil, fmt.Errorf("unknown channel %q: %v", channel, err)
+	}
+	return NodesUpToMajorMinor(maxMM), nil
+}
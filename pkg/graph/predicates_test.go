@@ -0,0 +1,105 @@
+package graph_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestParseCatalog(t *testing.T) {
+	c, err := graph.ParseCatalog("registry.redhat.io/redhat/certified-operator-index:v4.16")
+	require.NoError(t, err)
+	assert.Equal(t, graph.Catalog{Name: "registry.redhat.io/redhat/certified-operator-index", Tag: "v4.16"}, c)
+	assert.Equal(t, "registry.redhat.io/redhat/certified-operator-index:v4.16", c.String())
+
+	c, err = graph.ParseCatalog("localhost:5000/index:latest")
+	require.NoError(t, err)
+	assert.Equal(t, graph.Catalog{Name: "localhost:5000/index", Tag: "latest"}, c)
+
+	for _, s := range []string{"", "index", "index:", ":v1", "localhost:5000/index"} {
+		_, err := graph.ParseCatalog(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestInCatalog(t *testing.T) {
+	pkgs := catalogPackages(1, 1, 3)
+	nodes := pkgs[0].Nodes
+	nodes[0].Catalogs = sets.New(graph.Catalog{Name: "registry.example.com/index", Tag: "v1"})
+	nodes[1].Catalogs = sets.New(graph.Catalog{Name: "registry.example.com/index", Tag: "v2"})
+	g, err := graph.NewGraph(t.Context(), graph.GraphConfig{Packages: pkgs, AsOf: edgeWeightsAsOf})
+	require.NoError(t, err)
+
+	inV1 := graph.InCatalog("registry.example.com/index", "v1")
+	assert.True(t, inV1(g, nodes[0]))
+	assert.False(t, inV1(g, nodes[1]))
+	assert.False(t, inV1(g, nodes[2]), "nodes without catalogs are in none")
+
+	// A node is in the catalogs of its rebuilds.
+	nodes[2].Rebuilds = []*graph.Node{nodes[0]}
+	assert.True(t, inV1(g, nodes[2]))
+}
+
+func TestBuiltBetween(t *testing.T) {
+	pkgs := catalogPackages(1, 1, 3)
+	nodes := pkgs[0].Nodes
+	g, err := graph.NewGraph(t.Context(), graph.GraphConfig{Packages: pkgs, AsOf: edgeWeightsAsOf})
+	require.NoError(t, err)
+
+	match := func(p graph.NodePredicate) []bool {
+		var matched []bool
+		for _, n := range nodes {
+			matched = append(matched, p(g, n))
+		}
+		return matched
+	}
+	assert.Equal(t, []bool{false, true, false}, match(graph.BuiltBetween(nodes[1].ReleaseDate, nodes[2].ReleaseDate)))
+	assert.Equal(t, []bool{false, true, true}, match(graph.BuiltBetween(nodes[1].ReleaseDate, time.Time{})))
+	assert.Equal(t, []bool{true, false, false}, match(graph.BuiltBetween(time.Time{}, nodes[1].ReleaseDate)))
+
+	// Nodes are compared to the time the graph is built as of.
+	age := edgeWeightsAsOf.Sub(nodes[1].ReleaseDate)
+	assert.Equal(t, []bool{true, false, false}, match(graph.OlderThan(age)))
+	assert.Equal(t, []bool{false, false, false}, match(graph.OlderThan(age+time.Hour)))
+
+	nodes[0].ReleaseDate = time.Time{}
+	assert.False(t, graph.BuiltBetween(time.Time{}, time.Time{})(g, nodes[0]), "nodes without a release date never match")
+	assert.False(t, graph.OlderThan(0)(g, nodes[0]), "nodes without a release date never match")
+}
+
+func TestReachableFrom(t *testing.T) {
+	pkgs := catalogPackages(1, 2, 3)
+	nodes := pkgs[0].Nodes
+	g, err := graph.NewGraph(t.Context(), graph.GraphConfig{Packages: pkgs, AsOf: edgeWeightsAsOf})
+	require.NoError(t, err)
+	isNode := func(want *graph.Node) graph.NodePredicate {
+		return func(_ *graph.Graph, n *graph.Node) bool { return n == want }
+	}
+	isNot := func(skip *graph.Node) graph.NodePredicate {
+		return func(_ *graph.Graph, n *graph.Node) bool { return n != skip }
+	}
+	match := func(p graph.NodePredicate) []bool {
+		var matched []bool
+		for _, n := range nodes {
+			matched = append(matched, p(g, n))
+		}
+		return matched
+	}
+
+	for _, from := range nodes {
+		want := make([]bool, len(nodes))
+		for i, to := range nodes {
+			_, _, want[i] = g.ShortestPath(from, to)
+		}
+		assert.Equal(t, want, match(g.ReachableFrom(isNode(from), graph.AllNodes())), from.Version)
+	}
+	assert.Equal(t, []bool{false, true, true, false, false, false}, match(g.ReachableFrom(isNode(nodes[1]), graph.AllNodes())))
+
+	// Nodes that don't match within are neither matched nor started from.
+	assert.Equal(t, []bool{true, false, true, false, false, false}, match(g.ReachableFrom(isNode(nodes[0]), isNot(nodes[1]))))
+	assert.Equal(t, []bool{false, false, false, false, false, false}, match(g.ReachableFrom(isNode(nodes[1]), isNot(nodes[1]))))
+}
@@ -0,0 +1,117 @@
+package graph
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Ranking orders the nodes of a package from best to worst, which sets the
+// weights of the edges to them. Updates to a node weigh more than updates to
+// the nodes ranked before it in its tier, and more than any path through the
+// nodes of better tiers.
+type Ranking interface {
+	// Tiers returns the nodes of a package, which have their lifecycle
+	// phases set, grouped into tiers from best to worst, each ordered from
+	// best to worst. Every node must be in exactly one tier.
+	Tiers(nodes []*Node) [][]*Node
+}
+
+// RankingFunc adapts a function to a Ranking.
+type RankingFunc func(nodes []*Node) [][]*Node
+
+func (f RankingFunc) Tiers(nodes []*Node) [][]*Node {
+	return f(nodes)
+}
+
+// PhaseFirst ranks nodes by lifecycle phase and then by version, with a tier
+// for each phase, so that a node of a worse phase is never preferred over a
+// node of a better one, however much newer it is. It is the default ranking.
+func PhaseFirst() Ranking {
+	return RankingFunc(func(nodes []*Node) [][]*Node {
+		nodes = slices.SortedFunc(slices.Values(nodes), func(a, b *Node) int {
+			return cmp.Or(b.LifecyclePhase.Compare(a.LifecyclePhase), b.Compare(a))
+		})
+		return tiersBy(nodes, func(a, b *Node) bool { return a.LifecyclePhase == b.LifecyclePhase })
+	})
+}
+
+// NewestFirst ranks nodes by version alone, for users who prefer the newest
+// updates over those with the best support, such as staying on a newer
+// Maintenance stream rather than moving back to an older Full Support one.
+// End of life nodes are still ranked after all others. It is
+// Weighted{Recency: 1}.
+func NewestFirst() Ranking {
+	return Weighted{Recency: 1}
+}
+
+// Weighted ranks nodes by a weighted sum of how many of the lifecycle phases
+// of the package's nodes are better than theirs and how many of the package's
+// nodes are newer than them, in a single tier. A node of a worse phase is
+// preferred over a node of a better one when it is newer by more than
+// Phase/Recency nodes per phase between them, so that, for example,
+// Weighted{Phase: 3, Recency: 1} prefers a Maintenance node over Full
+// Support nodes that are more than three nodes older. Ties are ranked by
+// version.
+//
+// End of life nodes are ranked in a tier of their own after all others, so
+// that no weighting prefers them over supported nodes.
+type Weighted struct {
+	// Phase is the score of each lifecycle phase that is better than a
+	// node's.
+	Phase float64
+
+	// Recency is the score of each node that is newer than a node.
+	Recency float64
+}
+
+func (w Weighted) Tiers(nodes []*Node) [][]*Node {
+	var supported, eol []*Node
+	for _, n := range nodes {
+		if n.LifecyclePhase == LifecyclePhaseEndOfLife {
+			eol = append(eol, n)
+		} else {
+			supported = append(supported, n)
+		}
+	}
+
+	byVersion := slices.SortedFunc(slices.Values(supported), func(a, b *Node) int { return b.Compare(a) })
+	newer := make(map[*Node]int, len(byVersion))
+	for i, n := range byVersion {
+		if i > 0 && n.Compare(byVersion[i-1]) == 0 {
+			newer[n] = newer[byVersion[i-1]]
+			continue
+		}
+		newer[n] = i
+	}
+
+	// Phases are numbered from best to worst.
+	var phases []LifecyclePhase
+	for _, n := range supported {
+		phases = append(phases, n.LifecyclePhase)
+	}
+	slices.Sort(phases)
+	phases = slices.Compact(phases)
+	score := func(n *Node) float64 {
+		return w.Phase*float64(slices.Index(phases, n.LifecyclePhase)) + w.Recency*float64(newer[n])
+	}
+	slices.SortStableFunc(byVersion, func(a, b *Node) int { return cmp.Compare(score(a), score(b)) })
+
+	tiers := [][]*Node{byVersion}
+	if len(eol) > 0 {
+		tiers = append(tiers, slices.SortedFunc(slices.Values(eol), func(a, b *Node) int { return b.Compare(a) }))
+	}
+	return tiers
+}
+
+// tiersBy splits nodes into runs of consecutive nodes that are in the same
+// tier as the node before them.
+func tiersBy(nodes []*Node, sameTier func(a, b *Node) bool) [][]*Node {
+	var tiers [][]*Node
+	for i, n := range nodes {
+		if i == 0 || !sameTier(nodes[i-1], n) {
+			tiers = append(tiers, nil)
+		}
+		tiers[len(tiers)-1] = append(tiers[len(tiers)-1], n)
+	}
+	return tiers
+}
@@ -0,0 +1,117 @@
+package graph_test
+
+import (
+	"testing"
+
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rankedWeights returns the weight of the incoming edges of each node that has
+// any, by NVR.
+func rankedWeights(t *testing.T, pkg graph.Package, ranking graph.Ranking) map[string]float64 {
+	t.Helper()
+	g, err := graph.NewGraph(t.Context(), graph.GraphConfig{Packages: []graph.Package{pkg}, AsOf: edgeWeightsAsOf, Ranking: ranking})
+	require.NoError(t, err)
+	weights := map[string]float64{}
+	for _, to := range pkg.Nodes {
+		for from := range g.To(to) {
+			weights[to.NVR()] = g.EdgeWeight(from, to)
+			break
+		}
+	}
+	return weights
+}
+
+func TestRanking(t *testing.T) {
+	// 1.0 is fully supported, and the newer 1.1 is in maintenance.
+	phases := []graph.LifecyclePhase{graph.LifecyclePhaseFullSupport, graph.LifecyclePhaseMaintenance}
+
+	for _, tc := range []struct {
+		name    string
+		ranking graph.Ranking
+		want    map[string]float64
+	}{
+		{
+			name: "default",
+			want: map[string]float64{"foo.v1.0.1": 0.01, "foo.v1.1.1": 0.04, "foo.v1.1.0": 0.05},
+		},
+		{
+			name:    "PhaseFirst",
+			ranking: graph.PhaseFirst(),
+			want:    map[string]float64{"foo.v1.0.1": 0.01, "foo.v1.1.1": 0.04, "foo.v1.1.0": 0.05},
+		},
+		{
+			name:    "NewestFirst",
+			ranking: graph.NewestFirst(),
+			want:    map[string]float64{"foo.v1.1.1": 0.01, "foo.v1.1.0": 0.02, "foo.v1.0.1": 0.03},
+		},
+		{
+			// 1.1.1 is two nodes newer than 1.0.1, which doesn't make up
+			// for a phase that weighs three nodes, and three nodes newer
+			// than 1.0.0, which ties and is ranked by version.
+			name:    "Weighted phase over recency",
+			ranking: graph.Weighted{Phase: 3, Recency: 1},
+			want:    map[string]float64{"foo.v1.0.1": 0.01, "foo.v1.1.1": 0.02, "foo.v1.1.0": 0.04},
+		},
+		{
+			name:    "Weighted evenly",
+			ranking: graph.Weighted{Phase: 1, Recency: 1},
+			want:    map[string]float64{"foo.v1.1.1": 0.01, "foo.v1.1.0": 0.02, "foo.v1.0.1": 0.03},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := rankedWeights(t, phasedPackage(phases, 2), tc.ranking)
+			require.Len(t, got, len(tc.want))
+			for nvr, w := range tc.want {
+				assert.InDelta(t, w, got[nvr], 1e-9, nvr)
+			}
+		})
+	}
+}
+
+func TestWeightedRanksEndOfLifeLast(t *testing.T) {
+	// 1.1 is end of life, so even a ranking by recency alone ranks it after
+	// every supported node, in a tier of its own.
+	phases := []graph.LifecyclePhase{graph.LifecyclePhaseFullSupport, graph.LifecyclePhaseEndOfLife}
+	got := rankedWeights(t, phasedPackage(phases, 2), graph.NewestFirst())
+	assert.InDelta(t, 0.01, got["foo.v1.0.1"], 1e-9)
+	assert.InDelta(t, 0.04, got["foo.v1.1.1"], 1e-9)
+	assert.InDelta(t, 0.05, got["foo.v1.1.0"], 1e-9)
+}
+
+func TestInvalidRanking(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		ranking graph.RankingFunc
+		want    string
+	}{
+		{
+			name:    "missing node",
+			ranking: func(nodes []*graph.Node) [][]*graph.Node { return [][]*graph.Node{nodes[1:]} },
+			want:    "did not rank foo.v1.0.0",
+		},
+		{
+			name:    "repeated node",
+			ranking: func(nodes []*graph.Node) [][]*graph.Node { return [][]*graph.Node{nodes, nodes[:1]} },
+			want:    "ranked foo.v1.0.0 more than once",
+		},
+		{
+			name: "other node",
+			ranking: func(nodes []*graph.Node) [][]*graph.Node {
+				return [][]*graph.Node{append(nodes, &graph.Node{Name: "bar"})}
+			},
+			want: "ranked bar.v0.0.0, which is not one of its nodes",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := graph.NewGraph(t.Context(), graph.GraphConfig{
+				Packages: []graph.Package{phasedPackage([]graph.LifecyclePhase{graph.LifecyclePhaseFullSupport}, 2)},
+				AsOf:     edgeWeightsAsOf,
+				Ranking:  tc.ranking,
+			})
+			assert.ErrorContains(t, err, tc.want)
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package graph
+
+import (
+	"slices"
+
+	"github.com/opencontainers/go-digest"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+// collapseRebuilds returns a node for each version of nodes: the newest build
+// of the version, with the version's other builds as its Rebuilds, newest
+// first. Builds released at the same time are ordered by release.
+func collapseRebuilds(nodes []*Node) []*Node {
+	newestFirst := slices.SortedFunc(slices.Values(nodes), func(a, b *Node) int {
+		if v := a.Version.Compare(b.Version); v != 0 {
+			return v
+		}
+		if v := b.ReleaseDate.Compare(a.ReleaseDate); v != 0 {
+			return v
+		}
+		return compareNodes(b, a)
+	})
+
+	var collapsed []*Node
+	for i := 0; i < len(newestFirst); {
+		newest := newestFirst[i]
+		j := i + 1
+		for j < len(newestFirst) && newestFirst[j].Version.EQ(newest.Version) {
+			j++
+		}
+		newest.Rebuilds = nil
+		if j > i+1 {
+			newest.Rebuilds = slices.Clone(newestFirst[i+1 : j])
+		}
+		collapsed = append(collapsed, newest)
+		i = j
+	}
+	return collapsed
+}
+
+// ImageReferences returns the references of the images of the node and of
+// its rebuilds, newest first.
+func (n *Node) ImageReferences() []reference.Canonical {
+	var refs []reference.Canonical
+	for _, b := range append([]*Node{n}, n.Rebuilds...) {
+		if b.ImageReference != nil {
+			refs = append(refs, b.ImageReference)
+		}
+	}
+	return refs
+}
+
+// IdenticalRebuilds returns the rebuilds of the node, newest first, whose
+// content fingerprint is the node's, so that they differ from it only in how
+// they were built. It returns nil if the node was not fingerprinted.
+func (n *Node) IdenticalRebuilds() []*Node {
+	if n.ContentFingerprint == "" {
+		return nil
+	}
+	var identical []*Node
+	for _, r := range n.Rebuilds {
+		if r.ContentFingerprint == n.ContentFingerprint {
+			identical = append(identical, r)
+		}
+	}
+	return identical
+}
+
+// NodeForDigest returns the node whose image, or the image of one of whose
+// rebuilds, has a digest, or nil if there is none. It maps the images of a
+// catalog or mirror back to the nodes of a graph whose rebuilds were
+// collapsed.
+func (g *Graph) NodeForDigest(dgst digest.Digest) *Node {
+	return g.byDigest[dgst]
+}
+
+func (g *Graph) indexDigests() {
+	g.byDigest = map[digest.Digest]*Node{}
+	for n := range g.NodesMatching(AllNodes()) {
+		for _, ref := range n.ImageReferences() {
+			g.byDigest[ref.Digest()] = n
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package graph_test
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.podman.io/image/v5/docker/reference"
+)
+
+func TestCollapseRebuilds(t *testing.T) {
+	released := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	newNode := func(version, release string, hour int, hex string) *graph.Node {
+		ref, err := reference.ParseNamed("quay.io/example/foo-bundle@sha256:" + strings.Repeat(hex, 64))
+		require.NoError(t, err)
+		return &graph.Node{
+			Name:           "foo",
+			Version:        semver.MustParse(version),
+			Release:        &release,
+			ReleaseDate:    released.Add(time.Duration(hour) * time.Hour),
+			ImageReference: ref.(reference.Canonical),
+		}
+	}
+	pkg := graph.Package{
+		Name: "foo",
+		Streams: []graph.VersionStream{{
+			Version:        graph.MajorMinor{Major: 1},
+			LifecycleDates: datesInPhase(graph.LifecyclePhaseFullSupport, 0),
+		}},
+		Nodes: []*graph.Node{
+			newNode("1.0.0", "1", 0, "a"),
+			newNode("1.0.0", "2", 2, "b"),
+			newNode("1.0.0", "3", 1, "c"),
+			newNode("1.0.1", "1", 3, "d"),
+		},
+	}
+	g, err := graph.NewGraph(t.Context(), graph.GraphConfig{Packages: []graph.Package{pkg}, AsOf: edgeWeightsAsOf})
+	require.NoError(t, err)
+	assert.Len(t, slices.Collect(g.NodesMatching(graph.AllNodes())), 4)
+
+	g, err = graph.NewGraph(t.Context(), graph.GraphConfig{Packages: []graph.Package{pkg}, AsOf: edgeWeightsAsOf, CollapseRebuilds: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"foo.v1.0.0_2", "foo.v1.0.1_1"}, nvrs(slices.Collect(g.NodesMatching(graph.AllNodes()))))
+
+	newest := pkg.Nodes[1]
+	assert.Equal(t, []string{"foo.v1.0.0_3", "foo.v1.0.0_1"}, nvrs(newest.Rebuilds))
+	assert.Len(t, newest.ImageReferences(), 3)
+	assert.Empty(t, pkg.Nodes[3].Rebuilds)
+	for _, hex := range []string{"a", "b", "c"} {
+		assert.Same(t, newest, g.NodeForDigest(digest.Digest("sha256:"+strings.Repeat(hex, 64))), hex)
+	}
+	assert.Same(t, pkg.Nodes[3], g.NodeForDigest(pkg.Nodes[3].ImageReference.Digest()))
+	assert.Nil(t, g.NodeForDigest(digest.Digest("sha256:"+strings.Repeat("e", 64))))
+	path, _, ok := g.ShortestPath(newest, pkg.Nodes[3])
+	require.True(t, ok)
+	assert.Equal(t, []string{"foo.v1.0.0_2", "foo.v1.0.1_1"}, nvrs(path))
+
+	var buf bytes.Buffer
+	require.NoError(t, g.Encode(&buf))
+	decoded, err := graph.DecodeGraph(t.Context(), &buf)
+	require.NoError(t, err)
+	got := decoded.NodeForDigest(pkg.Nodes[0].ImageReference.Digest())
+	require.NotNil(t, got)
+	assert.Equal(t, "foo.v1.0.0_2", got.NVR())
+	assert.Equal(t, []string{"foo.v1.0.0_3", "foo.v1.0.0_1"}, nvrs(got.Rebuilds))
+
+	// Rebuilds are identical if their content fingerprints are the newest
+	// build's.
+	assert.Empty(t, got.IdenticalRebuilds(), "nodes that were not fingerprinted have no identical rebuilds")
+	pkg.Nodes[0].ContentFingerprint = digest.FromString("same")
+	pkg.Nodes[1].ContentFingerprint = digest.FromString("same")
+	pkg.Nodes[2].ContentFingerprint = digest.FromString("changed")
+	assert.Equal(t, []string{"foo.v1.0.0_1"}, nvrs(newest.IdenticalRebuilds()))
+	buf.Reset()
+	require.NoError(t, g.Encode(&buf))
+	decoded, err = graph.DecodeGraph(t.Context(), &buf)
+	require.NoError(t, err)
+	got = decoded.NodeForDigest(pkg.Nodes[0].ImageReference.Digest())
+	require.NotNil(t, got)
+	assert.Equal(t, digest.FromString("same"), got.ContentFingerprint)
+	assert.Equal(t, []string{"foo.v1.0.0_1"}, nvrs(got.IdenticalRebuilds()))
+
+	// Building again without collapsing restores every build.
+	g, err = graph.NewGraph(t.Context(), graph.GraphConfig{Packages: []graph.Package{pkg}, AsOf: edgeWeightsAsOf})
+	require.NoError(t, err)
+	assert.Empty(t, newest.Rebuilds)
+	assert.Same(t, pkg.Nodes[0], g.NodeForDigest(pkg.Nodes[0].ImageReference.Digest()))
+}
@@ -10,6 +10,11 @@ type Template struct {
 	Name           string               `json:"name"`
 	VersionStreams []VersionStream      `json:"versionStreams"`
 	Images         []CanonicalReference `json:"images"`
+
+	// MajorVersionBridges are the updates across major versions that the
+	// package permits, such as from the last minor version of one major
+	// version to the first of the next.
+	MajorVersionBridges []MajorVersionBridge `json:"majorVersionBridges,omitempty"`
 }
 
 const SchemaCincinnati = `olm.cincinnati`
@@ -33,5 +38,11 @@ func (t *Template) Validate() error {
 			errs = append(errs, fmt.Errorf("version %q invalid: %v", version.Version, err))
 		}
 	}
+	if err := validateStreams(t.VersionStreams); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateBridges(t.VersionStreams, t.MajorVersionBridges); err != nil {
+		errs = append(errs, err)
+	}
 	return errors.Join(errs...)
 }
@@ -0,0 +1,81 @@
+package graph_test
+
+import (
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateValidateStreams(t *testing.T) {
+	newTemplate := func(streams ...graph.VersionStream) graph.Template {
+		return graph.Template{
+			Schema:         graph.SchemaCincinnati,
+			Name:           "foo",
+			VersionStreams: streams,
+			Images:         []graph.CanonicalReference{{}},
+		}
+	}
+	stream := func(version string, minimumUpdateVersion string) graph.VersionStream {
+		v := semver.MustParse(version + ".0")
+		return graph.VersionStream{
+			Version:              graph.NewMajorMinorFromVersion(v),
+			MinimumUpdateVersion: semver.MustParse(minimumUpdateVersion),
+			LifecycleDates:       datesInPhase(graph.LifecyclePhaseFullSupport, 0),
+		}
+	}
+
+	for _, tc := range []struct {
+		name    string
+		tmpl    graph.Template
+		wantErr []string
+	}{
+		{
+			name: "valid",
+			tmpl: newTemplate(stream("1.0", "1.0.0"), stream("1.1", "1.0.0"), stream("1.2", "1.2.3")),
+		},
+		{
+			name:    "duplicate version",
+			tmpl:    newTemplate(stream("1.0", "1.0.0"), stream("1.1", "1.0.0"), stream("1.0", "1.0.0")),
+			wantErr: []string{`version "1.0" invalid: stream 2 declares the same version as stream 0`},
+		},
+		{
+			name: "minimum update version above stream",
+			tmpl: newTemplate(stream("1.0", "1.1.0"), stream("1.1", "2.0.0")),
+			wantErr: []string{
+				`version "1.0" invalid: minimum update version 1.1.0 is above the stream's version`,
+				`version "1.1" invalid: minimum update version 2.0.0 is above the stream's version`,
+			},
+		},
+		{
+			name: "major version bridges",
+			tmpl: func() graph.Template {
+				tmpl := newTemplate(stream("1.1", "1.0.0"), stream("2.0", "2.0.0"))
+				tmpl.MajorVersionBridges = []graph.MajorVersionBridge{
+					{From: semver.MustParse("1.1.0"), To: graph.MajorMinor{Major: 2}},
+					{From: semver.MustParse("1.1.0"), To: graph.MajorMinor{Major: 1, Minor: 1}},
+					{From: semver.MustParse("1.2.0"), To: graph.MajorMinor{Major: 3}},
+				}
+				return tmpl
+			}(),
+			wantErr: []string{
+				`major version bridge "1.1.0 -> 1.1" invalid: must be to a higher major version`,
+				`major version bridge "1.2.0 -> 3.0" invalid: version "1.2" is not in any stream`,
+				`major version bridge "1.2.0 -> 3.0" invalid: version "3.0" is not in any stream`,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.tmpl.Validate()
+			if len(tc.wantErr) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			for _, want := range tc.wantErr {
+				assert.ErrorContains(t, err, want)
+			}
+		})
+	}
+}
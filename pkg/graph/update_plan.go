@@ -2,14 +2,14 @@ package graph
 
 import (
 	"cmp"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"math"
 	"slices"
 	"strings"
 
-	"github.com/joelanford/extensiondb/examples/cincinnati/pkg/util"
-	"gonum.org/v1/gonum/graph"
+	"github.com/joelanford/extensiondb/pkg/util"
 	"k8s.io/apimachinery/pkg/util/sets"
 )
 
@@ -83,10 +83,135 @@ type PlatformNodeUpdate struct {
 	Error  error
 }
 
-func (g *Graph) PlanOpenShiftUpdate(froms []*Node, fromPlatform, toPlatform MajorMinor) (*PlatformUpdate, error) {
+// encodedPlatformUpdate is the form in which PlatformUpdate.MarshalJSON writes
+// a plan. Nodes are written as their versions and releases, and update paths
+// start with the installed version.
+type encodedPlatformUpdate struct {
+	Name        string                      `json:"name"`
+	From        MajorMinor                  `json:"from"`
+	To          MajorMinor                  `json:"to"`
+	NodeUpdates []encodedPlatformNodeUpdate `json:"nodeUpdates"`
+}
+
+type encodedPlatformNodeUpdate struct {
+	Package string   `json:"package"`
+	From    string   `json:"from"`
+	Before  []string `json:"before"`
+	After   []string `json:"after"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// MarshalJSON writes the plan in the form described by the platform update
+// schema of package schema.
+func (pu *PlatformUpdate) MarshalJSON() ([]byte, error) {
+	epu := encodedPlatformUpdate{
+		Name:        pu.Name,
+		From:        pu.From,
+		To:          pu.To,
+		NodeUpdates: make([]encodedPlatformNodeUpdate, 0, len(pu.NodeUpdates)),
+	}
+	for _, nu := range pu.NodeUpdates {
+		enu := encodedPlatformNodeUpdate{
+			Package: nu.From.Name,
+			From:    nu.From.VR(),
+			Before:  util.MapSlice(nu.Before, (*Node).VR),
+			After:   util.MapSlice(nu.After, (*Node).VR),
+		}
+		if nu.Error != nil {
+			enu.Error = nu.Error.Error()
+		}
+		epu.NodeUpdates = append(epu.NodeUpdates, enu)
+	}
+	return json.Marshal(epu)
+}
+
+// PlanOption configures PlanOpenShiftUpdate.
+type PlanOption func(*planConfig)
+
+type planConfig struct {
+	prefer    NodePredicate
+	exclude   NodePredicate
+	keepEdge  EdgePredicate
+	preferEUS bool
+}
+
+// PreferNodes makes PlanOpenShiftUpdate choose update paths that end in nodes
+// matching prefer over paths that don't, even if they are heavier.
+func PreferNodes(prefer NodePredicate) PlanOption {
+	return func(cfg *planConfig) {
+		cfg.prefer = prefer
+	}
+}
+
+// PreferEUS makes PlanOpenShiftUpdate choose, for updates from and to EUS
+// platform versions (the even minor versions of OpenShift 4), update paths
+// that end in nodes of EUS streams over paths that don't, even if they are
+// heavier, so that clusters that stay on EUS platform versions also stay on
+// streams with extended support. EUS streams are those whose lifecycle dates
+// have extension phases. Paths preferred by PreferNodes are still chosen
+// first.
+func PreferEUS() PlanOption {
+	return func(cfg *planConfig) {
+		cfg.preferEUS = true
+	}
+}
+
+// isEUSPlatform reports whether an OpenShift version has Extended Update
+// Support.
+func isEUSPlatform(mm MajorMinor) bool {
+	return mm.Major == 4 && mm.Minor%2 == 0
+}
+
+// inEUSStream reports whether a node's version stream has extension phases.
+func inEUSStream(_ *Graph, n *Node) bool {
+	return len(n.LifecycleDates.Extensions) > 0
+}
+
+// ExcludeNodes makes PlanOpenShiftUpdate leave out update paths through nodes
+// matching exclude, such as OlderThan(18 * 30 * 24 * time.Hour) to never
+// update through or to bundles built more than about 18 months ago. The node
+// being updated from is never excluded. Only the shortest path to each
+// candidate node is considered, so a node whose shortest path is excluded is
+// not updated to.
+func ExcludeNodes(exclude NodePredicate) PlanOption {
+	return func(cfg *planConfig) {
+		cfg.exclude = exclude
+	}
+}
+
+// KeepEdges makes PlanOpenShiftUpdate leave out update paths with an update
+// that doesn't match keep, such as ForbidEOLTargets() to never update to or
+// through end of life nodes. Rules can be combined with AndEdges. As with
+// ExcludeNodes, only the shortest path to each candidate node is considered;
+// to route around the edges instead, build the graph with
+// GraphConfig.KeepEdge.
+func KeepEdges(keep EdgePredicate) PlanOption {
+	return func(cfg *planConfig) {
+		cfg.keepEdge = keep
+	}
+}
+
+// keepsEdges reports whether every update of p matches keep.
+func (g *Graph) keepsEdges(p []*Node, keep EdgePredicate) bool {
+	for i := 1; i < len(p); i++ {
+		if !keep(g, p[i-1], p[i], g.EdgeWeight(p[i-1], p[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// PlanOpenShiftUpdate plans the updates of the froms nodes across an OpenShift
+// update from fromPlatform to toPlatform. It stops with ctx's error once ctx
+// is done.
+func (g *Graph) PlanOpenShiftUpdate(ctx context.Context, froms []*Node, fromPlatform, toPlatform MajorMinor, opts ...PlanOption) (*PlatformUpdate, error) {
 	if err := validateOpenShiftUpdate(fromPlatform, toPlatform); err != nil {
 		return nil, err
 	}
+	var cfg planConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
 
 	var traversedPlatforms []MajorMinor
 	for curPlatform := fromPlatform; curPlatform.Compare(toPlatform) <= 0; curPlatform.Minor++ {
@@ -95,7 +220,10 @@ func (g *Graph) PlanOpenShiftUpdate(froms []*Node, fromPlatform, toPlatform Majo
 
 	var pnus []PlatformNodeUpdate
 	for _, from := range froms {
-		pnus = append(pnus, g.platformNodeUpdatePathFrom(from, traversedPlatforms))
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		pnus = append(pnus, g.platformNodeUpdatePathFrom(from, traversedPlatforms, cfg))
 	}
 	return &PlatformUpdate{Name: "OpenShift", From: fromPlatform, To: toPlatform, NodeUpdates: pnus}, nil
 }
@@ -124,10 +252,12 @@ func validateOpenShiftUpdate(from MajorMinor, to MajorMinor) error {
 	return nil
 }
 
-func (g *Graph) platformNodeUpdatePathFrom(from *Node, traversedPlatforms []MajorMinor) PlatformNodeUpdate {
+func (g *Graph) platformNodeUpdatePathFrom(from *Node, traversedPlatforms []MajorMinor, cfg planConfig) PlatformNodeUpdate {
 	type updatePath struct {
-		p []*Node
-		w float64
+		p         []*Node
+		w         float64
+		preferred bool
+		eus       bool
 	}
 
 	// If the from node is not supported on the current platform version, that issue needs to somehow be resolved
@@ -144,6 +274,7 @@ func (g *Graph) platformNodeUpdatePathFrom(from *Node, traversedPlatforms []Majo
 	toPlatform := traversedPlatforms[len(traversedPlatforms)-1]
 	fromPlatformSet := sets.New[MajorMinor](fromPlatform)
 	toPlatformSet := sets.New[MajorMinor](toPlatform)
+	preferEUS := cfg.preferEUS && isEUSPlatform(fromPlatform) && isEUSPlatform(toPlatform)
 
 	// find all update paths into nodes supported on the toPlatform
 	var updatePaths []updatePath
@@ -151,18 +282,39 @@ func (g *Graph) platformNodeUpdatePathFrom(from *Node, traversedPlatforms []Majo
 		PackageNodes(from.Name),
 		supportedOnPlatforms(toPlatformSet),
 	)) {
-		p, w, _ := g.Paths().Between(from.ID(), to.ID())
-		if w == math.Inf(1) {
+		p, w, ok := g.ShortestPath(from, to)
+		if !ok {
+			continue
+		}
+		if cfg.exclude != nil && slices.ContainsFunc(p[1:], func(n *Node) bool { return cfg.exclude(g, n) }) {
+			continue
+		}
+		if cfg.keepEdge != nil && !g.keepsEdges(p, cfg.keepEdge) {
 			continue
 		}
 		updatePaths = append(updatePaths, updatePath{
-			p: util.MapSlice(p, func(n graph.Node) *Node { return n.(*Node) }),
-			w: w,
+			p:         p,
+			w:         w,
+			preferred: cfg.prefer != nil && cfg.prefer(g, to),
+			eus:       preferEUS && inEUSStream(g, to),
 		})
 	}
 
-	// Sort update paths by weight (then by number of updates)
+	// Sort update paths to preferred nodes first, then to nodes of EUS
+	// streams, then by weight (then by number of updates)
 	slices.SortFunc(updatePaths, func(a, b updatePath) int {
+		if a.preferred != b.preferred {
+			if a.preferred {
+				return -1
+			}
+			return 1
+		}
+		if a.eus != b.eus {
+			if a.eus {
+				return -1
+			}
+			return 1
+		}
 		if v := cmp.Compare(a.w, b.w); v != 0 {
 			return v
 		}
@@ -0,0 +1,66 @@
+package graph
+
+import "fmt"
+
+// Nodes are always ordered by semver precedence: a prerelease, such as
+// 1.2.3-rc.1, comes before its release, and the prereleases of a version are
+// ordered by their identifiers. Build metadata, such as the build.5 of
+// 1.2.3+build.5, doesn't affect precedence, so nodes whose versions differ
+// only in build metadata are ordered as builds of the same version: by
+// release, then by release date, and then by their versions as strings. The
+// policies below decide which of these nodes are placed in the graph and
+// which of them can be updated to.
+
+// PrereleasePolicy is how GraphConfig places nodes with prerelease versions.
+type PrereleasePolicy int
+
+const (
+	// PrereleasesAllowed updates to and from prereleases like any other
+	// version.
+	PrereleasesAllowed PrereleasePolicy = iota
+
+	// PrereleasesOptIn updates from prereleases like any other version, but
+	// only updates to prereleases from other prereleases, so that nodes of
+	// released versions are never updated to a prerelease.
+	PrereleasesOptIn
+
+	// PrereleasesExcluded leaves prereleases out of the graph, reporting
+	// them by Graph.SkippedNodes.
+	PrereleasesExcluded
+)
+
+// BuildMetadataPolicy is how GraphConfig places nodes whose versions have
+// build metadata.
+type BuildMetadataPolicy int
+
+const (
+	// BuildMetadataIgnored places nodes with build metadata like any other,
+	// as builds of their version without it.
+	BuildMetadataIgnored BuildMetadataPolicy = iota
+
+	// BuildMetadataExcluded leaves nodes with build metadata out of the
+	// graph, reporting them by Graph.SkippedNodes.
+	BuildMetadataExcluded
+)
+
+// excluded returns why the version policies of cfg leave a node out of the
+// graph, or nil if they don't.
+func (cfg GraphConfig) excluded(n *Node) error {
+	if cfg.Prereleases == PrereleasesExcluded && isPrerelease(n) {
+		return fmt.Errorf("version %s is a prerelease, and prereleases are excluded", n.Version)
+	}
+	if cfg.BuildMetadata == BuildMetadataExcluded && len(n.Version.Build) > 0 {
+		return fmt.Errorf("version %s has build metadata, and versions with build metadata are excluded", n.Version)
+	}
+	return nil
+}
+
+// permitsEdge reports whether the prerelease policy permits an edge from one
+// node to another.
+func (p PrereleasePolicy) permitsEdge(from, to *Node) bool {
+	return p != PrereleasesOptIn || !isPrerelease(to) || isPrerelease(from)
+}
+
+func isPrerelease(n *Node) bool {
+	return len(n.Version.Pre) > 0
+}
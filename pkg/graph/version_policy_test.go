@@ -0,0 +1,97 @@
+package graph_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// versionPolicyGraph builds a graph of prereleases, releases, and versions
+// with build metadata with the given version policies, and returns it with
+// its edges as "from->to" versions.
+func versionPolicyGraph(t *testing.T, prereleases graph.PrereleasePolicy, buildMetadata graph.BuildMetadataPolicy) (*graph.Graph, []string) {
+	t.Helper()
+	pkg := graph.Package{Name: "foo"}
+	for minor := range 2 {
+		pkg.Streams = append(pkg.Streams, graph.VersionStream{
+			Version:        graph.MajorMinor{Major: 1, Minor: uint64(minor)},
+			LifecycleDates: datesInPhase(graph.LifecyclePhaseFullSupport, 0),
+		})
+	}
+	released := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, v := range []string{"1.0.0", "1.1.0-rc.1", "1.1.0-rc.2", "1.1.0", "1.1.1+b", "1.1.1+a"} {
+		pkg.Nodes = append(pkg.Nodes, &graph.Node{
+			Name:    "foo",
+			Version: semver.MustParse(v),
+			// Both builds of 1.1.1 are released at the same time.
+			ReleaseDate: released.Add(time.Duration(min(i, 4)) * time.Hour),
+		})
+	}
+	g, err := graph.NewGraph(t.Context(), graph.GraphConfig{
+		Packages:      []graph.Package{pkg},
+		AsOf:          edgeWeightsAsOf,
+		Prereleases:   prereleases,
+		BuildMetadata: buildMetadata,
+	})
+	require.NoError(t, err)
+
+	var edges []string
+	for e := range g.Edges() {
+		edges = append(edges, e.From.VR()+"->"+e.To.VR())
+	}
+	return g, edges
+}
+
+func TestPrereleasePolicies(t *testing.T) {
+	g, edges := versionPolicyGraph(t, graph.PrereleasesAllowed, graph.BuildMetadataIgnored)
+	assert.Equal(t, []string{
+		"foo.v1.0.0", "foo.v1.1.0-rc.1", "foo.v1.1.0-rc.2", "foo.v1.1.0", "foo.v1.1.1+a", "foo.v1.1.1+b",
+	}, nvrs(slices.Collect(g.NodesMatching(graph.AllNodes()))), "prereleases come before their release")
+	assert.Contains(t, edges, "1.0.0->1.1.0-rc.1")
+	assert.Contains(t, edges, "1.1.0-rc.1->1.1.0-rc.2")
+	assert.Empty(t, g.SkippedNodes())
+
+	_, edges = versionPolicyGraph(t, graph.PrereleasesOptIn, graph.BuildMetadataIgnored)
+	assert.NotContains(t, edges, "1.0.0->1.1.0-rc.1")
+	assert.NotContains(t, edges, "1.0.0->1.1.0-rc.2")
+	assert.Contains(t, edges, "1.1.0-rc.1->1.1.0-rc.2")
+	assert.Contains(t, edges, "1.1.0-rc.2->1.1.0")
+	assert.Contains(t, edges, "1.0.0->1.1.0")
+
+	g, edges = versionPolicyGraph(t, graph.PrereleasesExcluded, graph.BuildMetadataIgnored)
+	assert.Equal(t, []string{
+		"foo.v1.0.0", "foo.v1.1.0", "foo.v1.1.1+a", "foo.v1.1.1+b",
+	}, nvrs(slices.Collect(g.NodesMatching(graph.AllNodes()))))
+	var skipped []string
+	for _, s := range g.SkippedNodes() {
+		skipped = append(skipped, s.Node.VR())
+		assert.ErrorContains(t, s.Err, "prereleases are excluded")
+	}
+	assert.ElementsMatch(t, []string{"1.1.0-rc.1", "1.1.0-rc.2"}, skipped)
+	assert.Contains(t, edges, "1.0.0->1.1.0")
+}
+
+func TestBuildMetadataPolicies(t *testing.T) {
+	// Builds of the same version released at the same time are ordered by
+	// their build metadata, every time.
+	for range 10 {
+		_, edges := versionPolicyGraph(t, graph.PrereleasesAllowed, graph.BuildMetadataIgnored)
+		assert.Contains(t, edges, "1.1.1+a->1.1.1+b")
+		assert.NotContains(t, edges, "1.1.1+b->1.1.1+a")
+	}
+
+	g, edges := versionPolicyGraph(t, graph.PrereleasesAllowed, graph.BuildMetadataExcluded)
+	var skipped []string
+	for _, s := range g.SkippedNodes() {
+		skipped = append(skipped, s.Node.VR())
+		assert.ErrorContains(t, s.Err, "build metadata")
+	}
+	assert.ElementsMatch(t, []string{"1.1.1+a", "1.1.1+b"}, skipped)
+	assert.NotContains(t, edges, "1.1.0->1.1.1+a")
+	assert.Equal(t, []string{"foo.v1.1.0"}, nvrs(g.HeadsFor("foo").UnsortedList()))
+}
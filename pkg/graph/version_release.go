@@ -0,0 +1,142 @@
+package graph
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver/v4"
+)
+
+// VersionRelease is a bundle's semantic version and its release, which
+// distinguishes rebuilds of the same version. An empty release means the
+// bundle has no release.
+type VersionRelease struct {
+	Version semver.Version
+	Release string
+}
+
+func NewVersionRelease(v semver.Version, release *string) VersionRelease {
+	vr := VersionRelease{Version: v}
+	if release != nil {
+		vr.Release = *release
+	}
+	return vr
+}
+
+func (vr VersionRelease) String() string {
+	if vr.Release == "" {
+		return vr.Version.String()
+	}
+	return fmt.Sprintf("%s_%s", vr.Version, vr.Release)
+}
+
+// Compare orders by version, then by release. Releases are compared the way
+// RPM compares them, so that, for example, release 10 is after release 9, and
+// a version without a release is before any of its releases.
+func (vr VersionRelease) Compare(other VersionRelease) int {
+	if v := vr.Version.Compare(other.Version); v != 0 {
+		return v
+	}
+	return CompareReleases(vr.Release, other.Release)
+}
+
+// CompareReleases compares two releases using RPM's rpmvercmp algorithm. Each
+// release is split into runs of digits and runs of letters, ignoring other
+// characters, and the runs are compared in turn: digits numerically, letters
+// lexically, and digits after letters. A tilde sorts before anything, even
+// the end of the release, and a caret sorts after the end of the release but
+// before anything else.
+func CompareReleases(a, b string) int {
+	if a == b {
+		return 0
+	}
+	for a != "" || b != "" {
+		a = strings.TrimLeftFunc(a, isReleaseSeparator)
+		b = strings.TrimLeftFunc(b, isReleaseSeparator)
+
+		if strings.HasPrefix(a, "~") || strings.HasPrefix(b, "~") {
+			if !strings.HasPrefix(a, "~") {
+				return 1
+			}
+			if !strings.HasPrefix(b, "~") {
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+		if strings.HasPrefix(a, "^") || strings.HasPrefix(b, "^") {
+			switch {
+			case a == "":
+				return -1
+			case b == "":
+				return 1
+			case !strings.HasPrefix(a, "^"):
+				return 1
+			case !strings.HasPrefix(b, "^"):
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+		if a == "" || b == "" {
+			break
+		}
+
+		numeric := isDigit(rune(a[0]))
+		inRun := isLetter
+		if numeric {
+			inRun = isDigit
+		}
+		var runA, runB string
+		runA, a = splitRun(a, inRun)
+		runB, b = splitRun(b, inRun)
+
+		// Runs of different types: digits sort after letters.
+		if runB == "" {
+			if numeric {
+				return 1
+			}
+			return -1
+		}
+
+		if numeric {
+			runA = strings.TrimLeft(runA, "0")
+			runB = strings.TrimLeft(runB, "0")
+			if v := cmp.Compare(len(runA), len(runB)); v != 0 {
+				return v
+			}
+		}
+		if v := cmp.Compare(runA, runB); v != 0 {
+			return v
+		}
+	}
+	switch {
+	case a == "" && b == "":
+		return 0
+	case a == "":
+		return -1
+	default:
+		return 1
+	}
+}
+
+func splitRun(s string, inRun func(rune) bool) (string, string) {
+	i := strings.IndexFunc(s, func(r rune) bool { return !inRun(r) })
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i:]
+}
+
+func isReleaseSeparator(r rune) bool {
+	return !isDigit(r) && !isLetter(r) && r != '~' && r != '^'
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func isLetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
@@ -0,0 +1,51 @@
+package graph_test
+
+import (
+	"testing"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareReleases(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1", "1", 0},
+		{"", "1", -1},
+		{"9", "10", -1},
+		{"1.el9", "1.el9", 0},
+		{"1.el8", "1.el9", -1},
+		{"2.el8", "1.el9", 1},
+		{"1.el9_2", "1.el9_10", -1},
+		{"001", "1", 0},
+		{"1a", "1", 1},
+		{"1a", "1.1", -1},
+		{"a", "1", -1},
+		{"1.0", "1_0", 0},
+		{"1~rc1", "1", -1},
+		{"1~rc1", "1~rc2", -1},
+		{"1^git1", "1", 1},
+		{"1^git1", "1.1", -1},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, graph.CompareReleases(test.a, test.b), "CompareReleases(%q, %q)", test.a, test.b)
+		assert.Equal(t, -test.expected, graph.CompareReleases(test.b, test.a), "CompareReleases(%q, %q)", test.b, test.a)
+	}
+}
+
+func TestVersionRelease(t *testing.T) {
+	v1 := semver.MustParse("1.0.0")
+	rel := func(s string) *string { return &s }
+
+	assert.Equal(t, "1.0.0", graph.NewVersionRelease(v1, nil).String())
+	assert.Equal(t, "1.0.0_10", graph.NewVersionRelease(v1, rel("10")).String())
+
+	a := &graph.Node{Name: "foo", Version: v1, Release: rel("9")}
+	b := &graph.Node{Name: "foo", Version: v1, Release: rel("10")}
+	assert.Equal(t, -1, a.Compare(b), "rebuilds order by release")
+	assert.Equal(t, -1, (&graph.Node{Name: "foo", Version: v1}).Compare(a), "a version is before its releases")
+	assert.Equal(t, 1, (&graph.Node{Name: "foo", Version: semver.MustParse("1.0.1")}).Compare(b), "versions order before releases")
+}
@@ -0,0 +1,97 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/blang/semver/v4"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+type VersionStream struct {
+	Version                        MajorMinor     `json:"version"`
+	MinimumUpdateVersion           semver.Version `json:"minimumUpdateVersion"`
+	LifecycleDates                 LifecycleDates `json:"lifecycleDates"`
+	SupportedPlatformVersions      []MajorMinor   `json:"supportedPlatformVersions"`
+	RequiresUpdatePlatformVersions []MajorMinor   `json:"requiresUpdatePlatformVersions"`
+}
+
+// validateStreams checks that no two streams declare the same version, and
+// that no stream's minimum update version is above the stream's version,
+// which would leave the stream's nodes with no updates to them.
+func validateStreams(streams []VersionStream) error {
+	var (
+		errs     []error
+		declared = map[MajorMinor]int{}
+	)
+	for i, stream := range streams {
+		if first, ok := declared[stream.Version]; ok {
+			errs = append(errs, fmt.Errorf("version %q invalid: stream %d declares the same version as stream %d", stream.Version, i, first))
+		} else {
+			declared[stream.Version] = i
+		}
+		if minMM := NewMajorMinorFromVersion(stream.MinimumUpdateVersion); minMM.Compare(stream.Version) > 0 {
+			errs = append(errs, fmt.Errorf("version %q invalid: minimum update version %s is above the stream's version", stream.Version, stream.MinimumUpdateVersion))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// MajorVersionBridge permits updates across major versions, which are
+// otherwise never made: the nodes of From's major version at or above From
+// can be updated to the nodes of the To stream.
+type MajorVersionBridge struct {
+	From semver.Version `json:"from"`
+	To   MajorMinor     `json:"to"`
+}
+
+func (b MajorVersionBridge) String() string {
+	return fmt.Sprintf("%s -> %s", b.From, b.To)
+}
+
+func (b MajorVersionBridge) permits(from, to *Node) bool {
+	return from.Version.Major == b.From.Major && from.Version.GTE(b.From) && NewMajorMinorFromVersion(to.Version) == b.To
+}
+
+// validateBridges checks that bridges go to higher major versions, and that
+// both of their ends are declared streams.
+func validateBridges(streams []VersionStream, bridges []MajorVersionBridge) error {
+	declared := sets.New[MajorMinor]()
+	for _, stream := range streams {
+		declared.Insert(stream.Version)
+	}
+	var errs []error
+	for _, b := range bridges {
+		if b.To.Major <= b.From.Major {
+			errs = append(errs, fmt.Errorf("major version bridge %q invalid: must be to a higher major version", b))
+		}
+		if fromMM := NewMajorMinorFromVersion(b.From); !declared.Has(fromMM) {
+			errs = append(errs, fmt.Errorf("major version bridge %q invalid: version %q is not in any stream", b, fromMM))
+		}
+		if !declared.Has(b.To) {
+			errs = append(errs, fmt.Errorf("major version bridge %q invalid: version %q is not in any stream", b, b.To))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// bridgedMajorVersions returns a function that maps a major version to the
+// lowest major version that it is bridged to, directly or through other
+// bridges, or to itself if it isn't bridged.
+func bridgedMajorVersions(bridges []MajorVersionBridge) func(uint64) uint64 {
+	lowest := map[uint64]uint64{}
+	find := func(major uint64) uint64 {
+		for {
+			l, ok := lowest[major]
+			if !ok || l == major {
+				return major
+			}
+			major = l
+		}
+	}
+	for _, b := range bridges {
+		from, to := find(b.From.Major), find(b.To.Major)
+		lowest[max(from, to)] = min(from, to)
+	}
+	return find
+}
@@ -0,0 +1,65 @@
+package graph
+
+import (
+	"math"
+	"time"
+)
+
+// WeightStrategy adjusts the weights of a graph's edges. Shortest paths
+// minimize the sum of the weights of their edges, so a strategy changes which
+// updates are recommended.
+type WeightStrategy interface {
+	// EdgeWeight returns the weight of an edge, given the weight that ranks
+	// the edge's target by lifecycle phase and version as edge.Weight, and
+	// the time the graph is built as of. Weights must be non-negative.
+	EdgeWeight(edge WeightedEdge, asOf time.Time) float64
+}
+
+// WeightStrategyFunc adapts a function to a WeightStrategy.
+type WeightStrategyFunc func(edge WeightedEdge, asOf time.Time) float64
+
+func (f WeightStrategyFunc) EdgeWeight(edge WeightedEdge, asOf time.Time) float64 {
+	return f(edge, asOf)
+}
+
+// SemverDistance penalizes updates that skip minor versions, for users who
+// prefer to update through each minor version in turn. Edges to the next minor
+// version, or within a minor version, keep their weight.
+//
+// Penalties are added to the lifecycle weights, so they change the shortest
+// paths only once they outweigh the intermediate updates they avoid, whose
+// weights grow with the number of nodes in the package.
+type SemverDistance struct {
+	// PerSkippedMinor is added to an edge's weight for each minor version
+	// it skips.
+	PerSkippedMinor float64
+}
+
+func (s SemverDistance) EdgeWeight(edge WeightedEdge, _ time.Time) float64 {
+	from, to := edge.From.Version, edge.To.Version
+	if from.Major != to.Major || to.Minor <= from.Minor+1 {
+		return edge.Weight
+	}
+	return edge.Weight + s.PerSkippedMinor*float64(to.Minor-from.Minor-1)
+}
+
+// Recency penalizes updates to nodes that were built long ago, so that paths
+// avoid routing through old rebuilds in favor of recent builds. Nodes built
+// after the graph's as-of time are not penalized.
+type Recency struct {
+	// PerYear is added to an edge's weight for each year between when its
+	// target was built and the graph's as-of time.
+	PerYear float64
+}
+
+func (r Recency) EdgeWeight(edge WeightedEdge, asOf time.Time) float64 {
+	age := asOf.Sub(edge.To.ReleaseDate)
+	if age <= 0 {
+		return edge.Weight
+	}
+	return edge.Weight + r.PerYear*age.Hours()/(365*24)
+}
+
+func validEdgeWeight(w float64) bool {
+	return w >= 0 && !math.IsInf(w, 0) && !math.IsNaN(w)
+}
@@ -0,0 +1,89 @@
+package graph_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// skippablePackage returns a package with full support streams 1.0, 1.1, and
+// 1.2, each with one node, that can all be updated to from 1.0.0.
+func skippablePackage() graph.Package {
+	pkg := graph.Package{Name: "foo"}
+	released := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for minor := range uint64(3) {
+		pkg.Streams = append(pkg.Streams, graph.VersionStream{
+			Version:              graph.MajorMinor{Major: 1, Minor: minor},
+			MinimumUpdateVersion: semver.Version{Major: 1},
+			LifecycleDates:       datesInPhase(graph.LifecyclePhaseFullSupport, 0),
+		})
+		pkg.Nodes = append(pkg.Nodes, &graph.Node{
+			Name:        "foo",
+			Version:     semver.Version{Major: 1, Minor: minor},
+			ReleaseDate: released.AddDate(int(minor), 0, 0),
+		})
+	}
+	return pkg
+}
+
+func TestSemverDistance(t *testing.T) {
+	pkg := skippablePackage()
+	from, to := pkg.Nodes[0], pkg.Nodes[2]
+
+	g, err := graph.NewGraph(t.Context(), graph.GraphConfig{Packages: []graph.Package{pkg}, AsOf: edgeWeightsAsOf})
+	require.NoError(t, err)
+	path, _, ok := g.ShortestPath(from, to)
+	require.True(t, ok)
+	assert.Equal(t, []string{"foo.v1.0.0", "foo.v1.2.0"}, nvrs(path))
+
+	g, err = graph.NewGraph(t.Context(), graph.GraphConfig{
+		Packages:       []graph.Package{pkg},
+		AsOf:           edgeWeightsAsOf,
+		WeightStrategy: graph.SemverDistance{PerSkippedMinor: 1},
+	})
+	require.NoError(t, err)
+	path, _, ok = g.ShortestPath(from, to)
+	require.True(t, ok)
+	assert.Equal(t, []string{"foo.v1.0.0", "foo.v1.1.0", "foo.v1.2.0"}, nvrs(path))
+	assert.InDelta(t, 1+graph.DefaultEdgeWeightDelta, g.EdgeWeight(from, to), 1e-9)
+}
+
+func TestRecency(t *testing.T) {
+	pkg := skippablePackage()
+	base, err := graph.NewGraph(t.Context(), graph.GraphConfig{Packages: []graph.Package{pkg}, AsOf: edgeWeightsAsOf})
+	require.NoError(t, err)
+	g, err := graph.NewGraph(t.Context(), graph.GraphConfig{
+		Packages:       []graph.Package{pkg},
+		AsOf:           edgeWeightsAsOf,
+		WeightStrategy: graph.Recency{PerYear: 2},
+	})
+	require.NoError(t, err)
+
+	from := pkg.Nodes[0]
+	for _, to := range pkg.Nodes[1:] {
+		years := edgeWeightsAsOf.Sub(to.ReleaseDate).Hours() / (365 * 24)
+		assert.InDelta(t, base.EdgeWeight(from, to)+2*years, g.EdgeWeight(from, to), 1e-9, to.NVR())
+	}
+
+	// Nodes built after the as-of time are not penalized.
+	w := graph.Recency{PerYear: 2}.EdgeWeight(graph.WeightedEdge{From: from, To: pkg.Nodes[1], Weight: 0.5}, pkg.Nodes[1].ReleaseDate.Add(-time.Hour))
+	assert.InDelta(t, 0.5, w, 1e-9)
+}
+
+func TestInvalidWeightStrategy(t *testing.T) {
+	for _, w := range []float64{-1, math.NaN(), math.Inf(1)} {
+		_, err := graph.NewGraph(t.Context(), graph.GraphConfig{
+			Packages: []graph.Package{skippablePackage()},
+			AsOf:     edgeWeightsAsOf,
+			WeightStrategy: graph.WeightStrategyFunc(func(graph.WeightedEdge, time.Time) float64 {
+				return w
+			}),
+		})
+		assert.ErrorContains(t, err, "invalid weight", "%v", w)
+	}
+}
@@ -0,0 +1,70 @@
+// Package util provides the slice, map, and comparison helpers that the graph
+// package and its consumers use to work with nodes, streams, and other values
+// in a deterministic order.
+package util
+
+import (
+	"hash/fnv"
+	"iter"
+	"maps"
+	"slices"
+)
+
+// KeySlice returns a map of the values of s by their keys. If several values
+// have the same key, the last one is kept.
+func KeySlice[K comparable, V any](s []V, key func(V) K) map[K]V {
+	m := make(map[K]V, len(s))
+	for _, v := range s {
+		m[key(v)] = v
+	}
+	return m
+}
+
+// MapSlice returns the result of calling f on each value of in, in order. It
+// returns an empty, non-nil slice if in is empty, so that mapped slices are
+// encoded as empty JSON arrays rather than null.
+func MapSlice[I, O any](in []I, f func(I) O) []O {
+	out := make([]O, len(in))
+	for i := range in {
+		out[i] = f(in[i])
+	}
+	return out
+}
+
+// OrderedMap yields the entries of m ordered by their keys, as sorted by cmp,
+// rather than in Go's random map order.
+func OrderedMap[K comparable, V any](m map[K]V, cmp func(a, b K) int) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		orderedKeys := slices.SortedFunc(maps.Keys(m), cmp)
+		for _, k := range orderedKeys {
+			v := m[k]
+			if !yield(k, v) {
+				return
+			}
+		}
+	}
+}
+
+// Comparer is implemented by types that order their values, such as
+// graph.Node and graph.MajorMinor. Compare returns a negative number if the
+// value is less than the other, zero if they are equal, and a positive number
+// if it is greater.
+type Comparer[T any] interface {
+	Compare(T) int
+}
+
+// Compare compares a and b with their Compare method, so that it can be
+// passed to functions like slices.SortFunc and OrderedMap.
+func Compare[T Comparer[T]](a, b T) int {
+	return a.Compare(b)
+}
+
+// HashString returns the 64-bit FNV-1a hash of s. The hash never changes, so
+// it is safe to persist, as the IDs of graph nodes are.
+func HashString(s string) uint64 {
+	h := fnv.New64a()
+	if _, err := h.Write([]byte(s)); err != nil {
+		panic(err)
+	}
+	return h.Sum64()
+}
@@ -0,0 +1,64 @@
+package util_test
+
+import (
+	"cmp"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/joelanford/extensiondb/pkg/graph"
+	"github.com/joelanford/extensiondb/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeySlice(t *testing.T) {
+	m := util.KeySlice([]string{"a", "bb", "cc"}, func(s string) int { return len(s) })
+	assert.Equal(t, map[int]string{1: "a", 2: "cc"}, m, "the last value with a key is kept")
+}
+
+func TestMapSlice(t *testing.T) {
+	assert.Equal(t, []string{"1", "2", "3"}, util.MapSlice([]int{1, 2, 3}, strconv.Itoa))
+
+	out := util.MapSlice[int, string](nil, strconv.Itoa)
+	assert.NotNil(t, out)
+	data, err := json.Marshal(out)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[]`, string(data))
+}
+
+func TestOrderedMap(t *testing.T) {
+	m := map[string]int{"c": 3, "a": 1, "b": 2}
+	var keys []string
+	var values []int
+	for k, v := range util.OrderedMap(m, cmp.Compare) {
+		keys = append(keys, k)
+		values = append(values, v)
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, keys)
+	assert.Equal(t, []int{1, 2, 3}, values)
+
+	// Iteration stops when the loop breaks.
+	keys = nil
+	for k := range util.OrderedMap(m, func(a, b string) int { return cmp.Compare(b, a) }) {
+		keys = append(keys, k)
+		if len(keys) == 2 {
+			break
+		}
+	}
+	assert.Equal(t, []string{"c", "b"}, keys)
+}
+
+func TestCompare(t *testing.T) {
+	mms := []graph.MajorMinor{{Major: 4, Minor: 16}, {Major: 1, Minor: 2}, {Major: 4, Minor: 2}}
+	slices.SortFunc(mms, util.Compare)
+	assert.Equal(t, []graph.MajorMinor{{Major: 1, Minor: 2}, {Major: 4, Minor: 2}, {Major: 4, Minor: 16}}, mms)
+}
+
+func TestHashString(t *testing.T) {
+	// Hashes are persisted, so they must never change.
+	assert.Equal(t, uint64(0xcbf29ce484222325), util.HashString(""))
+	assert.Equal(t, uint64(0xaf63dc4c8601ec8c), util.HashString("a"))
+	assert.NotEqual(t, util.HashString("foo.v1.0.0"), util.HashString("foo.v1.0.1"))
+}